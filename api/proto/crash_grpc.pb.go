@@ -0,0 +1,538 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: crash.proto
+
+package proto
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	CrashService_SubmitCrash_FullMethodName       = "/inceptor.v1.CrashService/SubmitCrash"
+	CrashService_SubmitCrashBatch_FullMethodName  = "/inceptor.v1.CrashService/SubmitCrashBatch"
+	CrashService_SubmitCrashStream_FullMethodName = "/inceptor.v1.CrashService/SubmitCrashStream"
+	CrashService_GetCrash_FullMethodName          = "/inceptor.v1.CrashService/GetCrash"
+	CrashService_ListCrashes_FullMethodName       = "/inceptor.v1.CrashService/ListCrashes"
+	CrashService_ListCrashesStream_FullMethodName = "/inceptor.v1.CrashService/ListCrashesStream"
+)
+
+// CrashServiceClient is the client API for CrashService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type CrashServiceClient interface {
+	// SubmitCrash submits a single crash report
+	SubmitCrash(ctx context.Context, in *CrashReport, opts ...grpc.CallOption) (*CrashResponse, error)
+	// SubmitCrashBatch submits multiple crash reports
+	SubmitCrashBatch(ctx context.Context, in *CrashBatchRequest, opts ...grpc.CallOption) (*CrashBatchResponse, error)
+	// SubmitCrashStream submits crash reports via streaming
+	SubmitCrashStream(ctx context.Context, opts ...grpc.CallOption) (CrashService_SubmitCrashStreamClient, error)
+	// GetCrash retrieves a single crash by ID
+	GetCrash(ctx context.Context, in *GetCrashRequest, opts ...grpc.CallOption) (*CrashReport, error)
+	// ListCrashes lists crashes with filters
+	ListCrashes(ctx context.Context, in *ListCrashesRequest, opts ...grpc.CallOption) (*ListCrashesResponse, error)
+	// ListCrashesStream lists crashes via streaming
+	ListCrashesStream(ctx context.Context, in *ListCrashesRequest, opts ...grpc.CallOption) (CrashService_ListCrashesStreamClient, error)
+}
+
+type crashServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCrashServiceClient(cc grpc.ClientConnInterface) CrashServiceClient {
+	return &crashServiceClient{cc}
+}
+
+func (c *crashServiceClient) SubmitCrash(ctx context.Context, in *CrashReport, opts ...grpc.CallOption) (*CrashResponse, error) {
+	out := new(CrashResponse)
+	err := c.cc.Invoke(ctx, CrashService_SubmitCrash_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *crashServiceClient) SubmitCrashBatch(ctx context.Context, in *CrashBatchRequest, opts ...grpc.CallOption) (*CrashBatchResponse, error) {
+	out := new(CrashBatchResponse)
+	err := c.cc.Invoke(ctx, CrashService_SubmitCrashBatch_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *crashServiceClient) SubmitCrashStream(ctx context.Context, opts ...grpc.CallOption) (CrashService_SubmitCrashStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &CrashService_ServiceDesc.Streams[0], CrashService_SubmitCrashStream_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &crashServiceSubmitCrashStreamClient{stream}
+	return x, nil
+}
+
+type CrashService_SubmitCrashStreamClient interface {
+	Send(*CrashReport) error
+	CloseAndRecv() (*CrashBatchResponse, error)
+	grpc.ClientStream
+}
+
+type crashServiceSubmitCrashStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *crashServiceSubmitCrashStreamClient) Send(m *CrashReport) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *crashServiceSubmitCrashStreamClient) CloseAndRecv() (*CrashBatchResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(CrashBatchResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *crashServiceClient) GetCrash(ctx context.Context, in *GetCrashRequest, opts ...grpc.CallOption) (*CrashReport, error) {
+	out := new(CrashReport)
+	err := c.cc.Invoke(ctx, CrashService_GetCrash_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *crashServiceClient) ListCrashes(ctx context.Context, in *ListCrashesRequest, opts ...grpc.CallOption) (*ListCrashesResponse, error) {
+	out := new(ListCrashesResponse)
+	err := c.cc.Invoke(ctx, CrashService_ListCrashes_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *crashServiceClient) ListCrashesStream(ctx context.Context, in *ListCrashesRequest, opts ...grpc.CallOption) (CrashService_ListCrashesStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &CrashService_ServiceDesc.Streams[1], CrashService_ListCrashesStream_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &crashServiceListCrashesStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CrashService_ListCrashesStreamClient interface {
+	Recv() (*CrashReport, error)
+	grpc.ClientStream
+}
+
+type crashServiceListCrashesStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *crashServiceListCrashesStreamClient) Recv() (*CrashReport, error) {
+	m := new(CrashReport)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// CrashServiceServer is the server API for CrashService service.
+// All implementations must embed UnimplementedCrashServiceServer
+// for forward compatibility
+type CrashServiceServer interface {
+	// SubmitCrash submits a single crash report
+	SubmitCrash(context.Context, *CrashReport) (*CrashResponse, error)
+	// SubmitCrashBatch submits multiple crash reports
+	SubmitCrashBatch(context.Context, *CrashBatchRequest) (*CrashBatchResponse, error)
+	// SubmitCrashStream submits crash reports via streaming
+	SubmitCrashStream(CrashService_SubmitCrashStreamServer) error
+	// GetCrash retrieves a single crash by ID
+	GetCrash(context.Context, *GetCrashRequest) (*CrashReport, error)
+	// ListCrashes lists crashes with filters
+	ListCrashes(context.Context, *ListCrashesRequest) (*ListCrashesResponse, error)
+	// ListCrashesStream lists crashes via streaming
+	ListCrashesStream(*ListCrashesRequest, CrashService_ListCrashesStreamServer) error
+	mustEmbedUnimplementedCrashServiceServer()
+}
+
+// UnimplementedCrashServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedCrashServiceServer struct {
+}
+
+func (UnimplementedCrashServiceServer) SubmitCrash(context.Context, *CrashReport) (*CrashResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitCrash not implemented")
+}
+func (UnimplementedCrashServiceServer) SubmitCrashBatch(context.Context, *CrashBatchRequest) (*CrashBatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitCrashBatch not implemented")
+}
+func (UnimplementedCrashServiceServer) SubmitCrashStream(CrashService_SubmitCrashStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method SubmitCrashStream not implemented")
+}
+func (UnimplementedCrashServiceServer) GetCrash(context.Context, *GetCrashRequest) (*CrashReport, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCrash not implemented")
+}
+func (UnimplementedCrashServiceServer) ListCrashes(context.Context, *ListCrashesRequest) (*ListCrashesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListCrashes not implemented")
+}
+func (UnimplementedCrashServiceServer) ListCrashesStream(*ListCrashesRequest, CrashService_ListCrashesStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListCrashesStream not implemented")
+}
+func (UnimplementedCrashServiceServer) mustEmbedUnimplementedCrashServiceServer() {}
+
+// UnsafeCrashServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CrashServiceServer will
+// result in compilation errors.
+type UnsafeCrashServiceServer interface {
+	mustEmbedUnimplementedCrashServiceServer()
+}
+
+func RegisterCrashServiceServer(s grpc.ServiceRegistrar, srv CrashServiceServer) {
+	s.RegisterService(&CrashService_ServiceDesc, srv)
+}
+
+func _CrashService_SubmitCrash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CrashReport)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CrashServiceServer).SubmitCrash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CrashService_SubmitCrash_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CrashServiceServer).SubmitCrash(ctx, req.(*CrashReport))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CrashService_SubmitCrashBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CrashBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CrashServiceServer).SubmitCrashBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CrashService_SubmitCrashBatch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CrashServiceServer).SubmitCrashBatch(ctx, req.(*CrashBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CrashService_SubmitCrashStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(CrashServiceServer).SubmitCrashStream(&crashServiceSubmitCrashStreamServer{stream})
+}
+
+type CrashService_SubmitCrashStreamServer interface {
+	SendAndClose(*CrashBatchResponse) error
+	Recv() (*CrashReport, error)
+	grpc.ServerStream
+}
+
+type crashServiceSubmitCrashStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *crashServiceSubmitCrashStreamServer) SendAndClose(m *CrashBatchResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *crashServiceSubmitCrashStreamServer) Recv() (*CrashReport, error) {
+	m := new(CrashReport)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _CrashService_GetCrash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCrashRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CrashServiceServer).GetCrash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CrashService_GetCrash_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CrashServiceServer).GetCrash(ctx, req.(*GetCrashRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CrashService_ListCrashes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCrashesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CrashServiceServer).ListCrashes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CrashService_ListCrashes_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CrashServiceServer).ListCrashes(ctx, req.(*ListCrashesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CrashService_ListCrashesStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListCrashesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CrashServiceServer).ListCrashesStream(m, &crashServiceListCrashesStreamServer{stream})
+}
+
+type CrashService_ListCrashesStreamServer interface {
+	Send(*CrashReport) error
+	grpc.ServerStream
+}
+
+type crashServiceListCrashesStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *crashServiceListCrashesStreamServer) Send(m *CrashReport) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// CrashService_ServiceDesc is the grpc.ServiceDesc for CrashService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var CrashService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "inceptor.v1.CrashService",
+	HandlerType: (*CrashServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SubmitCrash",
+			Handler:    _CrashService_SubmitCrash_Handler,
+		},
+		{
+			MethodName: "SubmitCrashBatch",
+			Handler:    _CrashService_SubmitCrashBatch_Handler,
+		},
+		{
+			MethodName: "GetCrash",
+			Handler:    _CrashService_GetCrash_Handler,
+		},
+		{
+			MethodName: "ListCrashes",
+			Handler:    _CrashService_ListCrashes_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SubmitCrashStream",
+			Handler:       _CrashService_SubmitCrashStream_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "ListCrashesStream",
+			Handler:       _CrashService_ListCrashesStream_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "crash.proto",
+}
+
+const (
+	GroupService_GetGroup_FullMethodName          = "/inceptor.v1.GroupService/GetGroup"
+	GroupService_ListGroups_FullMethodName        = "/inceptor.v1.GroupService/ListGroups"
+	GroupService_UpdateGroupStatus_FullMethodName = "/inceptor.v1.GroupService/UpdateGroupStatus"
+)
+
+// GroupServiceClient is the client API for GroupService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type GroupServiceClient interface {
+	// GetGroup retrieves a crash group by ID
+	GetGroup(ctx context.Context, in *GetGroupRequest, opts ...grpc.CallOption) (*CrashGroup, error)
+	// ListGroups lists crash groups
+	ListGroups(ctx context.Context, in *ListGroupsRequest, opts ...grpc.CallOption) (*ListGroupsResponse, error)
+	// UpdateGroupStatus updates a group's status
+	UpdateGroupStatus(ctx context.Context, in *UpdateGroupStatusRequest, opts ...grpc.CallOption) (*CrashGroup, error)
+}
+
+type groupServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewGroupServiceClient(cc grpc.ClientConnInterface) GroupServiceClient {
+	return &groupServiceClient{cc}
+}
+
+func (c *groupServiceClient) GetGroup(ctx context.Context, in *GetGroupRequest, opts ...grpc.CallOption) (*CrashGroup, error) {
+	out := new(CrashGroup)
+	err := c.cc.Invoke(ctx, GroupService_GetGroup_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *groupServiceClient) ListGroups(ctx context.Context, in *ListGroupsRequest, opts ...grpc.CallOption) (*ListGroupsResponse, error) {
+	out := new(ListGroupsResponse)
+	err := c.cc.Invoke(ctx, GroupService_ListGroups_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *groupServiceClient) UpdateGroupStatus(ctx context.Context, in *UpdateGroupStatusRequest, opts ...grpc.CallOption) (*CrashGroup, error) {
+	out := new(CrashGroup)
+	err := c.cc.Invoke(ctx, GroupService_UpdateGroupStatus_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GroupServiceServer is the server API for GroupService service.
+// All implementations must embed UnimplementedGroupServiceServer
+// for forward compatibility
+type GroupServiceServer interface {
+	// GetGroup retrieves a crash group by ID
+	GetGroup(context.Context, *GetGroupRequest) (*CrashGroup, error)
+	// ListGroups lists crash groups
+	ListGroups(context.Context, *ListGroupsRequest) (*ListGroupsResponse, error)
+	// UpdateGroupStatus updates a group's status
+	UpdateGroupStatus(context.Context, *UpdateGroupStatusRequest) (*CrashGroup, error)
+	mustEmbedUnimplementedGroupServiceServer()
+}
+
+// UnimplementedGroupServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedGroupServiceServer struct {
+}
+
+func (UnimplementedGroupServiceServer) GetGroup(context.Context, *GetGroupRequest) (*CrashGroup, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetGroup not implemented")
+}
+func (UnimplementedGroupServiceServer) ListGroups(context.Context, *ListGroupsRequest) (*ListGroupsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListGroups not implemented")
+}
+func (UnimplementedGroupServiceServer) UpdateGroupStatus(context.Context, *UpdateGroupStatusRequest) (*CrashGroup, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateGroupStatus not implemented")
+}
+func (UnimplementedGroupServiceServer) mustEmbedUnimplementedGroupServiceServer() {}
+
+// UnsafeGroupServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to GroupServiceServer will
+// result in compilation errors.
+type UnsafeGroupServiceServer interface {
+	mustEmbedUnimplementedGroupServiceServer()
+}
+
+func RegisterGroupServiceServer(s grpc.ServiceRegistrar, srv GroupServiceServer) {
+	s.RegisterService(&GroupService_ServiceDesc, srv)
+}
+
+func _GroupService_GetGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetGroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GroupServiceServer).GetGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GroupService_GetGroup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GroupServiceServer).GetGroup(ctx, req.(*GetGroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GroupService_ListGroups_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListGroupsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GroupServiceServer).ListGroups(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GroupService_ListGroups_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GroupServiceServer).ListGroups(ctx, req.(*ListGroupsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GroupService_UpdateGroupStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateGroupStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GroupServiceServer).UpdateGroupStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GroupService_UpdateGroupStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GroupServiceServer).UpdateGroupStatus(ctx, req.(*UpdateGroupStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// GroupService_ServiceDesc is the grpc.ServiceDesc for GroupService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var GroupService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "inceptor.v1.GroupService",
+	HandlerType: (*GroupServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetGroup",
+			Handler:    _GroupService_GetGroup_Handler,
+		},
+		{
+			MethodName: "ListGroups",
+			Handler:    _GroupService_ListGroups_Handler,
+		},
+		{
+			MethodName: "UpdateGroupStatus",
+			Handler:    _GroupService_UpdateGroupStatus_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "crash.proto",
+}
@@ -6,13 +6,16 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/flakerimi/inceptor/internal/api/grpc"
 	"github.com/flakerimi/inceptor/internal/api/rest"
 	"github.com/flakerimi/inceptor/internal/auth"
 	"github.com/flakerimi/inceptor/internal/config"
 	"github.com/flakerimi/inceptor/internal/core"
+	"github.com/flakerimi/inceptor/internal/ingest"
 	"github.com/flakerimi/inceptor/internal/storage"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -23,7 +26,8 @@ var version = "1.0.5"
 
 func main() {
 	// Parse flags
-	configPath := flag.String("config", "", "Path to configuration file")
+	configPath := flag.String("config", "", "Path to configuration file; separate several with commas, later files overriding earlier ones")
+	watchConfig := flag.Bool("watch-config", false, "Apply changes to hot-reloadable settings when a configuration file changes")
 	flag.Parse()
 
 	// Setup logging
@@ -31,7 +35,7 @@ func main() {
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339})
 
 	// Load configuration
-	cfg, err := config.Load(*configPath)
+	cfg, err := config.Load(strings.Split(*configPath, ",")...)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to load configuration")
 	}
@@ -39,13 +43,18 @@ func main() {
 	log.Info().Msg("Starting Inceptor - Crash Logging Service")
 
 	// Initialize storage
-	repo, err := storage.NewSQLiteRepository(cfg.Storage.SQLitePath)
+	repo, err := storage.NewRepository(cfg.Storage)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to initialize database")
 	}
-	defer repo.Close()
+	// Closed last, after everything that writes to it has stopped
+	defer func() {
+		if err := repo.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close database")
+		}
+	}()
 
-	fileStore, err := storage.NewLocalFileStore(cfg.Storage.LogsPath)
+	fileStore, err := storage.NewFileStore(cfg.Storage)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to initialize file store")
 	}
@@ -62,12 +71,23 @@ func main() {
 		cfg.Alerts.Slack.WebhookURL,
 	)
 	defer alerter.Close()
+	alerter.SetDashboardURL(cfg.Alerts.DashboardURL)
+	if cfg.Alerts.ActionLinks.Secret != "" {
+		alerter.SetActionLinks(core.NewActionLinks(cfg.Alerts.ActionLinks.Secret, cfg.Alerts.ActionLinks.TTL))
+	}
 
 	// Load existing alerts
 	alerts, err := repo.ListAlerts(context.Background(), "")
 	if err == nil {
 		alerter.SetAlerts(alerts)
 	}
+	if apps, err := repo.ListApps(context.Background()); err == nil {
+		for _, app := range apps {
+			alerter.SetMaintenanceWindows(app.ID, app.MaintenanceWindows)
+		}
+	}
+	alerter.StartThresholdEvaluator(repo, cfg.Alerts.ThresholdInterval)
+	alerter.StartDurableQueue(repo)
 
 	// Initialize retention manager
 	retention := core.NewRetentionManager(
@@ -79,16 +99,85 @@ func main() {
 	retention.Start()
 	defer retention.Stop()
 
-	// Initialize auth manager
+	// Initialize crash exporter
+	if cfg.Export.Enabled {
+		sink, err := storage.NewExportSink(cfg.Export)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to initialize export destination")
+		}
+		exporter := core.NewExporter(
+			repo,
+			fileStore,
+			sink,
+			cfg.Export.Interval,
+			cfg.Export.Delay,
+			cfg.Export.BatchSize,
+		)
+		exporter.Start()
+		defer exporter.Stop()
+	}
+
+	// Initialize auth manager. On first run the admin account takes over
+	// the shared dashboard password, if one was set.
+	var sessions auth.SessionStore
+	switch cfg.Auth.SessionStore {
+	case "database":
+		sessions = repo
+	case "memory":
+	default:
+		log.Fatal().Str("session_store", cfg.Auth.SessionStore).Msg("Invalid auth.session_store, expected database or memory")
+	}
+	authManager := auth.NewManager(repo, sessions)
+	authManager.SetBcryptCost(cfg.Auth.BcryptCost)
 	passwordHash, _ := repo.GetSetting(context.Background(), "password_hash")
-	authManager := auth.NewManager(passwordHash, func(hash string) {
-		if err := repo.SetSetting(context.Background(), "password_hash", hash); err != nil {
-			log.Error().Err(err).Msg("Failed to save password hash")
+	if err := authManager.Bootstrap(context.Background(), passwordHash); err != nil {
+		log.Fatal().Err(err).Msg("Failed to create admin account")
+	}
+	authManager.StartCleanup(cfg.Auth.SessionCleanupInterval)
+	if cfg.Auth.TrustedProxy.Enabled {
+		trustedProxy, err := auth.NewTrustedProxy(cfg.Auth.TrustedProxy.Header, cfg.Auth.TrustedProxy.CIDRs, core.UserRole(cfg.Auth.TrustedProxy.DefaultRole))
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid trusted proxy configuration")
+		}
+		authManager.SetTrustedProxy(trustedProxy)
+		log.Warn().
+			Str("header", cfg.Auth.TrustedProxy.Header).
+			Strs("cidrs", cfg.Auth.TrustedProxy.CIDRs).
+			Str("default_role", cfg.Auth.TrustedProxy.DefaultRole).
+			Msg("Dashboard login through trusted proxy enabled")
+	}
+	defer authManager.StopCleanup()
+
+	// Per-app rate limits are shared by the REST and gRPC servers
+	limiter := core.NewRateLimiter(cfg.Ingest.RateLimit.Rate, cfg.Ingest.RateLimit.Burst)
+	// So is recording when API keys were last used
+	keyUsage := core.NewAPIKeyUsageTracker(repo, core.APIKeyUsageInterval)
+	// And the ingest path, so both apply the same limits, grouping and alerts
+	pipeline := ingest.NewPipeline(repo, fileStore, alerter, cfg)
+
+	if *watchConfig {
+		err := config.Watch(context.Background(), cfg, func(newCfg *config.Config, changes []config.Change) {
+			for _, change := range changes {
+				if !change.HotReload() {
+					log.Warn().Str("setting", change.Key).Msg("Config setting changed; restart to apply it")
+					continue
+				}
+				log.Info().
+					Str("setting", change.Key).
+					Interface("old", change.Old).
+					Interface("new", change.New).
+					Msg("Config setting reloaded")
+			}
+			retention.SetDefaultDays(newCfg.Retention.DefaultDays)
+			limiter.SetDefaults(newCfg.Ingest.RateLimit.Rate, newCfg.Ingest.RateLimit.Burst)
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to watch configuration files")
 		}
-	})
+	}
 
 	// Initialize REST server
-	restServer := rest.NewServer(repo, fileStore, alerter, authManager, cfg.Auth.AdminKey, version)
+	restServer := rest.NewServer(repo, fileStore, alerter, pipeline, limiter, keyUsage, authManager, cfg, version)
 
 	// Start servers
 	errChan := make(chan error, 2)
@@ -102,17 +191,18 @@ func main() {
 		}
 	}()
 
-	// gRPC server (optional - uncomment when proto is compiled)
-	/*
-	go func() {
-		grpcServer := grpc.NewServer(repo, fileStore, alerter, cfg.Auth.AdminKey)
-		addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.GRPCPort)
-		log.Info().Str("addr", addr).Msg("Starting gRPC server")
-		if err := grpcServer.Run(addr); err != nil {
-			errChan <- fmt.Errorf("gRPC server error: %w", err)
-		}
-	}()
-	*/
+	// gRPC server, unless its port is set to 0
+	var grpcServer *grpc.Server
+	if cfg.Server.GRPCPort > 0 {
+		grpcServer = grpc.NewServer(repo, fileStore, pipeline, limiter, keyUsage, cfg)
+		go func() {
+			addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.GRPCPort)
+			log.Info().Str("addr", addr).Msg("Starting gRPC server")
+			if err := grpcServer.Run(addr); err != nil {
+				errChan <- fmt.Errorf("gRPC server error: %w", err)
+			}
+		}()
+	}
 
 	// Wait for shutdown signal
 	sigChan := make(chan os.Signal, 1)
@@ -126,4 +216,21 @@ func main() {
 	}
 
 	log.Info().Msg("Shutting down gracefully...")
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	// Stop taking requests before draining the alerts they may still raise
+	if err := restServer.Shutdown(ctx); err != nil {
+		log.Warn().Err(err).Msg("REST server did not shut down cleanly")
+	}
+	if grpcServer != nil {
+		if err := grpcServer.Shutdown(ctx); err != nil {
+			log.Warn().Err(err).Msg("gRPC server did not shut down cleanly")
+		}
+	}
+	keyUsage.Wait()
+	if err := alerter.Shutdown(ctx); err != nil {
+		log.Warn().Err(err).Msg("Queued alerts were not all delivered")
+	}
+	// Background jobs and the database are closed by the deferred calls
 }
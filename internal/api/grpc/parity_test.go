@@ -0,0 +1,364 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pb "github.com/flakerimi/inceptor/api/proto"
+	"github.com/flakerimi/inceptor/internal/api/rest"
+	"github.com/flakerimi/inceptor/internal/auth"
+	"github.com/flakerimi/inceptor/internal/config"
+	"github.com/flakerimi/inceptor/internal/core"
+	"github.com/flakerimi/inceptor/internal/ingest"
+	"github.com/flakerimi/inceptor/internal/storage"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// testAdminKey is the admin API key of parity test servers
+const testAdminKey = "test-admin-key"
+
+// parityServers are a gRPC server listening on a loopback port and a REST
+// router, sharing one repository and ingest pipeline
+type parityServers struct {
+	t       *testing.T
+	router  http.Handler
+	crashes pb.CrashServiceClient
+	groups  pb.GroupServiceClient
+}
+
+func newParityServers(t *testing.T) *parityServers {
+	t.Helper()
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	dir := t.TempDir()
+	cfg.Auth.AdminKey = testAdminKey
+	cfg.Storage.LogsPath = filepath.Join(dir, "crashes")
+	cfg.Ingest.Async.QueueSize = 0
+
+	repo := newTestRepository(t)
+	files, err := storage.NewLocalFileStore(cfg.Storage.LogsPath)
+	if err != nil {
+		t.Fatalf("open file store: %v", err)
+	}
+	alerter := core.NewAlertManager(core.SMTPConfig{}, "")
+	t.Cleanup(alerter.Close)
+	authManager := auth.NewManager(repo, repo)
+	if err := authManager.Bootstrap(context.Background(), ""); err != nil {
+		t.Fatalf("bootstrap auth: %v", err)
+	}
+	pipeline := ingest.NewPipeline(repo, files, alerter, cfg)
+	limiter := core.NewRateLimiter(cfg.Ingest.RateLimit.Rate, cfg.Ingest.RateLimit.Burst)
+
+	restServer := rest.NewServer(repo, files, alerter, pipeline, limiter, nil, authManager, cfg, "test")
+	t.Cleanup(restServer.Close)
+
+	grpcServer := NewServer(repo, files, pipeline, limiter, nil, cfg)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go grpcServer.Serve(lis)
+	t.Cleanup(func() { grpcServer.Shutdown(context.Background()) })
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return &parityServers{
+		t:       t,
+		router:  restServer.Router(),
+		crashes: pb.NewCrashServiceClient(conn),
+		groups:  pb.NewGroupServiceClient(conn),
+	}
+}
+
+// rest sends a REST request authenticated with key, failing the test unless
+// it gets wantStatus, and returns the decoded response
+func (ps *parityServers) rest(method, path, key string, body interface{}, wantStatus int) map[string]interface{} {
+	ps.t.Helper()
+
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			ps.t.Fatalf("marshal body: %v", err)
+		}
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", key)
+	w := httptest.NewRecorder()
+	ps.router.ServeHTTP(w, req)
+	if w.Code != wantStatus {
+		ps.t.Fatalf("%s %s: status %d, want %d: %s", method, path, w.Code, wantStatus, w.Body.String())
+	}
+	var resp map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		ps.t.Fatalf("decode %s %s: %v", method, path, err)
+	}
+	return resp
+}
+
+// submit submits a crash of errorType over gRPC with an app's key
+func (ps *parityServers) submit(key, errorType string) *pb.CrashResponse {
+	ps.t.Helper()
+
+	resp, err := ps.crashes.SubmitCrash(keyContext(key), &pb.CrashReport{
+		AppVersion:   "1.0.0",
+		Platform:     core.PlatformAndroid,
+		ErrorType:    errorType,
+		ErrorMessage: "Bad state",
+		UserId:       "user-1",
+		StackTrace:   []*pb.StackFrame{{FileName: "lib/main.dart", LineNumber: 10, MethodName: "main", ClassName: "App"}},
+	})
+	if err != nil {
+		ps.t.Fatalf("submit %s: %v", errorType, err)
+	}
+	return resp
+}
+
+// keyContext returns a context sending key as outgoing request metadata
+func keyContext(key string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "x-api-key", key)
+}
+
+// assertGroupParity checks that a gRPC group has the fields of the REST
+// API's JSON for it. Read-time fields may drift between the two calls.
+func assertGroupParity(t *testing.T, got *pb.CrashGroup, want map[string]interface{}) {
+	t.Helper()
+
+	timeField := func(name string) interface{} {
+		s, ok := want[name].(string)
+		if !ok {
+			return nil
+		}
+		at, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			t.Fatalf("%s = %q: %v", name, s, err)
+		}
+		return at.UTC()
+	}
+	protoTime := func(ts interface{ AsTime() time.Time }, valid bool) interface{} {
+		if !valid {
+			return nil
+		}
+		return ts.AsTime()
+	}
+
+	exact := []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"id", got.Id, want["id"]},
+		{"app_id", got.AppId, want["app_id"]},
+		{"fingerprint", got.Fingerprint, want["fingerprint"]},
+		{"title", got.Title, want["title"]},
+		{"error_type", got.ErrorType, want["error_type"]},
+		{"error_message", got.ErrorMessage, want["error_message"]},
+		{"occurrence_count", float64(got.OccurrenceCount), want["occurrence_count"]},
+		{"status", got.Status, want["status"]},
+		{"assigned_to", got.AssignedTo, stringField(want, "assigned_to")},
+		{"notes", got.Notes, stringField(want, "notes")},
+		{"aggregate_only", got.AggregateOnly, want["aggregate_only"]},
+		{"split_key", got.SplitKey, stringField(want, "split_key")},
+		{"culprit", got.Culprit, stringField(want, "culprit")},
+		{"affected_users", float64(got.AffectedUsers), numberField(want, "affected_users")},
+		{"latest_crash_id", got.LatestCrashId, stringField(want, "latest_crash_id")},
+		{"first_seen", protoTime(got.FirstSeen, got.FirstSeen != nil), timeField("first_seen")},
+		{"last_seen", protoTime(got.LastSeen, got.LastSeen != nil), timeField("last_seen")},
+		{"regressed_at", protoTime(got.RegressedAt, got.RegressedAt != nil), timeField("regressed_at")},
+	}
+	for _, f := range exact {
+		if gt, ok := f.got.(time.Time); ok {
+			if wt, ok := f.want.(time.Time); !ok || !gt.Equal(wt) {
+				t.Errorf("%s = %v, REST has %v", f.name, f.got, f.want)
+			}
+			continue
+		}
+		if f.got != f.want {
+			t.Errorf("%s = %v, REST has %v", f.name, f.got, f.want)
+		}
+	}
+
+	drifting := []struct {
+		name      string
+		got       float64
+		tolerance float64
+	}{
+		{"age", float64(got.Age), 1},
+		{"time_since_last_occurrence", float64(got.TimeSinceLastOccurrence), 1},
+		{"trending_score", got.TrendingScore, 0.01},
+	}
+	for _, f := range drifting {
+		want, _ := want[f.name].(float64)
+		if math.Abs(f.got-want) > f.tolerance {
+			t.Errorf("%s = %v, REST has %v", f.name, f.got, want)
+		}
+	}
+}
+
+// stringField returns a string field of a JSON object, "" if omitted
+func stringField(m map[string]interface{}, name string) string {
+	s, _ := m[name].(string)
+	return s
+}
+
+// numberField returns a number field of a JSON object, 0 if omitted
+func numberField(m map[string]interface{}, name string) float64 {
+	n, _ := m[name].(float64)
+	return n
+}
+
+func TestGroupParity(t *testing.T) {
+	ps := newParityServers(t)
+	app := ps.rest(http.MethodPost, "/api/v1/apps", testAdminKey, map[string]interface{}{"name": "Test App"}, http.StatusCreated)
+	appKey := app["api_key"].(string)
+	other := ps.rest(http.MethodPost, "/api/v1/apps", testAdminKey, map[string]interface{}{"name": "Other App"}, http.StatusCreated)
+	otherKey := other["api_key"].(string)
+
+	// A regressed group: resolved over REST, then reopened by a gRPC crash
+	regressed := ps.submit(appKey, "StateError").GroupId
+	ps.rest(http.MethodPatch, "/api/v1/groups/"+regressed, appKey, map[string]interface{}{"status": "resolved", "assigned_to": "alice", "notes": "fixed in 1.0.1"}, http.StatusOK)
+	reopening := ps.submit(appKey, "StateError").Id
+
+	// A group split off another
+	var splitIDs []string
+	var original string
+	for i := 0; i < 2; i++ {
+		resp := ps.submit(appKey, "RangeError")
+		splitIDs = append(splitIDs, resp.Id)
+		original = resp.GroupId
+	}
+	split := ps.rest(http.MethodPost, "/api/v1/groups/"+original+"/split", appKey, map[string]interface{}{"crash_ids": splitIDs[1:]}, http.StatusCreated)
+	splitGroup := split["new_group"].(map[string]interface{})["id"].(string)
+
+	ps.submit(otherKey, "StateError")
+
+	t.Run("GetGroup", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			id       string
+			key      string
+			wantCode codes.Code
+		}{
+			{"regressed", regressed, appKey, codes.OK},
+			{"split", splitGroup, appKey, codes.OK},
+			{"split from", original, appKey, codes.OK},
+			{"admin key", regressed, testAdminKey, codes.OK},
+			{"other app's key", regressed, otherKey, codes.PermissionDenied},
+			{"unknown group", "missing", appKey, codes.NotFound},
+			{"no key", regressed, "", codes.Unauthenticated},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				if tt.key != "" {
+					ctx = keyContext(tt.key)
+				}
+				got, err := ps.groups.GetGroup(ctx, &pb.GetGroupRequest{Id: tt.id})
+				if code := status.Code(err); code != tt.wantCode {
+					t.Fatalf("code = %v, want %v: %v", code, tt.wantCode, err)
+				}
+				if err != nil {
+					return
+				}
+				assertGroupParity(t, got, ps.rest(http.MethodGet, "/api/v1/groups/"+tt.id, tt.key, nil, http.StatusOK))
+			})
+		}
+
+		// The fields compared are set on the groups under test
+		got, err := ps.groups.GetGroup(keyContext(appKey), &pb.GetGroupRequest{Id: regressed})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.RegressedAt == nil || got.Status != "open" || got.AssignedTo != "alice" {
+			t.Errorf("regressed group = %v, want reopened with regressed_at and an assignee", got)
+		}
+		if got.Culprit != "App.main" || got.AffectedUsers != 1 || got.LatestCrashId != reopening {
+			t.Errorf("regressed group = %v, want culprit App.main, 1 affected user and latest crash %s", got, reopening)
+		}
+		if got, err := ps.groups.GetGroup(keyContext(appKey), &pb.GetGroupRequest{Id: splitGroup}); err != nil || got.SplitKey == "" {
+			t.Errorf("split group = %v, %v; want a split key", got, err)
+		}
+	})
+
+	t.Run("ListGroups", func(t *testing.T) {
+		tests := []struct {
+			name  string
+			key   string
+			req   *pb.ListGroupsRequest
+			query string
+		}{
+			{"app key", appKey, &pb.ListGroupsRequest{}, ""},
+			{"admin key", testAdminKey, &pb.ListGroupsRequest{}, ""},
+			{"admin key for an app", testAdminKey, &pb.ListGroupsRequest{AppId: other["id"].(string)}, "?app_id=" + other["id"].(string)},
+			{"error type", appKey, &pb.ListGroupsRequest{ErrorType: "RangeError"}, "?error_type=RangeError"},
+			{"sorted by trend", appKey, &pb.ListGroupsRequest{SortBy: "trending", SortOrder: "asc"}, "?sort_by=trending&sort_order=asc"},
+			{"paged", appKey, &pb.ListGroupsRequest{Limit: 2}, "?limit=2"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				got, err := ps.groups.ListGroups(keyContext(tt.key), tt.req)
+				if err != nil {
+					t.Fatalf("ListGroups: %v", err)
+				}
+				want := ps.rest(http.MethodGet, "/api/v1/groups"+tt.query, tt.key, nil, http.StatusOK)
+				data, _ := want["data"].([]interface{})
+				if float64(got.Total) != want["total"] || len(got.Groups) != len(data) || got.NextCursor != stringField(want, "next_cursor") {
+					t.Fatalf("total %d, %d groups, cursor %q; REST has %v, %d, %v",
+						got.Total, len(got.Groups), got.NextCursor, want["total"], len(data), want["next_cursor"])
+				}
+				for i, group := range got.Groups {
+					assertGroupParity(t, group, data[i].(map[string]interface{}))
+				}
+			})
+		}
+	})
+
+	t.Run("GetCrash", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			key      string
+			wantCode codes.Code
+		}{
+			{"app key", appKey, codes.OK},
+			{"admin key", testAdminKey, codes.OK},
+			{"other app's key", otherKey, codes.PermissionDenied},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				got, err := ps.crashes.GetCrash(keyContext(tt.key), &pb.GetCrashRequest{Id: splitIDs[0]})
+				if code := status.Code(err); code != tt.wantCode {
+					t.Fatalf("code = %v, want %v: %v", code, tt.wantCode, err)
+				}
+				if err == nil && (got.Id != splitIDs[0] || got.GroupId != original) {
+					t.Errorf("crash = %s in %s, want %s in %s", got.Id, got.GroupId, splitIDs[0], original)
+				}
+			})
+		}
+
+		// A crash split off is served in its new group, not the one its
+		// payload file was written with
+		got, err := ps.crashes.GetCrash(keyContext(appKey), &pb.GetCrashRequest{Id: splitIDs[1]})
+		if err != nil || got.GroupId != splitGroup || len(got.StackTrace) == 0 {
+			t.Errorf("split crash = %v, %v; want the full payload in %s", got, err, splitGroup)
+		}
+	})
+}
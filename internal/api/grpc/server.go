@@ -4,54 +4,79 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"strings"
 	"time"
 
+	pb "github.com/flakerimi/inceptor/api/proto"
+	"github.com/flakerimi/inceptor/internal/auth"
+	"github.com/flakerimi/inceptor/internal/config"
 	"github.com/flakerimi/inceptor/internal/core"
+	"github.com/flakerimi/inceptor/internal/ingest"
 	"github.com/flakerimi/inceptor/internal/storage"
-	"github.com/google/uuid"
-	"github.com/rs/zerolog/log"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-// Note: This file contains the gRPC server implementation.
-// The proto file needs to be compiled with protoc to generate the Go code.
-// For now, we'll define the interfaces and implementation manually.
-
-// CrashServiceServer is the gRPC server interface
-type CrashServiceServer interface {
-	SubmitCrash(context.Context, *CrashReport) (*CrashResponse, error)
-	SubmitCrashBatch(context.Context, *CrashBatchRequest) (*CrashBatchResponse, error)
-	SubmitCrashStream(CrashService_SubmitCrashStreamServer) error
-	GetCrash(context.Context, *GetCrashRequest) (*CrashReport, error)
-	ListCrashes(context.Context, *ListCrashesRequest) (*ListCrashesResponse, error)
-	ListCrashesStream(*ListCrashesRequest, CrashService_ListCrashesStreamServer) error
+// adminAppID is the app ID given to requests made with an admin key
+const adminAppID = "admin"
+
+// appContextKey is the context key of the app a request authenticated as
+type appContextKey struct{}
+
+// appFromContext returns the app the interceptors authenticated the request
+// as
+func appFromContext(ctx context.Context) (*core.App, error) {
+	app, ok := ctx.Value(appContextKey{}).(*core.App)
+	if !ok || app == nil {
+		return nil, status.Error(codes.Unauthenticated, "not authenticated")
+	}
+	return app, nil
 }
 
-// Server implements the gRPC crash service
+// Server implements the gRPC crash and group services
 type Server struct {
-	repo      storage.Repository
-	fileStore storage.FileStore
-	grouper   *core.Grouper
-	alerter   *core.AlertManager
-	adminKey  string
+	pb.UnimplementedCrashServiceServer
+	pb.UnimplementedGroupServiceServer
+
+	grpcServer *grpc.Server
+	repo       storage.Repository
+	fileStore  storage.FileStore
+	pipeline   *ingest.Pipeline
+	limiter    *core.RateLimiter
+	keyUsage   *core.APIKeyUsageTracker
+	adminKeys  []string
+	// aggregateThreshold is ingest.aggregate_threshold
+	aggregateThreshold int
 }
 
-// NewServer creates a new gRPC server
-func NewServer(repo storage.Repository, fileStore storage.FileStore, alerter *core.AlertManager, adminKey string) *Server {
-	return &Server{
+// NewServer creates a new gRPC server. Crashes go through pipeline, shared
+// with the REST server so both ingest them the same way.
+func NewServer(repo storage.Repository, fileStore storage.FileStore, pipeline *ingest.Pipeline, limiter *core.RateLimiter, keyUsage *core.APIKeyUsageTracker, cfg *config.Config) *Server {
+	s := &Server{
 		repo:      repo,
 		fileStore: fileStore,
-		grouper:   core.NewGrouper(),
-		alerter:   alerter,
-		adminKey:  adminKey,
+		pipeline:  pipeline,
+		limiter:   limiter,
+		keyUsage:  keyUsage,
+		adminKeys: cfg.Auth.AllAdminKeys(),
+
+		aggregateThreshold: cfg.Ingest.AggregateThreshold,
 	}
+	s.grpcServer = grpc.NewServer(
+		grpc.UnaryInterceptor(s.authInterceptor),
+		grpc.StreamInterceptor(s.streamAuthInterceptor),
+	)
+	pb.RegisterCrashServiceServer(s.grpcServer, s)
+	pb.RegisterGroupServiceServer(s.grpcServer, s)
+	return s
 }
 
 // Run starts the gRPC server
@@ -60,17 +85,34 @@ func (s *Server) Run(addr string) error {
 	if err != nil {
 		return fmt.Errorf("failed to listen: %w", err)
 	}
+	return s.Serve(lis)
+}
 
-	grpcServer := grpc.NewServer(
-		grpc.UnaryInterceptor(s.authInterceptor),
-		grpc.StreamInterceptor(s.streamAuthInterceptor),
-	)
-
-	// Register service (would use generated code in production)
-	// pb.RegisterCrashServiceServer(grpcServer, s)
+// Serve serves gRPC requests on lis until the server is shut down
+func (s *Server) Serve(lis net.Listener) error {
+	if err := s.grpcServer.Serve(lis); !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+	return nil
+}
 
-	log.Info().Str("addr", addr).Msg("Starting gRPC server")
-	return grpcServer.Serve(lis)
+// Shutdown stops accepting requests and waits for in-flight ones to finish.
+// If ctx ends first, the remaining calls are cancelled and ctx's error is
+// returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.grpcServer.Stop()
+		return ctx.Err()
+	}
 }
 
 // authInterceptor handles authentication for unary calls
@@ -80,21 +122,39 @@ func (s *Server) authInterceptor(ctx context.Context, req interface{}, info *grp
 	if err != nil {
 		return nil, err
 	}
+	if err := authorize(app, info.FullMethod); err != nil {
+		return nil, err
+	}
 
 	// Add app to context
-	ctx = context.WithValue(ctx, "app", app)
+	ctx = context.WithValue(ctx, appContextKey{}, app)
 	return handler(ctx, req)
 }
 
+// authenticatedStream is a server stream whose context carries the app it
+// authenticated as
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
 // streamAuthInterceptor handles authentication for streaming calls
 func (s *Server) streamAuthInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
 	// Extract API key from metadata
-	_, err := s.authenticate(ss.Context())
+	app, err := s.authenticate(ss.Context())
 	if err != nil {
 		return err
 	}
+	if err := authorize(app, info.FullMethod); err != nil {
+		return err
+	}
 
-	return handler(srv, ss)
+	// Add app to the stream's context
+	return handler(srv, &authenticatedStream{ss, context.WithValue(ss.Context(), appContextKey{}, app)})
 }
 
 // authenticate validates the API key and returns the app
@@ -111,9 +171,9 @@ func (s *Server) authenticate(ctx context.Context) (*core.App, error) {
 
 	apiKey := apiKeys[0]
 
-	// Check admin key
-	if s.adminKey != "" && apiKey == s.adminKey {
-		return &core.App{ID: "admin", Name: "Admin"}, nil
+	// Check admin keys
+	if auth.MatchAdminKey(apiKey, s.adminKeys) {
+		return &core.App{ID: adminAppID, Name: "Admin"}, nil
 	}
 
 	// Hash and lookup
@@ -127,69 +187,106 @@ func (s *Server) authenticate(ctx context.Context) (*core.App, error) {
 		return nil, status.Error(codes.Unauthenticated, "invalid API key")
 	}
 
+	if app.APIKeyExpired(time.Now()) {
+		return nil, status.Error(codes.Unauthenticated, "API key expired")
+	}
+
+	s.keyUsage.Touch(app)
 	return app, nil
 }
 
-// SubmitCrash handles a single crash submission
-func (s *Server) SubmitCrash(ctx context.Context, req *CrashReport) (*CrashResponse, error) {
-	app := ctx.Value("app").(*core.App)
-
-	crash := protoToCrash(req)
-	crash.ID = uuid.New().String()
-	crash.AppID = app.ID
-	crash.CreatedAt = time.Now().UTC()
-
-	if crash.Environment == "" {
-		crash.Environment = core.EnvironmentProduction
+// authorize checks that the app's API key has the scope a method needs.
+// Admin keys have every scope.
+func authorize(app *core.App, fullMethod string) error {
+	if app.ID == adminAppID {
+		return nil
 	}
+	scope := methodScope(fullMethod)
+	if !app.HasScope(scope) {
+		return status.Errorf(codes.PermissionDenied, "API key lacks the %s scope", scope)
+	}
+	return nil
+}
 
-	// Generate fingerprint
-	crash.Fingerprint = s.grouper.GenerateFingerprint(crash)
-	crash.GroupID = uuid.New().String()
+// methodScope returns the API key scope a gRPC method needs: submitting
+// crashes takes submit, everything else read
+func methodScope(fullMethod string) string {
+	method := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+	if strings.HasPrefix(method, "SubmitCrash") {
+		return core.ScopeSubmit
+	}
+	return core.ScopeRead
+}
 
-	// Get or create group
-	group, isNewGroup, err := s.repo.GetOrCreateGroup(ctx, crash)
+// SubmitCrash handles a single crash submission
+func (s *Server) SubmitCrash(ctx context.Context, req *pb.CrashReport) (*pb.CrashResponse, error) {
+	app, err := appFromContext(ctx)
 	if err != nil {
-		return nil, status.Error(codes.Internal, "failed to process crash group")
+		return nil, err
 	}
-	crash.GroupID = group.ID
-
-	// Save to file store
-	if logPath, err := s.fileStore.SaveCrashLog(ctx, crash); err == nil {
-		crash.LogFilePath = logPath
+	if app.Disabled {
+		return nil, status.Error(codes.PermissionDenied, "app is disabled")
+	}
+	if s.limiter != nil {
+		if wait, ok := s.limiter.Allow(app, time.Now()); !ok {
+			return nil, status.Errorf(codes.ResourceExhausted, "crash submission rate limit exceeded, retry after %s", wait.Round(time.Millisecond))
+		}
 	}
 
-	// Save to database
-	if err := s.repo.CreateCrash(ctx, crash); err != nil {
-		return nil, status.Error(codes.Internal, "failed to save crash")
+	submission := protoToSubmission(req)
+	if err := s.pipeline.Validate(submission); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	// Send alert
-	if s.alerter != nil {
-		eventType := core.AlertEventNewCrash
-		if isNewGroup {
-			eventType = core.AlertEventNewGroup
+	var ip net.IP
+	if p, ok := peer.FromContext(ctx); ok {
+		if addr, ok := p.Addr.(*net.TCPAddr); ok {
+			ip = addr.IP
 		}
-		s.alerter.Notify(core.AlertEvent{
-			Type:       eventType,
-			AppID:      app.ID,
-			Crash:      crash,
-			Group:      group,
-			IsNewGroup: isNewGroup,
-		})
+	}
+	crash, duplicate, err := s.pipeline.Accept(ctx, app, submission, ip)
+	if errors.Is(err, core.ErrMetadataTooLarge) || errors.Is(err, core.ErrMetadataTooDeep) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if duplicate != nil {
+		return &pb.CrashResponse{
+			Id:                 duplicate.ID,
+			GroupId:            duplicate.GroupID,
+			Fingerprint:        duplicate.Fingerprint,
+			FingerprintVersion: int32(duplicate.FingerprintVersion),
+		}, nil
 	}
 
-	return &CrashResponse{
-		Id:         crash.ID,
-		GroupId:    crash.GroupID,
-		Fingerprint: crash.Fingerprint,
-		IsNewGroup: isNewGroup,
-	}, nil
+	resp := &pb.CrashResponse{
+		Id:                 crash.ID,
+		Fingerprint:        crash.Fingerprint,
+		FingerprintVersion: int32(crash.FingerprintVersion),
+	}
+	group, isNewGroup, err := s.pipeline.Store(ctx, crash)
+	if errors.Is(err, ingest.ErrDeferred) {
+		// Kept for reprocessing; there is no group yet
+		return resp, nil
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if group != nil && group.IsAggregating(s.aggregateThreshold) {
+		// Only the group's count was updated; there is no crash to refer to
+		resp.Id = ""
+	}
+	if group != nil {
+		resp.GroupId = group.ID
+	}
+	resp.IsNewGroup = isNewGroup
+	return resp, nil
 }
 
 // SubmitCrashBatch handles batch crash submission
-func (s *Server) SubmitCrashBatch(ctx context.Context, req *CrashBatchRequest) (*CrashBatchResponse, error) {
-	var results []*CrashResponse
+func (s *Server) SubmitCrashBatch(ctx context.Context, req *pb.CrashBatchRequest) (*pb.CrashBatchResponse, error) {
+	var results []*pb.CrashResponse
 	accepted := 0
 	rejected := 0
 
@@ -203,7 +300,7 @@ func (s *Server) SubmitCrashBatch(ctx context.Context, req *CrashBatchRequest) (
 		results = append(results, resp)
 	}
 
-	return &CrashBatchResponse{
+	return &pb.CrashBatchResponse{
 		Accepted: int32(accepted),
 		Rejected: int32(rejected),
 		Results:  results,
@@ -211,15 +308,15 @@ func (s *Server) SubmitCrashBatch(ctx context.Context, req *CrashBatchRequest) (
 }
 
 // SubmitCrashStream handles streaming crash submission
-func (s *Server) SubmitCrashStream(stream CrashService_SubmitCrashStreamServer) error {
+func (s *Server) SubmitCrashStream(stream pb.CrashService_SubmitCrashStreamServer) error {
 	accepted := 0
 	rejected := 0
-	var results []*CrashResponse
+	var results []*pb.CrashResponse
 
 	for {
 		crashReport, err := stream.Recv()
 		if err == io.EOF {
-			return stream.SendAndClose(&CrashBatchResponse{
+			return stream.SendAndClose(&pb.CrashBatchResponse{
 				Accepted: int32(accepted),
 				Rejected: int32(rejected),
 				Results:  results,
@@ -240,7 +337,12 @@ func (s *Server) SubmitCrashStream(stream CrashService_SubmitCrashStreamServer)
 }
 
 // GetCrash retrieves a single crash
-func (s *Server) GetCrash(ctx context.Context, req *GetCrashRequest) (*CrashReport, error) {
+func (s *Server) GetCrash(ctx context.Context, req *pb.GetCrashRequest) (*pb.CrashReport, error) {
+	app, err := appFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	crash, err := s.repo.GetCrash(ctx, req.Id)
 	if err != nil {
 		return nil, status.Error(codes.Internal, "failed to retrieve crash")
@@ -248,20 +350,22 @@ func (s *Server) GetCrash(ctx context.Context, req *GetCrashRequest) (*CrashRepo
 	if crash == nil {
 		return nil, status.Error(codes.NotFound, "crash not found")
 	}
+	if app.ID != adminAppID && crash.AppID != app.ID {
+		return nil, status.Error(codes.PermissionDenied, "access denied")
+	}
 
 	// Load full data from file
-	if crash.LogFilePath != "" {
-		if fullCrash, err := s.fileStore.GetCrashLog(ctx, crash.LogFilePath); err == nil && fullCrash != nil {
-			crash = fullCrash
-		}
-	}
+	crash = ingest.LoadCrashFile(ctx, s.fileStore, crash)
 
 	return crashToProto(crash), nil
 }
 
 // ListCrashes lists crashes
-func (s *Server) ListCrashes(ctx context.Context, req *ListCrashesRequest) (*ListCrashesResponse, error) {
-	app := ctx.Value("app").(*core.App)
+func (s *Server) ListCrashes(ctx context.Context, req *pb.ListCrashesRequest) (*pb.ListCrashesResponse, error) {
+	app, err := appFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	filter := storage.CrashFilter{
 		AppID:       app.ID,
@@ -289,19 +393,19 @@ func (s *Server) ListCrashes(ctx context.Context, req *ListCrashesRequest) (*Lis
 		return nil, status.Error(codes.Internal, "failed to list crashes")
 	}
 
-	protosCrashes := make([]*CrashReport, len(crashes))
+	protosCrashes := make([]*pb.CrashReport, len(crashes))
 	for i, c := range crashes {
 		protosCrashes[i] = crashToProto(c)
 	}
 
-	return &ListCrashesResponse{
+	return &pb.ListCrashesResponse{
 		Crashes: protosCrashes,
 		Total:   int32(total),
 	}, nil
 }
 
 // ListCrashesStream streams crashes
-func (s *Server) ListCrashesStream(req *ListCrashesRequest, stream CrashService_ListCrashesStreamServer) error {
+func (s *Server) ListCrashesStream(req *pb.ListCrashesRequest, stream pb.CrashService_ListCrashesStreamServer) error {
 	resp, err := s.ListCrashes(stream.Context(), req)
 	if err != nil {
 		return err
@@ -316,28 +420,116 @@ func (s *Server) ListCrashesStream(req *ListCrashesRequest, stream CrashService_
 	return nil
 }
 
+// GetGroup retrieves a single crash group
+func (s *Server) GetGroup(ctx context.Context, req *pb.GetGroupRequest) (*pb.CrashGroup, error) {
+	app, err := appFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	group, err := s.repo.GetGroup(ctx, req.Id)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve group")
+	}
+	if group == nil {
+		return nil, status.Error(codes.NotFound, "group not found")
+	}
+	if app.ID != adminAppID && group.AppID != app.ID {
+		return nil, status.Error(codes.PermissionDenied, "access denied")
+	}
+
+	if err := s.repo.SummarizeGroupCrashes(ctx, []*core.CrashGroup{group}); err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve group")
+	}
+	group.ComputeActivity(time.Now())
+	return groupToProto(group), nil
+}
+
+// ListGroups lists crash groups with filters, like the REST endpoint. App
+// keys only see their own app's groups.
+func (s *Server) ListGroups(ctx context.Context, req *pb.ListGroupsRequest) (*pb.ListGroupsResponse, error) {
+	app, err := appFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := storage.GroupFilter{
+		AppID:     req.AppId,
+		Status:    req.Status,
+		ErrorType: req.ErrorType,
+		Search:    req.Search,
+		StaleDays: int(req.StaleDays),
+		SortBy:    req.SortBy,
+		SortOrder: req.SortOrder,
+		Offset:    int(req.Offset),
+		Cursor:    req.Cursor,
+	}
+	if app.ID != adminAppID {
+		filter.AppID = app.ID
+	}
+	if filter.SortBy == "" {
+		filter.SortBy = "last_seen"
+	}
+	if filter.SortOrder == "" {
+		filter.SortOrder = "desc"
+	}
+
+	// Fetch one extra row to tell whether there is a next page
+	limit := int(req.Limit)
+	if limit <= 0 {
+		limit = 50
+	}
+	filter.Limit = limit + 1
+	groups, total, err := s.repo.ListGroups(ctx, filter)
+	if errors.Is(err, storage.ErrInvalidCursor) || errors.Is(err, storage.ErrCursorSort) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to list groups")
+	}
+
+	resp := &pb.ListGroupsResponse{Total: int32(total)}
+	if len(groups) > limit {
+		groups = groups[:limit]
+		// Cursors are only defined for time sorts
+		last := groups[limit-1]
+		switch filter.SortBy {
+		case "last_seen":
+			resp.NextCursor = storage.EncodeCursor(last.LastSeen, last.ID)
+		case "first_seen":
+			resp.NextCursor = storage.EncodeCursor(last.FirstSeen, last.ID)
+		}
+	}
+
+	if err := s.repo.SummarizeGroupCrashes(ctx, groups); err != nil {
+		return nil, status.Error(codes.Internal, "failed to list groups")
+	}
+	now := time.Now()
+	resp.Groups = make([]*pb.CrashGroup, len(groups))
+	for i, group := range groups {
+		group.ComputeActivity(now)
+		resp.Groups[i] = groupToProto(group)
+	}
+	return resp, nil
+}
+
 // Helper functions for converting between core types and proto types
 
-func protoToCrash(p *CrashReport) *core.Crash {
-	crash := &core.Crash{
-		ID:           p.Id,
-		AppID:        p.AppId,
+// protoToSubmission converts a crash report to a submission. IDs,
+// fingerprints and timestamps are assigned by the server, so the report's
+// are ignored.
+func protoToSubmission(p *pb.CrashReport) *core.CrashSubmission {
+	crash := &core.CrashSubmission{
 		AppVersion:   p.AppVersion,
 		Platform:     p.Platform,
 		OSVersion:    p.OsVersion,
 		DeviceModel:  p.DeviceModel,
 		ErrorType:    p.ErrorType,
 		ErrorMessage: p.ErrorMessage,
-		Fingerprint:  p.Fingerprint,
-		GroupID:      p.GroupId,
 		UserID:       p.UserId,
 		Environment:  p.Environment,
 	}
 
-	if p.CreatedAt != nil {
-		crash.CreatedAt = p.CreatedAt.AsTime()
-	}
-
 	for _, f := range p.StackTrace {
 		crash.StackTrace = append(crash.StackTrace, core.StackFrame{
 			FileName:     f.FileName,
@@ -378,25 +570,26 @@ func protoToCrash(p *CrashReport) *core.Crash {
 	return crash
 }
 
-func crashToProto(c *core.Crash) *CrashReport {
-	p := &CrashReport{
-		Id:           c.ID,
-		AppId:        c.AppID,
-		AppVersion:   c.AppVersion,
-		Platform:     c.Platform,
-		OsVersion:    c.OSVersion,
-		DeviceModel:  c.DeviceModel,
-		ErrorType:    c.ErrorType,
-		ErrorMessage: c.ErrorMessage,
-		Fingerprint:  c.Fingerprint,
-		GroupId:      c.GroupID,
-		UserId:       c.UserID,
-		Environment:  c.Environment,
-		CreatedAt:    timestamppb.New(c.CreatedAt),
+func crashToProto(c *core.Crash) *pb.CrashReport {
+	p := &pb.CrashReport{
+		Id:                 c.ID,
+		AppId:              c.AppID,
+		AppVersion:         c.AppVersion,
+		Platform:           c.Platform,
+		OsVersion:          c.OSVersion,
+		DeviceModel:        c.DeviceModel,
+		ErrorType:          c.ErrorType,
+		ErrorMessage:       c.ErrorMessage,
+		Fingerprint:        c.Fingerprint,
+		FingerprintVersion: int32(c.FingerprintVersion),
+		GroupId:            c.GroupID,
+		UserId:             c.UserID,
+		Environment:        c.Environment,
+		CreatedAt:          timestamppb.New(c.CreatedAt),
 	}
 
 	for _, f := range c.StackTrace {
-		p.StackTrace = append(p.StackTrace, &StackFrame{
+		p.StackTrace = append(p.StackTrace, &pb.StackFrame{
 			FileName:     f.FileName,
 			LineNumber:   int32(f.LineNumber),
 			ColumnNumber: int32(f.ColumnNumber),
@@ -416,7 +609,7 @@ func crashToProto(c *core.Crash) *CrashReport {
 	}
 
 	for _, b := range c.Breadcrumbs {
-		pb := &Breadcrumb{
+		crumb := &pb.Breadcrumb{
 			Timestamp: timestamppb.New(b.Timestamp),
 			Type:      b.Type,
 			Category:  b.Category,
@@ -424,112 +617,50 @@ func crashToProto(c *core.Crash) *CrashReport {
 			Level:     b.Level,
 		}
 		if b.Data != nil {
-			pb.Data = make(map[string]string)
+			crumb.Data = make(map[string]string)
 			for k, v := range b.Data {
 				if s, ok := v.(string); ok {
-					pb.Data[k] = s
+					crumb.Data[k] = s
 				}
 			}
 		}
-		p.Breadcrumbs = append(p.Breadcrumbs, pb)
+		p.Breadcrumbs = append(p.Breadcrumbs, crumb)
 	}
 
 	return p
 }
 
+func groupToProto(g *core.CrashGroup) *pb.CrashGroup {
+	p := &pb.CrashGroup{
+		Id:                      g.ID,
+		AppId:                   g.AppID,
+		Fingerprint:             g.Fingerprint,
+		Title:                   g.Title,
+		ErrorType:               g.ErrorType,
+		ErrorMessage:            g.ErrorMessage,
+		FirstSeen:               timestamppb.New(g.FirstSeen),
+		LastSeen:                timestamppb.New(g.LastSeen),
+		OccurrenceCount:         int32(g.OccurrenceCount),
+		Status:                  g.Status,
+		AssignedTo:              g.AssignedTo,
+		Notes:                   g.Notes,
+		AggregateOnly:           g.AggregateOnly,
+		Age:                     g.Age,
+		TimeSinceLastOccurrence: g.TimeSinceLastOccurrence,
+		TrendingScore:           g.TrendingScore,
+		SplitKey:                g.SplitKey,
+		Culprit:                 g.Culprit,
+		AffectedUsers:           int32(g.AffectedUsers),
+		LatestCrashId:           g.LatestCrashID,
+	}
+	if g.RegressedAt != nil {
+		p.RegressedAt = timestamppb.New(*g.RegressedAt)
+	}
+	return p
+}
+
 func hashAPIKey(apiKey string) string {
 	h := sha256.New()
 	h.Write([]byte(apiKey))
 	return hex.EncodeToString(h.Sum(nil))
 }
-
-// Proto message types (would be generated by protoc in production)
-
-type CrashReport struct {
-	Id           string
-	AppId        string
-	AppVersion   string
-	Platform     string
-	OsVersion    string
-	DeviceModel  string
-	ErrorType    string
-	ErrorMessage string
-	StackTrace   []*StackFrame
-	Fingerprint  string
-	GroupId      string
-	UserId       string
-	Environment  string
-	CreatedAt    *timestamppb.Timestamp
-	Metadata     map[string]string
-	Breadcrumbs  []*Breadcrumb
-}
-
-type StackFrame struct {
-	FileName     string
-	LineNumber   int32
-	ColumnNumber int32
-	MethodName   string
-	ClassName    string
-	Native       bool
-}
-
-type Breadcrumb struct {
-	Timestamp *timestamppb.Timestamp
-	Type      string
-	Category  string
-	Message   string
-	Data      map[string]string
-	Level     string
-}
-
-type CrashResponse struct {
-	Id          string
-	GroupId     string
-	Fingerprint string
-	IsNewGroup  bool
-}
-
-type CrashBatchRequest struct {
-	Crashes []*CrashReport
-}
-
-type CrashBatchResponse struct {
-	Accepted int32
-	Rejected int32
-	Results  []*CrashResponse
-}
-
-type GetCrashRequest struct {
-	Id string
-}
-
-type ListCrashesRequest struct {
-	AppId       string
-	GroupId     string
-	Platform    string
-	Environment string
-	ErrorType   string
-	UserId      string
-	FromDate    *timestamppb.Timestamp
-	ToDate      *timestamppb.Timestamp
-	Search      string
-	Limit       int32
-	Offset      int32
-}
-
-type ListCrashesResponse struct {
-	Crashes []*CrashReport
-	Total   int32
-}
-
-// Stream interfaces (would be generated by protoc)
-type CrashService_SubmitCrashStreamServer interface {
-	SendAndClose(*CrashBatchResponse) error
-	Recv() (*CrashReport, error)
-	grpc.ServerStream
-}
-
-type CrashService_ListCrashesStreamServer interface {
-	Send(*CrashReport) error
-	grpc.ServerStream
-}
@@ -0,0 +1,399 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/flakerimi/inceptor/api/proto"
+	"github.com/flakerimi/inceptor/internal/config"
+	"github.com/flakerimi/inceptor/internal/core"
+	"github.com/flakerimi/inceptor/internal/ingest"
+	"github.com/flakerimi/inceptor/internal/storage"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestMain(m *testing.M) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	os.Exit(m.Run())
+}
+
+// newTestRepository opens a SQLite repository in a temporary directory
+func newTestRepository(t *testing.T) *storage.SQLiteRepository {
+	t.Helper()
+
+	repo, err := storage.NewSQLiteRepository(filepath.Join(t.TempDir(), "inceptor.db"))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	return repo
+}
+
+// withAPIKey returns a context carrying key as incoming request metadata
+func withAPIKey(key string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", key))
+}
+
+func TestAuthenticateAdminKeys(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Auth.AdminKey = "primary-key"
+	cfg.Auth.AdminKeys = []string{"old-key"}
+	s := NewServer(newTestRepository(t), nil, ingest.NewPipeline(nil, nil, nil, cfg), nil, nil, cfg)
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		wantAdmin bool
+		wantCode  codes.Code
+	}{
+		{"primary key", withAPIKey("primary-key"), true, codes.OK},
+		{"phased-out key", withAPIKey("old-key"), true, codes.OK},
+		{"unknown key", withAPIKey("other-key"), false, codes.Unauthenticated},
+		{"no metadata", context.Background(), false, codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, err := s.authenticate(tt.ctx)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("code = %v, want %v", code, tt.wantCode)
+			}
+			if tt.wantAdmin && (app == nil || app.ID != adminAppID) {
+				t.Errorf("app = %v, want the admin app", app)
+			}
+		})
+	}
+}
+
+func TestServerGroupsWithConfiguredGrouper(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Grouping.TitleStrategy = core.TitleStrategyTypeCulprit
+	cfg.Grouping.FrameLimit = 2
+	s := NewServer(newTestRepository(t), nil, ingest.NewPipeline(nil, nil, nil, cfg), nil, nil, cfg)
+
+	crash := &core.Crash{
+		ErrorType:  "StateError",
+		StackTrace: []core.StackFrame{{FileName: "lib/main.dart", MethodName: "main", ClassName: "App"}},
+	}
+	if got := s.pipeline.Grouper().GenerateTitle(crash); got != "StateError in App.main" {
+		t.Errorf("title = %q, want the configured strategy's", got)
+	}
+	if s.pipeline.Grouper().FrameLimit != 2 {
+		t.Errorf("FrameLimit = %d, want 2", s.pipeline.Grouper().FrameLimit)
+	}
+}
+
+func TestSubmitCrashUsesIngestPipeline(t *testing.T) {
+	report := func(errorType, message string) *pb.CrashReport {
+		return &pb.CrashReport{
+			AppVersion:   "1.0.0",
+			Platform:     core.PlatformAndroid,
+			ErrorType:    errorType,
+			ErrorMessage: message,
+			StackTrace:   []*pb.StackFrame{{FileName: "lib/main.dart", LineNumber: 10, MethodName: "main", ClassName: "App"}},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		configure func(cfg *config.Config)
+		limiter   *core.RateLimiter
+		rateLimit core.RateLimit
+		disabled  bool
+		// reports are submitted in order; the last one is checked
+		reports       []*pb.CrashReport
+		wantCode      codes.Code
+		wantGroup     bool
+		wantMaxLength int
+	}{
+		{
+			name:          "truncated message",
+			configure:     func(cfg *config.Config) { cfg.Ingest.MaxLengths.ErrorMessage = 100 },
+			reports:       []*pb.CrashReport{report("StateError", strings.Repeat("x", 50000))},
+			wantGroup:     true,
+			wantMaxLength: 100,
+		},
+		{
+			name: "metadata too large",
+			configure: func(cfg *config.Config) {
+				cfg.Ingest.MetadataLimits.Action = core.MetadataLimitReject
+				cfg.Ingest.MetadataLimits.MaxBytes = 10
+			},
+			reports: []*pb.CrashReport{func() *pb.CrashReport {
+				r := report("StateError", "Bad state")
+				r.Metadata = map[string]string{"screen": strings.Repeat("x", 100)}
+				return r
+			}()},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:      "group cap quarantine",
+			configure: func(cfg *config.Config) { cfg.Grouping.MaxGroupsPerApp = 1 },
+			reports:   []*pb.CrashReport{report("StateError", "Bad state"), report("RangeError", "Out of range")},
+			wantGroup: false,
+		},
+		{
+			name:     "missing error type",
+			reports:  []*pb.CrashReport{report("", "Bad state")},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "disabled app",
+			disabled: true,
+			reports:  []*pb.CrashReport{report("StateError", "Bad state")},
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			name:     "rate limited",
+			limiter:  core.NewRateLimiter(0.001, 1),
+			reports:  []*pb.CrashReport{report("StateError", "Bad state"), report("RangeError", "Out of range")},
+			wantCode: codes.ResourceExhausted,
+		},
+		{
+			name:      "app rate limit override",
+			limiter:   core.NewRateLimiter(0.001, 1),
+			rateLimit: core.RateLimit{Burst: 2},
+			reports:   []*pb.CrashReport{report("StateError", "Bad state"), report("RangeError", "Out of range")},
+			wantGroup: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			if tt.configure != nil {
+				tt.configure(cfg)
+			}
+			repo := newTestRepository(t)
+			files, err := storage.NewLocalFileStore(filepath.Join(t.TempDir(), "crashes"))
+			if err != nil {
+				t.Fatalf("open file store: %v", err)
+			}
+			s := NewServer(repo, files, ingest.NewPipeline(repo, files, nil, cfg), tt.limiter, nil, cfg)
+
+			app := &core.App{ID: "app-1", Name: "Test App", APIKeyID: "key-1", APIKeyHash: "hash", CreatedAt: time.Now().UTC(), Disabled: tt.disabled, RateLimit: tt.rateLimit}
+			if err := repo.CreateApp(context.Background(), app); err != nil {
+				t.Fatalf("create app: %v", err)
+			}
+			ctx := context.WithValue(context.Background(), appContextKey{}, app)
+
+			var resp *pb.CrashResponse
+			for _, r := range tt.reports {
+				resp, err = s.SubmitCrash(ctx, r)
+			}
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("code = %v, want %v: %v", code, tt.wantCode, err)
+			}
+			if err != nil {
+				return
+			}
+			if hasGroup := resp.GroupId != ""; hasGroup != tt.wantGroup {
+				t.Errorf("group ID = %q, want one: %v", resp.GroupId, tt.wantGroup)
+			}
+
+			stored, err := repo.GetCrash(context.Background(), resp.Id)
+			if err != nil || stored == nil {
+				t.Fatalf("get crash %s: %v", resp.Id, err)
+			}
+			if tt.wantMaxLength > 0 && len(stored.ErrorMessage) > tt.wantMaxLength {
+				t.Errorf("indexed message is %d bytes, want at most %d", len(stored.ErrorMessage), tt.wantMaxLength)
+			}
+		})
+	}
+}
+
+func TestAppFromContext(t *testing.T) {
+	app := &core.App{ID: "app-1"}
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		wantCode codes.Code
+	}{
+		{"authenticated", context.WithValue(context.Background(), appContextKey{}, app), codes.OK},
+		{"no app", context.Background(), codes.Unauthenticated},
+		{"nil app", context.WithValue(context.Background(), appContextKey{}, (*core.App)(nil)), codes.Unauthenticated},
+		{"other key", context.WithValue(context.Background(), "app", app), codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := appFromContext(tt.ctx)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("code = %v, want %v", code, tt.wantCode)
+			}
+			if err == nil && got != app {
+				t.Errorf("app = %v, want %v", got, app)
+			}
+		})
+	}
+
+	// Handlers called without the interceptors are refused rather than panicking
+	s := NewServer(newTestRepository(t), nil, ingest.NewPipeline(nil, nil, nil, &config.Config{}), nil, nil, &config.Config{})
+	if _, err := s.ListGroups(context.Background(), &pb.ListGroupsRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("ListGroups without an app: %v, want Unauthenticated", err)
+	}
+}
+
+// testServerStream is a server stream carrying a context
+type testServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamAuthInterceptor(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Auth.AdminKey = "admin-key"
+	s := NewServer(newTestRepository(t), nil, ingest.NewPipeline(nil, nil, nil, cfg), nil, nil, cfg)
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		wantCode codes.Code
+	}{
+		{"admin key", withAPIKey("admin-key"), codes.OK},
+		{"unknown key", withAPIKey("other-key"), codes.Unauthenticated},
+		{"no metadata", context.Background(), codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *core.App
+			handler := func(srv interface{}, stream grpc.ServerStream) error {
+				app, err := appFromContext(stream.Context())
+				got = app
+				return err
+			}
+			info := &grpc.StreamServerInfo{FullMethod: "/inceptor.v1.CrashService/SubmitCrashStream"}
+			err := s.streamAuthInterceptor(nil, &testServerStream{ctx: tt.ctx}, info, handler)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("code = %v, want %v: %v", code, tt.wantCode, err)
+			}
+			if err == nil && (got == nil || got.ID != adminAppID) {
+				t.Errorf("handler's app = %v, want the admin app", got)
+			}
+		})
+	}
+}
+
+func TestAuthenticateExpiredKeys(t *testing.T) {
+	repo := newTestRepository(t)
+	cfg := &config.Config{}
+	s := NewServer(repo, nil, ingest.NewPipeline(nil, nil, nil, cfg), nil, nil, cfg)
+	now := time.Now().UTC()
+	later, earlier := now.Add(time.Hour), now.Add(-time.Hour)
+
+	tests := []struct {
+		name      string
+		expiresAt *time.Time
+		wantCode  codes.Code
+	}{
+		{"no expiry", nil, codes.OK},
+		{"expires later", &later, codes.OK},
+		{"expired", &earlier, codes.Unauthenticated},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := fmt.Sprintf("app-key-%d", i)
+			app := &core.App{
+				ID:              fmt.Sprintf("app-%d", i),
+				Name:            tt.name,
+				APIKeyID:        fmt.Sprintf("key-%d", i),
+				APIKeyHash:      hashAPIKey(key),
+				CreatedAt:       now,
+				APIKeyExpiresAt: tt.expiresAt,
+			}
+			if err := repo.CreateApp(context.Background(), app); err != nil {
+				t.Fatalf("create app: %v", err)
+			}
+
+			got, err := s.authenticate(withAPIKey(key))
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("code = %v, want %v: %v", code, tt.wantCode, err)
+			}
+			if err == nil && got.ID != app.ID {
+				t.Errorf("app = %s, want %s", got.ID, app.ID)
+			}
+		})
+	}
+}
+
+func TestAuthorizeScopes(t *testing.T) {
+	const (
+		submitCrash = "/inceptor.v1.CrashService/SubmitCrash"
+		submitBatch = "/inceptor.v1.CrashService/SubmitCrashStream"
+		listCrashes = "/inceptor.v1.CrashService/ListCrashes"
+		getGroup    = "/inceptor.v1.GroupService/GetGroup"
+	)
+
+	tests := []struct {
+		name   string
+		app    *core.App
+		method string
+		want   codes.Code
+	}{
+		{"admin submits", &core.App{ID: adminAppID}, submitCrash, codes.OK},
+		{"admin reads", &core.App{ID: adminAppID, APIKeyScopes: []string{core.ScopeSubmit}}, listCrashes, codes.OK},
+		{"unscoped key submits", &core.App{ID: "app"}, submitCrash, codes.OK},
+		{"unscoped key reads", &core.App{ID: "app"}, getGroup, codes.OK},
+		{"submit key submits", &core.App{ID: "app", APIKeyScopes: []string{core.ScopeSubmit}}, submitCrash, codes.OK},
+		{"submit key streams", &core.App{ID: "app", APIKeyScopes: []string{core.ScopeSubmit}}, submitBatch, codes.OK},
+		{"submit key reads", &core.App{ID: "app", APIKeyScopes: []string{core.ScopeSubmit}}, listCrashes, codes.PermissionDenied},
+		{"read key submits", &core.App{ID: "app", APIKeyScopes: []string{core.ScopeRead}}, submitCrash, codes.PermissionDenied},
+		{"read key reads", &core.App{ID: "app", APIKeyScopes: []string{core.ScopeRead}}, getGroup, codes.OK},
+		// gRPC has no endpoints needing manage
+		{"manage key reads", &core.App{ID: "app", APIKeyScopes: []string{core.ScopeManage}}, getGroup, codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := status.Code(authorize(tt.app, tt.method)); code != tt.want {
+				t.Errorf("code = %v, want %v", code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticateRecordsKeyUse(t *testing.T) {
+	repo := newTestRepository(t)
+	cfg := &config.Config{}
+	cfg.Auth.AdminKey = "admin-key"
+	keyUsage := core.NewAPIKeyUsageTracker(repo, core.APIKeyUsageInterval)
+	s := NewServer(repo, nil, ingest.NewPipeline(nil, nil, nil, cfg), nil, keyUsage, cfg)
+	app := &core.App{ID: "app-1", Name: "Test App", APIKeyID: "key-1", APIKeyHash: hashAPIKey("app-key"), CreatedAt: time.Now().UTC()}
+	if err := repo.CreateApp(context.Background(), app); err != nil {
+		t.Fatalf("create app: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		key      string
+		wantUsed bool
+	}{
+		{"admin key", "admin-key", false},
+		{"unknown key", "other-key", false},
+		{"app key", "app-key", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.authenticate(withAPIKey(tt.key))
+			keyUsage.Wait()
+
+			got, err := repo.GetApp(context.Background(), app.ID)
+			if err != nil || got == nil {
+				t.Fatalf("GetApp = %v, %v", got, err)
+			}
+			if used := got.LastUsedAt != nil; used != tt.wantUsed {
+				t.Errorf("last used at = %v, want it set: %v", got.LastUsedAt, tt.wantUsed)
+			}
+		})
+	}
+}
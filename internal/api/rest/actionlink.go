@@ -0,0 +1,116 @@
+package rest
+
+import (
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/flakerimi/inceptor/internal/core"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// actionPage renders the pages of one-click alert links: the confirmation
+// form, or the group once the action is done
+var actionPage = template.Must(template.New("action").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{if .Done}}Group resolved{{else}}Resolve group?{{end}} - Inceptor</title>
+</head>
+<body style="font-family: sans-serif; max-width: 40em; margin: 3em auto; padding: 0 1em">
+{{if .Done}}<h1>Group resolved</h1>{{else}}<h1>Resolve this group?</h1>{{end}}
+<p><strong>{{.Group.Title}}</strong></p>
+<p>{{.Group.OccurrenceCount}} occurrences, last seen {{.Group.LastSeen.UTC.Format "2006-01-02 15:04 UTC"}}</p>
+{{if .Done}}<p>It will be reopened if it crashes again.</p>
+{{else}}<form method="post">
+<input type="hidden" name="token" value="{{.Token}}">
+<button type="submit">Resolve</button>
+</form>
+{{end}}</body>
+</html>
+`))
+
+// actionGroup verifies the token of a one-click alert link for a group and
+// returns the group, or writes the error response and returns nil. consume
+// uses the token up.
+func (h *Handler) actionGroup(c *gin.Context, action, token string, consume bool) *core.CrashGroup {
+	if h.actionLinks == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Action links are not enabled"})
+		return nil
+	}
+
+	id := c.Param("id")
+	check := h.actionLinks.Check
+	if consume {
+		check = h.actionLinks.Verify
+	}
+	if err := check(action, id, token, time.Now()); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid action link", "details": err.Error()})
+		return nil
+	}
+
+	group, err := h.repo.GetGroup(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve group"})
+		return nil
+	}
+	if group == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return nil
+	}
+	return group
+}
+
+// renderActionPage writes an action link page for a group
+func renderActionPage(c *gin.Context, group *core.CrashGroup, token string, done bool) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("Cache-Control", "no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Status(http.StatusOK)
+	data := struct {
+		Group *core.CrashGroup
+		Token string
+		Done  bool
+	}{group, token, done}
+	if err := actionPage.Execute(c.Writer, data); err != nil {
+		log.Error().Err(err).Str("group_id", group.ID).Msg("Failed to render action link page")
+	}
+}
+
+// ConfirmResolveGroupByToken shows the page a one-click resolve link opens:
+// the group and a form that resolves it. Following the link changes
+// nothing, so mail scanners and link previews can't resolve groups.
+func (h *Handler) ConfirmResolveGroupByToken(c *gin.Context) {
+	token := c.Query("token")
+	group := h.actionGroup(c, core.ActionResolve, token, false)
+	if group == nil {
+		return
+	}
+	renderActionPage(c, group, token, false)
+}
+
+// ResolveGroupByToken resolves a group from the confirmation page of a
+// one-click alert link. The signed token in the token form field stands in
+// for authentication; it is bound to the group, expires, and works once.
+func (h *Handler) ResolveGroupByToken(c *gin.Context) {
+	token := c.PostForm("token")
+	group := h.actionGroup(c, core.ActionResolve, token, true)
+	if group == nil {
+		return
+	}
+
+	if group.Status != string(core.GroupStatusResolved) {
+		group.Status = string(core.GroupStatusResolved)
+		if err := h.repo.UpdateGroup(c.Request.Context(), group); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update group"})
+			return
+		}
+		h.notifyResolved(group)
+		log.Info().Str("group_id", group.ID).Msg("Group resolved from alert link")
+	}
+
+	renderActionPage(c, group, "", true)
+}
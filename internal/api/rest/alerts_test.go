@@ -0,0 +1,107 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestCreateAlertVerifiesWebhook(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		pings []map[string]interface{}
+	)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		pings = append(pings, payload)
+		mu.Unlock()
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer receiver.Close()
+
+	tests := []struct {
+		name       string
+		url        string
+		verify     bool
+		wantStatus int
+		wantPing   bool
+	}{
+		{"verified", receiver.URL + "/ok", true, http.StatusCreated, true},
+		{"failing endpoint", receiver.URL + "/fail", true, http.StatusUnprocessableEntity, true},
+		{"unreachable endpoint", "http://127.0.0.1:1/hook", true, http.StatusUnprocessableEntity, false},
+		{"not verified", receiver.URL + "/fail", false, http.StatusCreated, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			appID, _ := ts.createApp(nil)
+			mu.Lock()
+			pings = nil
+			mu.Unlock()
+
+			w := ts.do(http.MethodPost, "/api/v1/alerts", testAdminKey, map[string]interface{}{
+				"app_id":  appID,
+				"type":    "webhook",
+				"config":  map[string]interface{}{"url": tt.url},
+				"enabled": true,
+				"verify":  tt.verify,
+			})
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if got := len(pings) > 0; got != tt.wantPing {
+				t.Fatalf("pinged = %v, want %v", got, tt.wantPing)
+			}
+			if tt.wantPing && pings[0]["event_type"] != "ping" {
+				t.Errorf("ping event_type = %v, want ping", pings[0]["event_type"])
+			}
+
+			// Only alerts that passed verification are saved
+			alerts := dataList(t, ts.do(http.MethodGet, "/api/v1/alerts?app_id="+appID, testAdminKey, nil))
+			wantSaved := tt.wantStatus == http.StatusCreated
+			if saved := len(alerts) == 1; saved != wantSaved {
+				t.Errorf("alert saved = %v, want %v", saved, wantSaved)
+			}
+		})
+	}
+}
+
+func TestCreateAlertPayloadVersion(t *testing.T) {
+	tests := []struct {
+		name       string
+		alertType  string
+		version    interface{}
+		wantStatus int
+	}{
+		{"supported", "webhook", "v1", http.StatusCreated},
+		{"unsupported", "webhook", 3, http.StatusBadRequest},
+		{"malformed", "webhook", "next", http.StatusBadRequest},
+		// Only webhooks have versioned payloads
+		{"other channel", "slack", 3, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			appID, _ := ts.createApp(nil)
+
+			w := ts.do(http.MethodPost, "/api/v1/alerts", testAdminKey, map[string]interface{}{
+				"app_id":  appID,
+				"type":    tt.alertType,
+				"config":  map[string]interface{}{"url": "http://127.0.0.1:1/hook", "webhook_url": "http://127.0.0.1:1/hook", "payload_version": tt.version},
+				"enabled": true,
+			})
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
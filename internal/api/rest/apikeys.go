@@ -0,0 +1,209 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/flakerimi/inceptor/internal/core"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// defaultKeyGracePeriodHours is how long RegenerateAppKey keeps an app's
+// other keys valid when the request doesn't say
+const defaultKeyGracePeriodHours = 24
+
+// apiKeyResponse is the public view of an API key, without its hash. Keys
+// without scopes are shown with all of them.
+func apiKeyResponse(key *core.APIKey) gin.H {
+	scopes := key.Scopes
+	if len(scopes) == 0 {
+		scopes = core.AllScopes
+	}
+	return gin.H{
+		"id":           key.ID,
+		"label":        key.Label,
+		"scopes":       scopes,
+		"expires_at":   key.ExpiresAt,
+		"created_at":   key.CreatedAt,
+		"last_used_at": key.LastUsedAt,
+	}
+}
+
+// ListAPIKeys lists an app's API keys, newest first, including expired ones
+func (h *Handler) ListAPIKeys(c *gin.Context) {
+	app, err := h.repo.GetApp(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve app"})
+		return
+	}
+	if app == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+		return
+	}
+
+	keys, err := h.repo.ListAPIKeys(c.Request.Context(), app.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list API keys"})
+		return
+	}
+
+	result := make([]gin.H, len(keys))
+	for i, key := range keys {
+		result[i] = apiKeyResponse(key)
+	}
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// RegenerateAppKey issues a new API key for an app. The app's other keys
+// stay valid for a grace period, so clients can be moved to the new key
+// without downtime, and can be revoked early with DeleteAPIKey.
+func (h *Handler) RegenerateAppKey(c *gin.Context) {
+	var req struct {
+		Label  string    `json:"label"`
+		Scopes *[]string `json:"scopes"`
+		// ExpiresAt is when the new key expires; it doesn't by default
+		ExpiresAt        *time.Time `json:"expires_at"`
+		GracePeriodHours *int       `json:"grace_period_hours" binding:"omitempty,min=0"`
+	}
+	// The body is optional
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	app, err := h.repo.GetApp(ctx, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve app"})
+		return
+	}
+	if app == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+		return
+	}
+
+	// The new key keeps the scopes of the newest key unless given
+	var scopes []string
+	if req.Scopes != nil {
+		scopes, err = core.NormalizeScopes(*req.Scopes)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key scopes", "details": err.Error()})
+			return
+		}
+	} else {
+		keys, err := h.repo.ListAPIKeys(ctx, app.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list API keys"})
+			return
+		}
+		if len(keys) > 0 {
+			scopes = keys[0].Scopes
+		}
+	}
+
+	gracePeriod := defaultKeyGracePeriodHours
+	if req.GracePeriodHours != nil {
+		gracePeriod = *req.GracePeriodHours
+	}
+
+	now := time.Now().UTC()
+	newAPIKey := generateSecureAPIKey()
+	key := &core.APIKey{
+		ID:        uuid.New().String(),
+		AppID:     app.ID,
+		KeyHash:   HashAPIKey(newAPIKey),
+		Label:     strings.TrimSpace(req.Label),
+		Scopes:    scopes,
+		ExpiresAt: utcTime(req.ExpiresAt),
+		CreatedAt: now,
+	}
+	if err := h.repo.RotateAPIKey(ctx, key, now.Add(time.Duration(gracePeriod)*time.Hour)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to regenerate API key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":      app.ID,
+		"name":    app.Name,
+		"api_key": newAPIKey, // Return new key to user
+		"key":     apiKeyResponse(key),
+	})
+}
+
+// UpdateAPIKey changes an API key's label, scopes or expiry
+func (h *Handler) UpdateAPIKey(c *gin.Context) {
+	var update struct {
+		Label  *string   `json:"label"`
+		Scopes *[]string `json:"scopes"`
+		// ExpiresAt is raw so null, which removes the expiry, can be told
+		// apart from leaving it out
+		ExpiresAt json.RawMessage `json:"expires_at"`
+	}
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	key, err := h.repo.GetAPIKey(c.Request.Context(), c.Param("id"), c.Param("keyID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve API key"})
+		return
+	}
+	if key == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+
+	if update.Label != nil {
+		key.Label = strings.TrimSpace(*update.Label)
+	}
+	if update.Scopes != nil {
+		scopes, err := core.NormalizeScopes(*update.Scopes)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key scopes", "details": err.Error()})
+			return
+		}
+		key.Scopes = scopes
+	}
+	if len(update.ExpiresAt) > 0 {
+		var expiresAt *time.Time
+		if err := json.Unmarshal(update.ExpiresAt, &expiresAt); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expires_at", "details": err.Error()})
+			return
+		}
+		key.ExpiresAt = utcTime(expiresAt)
+	}
+
+	if err := h.repo.UpdateAPIKey(c.Request.Context(), key); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update API key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, apiKeyResponse(key))
+}
+
+// DeleteAPIKey revokes an API key immediately. Revoking all of an app's
+// keys stops it from submitting crashes until a new key is generated.
+func (h *Handler) DeleteAPIKey(c *gin.Context) {
+	key, err := h.repo.GetAPIKey(c.Request.Context(), c.Param("id"), c.Param("keyID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve API key"})
+		return
+	}
+	if key == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+
+	if err := h.repo.DeleteAPIKey(c.Request.Context(), key.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete API key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key deleted"})
+}
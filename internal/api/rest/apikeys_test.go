@@ -0,0 +1,283 @@
+package rest
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+// keyScopes returns an API key response's scopes
+func keyScopes(key map[string]interface{}) []string {
+	var scopes []string
+	for _, scope := range key["scopes"].([]interface{}) {
+		scopes = append(scopes, scope.(string))
+	}
+	return scopes
+}
+
+// listKeys lists an app's API keys by ID
+func (ts *testServer) listKeys(appID string) map[string]map[string]interface{} {
+	ts.t.Helper()
+
+	w := ts.do(http.MethodGet, "/api/v1/apps/"+appID+"/keys", testAdminKey, nil)
+	if w.Code != http.StatusOK {
+		ts.t.Fatalf("list keys: status %d: %s", w.Code, w.Body.String())
+	}
+	keys := map[string]map[string]interface{}{}
+	for _, item := range dataList(ts.t, w) {
+		key := item.(map[string]interface{})
+		keys[key["id"].(string)] = key
+	}
+	return keys
+}
+
+func TestRegenerateAppKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		appScopes  []string
+		body       interface{}
+		wantStatus int
+		wantScopes []string
+		// wantGrace is how long the old key stays valid, if it does
+		wantGrace time.Duration
+	}{
+		{"no body", nil, nil, http.StatusOK, []string{"submit", "read", "manage"}, 24 * time.Hour},
+		{"grace period", nil, map[string]interface{}{"grace_period_hours": 2}, http.StatusOK, []string{"submit", "read", "manage"}, 2 * time.Hour},
+		{"no grace period", nil, map[string]interface{}{"grace_period_hours": 0}, http.StatusOK, []string{"submit", "read", "manage"}, 0},
+		{"scopes kept", []string{"submit"}, map[string]interface{}{"label": "ci"}, http.StatusOK, []string{"submit"}, 24 * time.Hour},
+		{"scopes changed", []string{"submit"}, map[string]interface{}{"scopes": []string{"read"}}, http.StatusOK, []string{"read"}, 24 * time.Hour},
+		{"unknown scope", nil, map[string]interface{}{"scopes": []string{"write"}}, http.StatusBadRequest, nil, 0},
+		{"negative grace period", nil, map[string]interface{}{"grace_period_hours": -1}, http.StatusBadRequest, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			appID, oldKey := ts.createApp(map[string]interface{}{"api_key_scopes": tt.appScopes})
+
+			start := time.Now()
+			w := ts.do(http.MethodPost, "/api/v1/apps/"+appID+"/regenerate-key", testAdminKey, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				if w := ts.do(http.MethodGet, "/api/v1/ping", oldKey, nil); w.Code != http.StatusOK {
+					t.Errorf("old key: status = %d, want it unchanged", w.Code)
+				}
+				return
+			}
+
+			resp := decode(t, w)
+			newKey := resp["api_key"].(string)
+			if newKey == "" || newKey == oldKey {
+				t.Fatalf("api_key = %q, want a new key", newKey)
+			}
+			if scopes := keyScopes(resp["key"].(map[string]interface{})); !slices.Equal(scopes, tt.wantScopes) {
+				t.Errorf("scopes = %v, want %v", scopes, tt.wantScopes)
+			}
+			if w := ts.do(http.MethodGet, "/api/v1/ping", newKey, nil); w.Code != http.StatusOK {
+				t.Errorf("new key: status = %d, want %d", w.Code, http.StatusOK)
+			}
+
+			w = ts.do(http.MethodGet, "/api/v1/ping", oldKey, nil)
+			if tt.wantGrace == 0 {
+				if w.Code != http.StatusUnauthorized || decode(t, w)["code"] != CodeAPIKeyExpired {
+					t.Errorf("old key: status = %d, want it expired: %s", w.Code, w.Body.String())
+				}
+				return
+			}
+			if w.Code != http.StatusOK {
+				t.Errorf("old key: status = %d, want it valid during the grace period", w.Code)
+			}
+			keys := ts.listKeys(appID)
+			if len(keys) != 2 {
+				t.Fatalf("app has %d keys, want 2", len(keys))
+			}
+			for id, key := range keys {
+				if id == resp["key"].(map[string]interface{})["id"] {
+					continue
+				}
+				expiresAt, err := time.Parse(time.RFC3339, key["expires_at"].(string))
+				if err != nil {
+					t.Fatalf("old key expires_at: %v", err)
+				}
+				if want := start.Add(tt.wantGrace); expiresAt.Before(want.Add(-time.Second)) || expiresAt.After(want.Add(5*time.Second)) {
+					t.Errorf("old key expires at %v, want about %v", expiresAt, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRegenerateAppKeyAccess(t *testing.T) {
+	ts := newTestServer(t)
+	appID, key := ts.createApp(nil)
+
+	tests := []struct {
+		name       string
+		appID      string
+		key        string
+		wantStatus int
+	}{
+		{"app key", appID, key, http.StatusForbidden},
+		{"unknown app", "missing", testAdminKey, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := ts.do(http.MethodPost, "/api/v1/apps/"+tt.appID+"/regenerate-key", tt.key, nil); w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestUpdateAPIKey(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		wantStatus    int
+		wantLabel     string
+		wantScopes    []string
+		wantExpiresAt interface{}
+	}{
+		{"label", `{"label": "  release builds "}`, http.StatusOK, "release builds", []string{"submit"}, "2030-01-01T00:00:00Z"},
+		{"scopes", `{"scopes": ["read", "read", "manage"]}`, http.StatusOK, "default", []string{"read", "manage"}, "2030-01-01T00:00:00Z"},
+		{"expiry", `{"expires_at": "2031-06-01T12:00:00+02:00"}`, http.StatusOK, "default", []string{"submit"}, "2031-06-01T10:00:00Z"},
+		{"expiry removed", `{"expires_at": null}`, http.StatusOK, "default", []string{"submit"}, nil},
+		{"unknown scope", `{"scopes": ["admin"]}`, http.StatusBadRequest, "", nil, nil},
+		{"bad expiry", `{"expires_at": "tomorrow"}`, http.StatusBadRequest, "", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			w := ts.do(http.MethodPost, "/api/v1/apps", testAdminKey, map[string]interface{}{
+				"name":               "Test App",
+				"api_key_scopes":     []string{"submit"},
+				"api_key_expires_at": "2030-01-01T00:00:00Z",
+			})
+			if w.Code != http.StatusCreated {
+				t.Fatalf("create app: status %d: %s", w.Code, w.Body.String())
+			}
+			app := decode(t, w)
+			appID := app["id"].(string)
+			keyID := app["key"].(map[string]interface{})["id"].(string)
+
+			w = ts.do(http.MethodPatch, "/api/v1/apps/"+appID+"/keys/"+keyID, testAdminKey, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			// The change is stored as well as returned
+			for _, key := range []map[string]interface{}{decode(t, w), ts.listKeys(appID)[keyID]} {
+				if key["label"] != tt.wantLabel {
+					t.Errorf("label = %v, want %q", key["label"], tt.wantLabel)
+				}
+				if scopes := keyScopes(key); !slices.Equal(scopes, tt.wantScopes) {
+					t.Errorf("scopes = %v, want %v", scopes, tt.wantScopes)
+				}
+				if key["expires_at"] != tt.wantExpiresAt {
+					t.Errorf("expires_at = %v, want %v", key["expires_at"], tt.wantExpiresAt)
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteAPIKey(t *testing.T) {
+	ts := newTestServer(t)
+	appID, oldKey := ts.createApp(nil)
+	otherAppID, _ := ts.createApp(nil)
+	oldKeyID := ""
+	for id := range ts.listKeys(appID) {
+		oldKeyID = id
+	}
+	w := ts.do(http.MethodPost, "/api/v1/apps/"+appID+"/regenerate-key", testAdminKey, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("regenerate key: status %d: %s", w.Code, w.Body.String())
+	}
+	newKey := decode(t, w)["api_key"].(string)
+
+	tests := []struct {
+		name       string
+		appID      string
+		keyID      string
+		wantStatus int
+	}{
+		{"another app's key", otherAppID, oldKeyID, http.StatusNotFound},
+		{"unknown key", appID, "missing", http.StatusNotFound},
+		{"old key", appID, oldKeyID, http.StatusOK},
+		{"already deleted", appID, oldKeyID, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := ts.do(http.MethodDelete, "/api/v1/apps/"+tt.appID+"/keys/"+tt.keyID, testAdminKey, nil); w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+
+	// The old key is revoked at once, despite the grace period
+	if w := ts.do(http.MethodGet, "/api/v1/ping", oldKey, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("deleted key: status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if w := ts.do(http.MethodGet, "/api/v1/ping", newKey, nil); w.Code != http.StatusOK {
+		t.Errorf("remaining key: status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if keys := ts.listKeys(appID); len(keys) != 1 {
+		t.Errorf("app has %d keys, want 1", len(keys))
+	}
+}
+
+func TestAPIKeyLastUsed(t *testing.T) {
+	tests := []struct {
+		name string
+		// use authenticates with the app's current key, if set
+		use      func(ts *testServer, key string)
+		wantUsed bool
+	}{
+		{"unused", nil, false},
+		{"ping", func(ts *testServer, key string) { ts.do(http.MethodGet, "/api/v1/ping", key, nil) }, true},
+		{"crash submitted", func(ts *testServer, key string) { ts.submitCrash(key, testCrash(nil)) }, true},
+		{"crashes listed", func(ts *testServer, key string) { ts.do(http.MethodGet, "/api/v1/crashes", key, nil) }, true},
+		{"admin key", func(ts *testServer, key string) { ts.do(http.MethodGet, "/api/v1/crashes", testAdminKey, nil) }, false},
+		{"wrong key", func(ts *testServer, key string) { ts.do(http.MethodGet, "/api/v1/ping", key+"x", nil) }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			appID, oldKey := ts.createApp(nil)
+			w := ts.do(http.MethodPost, "/api/v1/apps/"+appID+"/regenerate-key", testAdminKey, nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("regenerate key: status %d: %s", w.Code, w.Body.String())
+			}
+			resp := decode(t, w)
+			newKeyID := resp["key"].(map[string]interface{})["id"].(string)
+
+			// Only the key used is marked, not the app's other key
+			ts.do(http.MethodGet, "/api/v1/ping", oldKey, nil)
+			ts.keyUsage.Wait()
+			oldUsed := ts.listKeys(appID)
+			if tt.use != nil {
+				tt.use(ts, resp["api_key"].(string))
+			}
+			ts.keyUsage.Wait()
+
+			keys := ts.listKeys(appID)
+			if used := keys[newKeyID]["last_used_at"] != nil; used != tt.wantUsed {
+				t.Errorf("new key last_used_at = %v, want it set: %v", keys[newKeyID]["last_used_at"], tt.wantUsed)
+			}
+			for id, key := range keys {
+				if id != newKeyID && (key["last_used_at"] == nil || key["last_used_at"] != oldUsed[id]["last_used_at"]) {
+					t.Errorf("old key last_used_at = %v, want %v", key["last_used_at"], oldUsed[id]["last_used_at"])
+				}
+			}
+
+			app := decode(t, ts.do(http.MethodGet, "/api/v1/apps/"+appID, testAdminKey, nil))
+			if app["last_used_at"] == nil {
+				t.Errorf("app last_used_at = nil, want the last key use")
+			}
+		})
+	}
+}
@@ -0,0 +1,842 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/flakerimi/inceptor/internal/config"
+)
+
+func TestGetAppSummary(t *testing.T) {
+	ts := newTestServer(t)
+	appID, apiKey := ts.createApp(nil)
+	_, otherKey := ts.createApp(map[string]interface{}{"name": "Other App"})
+
+	ts.submitCrash(apiKey, testCrash(map[string]interface{}{"app_version": "1.0.0"}))
+	ts.submitCrash(apiKey, testCrash(map[string]interface{}{"app_version": "1.1.0", "error_type": "RangeError"}))
+
+	tests := []struct {
+		name       string
+		path       string
+		key        string
+		wantStatus int
+	}{
+		{"own app", "/api/v1/apps/" + appID + "/summary", apiKey, http.StatusOK},
+		{"admin", "/api/v1/apps/" + appID + "/summary", testAdminKey, http.StatusOK},
+		{"other app's key", "/api/v1/apps/" + appID + "/summary", otherKey, http.StatusForbidden},
+		{"unknown app", "/api/v1/apps/missing/summary", testAdminKey, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ts.do(http.MethodGet, tt.path, tt.key, nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			body := decode(t, w)
+			for _, section := range []string{"app", "stats", "recent_groups", "storage", "recent_deploys"} {
+				if body[section] == nil {
+					t.Errorf("summary has no %s section: %s", section, w.Body.String())
+				}
+			}
+			if stats, _ := body["stats"].(map[string]interface{}); stats["total_crashes"] != float64(2) {
+				t.Errorf("stats = %v, want 2 crashes", stats)
+			}
+			if groups, _ := body["recent_groups"].([]interface{}); len(groups) != 2 {
+				t.Errorf("recent_groups = %v, want both open groups", groups)
+			}
+			if storage, _ := body["storage"].(map[string]interface{}); storage["total_files"] != float64(2) {
+				t.Errorf("storage = %v, want 2 files", storage)
+			}
+			deploys, _ := body["recent_deploys"].([]interface{})
+			if len(deploys) != 2 || deploys[0].(map[string]interface{})["version"] != "1.1.0" {
+				t.Errorf("recent_deploys = %v, want 1.1.0 then 1.0.0", deploys)
+			}
+		})
+	}
+}
+
+func TestGetAppSummaryEmptyApp(t *testing.T) {
+	ts := newTestServer(t)
+	appID, apiKey := ts.createApp(nil)
+
+	w := ts.do(http.MethodGet, "/api/v1/apps/"+appID+"/summary", apiKey, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	// Sections are present even with nothing to show
+	deploys, ok := decode(t, w)["recent_deploys"].([]interface{})
+	if !ok || len(deploys) != 0 {
+		t.Errorf("recent_deploys = %v, want an empty list", deploys)
+	}
+}
+
+func TestPing(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.Ingest.RateLimit.Rate = 0.001
+		cfg.Ingest.RateLimit.Burst = 2
+	})
+	appID, apiKey := ts.createApp(map[string]interface{}{"name": "Ping App"})
+
+	tests := []struct {
+		name       string
+		key        string
+		wantStatus int
+		wantApp    bool
+	}{
+		{"app key", apiKey, http.StatusOK, true},
+		{"admin key", testAdminKey, http.StatusOK, false},
+		{"invalid key", "not-a-key", http.StatusUnauthorized, false},
+		{"no key", "", http.StatusUnauthorized, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := time.Now().Add(-time.Second)
+			w := ts.do(http.MethodGet, "/api/v1/ping", tt.key, nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var resp struct {
+				ServerTime time.Time `json:"server_time"`
+				App        *struct {
+					ID   string `json:"id"`
+					Name string `json:"name"`
+				} `json:"app"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.ServerTime.Before(before) {
+				t.Errorf("server_time = %v, want now", resp.ServerTime)
+			}
+			if tt.wantApp && (resp.App == nil || resp.App.ID != appID || resp.App.Name != "Ping App") {
+				t.Errorf("app = %+v, want %s named Ping App", resp.App, appID)
+			}
+			if !tt.wantApp && resp.App != nil {
+				t.Errorf("app = %+v, want none", resp.App)
+			}
+		})
+	}
+
+	// Pings are rate limited like other requests
+	w := ts.do(http.MethodGet, "/api/v1/ping", apiKey, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("second ping: status %d", w.Code)
+	}
+	if w := ts.do(http.MethodGet, "/api/v1/ping", apiKey, nil); w.Code != http.StatusTooManyRequests {
+		t.Errorf("ping past the burst: status %d, want 429", w.Code)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	ts := newTestServer(t)
+	appID, apiKey := ts.createApp(nil)
+	_, otherKey := ts.createApp(map[string]interface{}{"name": "Other App"})
+
+	groups := make(map[string]string)
+	seed := func(version, errorType string, n int) {
+		for i := 0; i < n; i++ {
+			resp := ts.submitCrash(apiKey, testCrash(map[string]interface{}{"app_version": version, "error_type": errorType}))
+			groups[errorType] = resp["group_id"].(string)
+		}
+	}
+	seed("2.0.0", "StateError", 3)
+	seed("2.0.0", "RangeError", 2)
+	seed("2.1.0", "StateError", 1)
+	seed("2.1.0", "FormatException", 4)
+	seed("2.2.0", "RangeError", 1)
+
+	var resp struct {
+		BaseCrashes      int `json:"base_crashes"`
+		CandidateCrashes int `json:"candidate_crashes"`
+		NewGroups        []struct {
+			GroupID string `json:"group_id"`
+			Count   int    `json:"count"`
+		} `json:"new_groups"`
+		ResolvedGroups []struct {
+			GroupID string `json:"group_id"`
+			Count   int    `json:"count"`
+		} `json:"resolved_groups"`
+		SharedGroups []struct {
+			GroupID string `json:"group_id"`
+			Delta   int    `json:"delta"`
+		} `json:"shared_groups"`
+	}
+	w := ts.do(http.MethodGet, "/api/v1/apps/"+appID+"/compare-versions?base=2.0.0&candidate=2.1.0", apiKey, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+
+	if resp.BaseCrashes != 5 || resp.CandidateCrashes != 5 {
+		t.Errorf("crashes = %d and %d, want 5 in each version", resp.BaseCrashes, resp.CandidateCrashes)
+	}
+	if len(resp.NewGroups) != 1 || resp.NewGroups[0].GroupID != groups["FormatException"] || resp.NewGroups[0].Count != 4 {
+		t.Errorf("new groups = %+v, want FormatException with 4 crashes", resp.NewGroups)
+	}
+	if len(resp.ResolvedGroups) != 1 || resp.ResolvedGroups[0].GroupID != groups["RangeError"] || resp.ResolvedGroups[0].Count != 2 {
+		t.Errorf("resolved groups = %+v, want RangeError with 2 crashes", resp.ResolvedGroups)
+	}
+	if len(resp.SharedGroups) != 1 || resp.SharedGroups[0].GroupID != groups["StateError"] || resp.SharedGroups[0].Delta != -2 {
+		t.Errorf("shared groups = %+v, want StateError down by 2", resp.SharedGroups)
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		key        string
+		wantStatus int
+	}{
+		{"missing candidate", "?base=2.0.0", apiKey, http.StatusBadRequest},
+		{"missing base", "?candidate=2.1.0", apiKey, http.StatusBadRequest},
+		{"other app's key", "?base=2.0.0&candidate=2.1.0", otherKey, http.StatusForbidden},
+		{"admin", "?base=2.0.0&candidate=2.1.0", testAdminKey, http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := ts.do(http.MethodGet, "/api/v1/apps/"+appID+"/compare-versions"+tt.query, tt.key, nil)
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.wantStatus)
+		}
+	}
+}
+
+func TestIncludeFrameworkFramesSetting(t *testing.T) {
+	nativeCrash := func(method string) map[string]interface{} {
+		return testCrash(map[string]interface{}{
+			"error_type": "SIGSEGV",
+			"stack_trace": []map[string]interface{}{
+				{"file_name": "libflutter.so", "method_name": method, "native": true},
+				{"file_name": "lib/player.dart", "line_number": 7, "method_name": "play", "class_name": "Player"},
+			},
+		})
+	}
+
+	tests := []struct {
+		name     string
+		include  bool
+		wantSame bool
+	}{
+		{"skipped by default", false, true},
+		{"included", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			appID, apiKey := ts.createApp(nil)
+			if tt.include {
+				w := ts.do(http.MethodPatch, "/api/v1/apps/"+appID, testAdminKey, map[string]interface{}{"include_framework_frames": true})
+				if w.Code != http.StatusOK {
+					t.Fatalf("update app: status %d: %s", w.Code, w.Body.String())
+				}
+			}
+			app := decode(t, ts.do(http.MethodGet, "/api/v1/apps/"+appID, testAdminKey, nil))
+			if app["include_framework_frames"] != tt.include {
+				t.Errorf("include_framework_frames = %v, want %v", app["include_framework_frames"], tt.include)
+			}
+
+			a := ts.submitCrash(apiKey, nativeCrash("SkCanvas::drawRect"))
+			b := ts.submitCrash(apiKey, nativeCrash("GrGLGpu::flush"))
+			if same := a["group_id"] == b["group_id"]; same != tt.wantSame {
+				t.Errorf("same group = %v, want %v", same, tt.wantSame)
+			}
+		})
+	}
+}
+
+func TestFingerprintMetadataKeysSetting(t *testing.T) {
+	ts := newTestServer(t)
+	appID, apiKey := ts.createApp(map[string]interface{}{"fingerprint_metadata_keys": []string{" http_status ", "", "http_status"}})
+
+	app := decode(t, ts.do(http.MethodGet, "/api/v1/apps/"+appID, testAdminKey, nil))
+	if keys, _ := app["fingerprint_metadata_keys"].([]interface{}); len(keys) != 1 || keys[0] != "http_status" {
+		t.Errorf("fingerprint_metadata_keys = %v, want [http_status]", app["fingerprint_metadata_keys"])
+	}
+
+	submit := func(metadata map[string]interface{}) string {
+		return ts.submitCrash(apiKey, testCrash(map[string]interface{}{"metadata": metadata}))["group_id"].(string)
+	}
+	notFound := submit(map[string]interface{}{"http_status": 404})
+	tests := []struct {
+		name     string
+		metadata map[string]interface{}
+		wantSame bool
+	}{
+		{"same status", map[string]interface{}{"http_status": 404, "path": "/a"}, true},
+		{"other status", map[string]interface{}{"http_status": 500}, false},
+	}
+	for _, tt := range tests {
+		if same := submit(tt.metadata) == notFound; same != tt.wantSame {
+			t.Errorf("%s: same group = %v, want %v", tt.name, same, tt.wantSame)
+		}
+	}
+
+	// Clearing the keys groups by the stack trace alone again
+	w := ts.do(http.MethodPatch, "/api/v1/apps/"+appID, testAdminKey, map[string]interface{}{"fingerprint_metadata_keys": []string{}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("update app: status %d: %s", w.Code, w.Body.String())
+	}
+	if submit(map[string]interface{}{"http_status": 404}) != submit(map[string]interface{}{"http_status": 500}) {
+		t.Errorf("crashes grouped by metadata after clearing the keys")
+	}
+}
+
+func TestFingerprintConfigSetting(t *testing.T) {
+	ts := newTestServer(t)
+	appID, apiKey := ts.createApp(map[string]interface{}{
+		"fingerprint_config": map[string]interface{}{"include_error_message": true},
+	})
+
+	submit := func(message string) string {
+		return ts.submitCrash(apiKey, testCrash(map[string]interface{}{"error_message": message}))["group_id"].(string)
+	}
+	if submit("no element") == submit("closed") {
+		t.Errorf("messages grouped together with include_error_message")
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		config     interface{}
+		wantStatus int
+		// wantConfig is the stored config after the request
+		wantConfig map[string]interface{}
+	}{
+		{"replaced", http.MethodPatch, "/api/v1/apps/" + appID, map[string]interface{}{"frame_limit": 8}, http.StatusOK, map[string]interface{}{"frame_limit": float64(8)}},
+		{"invalid pattern", http.MethodPatch, "/api/v1/apps/" + appID, map[string]interface{}{"ignore_frame_patterns": []string{"("}}, http.StatusBadRequest, map[string]interface{}{"frame_limit": float64(8)}},
+		{"frame limit too high", http.MethodPatch, "/api/v1/apps/" + appID, map[string]interface{}{"frame_limit": 100}, http.StatusBadRequest, map[string]interface{}{"frame_limit": float64(8)}},
+		{"cleared", http.MethodPatch, "/api/v1/apps/" + appID, map[string]interface{}{}, http.StatusOK, map[string]interface{}{}},
+		{"invalid on create", http.MethodPost, "/api/v1/apps", map[string]interface{}{"frame_limit": -1}, http.StatusBadRequest, map[string]interface{}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := map[string]interface{}{"fingerprint_config": tt.config}
+			if tt.method == http.MethodPost {
+				body["name"] = "Invalid"
+			}
+			w := ts.do(tt.method, tt.path, testAdminKey, body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			app := decode(t, ts.do(http.MethodGet, "/api/v1/apps/"+appID, testAdminKey, nil))
+			if config, _ := app["fingerprint_config"].(map[string]interface{}); !maps.Equal(config, tt.wantConfig) {
+				t.Errorf("fingerprint_config = %v, want %v", app["fingerprint_config"], tt.wantConfig)
+			}
+		})
+	}
+
+	// The cleared config groups by the stack trace alone again
+	if submit("no element") != submit("closed") {
+		t.Errorf("messages grouped separately after clearing the config")
+	}
+}
+
+func TestPreviewGroupingRules(t *testing.T) {
+	ts := newTestServer(t)
+	appID, apiKey := ts.createApp(nil)
+	for _, message := range []string{"closed", "no element", "closed"} {
+		ts.submitCrash(apiKey, testCrash(map[string]interface{}{"error_message": message}))
+	}
+
+	path := "/api/v1/apps/" + appID + "/grouping-rules/preview"
+	tests := []struct {
+		name       string
+		path       string
+		key        string
+		body       map[string]interface{}
+		wantStatus int
+		// want holds the expected preview counts
+		want map[string]interface{}
+	}{
+		{"unchanged", path, testAdminKey, map[string]interface{}{}, http.StatusOK,
+			map[string]interface{}{"sampled_crashes": float64(3), "current_groups": float64(1), "proposed_groups": float64(1), "splits": float64(0), "changed_crashes": float64(0)}},
+		{"split by message", path, testAdminKey, map[string]interface{}{"fingerprint_config": map[string]interface{}{"include_error_message": true}}, http.StatusOK,
+			map[string]interface{}{"sampled_crashes": float64(3), "current_groups": float64(1), "proposed_groups": float64(2), "splits": float64(1), "changed_crashes": float64(3)}},
+		{"sample size", path, testAdminKey, map[string]interface{}{"fingerprint_config": map[string]interface{}{"include_error_message": true}, "sample_size": 1}, http.StatusOK,
+			map[string]interface{}{"sampled_crashes": float64(1), "current_groups": float64(1), "proposed_groups": float64(1), "splits": float64(0), "changed_crashes": float64(0)}},
+		{"invalid config", path, testAdminKey, map[string]interface{}{"fingerprint_config": map[string]interface{}{"ignore_frame_patterns": []string{"("}}}, http.StatusBadRequest, nil},
+		{"invalid sample size", path, testAdminKey, map[string]interface{}{"sample_size": -1}, http.StatusBadRequest, nil},
+		{"unknown app", "/api/v1/apps/missing/grouping-rules/preview", testAdminKey, map[string]interface{}{}, http.StatusNotFound, nil},
+		{"app key", path, apiKey, map[string]interface{}{}, http.StatusForbidden, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ts.do(http.MethodPost, tt.path, tt.key, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			preview := decode(t, w)
+			for field, want := range tt.want {
+				if preview[field] != want {
+					t.Errorf("%s = %v, want %v", field, preview[field], want)
+				}
+			}
+		})
+	}
+
+	// Previewing saves nothing
+	app := decode(t, ts.do(http.MethodGet, "/api/v1/apps/"+appID, testAdminKey, nil))
+	if config, _ := app["fingerprint_config"].(map[string]interface{}); len(config) != 0 {
+		t.Errorf("fingerprint_config = %v after a preview, want it unchanged", config)
+	}
+}
+
+func TestAssignmentRulesSetting(t *testing.T) {
+	ts := newTestServer(t)
+
+	invalid := []map[string]interface{}{
+		{"match": "message", "value": "x", "assignee": "alice"},
+		{"match": "file", "value": " ", "assignee": "alice"},
+		{"match": "file", "value": "lib/", "assignee": ""},
+	}
+	for _, rule := range invalid {
+		w := ts.do(http.MethodPost, "/api/v1/apps", testAdminKey, map[string]interface{}{
+			"name":             "Rules App",
+			"assignment_rules": []map[string]interface{}{rule},
+		})
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("rule %v: status %d, want 400", rule, w.Code)
+		}
+	}
+
+	appID, apiKey := ts.createApp(map[string]interface{}{
+		"assignment_rules": []map[string]interface{}{
+			{"match": "error_type", "value": " RangeError ", "assignee": " alice "},
+		},
+	})
+	tests := []struct {
+		name      string
+		errorType string
+		// want is the assignee, omitted when empty
+		want interface{}
+	}{
+		{"matching rule", "RangeError", "alice"},
+		{"no matching rule", "StateError", nil},
+	}
+	for _, tt := range tests {
+		crash := ts.submitCrash(apiKey, testCrash(map[string]interface{}{"error_type": tt.errorType}))
+		group := decode(t, ts.do(http.MethodGet, "/api/v1/groups/"+crash["group_id"].(string), testAdminKey, nil))
+		if assignee := group["assigned_to"]; assignee != tt.want {
+			t.Errorf("%s: assigned to %v, want %v", tt.name, assignee, tt.want)
+		}
+	}
+
+	// Rules apply to new groups only: existing groups keep their assignee
+	w := ts.do(http.MethodPatch, "/api/v1/apps/"+appID, testAdminKey, map[string]interface{}{
+		"assignment_rules": []map[string]interface{}{{"match": "error_type", "value": "StateError", "assignee": "bob"}},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("update app: status %d: %s", w.Code, w.Body.String())
+	}
+	crash := ts.submitCrash(apiKey, testCrash(map[string]interface{}{"error_type": "StateError"}))
+	group := decode(t, ts.do(http.MethodGet, "/api/v1/groups/"+crash["group_id"].(string), testAdminKey, nil))
+	if assignee, ok := group["assigned_to"]; ok {
+		t.Errorf("existing group assigned to %v after a rule change", assignee)
+	}
+}
+
+func TestListAppsWithStats(t *testing.T) {
+	ts := newTestServer(t)
+	appID, apiKey := ts.createApp(nil)
+	emptyID, _ := ts.createApp(map[string]interface{}{"name": "Quiet App"})
+
+	ts.submitCrash(apiKey, testCrash(nil))
+	resolved := ts.submitCrash(apiKey, testCrash(map[string]interface{}{"error_type": "RangeError"}))
+	ts.addGroup(appID, "FormatException", time.Now().Add(-48*time.Hour))
+	w := ts.do(http.MethodPatch, "/api/v1/groups/"+resolved["group_id"].(string), testAdminKey, map[string]interface{}{"status": "resolved"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("resolve group: status %d: %s", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		name    string
+		include string
+		// want are the stats per app, nil when stats aren't included
+		want map[string]map[string]interface{}
+	}{
+		{"without stats", "", nil},
+		{"other includes", "?include=keys", nil},
+		{
+			name:    "with stats",
+			include: "?include=keys,%20stats",
+			want: map[string]map[string]interface{}{
+				appID:   {"total_crashes": float64(3), "open_groups": float64(2), "crashes_last_24h": float64(2)},
+				emptyID: {"total_crashes": float64(0), "open_groups": float64(0), "crashes_last_24h": float64(0)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ts.do(http.MethodGet, "/api/v1/apps"+tt.include, testAdminKey, nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+			apps := dataList(t, w)
+			if len(apps) != 2 {
+				t.Fatalf("apps = %d, want 2", len(apps))
+			}
+			for _, item := range apps {
+				app := item.(map[string]interface{})
+				stats, ok := app["stats"]
+				if tt.want == nil {
+					if ok {
+						t.Errorf("app %v has stats %v, want none", app["id"], stats)
+					}
+					continue
+				}
+				if !maps.Equal(stats.(map[string]interface{}), tt.want[app["id"].(string)]) {
+					t.Errorf("app %v stats = %v, want %v", app["id"], stats, tt.want[app["id"].(string)])
+				}
+			}
+		})
+	}
+}
+
+func TestDisableApp(t *testing.T) {
+	ts := newTestServer(t)
+	appID, apiKey := ts.createApp(nil)
+	ts.submitCrash(apiKey, testCrash(nil))
+
+	setDisabled := func(disabled bool) {
+		t.Helper()
+		w := ts.do(http.MethodPatch, "/api/v1/apps/"+appID, testAdminKey, map[string]interface{}{"disabled": disabled})
+		if w.Code != http.StatusOK {
+			t.Fatalf("update app: status %d: %s", w.Code, w.Body.String())
+		}
+		if app := decode(t, ts.do(http.MethodGet, "/api/v1/apps/"+appID, testAdminKey, nil)); app["disabled"] != disabled {
+			t.Errorf("disabled = %v, want %v", app["disabled"], disabled)
+		}
+	}
+
+	setDisabled(true)
+	w := ts.do(http.MethodPost, "/api/v1/crashes", apiKey, testCrash(nil))
+	if w.Code != http.StatusForbidden || decode(t, w)["code"] != CodeAppDisabled {
+		t.Errorf("submit to a disabled app: status %d: %s, want 403 %s", w.Code, w.Body.String(), CodeAppDisabled)
+	}
+	// Existing data stays readable
+	if crashes := dataList(t, ts.do(http.MethodGet, "/api/v1/crashes?app_id="+appID, testAdminKey, nil)); len(crashes) != 1 {
+		t.Errorf("crashes of a disabled app = %d, want 1", len(crashes))
+	}
+
+	setDisabled(false)
+	ts.submitCrash(apiKey, testCrash(nil))
+}
+
+func TestMaintenanceWindows(t *testing.T) {
+	ts := newTestServer(t)
+	appID, apiKey := ts.createApp(nil)
+	now := time.Now().UTC()
+	path := "/api/v1/apps/" + appID + "/maintenance-windows"
+
+	window := func(start, end time.Duration, recurrence string) map[string]interface{} {
+		body := map[string]interface{}{
+			"starts_at": now.Add(start).Format(time.RFC3339),
+			"ends_at":   now.Add(end).Format(time.RFC3339),
+			"reason":    " deploy ",
+		}
+		if recurrence != "" {
+			body["recurrence"] = recurrence
+		}
+		return body
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		key        string
+		body       map[string]interface{}
+		wantStatus int
+		wantActive bool
+	}{
+		{"active one-off", path, testAdminKey, window(-time.Hour, time.Hour, ""), http.StatusCreated, true},
+		{"upcoming daily", path, testAdminKey, window(2*time.Hour, 3*time.Hour, "daily"), http.StatusCreated, false},
+		{"already ended", path, testAdminKey, window(-2*time.Hour, -time.Hour, ""), http.StatusBadRequest, false},
+		{"ends before it starts", path, testAdminKey, window(time.Hour, -time.Hour, ""), http.StatusBadRequest, false},
+		{"unknown recurrence", path, testAdminKey, window(0, time.Hour, "hourly"), http.StatusBadRequest, false},
+		{"missing times", path, testAdminKey, map[string]interface{}{"reason": "deploy"}, http.StatusBadRequest, false},
+		{"unknown app", "/api/v1/apps/missing/maintenance-windows", testAdminKey, window(0, time.Hour, ""), http.StatusNotFound, false},
+		{"app key", path, apiKey, window(0, time.Hour, ""), http.StatusForbidden, false},
+	}
+	var created []string
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ts.do(http.MethodPost, tt.path, tt.key, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if w.Code != http.StatusCreated {
+				return
+			}
+			resp := decode(t, w)
+			if resp["active"] != tt.wantActive || resp["reason"] != "deploy" {
+				t.Errorf("window = %v, want active %v with a trimmed reason", resp, tt.wantActive)
+			}
+			created = append(created, resp["id"].(string))
+		})
+	}
+
+	listed := func() []string {
+		var ids []string
+		for _, item := range dataList(t, ts.do(http.MethodGet, path, testAdminKey, nil)) {
+			ids = append(ids, item.(map[string]interface{})["id"].(string))
+		}
+		return ids
+	}
+	if got := listed(); !slices.Equal(got, created) {
+		t.Errorf("windows = %v, want %v", got, created)
+	}
+	app := decode(t, ts.do(http.MethodGet, "/api/v1/apps/"+appID, testAdminKey, nil))
+	if windows, _ := app["maintenance_windows"].([]interface{}); len(windows) != len(created) {
+		t.Errorf("app maintenance_windows = %v, want %d windows", app["maintenance_windows"], len(created))
+	}
+
+	// Alerts are held back while the window is active
+	var received atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { received.Add(1) }))
+	defer receiver.Close()
+	w := ts.do(http.MethodPost, "/api/v1/alerts", testAdminKey, map[string]interface{}{
+		"app_id":  appID,
+		"type":    "webhook",
+		"config":  map[string]interface{}{"url": receiver.URL, "conditions": map[string]interface{}{"on_new_group": true}},
+		"enabled": true,
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create alert: status %d: %s", w.Code, w.Body.String())
+	}
+	ts.submitCrash(apiKey, testCrash(nil))
+	var suppressed interface{}
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		windows := dataList(t, ts.do(http.MethodGet, path, testAdminKey, nil))
+		if suppressed = windows[0].(map[string]interface{})["suppressed_alerts"]; suppressed == float64(1) {
+			break
+		}
+	}
+	if suppressed != float64(1) || received.Load() != 0 {
+		t.Errorf("suppressed_alerts = %v with %d alerts sent, want 1 suppressed and none sent", suppressed, received.Load())
+	}
+
+	if w := ts.do(http.MethodDelete, path+"/"+created[0], testAdminKey, nil); w.Code != http.StatusOK {
+		t.Errorf("delete: status %d: %s", w.Code, w.Body.String())
+	}
+	if w := ts.do(http.MethodDelete, path+"/"+created[0], testAdminKey, nil); w.Code != http.StatusNotFound {
+		t.Errorf("delete again: status %d, want 404", w.Code)
+	}
+	if got := listed(); !slices.Equal(got, created[1:]) {
+		t.Errorf("windows after delete = %v, want %v", got, created[1:])
+	}
+}
+
+func TestCreateAppsBulk(t *testing.T) {
+	many := make([]map[string]interface{}, maxBulkApps+1)
+	for i := range many {
+		many[i] = map[string]interface{}{"name": fmt.Sprintf("App %d", i)}
+	}
+
+	tests := []struct {
+		name       string
+		body       interface{}
+		wantStatus int
+		wantNames  []string
+		wantDays   []float64
+	}{
+		{
+			name:       "apps",
+			body:       []map[string]interface{}{{"name": " iOS "}, {"name": "Android", "retention_days": 90}},
+			wantStatus: http.StatusCreated,
+			wantNames:  []string{"iOS", "Android"},
+			wantDays:   []float64{30, 90},
+		},
+		{"not an array", map[string]interface{}{"name": "iOS"}, http.StatusBadRequest, nil, nil},
+		{"empty", []map[string]interface{}{}, http.StatusBadRequest, nil, nil},
+		{"too many", many, http.StatusBadRequest, nil, nil},
+		{"missing name", []map[string]interface{}{{"name": "iOS"}, {"name": " "}}, http.StatusBadRequest, nil, nil},
+		{"duplicate names", []map[string]interface{}{{"name": "iOS"}, {"name": "IOS"}}, http.StatusBadRequest, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			w := ts.do(http.MethodPost, "/api/v1/apps/bulk", testAdminKey, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+
+			listed := dataList(t, ts.do(http.MethodGet, "/api/v1/apps", testAdminKey, nil))
+			if len(listed) != len(tt.wantNames) {
+				t.Errorf("apps listed = %d, want %d: a failed request creates none", len(listed), len(tt.wantNames))
+			}
+			if w.Code != http.StatusCreated {
+				return
+			}
+
+			created := dataList(t, w)
+			if len(created) != len(tt.wantNames) {
+				t.Fatalf("created %d apps, want %d", len(created), len(tt.wantNames))
+			}
+			for i, item := range created {
+				app := item.(map[string]interface{})
+				if app["name"] != tt.wantNames[i] || app["retention_days"] != tt.wantDays[i] {
+					t.Errorf("app %d = %v, want %s with %v days retention", i, app, tt.wantNames[i], tt.wantDays[i])
+				}
+				// Each returned key submits crashes for its own app
+				resp := ts.submitCrash(app["api_key"].(string), testCrash(nil))
+				crash := decode(t, ts.do(http.MethodGet, "/api/v1/crashes/"+resp["id"].(string), testAdminKey, nil))
+				if crash["app_id"] != app["id"] {
+					t.Errorf("crash app = %v, want %v", crash["app_id"], app["id"])
+				}
+			}
+		})
+	}
+}
+
+func TestGetUserCrashes(t *testing.T) {
+	ts := newTestServer(t)
+	appID, apiKey := ts.createApp(nil)
+	_, otherKey := ts.createApp(map[string]interface{}{"name": "Other App"})
+
+	groups := make(map[string]string)
+	var latest string
+	seed := func(userID, errorType string, n int) {
+		for i := 0; i < n; i++ {
+			resp := ts.submitCrash(apiKey, testCrash(map[string]interface{}{"user_id": userID, "error_type": errorType}))
+			groups[errorType] = resp["group_id"].(string)
+			latest = resp["id"].(string)
+		}
+	}
+	seed("user-2", "RangeError", 1)
+	seed("user-1", "FormatException", 2)
+	seed("user-1", "StateError", 3)
+
+	type profile struct {
+		UserID       string `json:"user_id"`
+		TotalCrashes int    `json:"total_crashes"`
+		Groups       []struct {
+			GroupID string `json:"group_id"`
+			Count   int    `json:"count"`
+			Status  string `json:"status"`
+		} `json:"groups"`
+		RecentCrashes []struct {
+			ID     string `json:"id"`
+			UserID string `json:"user_id"`
+		} `json:"recent_crashes"`
+	}
+
+	tests := []struct {
+		name       string
+		user       string
+		query      string
+		key        string
+		wantStatus int
+		wantTotal  int
+		// wantGroups are the error types of the user's groups, in order
+		wantGroups []string
+		wantRecent int
+	}{
+		{"busiest group first", "user-1", "", apiKey, http.StatusOK, 5, []string{"StateError", "FormatException"}, 5},
+		{"crashes limit", "user-1", "?crashes_limit=2", apiKey, http.StatusOK, 5, []string{"StateError", "FormatException"}, 2},
+		{"crashes limit out of range", "user-1", "?crashes_limit=500", apiKey, http.StatusOK, 5, []string{"StateError", "FormatException"}, 5},
+		{"other user", "user-2", "", testAdminKey, http.StatusOK, 1, []string{"RangeError"}, 1},
+		{"no crashes", "user-3", "", apiKey, http.StatusOK, 0, nil, 0},
+		{"other app's key", "user-1", "", otherKey, http.StatusForbidden, 0, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ts.do(http.MethodGet, "/api/v1/apps/"+appID+"/users/"+tt.user+"/crashes"+tt.query, tt.key, nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var resp profile
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.UserID != tt.user || resp.TotalCrashes != tt.wantTotal {
+				t.Errorf("user %q with %d crashes, want %q with %d", resp.UserID, resp.TotalCrashes, tt.user, tt.wantTotal)
+			}
+			if resp.Groups == nil || resp.RecentCrashes == nil {
+				t.Errorf("response = %s, want lists even when empty", w.Body.String())
+			}
+			var got []string
+			for _, group := range resp.Groups {
+				got = append(got, group.GroupID)
+				if group.Status != "open" {
+					t.Errorf("group %s status = %q, want open", group.GroupID, group.Status)
+				}
+			}
+			var want []string
+			for _, errorType := range tt.wantGroups {
+				want = append(want, groups[errorType])
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("groups = %v, want %v", got, want)
+			}
+			if len(resp.RecentCrashes) != tt.wantRecent {
+				t.Fatalf("recent crashes = %d, want %d", len(resp.RecentCrashes), tt.wantRecent)
+			}
+			for _, crash := range resp.RecentCrashes {
+				if crash.UserID != tt.user {
+					t.Errorf("recent crash %s is %q's", crash.ID, crash.UserID)
+				}
+			}
+			if tt.user == "user-1" && resp.RecentCrashes[0].ID != latest {
+				t.Errorf("most recent crash = %s, want %s", resp.RecentCrashes[0].ID, latest)
+			}
+		})
+	}
+}
+
+func TestCreateAppKeySettings(t *testing.T) {
+	ts := newTestServer(t)
+	expiresAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600))
+
+	tests := []struct {
+		name          string
+		body          map[string]interface{}
+		wantStatus    int
+		wantScopes    []interface{}
+		wantExpiresAt interface{}
+	}{
+		{"defaults", map[string]interface{}{}, http.StatusCreated, []interface{}{"submit", "read", "manage"}, nil},
+		{"scopes", map[string]interface{}{"api_key_scopes": []string{"submit", "submit"}}, http.StatusCreated, []interface{}{"submit"}, nil},
+		{"expiry in UTC", map[string]interface{}{"api_key_expires_at": expiresAt}, http.StatusCreated, []interface{}{"submit", "read", "manage"}, "2030-01-02T02:04:05Z"},
+		{"unknown scope", map[string]interface{}{"api_key_scopes": []string{"submit", "delete"}}, http.StatusBadRequest, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.body["name"] = tt.name
+			w := ts.do(http.MethodPost, "/api/v1/apps", testAdminKey, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusCreated {
+				return
+			}
+			key := decode(t, w)["key"].(map[string]interface{})
+			if scopes, _ := key["scopes"].([]interface{}); !slices.Equal(scopes, tt.wantScopes) {
+				t.Errorf("scopes = %v, want %v", key["scopes"], tt.wantScopes)
+			}
+			if key["expires_at"] != tt.wantExpiresAt {
+				t.Errorf("expires_at = %v, want %v", key["expires_at"], tt.wantExpiresAt)
+			}
+		})
+	}
+}
@@ -1,10 +1,13 @@
 package rest
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/flakerimi/inceptor/internal/auth"
+	"github.com/flakerimi/inceptor/internal/core"
 	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
 )
 
 // AuthHandler holds auth-related handlers
@@ -17,8 +20,10 @@ func NewAuthHandler(authManager *auth.Manager) *AuthHandler {
 	return &AuthHandler{authManager: authManager}
 }
 
-// LoginRequest represents login credentials
+// LoginRequest represents login credentials. Username defaults to the
+// bootstrap admin account, for clients from before accounts existed.
 type LoginRequest struct {
+	Username string `json:"username"`
 	Password string `json:"password" binding:"required"`
 }
 
@@ -28,10 +33,18 @@ type ChangePasswordRequest struct {
 	NewPassword string `json:"new_password" binding:"required,min=4"`
 }
 
+// CreateUserRequest represents a new dashboard account
+type CreateUserRequest struct {
+	Username string        `json:"username" binding:"required"`
+	Password string        `json:"password" binding:"required,min=4"`
+	Role     core.UserRole `json:"role" binding:"required"`
+}
+
 // Status returns auth status
 func (h *AuthHandler) Status(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
-		"needs_password_change": h.authManager.NeedsPasswordChange(),
+		"needs_password_change": h.authManager.NeedsPasswordChange(c.Request.Context()),
+		"proxy_login":           h.authManager.ProxyLoginEnabled(),
 	})
 }
 
@@ -43,21 +56,74 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	if !h.authManager.ValidatePassword(req.Password) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+	if req.Username == "" {
+		req.Username = auth.DefaultUsername
+	}
+
+	user, err := h.authManager.Authenticate(c.Request.Context(), req.Username, req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check credentials"})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
 		return
 	}
 
-	session, err := h.authManager.CreateSession()
+	session, err := h.authManager.CreateSession(c.Request.Context(), user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
 		return
 	}
 
+	log.Info().Str("user", user.Username).Str("remote_addr", c.Request.RemoteAddr).Msg("Login")
+
 	c.JSON(http.StatusOK, gin.H{
 		"token":                 session.Token,
 		"expires_at":            session.ExpiresAt,
-		"needs_password_change": h.authManager.NeedsPasswordChange(),
+		"user":                  user,
+		"needs_password_change": user.MustChangePassword,
+	})
+}
+
+// ProxyLogin creates a session for a user already authenticated by a
+// trusted proxy, without asking for the password. The session gets the role
+// of the account with the proxy's username; users without an account are
+// provisioned one with the proxy's default role, admin unless configured.
+func (h *AuthHandler) ProxyLogin(c *gin.Context) {
+	username := h.authManager.ProxyUser(c.Request)
+	if username == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Proxy authentication not available"})
+		return
+	}
+
+	user, created, err := h.authManager.ProvisionProxyUser(c.Request.Context(), username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to provision user"})
+		return
+	}
+	if created {
+		log.Info().
+			Str("user", user.Username).
+			Str("role", string(user.Role)).
+			Str("remote_addr", c.Request.RemoteAddr).
+			Msg("Provisioned account for proxy user")
+	}
+
+	session, err := h.authManager.CreateSession(c.Request.Context(), user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return
+	}
+
+	log.Info().Str("user", username).Str("role", string(user.Role)).Str("remote_addr", c.Request.RemoteAddr).Msg("Proxy login")
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":      session.Token,
+		"expires_at": session.ExpiresAt,
+		"user":       user,
+		// The dashboard password isn't used, so there's nothing to change
+		"needs_password_change": false,
 	})
 }
 
@@ -69,7 +135,7 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 		if len(token) > 7 && token[:7] == "Bearer " {
 			token = token[7:]
 		}
-		h.authManager.DeleteSession(token)
+		h.authManager.DeleteSession(c.Request.Context(), token)
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
 }
@@ -82,7 +148,18 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 		return
 	}
 
-	if !h.authManager.ChangePassword(req.OldPassword, req.NewPassword) {
+	session := GetSession(c)
+	if session == nil || session.UserID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This session has no account password to change"})
+		return
+	}
+
+	changed, err := h.authManager.ChangePassword(c.Request.Context(), session.UserID, req.OldPassword, req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+	if !changed {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid old password or new password too short"})
 		return
 	}
@@ -90,6 +167,43 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
 }
 
+// ListUsers lists dashboard accounts
+func (h *AuthHandler) ListUsers(c *gin.Context) {
+	users, err := h.authManager.ListUsers(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": users})
+}
+
+// CreateUser adds a dashboard account
+func (h *AuthHandler) CreateUser(c *gin.Context) {
+	var req CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+
+	user, err := h.authManager.CreateUser(c.Request.Context(), req.Username, req.Password, req.Role)
+	switch {
+	case errors.Is(err, auth.ErrInvalidUser):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, auth.ErrUsernameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": "Username already taken"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+
+	log.Info().Str("user", user.Username).Str("role", string(user.Role)).Msg("User created")
+
+	c.JSON(http.StatusCreated, user)
+}
+
 // SessionAuth middleware validates session token
 func SessionAuth(authManager *auth.Manager) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -105,12 +219,14 @@ func SessionAuth(authManager *auth.Manager) gin.HandlerFunc {
 			token = token[7:]
 		}
 
-		if !authManager.ValidateSession(token) {
+		session := authManager.Session(c.Request.Context(), token)
+		if session == nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired session"})
 			c.Abort()
 			return
 		}
 
+		c.Set(ContextKeySession, session)
 		c.Next()
 	}
 }
@@ -0,0 +1,376 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flakerimi/inceptor/internal/auth"
+	"github.com/flakerimi/inceptor/internal/core"
+	"github.com/flakerimi/inceptor/internal/ingest"
+)
+
+func TestProxyLogin(t *testing.T) {
+	tests := []struct {
+		name        string
+		defaultRole core.UserRole
+		// account is the role of an existing account for the user, if any
+		account    core.UserRole
+		remoteAddr string
+		wantStatus int
+		wantRole   core.UserRole
+		// wantWrite is the status of an admin-only request with the session
+		wantWrite       int
+		wantProvisioned bool
+	}{
+		{"untrusted address", "", "", "192.168.1.5:4000", http.StatusUnauthorized, "", 0, false},
+		{"provisioned admin", "", "", "10.0.0.2:4000", http.StatusOK, core.UserRoleAdmin, http.StatusCreated, true},
+		{"provisioned with configured role", core.UserRoleViewer, "", "10.0.0.2:4000", http.StatusOK, core.UserRoleViewer, http.StatusForbidden, true},
+		{"existing viewer", "", core.UserRoleViewer, "10.0.0.2:4000", http.StatusOK, core.UserRoleViewer, http.StatusForbidden, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			proxy, err := auth.NewTrustedProxy("", []string{"10.0.0.0/8"}, tt.defaultRole)
+			if err != nil {
+				t.Fatalf("new trusted proxy: %v", err)
+			}
+			ts.server.authManager.SetTrustedProxy(proxy)
+			if tt.account != "" {
+				if _, err := ts.server.authManager.CreateUser(context.Background(), "alice", "secret-password", tt.account); err != nil {
+					t.Fatalf("create user: %v", err)
+				}
+			}
+			logs := captureLogs(t)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/proxy-login", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set(auth.DefaultProxyUserHeader, "alice")
+			req.Header.Set(HeaderRequestedWith, "XMLHttpRequest")
+			w := ts.serve(req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if provisioned := strings.Contains(logs.String(), "Provisioned account for proxy user"); provisioned != tt.wantProvisioned {
+				t.Errorf("provisioning logged = %v, want %v", provisioned, tt.wantProvisioned)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			resp := decode(t, w)
+			if user := resp["user"].(map[string]interface{}); user["role"] != string(tt.wantRole) {
+				t.Errorf("role = %v, want %s", user["role"], tt.wantRole)
+			}
+			account, err := ts.server.authManager.User(context.Background(), "alice")
+			if err != nil || account == nil || account.Role != tt.wantRole {
+				t.Errorf("account = %+v, %v; want one with role %s", account, err, tt.wantRole)
+			}
+
+			req = httptest.NewRequest(http.MethodPost, "/api/v1/apps", jsonBody(t, map[string]interface{}{"name": "Proxy App"}))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+resp["token"].(string))
+			if w := ts.serve(req); w.Code != tt.wantWrite {
+				t.Errorf("create app with the session: status %d, want %d", w.Code, tt.wantWrite)
+			}
+		})
+	}
+}
+
+func TestProxyLoginCrossSite(t *testing.T) {
+	ts := newTestServer(t)
+	proxy, err := auth.NewTrustedProxy("", []string{"10.0.0.0/8"}, "")
+	if err != nil {
+		t.Fatalf("new trusted proxy: %v", err)
+	}
+	ts.server.authManager.SetTrustedProxy(proxy)
+
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		wantStatus int
+	}{
+		{"dashboard", map[string]string{"Sec-Fetch-Site": "same-origin", "Origin": "http://inceptor.example.com", HeaderRequestedWith: "XMLHttpRequest"}, http.StatusOK},
+		{"origin of the forwarded host", map[string]string{"Origin": "https://crashes.example.com", "X-Forwarded-Host": "crashes.example.com", HeaderRequestedWith: "XMLHttpRequest"}, http.StatusOK},
+		{"without the custom header", map[string]string{"Sec-Fetch-Site": "same-origin"}, http.StatusForbidden},
+		{"cross-site fetch", map[string]string{"Sec-Fetch-Site": "cross-site", HeaderRequestedWith: "XMLHttpRequest"}, http.StatusForbidden},
+		{"same-site fetch", map[string]string{"Sec-Fetch-Site": "same-site", HeaderRequestedWith: "XMLHttpRequest"}, http.StatusForbidden},
+		{"other origin", map[string]string{"Origin": "https://evil.example.org", HeaderRequestedWith: "XMLHttpRequest"}, http.StatusForbidden},
+		{"opaque origin", map[string]string{"Origin": "null", HeaderRequestedWith: "XMLHttpRequest"}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "http://inceptor.example.com/api/v1/auth/proxy-login", nil)
+			req.RemoteAddr = "10.0.0.2:4000"
+			req.Header.Set(auth.DefaultProxyUserHeader, "alice")
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			w := ts.serve(req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != "" {
+				t.Errorf("Access-Control-Allow-Origin = %q, want none", origin)
+			}
+		})
+	}
+
+	// Other sites aren't granted a preflight either
+	req := httptest.NewRequest(http.MethodOptions, "/api/v2/auth/proxy-login", nil)
+	req.Header.Set("Origin", "https://evil.example.org")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	req.Header.Set("Access-Control-Request-Headers", HeaderRequestedWith)
+	w := ts.serve(req)
+	if origin, creds := w.Header().Get("Access-Control-Allow-Origin"), w.Header().Get("Access-Control-Allow-Credentials"); origin != "" || creds != "" {
+		t.Errorf("preflight allowed origin %q with credentials %q, want no CORS headers", origin, creds)
+	}
+	// Other endpoints still allow cross-origin requests
+	req = httptest.NewRequest(http.MethodOptions, "/api/v1/auth/login", nil)
+	req.Header.Set("Origin", "https://dashboard.example.org")
+	if w := ts.serve(req); w.Header().Get("Access-Control-Allow-Origin") != "https://dashboard.example.org" {
+		t.Errorf("login preflight: Access-Control-Allow-Origin = %q", w.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
+
+func TestLogin(t *testing.T) {
+	ts := newTestServer(t)
+	ts.createUser("viewer", core.UserRoleViewer)
+
+	tests := []struct {
+		name           string
+		body           map[string]interface{}
+		wantStatus     int
+		wantUser       string
+		wantRole       core.UserRole
+		wantNeedChange bool
+	}{
+		{"bootstrap admin by default", map[string]interface{}{"password": auth.DefaultPassword}, http.StatusOK, auth.DefaultUsername, core.UserRoleAdmin, true},
+		{"named account", map[string]interface{}{"username": "viewer", "password": "secret-password"}, http.StatusOK, "viewer", core.UserRoleViewer, false},
+		{"wrong password", map[string]interface{}{"username": "viewer", "password": "wrong"}, http.StatusUnauthorized, "", "", false},
+		{"unknown user", map[string]interface{}{"username": "bob", "password": "secret-password"}, http.StatusUnauthorized, "", "", false},
+		{"no password", map[string]interface{}{"username": "viewer"}, http.StatusBadRequest, "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ts.do(http.MethodPost, "/api/v1/auth/login", "", tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			resp := decode(t, w)
+			user := resp["user"].(map[string]interface{})
+			if user["username"] != tt.wantUser || user["role"] != string(tt.wantRole) {
+				t.Errorf("user = %v, want %s as %s", user, tt.wantUser, tt.wantRole)
+			}
+			if _, ok := user["password_hash"]; ok {
+				t.Error("response includes the password hash")
+			}
+			if resp["needs_password_change"] != tt.wantNeedChange {
+				t.Errorf("needs_password_change = %v, want %v", resp["needs_password_change"], tt.wantNeedChange)
+			}
+			if w := ts.doSession(http.MethodGet, "/api/v1/crashes", resp["token"].(string), nil); w.Code != http.StatusOK {
+				t.Errorf("list crashes with the session: status %d", w.Code)
+			}
+		})
+	}
+}
+
+func TestUsers(t *testing.T) {
+	ts := newTestServer(t)
+	admin := ts.login(auth.DefaultUsername, auth.DefaultPassword)
+	viewer := ts.createUser("viewer", core.UserRoleViewer)
+
+	tests := []struct {
+		name       string
+		token      string
+		body       map[string]interface{}
+		wantStatus int
+	}{
+		{"admin creates a viewer", admin, map[string]interface{}{"username": "carol", "password": "secret", "role": "viewer"}, http.StatusCreated},
+		{"admin creates an admin", admin, map[string]interface{}{"username": "dave", "password": "secret", "role": "admin"}, http.StatusCreated},
+		{"taken", admin, map[string]interface{}{"username": "carol", "password": "secret", "role": "viewer"}, http.StatusConflict},
+		{"unknown role", admin, map[string]interface{}{"username": "erin", "password": "secret", "role": "owner"}, http.StatusBadRequest},
+		{"short password", admin, map[string]interface{}{"username": "erin", "password": "abc", "role": "viewer"}, http.StatusBadRequest},
+		{"no role", admin, map[string]interface{}{"username": "erin", "password": "secret"}, http.StatusBadRequest},
+		{"viewer", viewer, map[string]interface{}{"username": "erin", "password": "secret", "role": "admin"}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ts.doSession(http.MethodPost, "/api/v1/users", tt.token, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusCreated {
+				return
+			}
+			user := decode(t, w)
+			if user["username"] != tt.body["username"] || user["role"] != tt.body["role"] {
+				t.Errorf("user = %v, want %v", user, tt.body)
+			}
+			// The new account can log in
+			ts.login(tt.body["username"].(string), "secret")
+		})
+	}
+
+	if w := ts.doSession(http.MethodGet, "/api/v1/users", viewer, nil); w.Code != http.StatusForbidden {
+		t.Errorf("list users as a viewer: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+	var names []string
+	for _, u := range dataList(t, ts.do(http.MethodGet, "/api/v1/users", testAdminKey, nil)) {
+		names = append(names, u.(map[string]interface{})["username"].(string))
+	}
+	if want := []string{auth.DefaultUsername, "carol", "dave", "viewer"}; !slices.Equal(names, want) {
+		t.Errorf("users = %v, want %v", names, want)
+	}
+}
+
+func TestViewerSession(t *testing.T) {
+	ts := newTestServer(t)
+	appID, apiKey := ts.createApp(nil)
+	crashID := ts.submitCrash(apiKey, testCrash(nil))["id"].(string)
+	groupID := ts.addGroup(appID, "StateError", time.Now())
+	admin := ts.login(auth.DefaultUsername, auth.DefaultPassword)
+	viewer := ts.createUser("viewer", core.UserRoleViewer)
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		body        map[string]interface{}
+		wantViewer  int
+		wantAdmin   int
+		wantViewErr string
+	}{
+		{"list crashes", http.MethodGet, "/api/v1/crashes", nil, http.StatusOK, http.StatusOK, ""},
+		{"get group", http.MethodGet, "/api/v1/groups/" + groupID, nil, http.StatusOK, http.StatusOK, ""},
+		{"admin-only read", http.MethodGet, "/api/v1/apps", nil, http.StatusForbidden, http.StatusOK, "ADMIN_REQUIRED"},
+		{"update group", http.MethodPatch, "/api/v1/groups/" + groupID, map[string]interface{}{"status": "resolved"}, http.StatusForbidden, http.StatusOK, "ADMIN_REQUIRED"},
+		{"create app", http.MethodPost, "/api/v1/apps", map[string]interface{}{"name": "Viewer App"}, http.StatusForbidden, http.StatusCreated, ""},
+		{"delete crash", http.MethodDelete, "/api/v1/crashes/" + crashID, nil, http.StatusForbidden, http.StatusOK, "ADMIN_REQUIRED"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ts.doSession(tt.method, tt.path, viewer, tt.body)
+			if w.Code != tt.wantViewer {
+				t.Fatalf("viewer: status %d, want %d: %s", w.Code, tt.wantViewer, w.Body.String())
+			}
+			if tt.wantViewErr != "" {
+				if code := decode(t, w)["code"]; code != tt.wantViewErr {
+					t.Errorf("viewer: code = %v, want %s", code, tt.wantViewErr)
+				}
+			}
+			if w := ts.doSession(tt.method, tt.path, admin, tt.body); w.Code != tt.wantAdmin {
+				t.Errorf("admin: status %d, want %d: %s", w.Code, tt.wantAdmin, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestChangePasswordEndpoint(t *testing.T) {
+	tests := []struct {
+		name        string
+		oldPassword string
+		newPassword string
+		wantStatus  int
+	}{
+		{"changed", auth.DefaultPassword, "new-secret", http.StatusOK},
+		{"wrong old password", "wrong", "new-secret", http.StatusBadRequest},
+		{"too short", auth.DefaultPassword, "abc", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			token := ts.login(auth.DefaultUsername, auth.DefaultPassword)
+
+			w := ts.doSession(http.MethodPost, "/api/v1/auth/change-password", token, map[string]interface{}{
+				"old_password": tt.oldPassword,
+				"new_password": tt.newPassword,
+			})
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+
+			password := auth.DefaultPassword
+			if tt.wantStatus == http.StatusOK {
+				password = tt.newPassword
+			}
+			ts.login(auth.DefaultUsername, password)
+			status := decode(t, ts.do(http.MethodGet, "/api/v1/auth/status", "", nil))
+			if status["needs_password_change"] != (tt.wantStatus != http.StatusOK) {
+				t.Errorf("needs_password_change = %v after the change returned %d", status["needs_password_change"], w.Code)
+			}
+		})
+	}
+
+	// Only sessions have a password to change
+	ts := newTestServer(t)
+	w := ts.do(http.MethodPost, "/api/v1/auth/change-password", testAdminKey, map[string]interface{}{"old_password": "x", "new_password": "new-secret"})
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("with the admin key: status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.login(auth.DefaultUsername, auth.DefaultPassword)
+	other := ts.login(auth.DefaultUsername, auth.DefaultPassword)
+
+	if w := ts.doSession(http.MethodPost, "/api/v1/auth/logout", token, nil); w.Code != http.StatusOK {
+		t.Fatalf("logout: status %d", w.Code)
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+	}{
+		{"logged out", token, http.StatusUnauthorized},
+		{"other session", other, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := ts.doSession(http.MethodGet, "/api/v1/crashes", tt.token, nil); w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestSessionSurvivesRestart(t *testing.T) {
+	tests := []struct {
+		name string
+		// sessions is the session store after the restart; nil keeps them
+		// in memory
+		sessions   func(ts *testServer) auth.SessionStore
+		wantStatus int
+	}{
+		{"database", func(ts *testServer) auth.SessionStore { return ts.repo }, http.StatusOK},
+		{"memory", func(ts *testServer) auth.SessionStore { return nil }, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			token := ts.login(auth.DefaultUsername, auth.DefaultPassword)
+
+			// A new server on the same database, as after a deploy
+			restarted := NewServer(ts.repo, ts.files, ts.alerter, ingest.NewPipeline(ts.repo, ts.files, ts.alerter, ts.cfg),
+				nil, nil, auth.NewManager(ts.repo, tt.sessions(ts)), ts.cfg, "test")
+			t.Cleanup(restarted.handler.Close)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/crashes", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			restarted.router.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/flakerimi/inceptor/internal/core"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/ugorji/go/codec"
+)
+
+const (
+	// maxMsgPackBodySize bounds the size of a msgpack request body
+	maxMsgPackBodySize = 1 << 20
+	// maxMsgPackInitLen bounds container lengths declared in msgpack headers,
+	// so a tiny payload can't make the decoder pre-allocate huge slices
+	maxMsgPackInitLen = 1 << 16
+)
+
+// msgpackHandle decodes maps into map[string]interface{} and raw bytes into
+// strings, matching what encoding/json produces for the same submission
+var msgpackHandle = func() *codec.MsgpackHandle {
+	h := &codec.MsgpackHandle{}
+	h.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	h.RawToString = true
+	h.MaxInitLen = maxMsgPackInitLen
+	return h
+}()
+
+// abortInvalidCrashBody responds to a failed bindCrashSubmission: 413 when a
+// body size limit was hit, otherwise 400
+func abortInvalidCrashBody(c *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":   "Request body too large",
+			"code":    CodeBodyTooLarge,
+			"details": fmt.Sprintf("limit is %d bytes", tooLarge.Limit),
+		})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+}
+
+// bindCrashSubmission decodes a crash submission according to the request's
+// Content-Type. JSON is the default; msgpack is accepted for bandwidth
+// constrained clients and validated with the same binding rules. JSON field
+// names may be camelCase as well as the canonical snake_case. With
+// preserveNumbers, JSON numbers in free-form fields decode as json.Number.
+func bindCrashSubmission(c *gin.Context, submission *core.CrashSubmission, preserveNumbers bool) error {
+	switch c.ContentType() {
+	case binding.MIMEMSGPACK, binding.MIMEMSGPACK2:
+		body := http.MaxBytesReader(c.Writer, c.Request.Body, maxMsgPackBodySize)
+		if err := codec.NewDecoder(body, msgpackHandle).Decode(submission); err != nil {
+			return err
+		}
+		return binding.Validator.ValidateStruct(submission)
+	default:
+		if c.Request.Body == nil {
+			return errors.New("invalid request")
+		}
+		// Decode loosely first so field names can be normalized; numbers stay
+		// json.Number until the final decode decides how to represent them
+		var raw map[string]interface{}
+		decoder := json.NewDecoder(c.Request.Body)
+		decoder.UseNumber()
+		if err := decoder.Decode(&raw); err != nil {
+			return err
+		}
+		snakeCaseSubmission(raw)
+
+		data, err := json.Marshal(raw)
+		if err != nil {
+			return err
+		}
+		decoder = json.NewDecoder(bytes.NewReader(data))
+		if preserveNumbers {
+			decoder.UseNumber()
+		}
+		if err := decoder.Decode(submission); err != nil {
+			return err
+		}
+		return binding.Validator.ValidateStruct(submission)
+	}
+}
+
+// snakeCaseSubmission renames camelCase keys of a decoded crash submission,
+// its stack frames and breadcrumbs to snake_case. Metadata and breadcrumb
+// data are left alone since their keys belong to the client. When both
+// spellings are present the snake_case one wins.
+func snakeCaseSubmission(submission map[string]interface{}) {
+	snakeCaseKeys(submission)
+	for _, field := range []string{"stack_trace", "breadcrumbs"} {
+		items, _ := submission[field].([]interface{})
+		for _, item := range items {
+			if m, ok := item.(map[string]interface{}); ok {
+				snakeCaseKeys(m)
+			}
+		}
+	}
+}
+
+// snakeCaseKeys renames the camelCase keys of a single object in place
+func snakeCaseKeys(m map[string]interface{}) {
+	for key, value := range m {
+		snake := toSnakeCase(key)
+		if snake == key {
+			continue
+		}
+		if _, exists := m[snake]; !exists {
+			m[snake] = value
+		}
+		delete(m, key)
+	}
+}
+
+// toSnakeCase converts camelCase to snake_case, keeping acronyms together
+// ("userID" becomes "user_id")
+func toSnakeCase(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,54 @@
+package rest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestToSnakeCase(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"app_version", "app_version"},
+		{"appVersion", "app_version"},
+		{"errorType", "error_type"},
+		{"userID", "user_id"},
+		{"deviceOSVersion", "device_os_version"},
+		{"line2Number", "line2_number"},
+		{"Platform", "platform"},
+	}
+	for _, tt := range tests {
+		if got := toSnakeCase(tt.in); got != tt.want {
+			t.Errorf("toSnakeCase(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSnakeCaseSubmission(t *testing.T) {
+	submission := map[string]interface{}{
+		"appVersion": "1.0.0",
+		// The canonical spelling wins over a camelCase duplicate
+		"error_type": "StateError",
+		"errorType":  "Ignored",
+		"stackTrace": []interface{}{map[string]interface{}{"fileName": "lib/main.dart", "lineNumber": 10}},
+		"metadata":   map[string]interface{}{"featureFlag": "beta"},
+		"breadcrumbs": []interface{}{
+			map[string]interface{}{"message": "tap", "data": map[string]interface{}{"buttonId": "save"}},
+		},
+	}
+	want := map[string]interface{}{
+		"app_version": "1.0.0",
+		"error_type":  "StateError",
+		"stack_trace": []interface{}{map[string]interface{}{"file_name": "lib/main.dart", "line_number": 10}},
+		// Client-defined keys are left alone
+		"metadata": map[string]interface{}{"featureFlag": "beta"},
+		"breadcrumbs": []interface{}{
+			map[string]interface{}{"message": "tap", "data": map[string]interface{}{"buttonId": "save"}},
+		},
+	}
+
+	snakeCaseSubmission(submission)
+	if !reflect.DeepEqual(submission, want) {
+		t.Errorf("snakeCaseSubmission() = %v, want %v", submission, want)
+	}
+}
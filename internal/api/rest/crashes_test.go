@@ -0,0 +1,1398 @@
+package rest
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flakerimi/inceptor/internal/config"
+	"github.com/flakerimi/inceptor/internal/core"
+	"github.com/flakerimi/inceptor/internal/storage"
+	"github.com/ugorji/go/codec"
+)
+
+func TestSubmitCrashRecordsFingerprintVersion(t *testing.T) {
+	ts := newTestServer(t)
+	appID, apiKey := ts.createApp(nil)
+
+	resp := ts.submitCrash(apiKey, testCrash(nil))
+	if got := resp["fingerprint_version"]; got != float64(core.FingerprintVersion) {
+		t.Errorf("submit response fingerprint_version = %v, want %d", got, core.FingerprintVersion)
+	}
+
+	w := ts.do(http.MethodGet, "/api/v1/crashes/"+resp["id"].(string), apiKey, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("get crash: status %d", w.Code)
+	}
+	if got := decode(t, w)["fingerprint_version"]; got != float64(core.FingerprintVersion) {
+		t.Errorf("stored fingerprint_version = %v, want %d", got, core.FingerprintVersion)
+	}
+
+	w = ts.do(http.MethodGet, "/api/v1/apps/"+appID+"/stats", apiKey, nil)
+	versions, _ := decode(t, w)["fingerprint_versions"].(map[string]interface{})
+	if got := versions[strconv.Itoa(core.FingerprintVersion)]; got != float64(1) {
+		t.Errorf("stats fingerprint_versions = %v, want one crash under version %d", versions, core.FingerprintVersion)
+	}
+}
+
+func TestSubmitCrashMsgPack(t *testing.T) {
+	ts := newTestServer(t)
+	_, apiKey := ts.createApp(nil)
+	crash := testCrash(map[string]interface{}{
+		"user_id":     "user-1",
+		"environment": "production",
+		"metadata":    map[string]interface{}{"screen": "home", "retries": 3},
+		"breadcrumbs": []map[string]interface{}{{"type": "navigation", "message": "opened home"}},
+	})
+
+	var packed []byte
+	if err := codec.NewEncoderBytes(&packed, &codec.MsgpackHandle{}).Encode(crash); err != nil {
+		t.Fatalf("encode msgpack: %v", err)
+	}
+
+	fromJSON := ts.submitCrash(apiKey, crash)
+	w := ts.doRaw(http.MethodPost, "/api/v1/crashes", apiKey, "application/msgpack", packed)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("msgpack submit: status %d: %s", w.Code, w.Body.String())
+	}
+	fromMsgPack := decode(t, w)
+
+	if fromMsgPack["group_id"] != fromJSON["group_id"] || fromMsgPack["fingerprint"] != fromJSON["fingerprint"] {
+		t.Errorf("msgpack crash grouped as %v/%v, JSON crash as %v/%v",
+			fromMsgPack["group_id"], fromMsgPack["fingerprint"], fromJSON["group_id"], fromJSON["fingerprint"])
+	}
+
+	stored := func(id interface{}) map[string]interface{} {
+		body := decode(t, ts.do(http.MethodGet, "/api/v1/crashes/"+id.(string), apiKey, nil))
+		for _, field := range []string{"id", "created_at", "log_file_path"} {
+			delete(body, field)
+		}
+		return body
+	}
+	jsonCrash, msgpackCrash := stored(fromJSON["id"]), stored(fromMsgPack["id"])
+	if !reflect.DeepEqual(jsonCrash, msgpackCrash) {
+		t.Errorf("msgpack crash stored as\n%v\nJSON crash as\n%v", msgpackCrash, jsonCrash)
+	}
+}
+
+func TestSubmitCrashMsgPackInvalid(t *testing.T) {
+	ts := newTestServer(t)
+	_, apiKey := ts.createApp(nil)
+
+	var oversized []byte
+	huge := testCrash(map[string]interface{}{"error_message": strings.Repeat("x", maxMsgPackBodySize)})
+	if err := codec.NewEncoderBytes(&oversized, &codec.MsgpackHandle{}).Encode(huge); err != nil {
+		t.Fatalf("encode msgpack: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		body       []byte
+		wantStatus int
+	}{
+		{"malformed", []byte{0xc1, 0x00, 0xff}, http.StatusBadRequest},
+		{"too large", oversized, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ts.doRaw(http.MethodPost, "/api/v1/crashes", apiKey, "application/msgpack", tt.body)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestSubmitCrashDedupWindow(t *testing.T) {
+	tests := []struct {
+		name string
+		// window is the configured dedup window; wait is the time between
+		// the two submissions
+		window    time.Duration
+		wait      time.Duration
+		wantDedup bool
+	}{
+		{"within window", time.Hour, 0, true},
+		{"beyond window", 100 * time.Millisecond, 200 * time.Millisecond, false},
+		{"dedup disabled", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, func(cfg *config.Config) {
+				cfg.Ingest.DedupWindow = tt.window
+			})
+			_, apiKey := ts.createApp(nil)
+			crash := testCrash(map[string]interface{}{"event_id": "evt-1"})
+
+			first := ts.submitCrash(apiKey, crash)
+			time.Sleep(tt.wait)
+			w := ts.do(http.MethodPost, "/api/v1/crashes", apiKey, crash)
+			second := decode(t, w)
+
+			if tt.wantDedup {
+				if w.Code != http.StatusOK || second["duplicate"] != true || second["id"] != first["id"] {
+					t.Errorf("resubmission = %d %v, want the original crash %v", w.Code, second, first["id"])
+				}
+			} else if w.Code != http.StatusCreated || second["id"] == first["id"] {
+				t.Errorf("resubmission = %d %v, want a new crash", w.Code, second)
+			}
+		})
+	}
+}
+
+func TestGetCrashByEventID(t *testing.T) {
+	ts := newTestServer(t)
+	appID, apiKey := ts.createApp(nil)
+	otherAppID, otherKey := ts.createApp(map[string]interface{}{"name": "Other"})
+	submitted := ts.submitCrash(apiKey, testCrash(map[string]interface{}{"event_id": "evt-1"}))
+
+	tests := []struct {
+		name       string
+		path       string
+		key        string
+		wantStatus int
+	}{
+		{"found", "/api/v1/crashes/by-event/evt-1", apiKey, http.StatusOK},
+		{"not found", "/api/v1/crashes/by-event/evt-2", apiKey, http.StatusNotFound},
+		{"admin with app_id", "/api/v1/crashes/by-event/evt-1?app_id=" + appID, testAdminKey, http.StatusOK},
+		{"admin without app_id", "/api/v1/crashes/by-event/evt-1", testAdminKey, http.StatusBadRequest},
+		{"other app's event", "/api/v1/crashes/by-event/evt-1", otherKey, http.StatusNotFound},
+		{"other app's app_id", "/api/v1/crashes/by-event/evt-1?app_id=" + appID, otherKey, http.StatusForbidden},
+		{"admin with wrong app_id", "/api/v1/crashes/by-event/evt-1?app_id=" + otherAppID, testAdminKey, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ts.do(http.MethodGet, tt.path, tt.key, nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if w.Code == http.StatusOK {
+				if got := decode(t, w)["id"]; got != submitted["id"] {
+					t.Errorf("crash = %v, want %v", got, submitted["id"])
+				}
+			}
+		})
+	}
+
+	// The crash's current group is served, not the one in its payload file
+	target := ts.submitCrash(apiKey, testCrash(map[string]interface{}{"error_type": "OtherError"}))["group_id"].(string)
+	if w := ts.do(http.MethodPost, "/api/v1/groups/"+submitted["group_id"].(string)+"/merge", apiKey, map[string]interface{}{"target_group_id": target}); w.Code != http.StatusOK {
+		t.Fatalf("merge: status %d: %s", w.Code, w.Body.String())
+	}
+	crash := decode(t, ts.do(http.MethodGet, "/api/v1/crashes/by-event/evt-1", apiKey, nil))
+	if crash["group_id"] != target {
+		t.Errorf("group_id = %v, want the merge target %s", crash["group_id"], target)
+	}
+	if _, hasStack := crash["stack_trace"].([]interface{}); !hasStack {
+		t.Error("crash has no stack trace, want the full payload")
+	}
+}
+
+func TestListCrashesHasMetadata(t *testing.T) {
+	ts := newTestServer(t)
+	_, apiKey := ts.createApp(nil)
+	flagged := ts.submitCrash(apiKey, testCrash(map[string]interface{}{"metadata": map[string]interface{}{"feature_flag": "beta"}}))
+	ts.submitCrash(apiKey, testCrash(map[string]interface{}{"metadata": map[string]interface{}{"screen": "home"}}))
+
+	crashes := dataList(t, ts.do(http.MethodGet, "/api/v1/crashes?has_metadata=feature_flag", apiKey, nil))
+	if len(crashes) != 1 || crashes[0].(map[string]interface{})["id"] != flagged["id"] {
+		t.Errorf("crashes = %v, want only %v", crashes, flagged["id"])
+	}
+
+	crashes = dataList(t, ts.do(http.MethodGet, "/api/v1/crashes?has_metadata=feature_flag&has_metadata=screen", apiKey, nil))
+	if len(crashes) != 0 {
+		t.Errorf("crashes with both keys = %v, want none", crashes)
+	}
+}
+
+func TestSubmitCrashPreservesNumbers(t *testing.T) {
+	// 2^53 + 1 rounds to 2^53 as a float64
+	const body = `{"app_version":"1.0.0","platform":"android","error_type":"StateError","error_message":"Bad state",` +
+		`"stack_trace":[{"file_name":"lib/main.dart","line_number":10,"method_name":"main"}],` +
+		`"metadata":{"user_id":9007199254740993},"breadcrumbs":[{"message":"tap","data":{"count":12345}}]}`
+
+	tests := []struct {
+		name     string
+		preserve bool
+		want     []string
+	}{
+		{"preserved", true, []string{`"user_id":9007199254740993`, `"count":12345`}},
+		{"as float64", false, []string{`"user_id":9007199254740992`, `"count":12345`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, func(cfg *config.Config) {
+				cfg.Ingest.PreserveNumbers = tt.preserve
+			})
+			_, apiKey := ts.createApp(nil)
+
+			w := ts.doRaw(http.MethodPost, "/api/v1/crashes", apiKey, "application/json", []byte(body))
+			if w.Code != http.StatusCreated {
+				t.Fatalf("submit crash: status %d: %s", w.Code, w.Body.String())
+			}
+			id := decode(t, w)["id"].(string)
+
+			w = ts.do(http.MethodGet, "/api/v1/crashes/"+id, apiKey, nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("get crash: status %d", w.Code)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(w.Body.String(), want) {
+					t.Errorf("crash %s doesn't contain %s", w.Body.String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestDebugFingerprint(t *testing.T) {
+	stack := []map[string]interface{}{
+		{"file_name": "lib/screens/home.dart", "line_number": 42, "method_name": "build_closure1", "class_name": "Home$1"},
+		{"file_name": "dart:async/zone.dart", "line_number": 1, "method_name": "_rootRun", "class_name": "dart:async"},
+		{"file_name": "libc.so", "method_name": "abort", "native": true},
+	}
+
+	tests := []struct {
+		name string
+		app  map[string]interface{}
+		// frames are the normalized form or skip reason of each frame, and
+		// whether it is a framework frame
+		frames []core.FingerprintFrame
+	}{
+		{
+			name: "default config",
+			frames: []core.FingerprintFrame{
+				{Normalized: "Home:build:home.dart"},
+				{Normalized: "dart:async:_rootRun:zone.dart", Framework: true},
+				{Skipped: core.FrameSkipNative},
+			},
+		},
+		{
+			name: "framework frames included",
+			app:  map[string]interface{}{"include_framework_frames": true},
+			frames: []core.FingerprintFrame{
+				{Normalized: "Home:build:home.dart"},
+				{Normalized: "dart:async:_rootRun:zone.dart", Framework: true},
+				{Normalized: "abort:libc.so"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			path := "/api/v1/debug/fingerprint"
+			if tt.app != nil {
+				appID, _ := ts.createApp(tt.app)
+				path += "?app_id=" + appID
+			}
+
+			w := ts.do(http.MethodPost, path, testAdminKey, testCrash(map[string]interface{}{"stack_trace": stack}))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+			var resp struct {
+				Fingerprint string                      `json:"fingerprint"`
+				Explanation core.FingerprintExplanation `json:"explanation"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+
+			if resp.Fingerprint == "" || resp.Fingerprint != resp.Explanation.Fingerprint {
+				t.Errorf("fingerprint = %q, explanation's = %q", resp.Fingerprint, resp.Explanation.Fingerprint)
+			}
+			if len(resp.Explanation.Frames) != len(tt.frames) {
+				t.Fatalf("frames = %+v, want %d", resp.Explanation.Frames, len(tt.frames))
+			}
+			for i, want := range tt.frames {
+				got := resp.Explanation.Frames[i]
+				if got.Index != i || got.Normalized != want.Normalized || got.Skipped != want.Skipped || got.Framework != want.Framework {
+					t.Errorf("frame %d = {normalized: %q, skipped: %q, framework: %v}, want {%q, %q, %v}",
+						i, got.Normalized, got.Skipped, got.Framework, want.Normalized, want.Skipped, want.Framework)
+				}
+			}
+		})
+	}
+
+	// Only admins may inspect grouping
+	ts := newTestServer(t)
+	_, apiKey := ts.createApp(nil)
+	if w := ts.do(http.MethodPost, "/api/v1/debug/fingerprint", apiKey, testCrash(nil)); w.Code != http.StatusUnauthorized && w.Code != http.StatusForbidden {
+		t.Errorf("app key status = %d, want 401 or 403", w.Code)
+	}
+}
+
+func TestQuarantineReview(t *testing.T) {
+	tests := []struct {
+		name   string
+		crash  map[string]interface{}
+		reason string
+	}{
+		{
+			name:   "garbage stack",
+			crash:  testCrash(map[string]interface{}{"stack_trace": []map[string]interface{}{{"line_number": 1}, {"line_number": 2}}}),
+			reason: core.QuarantineGarbageStack,
+		},
+		{
+			name:   "unprintable error type",
+			crash:  testCrash(map[string]interface{}{"error_type": "State\u0001Error"}),
+			reason: core.QuarantineBlankErrorType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			appID, apiKey := ts.createApp(nil)
+			ts.submitCrash(apiKey, testCrash(nil))
+			id := ts.submitCrash(apiKey, tt.crash)["id"].(string)
+
+			quarantined := dataList(t, ts.do(http.MethodGet, "/api/v1/crashes/quarantine?app_id="+appID, testAdminKey, nil))
+			if len(quarantined) != 1 {
+				t.Fatalf("quarantined crashes = %v, want the suspicious one", quarantined)
+			}
+			if got := quarantined[0].(map[string]interface{}); got["id"] != id || got["quarantine_reason"] != tt.reason {
+				t.Errorf("quarantined crash = %v, want %s with reason %s", got, id, tt.reason)
+			}
+			// Quarantined crashes are kept out of normal lists and stats
+			if crashes := dataList(t, ts.do(http.MethodGet, "/api/v1/crashes?app_id="+appID, apiKey, nil)); len(crashes) != 1 {
+				t.Errorf("listed crashes = %d, want only the genuine one", len(crashes))
+			}
+			if groups := dataList(t, ts.do(http.MethodGet, "/api/v1/groups?app_id="+appID, apiKey, nil)); len(groups) != 1 {
+				t.Errorf("groups = %d, want only the genuine crash's", len(groups))
+			}
+
+			w := ts.do(http.MethodPost, "/api/v1/crashes/"+id+"/release", testAdminKey, nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("release: status %d: %s", w.Code, w.Body.String())
+			}
+			if decode(t, w)["group_id"] == "" {
+				t.Errorf("released crash has no group")
+			}
+			if quarantined := dataList(t, ts.do(http.MethodGet, "/api/v1/crashes/quarantine?app_id="+appID, testAdminKey, nil)); len(quarantined) != 0 {
+				t.Errorf("quarantined crashes after release = %v, want none", quarantined)
+			}
+			if crashes := dataList(t, ts.do(http.MethodGet, "/api/v1/crashes?app_id="+appID, apiKey, nil)); len(crashes) != 2 {
+				t.Errorf("listed crashes after release = %d, want 2", len(crashes))
+			}
+			if w := ts.do(http.MethodPost, "/api/v1/crashes/"+id+"/release", testAdminKey, nil); w.Code != http.StatusConflict {
+				t.Errorf("second release: status %d, want 409", w.Code)
+			}
+		})
+	}
+}
+
+func TestReleaseCrashRunsPipeline(t *testing.T) {
+	ts := newTestServer(t)
+	appID, apiKey := ts.createApp(nil)
+	id := ts.submitCrash(apiKey, testCrash(map[string]interface{}{"error_type": "State\u0001Error", "app_version": "2.0.0"}))["id"].(string)
+
+	w := ts.do(http.MethodPost, "/api/v1/crashes/"+id+"/release", testAdminKey, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("release: status %d: %s", w.Code, w.Body.String())
+	}
+	groupID, _ := decode(t, w)["group_id"].(string)
+
+	// The crash is stored as ingestion would have stored it
+	group := decode(t, ts.do(http.MethodGet, "/api/v1/groups/"+groupID, apiKey, nil))
+	if group["culprit"] != "App.main" || group["latest_crash_id"] != id {
+		t.Errorf("group = %v, want culprit App.main and latest crash %s", group, id)
+	}
+	summary := decode(t, ts.do(http.MethodGet, "/api/v1/apps/"+appID+"/summary", apiKey, nil))
+	deploys, _ := summary["recent_deploys"].([]interface{})
+	if len(deploys) != 1 || deploys[0].(map[string]interface{})["version"] != "2.0.0" {
+		t.Errorf("recent_deploys = %v, want the released crash's version", deploys)
+	}
+}
+
+func TestDeleteQuarantinedCrash(t *testing.T) {
+	ts := newTestServer(t)
+	appID, apiKey := ts.createApp(nil)
+	id := ts.submitCrash(apiKey, testCrash(map[string]interface{}{"error_type": "State\u0001Error"}))["id"].(string)
+
+	if w := ts.do(http.MethodDelete, "/api/v1/crashes/"+id, testAdminKey, nil); w.Code != http.StatusOK && w.Code != http.StatusNoContent {
+		t.Fatalf("delete: status %d: %s", w.Code, w.Body.String())
+	}
+	if quarantined := dataList(t, ts.do(http.MethodGet, "/api/v1/crashes/quarantine?app_id="+appID, testAdminKey, nil)); len(quarantined) != 0 {
+		t.Errorf("quarantined crashes after delete = %v, want none", quarantined)
+	}
+}
+
+func TestSubmitCrashFieldNaming(t *testing.T) {
+	tests := []struct {
+		name  string
+		crash map[string]interface{}
+	}{
+		{
+			name: "snake_case",
+			crash: map[string]interface{}{
+				"app_version": "2.1.0", "platform": "android", "os_version": "14", "device_model": "Pixel 8",
+				"error_type": "StateError", "error_message": "Bad state",
+				"stack_trace": []map[string]interface{}{{"file_name": "lib/main.dart", "line_number": 10, "method_name": "main"}},
+			},
+		},
+		{
+			name: "camelCase",
+			crash: map[string]interface{}{
+				"appVersion": "2.1.0", "platform": "android", "osVersion": "14", "deviceModel": "Pixel 8",
+				"errorType": "StateError", "errorMessage": "Bad state",
+				"stackTrace": []map[string]interface{}{{"fileName": "lib/main.dart", "lineNumber": 10, "methodName": "main"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			_, apiKey := ts.createApp(nil)
+
+			w := ts.do(http.MethodPost, "/api/v1/crashes", apiKey, tt.crash)
+			if w.Code != http.StatusCreated {
+				t.Fatalf("submit crash: status %d: %s", w.Code, w.Body.String())
+			}
+			crash, err := ts.repo.GetCrash(context.Background(), decode(t, w)["id"].(string))
+			if err != nil || crash == nil {
+				t.Fatalf("get crash: %v, %v", crash, err)
+			}
+			if crash.AppVersion != "2.1.0" || crash.OSVersion != "14" || crash.DeviceModel != "Pixel 8" || crash.ErrorType != "StateError" {
+				t.Errorf("crash = {version %q, os %q, device %q, type %q}, want the submitted fields",
+					crash.AppVersion, crash.OSVersion, crash.DeviceModel, crash.ErrorType)
+			}
+		})
+	}
+}
+
+func TestGetCrashBatch(t *testing.T) {
+	ts := newTestServer(t)
+	_, apiKey := ts.createApp(nil)
+	_, otherKey := ts.createApp(map[string]interface{}{"name": "Other App"})
+	var ids []string
+	for i := 0; i < 3; i++ {
+		ids = append(ids, ts.submitCrash(apiKey, testCrash(map[string]interface{}{"error_message": "crash " + strconv.Itoa(i)}))["id"].(string))
+	}
+	otherID := ts.submitCrash(otherKey, testCrash(nil))["id"].(string)
+
+	tests := []struct {
+		name        string
+		key         string
+		query       string
+		wantIDs     []string
+		wantMissing []string
+	}{
+		{"in request order", apiKey, ids[2] + "," + ids[0] + "&ids=" + ids[1], []string{ids[2], ids[0], ids[1]}, nil},
+		{"duplicates", apiKey, ids[0] + "," + ids[0], []string{ids[0]}, nil},
+		{"other app's crash", apiKey, ids[0] + "," + otherID, []string{ids[0]}, []string{otherID}},
+		{"unknown", apiKey, ids[1] + ",nope", []string{ids[1]}, []string{"nope"}},
+		{"admin", testAdminKey, ids[0] + "," + otherID, []string{ids[0], otherID}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ts.do(http.MethodGet, "/api/v1/crashes/batch?ids="+tt.query, tt.key, nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+			var resp struct {
+				Data []struct {
+					ID         string            `json:"id"`
+					StackTrace []core.StackFrame `json:"stack_trace"`
+				} `json:"data"`
+				Missing []string `json:"missing"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			var got []string
+			for _, crash := range resp.Data {
+				got = append(got, crash.ID)
+				// Full crashes are read from their payload files
+				if len(crash.StackTrace) == 0 {
+					t.Errorf("crash %s has no stack trace", crash.ID)
+				}
+			}
+			if !slices.Equal(got, tt.wantIDs) || !slices.Equal(resp.Missing, tt.wantMissing) {
+				t.Errorf("crashes = %v, missing %v, want %v, missing %v", got, resp.Missing, tt.wantIDs, tt.wantMissing)
+			}
+		})
+	}
+
+	if w := ts.do(http.MethodGet, "/api/v1/crashes/batch", apiKey, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("no ids: status %d, want 400", w.Code)
+	}
+	var many []string
+	for i := 0; i <= maxBatchCrashIDs; i++ {
+		many = append(many, "id-"+strconv.Itoa(i))
+	}
+	if w := ts.do(http.MethodGet, "/api/v1/crashes/batch?ids="+strings.Join(many, ","), apiKey, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("too many ids: status %d, want 400", w.Code)
+	}
+}
+
+func TestCorruptCrashFiles(t *testing.T) {
+	ts := newTestServer(t)
+	appID, apiKey := ts.createApp(nil)
+	ids := make(map[string]string)
+	for _, name := range []string{"intact", "corrupt", "missing"} {
+		ids[name] = ts.submitCrash(apiKey, testCrash(map[string]interface{}{"error_message": name}))["id"].(string)
+	}
+	// filePath returns the payload file of a crash
+	filePath := func(id string) string {
+		crash, err := ts.repo.GetCrash(context.Background(), id)
+		if err != nil || crash == nil || crash.LogFilePath == "" {
+			t.Fatalf("get crash %s: %v", id, err)
+		}
+		return filepath.Join(ts.cfg.Storage.LogsPath, crash.LogFilePath)
+	}
+	corruptPath := filePath(ids["corrupt"])
+	if err := os.WriteFile(corruptPath, []byte(`{"id": "trunc`), 0644); err != nil {
+		t.Fatalf("corrupt file: %v", err)
+	}
+	if err := os.Remove(filePath(ids["missing"])); err != nil {
+		t.Fatalf("remove file: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		wantStatus string
+		// wantStack is whether the full payload, with its stack trace, is served
+		wantStack bool
+	}{
+		{"intact", "", true},
+		{"corrupt", core.FileStatusCorrupt, false},
+		{"missing", core.FileStatusMissing, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ts.do(http.MethodGet, "/api/v1/crashes/"+ids[tt.name], apiKey, nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+			}
+			crash := decode(t, w)
+			if got, _ := crash["file_status"].(string); got != tt.wantStatus {
+				t.Errorf("file_status = %q, want %q", got, tt.wantStatus)
+			}
+			// The database row is served either way
+			if crash["id"] != ids[tt.name] || crash["error_message"] != tt.name {
+				t.Errorf("crash = %v, want the indexed fields", crash)
+			}
+			if _, hasStack := crash["stack_trace"].([]interface{}); hasStack != tt.wantStack {
+				t.Errorf("has stack trace = %v, want %v", hasStack, tt.wantStack)
+			}
+		})
+	}
+
+	// The scanner reports the corrupt file for cleanup
+	if w := ts.do(http.MethodPost, "/api/v1/system/file-scan", testAdminKey, nil); w.Code != http.StatusAccepted {
+		t.Fatalf("start scan: status %d: %s", w.Code, w.Body.String())
+	}
+	var report fileScanReport
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		w := ts.do(http.MethodGet, "/api/v1/system/file-scan", testAdminKey, nil)
+		if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+			t.Fatalf("decode report: %v", err)
+		}
+		if report.Status != fileScanRunning || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if report.Status != fileScanDone || report.FilesScanned != 2 || report.CorruptFiles != 1 {
+		t.Fatalf("report = %+v, want 2 files scanned and 1 corrupt", report)
+	}
+	corrupt := report.Apps[appID]
+	if len(corrupt) != 1 || filepath.Join(ts.cfg.Storage.LogsPath, corrupt[0].Path) != corruptPath {
+		t.Errorf("corrupt files = %+v, want %s", corrupt, corruptPath)
+	}
+}
+
+func TestGeoIPEnrichment(t *testing.T) {
+	database := filepath.Join(t.TempDir(), "ranges.csv")
+	if err := os.WriteFile(database, []byte("192.0.2.0,192.0.2.255,DE\n198.51.100.0,198.51.100.255,FR\n"), 0644); err != nil {
+		t.Fatalf("write database: %v", err)
+	}
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.Ingest.GeoIP.Enabled = true
+		cfg.Ingest.GeoIP.DatabasePath = database
+	})
+	appID, apiKey := ts.createApp(nil)
+
+	submitFrom := func(remoteAddr string) string {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/crashes", jsonBody(t, testCrash(nil)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", apiKey)
+		req.RemoteAddr = remoteAddr
+		w := ts.serve(req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("submit crash: status %d: %s", w.Code, w.Body.String())
+		}
+		return decode(t, w)["id"].(string)
+	}
+
+	tests := []struct {
+		remoteAddr string
+		want       interface{}
+	}{
+		{"192.0.2.10:4000", "DE"},
+		{"198.51.100.7:4000", "FR"},
+		// Unknown addresses get no country
+		{"203.0.113.1:4000", nil},
+	}
+	for _, tt := range tests {
+		id := submitFrom(tt.remoteAddr)
+		crash := decode(t, ts.do(http.MethodGet, "/api/v1/crashes/"+id, testAdminKey, nil))
+		if crash["country"] != tt.want {
+			t.Errorf("%s: country = %v, want %v", tt.remoteAddr, crash["country"], tt.want)
+		}
+		if strings.Contains(fmt.Sprint(crash), strings.Split(tt.remoteAddr, ":")[0]) {
+			t.Errorf("%s: client IP stored with the crash", tt.remoteAddr)
+		}
+	}
+
+	crashes := dataList(t, ts.do(http.MethodGet, "/api/v1/crashes?app_id="+appID+"&country=FR", testAdminKey, nil))
+	if len(crashes) != 1 || crashes[0].(map[string]interface{})["country"] != "FR" {
+		t.Errorf("crashes from FR = %v, want 1", crashes)
+	}
+}
+
+func TestReplayAlerts(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		replays int
+	)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Inceptor-Replay") == "true" {
+			mu.Lock()
+			replays++
+			mu.Unlock()
+		}
+	}))
+	defer receiver.Close()
+
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.Alerts.Replay.Cooldown = time.Hour
+		cfg.Alerts.Replay.MaxPerHour = 2
+	})
+	appID, apiKey := ts.createApp(nil)
+	w := ts.do(http.MethodPost, "/api/v1/alerts", testAdminKey, map[string]interface{}{
+		"app_id":  appID,
+		"type":    "webhook",
+		"config":  map[string]interface{}{"url": receiver.URL, "conditions": map[string]interface{}{"on_new_group": true}},
+		"enabled": true,
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create alert: status %d: %s", w.Code, w.Body.String())
+	}
+
+	first := ts.submitCrash(apiKey, testCrash(nil))["id"].(string)
+	second := ts.submitCrash(apiKey, testCrash(nil))["id"].(string)
+	third := ts.submitCrash(apiKey, testCrash(map[string]interface{}{"error_type": "RangeError"}))["id"].(string)
+
+	tests := []struct {
+		name       string
+		crashID    string
+		key        string
+		wantStatus int
+		wantEvent  string
+		wantSent   int
+	}{
+		{"crash that opened its group", first, testAdminKey, http.StatusOK, string(core.AlertEventNewGroup), 1},
+		{"same crash again", first, testAdminKey, http.StatusTooManyRequests, "", 0},
+		// No new_crash condition is set, so nothing matches
+		{"later crash in the group", second, testAdminKey, http.StatusOK, string(core.AlertEventNewCrash), 0},
+		{"over the hourly cap", third, testAdminKey, http.StatusTooManyRequests, "", 0},
+		{"unknown crash", "missing", testAdminKey, http.StatusNotFound, "", 0},
+		{"app key", first, apiKey, http.StatusForbidden, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			replays = 0
+			mu.Unlock()
+
+			w := ts.do(http.MethodPost, "/api/v1/crashes/"+tt.crashID+"/replay-alerts", tt.key, nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+				t.Errorf("429 response without Retry-After")
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			resp := decode(t, w)
+			if resp["event_type"] != tt.wantEvent {
+				t.Errorf("event_type = %v, want %s", resp["event_type"], tt.wantEvent)
+			}
+			if alerts, _ := resp["alerts"].([]interface{}); len(alerts) != tt.wantSent {
+				t.Errorf("alerts = %v, want %d", resp["alerts"], tt.wantSent)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if replays != tt.wantSent {
+				t.Errorf("webhooks replayed = %d, want %d", replays, tt.wantSent)
+			}
+		})
+	}
+}
+
+func TestCursorPagination(t *testing.T) {
+	ts := newTestServer(t)
+	appID, apiKey := ts.createApp(nil)
+	start := time.Now().UTC().Add(-time.Hour)
+	for i, errorType := range []string{"A", "B", "C", "D", "E"} {
+		ts.addGroup(appID, errorType, start.Add(time.Duration(i)*time.Minute))
+	}
+
+	// page follows next_cursor from the first page, returning the IDs
+	// listed and the number of pages
+	page := func(t *testing.T, query string) ([]string, int) {
+		t.Helper()
+
+		var ids []string
+		pages := 0
+		cursor := ""
+		for pages < 10 {
+			path := query
+			if cursor != "" {
+				path += "&cursor=" + cursor
+			}
+			w := ts.do(http.MethodGet, path, apiKey, nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+			body := decode(t, w)
+			pages++
+			if body["total"] != float64(5) {
+				t.Errorf("total = %v, want every match", body["total"])
+			}
+			data, _ := body["data"].([]interface{})
+			for _, item := range data {
+				ids = append(ids, item.(map[string]interface{})["id"].(string))
+			}
+			next, ok := body["next_cursor"].(string)
+			if !ok {
+				break
+			}
+			cursor = next
+		}
+		return ids, pages
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		all       string
+		wantPages int
+	}{
+		{"crashes", "/api/v1/crashes?limit=2", "/api/v1/crashes?limit=50", 3},
+		{"crashes on one page", "/api/v1/crashes?limit=5", "/api/v1/crashes?limit=50", 1},
+		{"groups by last seen", "/api/v1/groups?sort_by=last_seen&limit=2", "/api/v1/groups?sort_by=last_seen&limit=50", 3},
+		{"groups by first seen ascending", "/api/v1/groups?sort_by=first_seen&sort_order=asc&limit=2", "/api/v1/groups?sort_by=first_seen&sort_order=asc&limit=50", 3},
+		// Only time sorts have cursors, so there's a single page
+		{"groups by count", "/api/v1/groups?sort_by=occurrence_count&limit=2", "/api/v1/groups?sort_by=occurrence_count&limit=2", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, _ := page(t, tt.all)
+			got, pages := page(t, tt.query)
+			if pages != tt.wantPages || !slices.Equal(got, want) {
+				t.Errorf("paged %v over %d pages, want %v over %d", got, pages, want, tt.wantPages)
+			}
+		})
+	}
+
+	for _, path := range []string{
+		"/api/v1/crashes?cursor=bogus!",
+		"/api/v1/groups?sort_by=last_seen&cursor=bogus!",
+		"/api/v1/groups?sort_by=occurrence_count&cursor=" + storage.EncodeCursor(start, "group-1"),
+	} {
+		if w := ts.do(http.MethodGet, path, apiKey, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", path, w.Code)
+		}
+	}
+}
+
+func TestExportCrashes(t *testing.T) {
+	ts := newTestServer(t)
+	appID, apiKey := ts.createApp(nil)
+	otherID, otherKey := ts.createApp(map[string]interface{}{"name": "Other App"})
+	first := ts.submitCrash(apiKey, testCrash(map[string]interface{}{"error_message": "Bad state, \"quoted\"\nsecond line"}))["id"].(string)
+	second := ts.submitCrash(apiKey, testCrash(map[string]interface{}{"error_type": "RangeError", "device_model": "Pixel 8"}))["id"].(string)
+	other := ts.submitCrash(otherKey, testCrash(nil))["id"].(string)
+
+	tests := []struct {
+		name       string
+		query      string
+		key        string
+		wantStatus int
+		// wantIDs are the exported crashes, newest first
+		wantIDs []string
+	}{
+		{"all apps", "", testAdminKey, http.StatusOK, []string{other, second, first}},
+		{"one app", "?app_id=" + appID, testAdminKey, http.StatusOK, []string{second, first}},
+		{"filtered", "?app_id=" + appID + "&error_type=RangeError", testAdminKey, http.StatusOK, []string{second}},
+		{"app key sees its own app", "?app_id=" + appID, otherKey, http.StatusOK, []string{other}},
+		{"paging ignored", "?app_id=" + appID + "&limit=1&offset=1", testAdminKey, http.StatusOK, []string{second, first}},
+		{"csv", "?format=csv&app_id=" + otherID, testAdminKey, http.StatusOK, []string{other}},
+		{"no matches", "?app_id=" + appID + "&error_type=None", testAdminKey, http.StatusOK, nil},
+		{"other format", "?format=json", testAdminKey, http.StatusBadRequest, nil},
+		{"no key", "", "", http.StatusUnauthorized, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ts.do(http.MethodGet, "/api/v1/crashes/export"+tt.query, tt.key, nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+			if cd := w.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, `attachment; filename="crashes-`) || !strings.HasSuffix(cd, `.csv"`) {
+				t.Errorf("Content-Disposition = %q", cd)
+			}
+
+			records, err := csv.NewReader(w.Body).ReadAll()
+			if err != nil {
+				t.Fatalf("parse CSV: %v", err)
+			}
+			if len(records) == 0 || !slices.Equal(records[0], crashExportColumns) {
+				t.Fatalf("header = %v, want %v", records, crashExportColumns)
+			}
+			var ids []string
+			for _, record := range records[1:] {
+				ids = append(ids, record[0])
+			}
+			if !slices.Equal(ids, tt.wantIDs) {
+				t.Errorf("exported %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+
+	// Fields are quoted as needed and read back unchanged
+	w := ts.do(http.MethodGet, "/api/v1/crashes/export?app_id="+appID+"&error_type=StateError", testAdminKey, nil)
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil || len(records) != 2 {
+		t.Fatalf("records = %v, %v", records, err)
+	}
+	row := records[1]
+	if row[1] != appID || row[2] != "1.0.0" || row[3] != "android" || row[4] != "14" || row[6] != "StateError" || row[7] != "Bad state, \"quoted\"\nsecond line" {
+		t.Errorf("row = %q", row)
+	}
+	if _, err := time.Parse(time.RFC3339, row[9]); err != nil || !strings.HasSuffix(row[9], "Z") {
+		t.Errorf("created_at = %q, want RFC 3339 in UTC", row[9])
+	}
+}
+
+func TestExportCrashesPages(t *testing.T) {
+	ts := newTestServer(t)
+	appID, _ := ts.createApp(nil)
+	groupID := ts.addGroup(appID, "StateError", time.Now())
+
+	// Pairs of crashes share a timestamp, so some fall on a page boundary
+	start := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	total := 2*crashExportPage + 1
+	want := make(map[string]bool)
+	for i := 0; i < total; i++ {
+		crash := &core.Crash{ID: fmt.Sprintf("crash-%04d", i), AppID: appID, GroupID: groupID, ErrorType: "StateError", CreatedAt: start.Add(time.Duration(i/2) * time.Second)}
+		if err := ts.repo.CreateCrash(context.Background(), crash); err != nil {
+			t.Fatalf("create crash: %v", err)
+		}
+		want[crash.ID] = true
+	}
+
+	w := ts.do(http.MethodGet, "/api/v1/crashes/export?error_type=StateError&app_id="+appID, testAdminKey, nil)
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("parse CSV: %v", err)
+	}
+	// The group's own crash is exported too
+	rows := records[1:]
+	if len(rows) != total+1 {
+		t.Fatalf("exported %d crashes, want %d", len(rows), total+1)
+	}
+	seen := make(map[string]bool)
+	var last time.Time
+	for i, row := range rows {
+		if seen[row[0]] {
+			t.Errorf("crash %s exported twice", row[0])
+		}
+		seen[row[0]] = true
+		createdAt, _ := time.Parse(time.RFC3339, row[9])
+		if i > 0 && createdAt.After(last) {
+			t.Errorf("row %d created at %s, after the row before it", i, createdAt)
+		}
+		last = createdAt
+	}
+	for id := range want {
+		if !seen[id] {
+			t.Errorf("crash %s missing from the export", id)
+		}
+	}
+}
+
+func TestExportCrashesNDJSON(t *testing.T) {
+	ts := newTestServer(t)
+	appID, apiKey := ts.createApp(nil)
+	submitted := ts.submitCrash(apiKey, testCrash(map[string]interface{}{"metadata": map[string]interface{}{"screen": "checkout"}}))["id"].(string)
+
+	// Crashes without files are exported from their rows, paged by the
+	// payload page size
+	groupID := ts.addGroup(appID, "RangeError", time.Now())
+	start := time.Now().UTC().Add(-time.Hour)
+	for i := 0; i < 2*crashExportPayloadPage; i++ {
+		crash := &core.Crash{ID: fmt.Sprintf("crash-%04d", i), AppID: appID, GroupID: groupID, ErrorType: "RangeError", CreatedAt: start.Add(time.Duration(i) * time.Second)}
+		if err := ts.repo.CreateCrash(context.Background(), crash); err != nil {
+			t.Fatalf("create crash: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCount int
+	}{
+		{"full payload", "&error_type=StateError", 1},
+		{"several pages", "&error_type=RangeError", 2*crashExportPayloadPage + 1},
+		{"everything", "", 2*crashExportPayloadPage + 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ts.do(http.MethodGet, "/api/v1/crashes/export?format=ndjson&app_id="+appID+tt.query, testAdminKey, nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+			if cd := w.Header().Get("Content-Disposition"); !strings.HasSuffix(cd, `.ndjson"`) {
+				t.Errorf("Content-Disposition = %q", cd)
+			}
+
+			lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+			if len(lines) != tt.wantCount {
+				t.Fatalf("exported %d lines, want %d", len(lines), tt.wantCount)
+			}
+			seen := make(map[string]bool)
+			for _, line := range lines {
+				var crash map[string]interface{}
+				if err := json.Unmarshal([]byte(line), &crash); err != nil {
+					t.Fatalf("line %q: %v", line, err)
+				}
+				id, _ := crash["id"].(string)
+				if seen[id] {
+					t.Errorf("crash %s exported twice", id)
+				}
+				seen[id] = true
+
+				// The submitted crash carries what only its file holds
+				if id != submitted {
+					continue
+				}
+				frames, _ := crash["stack_trace"].([]interface{})
+				metadata, _ := crash["metadata"].(map[string]interface{})
+				if len(frames) != 2 || metadata["screen"] != "checkout" {
+					t.Errorf("crash = %v, want its full payload", crash)
+				}
+			}
+		})
+	}
+}
+
+func TestSubmitCrashRateLimit(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.Ingest.RateLimit.Rate = 0.001
+		cfg.Ingest.RateLimit.Burst = 1
+	})
+
+	tests := []struct {
+		name      string
+		app       map[string]interface{}
+		wantBurst int
+	}{
+		{"server default", nil, 1},
+		{"app burst", map[string]interface{}{"rate_limit": map[string]interface{}{"burst": 3}}, 3},
+		{"app rate only", map[string]interface{}{"rate_limit": map[string]interface{}{"rate": 0.002}}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appID, apiKey := ts.createApp(tt.app)
+			for i := 0; i < tt.wantBurst; i++ {
+				if w := ts.do(http.MethodPost, "/api/v1/crashes", apiKey, testCrash(nil)); w.Code == http.StatusTooManyRequests {
+					t.Fatalf("submission %d limited within the burst of %d", i, tt.wantBurst)
+				}
+			}
+			w := ts.do(http.MethodPost, "/api/v1/crashes", apiKey, testCrash(nil))
+			if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+				t.Fatalf("status = %d, Retry-After %q; want 429 past the burst", w.Code, w.Header().Get("Retry-After"))
+			}
+			if body := decode(t, w); body["code"] != CodeRateLimited {
+				t.Errorf("body = %v", body)
+			}
+
+			// Disabled apps are turned away before their limit is checked
+			if w := ts.do(http.MethodPatch, "/api/v1/apps/"+appID, testAdminKey, map[string]interface{}{"disabled": true}); w.Code != http.StatusOK {
+				t.Fatalf("disable app: %d %s", w.Code, w.Body.String())
+			}
+			if w := ts.do(http.MethodPost, "/api/v1/crashes", apiKey, testCrash(nil)); w.Code != http.StatusForbidden {
+				t.Errorf("disabled app: status = %d, want 403", w.Code)
+			}
+		})
+	}
+}
+
+func TestAppRateLimitSettings(t *testing.T) {
+	ts := newTestServer(t)
+	appID, _ := ts.createApp(map[string]interface{}{"rate_limit": map[string]interface{}{"rate": 5, "burst": 10}})
+
+	rateLimit := func() core.RateLimit {
+		limit, _ := decode(t, ts.do(http.MethodGet, "/api/v1/apps/"+appID, testAdminKey, nil))["rate_limit"].(map[string]interface{})
+		rate, _ := limit["rate"].(float64)
+		burst, _ := limit["burst"].(float64)
+		return core.RateLimit{Rate: rate, Burst: int(burst)}
+	}
+	if got, want := rateLimit(), (core.RateLimit{Rate: 5, Burst: 10}); got != want {
+		t.Errorf("rate_limit = %+v, want %+v", got, want)
+	}
+
+	tests := []struct {
+		name      string
+		rateLimit map[string]interface{}
+		wantCode  int
+		want      core.RateLimit
+	}{
+		{"update", map[string]interface{}{"rate": 0.5, "burst": 2}, http.StatusOK, core.RateLimit{Rate: 0.5, Burst: 2}},
+		{"negative rate", map[string]interface{}{"rate": -1}, http.StatusBadRequest, core.RateLimit{Rate: 0.5, Burst: 2}},
+		{"negative burst", map[string]interface{}{"burst": -1}, http.StatusBadRequest, core.RateLimit{Rate: 0.5, Burst: 2}},
+		{"reset to defaults", map[string]interface{}{}, http.StatusOK, core.RateLimit{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ts.do(http.MethodPatch, "/api/v1/apps/"+appID, testAdminKey, map[string]interface{}{"rate_limit": tt.rateLimit})
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+			if got := rateLimit(); got != tt.want {
+				t.Errorf("rate_limit = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if w := ts.do(http.MethodPost, "/api/v1/apps", testAdminKey, map[string]interface{}{"name": "bad", "rate_limit": map[string]interface{}{"rate": -2}}); w.Code != http.StatusBadRequest {
+		t.Errorf("create with negative rate: status = %d, want 400", w.Code)
+	}
+}
+
+func TestSubmitCrashHistorical(t *testing.T) {
+	tests := []struct {
+		name            string
+		historicalAfter time.Duration
+		retentionDays   int
+		// age is how long before submission the crash occurred; zero sends
+		// no occurred_at
+		age            time.Duration
+		wantHistorical bool
+	}{
+		{"recent", 24 * time.Hour, 0, time.Hour, false},
+		{"older than historical_after", 24 * time.Hour, 0, 48 * time.Hour, true},
+		{"no occurred_at", 24 * time.Hour, 0, 0, false},
+		{"app retention", 0, 7, 8 * 24 * time.Hour, true},
+		{"within app retention", 0, 7, 6 * 24 * time.Hour, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, func(cfg *config.Config) {
+				cfg.Grouping.HistoricalAfter = tt.historicalAfter
+			})
+			_, apiKey := ts.createApp(map[string]interface{}{"retention_days": tt.retentionDays})
+			groupID := ts.submitCrash(apiKey, testCrash(nil))["group_id"].(string)
+			before, err := ts.repo.GetGroup(context.Background(), groupID)
+			if err != nil || before == nil {
+				t.Fatalf("get group: %v", err)
+			}
+
+			fields := map[string]interface{}{}
+			if tt.age > 0 {
+				fields["occurred_at"] = time.Now().Add(-tt.age).UTC().Format(time.RFC3339)
+			}
+			resp := ts.submitCrash(apiKey, testCrash(fields))
+			if resp["group_id"] != groupID {
+				t.Errorf("group = %v, want the existing %s", resp["group_id"], groupID)
+			}
+			if historical := resp["historical"] == true; historical != tt.wantHistorical {
+				t.Errorf("historical = %v, want %v", historical, tt.wantHistorical)
+			}
+
+			after, err := ts.repo.GetGroup(context.Background(), groupID)
+			if err != nil || after == nil {
+				t.Fatalf("get group: %v", err)
+			}
+			wantCount := 2
+			if tt.wantHistorical {
+				wantCount = 1
+			}
+			if after.OccurrenceCount != wantCount {
+				t.Errorf("occurrence count = %d, want %d", after.OccurrenceCount, wantCount)
+			}
+			if tt.wantHistorical && !after.LastSeen.Equal(before.LastSeen) {
+				t.Errorf("last seen moved from %v to %v", before.LastSeen, after.LastSeen)
+			}
+		})
+	}
+}
+
+func TestSubmitCrashMetadataLimits(t *testing.T) {
+	deep := map[string]interface{}{"a": map[string]interface{}{"b": map[string]interface{}{"c": "d"}}}
+	large := map[string]interface{}{"blob": strings.Repeat("x", 200), "user": "u1"}
+
+	tests := []struct {
+		name       string
+		action     string
+		metadata   map[string]interface{}
+		wantStatus int
+		wantCode   string
+		// wantMetadata is the stored metadata when the crash is accepted
+		wantMetadata map[string]interface{}
+		wantTrimmed  bool
+	}{
+		{"within limits", core.MetadataLimitTrim, map[string]interface{}{"user": "u1"}, http.StatusCreated, "", map[string]interface{}{"user": "u1"}, false},
+		{"deep trimmed", core.MetadataLimitTrim, deep, http.StatusCreated, "", map[string]interface{}{"a": map[string]interface{}{"b": "[max depth exceeded]"}}, true},
+		{"large trimmed", core.MetadataLimitTrim, large, http.StatusCreated, "", map[string]interface{}{"user": "u1"}, true},
+		{"deep rejected", core.MetadataLimitReject, deep, http.StatusBadRequest, CodeMetadataTooDeep, nil, false},
+		{"large rejected", core.MetadataLimitReject, large, http.StatusBadRequest, CodeMetadataTooLarge, nil, false},
+		{"within limits with reject", core.MetadataLimitReject, map[string]interface{}{"user": "u1"}, http.StatusCreated, "", map[string]interface{}{"user": "u1"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, func(cfg *config.Config) {
+				cfg.Ingest.MetadataLimits = config.MetadataLimitsConfig{MaxDepth: 2, MaxBytes: 100, Action: tt.action}
+			})
+			_, apiKey := ts.createApp(nil)
+
+			w := ts.do(http.MethodPost, "/api/v1/crashes", apiKey, testCrash(map[string]interface{}{"metadata": tt.metadata}))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			resp := decode(t, w)
+			if tt.wantStatus != http.StatusCreated {
+				if resp["code"] != tt.wantCode {
+					t.Errorf("code = %v, want %s", resp["code"], tt.wantCode)
+				}
+				return
+			}
+			if trimmed := resp["metadata_trimmed"] == true; trimmed != tt.wantTrimmed {
+				t.Errorf("metadata_trimmed = %v, want %v", trimmed, tt.wantTrimmed)
+			}
+
+			crash := decode(t, ts.do(http.MethodGet, "/api/v1/crashes/"+resp["id"].(string), testAdminKey, nil))
+			if !reflect.DeepEqual(crash["metadata"], tt.wantMetadata) {
+				t.Errorf("stored metadata = %v, want %v", crash["metadata"], tt.wantMetadata)
+			}
+		})
+	}
+}
+
+func TestSubmitCrashRawStackTrace(t *testing.T) {
+	ts := newTestServer(t)
+	_, apiKey := ts.createApp(nil)
+
+	tests := []struct {
+		name       string
+		fields     map[string]interface{}
+		wantStatus int
+		// wantMethods are the stored frames' method names
+		wantMethods []string
+	}{
+		{
+			name: "java",
+			fields: map[string]interface{}{
+				"stack_trace":     nil,
+				"raw_stack_trace": "java.lang.IllegalStateException: closed\n\tat com.example.Repo.fetch(Repo.java:12)\n\tat com.example.Main.onCreate(Main.kt:55)\n",
+			},
+			wantStatus:  http.StatusCreated,
+			wantMethods: []string{"fetch", "onCreate"},
+		},
+		{
+			name: "flutter",
+			fields: map[string]interface{}{
+				"platform":        "flutter",
+				"stack_trace":     nil,
+				"raw_stack_trace": "#0      Cart.checkout (package:shop/cart.dart:42:7)\n#1      main (package:shop/main.dart:10:3)\n",
+			},
+			wantStatus:  http.StatusCreated,
+			wantMethods: []string{"checkout", "main"},
+		},
+		{
+			name: "python",
+			fields: map[string]interface{}{
+				"platform":        "python",
+				"stack_trace":     nil,
+				"raw_stack_trace": "Traceback (most recent call last):\n  File \"/app/main.py\", line 10, in <module>\n  File \"/app/handlers.py\", line 42, in handle\nValueError: bad\n",
+			},
+			wantStatus:  http.StatusCreated,
+			wantMethods: []string{"handle", "<module>"},
+		},
+		{
+			name: "structured frames win",
+			fields: map[string]interface{}{
+				"raw_stack_trace": "\tat com.example.Repo.fetch(Repo.java:12)\n",
+			},
+			wantStatus:  http.StatusCreated,
+			wantMethods: []string{"main", "build"},
+		},
+		{
+			name:       "no frames in the text",
+			fields:     map[string]interface{}{"stack_trace": nil, "raw_stack_trace": "java.lang.OutOfMemoryError\n"},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "no stack trace",
+			fields:     map[string]interface{}{"stack_trace": nil},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crash := testCrash(nil)
+			for k, v := range tt.fields {
+				if v == nil {
+					delete(crash, k)
+				} else {
+					crash[k] = v
+				}
+			}
+			w := ts.do(http.MethodPost, "/api/v1/crashes", apiKey, crash)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusCreated {
+				return
+			}
+
+			stored := decode(t, ts.do(http.MethodGet, "/api/v1/crashes/"+decode(t, w)["id"].(string), testAdminKey, nil))
+			var methods []string
+			frames, _ := stored["stack_trace"].([]interface{})
+			for _, frame := range frames {
+				methods = append(methods, fmt.Sprint(frame.(map[string]interface{})["method_name"]))
+			}
+			if !slices.Equal(methods, tt.wantMethods) {
+				t.Errorf("methods = %v, want %v", methods, tt.wantMethods)
+			}
+		})
+	}
+}
+
+func TestSubmitCrashErrorTypeFallback(t *testing.T) {
+	tests := []struct {
+		name       string
+		fallback   string
+		errorType  interface{}
+		message    string
+		wantStatus int
+		// wantErrorType is the stored and fingerprinted error type
+		wantErrorType string
+	}{
+		{"disabled", "", "", "TypeError: x is undefined", http.StatusBadRequest, ""},
+		{"disabled, missing", "", nil, "TypeError: x is undefined", http.StatusBadRequest, ""},
+		{"unknown fallback", "first_word", "", "TypeError: x is undefined", http.StatusBadRequest, ""},
+		{"given", "top_frame", "StateError", "TypeError: x is undefined", http.StatusCreated, "StateError"},
+		{"top frame", "top_frame", "", "TypeError: x is undefined", http.StatusCreated, "UnknownError at App.main"},
+		{"message", "message", nil, "TypeError: x is undefined", http.StatusCreated, "TypeError"},
+		{"blank", "message", "  ", "TypeError: x is undefined", http.StatusCreated, "TypeError"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, func(cfg *config.Config) {
+				cfg.Grouping.ErrorTypeFallback = tt.fallback
+			})
+			_, apiKey := ts.createApp(nil)
+			crash := testCrash(map[string]interface{}{"error_type": tt.errorType, "error_message": tt.message})
+			if tt.errorType == nil {
+				delete(crash, "error_type")
+			}
+
+			w := ts.do(http.MethodPost, "/api/v1/crashes", apiKey, crash)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			debug := ts.do(http.MethodPost, "/api/v1/debug/fingerprint", testAdminKey, crash)
+			if tt.wantStatus != http.StatusCreated {
+				if debug.Code != tt.wantStatus {
+					t.Errorf("debug fingerprint status = %d, want %d", debug.Code, tt.wantStatus)
+				}
+				return
+			}
+
+			stored := decode(t, ts.do(http.MethodGet, "/api/v1/crashes/"+decode(t, w)["id"].(string), apiKey, nil))
+			if stored["error_type"] != tt.wantErrorType {
+				t.Errorf("stored error type = %v, want %q", stored["error_type"], tt.wantErrorType)
+			}
+			explanation, _ := decode(t, debug)["explanation"].(map[string]interface{})
+			if explanation["error_type"] != tt.wantErrorType {
+				t.Errorf("fingerprinted error type = %v, want %q", explanation["error_type"], tt.wantErrorType)
+			}
+		})
+	}
+}
+
+func TestSubmitCrashLastBreadcrumb(t *testing.T) {
+	ts := newTestServer(t)
+	appID, apiKey := ts.createApp(map[string]interface{}{
+		"fingerprint_config": map[string]interface{}{"include_last_breadcrumb": true},
+	})
+	_, otherKey := ts.createApp(map[string]interface{}{"name": "Other App"})
+	crash := func(messages ...string) map[string]interface{} {
+		var breadcrumbs []map[string]interface{}
+		for _, message := range messages {
+			breadcrumbs = append(breadcrumbs, map[string]interface{}{"type": "user", "category": "ui.click", "message": message})
+		}
+		return testCrash(map[string]interface{}{"breadcrumbs": breadcrumbs})
+	}
+
+	checkout := ts.submitCrash(apiKey, crash("Opened cart", "Tapped order 17"))["group_id"]
+	tests := []struct {
+		name     string
+		key      string
+		crash    map[string]interface{}
+		wantSame bool
+	}{
+		{"same action, other ID", apiKey, crash("Tapped order 23"), true},
+		{"other action", apiKey, crash("Tapped order 17", "Tapped cancel"), false},
+		{"no breadcrumbs", apiKey, crash(), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group := ts.submitCrash(tt.key, tt.crash)["group_id"]
+			if same := group == checkout; same != tt.wantSame {
+				t.Errorf("group = %v, same as %v: %v, want %v", group, checkout, same, tt.wantSame)
+			}
+		})
+	}
+
+	// Apps without the option group by the stack alone
+	if a, b := ts.submitCrash(otherKey, crash("Tapped checkout"))["group_id"], ts.submitCrash(otherKey, crash("Tapped cancel"))["group_id"]; a != b {
+		t.Errorf("groups without the option = %v, %v; want one", a, b)
+	}
+
+	// The debug endpoint sees the breadcrumbs too
+	explanation, _ := decode(t, ts.do(http.MethodPost, "/api/v1/debug/fingerprint?app_id="+appID, testAdminKey, crash("Tapped order 99")))["explanation"].(map[string]interface{})
+	if explanation["breadcrumb"] != "ui.click:Tapped order #" {
+		t.Errorf("explained breadcrumb = %v, want ui.click:Tapped order #", explanation["breadcrumb"])
+	}
+}
@@ -0,0 +1,99 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/flakerimi/inceptor/internal/auth"
+	"github.com/flakerimi/inceptor/internal/core"
+	"github.com/flakerimi/inceptor/internal/ingest"
+	"github.com/flakerimi/inceptor/internal/storage"
+)
+
+// flakyRepository fails to save crashes while down is set, as a database
+// with a transient fault would
+type flakyRepository struct {
+	storage.Repository
+	down atomic.Bool
+}
+
+func (r *flakyRepository) CreateCrash(ctx context.Context, crash *core.Crash) error {
+	if r.down.Load() {
+		return errors.New("database is locked")
+	}
+	return r.Repository.CreateCrash(ctx, crash)
+}
+
+func TestDeadLetterReprocess(t *testing.T) {
+	ts := newTestServer(t)
+	appID, apiKey := ts.createApp(nil)
+
+	// Serve through a repository that can be taken down
+	repo := &flakyRepository{Repository: ts.repo}
+	authManager := auth.NewManager(ts.repo, ts.repo)
+	limiter := core.NewRateLimiter(ts.cfg.Ingest.RateLimit.Rate, ts.cfg.Ingest.RateLimit.Burst)
+	ts.server.handler.Close()
+	ts.server = NewServer(repo, ts.files, ts.alerter, ingest.NewPipeline(repo, ts.files, ts.alerter, ts.cfg), limiter, nil, authManager, ts.cfg, "test")
+
+	repo.down.Store(true)
+	w := ts.do(http.MethodPost, "/api/v1/crashes", apiKey, testCrash(nil))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("submit while down: status %d, want 202: %s", w.Code, w.Body.String())
+	}
+	resp := decode(t, w)
+	id := resp["id"].(string)
+	if resp["status"] != "deferred" {
+		t.Errorf("status = %v, want deferred", resp["status"])
+	}
+
+	letters, err := ts.server.handler.deadLetters.List()
+	if err != nil {
+		t.Fatalf("list dead letters: %v", err)
+	}
+	if len(letters) != 1 || letters[0].ID != id || letters[0].Error != "database is locked" {
+		t.Fatalf("dead letters = %+v, want %s with the database error", letters, id)
+	}
+	if crash, _ := ts.repo.GetCrash(context.Background(), id); crash != nil {
+		t.Fatalf("crash %s was saved while the database was down", id)
+	}
+
+	tests := []struct {
+		name          string
+		down          bool
+		wantProcessed float64
+		wantFailed    float64
+		wantLetters   int
+	}{
+		{"still down", true, 0, 1, 1},
+		{"recovered", false, 1, 0, 0},
+		{"nothing left", false, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		repo.down.Store(tt.down)
+		w := ts.do(http.MethodPost, "/api/v1/system/dlq/reprocess", testAdminKey, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: reprocess: status %d: %s", tt.name, w.Code, w.Body.String())
+		}
+		resp := decode(t, w)
+		if resp["processed"] != tt.wantProcessed || resp["failed"] != tt.wantFailed {
+			t.Errorf("%s: reprocess = %v, want %v processed and %v failed", tt.name, resp, tt.wantProcessed, tt.wantFailed)
+		}
+		if letters, _ := ts.server.handler.deadLetters.List(); len(letters) != tt.wantLetters {
+			t.Errorf("%s: dead letters = %d, want %d", tt.name, len(letters), tt.wantLetters)
+		}
+	}
+
+	crash, err := ts.repo.GetCrash(context.Background(), id)
+	if err != nil || crash == nil {
+		t.Fatalf("reprocessed crash not saved: %v", err)
+	}
+	if crash.AppID != appID || crash.GroupID == "" {
+		t.Errorf("reprocessed crash = {app %s, group %q}, want grouped in %s", crash.AppID, crash.GroupID, appID)
+	}
+	if w := ts.do(http.MethodPost, "/api/v1/system/dlq/reprocess", apiKey, nil); w.Code != http.StatusForbidden && w.Code != http.StatusUnauthorized {
+		t.Errorf("reprocess with an app key: status %d, want 401 or 403", w.Code)
+	}
+}
@@ -0,0 +1,83 @@
+package rest
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/flakerimi/inceptor/internal/dsym"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// UploadDSYM stores an app's dSYM debug files for symbolicating its iOS
+// crashes. The body is the DWARF file from inside a .dSYM bundle or a zip
+// of one or more bundles, sent raw or as the "file" field of a multipart
+// form. Crashes received earlier are not symbolicated retroactively.
+func (h *Handler) UploadDSYM(c *gin.Context) {
+	if h.dsyms == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Symbolication is not enabled"})
+		return
+	}
+
+	app, err := h.repo.GetApp(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve app"})
+		return
+	}
+	if app == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+		return
+	}
+
+	maxSize := h.cfg.Ingest.Symbolication.MaxUploadBytes
+	if maxSize > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize)
+	}
+
+	body := io.Reader(c.Request.Body)
+	name := c.Query("name")
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			abortInvalidCrashBody(c, err)
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read upload"})
+			return
+		}
+		defer file.Close()
+		body = file
+		if name == "" {
+			name = header.Filename
+		}
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		abortInvalidCrashBody(c, err)
+		return
+	}
+	images, err := dsym.Parse(data, name, maxSize)
+	if errors.Is(err, dsym.ErrTooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Invalid dSYM", "code": CodeBodyTooLarge, "details": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dSYM", "details": err.Error()})
+		return
+	}
+
+	if err := h.dsyms.Save(app.ID, images); err != nil {
+		log.Error().Err(err).Str("app_id", app.ID).Msg("Failed to save dSYM")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save dSYM"})
+		return
+	}
+	h.symbolicator.Forget(app.ID, images)
+
+	log.Info().Str("app_id", app.ID).Int("images", len(images)).Msg("dSYM uploaded")
+	c.JSON(http.StatusCreated, gin.H{"data": images})
+}
@@ -0,0 +1,121 @@
+package rest
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"debug/macho"
+	"mime/multipart"
+	"net/http"
+	"testing"
+
+	"github.com/flakerimi/inceptor/internal/config"
+	"github.com/flakerimi/inceptor/internal/dsym/dsymtest"
+)
+
+const testDSYMUUID = "6A3B1C2D-0000-4000-8000-00000000A001"
+
+// testDSYM is a dSYM resolving offsets 0x1000-0x1100 to
+// HomeViewController.viewDidLoad at Home.swift:10
+var testDSYM = dsymtest.MachO(dsymtest.Image{
+	Cpu:   macho.CpuArm64,
+	UUID:  testDSYMUUID,
+	File:  "/src/MyApp/Home.swift",
+	Lines: []dsymtest.Line{{Offset: 0x1000, Line: 10}, {Offset: 0x1100}},
+	DIEs: []dsymtest.DIE{
+		{Tag: dwarf.TagClassType, Name: "HomeViewController", Children: []dsymtest.DIE{
+			{Tag: dwarf.TagSubprogram, Name: "viewDidLoad", Low: 0x1000, High: 0x1100},
+		}},
+	},
+})
+
+// multipartFile returns a multipart form body with data as its file field
+func multipartFile(t *testing.T, fileName string, data []byte) (string, []byte) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	part, err := w.CreateFormFile("file", fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	w.Close()
+	return w.FormDataContentType(), buf.Bytes()
+}
+
+func TestUploadDSYM(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.Ingest.Symbolication.MaxUploadBytes = 64 * 1024
+	})
+	appID, apiKey := ts.createApp(nil)
+	formType, form := multipartFile(t, "MyApp.dSYM", testDSYM)
+
+	tests := []struct {
+		name        string
+		path        string
+		key         string
+		contentType string
+		body        []byte
+		wantStatus  int
+		wantName    string
+	}{
+		{"raw", "/api/v1/apps/" + appID + "/dsyms?name=MyApp", testAdminKey, "application/octet-stream", testDSYM, http.StatusCreated, "MyApp"},
+		{"multipart", "/api/v1/apps/" + appID + "/dsyms", testAdminKey, formType, form, http.StatusCreated, "MyApp"},
+		{"not a dSYM", "/api/v1/apps/" + appID + "/dsyms", testAdminKey, "application/octet-stream", []byte("hello"), http.StatusBadRequest, ""},
+		{"too large", "/api/v1/apps/" + appID + "/dsyms", testAdminKey, "application/octet-stream", make([]byte, 65*1024), http.StatusRequestEntityTooLarge, ""},
+		{"unknown app", "/api/v1/apps/missing/dsyms", testAdminKey, "application/octet-stream", testDSYM, http.StatusNotFound, ""},
+		{"app key", "/api/v1/apps/" + appID + "/dsyms", apiKey, "application/octet-stream", testDSYM, http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ts.doRaw(http.MethodPost, tt.path, tt.key, tt.contentType, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusCreated {
+				return
+			}
+			images := dataList(t, w)
+			if len(images) != 1 {
+				t.Fatalf("images = %v, want one", images)
+			}
+			image := images[0].(map[string]interface{})
+			if image["uuid"] != testDSYMUUID || image["arch"] != "arm64" || image["name"] != tt.wantName {
+				t.Errorf("image = %v", image)
+			}
+		})
+	}
+
+	// Crashes received after the upload are symbolicated
+	resp := ts.submitCrash(apiKey, testCrash(map[string]interface{}{
+		"platform":     "ios",
+		"debug_images": []map[string]interface{}{{"name": "MyApp", "uuid": "6a3b1c2d00004000800000000000a001"}},
+		"stack_trace": []map[string]interface{}{
+			{"file_name": "MyApp", "method_name": "0x0000000104a8d010 MyApp + 4112"},
+		},
+	}))
+	crash := decode(t, ts.do(http.MethodGet, "/api/v1/crashes/"+resp["id"].(string), testAdminKey, nil))
+	frames, _ := crash["stack_trace"].([]interface{})
+	if len(frames) != 1 {
+		t.Fatalf("stack trace = %v", crash["stack_trace"])
+	}
+	frame := frames[0].(map[string]interface{})
+	if frame["method_name"] != "viewDidLoad" || frame["class_name"] != "HomeViewController" || frame["line_number"] != float64(10) {
+		t.Errorf("frame = %v, want HomeViewController.viewDidLoad at line 10", frame)
+	}
+	if crash["symbolicated"] != true {
+		t.Errorf("symbolicated = %v, want true", crash["symbolicated"])
+	}
+}
+
+func TestUploadDSYMDisabled(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.Ingest.Symbolication.Enabled = false
+	})
+	appID, _ := ts.createApp(nil)
+
+	w := ts.doRaw(http.MethodPost, "/api/v1/apps/"+appID+"/dsyms", testAdminKey, "application/octet-stream", testDSYM)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404 with symbolication disabled", w.Code)
+	}
+}
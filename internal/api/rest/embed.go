@@ -12,8 +12,10 @@ import (
 //go:embed all:static
 var staticFiles embed.FS
 
-// ServeStatic serves the embedded dashboard at root
-func ServeStatic(router *gin.Engine) {
+// ServeStatic serves the embedded dashboard at root. A non-nil root handler
+// answers "/" instead of the dashboard's overview page; its other pages are
+// still served.
+func ServeStatic(router *gin.Engine, root gin.HandlerFunc) {
 	staticFS, _ := fs.Sub(staticFiles, "static")
 	fileServer := http.FileServer(http.FS(staticFS))
 
@@ -32,6 +34,10 @@ func ServeStatic(router *gin.Engine) {
 	staticRoutes := []string{"/", "/apps", "/crashes", "/groups", "/settings"}
 	for _, route := range staticRoutes {
 		route := route // capture
+		if route == "/" && root != nil {
+			getAndHead(router, route, root)
+			continue
+		}
 		router.GET(route, func(c *gin.Context) {
 			path := strings.TrimPrefix(c.Request.URL.Path, "/")
 			if path == "" {
@@ -0,0 +1,128 @@
+package rest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/flakerimi/inceptor/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// Crash file scan states
+const (
+	fileScanIdle    = "idle"
+	fileScanRunning = "running"
+	fileScanDone    = "done"
+	fileScanFailed  = "failed"
+)
+
+// fileScanReport is the result of the most recent crash file scan
+type fileScanReport struct {
+	Status       string                          `json:"status"`
+	StartedAt    *time.Time                      `json:"started_at,omitempty"`
+	FinishedAt   *time.Time                      `json:"finished_at,omitempty"`
+	FilesScanned int                             `json:"files_scanned"`
+	CorruptFiles int                             `json:"corrupt_files"`
+	Apps         map[string][]storage.CorruptLog `json:"apps"`
+	Error        string                          `json:"error,omitempty"`
+}
+
+// fileScanner checks stored crash files in the background and keeps a
+// report of the ones that can't be decoded, grouped by app
+type fileScanner struct {
+	repo      storage.Repository
+	fileStore storage.FileStore
+
+	mu     sync.Mutex
+	report fileScanReport
+}
+
+func newFileScanner(repo storage.Repository, fileStore storage.FileStore) *fileScanner {
+	return &fileScanner{
+		repo:      repo,
+		fileStore: fileStore,
+		report:    fileScanReport{Status: fileScanIdle, Apps: map[string][]storage.CorruptLog{}},
+	}
+}
+
+// Start begins a scan in the background. It returns false if a scan is
+// already running.
+func (s *fileScanner) Start() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.report.Status == fileScanRunning {
+		return false
+	}
+
+	now := time.Now().UTC()
+	s.report = fileScanReport{
+		Status:    fileScanRunning,
+		StartedAt: &now,
+		Apps:      map[string][]storage.CorruptLog{},
+	}
+
+	go s.run()
+	return true
+}
+
+// Report returns a copy of the latest scan report
+func (s *fileScanner) Report() fileScanReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report := s.report
+	report.Apps = make(map[string][]storage.CorruptLog, len(s.report.Apps))
+	for appID, files := range s.report.Apps {
+		report.Apps[appID] = files
+	}
+	return report
+}
+
+func (s *fileScanner) run() {
+	ctx := context.Background()
+
+	err := s.scan(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	s.report.FinishedAt = &now
+	s.report.Status = fileScanDone
+	if err != nil {
+		log.Error().Err(err).Msg("Crash file scan failed")
+		s.report.Status = fileScanFailed
+		s.report.Error = "Scan failed; see server logs"
+	}
+	log.Info().
+		Int("files_scanned", s.report.FilesScanned).
+		Int("corrupt_files", s.report.CorruptFiles).
+		Msg("Crash file scan finished")
+}
+
+func (s *fileScanner) scan(ctx context.Context) error {
+	apps, err := s.repo.ListApps(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, app := range apps {
+		scanned, corrupt, err := s.fileStore.ScanCorruptLogs(ctx, app.ID)
+		if err != nil {
+			return err
+		}
+
+		// Publish progress per app so a running scan's report is useful
+		s.mu.Lock()
+		s.report.FilesScanned += scanned
+		s.report.CorruptFiles += len(corrupt)
+		if len(corrupt) > 0 {
+			s.report.Apps[app.ID] = corrupt
+		}
+		s.mu.Unlock()
+	}
+
+	return nil
+}
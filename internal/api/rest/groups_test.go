@@ -0,0 +1,500 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flakerimi/inceptor/internal/config"
+	"github.com/flakerimi/inceptor/internal/core"
+)
+
+func TestGroupTitleStrategy(t *testing.T) {
+	tests := []struct {
+		strategy string
+		want     string
+	}{
+		{core.TitleStrategyTypeMessage, "StateError: Bad state: no element"},
+		{core.TitleStrategyTypeCulprit, "StateError in App.main"},
+		{core.TitleStrategyMessage, "Bad state: no element"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			ts := newTestServer(t, func(cfg *config.Config) {
+				cfg.Grouping.TitleStrategy = tt.strategy
+			})
+			_, apiKey := ts.createApp(nil)
+			resp := ts.submitCrash(apiKey, testCrash(nil))
+
+			group := decode(t, ts.do(http.MethodGet, "/api/v1/groups/"+resp["group_id"].(string), apiKey, nil))
+			if group["title"] != tt.want {
+				t.Errorf("title = %v, want %q", group["title"], tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupActivityAndStaleFilter(t *testing.T) {
+	ts := newTestServer(t)
+	appID, apiKey := ts.createApp(nil)
+
+	// A group first seen ten days ago that last occurred three days ago
+	groupID := ts.addGroup(appID, "StateError", time.Now().UTC().AddDate(0, 0, -10))
+	ts.addGroup(appID, "StateError", time.Now().UTC().AddDate(0, 0, -3))
+
+	body := decode(t, ts.do(http.MethodGet, "/api/v1/groups/"+groupID, apiKey, nil))
+	if age, _ := body["age"].(float64); age < 10*24*3600 || age > 10*24*3600+60 {
+		t.Errorf("age = %v, want ten days in seconds", body["age"])
+	}
+	if since, _ := body["time_since_last_occurrence"].(float64); since < 3*24*3600 || since > 3*24*3600+60 {
+		t.Errorf("time_since_last_occurrence = %v, want three days in seconds", body["time_since_last_occurrence"])
+	}
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"stale_days=7", 1},
+		{"stale_days=30", 0},
+		{"", 1},
+	}
+	for _, tt := range tests {
+		groups := dataList(t, ts.do(http.MethodGet, "/api/v1/groups?"+tt.query, apiKey, nil))
+		if len(groups) != tt.want {
+			t.Errorf("groups?%s = %d groups, want %d", tt.query, len(groups), tt.want)
+		}
+		for _, g := range groups {
+			if _, ok := g.(map[string]interface{})["age"]; !ok {
+				t.Errorf("listed group has no age: %v", g)
+			}
+		}
+	}
+}
+
+func TestTrendingGroups(t *testing.T) {
+	ts := newTestServer(t)
+	appID, apiKey := ts.createApp(nil)
+
+	// Many occurrences a month ago, a few today and a couple yesterday
+	now := time.Now().UTC()
+	var old, hot, warm string
+	for i := 0; i < 20; i++ {
+		old = ts.addGroup(appID, "OldError", now.AddDate(0, 0, -30))
+	}
+	for i := 0; i < 3; i++ {
+		hot = ts.addGroup(appID, "HotError", now)
+	}
+	for i := 0; i < 2; i++ {
+		warm = ts.addGroup(appID, "WarmError", now.AddDate(0, 0, -1))
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"sort_by=trending", []string{hot, warm, old}},
+		{"sort_by=trending&sort_order=asc", []string{old, warm, hot}},
+		{"sort_by=occurrence_count", []string{old, hot, warm}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, g := range dataList(t, ts.do(http.MethodGet, "/api/v1/groups?"+tt.query, apiKey, nil)) {
+			got = append(got, g.(map[string]interface{})["id"].(string))
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("groups?%s = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	scores := map[string]float64{hot: 3, warm: 1, old: 0}
+	for id, want := range scores {
+		body := decode(t, ts.do(http.MethodGet, "/api/v1/groups/"+id, apiKey, nil))
+		if score, _ := body["trending_score"].(float64); score < want-0.01 || score > want+0.01 {
+			t.Errorf("group %s trending_score = %v, want %v", body["error_type"], body["trending_score"], want)
+		}
+	}
+}
+
+func TestAggregateOnlyGroups(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold int
+		update    map[string]interface{}
+	}{
+		{"set manually", 0, map[string]interface{}{"aggregate_only": true}},
+		{"ignored past threshold", 2, map[string]interface{}{"status": "ignored"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, func(cfg *config.Config) {
+				cfg.Ingest.AggregateThreshold = tt.threshold
+			})
+			appID, apiKey := ts.createApp(nil)
+			groupID := ts.submitCrash(apiKey, testCrash(nil))["group_id"].(string)
+			ts.submitCrash(apiKey, testCrash(nil))
+
+			if w := ts.do(http.MethodPatch, "/api/v1/groups/"+groupID, apiKey, tt.update); w.Code != http.StatusOK {
+				t.Fatalf("update group: status %d: %s", w.Code, w.Body.String())
+			}
+			for i := 0; i < 3; i++ {
+				resp := ts.submitCrash(apiKey, testCrash(nil))
+				if resp["aggregated"] != true || resp["group_id"] != groupID || resp["id"] != nil {
+					t.Fatalf("response = %v, want aggregated into %s without a crash", resp, groupID)
+				}
+			}
+
+			group := decode(t, ts.do(http.MethodGet, "/api/v1/groups/"+groupID, apiKey, nil))
+			if group["occurrence_count"] != float64(5) {
+				t.Errorf("occurrence_count = %v, want 5", group["occurrence_count"])
+			}
+			if crashes := dataList(t, ts.do(http.MethodGet, "/api/v1/crashes?app_id="+appID, apiKey, nil)); len(crashes) != 2 {
+				t.Errorf("crash rows = %d, want only the 2 stored before aggregating", len(crashes))
+			}
+			files := 0
+			filepath.WalkDir(ts.cfg.Storage.LogsPath, func(path string, d fs.DirEntry, err error) error {
+				if err == nil && !d.IsDir() {
+					files++
+				}
+				return nil
+			})
+			if files != 2 {
+				t.Errorf("crash files = %d, want only the 2 stored before aggregating", files)
+			}
+		})
+	}
+}
+
+func TestMergeGroup(t *testing.T) {
+	ts := newTestServer(t)
+	_, apiKey := ts.createApp(nil)
+	_, otherKey := ts.createApp(map[string]interface{}{"name": "Other App"})
+
+	submit := func(key, errorType string, n int) string {
+		var groupID string
+		for i := 0; i < n; i++ {
+			groupID = ts.submitCrash(key, testCrash(map[string]interface{}{"error_type": errorType}))["group_id"].(string)
+		}
+		return groupID
+	}
+	target := submit(apiKey, "StateError", 2)
+	source := submit(apiKey, "RangeError", 3)
+	otherApp := submit(otherKey, "StateError", 1)
+	w := ts.do(http.MethodPatch, "/api/v1/groups/"+target, apiKey, map[string]interface{}{"status": "resolved", "notes": "fixed in 2.0"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("update target: status %d: %s", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		name       string
+		source     string
+		body       map[string]interface{}
+		key        string
+		wantStatus int
+	}{
+		{"no target", source, map[string]interface{}{}, apiKey, http.StatusBadRequest},
+		{"unknown source", "missing", map[string]interface{}{"target_group_id": target}, apiKey, http.StatusNotFound},
+		{"unknown target", source, map[string]interface{}{"target_group_id": "missing"}, apiKey, http.StatusNotFound},
+		{"into itself", source, map[string]interface{}{"target_group_id": source}, apiKey, http.StatusBadRequest},
+		{"other app's key", source, map[string]interface{}{"target_group_id": target}, otherKey, http.StatusForbidden},
+		{"into another app's group", source, map[string]interface{}{"target_group_id": otherApp}, apiKey, http.StatusForbidden},
+		{"across apps as admin", source, map[string]interface{}{"target_group_id": otherApp}, testAdminKey, http.StatusBadRequest},
+		{"merged", source, map[string]interface{}{"target_group_id": target}, apiKey, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ts.do(http.MethodPost, "/api/v1/groups/"+tt.source+"/merge", tt.key, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+
+	// The target keeps its triage state and takes over the occurrences
+	group := decode(t, ts.do(http.MethodGet, "/api/v1/groups/"+target, apiKey, nil))
+	if group["occurrence_count"] != float64(5) || group["status"] != "resolved" || group["notes"] != "fixed in 2.0" {
+		t.Errorf("target = %v, want 5 occurrences, still resolved with its notes", group)
+	}
+	if w := ts.do(http.MethodGet, "/api/v1/groups/"+source, apiKey, nil); w.Code != http.StatusNotFound {
+		t.Errorf("merged group status = %d, want 404", w.Code)
+	}
+	if got := submit(apiKey, "RangeError", 1); got != target {
+		t.Errorf("later crash grouped into %s, want the target %s", got, target)
+	}
+}
+
+func TestSplitGroup(t *testing.T) {
+	ts := newTestServer(t)
+	_, apiKey := ts.createApp(nil)
+	_, otherKey := ts.createApp(map[string]interface{}{"name": "Other App"})
+
+	var crashIDs []string
+	var groupID string
+	for i := 0; i < 3; i++ {
+		resp := ts.submitCrash(apiKey, testCrash(nil))
+		crashIDs = append(crashIDs, resp["id"].(string))
+		groupID = resp["group_id"].(string)
+	}
+	otherGroup := ts.submitCrash(apiKey, testCrash(map[string]interface{}{"error_type": "RangeError"}))
+	original := decode(t, ts.do(http.MethodGet, "/api/v1/groups/"+groupID, apiKey, nil))
+
+	tests := []struct {
+		name       string
+		group      string
+		crashIDs   []string
+		key        string
+		wantStatus int
+	}{
+		{"no crashes", groupID, []string{}, apiKey, http.StatusBadRequest},
+		{"blank crash", groupID, []string{" "}, apiKey, http.StatusBadRequest},
+		{"unknown group", "missing", crashIDs[:1], apiKey, http.StatusNotFound},
+		{"other app's key", groupID, crashIDs[:1], otherKey, http.StatusForbidden},
+		{"crash not in group", groupID, []string{otherGroup["id"].(string)}, apiKey, http.StatusBadRequest},
+		{"whole group", groupID, crashIDs, apiKey, http.StatusBadRequest},
+		{"split", groupID, []string{crashIDs[1], crashIDs[2], crashIDs[1]}, apiKey, http.StatusCreated},
+	}
+	var split map[string]interface{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ts.do(http.MethodPost, "/api/v1/groups/"+tt.group+"/split", tt.key, map[string]interface{}{"crash_ids": tt.crashIDs})
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if w.Code == http.StatusCreated {
+				split = decode(t, w)
+			}
+		})
+	}
+	if split == nil {
+		t.Fatal("no split response")
+	}
+
+	group, _ := split["group"].(map[string]interface{})
+	newGroup, _ := split["new_group"].(map[string]interface{})
+	if group["id"] != groupID || group["occurrence_count"] != float64(1) {
+		t.Errorf("group = %v, want %s with 1 occurrence", group, groupID)
+	}
+	if newGroup["occurrence_count"] != float64(2) || newGroup["status"] != "open" || newGroup["title"] != original["title"] {
+		t.Errorf("new group = %v, want 2 open occurrences titled %v", newGroup, original["title"])
+	}
+	// The new group's fingerprint is the crashes' own, salted with the split key
+	if want := groupID + ":" + crashIDs[1]; newGroup["split_key"] != want {
+		t.Errorf("split key = %v, want %s", newGroup["split_key"], want)
+	}
+	if fingerprint, _ := newGroup["fingerprint"].(string); len(fingerprint) != 16 || fingerprint == original["fingerprint"] {
+		t.Errorf("new group fingerprint = %q, want 16 hex characters other than %v", fingerprint, original["fingerprint"])
+	}
+	if _, ok := original["split_key"]; ok {
+		t.Errorf("original group has split key %v", original["split_key"])
+	}
+	crash := decode(t, ts.do(http.MethodGet, "/api/v1/crashes/"+crashIDs[2], apiKey, nil))
+	if crash["group_id"] != newGroup["id"] {
+		t.Errorf("split crash in group %v, want %v", crash["group_id"], newGroup["id"])
+	}
+
+	// Later crashes keep joining the original group
+	if got := ts.submitCrash(apiKey, testCrash(nil))["group_id"]; got != groupID {
+		t.Errorf("later crash grouped into %v, want the original %s", got, groupID)
+	}
+}
+
+func TestUpdateGroupResolvesIncidents(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		resolves []string
+	)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&event)
+		if event["event_action"] == "resolve" {
+			mu.Lock()
+			resolves = append(resolves, event["dedup_key"].(string))
+			mu.Unlock()
+		}
+	}))
+	defer receiver.Close()
+
+	ts := newTestServer(t)
+	appID, apiKey := ts.createApp(nil)
+	groupID := ts.submitCrash(apiKey, testCrash(nil))["group_id"].(string)
+	ts.alerter.SetAlerts([]*core.Alert{{ID: "alert-1", AppID: appID, Type: "pagerduty", Enabled: true, Config: map[string]interface{}{
+		"routing_key": "key-1",
+		"events_url":  receiver.URL,
+	}}})
+
+	// Only a change to resolved closes the incident
+	updates := []map[string]interface{}{
+		{"status": "resolved"},
+		{"status": "resolved"},
+		{"notes": "fixed in 1.0.1"},
+		{"status": "open"},
+		{"status": "ignored"},
+		{"status": "resolved"},
+	}
+	for _, update := range updates {
+		if w := ts.do(http.MethodPatch, "/api/v1/groups/"+groupID, apiKey, update); w.Code != http.StatusOK {
+			t.Fatalf("update %v: status %d: %s", update, w.Code, w.Body.String())
+		}
+	}
+	if err := ts.alerter.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{core.PagerDutyDedupKey(groupID), core.PagerDutyDedupKey(groupID)}
+	if !slices.Equal(resolves, want) {
+		t.Errorf("resolve events = %v, want %v", resolves, want)
+	}
+}
+
+func TestCrashReopensResolvedGroup(t *testing.T) {
+	type delivery struct {
+		eventType      string
+		previousStatus string
+	}
+	var (
+		mu         sync.Mutex
+		deliveries []delivery
+	)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&event)
+		mu.Lock()
+		deliveries = append(deliveries, delivery{event["event_type"].(string), r.Header.Get("X-Inceptor-Previous-Status")})
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+
+	ts := newTestServer(t)
+	appID, apiKey := ts.createApp(nil)
+	groupID := ts.submitCrash(apiKey, testCrash(nil))["group_id"].(string)
+	ts.alerter.SetAlerts([]*core.Alert{{ID: "alert-1", AppID: appID, Type: "webhook", Enabled: true, Config: map[string]interface{}{
+		"url":        receiver.URL,
+		"conditions": map[string]interface{}{"on_regression": true},
+		"cooldown":   "0s",
+	}}})
+
+	tests := []struct {
+		name       string
+		status     string
+		wantStatus string
+		// wantRegressed is whether the group has been reopened
+		wantRegressed bool
+	}{
+		{"open", "", "open", false},
+		{"ignored", "ignored", "ignored", false},
+		{"resolved", "resolved", "open", true},
+		{"reopened", "", "open", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.status != "" {
+				if w := ts.do(http.MethodPatch, "/api/v1/groups/"+groupID, apiKey, map[string]interface{}{"status": tt.status}); w.Code != http.StatusOK {
+					t.Fatalf("update group: status %d: %s", w.Code, w.Body.String())
+				}
+			}
+			if got := ts.submitCrash(apiKey, testCrash(nil))["group_id"]; got != groupID {
+				t.Fatalf("crash grouped into %v, want %s", got, groupID)
+			}
+
+			group := decode(t, ts.do(http.MethodGet, "/api/v1/groups/"+groupID, testAdminKey, nil))
+			if group["status"] != tt.wantStatus {
+				t.Errorf("status = %v, want %s", group["status"], tt.wantStatus)
+			}
+			if _, regressed := group["regressed_at"]; regressed != tt.wantRegressed {
+				t.Errorf("regressed_at = %v", group["regressed_at"])
+			}
+		})
+	}
+	if err := ts.alerter.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []delivery{{"regression", "resolved"}}; !slices.Equal(deliveries, want) {
+		t.Errorf("alerts = %v, want %v", deliveries, want)
+	}
+}
+
+func TestResolveGroupByToken(t *testing.T) {
+	const secret = "link-secret"
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.Alerts.ActionLinks = config.ActionLinksConfig{Secret: secret, TTL: time.Hour}
+	})
+	_, apiKey := ts.createApp(nil)
+	groupID := ts.submitCrash(apiKey, testCrash(nil))["group_id"].(string)
+	links := core.NewActionLinks(secret, time.Hour)
+	token := links.Sign(core.ActionResolve, groupID, time.Now())
+
+	// resolve posts the confirmation form
+	resolve := func(ts *testServer, group, token string) *httptest.ResponseRecorder {
+		form := url.Values{"token": {token}}.Encode()
+		return ts.doRaw(http.MethodPost, "/api/v1/groups/"+group+"/resolve", "", "application/x-www-form-urlencoded", []byte(form))
+	}
+	status := func() interface{} {
+		return decode(t, ts.do(http.MethodGet, "/api/v1/groups/"+groupID, apiKey, nil))["status"]
+	}
+
+	// Opening the link, however often, only shows the confirmation form
+	for i := 0; i < 2; i++ {
+		w := ts.do(http.MethodGet, "/api/v1/groups/"+groupID+"/resolve?token="+url.QueryEscape(token), "", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("confirmation page: status %d: %s", w.Code, w.Body.String())
+		}
+		if body := w.Body.String(); !strings.Contains(body, `<form method="post">`) || !strings.Contains(body, `value="`+token+`"`) {
+			t.Errorf("confirmation page has no form posting the token: %s", body)
+		}
+	}
+	if got := status(); got != "open" {
+		t.Fatalf("status after opening the link = %v, want open", got)
+	}
+
+	tests := []struct {
+		name       string
+		group      string
+		token      string
+		wantStatus int
+	}{
+		{"no token", groupID, "", http.StatusForbidden},
+		{"other group's token", groupID, links.Sign(core.ActionResolve, "other", time.Now()), http.StatusForbidden},
+		{"other secret", groupID, core.NewActionLinks("other", time.Hour).Sign(core.ActionResolve, groupID, time.Now()), http.StatusForbidden},
+		{"expired", groupID, links.Sign(core.ActionResolve, groupID, time.Now().Add(-2*time.Hour)), http.StatusForbidden},
+		{"unknown group", "missing", links.Sign(core.ActionResolve, "missing", time.Now()), http.StatusNotFound},
+		{"valid", groupID, token, http.StatusOK},
+		{"used", groupID, token, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// No API key: the token is the authorization
+			w := resolve(ts, tt.group, tt.token)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+
+	if got := status(); got != string(core.GroupStatusResolved) {
+		t.Errorf("status = %v, want resolved", got)
+	}
+	// A used link no longer opens the confirmation form
+	if w := ts.do(http.MethodGet, "/api/v1/groups/"+groupID+"/resolve?token="+url.QueryEscape(token), "", nil); w.Code != http.StatusForbidden {
+		t.Errorf("used link status = %d, want 403", w.Code)
+	}
+
+	// Links are refused while disabled
+	disabled := newTestServer(t)
+	if w := resolve(disabled, groupID, links.Sign(core.ActionResolve, groupID, time.Now())); w.Code != http.StatusNotFound {
+		t.Errorf("disabled status = %d, want 404", w.Code)
+	}
+}
@@ -1,16 +1,31 @@
 package rest
 
 import (
+	"context"
 	"crypto/rand"
+	"encoding/csv"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/flakerimi/inceptor/internal/auth"
+	"github.com/flakerimi/inceptor/internal/config"
 	"github.com/flakerimi/inceptor/internal/core"
+	"github.com/flakerimi/inceptor/internal/dsym"
+	"github.com/flakerimi/inceptor/internal/ingest"
+	"github.com/flakerimi/inceptor/internal/proguard"
+	"github.com/flakerimi/inceptor/internal/sourcemap"
 	"github.com/flakerimi/inceptor/internal/storage"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
 )
 
 // Handler holds dependencies for REST handlers
@@ -19,27 +34,90 @@ type Handler struct {
 	fileStore storage.FileStore
 	grouper   *core.Grouper
 	alerter   *core.AlertManager
+	cfg       *config.Config
+	// pipeline is the ingest path shared with the gRPC server
+	pipeline *ingest.Pipeline
+	ingester *asyncIngester
+	// deadLetters is nil when dead-lettering is disabled
+	deadLetters *storage.DeadLetterQueue
+	fileScan    *fileScanner
+	replay      *core.ReplayGuard
+	// requestDurations times requests by method, route and status for the
+	// metrics endpoint
+	requestDurations *prometheus.HistogramVec
+	// sessions reports dashboard sessions on the metrics endpoint; nil
+	// leaves them out
+	sessions *auth.Manager
+	// metrics serves the metrics endpoint, once NewServer has set sessions
+	metrics http.Handler
+	// actionLinks is nil when one-click alert links are disabled
+	actionLinks *core.ActionLinks
+	// limiter is nil when crash submissions aren't rate limited
+	limiter *core.RateLimiter
+	// dsyms and symbolicator are nil when symbolication is disabled
+	dsyms        *dsym.Store
+	symbolicator *dsym.Symbolicator
+	// mappings and retracer are nil when deobfuscation is disabled
+	mappings *proguard.Store
+	retracer *proguard.Retracer
+	// sourceMaps and sourceMapResolver are nil when source maps are disabled
+	sourceMaps        *sourcemap.Store
+	sourceMapResolver *sourcemap.Resolver
 }
 
-// NewHandler creates a new Handler
-func NewHandler(repo storage.Repository, fileStore storage.FileStore, alerter *core.AlertManager) *Handler {
-	return &Handler{
-		repo:      repo,
-		fileStore: fileStore,
-		grouper:   core.NewGrouper(),
-		alerter:   alerter,
+// NewHandler creates a new Handler. Crashes go through pipeline, which the
+// gRPC server may share.
+func NewHandler(repo storage.Repository, fileStore storage.FileStore, alerter *core.AlertManager, pipeline *ingest.Pipeline, limiter *core.RateLimiter, cfg *config.Config) *Handler {
+	h := &Handler{
+		repo:        repo,
+		fileStore:   fileStore,
+		grouper:     pipeline.Grouper(),
+		alerter:     alerter,
+		cfg:         cfg,
+		pipeline:    pipeline,
+		deadLetters: pipeline.DeadLetters(),
+		fileScan:    newFileScanner(repo, fileStore),
+		replay:      core.NewReplayGuard(cfg.Alerts.Replay.Cooldown, cfg.Alerts.Replay.MaxPerHour),
+		limiter:     limiter,
+
+		requestDurations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "inceptor_http_request_duration_seconds",
+			Help:    "HTTP request latency.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+	}
+	h.dsyms, h.symbolicator = pipeline.Symbolication()
+	h.mappings, h.retracer = pipeline.Deobfuscation()
+	h.sourceMaps, h.sourceMapResolver = pipeline.SourceMaps()
+
+	if cfg.Alerts.ActionLinks.Secret != "" {
+		h.actionLinks = core.NewActionLinks(cfg.Alerts.ActionLinks.Secret, cfg.Alerts.ActionLinks.TTL)
+	}
+
+	if cfg.Ingest.Async.QueueSize > 0 {
+		h.ingester = newAsyncIngester(h.pipeline, cfg.Ingest.Async.QueueSize, cfg.Ingest.Async.Workers)
+	}
+
+	return h
+}
+
+// Close stops background work, finishing any queued crash ingestion
+func (h *Handler) Close() {
+	if h.ingester != nil {
+		h.ingester.Close()
 	}
 }
 
 // Root returns API info
 func (h *Handler) Root(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
-		"name":    "Inceptor",
-		"version": "1.0.0",
+		"name":        "Inceptor",
+		"version":     "1.0.0",
 		"description": "Self-hosted crash logging service for Flutter and mobile apps",
-		"docs":    "https://github.com/base-go/inceptor",
+		"docs":        "https://github.com/base-go/inceptor",
 		"endpoints": gin.H{
 			"health":  "GET /health",
+			"ready":   "GET /ready",
 			"crashes": "POST /api/v1/crashes",
 			"apps":    "GET /api/v1/apps (admin)",
 		},
@@ -51,6 +129,65 @@ func (h *Handler) Health(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "ok", "timestamp": time.Now().UTC()})
 }
 
+// readyCheckTimeout bounds each dependency check of Ready
+const readyCheckTimeout = 2 * time.Second
+
+// Ready checks the server's dependencies: that the database answers and
+// that crash payloads can be written. It returns 503, naming the failed
+// checks, when either is unhealthy; failure details are only logged, since
+// the endpoint is public. Unlike Health, which only shows the process is
+// up, it suits load balancers deciding where to send traffic.
+func (h *Handler) Ready(c *gin.Context) {
+	checks := gin.H{}
+	healthy := true
+	check := func(name string, fn func(ctx context.Context) error) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), readyCheckTimeout)
+		defer cancel()
+		if err := fn(ctx); err != nil {
+			log.Warn().Err(err).Str("check", name).Msg("Readiness check failed")
+			checks[name] = "error"
+			healthy = false
+			return
+		}
+		checks[name] = "ok"
+	}
+	check("database", h.repo.Ping)
+	check("file_store", h.fileStore.HealthCheck)
+
+	status, code := "ok", http.StatusOK
+	if !healthy {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+	response := gin.H{
+		"status":    status,
+		"checks":    checks,
+		"timestamp": time.Now().UTC(),
+	}
+	if h.alerter != nil {
+		response["alert_queue_depth"] = h.alerter.QueueDepth()
+	}
+	c.JSON(code, response)
+}
+
+// Ping confirms an API key is valid, so SDKs can check their configuration
+// at startup without submitting a crash
+func (h *Handler) Ping(c *gin.Context) {
+	response := gin.H{
+		"status":      "ok",
+		"server_time": time.Now().UTC(),
+	}
+	if app := GetApp(c); app != nil {
+		response["app"] = gin.H{
+			"id":   app.ID,
+			"name": app.Name,
+		}
+	}
+	if IsAdmin(c) {
+		response["admin"] = true
+	}
+	c.JSON(http.StatusOK, response)
+}
+
 // SubmitCrash handles crash report submission
 func (h *Handler) SubmitCrash(c *gin.Context) {
 	app := GetApp(c)
@@ -58,86 +195,248 @@ func (h *Handler) SubmitCrash(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid app context"})
 		return
 	}
+	if app.Disabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": "App is disabled", "code": CodeAppDisabled})
+		return
+	}
+	if h.limiter != nil {
+		if wait, ok := h.limiter.Allow(app, time.Now()); !ok {
+			RateLimitExceeded(c, CodeRateLimited, "Crash submission rate limit exceeded for this app", wait)
+			return
+		}
+	}
+
+	var submission core.CrashSubmission
+	if err := bindCrashSubmission(c, &submission, h.cfg.Ingest.PreserveNumbers); err != nil {
+		abortInvalidCrashBody(c, err)
+		return
+	}
+	if err := h.pipeline.Validate(&submission); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	crash, duplicate, err := h.pipeline.Accept(c.Request.Context(), app, &submission, net.ParseIP(c.ClientIP()))
+	if errors.Is(err, core.ErrMetadataTooLarge) || errors.Is(err, core.ErrMetadataTooDeep) {
+		code := CodeMetadataTooLarge
+		if errors.Is(err, core.ErrMetadataTooDeep) {
+			code = CodeMetadataTooDeep
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Metadata exceeds limits", "code": code, "details": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if duplicate != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"id":                  duplicate.ID,
+			"group_id":            duplicate.GroupID,
+			"fingerprint":         duplicate.Fingerprint,
+			"fingerprint_version": duplicate.FingerprintVersion,
+			"is_new_group":        false,
+			"duplicate":           true,
+		})
+		return
+	}
+
+	// Hand off to the background ingester when the client doesn't need the group result
+	if h.ingester != nil && h.wantsAsync(c, app) {
+		if !h.ingester.Enqueue(crash) {
+			RateLimitExceeded(c, CodeRateLimited, "Ingestion queue is full, retry later", time.Second)
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{
+			"id":                  crash.ID,
+			"fingerprint":         crash.Fingerprint,
+			"fingerprint_version": crash.FingerprintVersion,
+			"status":              "queued",
+		})
+		return
+	}
 
+	group, isNewGroup, err := h.pipeline.Store(c.Request.Context(), crash)
+	if errors.Is(err, ingest.ErrDeferred) {
+		c.JSON(http.StatusAccepted, gin.H{
+			"id":                  crash.ID,
+			"fingerprint":         crash.Fingerprint,
+			"fingerprint_version": crash.FingerprintVersion,
+			"status":              "deferred",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if group != nil && group.IsAggregating(h.cfg.Ingest.AggregateThreshold) {
+		// Only the group's count was updated; there is no crash to refer to
+		c.JSON(http.StatusOK, gin.H{
+			"group_id":            group.ID,
+			"fingerprint":         crash.Fingerprint,
+			"fingerprint_version": crash.FingerprintVersion,
+			"is_new_group":        false,
+			"aggregated":          true,
+		})
+		return
+	}
+
+	response := gin.H{
+		"id":                  crash.ID,
+		"group_id":            crash.GroupID,
+		"fingerprint":         crash.Fingerprint,
+		"fingerprint_version": crash.FingerprintVersion,
+		"is_new_group":        isNewGroup,
+	}
+	if crash.QuarantineReason != "" {
+		response["quarantined"] = true
+	}
+	if crash.Historical {
+		response["historical"] = true
+	}
+	if crash.MetadataTrimmed {
+		response["metadata_trimmed"] = true
+	}
+	if crash.Symbolicated != nil {
+		response["symbolicated"] = *crash.Symbolicated
+	}
+	if deobfuscated, ok := crash.Metadata[proguard.MetadataKey].(bool); ok {
+		response[proguard.MetadataKey] = deobfuscated
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// DebugFingerprint shows how a crash submission would be fingerprinted
+// without storing it. Pass app_id to account for that app's fingerprint
+// options, such as metadata keys, and churn fallback.
+func (h *Handler) DebugFingerprint(c *gin.Context) {
 	var submission core.CrashSubmission
-	if err := c.ShouldBindJSON(&submission); err != nil {
+	if err := bindCrashSubmission(c, &submission, h.cfg.Ingest.PreserveNumbers); err != nil {
+		abortInvalidCrashBody(c, err)
+		return
+	}
+	if err := h.pipeline.Validate(&submission); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
 		return
 	}
 
-	// Create crash object
 	crash := &core.Crash{
-		ID:           uuid.New().String(),
-		AppID:        app.ID,
-		AppVersion:   submission.AppVersion,
+		AppID:        c.Query("app_id"),
 		Platform:     submission.Platform,
-		OSVersion:    submission.OSVersion,
-		DeviceModel:  submission.DeviceModel,
 		ErrorType:    submission.ErrorType,
 		ErrorMessage: submission.ErrorMessage,
+		AppVersion:   submission.AppVersion,
 		StackTrace:   submission.StackTrace,
-		UserID:       submission.UserID,
-		Environment:  submission.Environment,
-		CreatedAt:    time.Now().UTC(),
 		Metadata:     submission.Metadata,
 		Breadcrumbs:  submission.Breadcrumbs,
+		DebugImages:  submission.DebugImages,
 	}
 
-	// Set default environment if not provided
-	if crash.Environment == "" {
-		crash.Environment = core.EnvironmentProduction
+	var app *core.App
+	if crash.AppID != "" {
+		var err error
+		app, err = h.repo.GetApp(c.Request.Context(), crash.AppID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve app"})
+			return
+		}
+		if app == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+			return
+		}
+		h.pipeline.ResolveFrames(crash, "app_id", crash.AppID)
 	}
+	h.pipeline.ApplyErrorTypeFallback(crash)
 
-	// Generate fingerprint
-	crash.Fingerprint = h.grouper.GenerateFingerprint(crash)
+	explanation := h.grouper.ExplainFingerprint(crash, app)
+	coarse := crash.AppID != "" && h.pipeline.CoarseFallback(crash.AppID)
+	fingerprint := explanation.Fingerprint
+	if coarse {
+		fingerprint = h.grouper.GenerateCoarseFingerprint(crash)
+	}
 
-	// Get or create group
-	crash.GroupID = uuid.New().String() // Pre-generate in case new group needed
-	group, isNewGroup, err := h.repo.GetOrCreateGroup(c.Request.Context(), crash)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process crash group"})
+	c.JSON(http.StatusOK, gin.H{
+		"fingerprint":     fingerprint,
+		"coarse_fallback": coarse,
+		"title":           h.grouper.GenerateTitle(crash),
+		"explanation":     explanation,
+	})
+}
+
+// ReprocessDeadLetters retries storing every dead-lettered crash. Crashes
+// that fail again stay in the queue with the new error.
+func (h *Handler) ReprocessDeadLetters(c *gin.Context) {
+	if h.deadLetters == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dead-letter queue is not enabled"})
 		return
 	}
-	crash.GroupID = group.ID
 
-	// Save full crash log to file
-	logPath, err := h.fileStore.SaveCrashLog(c.Request.Context(), crash)
+	letters, err := h.deadLetters.List()
 	if err != nil {
-		// Log error but continue - file storage is secondary
-		// log.Error().Err(err).Msg("Failed to save crash log file")
-	} else {
-		crash.LogFilePath = logPath
-	}
-
-	// Save crash to database
-	if err := h.repo.CreateCrash(c.Request.Context(), crash); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save crash"})
+		log.Error().Err(err).Msg("Failed to list dead letters")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read dead-letter queue"})
 		return
 	}
 
-	// Send alert
-	if h.alerter != nil {
-		eventType := core.AlertEventNewCrash
-		if isNewGroup {
-			eventType = core.AlertEventNewGroup
-		}
-		h.alerter.Notify(core.AlertEvent{
-			Type:       eventType,
-			AppID:      app.ID,
-			Crash:      crash,
-			Group:      group,
-			IsNewGroup: isNewGroup,
-		})
+	processed, failed := 0, 0
+	for _, letter := range letters {
+		crash := letter.Crash
+		if crash == nil {
+			continue
+		}
+		crash.GroupTitle = h.grouper.GenerateTitle(crash)
+		crash.GroupCulprit = core.Culprit(crash)
+
+		if _, _, err := h.pipeline.Store(c.Request.Context(), crash); err != nil {
+			failed++
+			continue
+		}
+		if err := h.deadLetters.Remove(letter.ID); err != nil {
+			log.Error().Err(err).Str("crash_id", crash.ID).Msg("Failed to remove dead letter")
+		}
+		processed++
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"id":           crash.ID,
-		"group_id":     crash.GroupID,
-		"fingerprint":  crash.Fingerprint,
-		"is_new_group": isNewGroup,
+	c.JSON(http.StatusOK, gin.H{
+		"processed": processed,
+		"failed":    failed,
 	})
 }
 
+// StartFileScan starts a background scan for stored crash files that can't
+// be decoded. Progress and results are read with GetFileScan.
+func (h *Handler) StartFileScan(c *gin.Context) {
+	if !h.fileScan.Start() {
+		c.JSON(http.StatusConflict, gin.H{"error": "A scan is already running"})
+		return
+	}
+	c.JSON(http.StatusAccepted, h.fileScan.Report())
+}
+
+// GetFileScan reports the latest crash file scan, listing corrupt files per app
+func (h *Handler) GetFileScan(c *gin.Context) {
+	c.JSON(http.StatusOK, h.fileScan.Report())
+}
+
+// wantsAsync reports whether a submission should be ingested in the
+// background, either because the client asked with "Prefer: respond-async"
+// or because the app is configured for async ingestion
+func (h *Handler) wantsAsync(c *gin.Context, app *core.App) bool {
+	for _, pref := range strings.Split(c.GetHeader("Prefer"), ",") {
+		if strings.EqualFold(strings.TrimSpace(pref), "respond-async") {
+			return true
+		}
+	}
+	for _, id := range h.cfg.Ingest.Async.AppIDs {
+		if id == app.ID {
+			return true
+		}
+	}
+	return false
+}
+
 // GetCrash retrieves a single crash
 func (h *Handler) GetCrash(c *gin.Context) {
 	id := c.Param("id")
@@ -161,17 +460,267 @@ func (h *Handler) GetCrash(c *gin.Context) {
 	}
 
 	// Load full crash data from file if available
-	if crash.LogFilePath != "" {
-		if fullCrash, err := h.fileStore.GetCrashLog(c.Request.Context(), crash.LogFilePath); err == nil && fullCrash != nil {
-			crash = fullCrash
+	crash = ingest.LoadCrashFile(c.Request.Context(), h.fileStore, crash)
+
+	c.JSON(http.StatusOK, crash)
+}
+
+// loadCrashFiles replaces crashes with their full payloads, read in parallel
+// from the crashes' files where available (see ingest.WithCrashFile)
+func (h *Handler) loadCrashFiles(ctx context.Context, crashes []*core.Crash) {
+	var paths []string
+	var withFiles []int
+	for i, crash := range crashes {
+		if crash.LogFilePath != "" {
+			paths = append(paths, crash.LogFilePath)
+			withFiles = append(withFiles, i)
+		}
+	}
+	if len(paths) == 0 {
+		return
+	}
+	fullCrashes, errs := h.fileStore.GetCrashLogs(ctx, paths)
+	for j, i := range withFiles {
+		crashes[i] = ingest.WithCrashFile(crashes[i], fullCrashes[j], errs[j])
+	}
+}
+
+// maxBatchCrashIDs caps how many crashes GetCrashBatch returns per request
+const maxBatchCrashIDs = 100
+
+// GetCrashBatch returns several crashes at once. IDs come from the ids query
+// parameter, comma-separated or repeated. Crashes that don't exist or belong
+// to another app are reported in "missing" rather than failing the request.
+func (h *Handler) GetCrashBatch(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var ids []string
+	seen := make(map[string]bool)
+	for _, param := range c.QueryArray("ids") {
+		for _, id := range strings.Split(param, ",") {
+			id = strings.TrimSpace(id)
+			if id == "" || seen[id] {
+				continue
+			}
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ids is required"})
+		return
+	}
+	if len(ids) > maxBatchCrashIDs {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Too many ids",
+			"details": "at most " + strconv.Itoa(maxBatchCrashIDs) + " crashes can be requested at once",
+		})
+		return
+	}
+
+	rows, err := h.repo.GetCrashesByIDs(ctx, ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve crashes"})
+		return
+	}
+
+	// Check access per crash
+	app := GetApp(c)
+	byID := make(map[string]*core.Crash, len(rows))
+	for _, crash := range rows {
+		if app != nil && crash.AppID != app.ID && !IsAdmin(c) {
+			continue
+		}
+		byID[crash.ID] = crash
+	}
+
+	crashes := make([]*core.Crash, 0, len(byID))
+	missing := make([]string, 0)
+	for _, id := range ids {
+		if crash, ok := byID[id]; ok {
+			crashes = append(crashes, crash)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+
+	h.loadCrashFiles(ctx, crashes)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    crashes,
+		"missing": missing,
+	})
+}
+
+// ListQuarantinedCrashes lists crashes held back by ingest heuristics
+func (h *Handler) ListQuarantinedCrashes(c *gin.Context) {
+	filter := storage.CrashFilter{
+		AppID:       c.Query("app_id"),
+		Quarantined: true,
+		Limit:       parseIntQuery(c, "limit", 50),
+		Offset:      parseIntQuery(c, "offset", 0),
+	}
+
+	crashes, total, err := h.repo.ListCrashes(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list crashes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":   crashes,
+		"total":  total,
+		"limit":  filter.Limit,
+		"offset": filter.Offset,
+	})
+}
+
+// ReleaseCrash moves a quarantined crash into normal processing: it is
+// grouped and alerted on as if it had just been ingested
+func (h *Handler) ReleaseCrash(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+
+	crash, err := h.repo.GetCrash(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve crash"})
+		return
+	}
+	if crash == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Crash not found"})
+		return
+	}
+	if crash.QuarantineReason == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Crash is not quarantined"})
+		return
+	}
+
+	// The stack trace is only kept in the payload file; titles need it
+	crash = ingest.LoadCrashFile(ctx, h.fileStore, crash)
+	crash.QuarantineReason = ""
+	crash.Released = true
+	crash.GroupTitle = h.grouper.GenerateTitle(crash)
+	crash.GroupCulprit = core.Culprit(crash)
+
+	group, isNewGroup, err := h.pipeline.Store(ctx, crash)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release crash"})
+		return
+	}
+	if group == nil {
+		// The app is still at its group cap
+		c.JSON(http.StatusConflict, gin.H{"error": "Crash was quarantined again: " + crash.QuarantineReason})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":           crash.ID,
+		"group_id":     group.ID,
+		"is_new_group": isNewGroup,
+	})
+}
+
+// ReplayAlerts re-sends alerts for a stored crash through the current alert
+// configuration, e.g. after fixing an alert that missed it
+func (h *Handler) ReplayAlerts(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+
+	crash, err := h.repo.GetCrash(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve crash"})
+		return
+	}
+	if crash == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Crash not found"})
+		return
+	}
+	if crash.QuarantineReason != "" || crash.GroupID == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Quarantined crashes raise no alerts; release the crash first"})
+		return
+	}
+
+	group, err := h.repo.GetGroup(ctx, crash.GroupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve crash group"})
+		return
+	}
+	if group == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Crash group not found"})
+		return
+	}
+
+	if wait, perCrash, ok := h.replay.Allow(crash.ID, time.Now()); !ok {
+		message := "Too many alert replays, retry later"
+		if perCrash {
+			message = "Alerts for this crash were replayed recently"
 		}
+		RateLimitExceeded(c, CodeRateLimited, message, wait)
+		return
+	}
+
+	// The crash opened its group if nothing was seen before it
+	isNewGroup := !crash.CreatedAt.After(group.FirstSeen)
+	eventType := core.AlertEventNewCrash
+	if isNewGroup {
+		eventType = core.AlertEventNewGroup
 	}
 
+	results := h.alerter.Replay(core.AlertEvent{
+		Type:       eventType,
+		AppID:      crash.AppID,
+		Crash:      crash,
+		Group:      group,
+		IsNewGroup: isNewGroup,
+	})
+
+	log.Info().Str("crash_id", crash.ID).Int("alerts", len(results)).Msg("Replayed crash alerts")
+	c.JSON(http.StatusOK, gin.H{
+		"crash_id":     crash.ID,
+		"event_type":   eventType,
+		"is_new_group": isNewGroup,
+		"alerts":       results,
+	})
+}
+
+// GetCrashByEventID retrieves a crash by the client-generated event ID
+func (h *Handler) GetCrashByEventID(c *gin.Context) {
+	eventID := c.Param("eventID")
+
+	appID := c.Query("app_id")
+	if app := GetApp(c); app != nil {
+		if appID != "" && appID != app.ID && !IsAdmin(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+		appID = app.ID
+	}
+	if appID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "app_id is required"})
+		return
+	}
+
+	crash, err := h.repo.GetCrashByEventID(c.Request.Context(), appID, eventID, time.Time{})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve crash"})
+		return
+	}
+
+	if crash == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Crash not found"})
+		return
+	}
+
+	// Load full crash data from file if available
+	crash = ingest.LoadCrashFile(c.Request.Context(), h.fileStore, crash)
+
 	c.JSON(http.StatusOK, crash)
 }
 
-// ListCrashes lists crashes with filters
-func (h *Handler) ListCrashes(c *gin.Context) {
+// crashFilter reads the crash list filters from the query. An app-scoped
+// caller only sees its own app's crashes.
+func crashFilter(c *gin.Context) storage.CrashFilter {
 	filter := storage.CrashFilter{
 		AppID:       c.Query("app_id"),
 		GroupID:     c.Query("group_id"),
@@ -179,9 +728,12 @@ func (h *Handler) ListCrashes(c *gin.Context) {
 		Environment: c.Query("environment"),
 		ErrorType:   c.Query("error_type"),
 		UserID:      c.Query("user_id"),
+		Country:     c.Query("country"),
 		Search:      c.Query("search"),
+		HasMetadata: c.QueryArray("has_metadata"),
 		Limit:       parseIntQuery(c, "limit", 50),
 		Offset:      parseIntQuery(c, "offset", 0),
+		Cursor:      c.Query("cursor"),
 	}
 
 	// Non-admin users can only see their own app's crashes
@@ -201,19 +753,162 @@ func (h *Handler) ListCrashes(c *gin.Context) {
 			filter.ToDate = &t
 		}
 	}
+	return filter
+}
+
+// ListCrashes lists crashes with filters
+func (h *Handler) ListCrashes(c *gin.Context) {
+	filter := crashFilter(c)
 
+	// Fetch one extra row to tell whether there is a next page
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	filter.Limit = limit + 1
 	crashes, total, err := h.repo.ListCrashes(c.Request.Context(), filter)
+	if errors.Is(err, storage.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list crashes"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"data":   crashes,
+	response := gin.H{
 		"total":  total,
-		"limit":  filter.Limit,
+		"limit":  limit,
 		"offset": filter.Offset,
-	})
+	}
+	if len(crashes) > limit {
+		crashes = crashes[:limit]
+		last := crashes[limit-1]
+		response["next_cursor"] = storage.EncodeCursor(last.CreatedAt, last.ID)
+	}
+	response["data"] = crashes
+	c.JSON(http.StatusOK, response)
+}
+
+// Crash export formats
+const (
+	crashExportCSV    = "csv"
+	crashExportNDJSON = "ndjson"
+)
+
+// crashExportColumns are the columns of a crash CSV export
+var crashExportColumns = []string{
+	"id", "app_id", "app_version", "platform", "os_version", "device_model",
+	"error_type", "error_message", "environment", "created_at",
+}
+
+// ExportCrashes reads crashes a page at a time; NDJSON pages are smaller,
+// since each crash's full payload is held until it's written
+const (
+	crashExportPage        = 500
+	crashExportPayloadPage = 100
+)
+
+// ExportCrashes streams every crash matching the ListCrashes filters,
+// newest first, as CSV of the indexed columns or as NDJSON of the full
+// payloads, one crash per line. Crashes are read a page at a time and
+// written as they come, so large exports aren't held in memory. limit,
+// offset and cursor are ignored.
+func (h *Handler) ExportCrashes(c *gin.Context) {
+	format := c.DefaultQuery("format", crashExportCSV)
+	pageSize := crashExportPage
+	switch format {
+	case crashExportCSV:
+	case crashExportNDJSON:
+		pageSize = crashExportPayloadPage
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format", "details": "format must be csv or ndjson"})
+		return
+	}
+
+	filter := crashFilter(c)
+	filter.Offset = 0
+	filter.Cursor = ""
+	filter.Limit = pageSize
+
+	// Read the first page before writing anything, so a failing query still
+	// gets an error response
+	ctx := c.Request.Context()
+	crashes, _, err := h.repo.ListCrashes(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list crashes"})
+		return
+	}
+
+	var write func(crashes []*core.Crash) error
+	filename := "crashes-" + time.Now().UTC().Format("20060102-150405") + "." + format
+	switch format {
+	case crashExportCSV:
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		w := csv.NewWriter(c.Writer)
+		w.Write(crashExportColumns)
+		write = func(crashes []*core.Crash) error {
+			for _, crash := range crashes {
+				w.Write([]string{
+					crash.ID,
+					crash.AppID,
+					crash.AppVersion,
+					crash.Platform,
+					crash.OSVersion,
+					crash.DeviceModel,
+					crash.ErrorType,
+					crash.ErrorMessage,
+					crash.Environment,
+					crash.CreatedAt.UTC().Format(time.RFC3339),
+				})
+			}
+			w.Flush()
+			return w.Error()
+		}
+	case crashExportNDJSON:
+		c.Header("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(c.Writer)
+		write = func(crashes []*core.Crash) error {
+			h.loadCrashFiles(ctx, crashes)
+			for _, crash := range crashes {
+				// Encode ends each crash with a newline
+				if err := enc.Encode(crash); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	rows := 0
+	for {
+		// Page from the indexed rows, before write swaps in full payloads
+		var next string
+		if len(crashes) == pageSize {
+			last := crashes[len(crashes)-1]
+			next = storage.EncodeCursor(last.CreatedAt, last.ID)
+		}
+		if err := write(crashes); err != nil {
+			// The client went away
+			log.Debug().Err(err).Int("rows", rows).Msg("Crash export aborted")
+			return
+		}
+		rows += len(crashes)
+		c.Writer.Flush()
+		if next == "" {
+			break
+		}
+
+		filter.Cursor = next
+		if crashes, _, err = h.repo.ListCrashes(ctx, filter); err != nil {
+			// The status is already sent, so the export just ends short
+			log.Error().Err(err).Int("rows", rows).Msg("Failed to list crashes for export")
+			return
+		}
+	}
+	log.Info().Str("app_id", filter.AppID).Str("format", format).Int("rows", rows).Msg("Crashes exported")
 }
 
 // DeleteCrash deletes a crash
@@ -275,6 +970,11 @@ func (h *Handler) GetGroup(c *gin.Context) {
 		return
 	}
 
+	if err := h.repo.SummarizeGroupCrashes(c.Request.Context(), []*core.CrashGroup{group}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve group"})
+		return
+	}
+	group.ComputeActivity(time.Now())
 	c.JSON(http.StatusOK, group)
 }
 
@@ -285,10 +985,12 @@ func (h *Handler) ListGroups(c *gin.Context) {
 		Status:    c.Query("status"),
 		ErrorType: c.Query("error_type"),
 		Search:    c.Query("search"),
+		StaleDays: parseIntQuery(c, "stale_days", 0),
 		SortBy:    c.DefaultQuery("sort_by", "last_seen"),
 		SortOrder: c.DefaultQuery("sort_order", "desc"),
 		Limit:     parseIntQuery(c, "limit", 50),
 		Offset:    parseIntQuery(c, "offset", 0),
+		Cursor:    c.Query("cursor"),
 	}
 
 	// Non-admin users can only see their own app's groups
@@ -297,18 +999,49 @@ func (h *Handler) ListGroups(c *gin.Context) {
 		filter.AppID = app.ID
 	}
 
+	// Fetch one extra row to tell whether there is a next page
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	filter.Limit = limit + 1
 	groups, total, err := h.repo.ListGroups(c.Request.Context(), filter)
+	if errors.Is(err, storage.ErrInvalidCursor) || errors.Is(err, storage.ErrCursorSort) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor", "details": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list groups"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"data":   groups,
+	response := gin.H{
 		"total":  total,
-		"limit":  filter.Limit,
+		"limit":  limit,
 		"offset": filter.Offset,
-	})
+	}
+	if len(groups) > limit {
+		groups = groups[:limit]
+		// Cursors are only defined for time sorts
+		last := groups[limit-1]
+		switch filter.SortBy {
+		case "last_seen":
+			response["next_cursor"] = storage.EncodeCursor(last.LastSeen, last.ID)
+		case "first_seen":
+			response["next_cursor"] = storage.EncodeCursor(last.FirstSeen, last.ID)
+		}
+	}
+
+	if err := h.repo.SummarizeGroupCrashes(c.Request.Context(), groups); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list groups"})
+		return
+	}
+	now := time.Now()
+	for _, group := range groups {
+		group.ComputeActivity(now)
+	}
+	response["data"] = groups
+	c.JSON(http.StatusOK, response)
 }
 
 // UpdateGroup updates a crash group
@@ -320,53 +1053,250 @@ func (h *Handler) UpdateGroup(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve group"})
 		return
 	}
-
-	if group == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+
+	if group == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return
+	}
+
+	// Check access
+	app := GetApp(c)
+	if app != nil && group.AppID != app.ID && !IsAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	var update struct {
+		Status        *string `json:"status"`
+		AssignedTo    *string `json:"assigned_to"`
+		Notes         *string `json:"notes"`
+		AggregateOnly *bool   `json:"aggregate_only"`
+	}
+
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	wasResolved := group.Status == string(core.GroupStatusResolved)
+	if update.Status != nil {
+		group.Status = *update.Status
+	}
+	if update.AssignedTo != nil {
+		group.AssignedTo = *update.AssignedTo
+	}
+	if update.Notes != nil {
+		group.Notes = *update.Notes
+	}
+	if update.AggregateOnly != nil {
+		group.AggregateOnly = *update.AggregateOnly
+	}
+
+	if err := h.repo.UpdateGroup(c.Request.Context(), group); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update group"})
+		return
+	}
+
+	if !wasResolved && group.Status == string(core.GroupStatusResolved) {
+		h.notifyResolved(group)
+	}
+
+	group.ComputeActivity(time.Now())
+	c.JSON(http.StatusOK, group)
+}
+
+// notifyResolved tells the alerter a group was resolved, to close the
+// incidents its crashes opened
+func (h *Handler) notifyResolved(group *core.CrashGroup) {
+	if h.alerter == nil {
+		return
+	}
+	h.alerter.Notify(core.AlertEvent{
+		Type:  core.AlertEventResolved,
+		AppID: group.AppID,
+		Group: group,
+	})
+}
+
+// MergeGroup merges a group into the target group given in the body. The
+// target keeps its status, assignee and notes and takes over the source's
+// crashes and occurrences; the source group is deleted.
+func (h *Handler) MergeGroup(c *gin.Context) {
+	var req struct {
+		TargetGroupID string `json:"target_group_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	app := GetApp(c)
+	for _, id := range []string{c.Param("id"), req.TargetGroupID} {
+		group, err := h.repo.GetGroup(ctx, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve group"})
+			return
+		}
+		if group == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group not found", "details": id})
+			return
+		}
+		if app != nil && group.AppID != app.ID && !IsAdmin(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+	}
+
+	target, err := h.repo.MergeGroups(ctx, c.Param("id"), req.TargetGroupID)
+	switch {
+	case errors.Is(err, storage.ErrGroupNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return
+	case errors.Is(err, storage.ErrMergeSameGroup), errors.Is(err, storage.ErrMergeDifferentApps):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merge", "details": err.Error()})
+		return
+	case err != nil:
+		log.Error().Err(err).Str("group_id", c.Param("id")).Msg("Failed to merge groups")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge groups"})
+		return
+	}
+
+	log.Info().Str("group_id", c.Param("id")).Str("target_group_id", target.ID).Msg("Groups merged")
+	target.ComputeActivity(time.Now())
+	c.JSON(http.StatusOK, target)
+}
+
+// maxSplitCrashIDs caps how many crashes SplitGroup moves per request
+const maxSplitCrashIDs = 500
+
+// SplitGroup moves the crashes given in the body out of a group into a new
+// group, for when one group holds two distinct bugs. The new group gets a
+// fingerprint of its own, so later crashes keep joining the original group.
+func (h *Handler) SplitGroup(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+
+	group, err := h.repo.GetGroup(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve group"})
+		return
+	}
+	if group == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return
+	}
+
+	// Check access
+	app := GetApp(c)
+	if app != nil && group.AppID != app.ID && !IsAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	var req struct {
+		CrashIDs []string `json:"crash_ids" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	var ids []string
+	seen := make(map[string]bool)
+	for _, crashID := range req.CrashIDs {
+		crashID = strings.TrimSpace(crashID)
+		if crashID == "" || seen[crashID] {
+			continue
+		}
+		seen[crashID] = true
+		ids = append(ids, crashID)
+	}
+	if len(ids) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "crash_ids is required"})
 		return
 	}
-
-	// Check access
-	app := GetApp(c)
-	if app != nil && group.AppID != app.ID && !IsAdmin(c) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+	if len(ids) > maxSplitCrashIDs {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Too many crash_ids",
+			"details": "at most " + strconv.Itoa(maxSplitCrashIDs) + " crashes can be split off at once",
+		})
 		return
 	}
 
-	var update struct {
-		Status     *string `json:"status"`
-		AssignedTo *string `json:"assigned_to"`
-		Notes      *string `json:"notes"`
+	// The new group is described by the first crash split off; the stack
+	// trace is only kept in the payload file and titles need it
+	crash, err := h.repo.GetCrash(ctx, ids[0])
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve crash"})
+		return
 	}
-
-	if err := c.ShouldBindJSON(&update); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+	if crash == nil || crash.GroupID != group.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid split", "details": storage.ErrCrashNotInGroup.Error() + ": " + ids[0]})
 		return
 	}
-
-	if update.Status != nil {
-		group.Status = *update.Status
+	titleSource := crash
+	if crash.LogFilePath != "" {
+		if fullCrash, err := h.fileStore.GetCrashLog(ctx, crash.LogFilePath); err == nil && fullCrash != nil {
+			titleSource = fullCrash
+		}
 	}
-	if update.AssignedTo != nil {
-		group.AssignedTo = *update.AssignedTo
+
+	// The fingerprint is the first crash's, salted with where the split
+	// came from, so later crashes keep landing in the original group
+	groupApp, err := h.repo.GetApp(ctx, group.AppID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve app"})
+		return
 	}
-	if update.Notes != nil {
-		group.Notes = *update.Notes
+	splitKey := group.ID + ":" + crash.ID
+	newGroup := &core.CrashGroup{
+		ID:           uuid.New().String(),
+		Fingerprint:  h.grouper.SplitFingerprint(titleSource, groupApp, splitKey),
+		Title:        h.grouper.GenerateTitle(titleSource),
+		Culprit:      core.Culprit(titleSource),
+		ErrorType:    crash.ErrorType,
+		ErrorMessage: crash.ErrorMessage,
+		Status:       string(core.GroupStatusOpen),
+		SplitKey:     splitKey,
 	}
 
-	if err := h.repo.UpdateGroup(c.Request.Context(), group); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update group"})
+	group, newGroup, err = h.repo.SplitGroup(ctx, group.ID, ids, newGroup)
+	switch {
+	case errors.Is(err, storage.ErrGroupNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return
+	case errors.Is(err, storage.ErrCrashNotInGroup), errors.Is(err, storage.ErrSplitWholeGroup):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid split", "details": err.Error()})
+		return
+	case err != nil:
+		log.Error().Err(err).Str("group_id", id).Msg("Failed to split group")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to split group"})
 		return
 	}
 
-	c.JSON(http.StatusOK, group)
+	log.Info().Str("group_id", group.ID).Str("new_group_id", newGroup.ID).Int("crashes", len(ids)).Msg("Group split")
+	now := time.Now()
+	group.ComputeActivity(now)
+	newGroup.ComputeActivity(now)
+	c.JSON(http.StatusCreated, gin.H{
+		"group":     group,
+		"new_group": newGroup,
+	})
 }
 
 // CreateApp creates a new app
 func (h *Handler) CreateApp(c *gin.Context) {
 	var req struct {
-		Name          string `json:"name" binding:"required"`
-		RetentionDays int    `json:"retention_days"`
+		Name                    string                `json:"name" binding:"required"`
+		RetentionDays           int                   `json:"retention_days"`
+		IncludeFrameworkFrames  bool                  `json:"include_framework_frames"`
+		FingerprintMetadataKeys []string              `json:"fingerprint_metadata_keys"`
+		MaxGroups               int                   `json:"max_groups" binding:"min=0"`
+		AssignmentRules         []core.AssignmentRule `json:"assignment_rules"`
+		RateLimit               core.RateLimit        `json:"rate_limit"`
+		FingerprintConfig       core.GrouperConfig    `json:"fingerprint_config"`
+		APIKeyScopes            []string              `json:"api_key_scopes"`
+		APIKeyExpiresAt         *time.Time            `json:"api_key_expires_at"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -374,16 +1304,44 @@ func (h *Handler) CreateApp(c *gin.Context) {
 		return
 	}
 
+	rules, err := normalizeAssignmentRules(req.AssignmentRules)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid assignment rules", "details": err.Error()})
+		return
+	}
+	if err := req.RateLimit.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rate limit", "details": err.Error()})
+		return
+	}
+	if err := req.FingerprintConfig.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid fingerprint config", "details": err.Error()})
+		return
+	}
+	scopes, err := core.NormalizeScopes(req.APIKeyScopes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key scopes", "details": err.Error()})
+		return
+	}
+
 	// Generate API key
 	apiKey := generateSecureAPIKey()
 
 	app := &core.App{
-		ID:            uuid.New().String(),
-		Name:          req.Name,
-		APIKey:        apiKey, // Return to user only once
-		APIKeyHash:    HashAPIKey(apiKey),
-		CreatedAt:     time.Now().UTC(),
-		RetentionDays: req.RetentionDays,
+		ID:                      uuid.New().String(),
+		Name:                    req.Name,
+		APIKey:                  apiKey, // Return to user only once
+		APIKeyID:                uuid.New().String(),
+		APIKeyHash:              HashAPIKey(apiKey),
+		CreatedAt:               time.Now().UTC(),
+		RetentionDays:           req.RetentionDays,
+		IncludeFrameworkFrames:  req.IncludeFrameworkFrames,
+		FingerprintMetadataKeys: normalizeMetadataKeys(req.FingerprintMetadataKeys),
+		MaxGroups:               req.MaxGroups,
+		AssignmentRules:         rules,
+		RateLimit:               req.RateLimit,
+		FingerprintConfig:       req.FingerprintConfig,
+		APIKeyScopes:            scopes,
+		APIKeyExpiresAt:         utcTime(req.APIKeyExpiresAt),
 	}
 
 	if app.RetentionDays <= 0 {
@@ -395,13 +1353,135 @@ func (h *Handler) CreateApp(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"id":             app.ID,
-		"name":           app.Name,
-		"api_key":        apiKey, // Only returned on creation
-		"created_at":     app.CreatedAt,
-		"retention_days": app.RetentionDays,
+	resp := appResponse(app)
+	resp["api_key"] = apiKey // Only returned on creation
+	resp["key"] = apiKeyResponse(&core.APIKey{
+		ID:        app.APIKeyID,
+		Label:     "default",
+		Scopes:    app.APIKeyScopes,
+		ExpiresAt: app.APIKeyExpiresAt,
+		CreatedAt: app.CreatedAt,
 	})
+	c.JSON(http.StatusCreated, resp)
+}
+
+// maxBulkApps caps how many apps CreateAppsBulk creates per request
+const maxBulkApps = 100
+
+// CreateAppsBulk creates several apps in one transaction, e.g. when
+// onboarding a customer. The body is an array of {name, retention_days};
+// each app is returned with its API key, which is not shown again.
+func (h *Handler) CreateAppsBulk(c *gin.Context) {
+	var req []struct {
+		Name          string `json:"name"`
+		RetentionDays int    `json:"retention_days"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		details := err.Error()
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field == "" {
+			details = "body must be an array of apps"
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": details})
+		return
+	}
+	if len(req) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": "at least one app is required"})
+		return
+	}
+	if len(req) > maxBulkApps {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Too many apps",
+			"details": "at most " + strconv.Itoa(maxBulkApps) + " apps can be created at once",
+		})
+		return
+	}
+
+	now := time.Now().UTC()
+	apps := make([]*core.App, len(req))
+	seen := make(map[string]int, len(req))
+	for i, item := range req {
+		name := strings.TrimSpace(item.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": fmt.Sprintf("app %d: name is required", i)})
+			return
+		}
+		key := strings.ToLower(name)
+		if first, dup := seen[key]; dup {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Duplicate app name",
+				"details": fmt.Sprintf("apps %d and %d are both named %q", first, i, name),
+			})
+			return
+		}
+		seen[key] = i
+
+		apiKey := generateSecureAPIKey()
+		apps[i] = &core.App{
+			ID:            uuid.New().String(),
+			Name:          name,
+			APIKey:        apiKey,
+			APIKeyID:      uuid.New().String(),
+			APIKeyHash:    HashAPIKey(apiKey),
+			CreatedAt:     now,
+			RetentionDays: item.RetentionDays,
+		}
+		if apps[i].RetentionDays <= 0 {
+			apps[i].RetentionDays = 30
+		}
+	}
+
+	if err := h.repo.CreateApps(c.Request.Context(), apps); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create apps"})
+		return
+	}
+
+	result := make([]gin.H, len(apps))
+	for i, app := range apps {
+		result[i] = appResponse(app)
+		result[i]["api_key"] = app.APIKey // Only returned on creation
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": result})
+}
+
+// appResponse is the public view of an app, without its API key hash
+func appResponse(app *core.App) gin.H {
+	metadataKeys := app.FingerprintMetadataKeys
+	if metadataKeys == nil {
+		metadataKeys = []string{}
+	}
+	assignmentRules := app.AssignmentRules
+	if assignmentRules == nil {
+		assignmentRules = []core.AssignmentRule{}
+	}
+	maintenanceWindows := app.MaintenanceWindows
+	if maintenanceWindows == nil {
+		maintenanceWindows = []core.MaintenanceWindow{}
+	}
+	return gin.H{
+		"id":                        app.ID,
+		"name":                      app.Name,
+		"created_at":                app.CreatedAt,
+		"retention_days":            app.RetentionDays,
+		"include_framework_frames":  app.IncludeFrameworkFrames,
+		"fingerprint_metadata_keys": metadataKeys,
+		"max_groups":                app.MaxGroups,
+		"assignment_rules":          assignmentRules,
+		"disabled":                  app.Disabled,
+		"maintenance_windows":       maintenanceWindows,
+		"rate_limit":                app.RateLimit,
+		"fingerprint_config":        app.FingerprintConfig,
+		"last_used_at":              app.LastUsedAt,
+	}
+}
+
+// utcTime returns t in UTC, or nil if t is nil
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
 }
 
 // GetApp retrieves app info
@@ -419,16 +1499,43 @@ func (h *Handler) GetApp(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"id":             app.ID,
-		"name":           app.Name,
-		"created_at":     app.CreatedAt,
-		"retention_days": app.RetentionDays,
-	})
+	c.JSON(http.StatusOK, appResponse(app))
+}
+
+// normalizeMetadataKeys trims fingerprint metadata keys and drops blanks and
+// duplicates, keeping the given order
+func normalizeMetadataKeys(keys []string) []string {
+	seen := make(map[string]bool, len(keys))
+	normalized := make([]string, 0, len(keys))
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		normalized = append(normalized, key)
+	}
+	return normalized
+}
+
+// normalizeAssignmentRules trims assignment rules and validates them,
+// keeping their order since the first matching rule wins
+func normalizeAssignmentRules(rules []core.AssignmentRule) ([]core.AssignmentRule, error) {
+	normalized := make([]core.AssignmentRule, 0, len(rules))
+	for i, rule := range rules {
+		rule.Match = strings.TrimSpace(rule.Match)
+		rule.Value = strings.TrimSpace(rule.Value)
+		rule.Assignee = strings.TrimSpace(rule.Assignee)
+		if err := rule.Validate(); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+		normalized = append(normalized, rule)
+	}
+	return normalized, nil
 }
 
-// RegenerateAppKey generates a new API key for an app
-func (h *Handler) RegenerateAppKey(c *gin.Context) {
+// UpdateApp updates an app's settings
+func (h *Handler) UpdateApp(c *gin.Context) {
 	id := c.Param("id")
 
 	app, err := h.repo.GetApp(c.Request.Context(), id)
@@ -442,21 +1549,143 @@ func (h *Handler) RegenerateAppKey(c *gin.Context) {
 		return
 	}
 
-	// Generate new API key
-	newAPIKey := generateSecureAPIKey()
-	newKeyHash := HashAPIKey(newAPIKey)
+	var update struct {
+		Name                    *string                `json:"name"`
+		RetentionDays           *int                   `json:"retention_days"`
+		IncludeFrameworkFrames  *bool                  `json:"include_framework_frames"`
+		FingerprintMetadataKeys *[]string              `json:"fingerprint_metadata_keys"`
+		MaxGroups               *int                   `json:"max_groups" binding:"omitempty,min=0"`
+		AssignmentRules         *[]core.AssignmentRule `json:"assignment_rules"`
+		Disabled                *bool                  `json:"disabled"`
+		RateLimit               *core.RateLimit        `json:"rate_limit"`
+		FingerprintConfig       *core.GrouperConfig    `json:"fingerprint_config"`
+	}
 
-	// Update app with new key hash
-	if err := h.repo.UpdateAppAPIKey(c.Request.Context(), id, newKeyHash); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to regenerate API key"})
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"id":      app.ID,
-		"name":    app.Name,
-		"api_key": newAPIKey, // Return new key to user
-	})
+	if update.Name != nil {
+		if *update.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name cannot be empty"})
+			return
+		}
+		app.Name = *update.Name
+	}
+	if update.RetentionDays != nil && *update.RetentionDays > 0 {
+		app.RetentionDays = *update.RetentionDays
+	}
+	if update.IncludeFrameworkFrames != nil {
+		// Only affects crashes fingerprinted from now on; existing groups keep
+		// their fingerprints
+		app.IncludeFrameworkFrames = *update.IncludeFrameworkFrames
+	}
+	if update.FingerprintMetadataKeys != nil {
+		app.FingerprintMetadataKeys = normalizeMetadataKeys(*update.FingerprintMetadataKeys)
+	}
+	if update.MaxGroups != nil {
+		app.MaxGroups = *update.MaxGroups
+	}
+	if update.AssignmentRules != nil {
+		// Only affects groups created from now on
+		rules, err := normalizeAssignmentRules(*update.AssignmentRules)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid assignment rules", "details": err.Error()})
+			return
+		}
+		app.AssignmentRules = rules
+	}
+	if update.Disabled != nil {
+		app.Disabled = *update.Disabled
+	}
+	if update.RateLimit != nil {
+		// Zero fields fall back to the server defaults
+		if err := update.RateLimit.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rate limit", "details": err.Error()})
+			return
+		}
+		app.RateLimit = *update.RateLimit
+	}
+	if update.FingerprintConfig != nil {
+		// Replaces the whole config. Only affects crashes fingerprinted from
+		// now on; existing crashes and groups keep their fingerprints.
+		if err := update.FingerprintConfig.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid fingerprint config", "details": err.Error()})
+			return
+		}
+		app.FingerprintConfig = *update.FingerprintConfig
+	}
+	if err := h.repo.UpdateApp(c.Request.Context(), app); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update app"})
+		return
+	}
+
+	c.JSON(http.StatusOK, appResponse(app))
+}
+
+// Bounds on the crashes sampled by PreviewGroupingRules
+const (
+	defaultGroupingPreviewSample = 500
+	maxGroupingPreviewSample     = 5000
+)
+
+// PreviewGroupingRules shows how an app's recent crashes would regroup under
+// proposed fingerprinting rules, without changing anything. Rules left out
+// of the body keep the app's current values.
+func (h *Handler) PreviewGroupingRules(c *gin.Context) {
+	ctx := c.Request.Context()
+	app, err := h.repo.GetApp(ctx, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve app"})
+		return
+	}
+	if app == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+		return
+	}
+
+	var req struct {
+		FingerprintConfig       *core.GrouperConfig `json:"fingerprint_config"`
+		IncludeFrameworkFrames  *bool               `json:"include_framework_frames"`
+		FingerprintMetadataKeys *[]string           `json:"fingerprint_metadata_keys"`
+		SampleSize              int                 `json:"sample_size" binding:"omitempty,min=1"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	proposed := *app
+	if req.FingerprintConfig != nil {
+		if err := req.FingerprintConfig.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid fingerprint config", "details": err.Error()})
+			return
+		}
+		proposed.FingerprintConfig = *req.FingerprintConfig
+	}
+	if req.IncludeFrameworkFrames != nil {
+		proposed.IncludeFrameworkFrames = *req.IncludeFrameworkFrames
+	}
+	if req.FingerprintMetadataKeys != nil {
+		proposed.FingerprintMetadataKeys = normalizeMetadataKeys(*req.FingerprintMetadataKeys)
+	}
+
+	sampleSize := defaultGroupingPreviewSample
+	if req.SampleSize > 0 {
+		sampleSize = min(req.SampleSize, maxGroupingPreviewSample)
+	}
+	crashes, _, err := h.repo.ListCrashes(ctx, storage.CrashFilter{AppID: app.ID, Limit: sampleSize})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list crashes"})
+		return
+	}
+	// Fingerprint the full payloads, as at ingestion
+	for i, crash := range crashes {
+		crashes[i] = ingest.LoadCrashFile(ctx, h.fileStore, crash)
+	}
+
+	c.JSON(http.StatusOK, h.grouper.PreviewGrouping(crashes, app, &proposed))
 }
 
 // ListApps lists all apps (admin only)
@@ -467,14 +1696,22 @@ func (h *Handler) ListApps(c *gin.Context) {
 		return
 	}
 
+	// Stats are opt-in to keep the default listing cheap
+	var summaries map[string]core.AppStatsSummary
+	if includes(c, "stats") {
+		summaries, err = h.repo.ListAppStatsSummaries(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get stats"})
+			return
+		}
+	}
+
 	// Don't expose API key hashes
 	result := make([]gin.H, len(apps))
 	for i, app := range apps {
-		result[i] = gin.H{
-			"id":             app.ID,
-			"name":           app.Name,
-			"created_at":     app.CreatedAt,
-			"retention_days": app.RetentionDays,
+		result[i] = appResponse(app)
+		if summaries != nil {
+			result[i]["stats"] = summaries[app.ID]
 		}
 	}
 
@@ -501,6 +1738,168 @@ func (h *Handler) GetAppStats(c *gin.Context) {
 	c.JSON(http.StatusOK, stats)
 }
 
+// GetAppSummary returns everything the app dashboard needs on load in one
+// response: crash stats, the most recently active open groups, and storage usage
+func (h *Handler) GetAppSummary(c *gin.Context) {
+	id := c.Param("id")
+
+	// Check access
+	app := GetApp(c)
+	if app != nil && app.ID != id && !IsAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	target, err := h.repo.GetApp(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve app"})
+		return
+	}
+	if target == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+		return
+	}
+
+	stats, err := h.repo.GetAppStats(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get stats"})
+		return
+	}
+
+	limit := parseIntQuery(c, "groups_limit", 5)
+	if limit < 1 || limit > 50 {
+		limit = 5
+	}
+	groups, _, err := h.repo.ListGroups(ctx, storage.GroupFilter{
+		AppID:     id,
+		Status:    "open",
+		SortBy:    "last_seen",
+		SortOrder: "desc",
+		Limit:     limit,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list groups"})
+		return
+	}
+	now := time.Now()
+	for _, group := range groups {
+		group.ComputeActivity(now)
+	}
+
+	storageStats, err := h.fileStore.GetStorageStats(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get storage stats"})
+		return
+	}
+
+	deployLimit := parseIntQuery(c, "deploys_limit", 5)
+	if deployLimit < 1 || deployLimit > 50 {
+		deployLimit = 5
+	}
+	deploys, err := h.repo.ListAppVersions(ctx, id, deployLimit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list app versions"})
+		return
+	}
+	if deploys == nil {
+		deploys = []core.AppVersion{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"app":            appResponse(target),
+		"stats":          stats,
+		"recent_groups":  groups,
+		"storage":        storageStats,
+		"recent_deploys": deploys,
+	})
+}
+
+// CompareVersions contrasts the crash groups of two app versions: groups new
+// in the candidate, groups no longer seen, and count changes for the rest
+func (h *Handler) CompareVersions(c *gin.Context) {
+	id := c.Param("id")
+
+	// Check access
+	app := GetApp(c)
+	if app != nil && app.ID != id && !IsAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	base, candidate := c.Query("base"), c.Query("candidate")
+	if base == "" || candidate == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "base and candidate versions are required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	baseCounts, err := h.repo.CountCrashesByGroupForVersion(ctx, id, base)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compare versions"})
+		return
+	}
+	candidateCounts, err := h.repo.CountCrashesByGroupForVersion(ctx, id, candidate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compare versions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, core.CompareVersions(base, candidate, baseCounts, candidateCounts))
+}
+
+// GetUserCrashes returns one user's crashes for support triage: the groups
+// they have hit with a count for each, and their most recent crashes
+func (h *Handler) GetUserCrashes(c *gin.Context) {
+	id, userID := c.Param("id"), c.Param("userID")
+
+	// Check access
+	app := GetApp(c)
+	if app != nil && app.ID != id && !IsAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	groups, err := h.repo.CountCrashesByGroupForUser(ctx, id, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count crashes"})
+		return
+	}
+	if groups == nil {
+		groups = []core.UserCrashGroup{}
+	}
+	total := 0
+	for _, group := range groups {
+		total += group.Count
+	}
+
+	limit := parseIntQuery(c, "crashes_limit", 10)
+	if limit < 1 || limit > 50 {
+		limit = 10
+	}
+	crashes, _, err := h.repo.ListCrashes(ctx, storage.CrashFilter{
+		AppID:  id,
+		UserID: userID,
+		Limit:  limit,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list crashes"})
+		return
+	}
+	if crashes == nil {
+		crashes = []*core.Crash{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":        userID,
+		"total_crashes":  total,
+		"groups":         groups,
+		"recent_crashes": crashes,
+	})
+}
+
 // CreateAlert creates a new alert
 func (h *Handler) CreateAlert(c *gin.Context) {
 	var req struct {
@@ -508,6 +1907,7 @@ func (h *Handler) CreateAlert(c *gin.Context) {
 		Type    string                 `json:"type" binding:"required"`
 		Config  map[string]interface{} `json:"config"`
 		Enabled bool                   `json:"enabled"`
+		Verify  bool                   `json:"verify"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -524,6 +1924,24 @@ func (h *Handler) CreateAlert(c *gin.Context) {
 		CreatedAt: time.Now().UTC(),
 	}
 
+	if alert.Type == "webhook" {
+		if _, err := core.WebhookPayloadVersion(alert.Config); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert config", "details": err.Error()})
+			return
+		}
+	}
+
+	// Optionally ping the webhook before saving so a bad URL is reported immediately
+	if req.Verify && alert.Type == "webhook" && h.alerter != nil {
+		if err := h.alerter.VerifyWebhook(c.Request.Context(), alert); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error":   "Webhook verification failed",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
 	if err := h.repo.CreateAlert(c.Request.Context(), alert); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create alert"})
 		return
@@ -580,6 +1998,17 @@ func parseIntQuery(c *gin.Context, key string, defaultVal int) int {
 	return defaultVal
 }
 
+// includes reports whether the comma-separated include query parameter
+// lists name
+func includes(c *gin.Context, name string) bool {
+	for _, part := range strings.Split(c.Query("include"), ",") {
+		if strings.TrimSpace(part) == name {
+			return true
+		}
+	}
+	return false
+}
+
 func generateSecureAPIKey() string {
 	b := make([]byte, 32)
 	rand.Read(b)
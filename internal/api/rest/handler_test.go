@@ -0,0 +1,69 @@
+package rest
+
+import (
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestReady(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		breakFn    func(ts *testServer)
+		wantStatus int
+		wantChecks map[string]interface{}
+	}{
+		{"healthy", http.MethodGet, nil, http.StatusOK, map[string]interface{}{"database": "ok", "file_store": "ok"}},
+		{"HEAD", http.MethodHead, nil, http.StatusOK, nil},
+		{"database closed", http.MethodGet, func(ts *testServer) { ts.repo.Close() },
+			http.StatusServiceUnavailable, map[string]interface{}{"database": "error", "file_store": "ok"}},
+		{"file store missing", http.MethodGet, func(ts *testServer) { os.RemoveAll(ts.cfg.Storage.LogsPath) },
+			http.StatusServiceUnavailable, map[string]interface{}{"database": "ok", "file_store": "error"}},
+		{"both", http.MethodGet, func(ts *testServer) {
+			ts.repo.Close()
+			os.RemoveAll(ts.cfg.Storage.LogsPath)
+		}, http.StatusServiceUnavailable, map[string]interface{}{"database": "error", "file_store": "error"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			if tt.breakFn != nil {
+				tt.breakFn(ts)
+			}
+
+			// Readiness is public
+			w := ts.do(tt.method, "/ready", "", nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantChecks == nil {
+				return
+			}
+			resp := decode(t, w)
+			wantStatus := "ok"
+			if tt.wantStatus != http.StatusOK {
+				wantStatus = "unavailable"
+			}
+			if resp["status"] != wantStatus {
+				t.Errorf("status = %v, want %s", resp["status"], wantStatus)
+			}
+			checks, _ := resp["checks"].(map[string]interface{})
+			for name, want := range tt.wantChecks {
+				if checks[name] != want {
+					t.Errorf("%s check = %v, want %v", name, checks[name], want)
+				}
+			}
+			if _, ok := resp["alert_queue_depth"]; !ok {
+				t.Error("response has no alert_queue_depth")
+			}
+		})
+	}
+
+	// The check leaves nothing behind in the crash directory
+	ts := newTestServer(t)
+	ts.do(http.MethodGet, "/ready", "", nil)
+	if entries, err := os.ReadDir(ts.cfg.Storage.LogsPath); err != nil || len(entries) != 0 {
+		t.Errorf("crash directory = %v, %v; want it empty", entries, err)
+	}
+}
@@ -0,0 +1,292 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/flakerimi/inceptor/internal/auth"
+	"github.com/flakerimi/inceptor/internal/config"
+	"github.com/flakerimi/inceptor/internal/core"
+	"github.com/flakerimi/inceptor/internal/ingest"
+	"github.com/flakerimi/inceptor/internal/storage"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+// testAdminKey is the admin API key of test servers
+const testAdminKey = "test-admin-key"
+
+func TestMain(m *testing.M) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	os.Exit(m.Run())
+}
+
+// testServer is a REST server backed by a SQLite database and local file
+// store in a temporary directory
+type testServer struct {
+	t       *testing.T
+	server  *Server
+	repo    *storage.SQLiteRepository
+	files   *storage.LocalFileStore
+	alerter *core.AlertManager
+	// keyUsage records API key use; Wait for its writes before reading them
+	keyUsage *core.APIKeyUsageTracker
+	cfg      *config.Config
+}
+
+// newTestServer starts a test server with the default configuration,
+// changed by configure if given
+func newTestServer(t *testing.T, configure ...func(cfg *config.Config)) *testServer {
+	t.Helper()
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	dir := t.TempDir()
+	cfg.Auth.AdminKey = testAdminKey
+	cfg.Storage.SQLitePath = filepath.Join(dir, "inceptor.db")
+	cfg.Storage.LogsPath = filepath.Join(dir, "crashes")
+	cfg.Storage.DeadLetterPath = filepath.Join(dir, "deadletter")
+	cfg.Ingest.Symbolication.DSYMPath = filepath.Join(dir, "dsyms")
+	cfg.Ingest.Deobfuscation.MappingPath = filepath.Join(dir, "mappings")
+	cfg.Ingest.SourceMaps.Path = filepath.Join(dir, "sourcemaps")
+	// Store crashes before responding, so tests can read them back
+	cfg.Ingest.Async.QueueSize = 0
+	for _, fn := range configure {
+		fn(cfg)
+	}
+
+	repo, err := storage.NewSQLiteRepository(cfg.Storage.SQLitePath)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	files, err := storage.NewLocalFileStore(cfg.Storage.LogsPath)
+	if err != nil {
+		t.Fatalf("open file store: %v", err)
+	}
+	alerter := core.NewAlertManager(core.SMTPConfig{}, "")
+	authManager := auth.NewManager(repo, repo)
+	if err := authManager.Bootstrap(context.Background(), ""); err != nil {
+		t.Fatalf("bootstrap auth: %v", err)
+	}
+	limiter := core.NewRateLimiter(cfg.Ingest.RateLimit.Rate, cfg.Ingest.RateLimit.Burst)
+	keyUsage := core.NewAPIKeyUsageTracker(repo, core.APIKeyUsageInterval)
+
+	ts := &testServer{
+		t:        t,
+		server:   NewServer(repo, files, alerter, ingest.NewPipeline(repo, files, alerter, cfg), limiter, keyUsage, authManager, cfg, "test"),
+		repo:     repo,
+		files:    files,
+		alerter:  alerter,
+		keyUsage: keyUsage,
+		cfg:      cfg,
+	}
+	t.Cleanup(func() {
+		ts.server.handler.Close()
+		keyUsage.Wait()
+		alerter.Close()
+		repo.Close()
+	})
+	return ts
+}
+
+// do sends a request with an optional JSON body, authenticated with key
+// unless it is empty
+func (ts *testServer) do(method, path, key string, body interface{}) *httptest.ResponseRecorder {
+	ts.t.Helper()
+
+	var data []byte
+	switch b := body.(type) {
+	case nil:
+		return ts.doRaw(method, path, key, "", nil)
+	case string:
+		data = []byte(b)
+	case []byte:
+		data = b
+	default:
+		var err error
+		if data, err = json.Marshal(b); err != nil {
+			ts.t.Fatalf("marshal body: %v", err)
+		}
+	}
+	return ts.doRaw(method, path, key, "application/json", data)
+}
+
+// jsonBody encodes v as a JSON request body
+func jsonBody(t *testing.T, v interface{}) *bytes.Reader {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal body: %v", err)
+	}
+	return bytes.NewReader(data)
+}
+
+// doRaw sends a request with a body of the given content type
+func (ts *testServer) doRaw(method, path, key, contentType string, body []byte) *httptest.ResponseRecorder {
+	ts.t.Helper()
+
+	req := httptest.NewRequest(method, path, bytes.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	return ts.serve(req)
+}
+
+// doSession sends a request with an optional JSON body, authenticated with
+// a dashboard session token
+func (ts *testServer) doSession(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	ts.t.Helper()
+
+	var reader io.Reader = http.NoBody
+	if body != nil {
+		reader = jsonBody(ts.t, body)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return ts.serve(req)
+}
+
+// login logs in with a username and password, returning the session token
+func (ts *testServer) login(username, password string) string {
+	ts.t.Helper()
+
+	w := ts.do(http.MethodPost, "/api/v1/auth/login", "", map[string]interface{}{"username": username, "password": password})
+	if w.Code != http.StatusOK {
+		ts.t.Fatalf("login as %s: status %d: %s", username, w.Code, w.Body.String())
+	}
+	return decode(ts.t, w)["token"].(string)
+}
+
+// createUser creates a dashboard account and logs in as it, returning the
+// session token
+func (ts *testServer) createUser(username string, role core.UserRole) string {
+	ts.t.Helper()
+
+	if _, err := ts.server.authManager.CreateUser(context.Background(), username, "secret-password", role); err != nil {
+		ts.t.Fatalf("create user %s: %v", username, err)
+	}
+	return ts.login(username, "secret-password")
+}
+
+// serve runs a prepared request through the server
+func (ts *testServer) serve(req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	ts.server.router.ServeHTTP(w, req)
+	return w
+}
+
+// createApp creates an app through the API, returning its ID and API key
+func (ts *testServer) createApp(body map[string]interface{}) (string, string) {
+	ts.t.Helper()
+
+	if body == nil {
+		body = map[string]interface{}{}
+	}
+	if _, ok := body["name"]; !ok {
+		body["name"] = "Test App"
+	}
+	w := ts.do(http.MethodPost, "/api/v1/apps", testAdminKey, body)
+	if w.Code != http.StatusCreated {
+		ts.t.Fatalf("create app: status %d: %s", w.Code, w.Body.String())
+	}
+	resp := decode(ts.t, w)
+	return resp["id"].(string), resp["api_key"].(string)
+}
+
+// submitCrash submits a crash with an app's key, failing the test unless
+// it is accepted
+func (ts *testServer) submitCrash(apiKey string, crash map[string]interface{}) map[string]interface{} {
+	ts.t.Helper()
+
+	w := ts.do(http.MethodPost, "/api/v1/crashes", apiKey, crash)
+	if w.Code != http.StatusCreated && w.Code != http.StatusOK {
+		ts.t.Fatalf("submit crash: status %d: %s", w.Code, w.Body.String())
+	}
+	return decode(ts.t, w)
+}
+
+// addGroup records an occurrence of errorType at a given time directly in
+// the database, as ingestion would have then, returning its group's ID
+func (ts *testServer) addGroup(appID, errorType string, at time.Time) string {
+	ts.t.Helper()
+
+	crash := &core.Crash{
+		ID:          uuid.New().String(),
+		AppID:       appID,
+		ErrorType:   errorType,
+		Fingerprint: "fp-" + errorType,
+		GroupID:     uuid.New().String(),
+		CreatedAt:   at,
+	}
+	group, _, err := ts.repo.GetOrCreateGroup(context.Background(), crash)
+	if err != nil {
+		ts.t.Fatalf("create group: %v", err)
+	}
+	crash.GroupID = group.ID
+	if err := ts.repo.CreateCrash(context.Background(), crash); err != nil {
+		ts.t.Fatalf("create crash: %v", err)
+	}
+	return group.ID
+}
+
+// testCrash returns a crash submission, changed by the given fields
+func testCrash(fields map[string]interface{}) map[string]interface{} {
+	crash := map[string]interface{}{
+		"app_version":   "1.0.0",
+		"platform":      "android",
+		"os_version":    "14",
+		"error_type":    "StateError",
+		"error_message": "Bad state: no element",
+		"stack_trace": []map[string]interface{}{
+			{"file_name": "lib/main.dart", "line_number": 10, "method_name": "main", "class_name": "App"},
+			{"file_name": "lib/home.dart", "line_number": 42, "method_name": "build", "class_name": "Home"},
+		},
+	}
+	for k, v := range fields {
+		crash[k] = v
+	}
+	return crash
+}
+
+// decode decodes a JSON object response body
+func decode(t *testing.T, w *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode response %q: %v", w.Body.String(), err)
+	}
+	return body
+}
+
+// dataList returns the "data" array of a list response, nil when empty
+func dataList(t *testing.T, w *httptest.ResponseRecorder) []interface{} {
+	t.Helper()
+
+	body := decode(t, w)
+	if body["data"] == nil {
+		return nil
+	}
+	data, ok := body["data"].([]interface{})
+	if !ok {
+		t.Fatalf("response has no data array: %s", w.Body.String())
+	}
+	return data
+}
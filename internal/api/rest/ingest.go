@@ -0,0 +1,85 @@
+package rest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/flakerimi/inceptor/internal/core"
+	"github.com/flakerimi/inceptor/internal/ingest"
+	"github.com/rs/zerolog/log"
+)
+
+// asyncIngester persists crashes in the background for clients that accept
+// a 202 instead of waiting for the group result
+type asyncIngester struct {
+	pipeline *ingest.Pipeline
+	queue    chan *core.Crash
+	wg       sync.WaitGroup
+	mu       sync.RWMutex
+	closed   bool
+}
+
+// newAsyncIngester starts workers draining a queue of at most queueSize crashes
+func newAsyncIngester(pipeline *ingest.Pipeline, queueSize, workers int) *asyncIngester {
+	if workers <= 0 {
+		workers = 1
+	}
+
+	ai := &asyncIngester{
+		pipeline: pipeline,
+		queue:    make(chan *core.Crash, queueSize),
+	}
+
+	for i := 0; i < workers; i++ {
+		ai.wg.Add(1)
+		go ai.worker()
+	}
+
+	return ai
+}
+
+// Enqueue queues a crash for ingestion. It returns false without blocking
+// when the queue is full or the ingester is shutting down.
+func (ai *asyncIngester) Enqueue(crash *core.Crash) bool {
+	ai.mu.RLock()
+	defer ai.mu.RUnlock()
+
+	if ai.closed {
+		return false
+	}
+
+	select {
+	case ai.queue <- crash:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close stops accepting crashes and waits for queued ones to be stored
+func (ai *asyncIngester) Close() {
+	ai.mu.Lock()
+	if ai.closed {
+		ai.mu.Unlock()
+		return
+	}
+	ai.closed = true
+	close(ai.queue)
+	ai.mu.Unlock()
+
+	ai.wg.Wait()
+}
+
+// worker stores queued crashes until the queue is closed and drained
+func (ai *asyncIngester) worker() {
+	defer ai.wg.Done()
+
+	for crash := range ai.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if _, _, err := ai.pipeline.Store(ctx, crash); err != nil {
+			log.Error().Err(err).Str("crash_id", crash.ID).Msg("Async crash ingestion failed")
+		}
+		cancel()
+	}
+}
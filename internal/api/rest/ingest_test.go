@@ -0,0 +1,143 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flakerimi/inceptor/internal/config"
+	"github.com/flakerimi/inceptor/internal/core"
+)
+
+// submitAsync submits a crash asking for asynchronous ingestion
+func (ts *testServer) submitAsync(apiKey string, crash map[string]interface{}) *httptest.ResponseRecorder {
+	ts.t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/crashes", jsonBody(ts.t, crash))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", apiKey)
+	req.Header.Set("Prefer", "respond-async")
+	return ts.serve(req)
+}
+
+// waitForCrash polls until a crash is stored, failing the test after a few
+// seconds
+func (ts *testServer) waitForCrash(id string) map[string]interface{} {
+	ts.t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		w := ts.do(http.MethodGet, "/api/v1/crashes/"+id, testAdminKey, nil)
+		if w.Code == http.StatusOK {
+			return decode(ts.t, w)
+		}
+		if time.Now().After(deadline) {
+			ts.t.Fatalf("crash %s not stored: status %d", id, w.Code)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAsyncSubmission(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.Ingest.Async.QueueSize = 10
+	})
+	_, apiKey := ts.createApp(nil)
+
+	w := ts.submitAsync(apiKey, testCrash(nil))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202: %s", w.Code, w.Body.String())
+	}
+	resp := decode(t, w)
+	if resp["status"] != "queued" || resp["id"] == nil {
+		t.Fatalf("response = %v, want a queued crash ID", resp)
+	}
+
+	crash := ts.waitForCrash(resp["id"].(string))
+	if crash["group_id"] == "" || crash["group_id"] == nil {
+		t.Errorf("stored crash = %v, want it grouped", crash)
+	}
+
+	// Without the header the group result is still returned synchronously
+	w = ts.do(http.MethodPost, "/api/v1/crashes", apiKey, testCrash(nil))
+	if w.Code != http.StatusCreated || decode(t, w)["group_id"] != crash["group_id"] {
+		t.Errorf("synchronous submission = %d %s, want 201 in the same group", w.Code, w.Body.String())
+	}
+}
+
+func TestAsyncSubmissionPerApp(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.Ingest.Async.QueueSize = 10
+	})
+	asyncAppID, asyncKey := ts.createApp(map[string]interface{}{"name": "Async"})
+	_, syncKey := ts.createApp(map[string]interface{}{"name": "Sync"})
+	ts.cfg.Ingest.Async.AppIDs = []string{asyncAppID}
+
+	tests := []struct {
+		name       string
+		key        string
+		wantStatus int
+	}{
+		{"configured app", asyncKey, http.StatusAccepted},
+		{"other app", syncKey, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ts.do(http.MethodPost, "/api/v1/crashes", tt.key, testCrash(nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			ts.waitForCrash(decode(t, w)["id"].(string))
+		})
+	}
+}
+
+func TestAsyncSubmissionQueueFull(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.Ingest.Async.QueueSize = 10
+	})
+	_, apiKey := ts.createApp(nil)
+
+	// A full queue with no workers draining it
+	ingester := ts.server.handler.ingester
+	ts.server.handler.ingester = &asyncIngester{queue: make(chan *core.Crash, 1)}
+	ts.server.handler.ingester.queue <- &core.Crash{}
+	defer func() { ts.server.handler.ingester = ingester }()
+
+	w := ts.submitAsync(apiKey, testCrash(nil))
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("missing Retry-After header")
+	}
+}
+
+func TestAsyncIngesterClose(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.Ingest.Async.QueueSize = 100
+	})
+	_, apiKey := ts.createApp(nil)
+
+	var ids []string
+	for i := 0; i < 20; i++ {
+		w := ts.submitAsync(apiKey, testCrash(nil))
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("status = %d, want 202", w.Code)
+		}
+		ids = append(ids, decode(t, w)["id"].(string))
+	}
+
+	// Closing stores everything queued, then refuses new crashes
+	ts.server.handler.ingester.Close()
+	for _, id := range ids {
+		if crash, err := ts.repo.GetCrash(context.Background(), id); err != nil || crash == nil {
+			t.Fatalf("crash %s not stored after Close: %v", id, err)
+		}
+	}
+	if w := ts.submitAsync(apiKey, testCrash(nil)); w.Code != http.StatusTooManyRequests {
+		t.Errorf("status after Close = %d, want 429", w.Code)
+	}
+}
@@ -0,0 +1,154 @@
+package rest
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/flakerimi/inceptor/internal/core"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maintenanceWindowResponse is the public view of a maintenance window, with
+// whether it is active now and how many alerts it has suppressed
+func (h *Handler) maintenanceWindowResponse(window core.MaintenanceWindow, now time.Time) gin.H {
+	suppressed := 0
+	if h.alerter != nil {
+		suppressed = h.alerter.SuppressedCount(window.ID)
+	}
+	return gin.H{
+		"id":                window.ID,
+		"starts_at":         window.StartsAt,
+		"ends_at":           window.EndsAt,
+		"recurrence":        window.Recurrence,
+		"reason":            window.Reason,
+		"allow_escalations": window.AllowEscalations,
+		"created_at":        window.CreatedAt,
+		"active":            window.Active(now),
+		"suppressed_alerts": suppressed,
+	}
+}
+
+// ListMaintenanceWindows lists an app's maintenance windows
+func (h *Handler) ListMaintenanceWindows(c *gin.Context) {
+	app, err := h.repo.GetApp(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve app"})
+		return
+	}
+	if app == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+		return
+	}
+
+	now := time.Now().UTC()
+	result := make([]gin.H, len(app.MaintenanceWindows))
+	for i, window := range app.MaintenanceWindows {
+		result[i] = h.maintenanceWindowResponse(window, now)
+	}
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// CreateMaintenanceWindow schedules a window during which the app's alerts
+// are suppressed. One-off windows that have already ended are dropped.
+func (h *Handler) CreateMaintenanceWindow(c *gin.Context) {
+	var req struct {
+		StartsAt         time.Time `json:"starts_at" binding:"required"`
+		EndsAt           time.Time `json:"ends_at" binding:"required"`
+		Recurrence       string    `json:"recurrence"`
+		Reason           string    `json:"reason"`
+		AllowEscalations bool      `json:"allow_escalations"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	now := time.Now().UTC()
+	window := core.MaintenanceWindow{
+		ID:               uuid.New().String(),
+		StartsAt:         req.StartsAt.UTC(),
+		EndsAt:           req.EndsAt.UTC(),
+		Recurrence:       strings.TrimSpace(req.Recurrence),
+		Reason:           strings.TrimSpace(req.Reason),
+		AllowEscalations: req.AllowEscalations,
+		CreatedAt:        now,
+	}
+	if window.Recurrence == "" {
+		window.Recurrence = core.MaintenanceOnce
+	}
+	if err := window.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid maintenance window", "details": err.Error()})
+		return
+	}
+	if window.Expired(now) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid maintenance window", "details": "ends_at is in the past"})
+		return
+	}
+
+	app, err := h.repo.GetApp(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve app"})
+		return
+	}
+	if app == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+		return
+	}
+
+	windows := make([]core.MaintenanceWindow, 0, len(app.MaintenanceWindows)+1)
+	for _, existing := range app.MaintenanceWindows {
+		if !existing.Expired(now) {
+			windows = append(windows, existing)
+		}
+	}
+	app.MaintenanceWindows = append(windows, window)
+
+	if err := h.repo.UpdateApp(c.Request.Context(), app); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save maintenance window"})
+		return
+	}
+	if h.alerter != nil {
+		h.alerter.SetMaintenanceWindows(app.ID, app.MaintenanceWindows)
+	}
+
+	c.JSON(http.StatusCreated, h.maintenanceWindowResponse(window, now))
+}
+
+// DeleteMaintenanceWindow removes one of an app's maintenance windows,
+// ending it early if it is active
+func (h *Handler) DeleteMaintenanceWindow(c *gin.Context) {
+	app, err := h.repo.GetApp(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve app"})
+		return
+	}
+	if app == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+		return
+	}
+
+	windowID := c.Param("windowID")
+	windows := make([]core.MaintenanceWindow, 0, len(app.MaintenanceWindows))
+	for _, window := range app.MaintenanceWindows {
+		if window.ID != windowID {
+			windows = append(windows, window)
+		}
+	}
+	if len(windows) == len(app.MaintenanceWindows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Maintenance window not found"})
+		return
+	}
+	app.MaintenanceWindows = windows
+
+	if err := h.repo.UpdateApp(c.Request.Context(), app); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete maintenance window"})
+		return
+	}
+	if h.alerter != nil {
+		h.alerter.SetMaintenanceWindows(app.ID, app.MaintenanceWindows)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Maintenance window deleted"})
+}
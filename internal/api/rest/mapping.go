@@ -0,0 +1,88 @@
+package rest
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/flakerimi/inceptor/internal/proguard"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// UploadMapping stores the ProGuard or R8 mapping file of an app version for
+// deobfuscating its Android crashes. The version is given as app_version in
+// the query or form, and the mapping.txt is sent raw or as the "file" field
+// of a multipart form. Crashes received earlier are not retraced
+// retroactively.
+func (h *Handler) UploadMapping(c *gin.Context) {
+	if h.mappings == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deobfuscation is not enabled"})
+		return
+	}
+
+	app, err := h.repo.GetApp(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve app"})
+		return
+	}
+	if app == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+		return
+	}
+
+	if maxSize := h.cfg.Ingest.Deobfuscation.MaxUploadBytes; maxSize > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize)
+	}
+
+	body := io.Reader(c.Request.Body)
+	appVersion := c.Query("app_version")
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			abortInvalidCrashBody(c, err)
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read upload"})
+			return
+		}
+		defer file.Close()
+		body = file
+		if appVersion == "" {
+			appVersion = c.PostForm("app_version")
+		}
+	}
+	appVersion = strings.TrimSpace(appVersion)
+	if appVersion == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "app_version is required"})
+		return
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		abortInvalidCrashBody(c, err)
+		return
+	}
+	mapping, err := proguard.Parse(bytes.NewReader(data))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mapping", "details": err.Error()})
+		return
+	}
+
+	if err := h.mappings.Save(app.ID, appVersion, data); err != nil {
+		log.Error().Err(err).Str("app_id", app.ID).Msg("Failed to save mapping")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save mapping"})
+		return
+	}
+	h.retracer.Forget(app.ID, appVersion)
+
+	log.Info().Str("app_id", app.ID).Str("app_version", appVersion).Int("classes", mapping.Classes()).Msg("Mapping uploaded")
+	c.JSON(http.StatusCreated, gin.H{"data": gin.H{
+		"app_version": appVersion,
+		"classes":     mapping.Classes(),
+		"size_bytes":  len(data),
+	}})
+}
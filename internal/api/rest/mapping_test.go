@@ -0,0 +1,100 @@
+package rest
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"testing"
+
+	"github.com/flakerimi/inceptor/internal/config"
+)
+
+const testMappingFile = `com.example.app.MainActivity -> a.a:
+    1:1:void onCreate(android.os.Bundle):42:42 -> a
+com.example.app.CrashException -> a.b:
+`
+
+func TestUploadMapping(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.Ingest.Deobfuscation.MaxUploadBytes = 64 * 1024
+	})
+	appID, apiKey := ts.createApp(nil)
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	mw.WriteField("app_version", "2.0.0")
+	part, _ := mw.CreateFormFile("file", "mapping.txt")
+	part.Write([]byte(testMappingFile))
+	mw.Close()
+
+	path := "/api/v1/apps/" + appID + "/mappings"
+	tests := []struct {
+		name        string
+		path        string
+		key         string
+		contentType string
+		body        []byte
+		wantStatus  int
+		wantVersion string
+	}{
+		{"raw", path + "?app_version=1.0.0", testAdminKey, "text/plain", []byte(testMappingFile), http.StatusCreated, "1.0.0"},
+		{"multipart", path, testAdminKey, mw.FormDataContentType(), form.Bytes(), http.StatusCreated, "2.0.0"},
+		{"no version", path, testAdminKey, "text/plain", []byte(testMappingFile), http.StatusBadRequest, ""},
+		{"blank version", path + "?app_version=+", testAdminKey, "text/plain", []byte(testMappingFile), http.StatusBadRequest, ""},
+		{"not a mapping", path + "?app_version=1.0.0", testAdminKey, "text/plain", []byte("hello"), http.StatusBadRequest, ""},
+		{"too large", path + "?app_version=1.0.0", testAdminKey, "text/plain", bytes.Repeat([]byte("#\n"), 33*1024), http.StatusRequestEntityTooLarge, ""},
+		{"unknown app", "/api/v1/apps/missing/mappings?app_version=1.0.0", testAdminKey, "text/plain", []byte(testMappingFile), http.StatusNotFound, ""},
+		{"app key", path + "?app_version=1.0.0", apiKey, "text/plain", []byte(testMappingFile), http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ts.doRaw(http.MethodPost, tt.path, tt.key, tt.contentType, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusCreated {
+				return
+			}
+			data, _ := decode(t, w)["data"].(map[string]interface{})
+			if data["app_version"] != tt.wantVersion || data["classes"] != float64(2) || data["size_bytes"] != float64(len(testMappingFile)) {
+				t.Errorf("data = %v", data)
+			}
+		})
+	}
+
+	// Crashes of a version with a mapping are retraced
+	resp := ts.submitCrash(apiKey, testCrash(map[string]interface{}{
+		"app_version": "1.0.0",
+		"error_type":  "a.b",
+		"stack_trace": []map[string]interface{}{
+			{"file_name": "SourceFile", "class_name": "a.a", "method_name": "a", "line_number": 1},
+		},
+	}))
+	crash := decode(t, ts.do(http.MethodGet, "/api/v1/crashes/"+resp["id"].(string), testAdminKey, nil))
+	frames, _ := crash["stack_trace"].([]interface{})
+	if len(frames) != 1 {
+		t.Fatalf("stack trace = %v", crash["stack_trace"])
+	}
+	frame := frames[0].(map[string]interface{})
+	if frame["class_name"] != "com.example.app.MainActivity" || frame["method_name"] != "onCreate" || frame["line_number"] != float64(42) {
+		t.Errorf("frame = %v, want MainActivity.onCreate at line 42", frame)
+	}
+	if crash["error_type"] != "com.example.app.CrashException" {
+		t.Errorf("error type = %v, want the original class", crash["error_type"])
+	}
+	if metadata, _ := crash["metadata"].(map[string]interface{}); metadata["deobfuscated"] != true {
+		t.Errorf("metadata = %v, want deobfuscated", crash["metadata"])
+	}
+}
+
+func TestUploadMappingDisabled(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.Ingest.Deobfuscation.Enabled = false
+	})
+	appID, _ := ts.createApp(nil)
+
+	w := ts.doRaw(http.MethodPost, "/api/v1/apps/"+appID+"/mappings?app_version=1.0.0", testAdminKey, "text/plain", []byte(testMappingFile))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404 with deobfuscation disabled", w.Code)
+	}
+}
@@ -0,0 +1,48 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricsGaugeTimeout bounds the queries gauges make on each scrape
+const metricsGaugeTimeout = 5 * time.Second
+
+// newMetricsHandler registers the server's metrics with a registry of its
+// own, so servers in one process don't share series, and serves them the
+// way promhttp.Handler serves the default registry: with Go runtime and
+// process metrics, and scrapes of the endpoint itself counted.
+func (h *Handler) newMetricsHandler() http.Handler {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		h.requestDurations,
+	)
+	reg.MustRegister(h.pipeline.Collectors()...)
+	if h.alerter != nil {
+		reg.MustRegister(h.alerter.Collectors()...)
+	}
+	if h.sessions != nil {
+		reg.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "inceptor_active_sessions",
+			Help: "Dashboard sessions that haven't expired.",
+		}, func() float64 {
+			ctx, cancel := context.WithTimeout(context.Background(), metricsGaugeTimeout)
+			defer cancel()
+			return float64(h.sessions.ActiveSessions(ctx))
+		}))
+	}
+	return promhttp.InstrumentMetricHandler(reg, promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+}
+
+// Metrics exposes operational metrics in the Prometheus text format
+func (h *Handler) Metrics(c *gin.Context) {
+	h.metrics.ServeHTTP(c.Writer, c.Request)
+}
@@ -0,0 +1,111 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	ts := newTestServer(t)
+	appID, apiKey := ts.createApp(nil)
+
+	// Every delivery fails, without retries
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+	if w := ts.do(http.MethodPost, "/api/v1/alerts", testAdminKey, map[string]interface{}{
+		"app_id":  appID,
+		"type":    "webhook",
+		"enabled": true,
+		"config": map[string]interface{}{
+			"url":         receiver.URL,
+			"max_retries": 0,
+			"conditions":  map[string]interface{}{"on_new_group": true},
+		},
+	}); w.Code != http.StatusCreated {
+		t.Fatalf("create alert: status %d: %s", w.Code, w.Body.String())
+	}
+
+	now := time.Now().UTC()
+	for _, occurredAt := range []time.Time{now.Add(-2 * time.Minute), now.Add(-3 * 24 * time.Hour), now.Add(time.Hour)} {
+		ts.submitCrash(apiKey, testCrash(map[string]interface{}{"occurred_at": occurredAt.Format(time.RFC3339)}))
+	}
+	// Crashes without occurred_at aren't observed
+	ts.submitCrash(apiKey, testCrash(map[string]interface{}{"platform": "ios", "environment": "staging"}))
+
+	authTests := []struct {
+		name       string
+		key        string
+		wantStatus int
+	}{
+		{"admin key", testAdminKey, http.StatusOK},
+		{"app key", apiKey, http.StatusForbidden},
+		{"no key", "", http.StatusUnauthorized},
+	}
+	for _, tt := range authTests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ts.do(http.MethodGet, "/metrics", tt.key, nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+				t.Errorf("Content-Type = %q, want the text format", w.Header().Get("Content-Type"))
+			}
+		})
+	}
+
+	// The alert is sent by the alerter's worker
+	var body string
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		body = ts.do(http.MethodGet, "/metrics", testAdminKey, nil).Body.String()
+		if strings.Contains(body, `inceptor_alert_send_errors_total{type="webhook"}`) {
+			break
+		}
+	}
+
+	tests := []struct {
+		name   string
+		sample string
+	}{
+		// The crash from the future, by the client's clock
+		{"crash age below zero", fmt.Sprintf(`inceptor_crash_age_seconds_bucket{app_id="%s",le="-60"} 1`, appID)},
+		{"crash age of minutes", fmt.Sprintf(`inceptor_crash_age_seconds_bucket{app_id="%s",le="300"} 2`, appID)},
+		{"crash age of days", fmt.Sprintf(`inceptor_crash_age_seconds_bucket{app_id="%s",le="604800"} 3`, appID)},
+		{"crash age count", fmt.Sprintf(`inceptor_crash_age_seconds_count{app_id="%s"} 3`, appID)},
+		{"crashes received", fmt.Sprintf(`inceptor_crashes_received_total{app_id="%s",environment="production",platform="android"} 3`, appID)},
+		{"crashes received by platform", fmt.Sprintf(`inceptor_crashes_received_total{app_id="%s",environment="staging",platform="ios"} 1`, appID)},
+		{"request durations", `inceptor_http_request_duration_seconds_count{method="POST",route="/api/v1/crashes",status="201"} 4`},
+		{"rejected requests", `inceptor_http_request_duration_seconds_count{method="GET",route="/metrics",status="401"} 1`},
+		{"alert sends", `inceptor_alert_send_total{type="webhook"} 1`},
+		{"alert send errors", `inceptor_alert_send_errors_total{type="webhook"} 1`},
+		{"alert queue depth", `inceptor_alert_queue_depth 0`},
+		{"active sessions", `inceptor_active_sessions 0`},
+		{"Go runtime", `# TYPE go_goroutines gauge`},
+		{"scrapes", `promhttp_metric_handler_requests_total{code="200"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !strings.Contains(body, tt.sample) {
+				t.Errorf("metrics have no %q:\n%s", tt.sample, body)
+			}
+		})
+	}
+}
+
+func TestMetricsPerServer(t *testing.T) {
+	first := newTestServer(t)
+	_, apiKey := first.createApp(nil)
+	first.submitCrash(apiKey, testCrash(nil))
+
+	// A second server in the same process registers its own metrics
+	second := newTestServer(t)
+	body := second.do(http.MethodGet, "/metrics", testAdminKey, nil).Body.String()
+	if strings.Contains(body, "inceptor_crashes_received_total{") {
+		t.Errorf("second server's metrics count the first's crashes:\n%s", body)
+	}
+}
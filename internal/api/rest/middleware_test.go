@@ -0,0 +1,563 @@
+package rest
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flakerimi/inceptor/internal/config"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// captureLogs sends log output to a buffer until the test ends
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	logger, level := log.Logger, zerolog.GlobalLevel()
+	log.Logger = zerolog.New(&buf)
+	zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	t.Cleanup(func() {
+		log.Logger = logger
+		zerolog.SetGlobalLevel(level)
+	})
+	return &buf
+}
+
+// logLines decodes captured JSON log lines
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+
+	var lines []map[string]interface{}
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var entry map[string]interface{}
+		if err := json.Unmarshal(line, &entry); err != nil {
+			t.Fatalf("decode log line %q: %v", line, err)
+		}
+		lines = append(lines, entry)
+	}
+	return lines
+}
+
+func TestRequestLoggerSampling(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        config.AccessLogConfig
+		successes  int
+		errors     int
+		wantLogged int
+	}{
+		{"every request", config.AccessLogConfig{Enabled: true, SampleRate: 1}, 5, 2, 7},
+		{"one in three successes", config.AccessLogConfig{Enabled: true, SampleRate: 3}, 9, 2, 5},
+		{"errors always logged", config.AccessLogConfig{Enabled: true, SampleRate: 1000}, 10, 4, 4},
+		{"disabled", config.AccessLogConfig{Enabled: false, SampleRate: 1}, 3, 3, 0},
+		{"skipped path", config.AccessLogConfig{Enabled: true, SampleRate: 1, SkipPaths: []string{"/ok", "/fail"}}, 3, 3, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := captureLogs(t)
+			router := gin.New()
+			router.Use(RequestLogger(tt.cfg))
+			router.GET("/ok", func(c *gin.Context) { c.Status(http.StatusOK) })
+			router.GET("/fail", func(c *gin.Context) { c.Status(http.StatusInternalServerError) })
+
+			for i := 0; i < tt.successes; i++ {
+				router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ok", nil))
+			}
+			for i := 0; i < tt.errors; i++ {
+				router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+			}
+
+			lines := logLines(t, buf)
+			if len(lines) != tt.wantLogged {
+				t.Fatalf("logged %d requests, want %d", len(lines), tt.wantLogged)
+			}
+			failures := 0
+			for _, line := range lines {
+				if line["level"] == "error" {
+					failures++
+				}
+			}
+			if failures != tt.errors && tt.wantLogged > 0 {
+				t.Errorf("logged %d failed requests, want all %d", failures, tt.errors)
+			}
+		})
+	}
+}
+
+func TestRequestLoggerFields(t *testing.T) {
+	buf := captureLogs(t)
+	router := gin.New()
+	router.Use(RequestLogger(config.AccessLogConfig{Enabled: true, SampleRate: 1, Fields: []string{"method", "status"}}))
+	router.GET("/ok", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ok?x=1", nil))
+
+	lines := logLines(t, buf)
+	if len(lines) != 1 {
+		t.Fatalf("logged %d requests, want 1", len(lines))
+	}
+	line := lines[0]
+	if line["method"] != "GET" || line["status"] != float64(200) {
+		t.Errorf("log line = %v, want method and status", line)
+	}
+	for _, field := range []string{"path", "query", "latency", "client_ip"} {
+		if _, ok := line[field]; ok {
+			t.Errorf("log line has unselected field %q", field)
+		}
+	}
+}
+
+func TestRequestLoggerRedactsCredentials(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"api_key=secret-key&limit=10", "api_key=REDACTED&limit=10"},
+		{"token=1700000000.abc.sig", "token=REDACTED"},
+		{"app_id=a&api%5Fkey=secret-key&token=t&token=u", "app_id=a&api%5Fkey=REDACTED&token=REDACTED&token=REDACTED"},
+		{"tokens=kept&x=1", "tokens=kept&x=1"},
+		{"token", "token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			buf := captureLogs(t)
+			router := gin.New()
+			router.Use(RequestLogger(config.AccessLogConfig{Enabled: true, SampleRate: 1, Fields: []string{"query"}}))
+			router.GET("/ok", func(c *gin.Context) { c.Status(http.StatusOK) })
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ok?"+tt.query, nil))
+
+			lines := logLines(t, buf)
+			if len(lines) != 1 {
+				t.Fatalf("logged %d requests, want 1", len(lines))
+			}
+			if lines[0]["query"] != tt.want {
+				t.Errorf("query = %v, want %q", lines[0]["query"], tt.want)
+			}
+		})
+	}
+}
+
+func TestMultipleAdminKeys(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.Auth.AdminKeys = []string{"old-admin-key"}
+	})
+
+	tests := []struct {
+		name       string
+		key        string
+		wantStatus int
+	}{
+		{"primary key", testAdminKey, http.StatusOK},
+		{"phased-out key", "old-admin-key", http.StatusOK},
+		{"unknown key", "not-an-admin-key", http.StatusUnauthorized},
+		{"no key", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := ts.do(http.MethodGet, "/api/v1/apps", tt.key, nil); w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRequireContentType(t *testing.T) {
+	ts := newTestServer(t)
+	appID, apiKey := ts.createApp(nil)
+	groupID := ts.addGroup(appID, "StateError", time.Now().UTC())
+	crashJSON, _ := json.Marshal(testCrash(nil))
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		key         string
+		contentType string
+		body        []byte
+		wantStatus  int
+	}{
+		{"crash as form", http.MethodPost, "/api/v1/crashes", apiKey, "application/x-www-form-urlencoded", []byte("error_type=StateError"), http.StatusUnsupportedMediaType},
+		{"crash as text", http.MethodPost, "/api/v1/crashes", apiKey, "text/plain", crashJSON, http.StatusUnsupportedMediaType},
+		{"crash without content type", http.MethodPost, "/api/v1/crashes", apiKey, "", crashJSON, http.StatusUnsupportedMediaType},
+		{"crash as JSON with charset", http.MethodPost, "/api/v1/crashes", apiKey, "application/json; charset=utf-8", crashJSON, http.StatusCreated},
+		{"group update as form", http.MethodPatch, "/api/v1/groups/" + groupID, testAdminKey, "multipart/form-data", []byte("status=resolved"), http.StatusUnsupportedMediaType},
+		{"group update as JSON", http.MethodPatch, "/api/v1/groups/" + groupID, testAdminKey, "application/json", []byte(`{"status":"resolved"}`), http.StatusOK},
+		{"login as form", http.MethodPost, "/api/v1/auth/login", "", "application/x-www-form-urlencoded", []byte("password=x"), http.StatusUnsupportedMediaType},
+		{"logout without body", http.MethodPost, "/api/v1/auth/logout", "", "", nil, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ts.doRaw(tt.method, tt.path, tt.key, tt.contentType, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus == http.StatusUnsupportedMediaType {
+				if code := decode(t, w)["code"]; code != "UNSUPPORTED_MEDIA_TYPE" {
+					t.Errorf("code = %v, want UNSUPPORTED_MEDIA_TYPE", code)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitExceeded(t *testing.T) {
+	tests := []struct {
+		retryAfter time.Duration
+		want       string
+	}{
+		{0, "1"},
+		{300 * time.Millisecond, "1"},
+		{time.Second, "1"},
+		{1500 * time.Millisecond, "2"},
+		{time.Minute, "60"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		RateLimitExceeded(c, CodeQuotaExceeded, "Slow down", tt.retryAfter)
+
+		if w.Code != http.StatusTooManyRequests || !c.IsAborted() {
+			t.Errorf("%v: status %d, aborted %v, want an aborted 429", tt.retryAfter, w.Code, c.IsAborted())
+		}
+		if got := w.Header().Get("Retry-After"); got != tt.want {
+			t.Errorf("%v: Retry-After = %q, want %q", tt.retryAfter, got, tt.want)
+		}
+		body := decode(t, w)
+		if body["code"] != CodeQuotaExceeded || body["error"] != "Slow down" || fmt.Sprint(body["retry_after"]) != tt.want {
+			t.Errorf("%v: body = %v", tt.retryAfter, body)
+		}
+	}
+}
+
+func TestThrottledResponses(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.Ingest.RateLimit.Rate = 0.001
+		cfg.Ingest.RateLimit.Burst = 1
+		cfg.Server.AdminRateLimit.Rate = 0.001
+		cfg.Server.AdminRateLimit.Burst = 1
+	})
+	_, apiKey := ts.createApp(nil)
+
+	tests := []struct {
+		name, method, path, key string
+		body                    interface{}
+	}{
+		{"crash submission", http.MethodPost, "/api/v1/crashes", apiKey, testCrash(nil)},
+		{"app API", http.MethodGet, "/api/v1/ping", apiKey, nil},
+		{"admin API", http.MethodGet, "/api/v1/apps", testAdminKey, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var w *httptest.ResponseRecorder
+			for i := 0; i < 3; i++ {
+				if w = ts.do(tt.method, tt.path, tt.key, tt.body); w.Code == http.StatusTooManyRequests {
+					break
+				}
+			}
+			if w.Code != http.StatusTooManyRequests {
+				t.Fatalf("status = %d, want 429 past the burst", w.Code)
+			}
+			retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+			if err != nil || retryAfter < 1 {
+				t.Errorf("Retry-After = %q, want whole seconds", w.Header().Get("Retry-After"))
+			}
+			body := decode(t, w)
+			if body["code"] != CodeRateLimited || body["retry_after"] != float64(retryAfter) || body["error"] == "" {
+				t.Errorf("body = %v, want the shared throttling body", body)
+			}
+		})
+	}
+}
+
+func TestRateLimitBuckets(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.Auth.AdminKeys = []string{"setup-admin-key", "second-admin-key"}
+		cfg.Ingest.RateLimit.Rate = 0.001
+		cfg.Ingest.RateLimit.Burst = 2
+		cfg.Server.AdminRateLimit.Rate = 0.001
+		cfg.Server.AdminRateLimit.Burst = 2
+	})
+	// Apps are created with their own admin key, so the test's admin
+	// buckets start full
+	createApp := func(body map[string]interface{}) string {
+		w := ts.do(http.MethodPost, "/api/v1/apps", "setup-admin-key", body)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("create app: %d %s", w.Code, w.Body.String())
+		}
+		return decode(t, w)["api_key"].(string)
+	}
+	appKey := createApp(map[string]interface{}{"name": "Default"})
+	overrideKey := createApp(map[string]interface{}{"name": "Override", "rate_limit": map[string]interface{}{"burst": 4}})
+
+	// Requests are made in order, sharing buckets
+	tests := []struct {
+		name, method, path, key string
+		body                    interface{}
+		wantLimited             bool
+	}{
+		{"admin key", http.MethodGet, "/api/v1/apps", testAdminKey, nil, false},
+		{"admin key within burst", http.MethodGet, "/api/v1/groups", testAdminKey, nil, false},
+		{"admin key past burst", http.MethodGet, "/api/v1/apps", testAdminKey, nil, true},
+		{"other admin key", http.MethodGet, "/api/v1/apps", "second-admin-key", nil, false},
+		{"app unaffected by admin traffic", http.MethodGet, "/api/v1/ping", appKey, nil, false},
+		{"app API request", http.MethodGet, "/api/v1/crashes", appKey, nil, false},
+		{"crash submission shares the app's bucket", http.MethodPost, "/api/v1/crashes", appKey, testCrash(nil), true},
+		{"app API past burst", http.MethodGet, "/api/v1/ping", appKey, nil, true},
+		{"app override", http.MethodGet, "/api/v1/ping", overrideKey, nil, false},
+		{"app override within burst", http.MethodGet, "/api/v1/ping", overrideKey, nil, false},
+		{"app override within burst again", http.MethodGet, "/api/v1/crashes", overrideKey, nil, false},
+		{"app override crash submission", http.MethodPost, "/api/v1/crashes", overrideKey, testCrash(nil), false},
+		{"app override past burst", http.MethodGet, "/api/v1/ping", overrideKey, nil, true},
+		{"no credentials aren't throttled", http.MethodGet, "/api/v1/apps", "", nil, false},
+	}
+	for _, tt := range tests {
+		w := ts.do(tt.method, tt.path, tt.key, tt.body)
+		if limited := w.Code == http.StatusTooManyRequests; limited != tt.wantLimited {
+			t.Errorf("%s: status %d, want limited %v", tt.name, w.Code, tt.wantLimited)
+		}
+	}
+}
+
+func TestDecompressBody(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.Ingest.MaxDecompressedBytes = 64 * 1024
+	})
+	_, apiKey := ts.createApp(nil)
+
+	plain, err := json.Marshal(testCrash(nil))
+	if err != nil {
+		t.Fatalf("marshal crash: %v", err)
+	}
+	compress := func(newWriter func(w io.Writer) io.WriteCloser, data []byte) []byte {
+		var buf bytes.Buffer
+		w := newWriter(&buf)
+		w.Write(data)
+		w.Close()
+		return buf.Bytes()
+	}
+	gzipped := func(data []byte) []byte {
+		return compress(func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }, data)
+	}
+	zlibbed := compress(func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }, plain)
+	rawDeflate := compress(func(w io.Writer) io.WriteCloser {
+		fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+		return fw
+	}, plain)
+	// A small body that inflates past the limit
+	padded := bytes.Replace(plain, []byte(`"app_version"`), []byte(`"padding":"`+strings.Repeat("x", 100*1024)+`","app_version"`), 1)
+
+	tests := []struct {
+		name       string
+		encoding   string
+		body       []byte
+		wantStatus int
+		wantCode   string
+	}{
+		{"uncompressed", "", plain, http.StatusCreated, ""},
+		{"identity", "identity", plain, http.StatusCreated, ""},
+		{"gzip", "gzip", gzipped(plain), http.StatusCreated, ""},
+		{"x-gzip", "x-gzip", gzipped(plain), http.StatusCreated, ""},
+		{"encoding case", " GZIP ", gzipped(plain), http.StatusCreated, ""},
+		{"zlib deflate", "deflate", zlibbed, http.StatusCreated, ""},
+		{"raw deflate", "deflate", rawDeflate, http.StatusCreated, ""},
+		{"unsupported encoding", "br", plain, http.StatusUnsupportedMediaType, "UNSUPPORTED_CONTENT_ENCODING"},
+		{"not gzip", "gzip", plain, http.StatusBadRequest, ""},
+		{"over the limit", "gzip", gzipped(padded), http.StatusRequestEntityTooLarge, CodeBodyTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/crashes", bytes.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-API-Key", apiKey)
+			if tt.encoding != "" {
+				req.Header.Set("Content-Encoding", tt.encoding)
+			}
+			w := ts.serve(req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantCode != "" {
+				if code := decode(t, w)["code"]; code != tt.wantCode {
+					t.Errorf("code = %v, want %s", code, tt.wantCode)
+				}
+			}
+		})
+	}
+
+	// The fingerprint debugger takes compressed bodies too
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/debug/fingerprint", bytes.NewReader(gzipped(plain)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("X-API-Key", testAdminKey)
+	if w := ts.serve(req); w.Code != http.StatusOK {
+		t.Errorf("debug fingerprint: status %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit int
+		// running requests are held in the handler while one more is sent
+		running    int
+		wantStatus int
+	}{
+		{"under the limit", 3, 2, http.StatusOK},
+		{"at the limit", 2, 2, http.StatusTooManyRequests},
+		{"disabled", 0, 5, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started := make(chan struct{})
+			release := make(chan struct{})
+			router := gin.New()
+			// Both API versions share one limit, as in the server
+			limit := ConcurrencyLimit(tt.limit)
+			handler := func(c *gin.Context) {
+				if c.Query("hold") != "" {
+					started <- struct{}{}
+					<-release
+				}
+				c.Status(http.StatusOK)
+			}
+			router.POST("/api/v1/crashes", limit, handler)
+			router.POST("/api/v2/crashes", limit, handler)
+
+			var wg sync.WaitGroup
+			for i := 0; i < tt.running; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/v1/crashes?hold=1", nil))
+				}()
+				<-started
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v2/crashes", nil))
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if w.Code == http.StatusTooManyRequests && (w.Header().Get("Retry-After") != "1" || decode(t, w)["code"] != CodeRateLimited) {
+				t.Errorf("429 response = %v %s, want Retry-After 1 and code %s", w.Header(), w.Body.String(), CodeRateLimited)
+			}
+
+			// Finished requests free their slot
+			close(release)
+			wg.Wait()
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/crashes", nil))
+			if w.Code != http.StatusOK {
+				t.Errorf("status after release = %d, want 200", w.Code)
+			}
+		})
+	}
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	ts := newTestServer(t)
+	keys := map[string]string{}
+	for name, scopes := range map[string][]string{
+		"submit": {"submit"},
+		"read":   {"read"},
+		"manage": {"manage"},
+		"all":    nil,
+	} {
+		_, keys[name] = ts.createApp(map[string]interface{}{"name": name, "api_key_scopes": scopes})
+	}
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		body      interface{}
+		wantScope string
+	}{
+		{"submit crash", http.MethodPost, "/api/v1/crashes", testCrash(nil), "submit"},
+		{"list crashes", http.MethodGet, "/api/v1/crashes", nil, "read"},
+		{"list groups", http.MethodGet, "/api/v1/groups", nil, "read"},
+		{"list alerts", http.MethodGet, "/api/v1/alerts", nil, "read"},
+		{"update group", http.MethodPatch, "/api/v1/groups/missing", map[string]interface{}{"status": "resolved"}, "manage"},
+		{"delete crash", http.MethodDelete, "/api/v1/crashes/missing", nil, "manage"},
+	}
+	for _, tt := range tests {
+		for name, key := range keys {
+			t.Run(tt.name+" with "+name+" key", func(t *testing.T) {
+				w := ts.do(tt.method, tt.path, key, tt.body)
+				allowed := name == "all" || name == tt.wantScope
+				if !allowed {
+					if w.Code != http.StatusForbidden {
+						t.Fatalf("status = %d, want %d", w.Code, http.StatusForbidden)
+					}
+					resp := decode(t, w)
+					if resp["code"] != CodeInsufficientScope || resp["scope"] != tt.wantScope {
+						t.Errorf("response = %v, want %s for the %s scope", resp, CodeInsufficientScope, tt.wantScope)
+					}
+					return
+				}
+				if w.Code == http.StatusForbidden || w.Code == http.StatusUnauthorized {
+					t.Errorf("status = %d, want the request let through: %s", w.Code, w.Body.String())
+				}
+			})
+		}
+	}
+
+	// Admin keys aren't limited by scopes
+	if w := ts.do(http.MethodGet, "/api/v1/crashes", testAdminKey, nil); w.Code != http.StatusOK {
+		t.Errorf("admin key: status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestAPIKeyExpiry(t *testing.T) {
+	ts := newTestServer(t)
+	now := time.Now().UTC()
+
+	tests := []struct {
+		name       string
+		expiresAt  interface{}
+		wantStatus int
+	}{
+		{"no expiry", nil, http.StatusOK},
+		{"expires later", now.Add(time.Hour).Format(time.RFC3339), http.StatusOK},
+		{"expired", now.Add(-time.Hour).Format(time.RFC3339), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, key := ts.createApp(map[string]interface{}{"api_key_expires_at": tt.expiresAt})
+
+			for _, path := range []string{"/api/v1/ping", "/api/v1/crashes"} {
+				w := ts.do(http.MethodGet, path, key, nil)
+				if w.Code != tt.wantStatus {
+					t.Fatalf("GET %s: status = %d, want %d", path, w.Code, tt.wantStatus)
+				}
+				if tt.wantStatus == http.StatusUnauthorized {
+					if code := decode(t, w)["code"]; code != CodeAPIKeyExpired {
+						t.Errorf("GET %s: code = %v, want %s", path, code, CodeAPIKeyExpired)
+					}
+				}
+			}
+			w := ts.do(http.MethodPost, "/api/v1/crashes", key, testCrash(nil))
+			if accepted := w.Code == http.StatusCreated; accepted != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("submit crash: status = %d", w.Code)
+			}
+		})
+	}
+}
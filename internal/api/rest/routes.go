@@ -1,112 +1,348 @@
 package rest
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
 	"github.com/flakerimi/inceptor/internal/auth"
+	"github.com/flakerimi/inceptor/internal/config"
 	"github.com/flakerimi/inceptor/internal/core"
+	"github.com/flakerimi/inceptor/internal/ingest"
 	"github.com/flakerimi/inceptor/internal/storage"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/rs/zerolog/log"
 )
 
 // Server holds the REST API server
 type Server struct {
 	router      *gin.Engine
+	httpServer  *http.Server
 	handler     *Handler
 	authHandler *AuthHandler
 	authManager *auth.Manager
+	keyUsage    *core.APIKeyUsageTracker
+	cfg         *config.Config
 	version     string
+	// submissions bounds concurrent crash submissions across API versions
+	submissions gin.HandlerFunc
 }
 
-// NewServer creates a new REST API server
-func NewServer(repo storage.Repository, fileStore storage.FileStore, alerter *core.AlertManager, authManager *auth.Manager, adminKey string, version string) *Server {
+// NewServer creates a new REST API server that ingests crashes through
+// pipeline
+func NewServer(repo storage.Repository, fileStore storage.FileStore, alerter *core.AlertManager, pipeline *ingest.Pipeline, limiter *core.RateLimiter, keyUsage *core.APIKeyUsageTracker, authManager *auth.Manager, cfg *config.Config, version string) *Server {
 	gin.SetMode(gin.ReleaseMode)
 
 	router := gin.New()
-	handler := NewHandler(repo, fileStore, alerter)
+	handler := NewHandler(repo, fileStore, alerter, pipeline, limiter, cfg)
+	handler.sessions = authManager
+	handler.metrics = handler.newMetricsHandler()
 	authHandler := NewAuthHandler(authManager)
 
 	s := &Server{
 		router:      router,
+		httpServer:  &http.Server{Handler: router},
 		handler:     handler,
 		authHandler: authHandler,
 		authManager: authManager,
+		keyUsage:    keyUsage,
+		cfg:         cfg,
 		version:     version,
+		submissions: ConcurrencyLimit(cfg.Ingest.MaxConcurrentSubmissions),
 	}
 
-	s.setupRoutes(repo, adminKey)
+	s.setupRoutes(repo, cfg.Auth.AllAdminKeys())
 
 	return s
 }
 
 // setupRoutes configures all routes
-func (s *Server) setupRoutes(repo storage.Repository, adminKey string) {
+func (s *Server) setupRoutes(repo storage.Repository, adminKeys []string) {
 	// Middleware
 	s.router.Use(Recovery())
-	s.router.Use(CORS())
+	s.router.Use(RequestLogger(s.cfg.Logging.AccessLog))
+	s.router.Use(RequestMetrics(s.handler.requestDurations))
+	// Proxy login must only be called by the dashboard itself
+	s.router.Use(CORS("/api/v1/auth/proxy-login", "/api/v2/auth/proxy-login"))
 
 	// Serve embedded dashboard
-	ServeStatic(s.router)
+	ServeStatic(s.router, rootHandler(s.cfg.Server.Root, s.version))
+
+	// App API key requests share the app's crash submission bucket; admin
+	// keys and dashboard sessions get their own. Crash submission checks
+	// its limit in the handler, after rejecting disabled apps.
+	limit := RateLimit(s.handler.limiter, core.NewRateLimiter(s.cfg.Server.AdminRateLimit.Rate, s.cfg.Server.AdminRateLimit.Burst))
 
 	// Health check (no auth)
-	s.router.GET("/health", s.handler.Health)
-	s.router.GET("/ready", s.handler.Health)
+	getAndHead(s.router, "/health", s.handler.Health)
+	getAndHead(s.router, "/ready", s.handler.Ready)
 
-	// System endpoints
-	s.router.GET("/api/v1/system/version", s.handleGetVersion)
-	s.router.POST("/api/v1/system/update", APIKeyOrSessionAuth(repo, adminKey, s.authManager), AdminOnly(), s.handleSystemUpdate)
+	// Prometheus metrics; scrapers can pass the admin key as ?api_key=
+	getAndHead(s.router, "/metrics", APIKeyOrSessionAuth(repo, adminKeys, s.authManager, s.keyUsage), AdminOnly(), limit, s.handler.Metrics)
 
-	// API v1
+	// API v1, announced as deprecated once server.v1_deprecation is set
 	v1 := s.router.Group("/api/v1")
+	if deprecation := v1Deprecation(s.cfg.Server.V1Deprecation); deprecation != nil {
+		v1.Use(deprecation)
+	}
+	s.setupAPIRoutes(v1, repo, adminKeys, limit)
+
+	// API v2 shares v1's handlers for every endpoint whose schema hasn't
+	// changed
+	s.setupAPIRoutes(s.router.Group("/api/v2"), repo, adminKeys, limit)
+
+	s.setupAllowedMethods()
+}
+
+// setupAPIRoutes registers the API endpoints under one version's prefix
+func (s *Server) setupAPIRoutes(api *gin.RouterGroup, repo storage.Repository, adminKeys []string, limit gin.HandlerFunc) {
+	// System endpoints
+	getAndHead(api, "/system/version", s.handleGetVersion)
+	api.POST("/system/update", APIKeyOrSessionAuth(repo, adminKeys, s.authManager, s.keyUsage), AdminOnly(), limit, s.handleSystemUpdate)
+	api.POST("/system/dlq/reprocess", APIKeyOrSessionAuth(repo, adminKeys, s.authManager, s.keyUsage), AdminOnly(), limit, s.handler.ReprocessDeadLetters)
+	api.POST("/system/file-scan", APIKeyOrSessionAuth(repo, adminKeys, s.authManager, s.keyUsage), AdminOnly(), limit, s.handler.StartFileScan)
+	getAndHead(api, "/system/file-scan", APIKeyOrSessionAuth(repo, adminKeys, s.authManager, s.keyUsage), AdminOnly(), limit, s.handler.GetFileScan)
+
+	// Write endpoints only accept JSON bodies (crash submission also takes msgpack)
+	jsonOnly := RequireContentType(binding.MIMEJSON)
+	crashBody := RequireContentType(binding.MIMEJSON, binding.MIMEMSGPACK, binding.MIMEMSGPACK2)
+	// Crash bodies may also be gzip or deflate compressed
+	decompress := DecompressBody(s.cfg.Ingest.MaxDecompressedBytes)
 
 	// Auth routes (no auth required)
-	authGroup := v1.Group("/auth")
+	authGroup := api.Group("/auth")
 	{
-		authGroup.GET("/status", s.authHandler.Status)
-		authGroup.POST("/login", s.authHandler.Login)
+		getAndHead(authGroup, "/status", s.authHandler.Status)
+		authGroup.POST("/login", jsonOnly, s.authHandler.Login)
+		authGroup.POST("/proxy-login", SameOrigin(), s.authHandler.ProxyLogin)
 		authGroup.POST("/logout", s.authHandler.Logout)
 		// Change password requires valid session
-		authGroup.POST("/change-password", SessionAuth(s.authManager), s.authHandler.ChangePassword)
+		authGroup.POST("/change-password", SessionAuth(s.authManager), jsonOnly, s.authHandler.ChangePassword)
 	}
 
-	// Public crash submission endpoint (requires app API key)
-	v1.POST("/crashes", APIKeyAuth(repo, adminKey), s.handler.SubmitCrash)
+	// API key check for SDKs (requires app API key)
+	getAndHead(api, "/ping", APIKeyAuth(repo, adminKeys, s.keyUsage), limit, s.handler.Ping)
+
+	// Public crash submission endpoint (requires app API key). Excess
+	// concurrent submissions are shed before authenticating them.
+	api.POST("/crashes", s.submissions, APIKeyAuth(repo, adminKeys, s.keyUsage), RequireScope(core.ScopeSubmit), crashBody, decompress, s.handler.SubmitCrash)
 
-	// Authenticated routes (accepts session token OR API key)
-	authenticated := v1.Group("")
-	authenticated.Use(APIKeyOrSessionAuth(repo, adminKey, s.authManager))
+	// One-click alert links, authorized by their signed token. Opening the
+	// link only shows a confirmation form, which POSTs back to act.
+	api.GET("/groups/:id/resolve", s.handler.ConfirmResolveGroupByToken)
+	api.POST("/groups/:id/resolve", s.handler.ResolveGroupByToken)
+
+	// Authenticated routes (accepts session token OR API key). Reading
+	// takes the read scope; changes take the manage scope and aren't open
+	// to viewer sessions.
+	authenticated := api.Group("")
+	authenticated.Use(APIKeyOrSessionAuth(repo, adminKeys, s.authManager, s.keyUsage), limit)
+	reads := authenticated.Group("", RequireScope(core.ScopeRead))
+	writes := authenticated.Group("", WriteAccess(), RequireScope(core.ScopeManage))
 	{
 		// Crashes
-		authenticated.GET("/crashes", s.handler.ListCrashes)
-		authenticated.GET("/crashes/:id", s.handler.GetCrash)
-		authenticated.DELETE("/crashes/:id", s.handler.DeleteCrash)
+		getAndHead(reads, "/crashes", s.handler.ListCrashes)
+		getAndHead(reads, "/crashes/batch", s.handler.GetCrashBatch)
+		getAndHead(reads, "/crashes/export", s.handler.ExportCrashes)
+		getAndHead(reads, "/crashes/:id", s.handler.GetCrash)
+		getAndHead(reads, "/crashes/by-event/:eventID", s.handler.GetCrashByEventID)
+		writes.DELETE("/crashes/:id", s.handler.DeleteCrash)
 
 		// Groups
-		authenticated.GET("/groups", s.handler.ListGroups)
-		authenticated.GET("/groups/:id", s.handler.GetGroup)
-		authenticated.PATCH("/groups/:id", s.handler.UpdateGroup)
+		getAndHead(reads, "/groups", s.handler.ListGroups)
+		getAndHead(reads, "/groups/:id", s.handler.GetGroup)
+		writes.PATCH("/groups/:id", jsonOnly, s.handler.UpdateGroup)
+		writes.POST("/groups/:id/merge", jsonOnly, s.handler.MergeGroup)
+		writes.POST("/groups/:id/split", jsonOnly, s.handler.SplitGroup)
 
 		// App stats (app can access their own stats)
-		authenticated.GET("/apps/:id/stats", s.handler.GetAppStats)
+		getAndHead(reads, "/apps/:id/stats", s.handler.GetAppStats)
+		getAndHead(reads, "/apps/:id/summary", s.handler.GetAppSummary)
+		getAndHead(reads, "/apps/:id/compare-versions", s.handler.CompareVersions)
+		getAndHead(reads, "/apps/:id/users/:userID/crashes", s.handler.GetUserCrashes)
 
 		// Alerts
-		authenticated.GET("/alerts", s.handler.ListAlerts)
+		getAndHead(reads, "/alerts", s.handler.ListAlerts)
 	}
 
-	// Admin-only routes (accepts session token OR admin API key)
-	admin := v1.Group("")
-	admin.Use(APIKeyOrSessionAuth(repo, adminKey, s.authManager), AdminOnly())
+	// Admin-only routes (accepts admin session token OR admin API key)
+	admin := api.Group("")
+	admin.Use(APIKeyOrSessionAuth(repo, adminKeys, s.authManager, s.keyUsage), AdminOnly(), limit)
 	{
 		// App management
-		admin.POST("/apps", s.handler.CreateApp)
-		admin.GET("/apps", s.handler.ListApps)
-		admin.GET("/apps/:id", s.handler.GetApp)
-		admin.POST("/apps/:id/regenerate-key", s.handler.RegenerateAppKey)
+		admin.POST("/apps", jsonOnly, s.handler.CreateApp)
+		admin.POST("/apps/bulk", jsonOnly, s.handler.CreateAppsBulk)
+		getAndHead(admin, "/apps", s.handler.ListApps)
+		getAndHead(admin, "/apps/:id", s.handler.GetApp)
+		admin.PATCH("/apps/:id", jsonOnly, s.handler.UpdateApp)
+		admin.POST("/apps/:id/grouping-rules/preview", jsonOnly, s.handler.PreviewGroupingRules)
+		admin.POST("/apps/:id/regenerate-key", jsonOnly, s.handler.RegenerateAppKey)
+		getAndHead(admin, "/apps/:id/keys", s.handler.ListAPIKeys)
+		admin.PATCH("/apps/:id/keys/:keyID", jsonOnly, s.handler.UpdateAPIKey)
+		admin.DELETE("/apps/:id/keys/:keyID", s.handler.DeleteAPIKey)
+		getAndHead(admin, "/apps/:id/maintenance-windows", s.handler.ListMaintenanceWindows)
+		admin.POST("/apps/:id/maintenance-windows", jsonOnly, s.handler.CreateMaintenanceWindow)
+		admin.DELETE("/apps/:id/maintenance-windows/:windowID", s.handler.DeleteMaintenanceWindow)
+		admin.POST("/apps/:id/dsyms", s.handler.UploadDSYM)
+		admin.POST("/apps/:id/mappings", s.handler.UploadMapping)
+		admin.POST("/apps/:id/sourcemaps", s.handler.UploadSourceMap)
+		getAndHead(admin, "/apps/:id/symbols", s.handler.ListSymbols)
+		admin.DELETE("/apps/:id/symbols/:symbolID", s.handler.DeleteSymbol)
+
+		// Quarantine review; quarantined crashes are deleted with DELETE /crashes/:id
+		getAndHead(admin, "/crashes/quarantine", s.handler.ListQuarantinedCrashes)
+		admin.POST("/crashes/:id/release", s.handler.ReleaseCrash)
+		admin.POST("/crashes/:id/replay-alerts", s.handler.ReplayAlerts)
 
 		// Alert management
-		admin.POST("/alerts", s.handler.CreateAlert)
+		admin.POST("/alerts", jsonOnly, s.handler.CreateAlert)
 		admin.DELETE("/alerts/:id", s.handler.DeleteAlert)
+
+		// Dashboard accounts
+		getAndHead(admin, "/users", s.authHandler.ListUsers)
+		admin.POST("/users", jsonOnly, s.authHandler.CreateUser)
+
+		// Debugging
+		admin.POST("/debug/fingerprint", crashBody, decompress, s.handler.DebugFingerprint)
+	}
+}
+
+// v1Deprecation returns middleware announcing v1's deprecation, or nil when
+// no deprecation date is configured or the configured dates are invalid
+func v1Deprecation(cfg config.APIDeprecationConfig) gin.HandlerFunc {
+	if cfg.Date == "" {
+		return nil
+	}
+	deprecatedAt, err := time.Parse(time.RFC3339, cfg.Date)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid server.v1_deprecation.date, v1 deprecation headers disabled")
+		return nil
+	}
+	var sunset time.Time
+	if cfg.Sunset != "" {
+		if sunset, err = time.Parse(time.RFC3339, cfg.Sunset); err != nil {
+			log.Error().Err(err).Msg("Invalid server.v1_deprecation.sunset, v1 deprecation headers disabled")
+			return nil
+		}
+	}
+	return Deprecation(deprecatedAt, sunset, cfg.Link)
+}
+
+// Root modes
+const (
+	rootModeDashboard = "dashboard"
+	rootModeRedirect  = "redirect"
+	rootModeStatus    = "status"
+)
+
+// rootHandler returns the handler for "/" configured by server.root, or nil
+// to serve the dashboard there. An invalid setting is logged and the
+// dashboard is served.
+func rootHandler(cfg config.RootConfig, version string) gin.HandlerFunc {
+	switch cfg.Mode {
+	case "", rootModeDashboard:
+		return nil
+	case rootModeRedirect:
+		if cfg.RedirectURL == "" {
+			log.Error().Msg("server.root.redirect_url is not set, serving the dashboard at /")
+			return nil
+		}
+		return func(c *gin.Context) {
+			c.Redirect(http.StatusFound, cfg.RedirectURL)
+		}
+	case rootModeStatus:
+		return func(c *gin.Context) {
+			response := gin.H{
+				"name":      cfg.Name,
+				"status":    "ok",
+				"version":   version,
+				"timestamp": time.Now().UTC(),
+			}
+			if cfg.Message != "" {
+				response["message"] = cfg.Message
+			}
+			c.JSON(http.StatusOK, response)
+		}
+	default:
+		log.Error().Str("mode", cfg.Mode).Msg("Invalid server.root.mode, serving the dashboard at /")
+		return nil
 	}
 }
 
+// getAndHead registers handlers for GET and HEAD on a path. net/http drops
+// the body of HEAD responses, so GET handlers serve both unchanged.
+func getAndHead(r gin.IRoutes, path string, handlers ...gin.HandlerFunc) {
+	r.Match([]string{http.MethodGet, http.MethodHead}, path, handlers...)
+}
+
+// setupAllowedMethods answers OPTIONS on every registered path with an Allow
+// header listing its methods, and returns 405 with the same header when a
+// path exists but not for the request method. Must run after all routes
+// are registered.
+func (s *Server) setupAllowedMethods() {
+	methods := make(map[string][]string)
+	var paths []string
+	for _, route := range s.router.Routes() {
+		if _, ok := methods[route.Path]; !ok {
+			paths = append(paths, route.Path)
+		}
+		methods[route.Path] = append(methods[route.Path], route.Method)
+	}
+
+	allowed := make(map[string]string, len(paths))
+	for _, path := range paths {
+		allow := strings.Join(append(methods[path], http.MethodOptions), ", ")
+		allowed[path] = allow
+		s.router.OPTIONS(path, func(c *gin.Context) {
+			c.Header("Allow", allow)
+			c.AbortWithStatus(http.StatusNoContent)
+		})
+	}
+
+	s.router.HandleMethodNotAllowed = true
+	s.router.NoMethod(func(c *gin.Context) {
+		for _, path := range paths {
+			if matchRoutePath(path, c.Request.URL.Path) {
+				c.Header("Allow", allowed[path])
+				break
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusMethodNotAllowed, gin.H{
+			"error": "Method not allowed",
+			"code":  "METHOD_NOT_ALLOWED",
+		})
+	})
+}
+
+// matchRoutePath reports whether a request path matches a gin route pattern
+// with :param and *wildcard segments
+func matchRoutePath(pattern, path string) bool {
+	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+
+	for i, part := range patternParts {
+		if strings.HasPrefix(part, "*") {
+			return true
+		}
+		if i >= len(pathParts) {
+			return false
+		}
+		if strings.HasPrefix(part, ":") {
+			if pathParts[i] == "" {
+				return false
+			}
+			continue
+		}
+		if part != pathParts[i] {
+			return false
+		}
+	}
+	return len(patternParts) == len(pathParts)
+}
+
 // Router returns the Gin router
 func (s *Server) Router() *gin.Engine {
 	return s.router
@@ -114,5 +350,23 @@ func (s *Server) Router() *gin.Engine {
 
 // Run starts the server
 func (s *Server) Run(addr string) error {
-	return s.router.Run(addr)
+	s.httpServer.Addr = addr
+	if err := s.httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Shutdown stops accepting requests, waits for in-flight ones to finish,
+// then stores the crashes still queued for async ingestion. It returns
+// ctx's error if requests were still running when ctx ended.
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.httpServer.Shutdown(ctx)
+	s.handler.Close()
+	return err
+}
+
+// Close releases background resources held by the handlers
+func (s *Server) Close() {
+	s.handler.Close()
 }
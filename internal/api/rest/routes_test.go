@@ -0,0 +1,310 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flakerimi/inceptor/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+func TestHeadAndOptions(t *testing.T) {
+	ts := newTestServer(t)
+	_, apiKey := ts.createApp(nil)
+	crashID := ts.submitCrash(apiKey, testCrash(nil))["id"].(string)
+
+	// A real server, so HEAD bodies are dropped as they would be in production
+	srv := httptest.NewServer(ts.server.router)
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantAllow  string
+	}{
+		{"HEAD crash", http.MethodHead, "/api/v1/crashes/" + crashID, http.StatusOK, ""},
+		{"HEAD missing crash", http.MethodHead, "/api/v1/crashes/missing", http.StatusNotFound, ""},
+		{"HEAD crash list", http.MethodHead, "/api/v1/crashes", http.StatusOK, ""},
+		{"OPTIONS crash", http.MethodOptions, "/api/v1/crashes/" + crashID, http.StatusNoContent, "GET, HEAD, DELETE, OPTIONS"},
+		{"PUT crash", http.MethodPut, "/api/v1/crashes/" + crashID, http.StatusMethodNotAllowed, "GET, HEAD, DELETE, OPTIONS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, srv.URL+tt.path, nil)
+			req.Header.Set("X-API-Key", testAdminKey)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.method == http.MethodHead {
+				if len(body) != 0 {
+					t.Errorf("HEAD response has a %d byte body", len(body))
+				}
+				if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+					t.Errorf("Content-Type = %q, want the GET response's", ct)
+				}
+			}
+			if got := resp.Header.Get("Allow"); got != tt.wantAllow {
+				t.Errorf("Allow = %q, want %q", got, tt.wantAllow)
+			}
+		})
+	}
+}
+
+func TestMatchRoutePath(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"/api/v1/crashes/:id", "/api/v1/crashes/abc", true},
+		{"/api/v1/crashes/:id", "/api/v1/crashes/abc/", true},
+		{"/api/v1/crashes/:id", "/api/v1/crashes", false},
+		{"/api/v1/crashes/:id", "/api/v1/groups/abc", false},
+		{"/assets/*filepath", "/assets/js/app.js", true},
+		{"/health", "/health", true},
+	}
+	for _, tt := range tests {
+		if got := matchRoutePath(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("matchRoutePath(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+// freeAddr returns a local address nothing is listening on
+func freeAddr(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func TestServerShutdown(t *testing.T) {
+	tests := []struct {
+		name string
+		// inFlight is how long a request is still running at shutdown
+		inFlight time.Duration
+		wantErr  error
+	}{
+		{"idle", 0, nil},
+		{"request finishes in time", 100 * time.Millisecond, nil},
+		{"request outlives the deadline", 2 * time.Second, context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, func(cfg *config.Config) {
+				cfg.Ingest.Async.QueueSize = 10
+			})
+			_, apiKey := ts.createApp(nil)
+			started := make(chan struct{})
+			ts.server.router.GET("/slow", func(c *gin.Context) {
+				close(started)
+				time.Sleep(tt.inFlight)
+				c.Status(http.StatusNoContent)
+			})
+
+			addr := freeAddr(t)
+			ran := make(chan error, 1)
+			go func() { ran <- ts.server.Run(addr) }()
+			base := "http://" + addr
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				if resp, err := http.Get(base + "/health"); err == nil {
+					resp.Body.Close()
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatal("server did not start")
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			// Crashes queued for async ingestion are stored by Shutdown
+			var ids []string
+			for i := 0; i < 3; i++ {
+				req, _ := http.NewRequest(http.MethodPost, base+"/api/v1/crashes", jsonBody(t, testCrash(nil)))
+				req.Header.Set("Content-Type", "application/json")
+				req.Header.Set("X-API-Key", apiKey)
+				req.Header.Set("Prefer", "respond-async")
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatalf("submit: %v", err)
+				}
+				var body struct{ ID string }
+				json.NewDecoder(resp.Body).Decode(&body)
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusAccepted {
+					t.Fatalf("submit status = %d, want 202", resp.StatusCode)
+				}
+				ids = append(ids, body.ID)
+			}
+
+			slow := make(chan int, 1)
+			if tt.inFlight > 0 {
+				go func() {
+					resp, err := http.Get(base + "/slow")
+					if err != nil {
+						slow <- 0
+						return
+					}
+					resp.Body.Close()
+					slow <- resp.StatusCode
+				}()
+				<-started
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			if err := ts.server.Shutdown(ctx); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Shutdown = %v, want %v", err, tt.wantErr)
+			}
+			if err := <-ran; err != nil {
+				t.Errorf("Run = %v, want nil after Shutdown", err)
+			}
+			if tt.inFlight > 0 && tt.wantErr == nil {
+				if status := <-slow; status != http.StatusNoContent {
+					t.Errorf("in-flight request status = %d, want 204", status)
+				}
+			}
+			for _, id := range ids {
+				if crash, err := ts.repo.GetCrash(context.Background(), id); err != nil || crash == nil {
+					t.Errorf("queued crash %s = %v, %v; want it stored", id, crash, err)
+				}
+			}
+			if _, err := http.Get(base + "/health"); err == nil {
+				t.Error("server still accepting requests after Shutdown")
+			}
+		})
+	}
+}
+
+func TestAPIVersions(t *testing.T) {
+	const link = "https://docs.example.com/migrate-to-v2"
+	tests := []struct {
+		name        string
+		deprecation config.APIDeprecationConfig
+		// want are the v1 headers; v2 never sends them
+		wantDeprecation, wantSunset, wantLink string
+	}{
+		{"not deprecated", config.APIDeprecationConfig{}, "", "", ""},
+		{"deprecated", config.APIDeprecationConfig{Date: "2026-01-01T00:00:00Z"}, "@1767225600", "", ""},
+		{
+			"with sunset and link",
+			config.APIDeprecationConfig{Date: "2026-01-01T00:00:00Z", Sunset: "2026-07-01T12:00:00+02:00", Link: link},
+			"@1767225600", "Wed, 01 Jul 2026 10:00:00 GMT", "<" + link + `>; rel="deprecation"; type="text/html"`,
+		},
+		{"invalid date", config.APIDeprecationConfig{Date: "January 2026"}, "", "", ""},
+		{"invalid sunset", config.APIDeprecationConfig{Date: "2026-01-01T00:00:00Z", Sunset: "soon"}, "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, func(cfg *config.Config) {
+				cfg.Server.V1Deprecation = tt.deprecation
+			})
+			_, apiKey := ts.createApp(nil)
+
+			// Both versions serve the same data
+			w := ts.do(http.MethodPost, "/api/v2/crashes", apiKey, testCrash(nil))
+			if w.Code != http.StatusCreated {
+				t.Fatalf("v2 submission status = %d: %s", w.Code, w.Body.String())
+			}
+			id := decode(t, w)["id"].(string)
+
+			for _, version := range []string{"v1", "v2"} {
+				w := ts.do(http.MethodGet, "/api/"+version+"/crashes/"+id, apiKey, nil)
+				if w.Code != http.StatusOK {
+					t.Errorf("%s: status = %d, want 200", version, w.Code)
+				}
+				want := [3]string{}
+				if version == "v1" {
+					want = [3]string{tt.wantDeprecation, tt.wantSunset, tt.wantLink}
+				}
+				got := [3]string{w.Header().Get("Deprecation"), w.Header().Get("Sunset"), w.Header().Get("Link")}
+				if got != want {
+					t.Errorf("%s: Deprecation, Sunset, Link = %q, want %q", version, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRootPath(t *testing.T) {
+	tests := []struct {
+		name         string
+		root         config.RootConfig
+		wantStatus   int
+		wantLocation string
+		// wantJSON is the status mode's response, less its timestamp
+		wantJSON map[string]interface{}
+	}{
+		{"dashboard", config.RootConfig{Mode: "dashboard"}, 0, "", nil},
+		{"default", config.RootConfig{}, 0, "", nil},
+		{"redirect", config.RootConfig{Mode: "redirect", RedirectURL: "https://example.com/status"}, http.StatusFound, "https://example.com/status", nil},
+		{"redirect without a URL", config.RootConfig{Mode: "redirect"}, 0, "", nil},
+		{"status", config.RootConfig{Mode: "status", Name: "Acme Crashes", Message: "Internal use only"}, http.StatusOK, "", map[string]interface{}{
+			"name": "Acme Crashes", "status": "ok", "version": "test", "message": "Internal use only",
+		}},
+		{"status without a message", config.RootConfig{Mode: "status", Name: "Inceptor"}, http.StatusOK, "", map[string]interface{}{
+			"name": "Inceptor", "status": "ok", "version": "test",
+		}},
+		{"invalid mode", config.RootConfig{Mode: "blank"}, 0, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, func(cfg *config.Config) {
+				cfg.Server.Root = tt.root
+			})
+			// The dashboard's other pages are served in every mode
+			dashboard := ts.do(http.MethodGet, "/apps", "", nil)
+
+			w := ts.do(http.MethodGet, "/", "", nil)
+			if tt.wantStatus == 0 {
+				if w.Code != dashboard.Code || w.Body.String() != dashboard.Body.String() {
+					t.Errorf("/ = %d %q, want the dashboard's %d %q", w.Code, w.Body.String(), dashboard.Code, dashboard.Body.String())
+				}
+				return
+			}
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("Location = %q, want %q", got, tt.wantLocation)
+			}
+			if tt.wantJSON == nil {
+				return
+			}
+			got := decode(t, w)
+			if _, err := time.Parse(time.RFC3339Nano, got["timestamp"].(string)); err != nil {
+				t.Errorf("timestamp = %v: %v", got["timestamp"], err)
+			}
+			delete(got, "timestamp")
+			if !reflect.DeepEqual(got, tt.wantJSON) {
+				t.Errorf("response = %v, want %v", got, tt.wantJSON)
+			}
+			if head := ts.do(http.MethodHead, "/", "", nil); head.Code != http.StatusOK {
+				t.Errorf("HEAD status = %d, want 200", head.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package rest
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/flakerimi/inceptor/internal/sourcemap"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// UploadSourceMap stores the source map of one JavaScript bundle of an app
+// version for resolving its web crashes. The body is the .map file, or the
+// bundle itself when its source map is inline, sent raw or as the "file"
+// field of a multipart form. The version is given as app_version and the
+// bundle's file name as bundle, in the query or form; the bundle defaults to
+// the map's "file" field or the uploaded file's name. Crashes received
+// earlier are not resolved retroactively.
+func (h *Handler) UploadSourceMap(c *gin.Context) {
+	if h.sourceMaps == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Source maps are not enabled"})
+		return
+	}
+
+	app, err := h.repo.GetApp(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve app"})
+		return
+	}
+	if app == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+		return
+	}
+
+	if maxSize := h.cfg.Ingest.SourceMaps.MaxUploadBytes; maxSize > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize)
+	}
+
+	body := io.Reader(c.Request.Body)
+	appVersion, bundle, fileName := c.Query("app_version"), c.Query("bundle"), ""
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			abortInvalidCrashBody(c, err)
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read upload"})
+			return
+		}
+		defer file.Close()
+		body = file
+		fileName = header.Filename
+		if appVersion == "" {
+			appVersion = c.PostForm("app_version")
+		}
+		if bundle == "" {
+			bundle = c.PostForm("bundle")
+		}
+	}
+	appVersion = strings.TrimSpace(appVersion)
+	if appVersion == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "app_version is required"})
+		return
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		abortInvalidCrashBody(c, err)
+		return
+	}
+	mapData, err := sourcemap.Extract(data)
+	var m *sourcemap.Map
+	if err == nil {
+		m, err = sourcemap.Parse(mapData)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid source map", "details": err.Error()})
+		return
+	}
+
+	if bundle == "" {
+		bundle = m.File
+	}
+	if bundle == "" {
+		bundle = strings.TrimSuffix(fileName, ".map")
+	}
+	bundle = sourcemap.BundleName(bundle)
+	if bundle == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bundle is required"})
+		return
+	}
+
+	if err := h.sourceMaps.Save(app.ID, appVersion, bundle, mapData); err != nil {
+		log.Error().Err(err).Str("app_id", app.ID).Msg("Failed to save source map")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save source map"})
+		return
+	}
+	h.sourceMapResolver.Forget(app.ID, appVersion, bundle)
+
+	log.Info().Str("app_id", app.ID).Str("app_version", appVersion).Str("bundle", bundle).Msg("Source map uploaded")
+	c.JSON(http.StatusCreated, gin.H{"data": gin.H{
+		"app_version": appVersion,
+		"bundle":      bundle,
+		"sources":     m.Sources(),
+		"size_bytes":  len(mapData),
+	}})
+}
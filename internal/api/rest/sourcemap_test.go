@@ -0,0 +1,110 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"mime/multipart"
+	"net/http"
+	"testing"
+
+	"github.com/flakerimi/inceptor/internal/config"
+)
+
+// testSourceMap maps all of app.min.js line 1 to src/app.ts:1:1, named
+// handleClick
+const testSourceMap = `{"version":3,"file":"app.min.js","sources":["src/app.ts"],"names":["handleClick"],"mappings":"AAAAA"}`
+
+func TestUploadSourceMap(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.Ingest.SourceMaps.MaxUploadBytes = 64 * 1024
+	})
+	appID, apiKey := ts.createApp(nil)
+
+	form := func(fileName string, data []byte, fields map[string]string) (string, []byte) {
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		for k, v := range fields {
+			w.WriteField(k, v)
+		}
+		part, _ := w.CreateFormFile("file", fileName)
+		part.Write(data)
+		w.Close()
+		return w.FormDataContentType(), buf.Bytes()
+	}
+	noFile := []byte(`{"version":3,"sources":["src/vendor.ts"],"names":[],"mappings":"AAAA"}`)
+	formType, formBody := form("vendor.js.map", noFile, map[string]string{"app_version": "2.0.0"})
+	inline := []byte("!function(){}();\n//# sourceMappingURL=data:application/json;base64," + base64.StdEncoding.EncodeToString([]byte(testSourceMap)))
+
+	path := "/api/v1/apps/" + appID + "/sourcemaps"
+	tests := []struct {
+		name        string
+		path        string
+		key         string
+		contentType string
+		body        []byte
+		wantStatus  int
+		wantVersion string
+		wantBundle  string
+	}{
+		{"bundle from the map", path + "?app_version=1.0.0", testAdminKey, "application/json", []byte(testSourceMap), http.StatusCreated, "1.0.0", "app.min.js"},
+		{"bundle from the file name", path, testAdminKey, formType, formBody, http.StatusCreated, "2.0.0", "vendor.js"},
+		{"bundle given", path + "?app_version=1.0.0&bundle=https://cdn.example.com/main.js?v=2", testAdminKey, "application/json", []byte(testSourceMap), http.StatusCreated, "1.0.0", "main.js"},
+		{"inline map", path + "?app_version=3.0.0", testAdminKey, "application/javascript", inline, http.StatusCreated, "3.0.0", "app.min.js"},
+		{"no bundle", path + "?app_version=1.0.0", testAdminKey, "application/json", noFile, http.StatusBadRequest, "", ""},
+		{"no version", path, testAdminKey, "application/json", []byte(testSourceMap), http.StatusBadRequest, "", ""},
+		{"external map", path + "?app_version=1.0.0", testAdminKey, "application/javascript", []byte("//# sourceMappingURL=app.min.js.map"), http.StatusBadRequest, "", ""},
+		{"invalid map", path + "?app_version=1.0.0", testAdminKey, "application/json", []byte(`{"version":2}`), http.StatusBadRequest, "", ""},
+		{"too large", path + "?app_version=1.0.0", testAdminKey, "application/json", bytes.Repeat([]byte(" "), 65*1024), http.StatusRequestEntityTooLarge, "", ""},
+		{"unknown app", "/api/v1/apps/missing/sourcemaps?app_version=1.0.0", testAdminKey, "application/json", []byte(testSourceMap), http.StatusNotFound, "", ""},
+		{"app key", path + "?app_version=1.0.0", apiKey, "application/json", []byte(testSourceMap), http.StatusForbidden, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ts.doRaw(http.MethodPost, tt.path, tt.key, tt.contentType, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusCreated {
+				return
+			}
+			data, _ := decode(t, w)["data"].(map[string]interface{})
+			if data["app_version"] != tt.wantVersion || data["bundle"] != tt.wantBundle || data["sources"] != float64(1) {
+				t.Errorf("data = %v", data)
+			}
+		})
+	}
+
+	// Crashes of a version with source maps are resolved
+	resp := ts.submitCrash(apiKey, testCrash(map[string]interface{}{
+		"platform":    "web",
+		"app_version": "1.0.0",
+		"stack_trace": []map[string]interface{}{
+			{"file_name": "https://cdn.example.com/app.min.js", "method_name": "t", "line_number": 1, "column_number": 120},
+			{"file_name": "https://cdn.example.com/app.min.js", "method_name": "e", "line_number": 1, "column_number": 30},
+		},
+	}))
+	crash := decode(t, ts.do(http.MethodGet, "/api/v1/crashes/"+resp["id"].(string), testAdminKey, nil))
+	frames, _ := crash["stack_trace"].([]interface{})
+	if len(frames) != 2 {
+		t.Fatalf("stack trace = %v", crash["stack_trace"])
+	}
+	frame := frames[0].(map[string]interface{})
+	if frame["file_name"] != "src/app.ts" || frame["method_name"] != "handleClick" || frame["line_number"] != float64(1) {
+		t.Errorf("frame = %v, want handleClick in src/app.ts at line 1", frame)
+	}
+	if crash["symbolicated"] != true {
+		t.Errorf("symbolicated = %v, want true", crash["symbolicated"])
+	}
+}
+
+func TestUploadSourceMapDisabled(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.Ingest.SourceMaps.Enabled = false
+	})
+	appID, _ := ts.createApp(nil)
+
+	w := ts.doRaw(http.MethodPost, "/api/v1/apps/"+appID+"/sourcemaps?app_version=1.0.0", testAdminKey, "application/json", []byte(testSourceMap))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404 with source maps disabled", w.Code)
+	}
+}
@@ -0,0 +1,184 @@
+package rest
+
+import (
+	"encoding/base64"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flakerimi/inceptor/internal/dsym"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// Symbol artifact types
+const (
+	symbolTypeDSYM      = "dsym"
+	symbolTypeMapping   = "proguard"
+	symbolTypeSourceMap = "sourcemap"
+)
+
+// symbolArtifact is an uploaded symbolication file: a dSYM debug image,
+// ProGuard mapping or source map
+type symbolArtifact struct {
+	// ID is the type followed by the artifact's key, e.g.
+	// "proguard.<base64 app version>"; it is stable across uploads
+	// replacing the file
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	UUID       string    `json:"uuid,omitempty"`
+	AppVersion string    `json:"app_version,omitempty"`
+	Bundle     string    `json:"bundle,omitempty"`
+	SizeBytes  int64     `json:"size_bytes"`
+	UploadedAt time.Time `json:"uploaded_at"`
+}
+
+// encodeSymbolKey encodes a version or bundle name for a symbol ID
+func encodeSymbolKey(s string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+// ListSymbols lists an app's uploaded dSYMs, mappings and source maps,
+// newest first, with their total size. Pass type to list one kind only.
+func (h *Handler) ListSymbols(c *gin.Context) {
+	app, err := h.repo.GetApp(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve app"})
+		return
+	}
+	if app == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+		return
+	}
+
+	kind := c.Query("type")
+	switch kind {
+	case "", symbolTypeDSYM, symbolTypeMapping, symbolTypeSourceMap:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type", "details": "type must be dsym, proguard or sourcemap"})
+		return
+	}
+
+	artifacts := make([]symbolArtifact, 0)
+	if h.dsyms != nil && (kind == "" || kind == symbolTypeDSYM) {
+		images, err := h.dsyms.List(app.ID)
+		if err != nil {
+			log.Error().Err(err).Str("app_id", app.ID).Msg("Failed to list dSYMs")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list symbols"})
+			return
+		}
+		for _, image := range images {
+			artifacts = append(artifacts, symbolArtifact{
+				ID:         symbolTypeDSYM + "." + image.UUID,
+				Type:       symbolTypeDSYM,
+				UUID:       image.UUID,
+				SizeBytes:  image.Size,
+				UploadedAt: image.UploadedAt,
+			})
+		}
+	}
+	if h.mappings != nil && (kind == "" || kind == symbolTypeMapping) {
+		mappings, err := h.mappings.List(app.ID)
+		if err != nil {
+			log.Error().Err(err).Str("app_id", app.ID).Msg("Failed to list mappings")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list symbols"})
+			return
+		}
+		for _, mapping := range mappings {
+			artifacts = append(artifacts, symbolArtifact{
+				ID:         symbolTypeMapping + "." + encodeSymbolKey(mapping.AppVersion),
+				Type:       symbolTypeMapping,
+				AppVersion: mapping.AppVersion,
+				SizeBytes:  mapping.Size,
+				UploadedAt: mapping.UploadedAt,
+			})
+		}
+	}
+	if h.sourceMaps != nil && (kind == "" || kind == symbolTypeSourceMap) {
+		maps, err := h.sourceMaps.List(app.ID)
+		if err != nil {
+			log.Error().Err(err).Str("app_id", app.ID).Msg("Failed to list source maps")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list symbols"})
+			return
+		}
+		for _, m := range maps {
+			artifacts = append(artifacts, symbolArtifact{
+				ID:         symbolTypeSourceMap + "." + encodeSymbolKey(m.AppVersion) + "." + encodeSymbolKey(m.Bundle),
+				Type:       symbolTypeSourceMap,
+				AppVersion: m.AppVersion,
+				Bundle:     m.Bundle,
+				SizeBytes:  m.Size,
+				UploadedAt: m.UploadedAt,
+			})
+		}
+	}
+
+	sort.SliceStable(artifacts, func(i, j int) bool {
+		return artifacts[i].UploadedAt.After(artifacts[j].UploadedAt)
+	})
+	var total int64
+	for _, artifact := range artifacts {
+		total += artifact.SizeBytes
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":        artifacts,
+		"total":       len(artifacts),
+		"total_bytes": total,
+	})
+}
+
+// DeleteSymbol removes one of an app's uploaded symbolication files by the
+// ID ListSymbols reports. Crashes received afterwards are no longer
+// symbolicated with it.
+func (h *Handler) DeleteSymbol(c *gin.Context) {
+	app, err := h.repo.GetApp(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve app"})
+		return
+	}
+	if app == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+		return
+	}
+
+	kind, key, _ := strings.Cut(c.Param("symbolID"), ".")
+	var deleted bool
+	switch kind {
+	case symbolTypeDSYM:
+		if h.dsyms != nil {
+			if deleted, err = h.dsyms.Delete(app.ID, key); deleted {
+				h.symbolicator.Forget(app.ID, []dsym.Image{{UUID: key}})
+			}
+		}
+	case symbolTypeMapping:
+		version, decodeErr := base64.RawURLEncoding.DecodeString(key)
+		if h.mappings != nil && decodeErr == nil {
+			if deleted, err = h.mappings.Delete(app.ID, string(version)); deleted {
+				h.retracer.Forget(app.ID, string(version))
+			}
+		}
+	case symbolTypeSourceMap:
+		encodedVersion, encodedBundle, _ := strings.Cut(key, ".")
+		version, versionErr := base64.RawURLEncoding.DecodeString(encodedVersion)
+		bundle, bundleErr := base64.RawURLEncoding.DecodeString(encodedBundle)
+		if h.sourceMaps != nil && versionErr == nil && bundleErr == nil && len(bundle) > 0 {
+			if deleted, err = h.sourceMaps.Delete(app.ID, string(version), string(bundle)); deleted {
+				h.sourceMapResolver.Forget(app.ID, string(version), string(bundle))
+			}
+		}
+	}
+	if err != nil {
+		log.Error().Err(err).Str("app_id", app.ID).Str("symbol_id", c.Param("symbolID")).Msg("Failed to delete symbol")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete symbol"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Symbol not found"})
+		return
+	}
+
+	log.Info().Str("app_id", app.ID).Str("symbol_id", c.Param("symbolID")).Msg("Symbol deleted")
+	c.JSON(http.StatusOK, gin.H{"message": "Symbol deleted"})
+}
@@ -0,0 +1,153 @@
+package rest
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestListSymbols(t *testing.T) {
+	ts := newTestServer(t)
+	appID, apiKey := ts.createApp(nil)
+	otherID, _ := ts.createApp(map[string]interface{}{"name": "Other App"})
+
+	uploads := []struct {
+		path        string
+		contentType string
+		body        []byte
+	}{
+		{"/dsyms", "application/octet-stream", testDSYM},
+		{"/mappings?app_version=1.0.0", "text/plain", []byte(testMappingFile)},
+		{"/sourcemaps?app_version=1.0.0", "application/json", []byte(testSourceMap)},
+	}
+	for _, u := range uploads {
+		if w := ts.doRaw(http.MethodPost, "/api/v1/apps/"+appID+u.path, testAdminKey, u.contentType, u.body); w.Code != http.StatusCreated {
+			t.Fatalf("upload %s: status %d: %s", u.path, w.Code, w.Body.String())
+		}
+	}
+
+	dsymID := "dsym." + testDSYMUUID
+	mappingID := "proguard." + encodeSymbolKey("1.0.0")
+	sourceMapID := "sourcemap." + encodeSymbolKey("1.0.0") + "." + encodeSymbolKey("app.min.js")
+	wantBytes := int64(len(testDSYM) + len(testMappingFile) + len(testSourceMap))
+
+	path := "/api/v1/apps/" + appID + "/symbols"
+	tests := []struct {
+		name       string
+		path       string
+		key        string
+		wantStatus int
+		wantIDs    []string
+	}{
+		{"all", path, testAdminKey, http.StatusOK, []string{dsymID, mappingID, sourceMapID}},
+		{"dSYMs", path + "?type=dsym", testAdminKey, http.StatusOK, []string{dsymID}},
+		{"mappings", path + "?type=proguard", testAdminKey, http.StatusOK, []string{mappingID}},
+		{"source maps", path + "?type=sourcemap", testAdminKey, http.StatusOK, []string{sourceMapID}},
+		{"other app", "/api/v1/apps/" + otherID + "/symbols", testAdminKey, http.StatusOK, nil},
+		{"invalid type", path + "?type=pdb", testAdminKey, http.StatusBadRequest, nil},
+		{"unknown app", "/api/v1/apps/missing/symbols", testAdminKey, http.StatusNotFound, nil},
+		{"app key", path, apiKey, http.StatusForbidden, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ts.do(http.MethodGet, tt.path, tt.key, nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			resp := decode(t, w)
+			data, _ := resp["data"].([]interface{})
+			ids := make([]string, 0, len(data))
+			for _, d := range data {
+				ids = append(ids, d.(map[string]interface{})["id"].(string))
+			}
+			slices.Sort(ids)
+			want := slices.Clone(tt.wantIDs)
+			slices.Sort(want)
+			if !slices.Equal(ids, want) {
+				t.Errorf("IDs = %v, want %v", ids, want)
+			}
+			if resp["total"] != float64(len(tt.wantIDs)) {
+				t.Errorf("total = %v, want %d", resp["total"], len(tt.wantIDs))
+			}
+		})
+	}
+
+	resp := decode(t, ts.do(http.MethodGet, path, testAdminKey, nil))
+	if resp["total_bytes"] != float64(wantBytes) {
+		t.Errorf("total_bytes = %v, want %d", resp["total_bytes"], wantBytes)
+	}
+}
+
+func TestDeleteSymbol(t *testing.T) {
+	ts := newTestServer(t)
+	appID, apiKey := ts.createApp(nil)
+	for _, u := range []struct{ path, contentType, body string }{
+		{"/dsyms", "application/octet-stream", string(testDSYM)},
+		{"/mappings?app_version=1.0.0", "text/plain", testMappingFile},
+		{"/sourcemaps?app_version=1.0.0", "application/json", testSourceMap},
+		// Kept, so the app still has source maps after the delete
+		{"/sourcemaps?app_version=1.0.0&bundle=vendor.js", "application/json", testSourceMap},
+	} {
+		if w := ts.doRaw(http.MethodPost, "/api/v1/apps/"+appID+u.path, testAdminKey, u.contentType, []byte(u.body)); w.Code != http.StatusCreated {
+			t.Fatalf("upload %s: status %d: %s", u.path, w.Code, w.Body.String())
+		}
+	}
+
+	// submitCrash reports whether a minified web crash was resolved
+	submitCrash := func() bool {
+		resp := ts.submitCrash(apiKey, testCrash(map[string]interface{}{
+			"platform":    "web",
+			"app_version": "1.0.0",
+			"stack_trace": []map[string]interface{}{
+				{"file_name": "https://cdn.example.com/app.min.js", "method_name": "t", "line_number": 1, "column_number": 120},
+			},
+		}))
+		crash := decode(t, ts.do(http.MethodGet, "/api/v1/crashes/"+resp["id"].(string), testAdminKey, nil))
+		return crash["symbolicated"] == true
+	}
+	if !submitCrash() {
+		t.Fatal("crash before deleting the source map was not symbolicated")
+	}
+
+	path := "/api/v1/apps/" + appID + "/symbols/"
+	sourceMapID := "sourcemap." + encodeSymbolKey("1.0.0") + "." + encodeSymbolKey("app.min.js")
+	tests := []struct {
+		name       string
+		path       string
+		key        string
+		wantStatus int
+	}{
+		{"app key", path + "dsym." + testDSYMUUID, apiKey, http.StatusForbidden},
+		{"unknown app", "/api/v1/apps/missing/symbols/dsym." + testDSYMUUID, testAdminKey, http.StatusNotFound},
+		{"dSYM", path + "dsym." + testDSYMUUID, testAdminKey, http.StatusOK},
+		{"dSYM again", path + "dsym." + testDSYMUUID, testAdminKey, http.StatusNotFound},
+		{"mapping", path + "proguard." + encodeSymbolKey("1.0.0"), testAdminKey, http.StatusOK},
+		{"other version", path + "proguard." + encodeSymbolKey("2.0.0"), testAdminKey, http.StatusNotFound},
+		{"source map", path + sourceMapID, testAdminKey, http.StatusOK},
+		{"source map again", path + sourceMapID, testAdminKey, http.StatusNotFound},
+		{"bad encoding", path + "proguard.!!!", testAdminKey, http.StatusNotFound},
+		{"no bundle", path + "sourcemap." + encodeSymbolKey("1.0.0"), testAdminKey, http.StatusNotFound},
+		{"unknown type", path + "pdb.abc", testAdminKey, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ts.do(http.MethodDelete, tt.path, tt.key, nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+
+	if resp := decode(t, ts.do(http.MethodGet, "/api/v1/apps/"+appID+"/symbols", testAdminKey, nil)); resp["total"] != float64(1) {
+		t.Errorf("symbols after the deletes = %v, want only vendor.js", resp["data"])
+	}
+
+	// Crashes received afterwards are left as they are, even though the
+	// source map was cached
+	if submitCrash() {
+		t.Error("crash after deleting the source map was symbolicated")
+	}
+}
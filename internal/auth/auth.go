@@ -2,149 +2,462 @@
 package auth
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/flakerimi/inceptor/internal/core"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/bcrypt"
 )
 
 const DefaultPassword = "inceptor"
 
-// Session represents an authenticated session
-type Session struct {
-	Token     string
-	CreatedAt time.Time
-	ExpiresAt time.Time
+// DefaultUsername is the admin account created on first run
+const DefaultUsername = "admin"
+
+// MinPasswordLength is the shortest password accepted for an account
+const MinPasswordLength = 4
+
+var (
+	// ErrUsernameTaken means an account with the username already exists
+	ErrUsernameTaken = errors.New("username already taken")
+	// ErrInvalidUser means the username, password or role is unacceptable
+	ErrInvalidUser = errors.New("username is required, password must be at least 4 characters and role admin or viewer")
+)
+
+// UserStore persists dashboard accounts
+type UserStore interface {
+	CreateUser(ctx context.Context, user *core.User) error
+	GetUser(ctx context.Context, id string) (*core.User, error)
+	GetUserByUsername(ctx context.Context, username string) (*core.User, error)
+	ListUsers(ctx context.Context) ([]*core.User, error)
+	UpdateUserPassword(ctx context.Context, id, passwordHash string, mustChange bool) error
 }
 
 // Manager handles authentication and sessions
 type Manager struct {
-	passwordHash    string
-	isDefaultPassword bool
-	sessions        map[string]*Session
-	mu              sync.RWMutex
-	onPasswordChange func(hash string) // callback to persist password
+	users        UserStore
+	bcryptCost   int
+	sessions     SessionStore
+	mu           sync.RWMutex
+	now          func() time.Time // clock, replaceable in tests
+	trustedProxy *TrustedProxy
+	stopCleanup  chan struct{}
+	cleanupWG    sync.WaitGroup
+}
+
+// NewManager creates a new auth manager whose accounts are kept in users
+// and sessions in sessions, or in memory if sessions is nil
+func NewManager(users UserStore, sessions SessionStore) *Manager {
+	if sessions == nil {
+		sessions = NewMemorySessionStore()
+	}
+	return &Manager{
+		users:      users,
+		bcryptCost: bcrypt.DefaultCost,
+		sessions:   sessions,
+		now:        time.Now,
+	}
+}
+
+// SetBcryptCost sets the work factor of new password hashes. Costs outside
+// bcrypt's range are ignored.
+func (m *Manager) SetBcryptCost(cost int) {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bcryptCost = cost
+}
+
+func (m *Manager) cost() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.bcryptCost
 }
 
-// NewManager creates a new auth manager
-func NewManager(passwordHash string, onPasswordChange func(hash string)) *Manager {
-	m := &Manager{
-		sessions:         make(map[string]*Session),
-		onPasswordChange: onPasswordChange,
+// Bootstrap creates the DefaultUsername admin account when there are no
+// accounts yet. It takes over legacyHash, the shared dashboard password
+// from before accounts existed, or else gets DefaultPassword, which must be
+// changed at first login.
+func (m *Manager) Bootstrap(ctx context.Context, legacyHash string) error {
+	users, err := m.users.ListUsers(ctx)
+	if err != nil {
+		return err
+	}
+	if len(users) > 0 {
+		return nil
 	}
 
-	if passwordHash == "" {
-		// No password set, use default
-		m.passwordHash = HashPassword(DefaultPassword)
-		m.isDefaultPassword = true
-	} else {
-		m.passwordHash = passwordHash
-		m.isDefaultPassword = passwordHash == HashPassword(DefaultPassword)
+	hash := legacyHash
+	if hash == "" {
+		if hash, err = HashPassword(DefaultPassword, m.cost()); err != nil {
+			return err
+		}
 	}
+	return m.users.CreateUser(ctx, &core.User{
+		ID:                 uuid.New().String(),
+		Username:           DefaultUsername,
+		PasswordHash:       hash,
+		Role:               core.UserRoleAdmin,
+		MustChangePassword: ValidatePassword(DefaultPassword, hash),
+		CreatedAt:          m.now().UTC(),
+	})
+}
+
+// bcryptMarker starts every bcrypt hash ($2a$, $2b$ or $2y$ followed by the
+// cost). Stored hashes without it are legacy unsalted SHA256 hex digests.
+const bcryptMarker = "$2"
 
-	return m
+// HashPassword hashes a password using bcrypt with the given cost
+func HashPassword(password string, cost int) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
 }
 
-// HashPassword hashes a password using SHA256
-func HashPassword(password string) string {
+// IsLegacyHash reports whether a stored hash is a legacy SHA256 digest
+func IsLegacyHash(hash string) bool {
+	return !strings.HasPrefix(hash, bcryptMarker)
+}
+
+// legacyHashPassword hashes a password the way passwords were stored before
+// bcrypt, for checking those hashes only
+func legacyHashPassword(password string) string {
 	hash := sha256.Sum256([]byte(password))
 	return hex.EncodeToString(hash[:])
 }
 
-// ValidatePassword checks if the password matches the stored hash
-func (m *Manager) ValidatePassword(password string) bool {
-	return HashPassword(password) == m.passwordHash
+// ValidatePassword checks if the password matches the stored hash, which
+// may be bcrypt or legacy SHA256
+func ValidatePassword(password, hash string) bool {
+	if IsLegacyHash(hash) {
+		return subtle.ConstantTimeCompare([]byte(legacyHashPassword(password)), []byte(hash)) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// needsRehash reports whether a stored hash should be replaced by a bcrypt
+// hash of the given cost
+func needsRehash(hash string, cost int) bool {
+	if IsLegacyHash(hash) {
+		return true
+	}
+	hashCost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || hashCost != cost
+}
+
+// MatchAdminKey reports whether key matches any of the configured admin keys.
+// Every key is compared in constant time so the result doesn't leak which key
+// (if any) was close.
+func MatchAdminKey(key string, adminKeys []string) bool {
+	if key == "" {
+		return false
+	}
+	match := 0
+	for _, adminKey := range adminKeys {
+		if adminKey == "" {
+			continue
+		}
+		match |= subtle.ConstantTimeCompare([]byte(key), []byte(adminKey))
+	}
+	return match == 1
+}
+
+// Authenticate returns the user with the username and password, or nil if
+// they don't match an account. A legacy SHA256 hash, or a bcrypt hash of
+// another cost, is replaced with a current one once the password is known.
+func (m *Manager) Authenticate(ctx context.Context, username, password string) (*core.User, error) {
+	user, err := m.users.GetUserByUsername(ctx, username)
+	if err != nil || user == nil {
+		return nil, err
+	}
+	if !ValidatePassword(password, user.PasswordHash) {
+		return nil, nil
+	}
+
+	if cost := m.cost(); needsRehash(user.PasswordHash, cost) {
+		// The login succeeds either way; the upgrade is retried next time
+		hash, err := HashPassword(password, cost)
+		if err == nil {
+			err = m.users.UpdateUserPassword(ctx, user.ID, hash, user.MustChangePassword)
+		}
+		if err != nil {
+			log.Error().Err(err).Str("user", user.Username).Msg("Failed to upgrade password hash")
+		} else {
+			user.PasswordHash = hash
+		}
+	}
+	return user, nil
+}
+
+// CreateUser adds an account with the given password and role
+func (m *Manager) CreateUser(ctx context.Context, username, password string, role core.UserRole) (*core.User, error) {
+	username = strings.TrimSpace(username)
+	if username == "" || len(password) < MinPasswordLength || !role.Valid() {
+		return nil, ErrInvalidUser
+	}
+
+	existing, err := m.users.GetUserByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrUsernameTaken
+	}
+
+	hash, err := HashPassword(password, m.cost())
+	if err != nil {
+		return nil, err
+	}
+
+	user := &core.User{
+		ID:           uuid.New().String(),
+		Username:     username,
+		PasswordHash: hash,
+		Role:         role,
+		CreatedAt:    m.now().UTC(),
+	}
+	if err := m.users.CreateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// ListUsers returns all accounts
+func (m *Manager) ListUsers(ctx context.Context) ([]*core.User, error) {
+	return m.users.ListUsers(ctx)
+}
+
+// User returns the account with the username, or nil if there is none
+func (m *Manager) User(ctx context.Context, username string) (*core.User, error) {
+	return m.users.GetUserByUsername(ctx, username)
 }
 
-// NeedsPasswordChange returns true if using default password
-func (m *Manager) NeedsPasswordChange() bool {
-	return m.isDefaultPassword
+// NeedsPasswordChange returns true while the bootstrap admin account still
+// has the default password
+func (m *Manager) NeedsPasswordChange(ctx context.Context) bool {
+	user, err := m.users.GetUserByUsername(ctx, DefaultUsername)
+	return err == nil && user != nil && user.MustChangePassword
 }
 
-// CreateSession creates a new session for authenticated user
-func (m *Manager) CreateSession() (*Session, error) {
+// CreateSession creates a new session for an authenticated user
+func (m *Manager) CreateSession(ctx context.Context, user *core.User) (*core.Session, error) {
 	token := make([]byte, 32)
 	if _, err := rand.Read(token); err != nil {
 		return nil, err
 	}
 
-	session := &Session{
+	now := m.now().UTC()
+	session := &core.Session{
 		Token:     hex.EncodeToString(token),
-		CreatedAt: time.Now(),
-		ExpiresAt: time.Now().Add(24 * time.Hour), // 24 hour sessions
+		UserID:    user.ID,
+		Username:  user.Username,
+		Role:      user.Role,
+		CreatedAt: now,
+		ExpiresAt: now.Add(24 * time.Hour), // 24 hour sessions
 	}
+	session.TokenHash = hashToken(session.Token)
 
-	m.mu.Lock()
-	m.sessions[session.Token] = session
-	m.mu.Unlock()
-
+	if err := m.sessions.CreateSession(ctx, session); err != nil {
+		return nil, err
+	}
 	return session, nil
 }
 
-// ValidateSession checks if a session token is valid
-func (m *Manager) ValidateSession(token string) bool {
+// Session returns the session for a token, or nil if it is unknown or
+// expired. Store errors are logged and treated as an unknown token.
+func (m *Manager) Session(ctx context.Context, token string) *core.Session {
 	if token == "" {
-		return false
+		return nil
 	}
 
-	m.mu.RLock()
-	session, exists := m.sessions[token]
-	m.mu.RUnlock()
-
-	if !exists {
-		return false
+	tokenHash := hashToken(token)
+	session, err := m.sessions.GetSession(ctx, tokenHash)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to look up session")
+		return nil
+	}
+	if session == nil {
+		return nil
 	}
 
-	if time.Now().After(session.ExpiresAt) {
-		m.DeleteSession(token)
-		return false
+	if m.now().After(session.ExpiresAt) {
+		m.DeleteSession(ctx, token)
+		return nil
 	}
 
-	return true
+	return session
 }
 
-// ChangePassword updates the password
-func (m *Manager) ChangePassword(oldPassword, newPassword string) bool {
-	if !m.ValidatePassword(oldPassword) {
-		return false
+// ValidateSession checks if a session token is valid
+func (m *Manager) ValidateSession(ctx context.Context, token string) bool {
+	return m.Session(ctx, token) != nil
+}
+
+// ChangePassword updates a user's password. It returns false if the old
+// password is wrong or the new one too short.
+func (m *Manager) ChangePassword(ctx context.Context, userID, oldPassword, newPassword string) (bool, error) {
+	user, err := m.users.GetUser(ctx, userID)
+	if err != nil || user == nil {
+		return false, err
 	}
-	if newPassword == "" || len(newPassword) < 4 {
-		return false
+	if !ValidatePassword(oldPassword, user.PasswordHash) {
+		return false, nil
 	}
-
-	m.passwordHash = HashPassword(newPassword)
-	m.isDefaultPassword = false
-
-	// Persist the new password hash
-	if m.onPasswordChange != nil {
-		m.onPasswordChange(m.passwordHash)
+	if len(newPassword) < MinPasswordLength {
+		return false, nil
 	}
 
-	return true
+	hash, err := HashPassword(newPassword, m.cost())
+	if err != nil {
+		return false, err
+	}
+	if err := m.users.UpdateUserPassword(ctx, user.ID, hash, false); err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
 // DeleteSession removes a session
-func (m *Manager) DeleteSession(token string) {
-	m.mu.Lock()
-	delete(m.sessions, token)
-	m.mu.Unlock()
+func (m *Manager) DeleteSession(ctx context.Context, token string) {
+	if err := m.sessions.DeleteSession(ctx, hashToken(token)); err != nil {
+		log.Error().Err(err).Msg("Failed to delete session")
+	}
 }
 
-// GetPasswordHash returns the current password hash
-func (m *Manager) GetPasswordHash() string {
-	return m.passwordHash
+// ActiveSessions returns the number of sessions that haven't expired
+func (m *Manager) ActiveSessions(ctx context.Context) int {
+	active, err := m.sessions.CountActiveSessions(ctx, m.now().UTC())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to count sessions")
+	}
+	return active
 }
 
 // CleanupExpiredSessions removes expired sessions
-func (m *Manager) CleanupExpiredSessions() {
+func (m *Manager) CleanupExpiredSessions(ctx context.Context) {
+	deleted, err := m.sessions.DeleteExpiredSessions(ctx, m.now().UTC())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to delete expired sessions")
+		return
+	}
+	if deleted > 0 {
+		log.Debug().Int64("count", deleted).Msg("Deleted expired sessions")
+	}
+}
+
+// SetClock replaces the clock used for session expiry
+func (m *Manager) SetClock(now func() time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = now
+}
+
+// SetTrustedProxy lets users authenticated by proxy log in without the
+// dashboard password
+func (m *Manager) SetTrustedProxy(proxy *TrustedProxy) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.trustedProxy = proxy
+}
+
+// ProxyLoginEnabled reports whether a trusted proxy is configured
+func (m *Manager) ProxyLoginEnabled() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.trustedProxy != nil
+}
+
+// ProxyUser returns the user a trusted proxy authenticated for the request,
+// or "" if there is none
+func (m *Manager) ProxyUser(r *http.Request) string {
+	m.mu.RLock()
+	proxy := m.trustedProxy
+	m.mu.RUnlock()
+
+	if proxy == nil {
+		return ""
+	}
+	return proxy.User(r)
+}
+
+// ProvisionProxyUser returns the account of a user a trusted proxy
+// authenticated, creating it with the proxy's default role if there is
+// none. Provisioned accounts get a random password, so they can only log
+// in through the proxy. It reports whether the account was created.
+func (m *Manager) ProvisionProxyUser(ctx context.Context, username string) (*core.User, bool, error) {
+	m.mu.RLock()
+	proxy := m.trustedProxy
+	m.mu.RUnlock()
+	if proxy == nil {
+		return nil, false, errors.New("no trusted proxy configured")
+	}
+
+	user, err := m.users.GetUserByUsername(ctx, username)
+	if err != nil || user != nil {
+		return user, false, err
+	}
 
-	now := time.Now()
-	for token, session := range m.sessions {
-		if now.After(session.ExpiresAt) {
-			delete(m.sessions, token)
+	password := make([]byte, 32)
+	if _, err := rand.Read(password); err != nil {
+		return nil, false, err
+	}
+	user, err = m.CreateUser(ctx, username, hex.EncodeToString(password), proxy.role)
+	if errors.Is(err, ErrUsernameTaken) {
+		// Provisioned by a concurrent login
+		user, err = m.users.GetUserByUsername(ctx, username)
+		return user, false, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return user, true, nil
+}
+
+// StartCleanup periodically removes expired sessions until StopCleanup is called
+func (m *Manager) StartCleanup(interval time.Duration) {
+	if interval <= 0 || m.stopCleanup != nil {
+		return
+	}
+
+	m.stopCleanup = make(chan struct{})
+	m.cleanupWG.Add(1)
+	go func() {
+		defer m.cleanupWG.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-m.stopCleanup:
+				return
+			case <-ticker.C:
+				m.CleanupExpiredSessions(context.Background())
+			}
 		}
+	}()
+}
+
+// StopCleanup stops the session cleanup worker
+func (m *Manager) StopCleanup() {
+	if m.stopCleanup == nil {
+		return
 	}
+	close(m.stopCleanup)
+	m.cleanupWG.Wait()
+	m.stopCleanup = nil
 }
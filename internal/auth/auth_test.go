@@ -0,0 +1,473 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flakerimi/inceptor/internal/core"
+	"github.com/rs/zerolog"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestMain(m *testing.M) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	os.Exit(m.Run())
+}
+
+func TestMatchAdminKey(t *testing.T) {
+	keys := []string{"primary-key", "", "old-key"}
+	tests := []struct {
+		name string
+		key  string
+		want bool
+	}{
+		{"primary key", "primary-key", true},
+		{"phased-out key", "old-key", true},
+		{"unknown key", "other-key", false},
+		{"prefix of a key", "primary", false},
+		{"empty key", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchAdminKey(tt.key, keys); got != tt.want {
+				t.Errorf("MatchAdminKey(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+
+	if MatchAdminKey("any", nil) {
+		t.Error("MatchAdminKey matched with no admin keys configured")
+	}
+}
+
+// storedSessions counts the sessions kept in a memory store, expired or not
+func storedSessions(s *MemorySessionStore) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.sessions)
+}
+
+func TestCleanupExpiredSessions(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		// ages are how long before the cleanup each session was created
+		ages       []time.Duration
+		wantStored int
+	}{
+		{"none expired", []time.Duration{time.Hour, 23 * time.Hour}, 2},
+		{"some expired", []time.Duration{time.Hour, 25 * time.Hour, 48 * time.Hour}, 1},
+		{"all expired", []time.Duration{30 * time.Hour}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := NewMemorySessionStore()
+			m := NewManager(nil, store)
+			for _, age := range tt.ages {
+				created := start.Add(-age)
+				m.SetClock(func() time.Time { return created })
+				if _, err := m.CreateSession(ctx, &core.User{ID: "user-1"}); err != nil {
+					t.Fatalf("create session: %v", err)
+				}
+			}
+
+			m.SetClock(func() time.Time { return start })
+			m.CleanupExpiredSessions(ctx)
+			if got := storedSessions(store); got != tt.wantStored {
+				t.Errorf("stored sessions = %d, want %d", got, tt.wantStored)
+			}
+			if got := m.ActiveSessions(ctx); got != tt.wantStored {
+				t.Errorf("active sessions = %d, want %d", got, tt.wantStored)
+			}
+		})
+	}
+}
+
+func TestStartCleanup(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemorySessionStore()
+	m := NewManager(nil, store)
+	if _, err := m.CreateSession(ctx, &core.User{ID: "user-1"}); err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	// Expire the session, then let the worker find it
+	expired := time.Now().Add(25 * time.Hour)
+	m.SetClock(func() time.Time { return expired })
+	m.StartCleanup(10 * time.Millisecond)
+	defer m.StopCleanup()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for storedSessions(store) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expired session was not cleaned up")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Stopping twice is harmless
+	m.StopCleanup()
+	m.StopCleanup()
+}
+
+// memoryUserStore is a UserStore holding accounts in memory
+type memoryUserStore struct {
+	mu    sync.Mutex
+	users map[string]*core.User
+}
+
+func newMemoryUserStore() *memoryUserStore {
+	return &memoryUserStore{users: make(map[string]*core.User)}
+}
+
+func (s *memoryUserStore) CreateUser(ctx context.Context, user *core.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := *user
+	s.users[user.ID] = &stored
+	return nil
+}
+
+func (s *memoryUserStore) GetUser(ctx context.Context, id string) (*core.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if user, ok := s.users[id]; ok {
+		copied := *user
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (s *memoryUserStore) GetUserByUsername(ctx context.Context, username string) (*core.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, user := range s.users {
+		if user.Username == username {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *memoryUserStore) ListUsers(ctx context.Context) ([]*core.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	users := make([]*core.User, 0, len(s.users))
+	for _, user := range s.users {
+		copied := *user
+		users = append(users, &copied)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users, nil
+}
+
+func (s *memoryUserStore) UpdateUserPassword(ctx context.Context, id, passwordHash string, mustChange bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if user, ok := s.users[id]; ok {
+		user.PasswordHash = passwordHash
+		user.MustChangePassword = mustChange
+	}
+	return nil
+}
+
+// newTestManager returns a Manager keeping accounts in memory, hashing
+// passwords at the lowest bcrypt cost
+func newTestManager() *Manager {
+	m := NewManager(newMemoryUserStore(), nil)
+	m.SetBcryptCost(bcrypt.MinCost)
+	return m
+}
+
+func TestBootstrap(t *testing.T) {
+	legacy := legacyHashPassword("legacy-password")
+	tests := []struct {
+		name       string
+		legacyHash string
+		// existing is an account created beforehand, if any
+		existing     string
+		wantUsers    []string
+		wantPassword string
+		wantChange   bool
+	}{
+		{"first run", "", "", []string{DefaultUsername}, DefaultPassword, true},
+		{"legacy password", legacy, "", []string{DefaultUsername}, "legacy-password", false},
+		{"legacy default password", legacyHashPassword(DefaultPassword), "", []string{DefaultUsername}, DefaultPassword, true},
+		{"accounts exist", legacy, "alice", []string{"alice"}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			m := newTestManager()
+			if tt.existing != "" {
+				if _, err := m.CreateUser(ctx, tt.existing, "secret", core.UserRoleViewer); err != nil {
+					t.Fatalf("create user: %v", err)
+				}
+			}
+
+			if err := m.Bootstrap(ctx, tt.legacyHash); err != nil {
+				t.Fatalf("Bootstrap: %v", err)
+			}
+			// Bootstrapping again changes nothing
+			if err := m.Bootstrap(ctx, ""); err != nil {
+				t.Fatalf("second Bootstrap: %v", err)
+			}
+
+			users, _ := m.ListUsers(ctx)
+			var names []string
+			for _, user := range users {
+				names = append(names, user.Username)
+			}
+			if !slices.Equal(names, tt.wantUsers) {
+				t.Fatalf("users = %v, want %v", names, tt.wantUsers)
+			}
+			if got := m.NeedsPasswordChange(ctx); got != tt.wantChange {
+				t.Errorf("NeedsPasswordChange = %v, want %v", got, tt.wantChange)
+			}
+			if tt.wantPassword == "" {
+				return
+			}
+			user, err := m.Authenticate(ctx, DefaultUsername, tt.wantPassword)
+			if err != nil || user == nil || user.Role != core.UserRoleAdmin {
+				t.Errorf("Authenticate = %+v, %v; want the admin account", user, err)
+			}
+		})
+	}
+}
+
+func TestCreateUser(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager()
+	if _, err := m.CreateUser(ctx, "alice", "secret", core.UserRoleAdmin); err != nil {
+		t.Fatalf("create alice: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		role     core.UserRole
+		wantErr  error
+		wantName string
+	}{
+		{"viewer", "bob", "secret", core.UserRoleViewer, nil, "bob"},
+		{"trimmed", "  carol ", "secret", core.UserRoleAdmin, nil, "carol"},
+		{"taken", "alice", "secret", core.UserRoleViewer, ErrUsernameTaken, ""},
+		{"taken after trimming", " alice", "secret", core.UserRoleViewer, ErrUsernameTaken, ""},
+		{"no username", "  ", "secret", core.UserRoleViewer, ErrInvalidUser, ""},
+		{"short password", "dave", "abc", core.UserRoleViewer, ErrInvalidUser, ""},
+		{"unknown role", "dave", "secret", "owner", ErrInvalidUser, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := m.CreateUser(ctx, tt.username, tt.password, tt.role)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateUser = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if user.Username != tt.wantName || user.Role != tt.role || user.MustChangePassword || user.ID == "" {
+				t.Errorf("user = %+v, want %s with role %s", user, tt.wantName, tt.role)
+			}
+			if user.PasswordHash == tt.password {
+				t.Error("password stored in plain text")
+			}
+			if got, _ := m.Authenticate(ctx, tt.wantName, tt.password); got == nil || got.ID != user.ID {
+				t.Errorf("Authenticate = %+v, want the new account", got)
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager()
+	alice, err := m.CreateUser(ctx, "alice", "secret", core.UserRoleViewer)
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		wantUser bool
+	}{
+		{"valid", "alice", "secret", true},
+		{"wrong password", "alice", "Secret", false},
+		{"empty password", "alice", "", false},
+		{"unknown user", "bob", "secret", false},
+		{"other case", "Alice", "secret", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := m.Authenticate(ctx, tt.username, tt.password)
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			if (user != nil) != tt.wantUser {
+				t.Fatalf("user = %+v, want found %v", user, tt.wantUser)
+			}
+			if user != nil && (user.ID != alice.ID || user.Role != core.UserRoleViewer) {
+				t.Errorf("user = %+v, want alice as a viewer", user)
+			}
+		})
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	tests := []struct {
+		name        string
+		oldPassword string
+		newPassword string
+		want        bool
+		// wantPassword is the password that logs in afterwards
+		wantPassword string
+	}{
+		{"changed", DefaultPassword, "new-secret", true, "new-secret"},
+		{"wrong old password", "wrong", "new-secret", false, DefaultPassword},
+		{"new password too short", DefaultPassword, "abc", false, DefaultPassword},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			m := newTestManager()
+			if err := m.Bootstrap(ctx, ""); err != nil {
+				t.Fatalf("Bootstrap: %v", err)
+			}
+			admin, _ := m.User(ctx, DefaultUsername)
+
+			changed, err := m.ChangePassword(ctx, admin.ID, tt.oldPassword, tt.newPassword)
+			if err != nil || changed != tt.want {
+				t.Fatalf("ChangePassword = %v, %v; want %v", changed, err, tt.want)
+			}
+			if user, _ := m.Authenticate(ctx, DefaultUsername, tt.wantPassword); user == nil {
+				t.Errorf("login with %q failed", tt.wantPassword)
+			}
+			// Changing the default password clears the prompt to change it
+			if got := m.NeedsPasswordChange(ctx); got == tt.want {
+				t.Errorf("NeedsPasswordChange = %v, want %v", got, !tt.want)
+			}
+		})
+	}
+
+	changed, err := newTestManager().ChangePassword(context.Background(), "missing", DefaultPassword, "new-secret")
+	if changed || err != nil {
+		t.Errorf("ChangePassword for an unknown user = %v, %v; want false", changed, err)
+	}
+}
+
+func TestValidatePassword(t *testing.T) {
+	bcryptHash, err := HashPassword("secret", bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	tests := []struct {
+		name       string
+		password   string
+		hash       string
+		want       bool
+		wantLegacy bool
+	}{
+		{"bcrypt", "secret", bcryptHash, true, false},
+		{"bcrypt, wrong password", "Secret", bcryptHash, false, false},
+		{"legacy SHA256", "secret", legacyHashPassword("secret"), true, true},
+		{"legacy SHA256, wrong password", "Secret", legacyHashPassword("secret"), false, true},
+		{"legacy digest as password", legacyHashPassword("secret"), legacyHashPassword("secret"), false, true},
+		{"malformed bcrypt", "secret", "$2a$10$short", false, false},
+		{"empty hash", "", "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidatePassword(tt.password, tt.hash); got != tt.want {
+				t.Errorf("ValidatePassword = %v, want %v", got, tt.want)
+			}
+			if got := IsLegacyHash(tt.hash); got != tt.wantLegacy {
+				t.Errorf("IsLegacyHash = %v, want %v", got, tt.wantLegacy)
+			}
+		})
+	}
+
+	// bcrypt hashes are salted
+	again, _ := HashPassword("secret", bcrypt.MinCost)
+	if again == bcryptHash {
+		t.Error("two hashes of one password are equal, want them salted")
+	}
+}
+
+func TestAuthenticateUpgradesHashes(t *testing.T) {
+	const cost = bcrypt.MinCost + 1
+	otherCost, _ := HashPassword("secret", bcrypt.MinCost)
+	current, _ := HashPassword("secret", cost)
+	tests := []struct {
+		name     string
+		hash     string
+		password string
+		// wantUpgraded is whether the stored hash is replaced
+		wantUpgraded bool
+	}{
+		{"legacy SHA256", legacyHashPassword("secret"), "secret", true},
+		{"other cost", otherCost, "secret", true},
+		{"current cost", current, "secret", false},
+		{"wrong password", legacyHashPassword("secret"), "wrong", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			users := newMemoryUserStore()
+			users.CreateUser(ctx, &core.User{ID: "user-1", Username: "alice", PasswordHash: tt.hash, Role: core.UserRoleAdmin, MustChangePassword: true})
+			m := NewManager(users, nil)
+			m.SetBcryptCost(cost)
+
+			m.Authenticate(ctx, "alice", tt.password)
+
+			stored, _ := users.GetUser(ctx, "user-1")
+			if upgraded := stored.PasswordHash != tt.hash; upgraded != tt.wantUpgraded {
+				t.Fatalf("hash upgraded = %v, want %v", upgraded, tt.wantUpgraded)
+			}
+			if !tt.wantUpgraded {
+				return
+			}
+			if got, err := bcrypt.Cost([]byte(stored.PasswordHash)); err != nil || got != cost {
+				t.Errorf("upgraded hash cost = %d, %v; want %d", got, err, cost)
+			}
+			if !stored.MustChangePassword {
+				t.Error("upgrade cleared MustChangePassword")
+			}
+			// The password still works against the new hash
+			if user, err := m.Authenticate(ctx, "alice", tt.password); err != nil || user == nil {
+				t.Errorf("Authenticate after the upgrade = %v, %v", user, err)
+			}
+		})
+	}
+}
+
+func TestSetBcryptCost(t *testing.T) {
+	tests := []struct {
+		cost int
+		want int
+	}{
+		{bcrypt.MinCost, bcrypt.MinCost},
+		{12, 12},
+		{bcrypt.MinCost - 1, bcrypt.DefaultCost},
+		{bcrypt.MaxCost + 1, bcrypt.DefaultCost},
+		{0, bcrypt.DefaultCost},
+	}
+	for _, tt := range tests {
+		m := NewManager(newMemoryUserStore(), nil)
+		m.SetBcryptCost(tt.cost)
+		if got := m.cost(); got != tt.want {
+			t.Errorf("SetBcryptCost(%d): cost = %d, want %d", tt.cost, got, tt.want)
+		}
+	}
+}
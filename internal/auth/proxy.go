@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/flakerimi/inceptor/internal/core"
+)
+
+// DefaultProxyUserHeader is the header an authenticating proxy sets to the
+// logged-in user when none is configured
+const DefaultProxyUserHeader = "X-Forwarded-User"
+
+// DefaultProxyUserRole is the role of accounts provisioned for proxy users
+// when none is configured. The proxy has already decided who may use the
+// dashboard, so they get full access unless the deployment asks for less.
+const DefaultProxyUserRole = core.UserRoleAdmin
+
+// TrustedProxy accepts the user identified by an authenticating reverse
+// proxy, such as an SSO gateway, in place of the dashboard password
+type TrustedProxy struct {
+	header string
+	nets   []*net.IPNet
+	// role is given to accounts provisioned for users the proxy
+	// authenticates who have none yet
+	role core.UserRole
+}
+
+// NewTrustedProxy creates a TrustedProxy that reads the user from header on
+// requests whose connection comes from one of cidrs. Users without an
+// account get one with role, DefaultProxyUserRole if it is empty.
+func NewTrustedProxy(header string, cidrs []string, role core.UserRole) (*TrustedProxy, error) {
+	if header == "" {
+		header = DefaultProxyUserHeader
+	}
+	if role == "" {
+		role = DefaultProxyUserRole
+	}
+	if !role.Valid() {
+		return nil, fmt.Errorf("invalid trusted proxy default role %q: must be admin or viewer", role)
+	}
+	if len(cidrs) == 0 {
+		return nil, fmt.Errorf("at least one trusted proxy CIDR is required")
+	}
+
+	p := &TrustedProxy{header: header, role: role}
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy CIDR %q: %w", cidr, err)
+		}
+		p.nets = append(p.nets, ipNet)
+	}
+	return p, nil
+}
+
+// User returns the user the proxy authenticated, or "" if the request didn't
+// come directly from a trusted proxy or carries no user. Only the connection's
+// address is checked; X-Forwarded-For is ignored since any client can set it.
+func (p *TrustedProxy) User(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !p.trusts(ip) {
+		return ""
+	}
+	return strings.TrimSpace(r.Header.Get(p.header))
+}
+
+func (p *TrustedProxy) trusts(ip net.IP) bool {
+	for _, ipNet := range p.nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
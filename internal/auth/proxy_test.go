@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/flakerimi/inceptor/internal/core"
+)
+
+// memoryUsers is an in-memory UserStore
+type memoryUsers struct {
+	mu    sync.Mutex
+	users map[string]*core.User
+}
+
+func newMemoryUsers(users ...*core.User) *memoryUsers {
+	s := &memoryUsers{users: make(map[string]*core.User)}
+	for _, user := range users {
+		s.users[user.Username] = user
+	}
+	return s
+}
+
+func (s *memoryUsers) CreateUser(ctx context.Context, user *core.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[user.Username]; ok {
+		return ErrUsernameTaken
+	}
+	s.users[user.Username] = user
+	return nil
+}
+
+func (s *memoryUsers) GetUser(ctx context.Context, id string) (*core.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, user := range s.users {
+		if user.ID == id {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *memoryUsers) GetUserByUsername(ctx context.Context, username string) (*core.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.users[username], nil
+}
+
+func (s *memoryUsers) ListUsers(ctx context.Context) ([]*core.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var users []*core.User
+	for _, user := range s.users {
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func (s *memoryUsers) UpdateUserPassword(ctx context.Context, id, passwordHash string, mustChange bool) error {
+	return nil
+}
+
+func TestNewTrustedProxy(t *testing.T) {
+	tests := []struct {
+		name     string
+		cidrs    []string
+		role     core.UserRole
+		wantRole core.UserRole
+		wantErr  bool
+	}{
+		{"admin by default", []string{"10.0.0.0/8"}, "", core.UserRoleAdmin, false},
+		{"viewer role", []string{"10.0.0.0/8"}, core.UserRoleViewer, core.UserRoleViewer, false},
+		{"invalid role", []string{"10.0.0.0/8"}, "owner", "", true},
+		{"no CIDRs", nil, "", "", true},
+		{"invalid CIDR", []string{"10.0.0.1"}, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy, err := NewTrustedProxy("", tt.cidrs, tt.role)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && proxy.role != tt.wantRole {
+				t.Errorf("role = %q, want %q", proxy.role, tt.wantRole)
+			}
+		})
+	}
+}
+
+func TestTrustedProxyUser(t *testing.T) {
+	proxy, err := NewTrustedProxy("X-Auth-User", []string{"10.0.0.0/8", "::1/128"}, "")
+	if err != nil {
+		t.Fatalf("new trusted proxy: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{"trusted proxy", "10.1.2.3:5000", map[string]string{"X-Auth-User": " alice "}, "alice"},
+		{"trusted IPv6 proxy", "[::1]:5000", map[string]string{"X-Auth-User": "alice"}, "alice"},
+		{"untrusted address", "192.168.1.5:5000", map[string]string{"X-Auth-User": "alice"}, ""},
+		{"spoofed forwarded address", "192.168.1.5:5000", map[string]string{"X-Auth-User": "alice", "X-Forwarded-For": "10.1.2.3"}, ""},
+		{"other header", "10.1.2.3:5000", map[string]string{"X-Forwarded-User": "alice"}, ""},
+		{"no user", "10.1.2.3:5000", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/v1/auth/proxy-login", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			if got := proxy.User(req); got != tt.want {
+				t.Errorf("User() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProvisionProxyUser(t *testing.T) {
+	tests := []struct {
+		name        string
+		role        core.UserRole
+		existing    *core.User
+		wantRole    core.UserRole
+		wantCreated bool
+	}{
+		{"provisioned as admin", "", nil, core.UserRoleAdmin, true},
+		{"provisioned with configured role", core.UserRoleViewer, nil, core.UserRoleViewer, true},
+		{"existing account kept", "", &core.User{ID: "user-1", Username: "alice", Role: core.UserRoleViewer}, core.UserRoleViewer, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			users := newMemoryUsers()
+			if tt.existing != nil {
+				users = newMemoryUsers(tt.existing)
+			}
+			m := NewManager(users, nil)
+			m.SetBcryptCost(4)
+			proxy, err := NewTrustedProxy("", []string{"10.0.0.0/8"}, tt.role)
+			if err != nil {
+				t.Fatalf("new trusted proxy: %v", err)
+			}
+			m.SetTrustedProxy(proxy)
+
+			user, created, err := m.ProvisionProxyUser(ctx, "alice")
+			if err != nil {
+				t.Fatalf("provision: %v", err)
+			}
+			if created != tt.wantCreated || user.Username != "alice" || user.Role != tt.wantRole {
+				t.Errorf("user = %s with role %s, created %v; want alice with %s, created %v", user.Username, user.Role, created, tt.wantRole, tt.wantCreated)
+			}
+
+			// Only the first login provisions
+			again, created, err := m.ProvisionProxyUser(ctx, "alice")
+			if err != nil || created || again.ID != user.ID {
+				t.Errorf("second login: user %v, created %v, err %v; want the same account", again, created, err)
+			}
+			// The account can't be logged into with a password
+			for _, password := range []string{"", DefaultPassword} {
+				if found, _ := m.Authenticate(ctx, "alice", password); found != nil && tt.wantCreated {
+					t.Errorf("provisioned account accepted password %q", password)
+				}
+			}
+		})
+	}
+
+	m := NewManager(newMemoryUsers(), nil)
+	if _, _, err := m.ProvisionProxyUser(context.Background(), "alice"); err == nil {
+		t.Error("provisioned a user without a trusted proxy")
+	}
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/flakerimi/inceptor/internal/core"
+)
+
+// SessionStore keeps dashboard sessions, keyed by the hash of their token
+type SessionStore interface {
+	CreateSession(ctx context.Context, session *core.Session) error
+	// GetSession returns nil if there is no session with the token hash
+	GetSession(ctx context.Context, tokenHash string) (*core.Session, error)
+	DeleteSession(ctx context.Context, tokenHash string) error
+	// DeleteExpiredSessions removes sessions that expired before now and
+	// returns how many there were
+	DeleteExpiredSessions(ctx context.Context, now time.Time) (int64, error)
+	// CountActiveSessions counts sessions that haven't expired by now
+	CountActiveSessions(ctx context.Context, now time.Time) (int, error)
+}
+
+// hashToken returns the key a session is stored under
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// MemorySessionStore keeps sessions in memory, so they end with the process
+type MemorySessionStore struct {
+	sessions map[string]*core.Session
+	mu       sync.RWMutex
+}
+
+// NewMemorySessionStore creates an empty in-memory session store
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: make(map[string]*core.Session)}
+}
+
+func (s *MemorySessionStore) CreateSession(ctx context.Context, session *core.Session) error {
+	stored := *session
+	stored.Token = ""
+
+	s.mu.Lock()
+	s.sessions[session.TokenHash] = &stored
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemorySessionStore) GetSession(ctx context.Context, tokenHash string) (*core.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, exists := s.sessions[tokenHash]
+	if !exists {
+		return nil, nil
+	}
+	found := *session
+	return &found, nil
+}
+
+func (s *MemorySessionStore) DeleteSession(ctx context.Context, tokenHash string) error {
+	s.mu.Lock()
+	delete(s.sessions, tokenHash)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemorySessionStore) DeleteExpiredSessions(ctx context.Context, now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for tokenHash, session := range s.sessions {
+		if now.After(session.ExpiresAt) {
+			delete(s.sessions, tokenHash)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (s *MemorySessionStore) CountActiveSessions(ctx context.Context, now time.Time) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	active := 0
+	for _, session := range s.sessions {
+		if !now.After(session.ExpiresAt) {
+			active++
+		}
+	}
+	return active, nil
+}
@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/flakerimi/inceptor/internal/core"
+)
+
+func TestMemorySessionStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemorySessionStore()
+	for _, session := range []*core.Session{
+		{Token: "token-1", TokenHash: hashToken("token-1"), UserID: "user-1", ExpiresAt: now.Add(time.Hour)},
+		{Token: "token-2", TokenHash: hashToken("token-2"), UserID: "user-1", ExpiresAt: now},
+		{Token: "token-3", TokenHash: hashToken("token-3"), UserID: "user-2", ExpiresAt: now.Add(-time.Second)},
+	} {
+		if err := store.CreateSession(ctx, session); err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+	}
+
+	// Tokens aren't kept, only their hashes
+	session, _ := store.GetSession(ctx, hashToken("token-1"))
+	if session == nil || session.Token != "" || session.UserID != "user-1" {
+		t.Fatalf("GetSession = %+v, want user-1's session without its token", session)
+	}
+	// Changing a returned session doesn't change the store
+	session.UserID = "changed"
+	if again, _ := store.GetSession(ctx, hashToken("token-1")); again.UserID != "user-1" {
+		t.Errorf("stored session changed through a returned copy")
+	}
+
+	// A session expiring right now is still active
+	if active, _ := store.CountActiveSessions(ctx, now); active != 2 {
+		t.Errorf("CountActiveSessions = %d, want 2", active)
+	}
+	if deleted, _ := store.DeleteExpiredSessions(ctx, now); deleted != 1 {
+		t.Errorf("DeleteExpiredSessions = %d, want 1", deleted)
+	}
+	store.DeleteSession(ctx, hashToken("token-1"))
+
+	tests := []struct {
+		token string
+		want  bool
+	}{
+		{"token-1", false},
+		{"token-2", true},
+		{"token-3", false},
+		{"unknown", false},
+	}
+	for _, tt := range tests {
+		session, err := store.GetSession(ctx, hashToken(tt.token))
+		if err != nil {
+			t.Fatalf("GetSession: %v", err)
+		}
+		if (session != nil) != tt.want {
+			t.Errorf("%s found = %v, want %v", tt.token, session != nil, tt.want)
+		}
+	}
+}
+
+func TestManagerSession(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	user := &core.User{ID: "user-1", Username: "alice", Role: core.UserRoleViewer}
+	tests := []struct {
+		name string
+		// token returns the token to look up, given a new session's
+		token   func(m *Manager, token string) string
+		elapsed time.Duration
+		// restart looks the session up with another manager on the store
+		restart bool
+		want    bool
+	}{
+		{"valid", func(m *Manager, token string) string { return token }, time.Hour, false, true},
+		{"empty token", func(m *Manager, token string) string { return "" }, 0, false, false},
+		{"unknown token", func(m *Manager, token string) string { return "unknown" }, 0, false, false},
+		{"token hash", func(m *Manager, token string) string { return hashToken(token) }, 0, false, false},
+		{"expired", func(m *Manager, token string) string { return token }, 25 * time.Hour, false, false},
+		{"logged out", func(m *Manager, token string) string {
+			m.DeleteSession(context.Background(), token)
+			return token
+		}, 0, false, false},
+		// As after a restart
+		{"restarted", func(m *Manager, token string) string { return token }, 0, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := NewMemorySessionStore()
+			m := NewManager(newMemoryUserStore(), store)
+			m.SetClock(func() time.Time { return start })
+			created, err := m.CreateSession(ctx, user)
+			if err != nil {
+				t.Fatalf("CreateSession: %v", err)
+			}
+			if created.Token == "" || created.TokenHash != hashToken(created.Token) || !created.ExpiresAt.Equal(start.Add(24*time.Hour)) {
+				t.Fatalf("created session = %+v, want a token expiring in 24h", created)
+			}
+
+			token := tt.token(m, created.Token)
+			if tt.restart {
+				m = NewManager(newMemoryUserStore(), store)
+			}
+			m.SetClock(func() time.Time { return start.Add(tt.elapsed) })
+
+			session := m.Session(ctx, token)
+			if (session != nil) != tt.want {
+				t.Fatalf("session found = %v, want %v", session != nil, tt.want)
+			}
+			if session != nil && (session.UserID != user.ID || session.Username != user.Username || session.IsAdmin()) {
+				t.Errorf("session = %+v, want alice's viewer session", session)
+			}
+			// Expired sessions are removed when they're looked up
+			if tt.elapsed > 24*time.Hour && storedSessions(store) != 0 {
+				t.Error("expired session left in the store")
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"strings"
 	"time"
 
@@ -11,8 +12,15 @@ type Config struct {
 	Server    ServerConfig    `mapstructure:"server"`
 	Storage   StorageConfig   `mapstructure:"storage"`
 	Retention RetentionConfig `mapstructure:"retention"`
+	Export    ExportConfig    `mapstructure:"export"`
 	Alerts    AlertsConfig    `mapstructure:"alerts"`
 	Auth      AuthConfig      `mapstructure:"auth"`
+	Logging   LoggingConfig   `mapstructure:"logging"`
+	Ingest    IngestConfig    `mapstructure:"ingest"`
+	Grouping  GroupingConfig  `mapstructure:"grouping"`
+
+	// Files are the config files read, in order of precedence, lowest first
+	Files []string `mapstructure:"-"`
 }
 
 type ServerConfig struct {
@@ -20,11 +28,79 @@ type ServerConfig struct {
 	GRPCPort      int    `mapstructure:"grpc_port"`
 	DashboardPort int    `mapstructure:"dashboard_port"`
 	Host          string `mapstructure:"host"`
+	// AdminRateLimit limits REST requests made with an admin key or a
+	// dashboard session, per key or session
+	AdminRateLimit RateLimitConfig `mapstructure:"admin_rate_limit"`
+	// ShutdownTimeout bounds how long a graceful shutdown waits for
+	// in-flight requests and queued alerts
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+	// V1Deprecation announces to clients that /api/v1 is deprecated
+	V1Deprecation APIDeprecationConfig `mapstructure:"v1_deprecation"`
+	// Root selects what the server answers at "/"
+	Root RootConfig `mapstructure:"root"`
+}
+
+// RootConfig selects what the server answers at "/", for deployments that
+// don't want the dashboard there
+type RootConfig struct {
+	// Mode is dashboard (default), redirect or status
+	Mode string `mapstructure:"mode"`
+	// RedirectURL is where redirect mode sends visitors
+	RedirectURL string `mapstructure:"redirect_url"`
+	// Name and Message brand the status mode's JSON response
+	Name    string `mapstructure:"name"`
+	Message string `mapstructure:"message"`
+}
+
+// APIDeprecationConfig sets the deprecation headers sent on every response
+// of an API version. Dates are RFC 3339.
+type APIDeprecationConfig struct {
+	// Date is when the version was or will be deprecated. Empty sends no
+	// headers.
+	Date string `mapstructure:"date"`
+	// Sunset is when the version will stop working, if decided
+	Sunset string `mapstructure:"sunset"`
+	// Link is the URL of the migration guide
+	Link string `mapstructure:"link"`
 }
 
 type StorageConfig struct {
+	// Driver selects the repository backend: sqlite or postgres
+	Driver     string `mapstructure:"driver"`
 	SQLitePath string `mapstructure:"sqlite_path"`
-	LogsPath   string `mapstructure:"logs_path"`
+	// DSN is the connection string for the postgres driver
+	DSN string `mapstructure:"dsn"`
+	// ReplicaDSNs are connection strings of postgres read replicas that
+	// serve dashboard reads in turn (empty reads from the primary)
+	ReplicaDSNs []string `mapstructure:"replica_dsns"`
+	// ReplicaReadAfterWrite is how long a written crash or group is read
+	// from the primary rather than a replica, to cover replication lag
+	ReplicaReadAfterWrite time.Duration `mapstructure:"replica_read_after_write"`
+	// FileStore selects where full crash payloads are kept: local or s3
+	FileStore string   `mapstructure:"file_store"`
+	LogsPath  string   `mapstructure:"logs_path"`
+	S3        S3Config `mapstructure:"s3"`
+	// ReadConcurrency bounds parallel payload reads for batch crash requests
+	ReadConcurrency int `mapstructure:"read_concurrency"`
+	// DeadLetterPath is where crashes that fail to save to the database are
+	// kept for reprocessing (empty disables dead-lettering)
+	DeadLetterPath string `mapstructure:"dead_letter_path"`
+}
+
+// S3Config locates the bucket for the s3 file store. Any S3-compatible
+// service works, e.g. MinIO via Endpoint.
+type S3Config struct {
+	Bucket string `mapstructure:"bucket"`
+	// Prefix is prepended to every object key
+	Prefix string `mapstructure:"prefix"`
+	Region string `mapstructure:"region"`
+	// Endpoint is the service host, optionally with an http:// or https://
+	// scheme (https is assumed). Empty means AWS S3.
+	Endpoint string `mapstructure:"endpoint"`
+	// AccessKeyID and SecretAccessKey may be left empty to use the standard
+	// AWS environment variables, credentials file or instance role
+	AccessKeyID     string `mapstructure:"access_key_id"`
+	SecretAccessKey string `mapstructure:"secret_access_key"`
 }
 
 type RetentionConfig struct {
@@ -32,9 +108,49 @@ type RetentionConfig struct {
 	CleanupInterval time.Duration `mapstructure:"cleanup_interval"`
 }
 
+// ExportConfig controls the scheduled export of new crashes to an external
+// sink, independent of retention
+type ExportConfig struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Interval time.Duration `mapstructure:"interval"`
+	// Delay skips crashes newer than this so late async writes aren't missed
+	Delay time.Duration `mapstructure:"delay"`
+	// Destination selects where export files go: local
+	Destination string `mapstructure:"destination"`
+	Path        string `mapstructure:"path"`
+	// BatchSize is the number of crashes per export file
+	BatchSize int `mapstructure:"batch_size"`
+}
+
 type AlertsConfig struct {
-	SMTP  SMTPConfig  `mapstructure:"smtp"`
-	Slack SlackConfig `mapstructure:"slack"`
+	SMTP   SMTPConfig        `mapstructure:"smtp"`
+	Slack  SlackConfig       `mapstructure:"slack"`
+	Replay AlertReplayConfig `mapstructure:"replay"`
+	// ThresholdInterval is how often threshold conditions are evaluated
+	ThresholdInterval time.Duration `mapstructure:"threshold_interval"`
+	// DashboardURL is the dashboard's public base URL, used to link alerts
+	// back to their crash
+	DashboardURL string `mapstructure:"dashboard_url"`
+	// ActionLinks configures one-click links in alert emails
+	ActionLinks ActionLinksConfig `mapstructure:"action_links"`
+}
+
+// ActionLinksConfig configures the signed links in alert emails that act on
+// a group without logging in. They are disabled while Secret is empty, and
+// need AlertsConfig.DashboardURL.
+type ActionLinksConfig struct {
+	// Secret is the HMAC key links are signed with
+	Secret string `mapstructure:"secret"`
+	// TTL is how long a link stays valid after the email is sent
+	TTL time.Duration `mapstructure:"ttl"`
+}
+
+// AlertReplayConfig limits replaying alerts for past crashes
+type AlertReplayConfig struct {
+	// Cooldown is how long before the same crash may be replayed again
+	Cooldown time.Duration `mapstructure:"cooldown"`
+	// MaxPerHour caps replays across all crashes (0 for no cap)
+	MaxPerHour int `mapstructure:"max_per_hour"`
 }
 
 type SMTPConfig struct {
@@ -52,9 +168,258 @@ type SlackConfig struct {
 type AuthConfig struct {
 	Enabled  bool   `mapstructure:"enabled"`
 	AdminKey string `mapstructure:"admin_key"`
+	// AdminKeys are additional admin keys accepted alongside AdminKey, e.g.
+	// the previous key while automation is migrated to a rotated one
+	AdminKeys []string `mapstructure:"admin_keys"`
+	// SessionStore is where dashboard sessions are kept: "database", so they
+	// survive restarts, or "memory"
+	SessionStore string `mapstructure:"session_store"`
+	// SessionCleanupInterval is how often expired dashboard sessions are purged
+	SessionCleanupInterval time.Duration `mapstructure:"session_cleanup_interval"`
+	// BcryptCost is the work factor of dashboard password hashes
+	BcryptCost int `mapstructure:"bcrypt_cost"`
+	// TrustedProxy lets an authenticating reverse proxy log dashboard users in
+	TrustedProxy TrustedProxyConfig `mapstructure:"trusted_proxy"`
+}
+
+// TrustedProxyConfig configures dashboard login through an SSO proxy
+type TrustedProxyConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Header carries the user the proxy authenticated
+	Header string `mapstructure:"header"`
+	// CIDRs the proxy connects from; the header is ignored from anywhere else
+	CIDRs []string `mapstructure:"cidrs"`
+	// DefaultRole is the role of accounts provisioned for proxy users
+	// without one: admin (the default) or viewer
+	DefaultRole string `mapstructure:"default_role"`
+}
+
+// AllAdminKeys returns the primary admin key followed by any additional keys,
+// skipping empty entries
+func (a AuthConfig) AllAdminKeys() []string {
+	var keys []string
+	if a.AdminKey != "" {
+		keys = append(keys, a.AdminKey)
+	}
+	for _, k := range a.AdminKeys {
+		if k != "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+type IngestConfig struct {
+	// DedupWindow is how long a client event_id is remembered; a repeated
+	// event_id within the window returns the original crash instead of
+	// creating a new one. Zero disables deduplication.
+	DedupWindow time.Duration `mapstructure:"dedup_window"`
+
+	Async AsyncIngestConfig `mapstructure:"async"`
+
+	// MaxLengths bounds indexed crash fields; the payload file keeps them whole
+	MaxLengths MaxLengthsConfig `mapstructure:"max_lengths"`
+
+	// PreserveNumbers keeps JSON numbers in metadata and breadcrumb data
+	// exactly as sent instead of converting them to float64, so large
+	// integer IDs don't lose precision
+	PreserveNumbers bool `mapstructure:"preserve_numbers"`
+
+	// MetadataLimits bounds the nesting and size of metadata and breadcrumb
+	// data
+	MetadataLimits MetadataLimitsConfig `mapstructure:"metadata_limits"`
+
+	Quarantine QuarantineConfig `mapstructure:"quarantine"`
+
+	GeoIP GeoIPConfig `mapstructure:"geoip"`
+
+	RateLimit RateLimitConfig `mapstructure:"rate_limit"`
+
+	Symbolication SymbolicationConfig `mapstructure:"symbolication"`
+
+	Deobfuscation DeobfuscationConfig `mapstructure:"deobfuscation"`
+
+	SourceMaps SourceMapConfig `mapstructure:"source_maps"`
+
+	// MaxDecompressedBytes caps gzip or deflate compressed crash bodies once
+	// decompressed; larger bodies are rejected with 413
+	MaxDecompressedBytes int64 `mapstructure:"max_decompressed_bytes"`
+
+	// MaxConcurrentSubmissions caps REST crash submissions in progress at
+	// once; more are rejected with 429. Zero disables the cap.
+	MaxConcurrentSubmissions int `mapstructure:"max_concurrent_submissions"`
+
+	// AggregateThreshold treats ignored groups as aggregate-only once they
+	// reach this many occurrences: further crashes only bump the group's
+	// count. Zero disables the automatic mode.
+	AggregateThreshold int `mapstructure:"aggregate_threshold"`
+}
+
+// QuarantineConfig controls the ingest heuristics that hold suspicious
+// submissions back from grouping until an admin reviews them
+type QuarantineConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// FloodThreshold is how many identical submissions are accepted per
+	// FloodWindow before the rest are quarantined (0 disables)
+	FloodThreshold int           `mapstructure:"flood_threshold"`
+	FloodWindow    time.Duration `mapstructure:"flood_window"`
+}
+
+// RateLimitConfig is a token-bucket rate limit. As ingest.rate_limit it is
+// the default per-app limit, which apps can override.
+type RateLimitConfig struct {
+	// Rate is the sustained requests per second per caller (0 disables)
+	Rate  float64 `mapstructure:"rate"`
+	Burst int     `mapstructure:"burst"`
+}
+
+// GeoIPConfig enables tagging crashes with the country of the submitting
+// client's IP address. The IP itself is never stored.
+type GeoIPConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// DatabasePath is a CSV file of "start,end,country" IP ranges
+	DatabasePath string `mapstructure:"database_path"`
 }
 
-func Load(configPath string) (*Config, error) {
+// SymbolicationConfig enables resolving raw address frames in iOS crashes
+// with dSYM files uploaded per app
+type SymbolicationConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// DSYMPath is where uploaded dSYMs are kept
+	DSYMPath string `mapstructure:"dsym_path"`
+	// MaxUploadBytes bounds a dSYM upload, and the files extracted from an
+	// uploaded zip (0 = unlimited)
+	MaxUploadBytes int64 `mapstructure:"max_upload_bytes"`
+	// CacheSize is how many parsed dSYMs are kept in memory
+	CacheSize int `mapstructure:"cache_size"`
+}
+
+// DeobfuscationConfig enables retracing obfuscated Android crashes with
+// ProGuard or R8 mapping files uploaded per app version
+type DeobfuscationConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// MappingPath is where uploaded mapping files are kept
+	MappingPath string `mapstructure:"mapping_path"`
+	// MaxUploadBytes bounds a mapping file upload (0 = unlimited)
+	MaxUploadBytes int64 `mapstructure:"max_upload_bytes"`
+	// CacheSize is how many parsed mappings are kept in memory
+	CacheSize int `mapstructure:"cache_size"`
+}
+
+// SourceMapConfig enables resolving minified web crash frames with
+// JavaScript source maps uploaded per app version and bundle
+type SourceMapConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Path is where uploaded source maps are kept
+	Path string `mapstructure:"path"`
+	// MaxUploadBytes bounds a source map upload (0 = unlimited)
+	MaxUploadBytes int64 `mapstructure:"max_upload_bytes"`
+	// CacheSize is how many parsed source maps are kept in memory
+	CacheSize int `mapstructure:"cache_size"`
+}
+
+// MetadataLimitsConfig bounds client-supplied metadata and breadcrumb data.
+// Zero disables a limit.
+type MetadataLimitsConfig struct {
+	// MaxDepth is how many levels of nested objects and arrays a map may have
+	MaxDepth int `mapstructure:"max_depth"`
+	// MaxBytes bounds the JSON size of the metadata, and separately of all
+	// breadcrumb data together
+	MaxBytes int `mapstructure:"max_bytes"`
+	// Action is trim (cut the maps down and store the crash) or reject
+	Action string `mapstructure:"action"`
+}
+
+type MaxLengthsConfig struct {
+	ErrorType    int `mapstructure:"error_type"`
+	ErrorMessage int `mapstructure:"error_message"`
+	FrameField   int `mapstructure:"frame_field"`
+}
+
+type AsyncIngestConfig struct {
+	// QueueSize bounds crashes waiting for background ingestion; submissions
+	// beyond it are rejected with 429. Zero disables async ingestion.
+	QueueSize int `mapstructure:"queue_size"`
+	Workers   int `mapstructure:"workers"`
+	// AppIDs are always ingested asynchronously; other apps opt in per
+	// request with "Prefer: respond-async"
+	AppIDs []string `mapstructure:"app_ids"`
+}
+
+type GroupingConfig struct {
+	// TitleStrategy is one of type_message, type_culprit or message
+	TitleStrategy string `mapstructure:"title_strategy"`
+	// TitleTemplates maps error types to title templates
+	TitleTemplates map[string]string `mapstructure:"title_templates"`
+	// FramePositions maps platforms to the source positions kept in
+	// fingerprints: none, column, line or line_column (default none)
+	FramePositions map[string]string `mapstructure:"frame_positions"`
+	// FrameLimit is the number of frames fingerprinted under flat weighting
+	FrameLimit int `mapstructure:"frame_limit"`
+	// FrameWeighting is flat (default) or weighted
+	FrameWeighting string             `mapstructure:"frame_weighting"`
+	Weighted       FrameWeightsConfig `mapstructure:"weighted"`
+	AppleFrames    AppleFramesConfig  `mapstructure:"apple_frames"`
+	Churn          ChurnConfig        `mapstructure:"churn"`
+	// MaxGroupsPerApp caps each app's crash groups unless the app sets its
+	// own cap (0 means no cap)
+	MaxGroupsPerApp int `mapstructure:"max_groups_per_app"`
+	// ErrorTypeFallback derives an error type for crashes submitted without
+	// one: top_frame or message ("" rejects them)
+	ErrorTypeFallback string `mapstructure:"error_type_fallback"`
+	// HistoricalAfter is how old, by its occurred_at, a crash must be to
+	// attach to an existing group without updating its last_seen or count
+	// (0 uses the app's retention period)
+	HistoricalAfter time.Duration `mapstructure:"historical_after"`
+}
+
+// FrameWeightsConfig configures weighted fingerprinting, counting frames
+// from the top in-app frame
+type FrameWeightsConfig struct {
+	// FullFrames are fingerprinted in full (at least 1)
+	FullFrames int `mapstructure:"full_frames"`
+	// CoarseFrames follow the full frames and contribute only their class or file
+	CoarseFrames int `mapstructure:"coarse_frames"`
+}
+
+// AppleFramesConfig normalizes Apple-format frames ("0x1a2b MyApp + 1234")
+// so unsymbolicated iOS and macOS crashes group across launches and builds
+type AppleFramesConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// OffsetBucket is the size of the offset ranges unsymbolicated frames
+	// group by (0 groups them by binary alone)
+	OffsetBucket uint64 `mapstructure:"offset_bucket"`
+}
+
+// ChurnConfig guards against fingerprint churn: an app creating an
+// unusual number of new groups in a short window
+type ChurnConfig struct {
+	// MaxNewGroups is the number of new groups allowed per window (0 disables)
+	MaxNewGroups int           `mapstructure:"max_new_groups"`
+	Window       time.Duration `mapstructure:"window"`
+	// FallbackDuration is how long to use the coarse error-type-only
+	// fingerprint after the guard trips (0 only flags the churn)
+	FallbackDuration time.Duration `mapstructure:"fallback_duration"`
+}
+
+type LoggingConfig struct {
+	AccessLog AccessLogConfig `mapstructure:"access_log"`
+}
+
+type AccessLogConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// SampleRate logs 1 in N successful requests; errors are always logged
+	SampleRate int      `mapstructure:"sample_rate"`
+	Fields     []string `mapstructure:"fields"`
+	SkipPaths  []string `mapstructure:"skip_paths"`
+}
+
+// Load reads the config from the given files, merged in order so each file
+// overrides the ones before it. Environment variables (INCEPTOR_ followed by
+// the key, e.g. INCEPTOR_SERVER_REST_PORT) override every file, and defaults
+// apply to anything left unset. Without files, config.yaml is read from
+// ./configs or the working directory if present.
+func Load(configPaths ...string) (*Config, error) {
 	v := viper.New()
 
 	// Set defaults
@@ -62,31 +427,135 @@ func Load(configPath string) (*Config, error) {
 	v.SetDefault("server.grpc_port", 9090)
 	v.SetDefault("server.dashboard_port", 3000)
 	v.SetDefault("server.host", "0.0.0.0")
+	v.SetDefault("server.admin_rate_limit.rate", 20)
+	v.SetDefault("server.admin_rate_limit.burst", 100)
+	v.SetDefault("server.shutdown_timeout", "30s")
+	v.SetDefault("server.v1_deprecation.date", "")
+	v.SetDefault("server.v1_deprecation.sunset", "")
+	v.SetDefault("server.v1_deprecation.link", "")
+	v.SetDefault("server.root.mode", "dashboard")
+	v.SetDefault("server.root.redirect_url", "")
+	v.SetDefault("server.root.name", "Inceptor")
+	v.SetDefault("server.root.message", "")
+	v.SetDefault("storage.driver", "sqlite")
 	v.SetDefault("storage.sqlite_path", "./data/inceptor.db")
+	v.SetDefault("storage.dsn", "")
+	v.SetDefault("storage.replica_dsns", []string{})
+	v.SetDefault("storage.replica_read_after_write", "5s")
+	v.SetDefault("storage.file_store", "local")
 	v.SetDefault("storage.logs_path", "./data/crashes")
+	v.SetDefault("storage.s3.bucket", "")
+	v.SetDefault("storage.s3.prefix", "")
+	v.SetDefault("storage.s3.region", "us-east-1")
+	v.SetDefault("storage.s3.endpoint", "")
+	v.SetDefault("storage.s3.access_key_id", "")
+	v.SetDefault("storage.s3.secret_access_key", "")
+	v.SetDefault("storage.read_concurrency", 8)
+	v.SetDefault("storage.dead_letter_path", "./data/deadletter")
 	v.SetDefault("retention.default_days", 30)
 	v.SetDefault("retention.cleanup_interval", "24h")
+	v.SetDefault("export.enabled", false)
+	v.SetDefault("export.interval", "24h")
+	v.SetDefault("export.delay", "5m")
+	v.SetDefault("export.destination", "local")
+	v.SetDefault("export.path", "./data/exports")
+	v.SetDefault("export.batch_size", 1000)
+	v.SetDefault("alerts.replay.cooldown", "1h")
+	v.SetDefault("alerts.replay.max_per_hour", 20)
+	v.SetDefault("alerts.threshold_interval", "1m")
+	v.SetDefault("alerts.dashboard_url", "")
+	v.SetDefault("alerts.action_links.secret", "")
+	v.SetDefault("alerts.action_links.ttl", "24h")
 	v.SetDefault("auth.enabled", true)
-
-	// Config file
-	if configPath != "" {
-		v.SetConfigFile(configPath)
-	} else {
-		v.SetConfigName("config")
-		v.SetConfigType("yaml")
-		v.AddConfigPath("./configs")
-		v.AddConfigPath(".")
-	}
+	v.SetDefault("auth.session_store", "database")
+	v.SetDefault("auth.session_cleanup_interval", "1h")
+	v.SetDefault("auth.bcrypt_cost", 10)
+	v.SetDefault("auth.trusted_proxy.enabled", false)
+	v.SetDefault("auth.trusted_proxy.header", "X-Forwarded-User")
+	v.SetDefault("auth.trusted_proxy.cidrs", []string{})
+	v.SetDefault("auth.trusted_proxy.default_role", "admin")
+	v.SetDefault("ingest.dedup_window", "24h")
+	v.SetDefault("ingest.async.queue_size", 1000)
+	v.SetDefault("ingest.async.workers", 2)
+	v.SetDefault("ingest.max_lengths.error_type", 256)
+	v.SetDefault("ingest.max_lengths.error_message", 8192)
+	v.SetDefault("ingest.max_lengths.frame_field", 1024)
+	v.SetDefault("ingest.preserve_numbers", true)
+	v.SetDefault("ingest.metadata_limits.max_depth", 10)
+	v.SetDefault("ingest.metadata_limits.max_bytes", 64<<10)
+	v.SetDefault("ingest.metadata_limits.action", "trim")
+	v.SetDefault("ingest.aggregate_threshold", 0)
+	v.SetDefault("ingest.max_decompressed_bytes", 10<<20)
+	v.SetDefault("ingest.max_concurrent_submissions", 100)
+	v.SetDefault("ingest.quarantine.enabled", true)
+	v.SetDefault("ingest.quarantine.flood_threshold", 100)
+	v.SetDefault("ingest.quarantine.flood_window", "1m")
+	v.SetDefault("ingest.rate_limit.rate", 100)
+	v.SetDefault("ingest.rate_limit.burst", 200)
+	v.SetDefault("ingest.geoip.enabled", false)
+	v.SetDefault("ingest.geoip.database_path", "")
+	v.SetDefault("ingest.symbolication.enabled", true)
+	v.SetDefault("ingest.symbolication.dsym_path", "./data/dsyms")
+	v.SetDefault("ingest.symbolication.max_upload_bytes", 512<<20)
+	v.SetDefault("ingest.symbolication.cache_size", 4)
+	v.SetDefault("ingest.deobfuscation.enabled", true)
+	v.SetDefault("ingest.deobfuscation.mapping_path", "./data/mappings")
+	v.SetDefault("ingest.deobfuscation.max_upload_bytes", 256<<20)
+	v.SetDefault("ingest.deobfuscation.cache_size", 4)
+	v.SetDefault("ingest.source_maps.enabled", true)
+	v.SetDefault("ingest.source_maps.path", "./data/sourcemaps")
+	v.SetDefault("ingest.source_maps.max_upload_bytes", 64<<20)
+	v.SetDefault("ingest.source_maps.cache_size", 16)
+	v.SetDefault("grouping.title_strategy", "type_message")
+	v.SetDefault("grouping.frame_limit", 5)
+	v.SetDefault("grouping.frame_weighting", "flat")
+	v.SetDefault("grouping.weighted.full_frames", 1)
+	v.SetDefault("grouping.weighted.coarse_frames", 0)
+	v.SetDefault("grouping.apple_frames.enabled", false)
+	v.SetDefault("grouping.apple_frames.offset_bucket", 4096)
+	v.SetDefault("grouping.churn.max_new_groups", 0)
+	v.SetDefault("grouping.churn.window", "10m")
+	v.SetDefault("grouping.churn.fallback_duration", "0")
+	v.SetDefault("grouping.max_groups_per_app", 0)
+	v.SetDefault("grouping.historical_after", "0")
+	v.SetDefault("grouping.error_type_fallback", "")
+	v.SetDefault("logging.access_log.enabled", true)
+	v.SetDefault("logging.access_log.sample_rate", 1)
+	v.SetDefault("logging.access_log.fields", []string{"method", "path", "status", "latency", "client_ip"})
+	v.SetDefault("logging.access_log.skip_paths", []string{"/health", "/ready"})
 
 	// Environment variables
 	v.SetEnvPrefix("INCEPTOR")
 	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	v.AutomaticEnv()
 
-	// Read config file (optional)
-	if err := v.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-			return nil, err
+	var files []string
+	for _, path := range configPaths {
+		if path != "" {
+			files = append(files, path)
+		}
+	}
+	if len(files) == 0 {
+		// Look for an optional config.yaml
+		v.SetConfigName("config")
+		v.SetConfigType("yaml")
+		v.AddConfigPath("./configs")
+		v.AddConfigPath(".")
+		if err := v.ReadInConfig(); err != nil {
+			if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+				return nil, err
+			}
+		}
+		if used := v.ConfigFileUsed(); used != "" {
+			files = append(files, used)
+		}
+	} else {
+		// Each file overrides the settings of the files before it
+		for _, path := range files {
+			v.SetConfigFile(path)
+			if err := v.MergeInConfig(); err != nil {
+				return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+			}
 		}
 	}
 
@@ -94,6 +563,7 @@ func Load(configPath string) (*Config, error) {
 	if err := v.Unmarshal(&cfg); err != nil {
 		return nil, err
 	}
+	cfg.Files = files
 
 	return &cfg, nil
 }
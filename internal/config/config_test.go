@@ -0,0 +1,260 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestMain(m *testing.M) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	os.Exit(m.Run())
+}
+
+func TestAllAdminKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		auth AuthConfig
+		want []string
+	}{
+		{"none", AuthConfig{}, nil},
+		{"primary only", AuthConfig{AdminKey: "a"}, []string{"a"}},
+		{"primary and old keys", AuthConfig{AdminKey: "a", AdminKeys: []string{"b", "c"}}, []string{"a", "b", "c"}},
+		{"old keys only", AuthConfig{AdminKeys: []string{"b"}}, []string{"b"}},
+		{"empty entries skipped", AuthConfig{AdminKey: "", AdminKeys: []string{"", "b"}}, []string{"b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.auth.AllAdminKeys(); !slices.Equal(got, tt.want) {
+				t.Errorf("AllAdminKeys() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplicaSettings(t *testing.T) {
+	tests := []struct {
+		name           string
+		env            map[string]string
+		wantDSNs       []string
+		readAfterWrite time.Duration
+	}{
+		{"defaults", nil, []string{}, 5 * time.Second},
+		{
+			name:           "from environment",
+			env:            map[string]string{"INCEPTOR_STORAGE_REPLICA_DSNS": "host=replica1,host=replica2", "INCEPTOR_STORAGE_REPLICA_READ_AFTER_WRITE": "2s"},
+			wantDSNs:       []string{"host=replica1", "host=replica2"},
+			readAfterWrite: 2 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			if !slices.Equal(cfg.Storage.ReplicaDSNs, tt.wantDSNs) {
+				t.Errorf("ReplicaDSNs = %q, want %q", cfg.Storage.ReplicaDSNs, tt.wantDSNs)
+			}
+			if cfg.Storage.ReplicaReadAfterWrite != tt.readAfterWrite {
+				t.Errorf("ReplicaReadAfterWrite = %v, want %v", cfg.Storage.ReplicaReadAfterWrite, tt.readAfterWrite)
+			}
+		})
+	}
+}
+
+func TestAlertsThresholdInterval(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want time.Duration
+	}{
+		{"default", nil, time.Minute},
+		{"from environment", map[string]string{"INCEPTOR_ALERTS_THRESHOLD_INTERVAL": "15s"}, 15 * time.Second},
+		{"disabled", map[string]string{"INCEPTOR_ALERTS_THRESHOLD_INTERVAL": "0s"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			if cfg.Alerts.ThresholdInterval != tt.want {
+				t.Errorf("ThresholdInterval = %v, want %v", cfg.Alerts.ThresholdInterval, tt.want)
+			}
+		})
+	}
+}
+
+func TestBcryptCost(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want int
+	}{
+		{"default", nil, 10},
+		{"from environment", map[string]string{"INCEPTOR_AUTH_BCRYPT_COST": "12"}, 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			if cfg.Auth.BcryptCost != tt.want {
+				t.Errorf("BcryptCost = %d, want %d", cfg.Auth.BcryptCost, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionStore(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{"default", nil, "database"},
+		{"from environment", map[string]string{"INCEPTOR_AUTH_SESSION_STORE": "memory"}, "memory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			if cfg.Auth.SessionStore != tt.want {
+				t.Errorf("SessionStore = %q, want %q", cfg.Auth.SessionStore, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrustedProxyDefaultRole(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{"default", nil, "admin"},
+		{"from environment", map[string]string{"INCEPTOR_AUTH_TRUSTED_PROXY_DEFAULT_ROLE": "viewer"}, "viewer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			if cfg.Auth.TrustedProxy.DefaultRole != tt.want {
+				t.Errorf("DefaultRole = %q, want %q", cfg.Auth.TrustedProxy.DefaultRole, tt.want)
+			}
+		})
+	}
+}
+
+func TestRootConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want RootConfig
+	}{
+		{"default", nil, RootConfig{Mode: "dashboard", Name: "Inceptor"}},
+		{"status", map[string]string{"INCEPTOR_SERVER_ROOT_MODE": "status", "INCEPTOR_SERVER_ROOT_MESSAGE": "Internal use only"}, RootConfig{Mode: "status", Name: "Inceptor", Message: "Internal use only"}},
+		{"redirect", map[string]string{"INCEPTOR_SERVER_ROOT_MODE": "redirect", "INCEPTOR_SERVER_ROOT_REDIRECT_URL": "https://example.com"}, RootConfig{Mode: "redirect", RedirectURL: "https://example.com", Name: "Inceptor"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			if cfg.Server.Root != tt.want {
+				t.Errorf("Root = %+v, want %+v", cfg.Server.Root, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, contents string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	base := write("base.yaml", "server:\n  rest_port: 9000\nretention:\n  default_days: 60\n")
+	override := write("override.yaml", "retention:\n  default_days: 7\n")
+	invalid := write("invalid.yaml", "retention: [\n")
+
+	tests := []struct {
+		name     string
+		files    []string
+		env      map[string]string
+		wantPort int
+		wantDays int
+		wantErr  string
+	}{
+		{"defaults", nil, nil, 8080, 30, ""},
+		{"one file", []string{base}, nil, 9000, 60, ""},
+		{"later file wins", []string{base, override}, nil, 9000, 7, ""},
+		{"order matters", []string{override, base}, nil, 9000, 60, ""},
+		{"empty paths skipped", []string{"", override}, nil, 8080, 7, ""},
+		{"environment wins", []string{base, override}, map[string]string{"INCEPTOR_RETENTION_DEFAULT_DAYS": "90"}, 9000, 90, ""},
+		{"missing file", []string{base, filepath.Join(dir, "missing.yaml")}, nil, 0, 0, "missing.yaml"},
+		{"invalid file", []string{invalid}, nil, 0, 0, "invalid.yaml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			cfg, err := Load(tt.files...)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load = %v, want an error naming %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			if cfg.Server.RESTPort != tt.wantPort || cfg.Retention.DefaultDays != tt.wantDays {
+				t.Errorf("port, retention = %d, %d; want %d, %d", cfg.Server.RESTPort, cfg.Retention.DefaultDays, tt.wantPort, tt.wantDays)
+			}
+			var wantFiles []string
+			for _, f := range tt.files {
+				if f != "" {
+					wantFiles = append(wantFiles, f)
+				}
+			}
+			if !slices.Equal(cfg.Files, wantFiles) {
+				t.Errorf("Files = %v, want %v", cfg.Files, wantFiles)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+)
+
+// HotReloadable lists the settings a running server applies when its config
+// files change. Any other change, such as a port or the database path, is
+// only logged and takes a restart.
+var HotReloadable = map[string]bool{
+	"retention.default_days":  true,
+	"ingest.rate_limit.rate":  true,
+	"ingest.rate_limit.burst": true,
+}
+
+// Change is a setting whose value differs between two configs
+type Change struct {
+	// Key is the setting's dotted name, e.g. "retention.default_days"
+	Key string
+	Old interface{}
+	New interface{}
+}
+
+// HotReload reports whether a running server applies the change
+func (c Change) HotReload() bool {
+	return HotReloadable[c.Key]
+}
+
+// Diff lists the settings whose values differ between two configs
+func Diff(old, new *Config) []Change {
+	var changes []Change
+	diffValues("", reflect.ValueOf(*old), reflect.ValueOf(*new), &changes)
+	return changes
+}
+
+// diffValues compares two values of a config struct field by field, keyed
+// by their mapstructure names
+func diffValues(key string, old, new reflect.Value, changes *[]Change) {
+	if old.Kind() != reflect.Struct {
+		if !reflect.DeepEqual(old.Interface(), new.Interface()) {
+			*changes = append(*changes, Change{Key: key, Old: old.Interface(), New: new.Interface()})
+		}
+		return
+	}
+
+	t := old.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get("mapstructure")
+		if name == "" || name == "-" {
+			continue
+		}
+		if key != "" {
+			name = key + "." + name
+		}
+		diffValues(name, old.Field(i), new.Field(i), changes)
+	}
+}
+
+// watchDebounce waits for a burst of file events, such as an editor's
+// write and rename, to settle before reloading
+const watchDebounce = 250 * time.Millisecond
+
+// Watch reloads the config from current's files whenever one of them
+// changes, calling onReload with the new config and the settings that
+// changed, until ctx is done. Files are watched through their directories,
+// so editors that replace a file rather than write it in place are
+// noticed. A config that fails to load is logged and skipped.
+func Watch(ctx context.Context, current *Config, onReload func(cfg *Config, changes []Change)) error {
+	if len(current.Files) == 0 {
+		return fmt.Errorf("no config files to watch")
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create config watcher: %w", err)
+	}
+	files := make(map[string]bool, len(current.Files))
+	dirs := make(map[string]bool)
+	for _, file := range current.Files {
+		path, err := filepath.Abs(file)
+		if err != nil {
+			watcher.Close()
+			return fmt.Errorf("failed to resolve config file %s: %w", file, err)
+		}
+		files[path] = true
+		dir := filepath.Dir(path)
+		if !dirs[dir] {
+			if err := watcher.Add(dir); err != nil {
+				watcher.Close()
+				return fmt.Errorf("failed to watch %s: %w", dir, err)
+			}
+			dirs[dir] = true
+		}
+	}
+
+	log.Info().Strs("files", current.Files).Msg("Watching config files for changes")
+	go func() {
+		defer watcher.Close()
+		debounce := time.NewTimer(0)
+		<-debounce.C
+		for {
+			select {
+			case <-ctx.Done():
+				debounce.Stop()
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if path, err := filepath.Abs(event.Name); err == nil && files[path] {
+					debounce.Reset(watchDebounce)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Error().Err(err).Msg("Config watcher error")
+			case <-debounce.C:
+				cfg, err := Load(current.Files...)
+				if err != nil {
+					log.Error().Err(err).Msg("Failed to reload config, keeping the current one")
+					continue
+				}
+				if changes := Diff(current, cfg); len(changes) > 0 {
+					onReload(cfg, changes)
+				}
+				current = cfg
+			}
+		}
+	}()
+	return nil
+}
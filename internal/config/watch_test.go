@@ -0,0 +1,119 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	base, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(cfg *Config)
+		want   []Change
+	}{
+		{"unchanged", func(cfg *Config) {}, nil},
+		{"hot setting", func(cfg *Config) { cfg.Retention.DefaultDays = 7 }, []Change{{Key: "retention.default_days", Old: 30, New: 7}}},
+		{"nested settings", func(cfg *Config) {
+			cfg.Ingest.RateLimit.Rate = 5
+			cfg.Ingest.RateLimit.Burst = 10
+		}, []Change{{Key: "ingest.rate_limit.rate", Old: base.Ingest.RateLimit.Rate, New: float64(5)}, {Key: "ingest.rate_limit.burst", Old: 200, New: 10}}},
+		{"restart setting", func(cfg *Config) { cfg.Server.RESTPort = 9000 }, []Change{{Key: "server.rest_port", Old: 8080, New: 9000}}},
+		{"slice", func(cfg *Config) { cfg.Auth.AdminKeys = []string{"old"} }, []Change{{Key: "auth.admin_keys", Old: base.Auth.AdminKeys, New: []string{"old"}}}},
+		// Files aren't a setting
+		{"files", func(cfg *Config) { cfg.Files = []string{"other.yaml"} }, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed, _ := Load()
+			tt.modify(changed)
+			if got := Diff(base, changed); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	hot := map[string]bool{"retention.default_days": true, "ingest.rate_limit.rate": true, "server.rest_port": false}
+	for key, want := range hot {
+		if got := (Change{Key: key}).HotReload(); got != want {
+			t.Errorf("%s: HotReload = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	write := func(contents string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("retention:\n  default_days: 60\n")
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reloads := make(chan []Change, 10)
+	if err := Watch(ctx, cfg, func(cfg *Config, changes []Change) { reloads <- changes }); err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	// next returns the changes of the next reload, or nil if there is none
+	next := func() []Change {
+		t.Helper()
+		select {
+		case changes := <-reloads:
+			return changes
+		case <-time.After(4 * watchDebounce):
+			return nil
+		}
+	}
+
+	tests := []struct {
+		name   string
+		update func()
+		want   []Change
+	}{
+		{"written in place", func() { write("retention:\n  default_days: 7\n") }, []Change{{Key: "retention.default_days", Old: 60, New: 7}}},
+		{"other file in the directory", func() { os.WriteFile(filepath.Join(dir, "other.yaml"), []byte("x: 1\n"), 0o644) }, nil},
+		{"no change", func() { write("retention:\n  default_days: 7\n") }, nil},
+		// An invalid config is skipped, so the next change is from the last
+		// valid one
+		{"invalid", func() { write("retention: [\n") }, nil},
+		{"replaced", func() {
+			tmp := filepath.Join(dir, "config.yaml.tmp")
+			os.WriteFile(tmp, []byte("retention:\n  default_days: 14\n"), 0o644)
+			os.Rename(tmp, path)
+		}, []Change{{Key: "retention.default_days", Old: 7, New: 14}}},
+	}
+	for _, tt := range tests {
+		tt.update()
+		if got := next(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: reloaded with %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	// Nothing is reloaded once ctx is done
+	cancel()
+	time.Sleep(watchDebounce)
+	write("retention:\n  default_days: 1\n")
+	if got := next(); got != nil {
+		t.Errorf("reloaded with %+v after ctx was done", got)
+	}
+
+	if err := Watch(context.Background(), &Config{}, func(*Config, []Change) {}); err == nil {
+		t.Error("Watch without files succeeded, want an error")
+	}
+}
@@ -0,0 +1,127 @@
+package core
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestActionLinks(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	links := NewActionLinks("secret", time.Hour)
+	token := links.Sign(ActionResolve, "group-1", now)
+
+	// tampered is token with its expiry moved, keeping the signature
+	parts := strings.Split(token, ".")
+	tampered := func(expires time.Time) string {
+		return strings.Join([]string{strconv.FormatInt(expires.Unix(), 10), parts[1], parts[2]}, ".")
+	}
+
+	tests := []struct {
+		name    string
+		links   *ActionLinks
+		action  string
+		group   string
+		token   string
+		at      time.Time
+		wantErr error
+	}{
+		{"other group", links, ActionResolve, "group-2", token, now, ErrActionTokenInvalid},
+		{"other action", links, "ignore", "group-1", token, now, ErrActionTokenInvalid},
+		{"other secret", NewActionLinks("other", time.Hour), ActionResolve, "group-1", token, now, ErrActionTokenInvalid},
+		{"malformed", links, ActionResolve, "group-1", "not-a-token", now, ErrActionTokenInvalid},
+		{"empty", links, ActionResolve, "group-1", "", now, ErrActionTokenInvalid},
+		{"extended expiry", links, ActionResolve, "group-1", tampered(now.Add(24 * time.Hour)), now, ErrActionTokenInvalid},
+		{"expired", links, ActionResolve, "group-1", token, now.Add(time.Hour), ErrActionTokenExpired},
+		{"valid", links, ActionResolve, "group-1", token, now.Add(59 * time.Minute), nil},
+		{"used", links, ActionResolve, "group-1", token, now.Add(59 * time.Minute), ErrActionTokenUsed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.links.Verify(tt.action, tt.group, tt.token, tt.at); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Tokens for the same group are distinct, so each works once
+	other := links.Sign(ActionResolve, "group-1", now)
+	if other == token {
+		t.Fatal("Sign returned the same token twice")
+	}
+	if err := links.Verify(ActionResolve, "group-1", other, now); err != nil {
+		t.Errorf("Verify second token = %v", err)
+	}
+
+	// Checking a token doesn't use it up
+	checked := links.Sign(ActionResolve, "group-1", now)
+	for i := 0; i < 2; i++ {
+		if err := links.Check(ActionResolve, "group-1", checked, now); err != nil {
+			t.Fatalf("Check #%d = %v", i+1, err)
+		}
+	}
+	if err := links.Check(ActionResolve, "group-2", checked, now); !errors.Is(err, ErrActionTokenInvalid) {
+		t.Errorf("Check other group = %v, want %v", err, ErrActionTokenInvalid)
+	}
+	if err := links.Verify(ActionResolve, "group-1", checked, now); err != nil {
+		t.Fatalf("Verify checked token = %v", err)
+	}
+	if err := links.Check(ActionResolve, "group-1", checked, now); !errors.Is(err, ErrActionTokenUsed) {
+		t.Errorf("Check used token = %v, want %v", err, ErrActionTokenUsed)
+	}
+
+	// Used tokens are forgotten once they expire
+	links.Verify(ActionResolve, "group-1", links.Sign(ActionResolve, "group-1", now.Add(2*time.Hour)), now.Add(2*time.Hour))
+	links.mu.Lock()
+	defer links.mu.Unlock()
+	if len(links.used) != 1 {
+		t.Errorf("remembered tokens = %d, want only the unexpired one", len(links.used))
+	}
+}
+
+func TestResolveURL(t *testing.T) {
+	links := NewActionLinks("secret", time.Hour)
+	tests := []struct {
+		name         string
+		dashboardURL string
+		links        *ActionLinks
+		groupID      string
+		wantPrefix   string
+	}{
+		{"enabled", "https://crashes.example.com/", links, "group-1", "https://crashes.example.com/api/v1/groups/group-1/resolve?token="},
+		{"escaped group", "https://crashes.example.com", links, "a/b", "https://crashes.example.com/api/v1/groups/a%2Fb/resolve?token="},
+		{"disabled", "https://crashes.example.com", nil, "group-1", ""},
+		{"no dashboard URL", "", links, "group-1", ""},
+		{"no group", "https://crashes.example.com", links, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			am := newTestAlertManager(t)
+			am.SetDashboardURL(tt.dashboardURL)
+			if tt.links != nil {
+				am.SetActionLinks(tt.links)
+			}
+
+			link := am.resolveURL(tt.groupID)
+			if tt.wantPrefix == "" {
+				if link != "" {
+					t.Errorf("resolveURL = %q, want none", link)
+				}
+				return
+			}
+			if !strings.HasPrefix(link, tt.wantPrefix) {
+				t.Fatalf("resolveURL = %q, want prefix %q", link, tt.wantPrefix)
+			}
+			u, err := url.Parse(link)
+			if err != nil {
+				t.Fatalf("parse %q: %v", link, err)
+			}
+			if err := tt.links.Verify(ActionResolve, tt.groupID, u.Query().Get("token"), time.Now()); err != nil {
+				t.Errorf("link token: %v", err)
+			}
+		})
+	}
+}
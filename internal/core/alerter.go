@@ -7,22 +7,72 @@ import (
 	"fmt"
 	"net/http"
 	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog/log"
 )
 
 // AlertManager handles sending alerts when crashes occur
 type AlertManager struct {
-	alerts    []*Alert
-	alertsMu  sync.RWMutex
-	smtpCfg   SMTPConfig
-	slackURL  string
-	client    *http.Client
-	queue     chan AlertEvent
-	ctx       context.Context
-	cancel    context.CancelFunc
+	alerts   []*Alert
+	alertsMu sync.RWMutex
+	smtpCfg  SMTPConfig
+	slackURL string
+	// dashboardURL is the dashboard's base URL, for links back to crashes
+	dashboardURL string
+	// actionLinks signs one-click links in emails; nil leaves them out
+	actionLinks *ActionLinks
+	client      *http.Client
+	queue       chan *QueuedAlertEvent
+	// queueMu guards closing the queue and queueRepo; closed is set once the
+	// queue is closed, and done once the worker has exited
+	queueMu sync.RWMutex
+	closed  bool
+	done    chan struct{}
+	// queueRepo stores queued events until they are sent, once
+	// StartDurableQueue is called. inFlight holds the IDs of stored events
+	// waiting in the in-memory queue, which polling skips; it is guarded by
+	// inFlightMu. polls asks the worker to poll for events due by a time.
+	queueRepo  AlertQueueRepository
+	inFlight   map[int64]bool
+	inFlightMu sync.Mutex
+	polls      chan time.Time
+	// counter tracks recent occurrences per group for rate-based conditions
+	counter *GroupCounter
+	// velocityFired records when a velocity condition last fired. It is
+	// guarded by stateMu.
+	velocityFired map[string]time.Time
+	// notified records when each alert last notified about each group, for
+	// alert cooldowns. It is guarded by stateMu.
+	notified map[string]time.Time
+	// recentGroups holds the latest crash event of each group that crashed
+	// recently, the candidates for threshold conditions; thresholdStates
+	// tracks those conditions per alert and group. Both are guarded by
+	// stateMu.
+	recentGroups    map[string]AlertEvent
+	thresholdStates map[thresholdKey]thresholdState
+	stateMu         sync.Mutex
+	// maintenance holds each app's maintenance windows; suppressed counts
+	// the alerts each window held back. Both are guarded by maintenanceMu.
+	maintenance   map[string][]MaintenanceWindow
+	suppressed    map[string]int
+	maintenanceMu sync.Mutex
+	// coalescing holds events for alerts with a coalesce window, keyed by
+	// alert and app. It is only touched by the worker; flushes receives the
+	// keys whose window has elapsed.
+	coalescing map[string]*coalesceBuffer
+	flushes    chan string
+	// sends and sendErrors count alert deliveries and failed deliveries by
+	// alert type, for the metrics endpoint
+	sends      *prometheus.CounterVec
+	sendErrors *prometheus.CounterVec
+	ctx        context.Context
+	cancel     context.CancelFunc
 }
 
 // SMTPConfig holds SMTP configuration
@@ -36,20 +86,40 @@ type SMTPConfig struct {
 
 // AlertEvent represents an event that may trigger alerts
 type AlertEvent struct {
-	Type      AlertEventType
-	AppID     string
-	Crash     *Crash
-	Group     *CrashGroup
+	Type       AlertEventType
+	AppID      string
+	Crash      *Crash
+	Group      *CrashGroup
 	IsNewGroup bool
+	// IsNewVersion is set on the first crash ever seen for the crash's app version
+	IsNewVersion bool
+	// Replay is set when the event is re-sent for a stored crash; channels
+	// mark the notification so it isn't mistaken for a new occurrence
+	Replay bool
+	// Threshold describes the crossing for AlertEventThreshold events
+	Threshold *ThresholdCrossing
+	// PreviousStatus is the status a group had before the crash of an
+	// AlertEventRegression event reopened it
+	PreviousStatus string
 }
 
 // AlertEventType defines types of alertable events
 type AlertEventType string
 
 const (
-	AlertEventNewCrash    AlertEventType = "new_crash"
-	AlertEventNewGroup    AlertEventType = "new_group"
-	AlertEventThreshold   AlertEventType = "threshold"
+	AlertEventNewCrash  AlertEventType = "new_crash"
+	AlertEventNewGroup  AlertEventType = "new_group"
+	AlertEventThreshold AlertEventType = "threshold"
+	AlertEventPing      AlertEventType = "ping"
+	AlertEventChurn     AlertEventType = "group_churn"
+	// AlertEventCoalesced summarizes several events sent as one message
+	AlertEventCoalesced AlertEventType = "coalesced"
+	// AlertEventResolved is sent when a group is marked resolved, to close
+	// the incidents its crashes opened
+	AlertEventResolved AlertEventType = "group_resolved"
+	// AlertEventRegression is sent instead of AlertEventNewCrash when the
+	// crash reopened a resolved group
+	AlertEventRegression AlertEventType = "regression"
 )
 
 // NewAlertManager creates a new AlertManager
@@ -57,13 +127,33 @@ func NewAlertManager(smtpCfg SMTPConfig, slackURL string) *AlertManager {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	am := &AlertManager{
-		alerts:   make([]*Alert, 0),
-		smtpCfg:  smtpCfg,
-		slackURL: slackURL,
-		client:   &http.Client{Timeout: 10 * time.Second},
-		queue:    make(chan AlertEvent, 100),
-		ctx:      ctx,
-		cancel:   cancel,
+		alerts:          make([]*Alert, 0),
+		smtpCfg:         smtpCfg,
+		slackURL:        slackURL,
+		client:          &http.Client{Timeout: 10 * time.Second},
+		queue:           make(chan *QueuedAlertEvent, 100),
+		done:            make(chan struct{}),
+		inFlight:        make(map[int64]bool),
+		polls:           make(chan time.Time),
+		counter:         NewGroupCounter(time.Minute, maxVelocityWindow*2),
+		velocityFired:   make(map[string]time.Time),
+		notified:        make(map[string]time.Time),
+		recentGroups:    make(map[string]AlertEvent),
+		thresholdStates: make(map[thresholdKey]thresholdState),
+		maintenance:     make(map[string][]MaintenanceWindow),
+		suppressed:      make(map[string]int),
+		coalescing:      make(map[string]*coalesceBuffer),
+		flushes:         make(chan string),
+		sends: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "inceptor_alert_send_total",
+			Help: "Alert deliveries, each counted once however often it was retried.",
+		}, []string{"type"}),
+		sendErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "inceptor_alert_send_errors_total",
+			Help: "Alert deliveries that failed.",
+		}, []string{"type"}),
+		ctx:    ctx,
+		cancel: cancel,
 	}
 
 	// Start worker
@@ -72,6 +162,29 @@ func NewAlertManager(smtpCfg SMTPConfig, slackURL string) *AlertManager {
 	return am
 }
 
+// SetDashboardURL sets the dashboard's base URL, used to link alerts back
+// to their crash. Call it before any events are sent.
+func (am *AlertManager) SetDashboardURL(url string) {
+	am.dashboardURL = strings.TrimSuffix(url, "/")
+}
+
+// SetActionLinks enables one-click resolve links in alert emails, signed by
+// links. They need the dashboard URL, as the API is served from the same
+// address. Call it before any events are sent.
+func (am *AlertManager) SetActionLinks(links *ActionLinks) {
+	am.actionLinks = links
+}
+
+// resolveURL returns a one-click link resolving a group, or "" when action
+// links are disabled
+func (am *AlertManager) resolveURL(groupID string) string {
+	if am.actionLinks == nil || am.dashboardURL == "" || groupID == "" {
+		return ""
+	}
+	token := am.actionLinks.Sign(ActionResolve, groupID, time.Now())
+	return fmt.Sprintf("%s/api/v1/groups/%s/resolve?token=%s", am.dashboardURL, url.PathEscape(groupID), url.QueryEscape(token))
+}
+
 // SetAlerts updates the list of configured alerts
 func (am *AlertManager) SetAlerts(alerts []*Alert) {
 	am.alertsMu.Lock()
@@ -86,43 +199,193 @@ func (am *AlertManager) AddAlert(alert *Alert) {
 	am.alerts = append(am.alerts, alert)
 }
 
-// Notify queues an alert event for processing
+// SetMaintenanceWindows replaces an app's maintenance windows
+func (am *AlertManager) SetMaintenanceWindows(appID string, windows []MaintenanceWindow) {
+	am.maintenanceMu.Lock()
+	defer am.maintenanceMu.Unlock()
+	if len(windows) == 0 {
+		delete(am.maintenance, appID)
+		return
+	}
+	am.maintenance[appID] = append([]MaintenanceWindow(nil), windows...)
+}
+
+// SuppressedCount returns how many alerts a maintenance window has held back
+// since the server started
+func (am *AlertManager) SuppressedCount(windowID string) int {
+	am.maintenanceMu.Lock()
+	defer am.maintenanceMu.Unlock()
+	return am.suppressed[windowID]
+}
+
+// activeMaintenance returns the app's maintenance window covering t, if any
+func (am *AlertManager) activeMaintenance(appID string, t time.Time) *MaintenanceWindow {
+	am.maintenanceMu.Lock()
+	defer am.maintenanceMu.Unlock()
+	for _, window := range am.maintenance[appID] {
+		if window.Active(t) {
+			return &window
+		}
+	}
+	return nil
+}
+
+// recordSuppressed counts an alert held back by a maintenance window
+func (am *AlertManager) recordSuppressed(window *MaintenanceWindow, alert *Alert, event AlertEvent) {
+	am.maintenanceMu.Lock()
+	am.suppressed[window.ID]++
+	am.maintenanceMu.Unlock()
+	log.Debug().
+		Str("alert_id", alert.ID).
+		Str("app_id", event.AppID).
+		Str("window_id", window.ID).
+		Str("event", string(event.Type)).
+		Msg("Alert suppressed by maintenance window")
+}
+
+// firesDuringMaintenance reads whether an alert ignores maintenance windows:
+//
+//	"fire_during_maintenance": true
+//
+// for critical channels that must page even during a deploy
+func firesDuringMaintenance(config map[string]interface{}) bool {
+	fire, _ := config["fire_during_maintenance"].(bool)
+	return fire
+}
+
+// Notify queues an alert event for processing. With a durable queue the
+// event is stored first, so it is sent even if the in-memory queue is full
+// or the server stops before processing it.
 func (am *AlertManager) Notify(event AlertEvent) {
+	am.queueMu.RLock()
+	defer am.queueMu.RUnlock()
+
+	if am.closed {
+		log.Warn().Msg("Alert manager is shutting down, dropping event")
+		return
+	}
+
+	item := &QueuedAlertEvent{Event: event}
+	if am.queueRepo != nil {
+		// Leave the event to polling if the in-memory queue is full
+		if len(am.queue) == cap(am.queue) {
+			am.persist(am.queueRepo, item, false)
+			if item.ID != 0 {
+				log.Warn().Msg("Alert queue full, leaving event in the durable queue")
+				return
+			}
+		} else {
+			am.persist(am.queueRepo, item, true)
+		}
+	}
+
 	select {
-	case am.queue <- event:
+	case am.queue <- item:
 	default:
+		if item.ID != 0 {
+			// Polling picks it up once its claim runs out
+			am.setInFlight(item.ID, false)
+			log.Warn().Msg("Alert queue full, leaving event in the durable queue")
+			return
+		}
 		log.Warn().Msg("Alert queue full, dropping event")
 	}
 }
 
-// Close shuts down the alert manager
+// closeQueue stops accepting events; the worker exits once it has drained
+// the queue
+func (am *AlertManager) closeQueue() {
+	am.queueMu.Lock()
+	defer am.queueMu.Unlock()
+	if !am.closed {
+		am.closed = true
+		close(am.queue)
+	}
+}
+
+// Shutdown stops accepting events and waits until the queued ones and any
+// coalesced events still inside their window have been sent. If ctx ends
+// first, delivery is abandoned and ctx's error is returned.
+func (am *AlertManager) Shutdown(ctx context.Context) error {
+	am.closeQueue()
+	defer am.cancel()
+
+	select {
+	case <-am.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close shuts down the alert manager without waiting for queued events
 func (am *AlertManager) Close() {
 	am.cancel()
-	close(am.queue)
+	am.closeQueue()
 }
 
 // worker processes alert events
 func (am *AlertManager) worker() {
+	defer close(am.done)
+	pruneTicker := time.NewTicker(time.Hour)
+	defer pruneTicker.Stop()
+	pollTicker := time.NewTicker(alertQueuePollInterval)
+	defer pollTicker.Stop()
+	// Don't lose coalesced events still waiting for their window on shutdown
+	defer am.flushAllCoalesced()
+
 	for {
 		select {
 		case <-am.ctx.Done():
 			return
-		case event, ok := <-am.queue:
+		case item, ok := <-am.queue:
 			if !ok {
 				return
 			}
-			am.processEvent(event)
+			am.processQueued(item)
+		case key := <-am.flushes:
+			am.flushCoalesced(key)
+		case dueBy := <-am.polls:
+			am.pollQueue(dueBy)
+		case now := <-pollTicker.C:
+			am.pollQueue(now)
+		case <-pruneTicker.C:
+			am.counter.Prune(time.Now().Add(-maxVelocityWindow * 2))
+			am.pruneRecentGroups(time.Now().Add(-maxThresholdWindow))
+			am.pruneNotified(time.Now().Add(-maxAlertCooldown))
 		}
 	}
 }
 
-// processEvent processes a single alert event
-func (am *AlertManager) processEvent(event AlertEvent) {
+// processEvent processes a single alert event, returning the alerts that
+// failed to send it
+func (am *AlertManager) processEvent(event AlertEvent) []alertFailure {
+	if event.Type == AlertEventResolved {
+		am.processResolved(event)
+		return nil
+	}
+
+	// Count every crash once, before any alert evaluates rate conditions
+	if event.Group != nil && event.Crash != nil &&
+		(event.Type == AlertEventNewCrash || event.Type == AlertEventNewGroup || event.Type == AlertEventRegression) {
+		am.counter.Record(event.Group.ID, event.Crash.CreatedAt)
+		am.recordRecentGroup(event)
+	}
+
+	// Windows are matched against when the crash happened, not when the
+	// queued event is processed
+	at := time.Now()
+	if event.Crash != nil {
+		at = event.Crash.CreatedAt
+	}
+	maintenance := am.activeMaintenance(event.AppID, at)
+
 	am.alertsMu.RLock()
 	alerts := make([]*Alert, len(am.alerts))
 	copy(alerts, am.alerts)
 	am.alertsMu.RUnlock()
 
+	var failures []alertFailure
 	for _, alert := range alerts {
 		if !alert.Enabled {
 			continue
@@ -132,16 +395,383 @@ func (am *AlertManager) processEvent(event AlertEvent) {
 			continue
 		}
 
+		var suppressBy *MaintenanceWindow
+		if maintenance != nil && !firesDuringMaintenance(alert.Config) {
+			suppressBy = maintenance
+		}
+
+		// Escalation rules are evaluated independently of the alert's conditions
+		escalationWindow := suppressBy
+		if maintenance != nil && maintenance.AllowEscalations {
+			escalationWindow = nil
+		}
+		am.processEscalations(alert, event, escalationWindow)
+
 		// Check if this alert type matches the event
 		if !am.shouldAlert(alert, event) {
 			continue
 		}
 
+		if suppressBy != nil {
+			am.recordSuppressed(suppressBy, alert, event)
+			continue
+		}
+
+		// A burst of crashes in one group notifies once per cooldown
+		if am.inCooldown(alert, event, at) {
+			log.Debug().Str("alert_id", alert.ID).Str("group_id", event.Group.ID).Msg("Alert in cooldown for group")
+			continue
+		}
+
+		// Hold the event back if the alert batches bursts into one message
+		if window := coalesceWindow(alert.Config); window > 0 {
+			am.coalesce(alert, event, window)
+			continue
+		}
+
 		// Send the alert
 		if err := am.sendAlert(alert, event); err != nil {
 			log.Error().Err(err).Str("alert_id", alert.ID).Msg("Failed to send alert")
+			failures = append(failures, alertFailure{alert, err})
+		}
+	}
+	return failures
+}
+
+// ReplayResult is the outcome of replaying an event to one alert
+type ReplayResult struct {
+	AlertID string `json:"alert_id"`
+	Type    string `json:"type"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Replay re-sends an event for a stored crash to every enabled alert whose
+// current conditions match it, returning one result per alert tried. It sends
+// synchronously and skips coalescing, escalations and velocity conditions,
+// which depend on live traffic rather than on the crash itself.
+func (am *AlertManager) Replay(event AlertEvent) []ReplayResult {
+	event.Replay = true
+
+	am.alertsMu.RLock()
+	alerts := make([]*Alert, len(am.alerts))
+	copy(alerts, am.alerts)
+	am.alertsMu.RUnlock()
+
+	results := []ReplayResult{}
+	for _, alert := range alerts {
+		if !alert.Enabled || (alert.AppID != "" && alert.AppID != event.AppID) {
+			continue
+		}
+		if !am.shouldAlert(alert, event) {
+			continue
+		}
+
+		result := ReplayResult{AlertID: alert.ID, Type: alert.Type}
+		if err := am.sendAlert(alert, event); err != nil {
+			log.Error().Err(err).Str("alert_id", alert.ID).Msg("Failed to replay alert")
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// defaultAlertCooldown is how long an alert stays quiet about a group after
+// notifying about it, unless the alert sets its own cooldown
+const defaultAlertCooldown = 5 * time.Minute
+
+// maxAlertCooldown is the longest cooldown an alert may use
+const maxAlertCooldown = 24 * time.Hour
+
+// alertCooldown reads an alert's cooldown, e.g.
+//
+//	"cooldown": "15m"
+//
+// Once the alert notifies about a group, further events for that group are
+// dropped until the cooldown has passed. It defaults to 5m, is capped at
+// maxAlertCooldown, and "0" disables it.
+func alertCooldown(config map[string]interface{}) time.Duration {
+	c, ok := config["cooldown"].(string)
+	if !ok {
+		return defaultAlertCooldown
+	}
+	d, err := time.ParseDuration(c)
+	if err != nil || d < 0 {
+		return defaultAlertCooldown
+	}
+	return min(d, maxAlertCooldown)
+}
+
+// inCooldown reports whether the alert notified about the event's group
+// less than its cooldown before at; otherwise it records at as the alert's
+// latest notification for the group. Events without a group, and threshold
+// events, which have a cooldown of their own, are never held back.
+func (am *AlertManager) inCooldown(alert *Alert, event AlertEvent, at time.Time) bool {
+	if event.Group == nil || event.Type == AlertEventThreshold {
+		return false
+	}
+	cooldown := alertCooldown(alert.Config)
+	if cooldown == 0 {
+		return false
+	}
+
+	key := alert.ID + "|" + event.Group.ID
+	am.stateMu.Lock()
+	defer am.stateMu.Unlock()
+	// A regression or a crash in a new version always notifies, and starts
+	// a new cooldown; each happens once, so neither can burst
+	if last, ok := am.notified[key]; ok && at.Sub(last) < cooldown && event.Type != AlertEventRegression && !event.IsNewVersion {
+		return true
+	}
+	am.notified[key] = at
+	return false
+}
+
+// pruneNotified forgets alert notifications sent before before, which no
+// cooldown can still cover
+func (am *AlertManager) pruneNotified(before time.Time) {
+	am.stateMu.Lock()
+	defer am.stateMu.Unlock()
+	for key, at := range am.notified {
+		if at.Before(before) {
+			delete(am.notified, key)
+		}
+	}
+}
+
+// coalesceBuffer collects the events for one alert and app until the
+// alert's coalesce window elapses
+type coalesceBuffer struct {
+	alert  *Alert
+	window time.Duration
+	events []AlertEvent
+}
+
+// coalesceWindow reads an alert's coalesce window, e.g.
+//
+//	"coalesce_window": "60s"
+//
+// Events matching the alert within the window after the first one are sent
+// as a single summary. Zero disables coalescing.
+func coalesceWindow(config map[string]interface{}) time.Duration {
+	w, ok := config["coalesce_window"].(string)
+	if !ok {
+		return 0
+	}
+	d, err := time.ParseDuration(w)
+	if err != nil || d <= 0 {
+		return 0
+	}
+	return d
+}
+
+// coalesce buffers an event, starting the flush timer if it is the first
+// event of a new window
+func (am *AlertManager) coalesce(alert *Alert, event AlertEvent, window time.Duration) {
+	key := alert.ID + "|" + event.AppID
+	if buf, ok := am.coalescing[key]; ok {
+		buf.events = append(buf.events, event)
+		return
+	}
+
+	am.coalescing[key] = &coalesceBuffer{alert: alert, window: window, events: []AlertEvent{event}}
+	time.AfterFunc(window, func() {
+		select {
+		case am.flushes <- key:
+		case <-am.ctx.Done():
+		}
+	})
+}
+
+// flushCoalesced sends the events buffered under key. A lone event is sent
+// as a normal alert; several are sent as one summary.
+func (am *AlertManager) flushCoalesced(key string) {
+	buf, ok := am.coalescing[key]
+	if !ok {
+		return
+	}
+	delete(am.coalescing, key)
+
+	var err error
+	if len(buf.events) == 1 {
+		err = am.sendAlert(buf.alert, buf.events[0])
+	} else {
+		err = am.sendCoalesced(buf.alert, buf.events, buf.window)
+		am.countSend(buf.alert, err)
+	}
+	if err != nil {
+		log.Error().Err(err).
+			Str("alert_id", buf.alert.ID).
+			Int("events", len(buf.events)).
+			Msg("Failed to send alert")
+	}
+}
+
+// flushAllCoalesced sends every buffered event without waiting for windows
+func (am *AlertManager) flushAllCoalesced() {
+	for key := range am.coalescing {
+		am.flushCoalesced(key)
+	}
+}
+
+// coalescedSummary describes a batch of events, e.g. "5 new groups in the last 1m"
+func coalescedSummary(events []AlertEvent, window time.Duration) string {
+	newGroups := 0
+	for _, event := range events {
+		if event.IsNewGroup {
+			newGroups++
 		}
 	}
+
+	what := fmt.Sprintf("%d alerts", len(events))
+	if newGroups == len(events) {
+		what = fmt.Sprintf("%d new groups", len(events))
+	}
+
+	return fmt.Sprintf("%s in the last %s", what, formatWindow(window))
+}
+
+// formatWindow formats a duration without zero units, so 60s reads "1m"
+// rather than "1m0s"
+func formatWindow(window time.Duration) string {
+	w := window.String()
+	if strings.HasSuffix(w, "m0s") {
+		w = strings.TrimSuffix(w, "0s")
+	}
+	if strings.HasSuffix(w, "h0m") {
+		w = strings.TrimSuffix(w, "0m")
+	}
+	return w
+}
+
+// coalescedLine describes one event in a coalesced message
+func coalescedLine(event AlertEvent) string {
+	if event.Crash == nil {
+		return string(event.Type)
+	}
+	line := event.Crash.ErrorType
+	if event.Crash.ErrorMessage != "" {
+		line += ": " + event.Crash.ErrorMessage
+	}
+	if event.Threshold != nil {
+		line += fmt.Sprintf(" (%s)", event.Threshold.Summary())
+	} else if event.Group != nil {
+		line += fmt.Sprintf(" (%d occurrences)", event.Group.OccurrenceCount)
+	}
+	return line
+}
+
+// sendCoalesced sends a batch of events as a single message via the
+// alert's channel
+func (am *AlertManager) sendCoalesced(alert *Alert, events []AlertEvent, window time.Duration) error {
+	switch alert.Type {
+	case "webhook":
+		return am.withRetry(alert, func() error { return am.sendCoalescedWebhook(alert, events, window) })
+	case "email":
+		return am.sendCoalescedEmail(alert, events, window)
+	case "slack":
+		return am.withRetry(alert, func() error { return am.sendCoalescedSlack(alert, events, window) })
+	case "discord":
+		return am.withRetry(alert, func() error { return am.sendCoalescedDiscord(alert, events, window) })
+	case "teams":
+		return am.withRetry(alert, func() error { return am.sendCoalescedTeams(alert, events, window) })
+	case "pagerduty":
+		return am.sendCoalescedPagerDuty(alert, events)
+	case "telegram":
+		return am.sendCoalescedTelegram(alert, events, window)
+	default:
+		return fmt.Errorf("unknown alert type: %s", alert.Type)
+	}
+}
+
+// EscalationRule routes an alert to a different channel once a group's
+// occurrence count reaches a threshold
+type EscalationRule struct {
+	Threshold int
+	Type      string
+	Config    map[string]interface{}
+}
+
+// parseEscalationRules reads escalation rules from an alert config, e.g.
+//
+//	"escalation": [
+//	  {"threshold": 1, "type": "slack"},
+//	  {"threshold": 100, "type": "webhook", "config": {"url": "https://..."}}
+//	]
+func parseEscalationRules(config map[string]interface{}) []EscalationRule {
+	raw, ok := config["escalation"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	var rules []EscalationRule
+	for _, r := range raw {
+		m, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		threshold, _ := m["threshold"].(float64)
+		alertType, _ := m["type"].(string)
+		if threshold < 1 || alertType == "" {
+			continue
+		}
+		rule := EscalationRule{Threshold: int(threshold), Type: alertType}
+		rule.Config, _ = m["config"].(map[string]interface{})
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// processEscalations fires every escalation rule whose threshold the group's
+// occurrence count crossed with the event's crash. Each event counts one
+// crash, so a threshold fires on the crash that takes the count from below
+// it to at least it: once per group, without keeping state that a restart
+// would lose. While suppressBy is set, crossed thresholds are counted as
+// suppressed instead.
+func (am *AlertManager) processEscalations(alert *Alert, event AlertEvent, suppressBy *MaintenanceWindow) {
+	if event.Group == nil || event.Crash == nil || event.Type == AlertEventChurn || event.Type == AlertEventThreshold {
+		return
+	}
+
+	count := event.Group.OccurrenceCount
+	for _, rule := range parseEscalationRules(alert.Config) {
+		if !escalationCrossed(rule.Threshold, count) {
+			continue
+		}
+		if suppressBy != nil {
+			am.recordSuppressed(suppressBy, alert, event)
+			continue
+		}
+
+		// Rule config overrides the parent alert config for the escalated channel
+		config := make(map[string]interface{}, len(alert.Config)+len(rule.Config))
+		for k, v := range alert.Config {
+			config[k] = v
+		}
+		for k, v := range rule.Config {
+			config[k] = v
+		}
+
+		escalated := &Alert{
+			ID:      alert.ID,
+			AppID:   alert.AppID,
+			Type:    rule.Type,
+			Config:  config,
+			Enabled: true,
+		}
+		if err := am.sendAlert(escalated, event); err != nil {
+			log.Error().Err(err).
+				Str("alert_id", alert.ID).
+				Int("threshold", rule.Threshold).
+				Msg("Failed to send escalation alert")
+		}
+	}
+}
+
+// escalationCrossed reports whether the crash that brought a group's
+// occurrence count to count crossed threshold
+func escalationCrossed(threshold, count int) bool {
+	return count-1 < threshold && threshold <= count
 }
 
 // shouldAlert checks if an alert should be triggered for an event
@@ -149,6 +779,13 @@ func (am *AlertManager) shouldAlert(alert *Alert, event AlertEvent) bool {
 	// Get alert conditions from config
 	conditions, _ := alert.Config["conditions"].(map[string]interface{})
 
+	// Alert on the first crash of a release
+	if event.IsNewVersion {
+		if alertOnVersion, ok := conditions["on_new_version_crash"].(bool); ok && alertOnVersion {
+			return true
+		}
+	}
+
 	switch event.Type {
 	case AlertEventNewGroup:
 		// Alert on new crash groups
@@ -160,8 +797,27 @@ func (am *AlertManager) shouldAlert(alert *Alert, event AlertEvent) bool {
 		if alertOnCrash, ok := conditions["on_every_crash"].(bool); ok && alertOnCrash {
 			return true
 		}
+	case AlertEventRegression:
+		// Alert when a resolved group crashes again; it is still a crash
+		if alertOnRegression, ok := conditions["on_regression"].(bool); ok && alertOnRegression {
+			return true
+		}
+		if alertOnCrash, ok := conditions["on_every_crash"].(bool); ok && alertOnCrash {
+			return true
+		}
+	case AlertEventChurn:
+		// Alert when an app creates new groups faster than the churn limit
+		if alertOnChurn, ok := conditions["on_group_churn"].(bool); ok && alertOnChurn {
+			return true
+		}
+		return false
 	case AlertEventThreshold:
-		// Alert when threshold exceeded (handled elsewhere)
+		// Threshold events are raised for one alert by the evaluator
+		return event.Threshold != nil && event.Threshold.AlertID == alert.ID
+	}
+
+	// Check occurrence velocity; a replayed crash says nothing about the current rate
+	if velocity, ok := conditions["velocity"].(map[string]interface{}); ok && !event.Replay && am.velocityExceeded(alert, event, velocity) {
 		return true
 	}
 
@@ -177,15 +833,110 @@ func (am *AlertManager) shouldAlert(alert *Alert, event AlertEvent) bool {
 	return false
 }
 
+// maxVelocityWindow is the longest window a velocity condition may use; the
+// occurrence counter keeps twice this much history
+const maxVelocityWindow = 12 * time.Hour
+
+// velocityExceeded reports whether a group's crash rate over the most recent
+// window has grown by at least factor compared to the window before it:
+//
+//	"velocity": {"window": "1h", "factor": 5, "min_count": 10}
+//
+// min_count ignores groups that are accelerating from a negligible base.
+// Once fired, the condition stays quiet for that group for one window.
+func (am *AlertManager) velocityExceeded(alert *Alert, event AlertEvent, velocity map[string]interface{}) bool {
+	if event.Group == nil || event.Crash == nil {
+		return false
+	}
+
+	window := time.Hour
+	if w, ok := velocity["window"].(string); ok {
+		if d, err := time.ParseDuration(w); err == nil && d > 0 {
+			window = d
+		}
+	}
+	if window > maxVelocityWindow {
+		window = maxVelocityWindow
+	}
+	factor := 2.0
+	if f, ok := velocity["factor"].(float64); ok && f > 0 {
+		factor = f
+	}
+	minCount := 1
+	if m, ok := velocity["min_count"].(float64); ok && m > 0 {
+		minCount = int(m)
+	}
+
+	now := event.Crash.CreatedAt
+	recent := am.counter.Count(event.Group.ID, now.Add(-window), now)
+	prior := am.counter.Count(event.Group.ID, now.Add(-2*window), now.Add(-window))
+
+	if recent < minCount {
+		return false
+	}
+	// An empty prior window counts as one occurrence so a brand-new burst
+	// still has to reach factor to fire
+	if float64(recent) < factor*float64(max(prior, 1)) {
+		return false
+	}
+
+	key := alert.ID + "|" + event.Group.ID
+	am.stateMu.Lock()
+	defer am.stateMu.Unlock()
+	if last, ok := am.velocityFired[key]; ok && now.Sub(last) < window {
+		return false
+	}
+	am.velocityFired[key] = now
+	return true
+}
+
 // sendAlert sends an alert via the configured channel
 func (am *AlertManager) sendAlert(alert *Alert, event AlertEvent) error {
+	err := am.deliverAlert(alert, event)
+	am.countSend(alert, err)
+	return err
+}
+
+// countSend counts a delivery, and whether it failed, by alert type
+func (am *AlertManager) countSend(alert *Alert, err error) {
+	am.sends.WithLabelValues(alert.Type).Inc()
+	if err != nil {
+		am.sendErrors.WithLabelValues(alert.Type).Inc()
+	}
+}
+
+// Collectors returns the alert metrics: deliveries and failed deliveries
+// by alert type, and the in-memory queue depth
+func (am *AlertManager) Collectors() []prometheus.Collector {
+	queueDepth := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "inceptor_alert_queue_depth",
+		Help: "Alert events waiting in the in-memory queue.",
+	}, func() float64 { return float64(am.QueueDepth()) })
+	return []prometheus.Collector{am.sends, am.sendErrors, queueDepth}
+}
+
+// QueueDepth returns the number of events waiting in the in-memory queue
+func (am *AlertManager) QueueDepth() int {
+	return len(am.queue)
+}
+
+// deliverAlert sends an event via the alert's channel
+func (am *AlertManager) deliverAlert(alert *Alert, event AlertEvent) error {
 	switch alert.Type {
 	case "webhook":
-		return am.sendWebhook(alert, event)
+		return am.withRetry(alert, func() error { return am.sendWebhook(alert, event) })
 	case "email":
 		return am.sendEmail(alert, event)
 	case "slack":
-		return am.sendSlack(alert, event)
+		return am.withRetry(alert, func() error { return am.sendSlack(alert, event) })
+	case "discord":
+		return am.withRetry(alert, func() error { return am.sendDiscord(alert, event) })
+	case "teams":
+		return am.withRetry(alert, func() error { return am.sendTeams(alert, event) })
+	case "pagerduty":
+		return am.sendPagerDuty(alert, event)
+	case "telegram":
+		return am.sendTelegram(alert, event)
 	default:
 		return fmt.Errorf("unknown alert type: %s", alert.Type)
 	}
@@ -198,10 +949,94 @@ func (am *AlertManager) sendWebhook(alert *Alert, event AlertEvent) error {
 		return fmt.Errorf("webhook URL not configured")
 	}
 
+	payload := webhookEventPayload(event)
+	payload["timestamp"] = time.Now().UTC().Format(time.RFC3339)
+
+	// Payload shapes are fixed per version, so replays, threshold details
+	// and a regressed group's previous status are given in headers
+	extraHeaders := make(map[string]string)
+	if event.Replay {
+		extraHeaders["X-Inceptor-Replay"] = "true"
+	}
+	if event.Threshold != nil {
+		extraHeaders["X-Inceptor-Threshold-Count"] = strconv.Itoa(event.Threshold.Count)
+		extraHeaders["X-Inceptor-Threshold-Window"] = formatWindow(event.Threshold.Window)
+	}
+	if event.PreviousStatus != "" {
+		extraHeaders["X-Inceptor-Previous-Status"] = event.PreviousStatus
+	}
+
+	return am.postWebhook(context.Background(), alert, url, payload, extraHeaders)
+}
+
+// sendCoalescedWebhook sends a batch of events in one webhook payload, each
+// entry shaped like a single-event payload
+func (am *AlertManager) sendCoalescedWebhook(alert *Alert, events []AlertEvent, window time.Duration) error {
+	url, ok := alert.Config["url"].(string)
+	if !ok || url == "" {
+		return fmt.Errorf("webhook URL not configured")
+	}
+
+	entries := make([]map[string]interface{}, len(events))
+	for i, event := range events {
+		entries[i] = webhookEventPayload(event)
+	}
+
+	payload := map[string]interface{}{
+		"event_type":     AlertEventCoalesced,
+		"app_id":         events[0].AppID,
+		"timestamp":      time.Now().UTC().Format(time.RFC3339),
+		"summary":        coalescedSummary(events, window),
+		"window_seconds": int(window.Seconds()),
+		"count":          len(events),
+		"events":         entries,
+	}
+
+	return am.postWebhook(context.Background(), alert, url, payload, nil)
+}
+
+// Webhook payload versions. An alert pins one with "payload_version" in its
+// config so its integration keeps receiving the same shape; new fields are
+// added under a new version rather than to an existing one.
+const (
+	// WebhookPayloadV1 is the original payload built by webhookEventPayload
+	WebhookPayloadV1 = 1
+	// LatestWebhookPayloadVersion is sent to alerts that don't pin a version
+	LatestWebhookPayloadVersion = WebhookPayloadV1
+)
+
+// WebhookPayloadVersion returns the payload version an alert config pins,
+// given as a number or a string like "v1", or the latest version if none
+func WebhookPayloadVersion(config map[string]interface{}) (int, error) {
+	raw, ok := config["payload_version"]
+	if !ok || raw == nil {
+		return LatestWebhookPayloadVersion, nil
+	}
+
+	version := 0
+	switch v := raw.(type) {
+	case float64:
+		if v == float64(int(v)) {
+			version = int(v)
+		}
+	case string:
+		version, _ = strconv.Atoi(strings.TrimPrefix(strings.ToLower(v), "v"))
+	}
+
+	switch version {
+	case WebhookPayloadV1:
+		return version, nil
+	default:
+		return 0, fmt.Errorf("unsupported webhook payload_version: %v", raw)
+	}
+}
+
+// webhookEventPayload builds the version 1 webhook fields describing one
+// event. Its shape is fixed: new fields belong in a new payload version.
+func webhookEventPayload(event AlertEvent) map[string]interface{} {
 	payload := map[string]interface{}{
 		"event_type": event.Type,
 		"app_id":     event.AppID,
-		"timestamp":  time.Now().UTC().Format(time.RFC3339),
 	}
 
 	if event.Crash != nil {
@@ -226,13 +1061,45 @@ func (am *AlertManager) sendWebhook(alert *Alert, event AlertEvent) error {
 	}
 
 	payload["is_new_group"] = event.IsNewGroup
+	payload["is_new_version"] = event.IsNewVersion
+
+	return payload
+}
+
+// VerifyWebhook sends a synthetic ping to a webhook alert's URL so a
+// misconfigured endpoint is caught before the alert is saved
+func (am *AlertManager) VerifyWebhook(ctx context.Context, alert *Alert) error {
+	url, ok := alert.Config["url"].(string)
+	if !ok || url == "" {
+		return fmt.Errorf("webhook URL not configured")
+	}
+
+	payload := map[string]interface{}{
+		"event_type": AlertEventPing,
+		"app_id":     alert.AppID,
+		"timestamp":  time.Now().UTC().Format(time.RFC3339),
+		"message":    "Inceptor webhook verification",
+	}
+
+	return am.postWebhook(ctx, alert, url, payload, nil)
+}
+
+// postWebhook posts a JSON payload to a webhook URL, applying any custom
+// headers from the alert config, then extraHeaders. Any non-2xx response is
+// treated as a failure.
+func (am *AlertManager) postWebhook(ctx context.Context, alert *Alert, url string, payload map[string]interface{}, extraHeaders map[string]string) error {
+	version, err := WebhookPayloadVersion(alert.Config)
+	if err != nil {
+		return err
+	}
+	payload["payload_version"] = version
 
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -247,15 +1114,19 @@ func (am *AlertManager) sendWebhook(alert *Alert, event AlertEvent) error {
 			}
 		}
 	}
+	for k, v := range extraHeaders {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("X-Inceptor-Payload-Version", strconv.Itoa(version))
 
 	resp, err := am.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send webhook: %w", err)
+		return retryable(fmt.Errorf("failed to send webhook: %w", err))
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode >= 400 {
-		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return statusError(resp, fmt.Errorf("webhook returned status %d", resp.StatusCode))
 	}
 
 	return nil
@@ -276,9 +1147,26 @@ func (am *AlertManager) sendEmail(alert *Alert, event AlertEvent) error {
 	if event.IsNewGroup {
 		subject = fmt.Sprintf("[Inceptor] NEW ERROR in %s: %s", event.AppID, event.Crash.ErrorType)
 	}
+	intro := "New crash detected in your application."
+	if event.Threshold != nil {
+		subject = fmt.Sprintf("[Inceptor] SPIKE in %s: %s, %s", event.AppID, event.Crash.ErrorType, event.Threshold.Summary())
+		intro = fmt.Sprintf("A crash group reached its alert threshold: %s (threshold %d).", event.Threshold.Summary(), event.Threshold.Threshold)
+	}
+	if event.Type == AlertEventRegression {
+		subject = fmt.Sprintf("[Inceptor] REGRESSION in %s: %s", event.AppID, event.Crash.ErrorType)
+		intro = fmt.Sprintf("A crash group that was %s has crashed again and was reopened.", event.PreviousStatus)
+	}
+	if event.Replay {
+		subject = strings.Replace(subject, "[Inceptor]", "[Inceptor] [Replay]", 1)
+	}
+
+	dashboardURL := am.dashboardURL
+	if dashboardURL == "" {
+		dashboardURL = "[your-dashboard-url]"
+	}
 
 	body := fmt.Sprintf(`
-New crash detected in your application.
+%s
 
 App ID: %s
 Error Type: %s
@@ -292,8 +1180,9 @@ Group ID: %s
 Is New Group: %v
 Occurrence Count: %d
 
-View in dashboard: [your-dashboard-url]/crashes/%s
+View in dashboard: %s/crashes/%s
 `,
+		intro,
 		event.AppID,
 		event.Crash.ErrorType,
 		event.Crash.ErrorMessage,
@@ -304,9 +1193,44 @@ View in dashboard: [your-dashboard-url]/crashes/%s
 		event.Group.ID,
 		event.IsNewGroup,
 		event.Group.OccurrenceCount,
+		dashboardURL,
 		event.Crash.ID,
 	)
+	if link := am.resolveURL(event.Group.ID); link != "" {
+		body += fmt.Sprintf("Resolve this group: %s\n", link)
+	}
 
+	return am.sendMail(to, subject, body)
+}
+
+// sendCoalescedEmail sends a batch of events as one email
+func (am *AlertManager) sendCoalescedEmail(alert *Alert, events []AlertEvent, window time.Duration) error {
+	to, ok := alert.Config["to"].(string)
+	if !ok || to == "" {
+		return fmt.Errorf("email recipient not configured")
+	}
+
+	if am.smtpCfg.Host == "" {
+		return fmt.Errorf("SMTP not configured")
+	}
+
+	summary := coalescedSummary(events, window)
+	subject := fmt.Sprintf("[Inceptor] %s: %s", events[0].AppID, summary)
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "\n%s in %s:\n\n", summary, events[0].AppID)
+	for _, event := range events {
+		fmt.Fprintf(&body, "- %s\n", coalescedLine(event))
+		if link := am.resolveURL(event.Group.ID); link != "" {
+			fmt.Fprintf(&body, "  Resolve: %s\n", link)
+		}
+	}
+
+	return am.sendMail(to, subject, body.String())
+}
+
+// sendMail sends a plain-text email through the configured SMTP server
+func (am *AlertManager) sendMail(to, subject, body string) error {
 	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s",
 		am.smtpCfg.From, to, subject, body)
 
@@ -340,12 +1264,21 @@ func (am *AlertManager) sendSlack(alert *Alert, event AlertEvent) error {
 	if event.IsNewGroup {
 		title = fmt.Sprintf("🆕 NEW ERROR in %s", event.AppID)
 	}
+	if event.Threshold != nil {
+		title = fmt.Sprintf("📈 SPIKE in %s: %s", event.AppID, event.Threshold.Summary())
+	}
+	if event.Type == AlertEventRegression {
+		title = fmt.Sprintf("🔁 REGRESSION in %s (was %s)", event.AppID, event.PreviousStatus)
+	}
+	if event.Replay {
+		title = "[Replay] " + title
+	}
 
 	payload := map[string]interface{}{
 		"attachments": []map[string]interface{}{
 			{
-				"color":  color,
-				"title":  title,
+				"color": color,
+				"title": title,
 				"fields": []map[string]interface{}{
 					{"title": "Error Type", "value": event.Crash.ErrorType, "short": true},
 					{"title": "Platform", "value": event.Crash.Platform, "short": true},
@@ -353,13 +1286,49 @@ func (am *AlertManager) sendSlack(alert *Alert, event AlertEvent) error {
 					{"title": "Environment", "value": event.Crash.Environment, "short": true},
 					{"title": "Occurrences", "value": fmt.Sprintf("%d", event.Group.OccurrenceCount), "short": true},
 				},
-				"text":      event.Crash.ErrorMessage,
-				"footer":    "Inceptor Crash Logger",
-				"ts":        event.Crash.CreatedAt.Unix(),
+				"text":   event.Crash.ErrorMessage,
+				"footer": "Inceptor Crash Logger",
+				"ts":     event.Crash.CreatedAt.Unix(),
+			},
+		},
+	}
+
+	return am.postSlack(webhookURL, payload)
+}
+
+// sendCoalescedSlack sends a batch of events as one Slack message
+func (am *AlertManager) sendCoalescedSlack(alert *Alert, events []AlertEvent, window time.Duration) error {
+	webhookURL := am.slackURL
+	if url, ok := alert.Config["webhook_url"].(string); ok && url != "" {
+		webhookURL = url
+	}
+
+	if webhookURL == "" {
+		return fmt.Errorf("Slack webhook URL not configured")
+	}
+
+	lines := make([]string, len(events))
+	for i, event := range events {
+		lines[i] = "• " + coalescedLine(event)
+	}
+
+	payload := map[string]interface{}{
+		"attachments": []map[string]interface{}{
+			{
+				"color":  "#ff6600",
+				"title":  fmt.Sprintf("%s in %s", coalescedSummary(events, window), events[0].AppID),
+				"text":   strings.Join(lines, "\n"),
+				"footer": "Inceptor Crash Logger",
+				"ts":     time.Now().Unix(),
 			},
 		},
 	}
 
+	return am.postSlack(webhookURL, payload)
+}
+
+// postSlack posts a message payload to a Slack incoming webhook
+func (am *AlertManager) postSlack(webhookURL string, payload map[string]interface{}) error {
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("failed to marshal payload: %w", err)
@@ -367,12 +1336,12 @@ func (am *AlertManager) sendSlack(alert *Alert, event AlertEvent) error {
 
 	resp, err := am.client.Post(webhookURL, "application/json", bytes.NewReader(body))
 	if err != nil {
-		return fmt.Errorf("failed to send Slack message: %w", err)
+		return retryable(fmt.Errorf("failed to send Slack message: %w", err))
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		return fmt.Errorf("Slack webhook returned status %d", resp.StatusCode)
+		return statusError(resp, fmt.Errorf("Slack webhook returned status %d", resp.StatusCode))
 	}
 
 	return nil
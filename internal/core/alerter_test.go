@@ -0,0 +1,726 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+)
+
+func TestMain(m *testing.M) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	os.Exit(m.Run())
+}
+
+// receivedRequest is a request received by a webhookReceiver
+type receivedRequest struct {
+	Path    string
+	Header  http.Header
+	Payload map[string]interface{}
+}
+
+// webhookReceiver records the requests alert channels send it
+type webhookReceiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []receivedRequest
+	// status is the response status, 200 when zero
+	status int
+}
+
+func newWebhookReceiver(t *testing.T) *webhookReceiver {
+	t.Helper()
+
+	r := &webhookReceiver{}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(req.Body).Decode(&payload)
+
+		r.mu.Lock()
+		r.requests = append(r.requests, receivedRequest{req.URL.Path, req.Header.Clone(), payload})
+		status := r.status
+		r.mu.Unlock()
+
+		if status != 0 {
+			w.WriteHeader(status)
+		}
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// received returns the requests received so far
+func (r *webhookReceiver) received() []receivedRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]receivedRequest(nil), r.requests...)
+}
+
+// paths returns the paths of the requests received so far
+func (r *webhookReceiver) paths() []string {
+	var paths []string
+	for _, req := range r.received() {
+		paths = append(paths, req.Path)
+	}
+	return paths
+}
+
+// newTestAlertManager returns an AlertManager closed when the test ends
+func newTestAlertManager(t *testing.T, alerts ...*Alert) *AlertManager {
+	t.Helper()
+
+	am := NewAlertManager(SMTPConfig{}, "")
+	t.Cleanup(am.Close)
+	am.SetAlerts(alerts)
+	return am
+}
+
+// crashEvent returns a new_crash event for a group that has reached count
+// occurrences
+func crashEvent(appID, groupID string, count int, at time.Time) AlertEvent {
+	return AlertEvent{
+		Type:  AlertEventNewCrash,
+		AppID: appID,
+		Crash: &Crash{ID: "crash-" + groupID, AppID: appID, GroupID: groupID, ErrorType: "StateError", CreatedAt: at},
+		Group: &CrashGroup{ID: groupID, AppID: appID, ErrorType: "StateError", OccurrenceCount: count, FirstSeen: at, LastSeen: at},
+	}
+}
+
+func TestEscalationThresholds(t *testing.T) {
+	tests := []struct {
+		name       string
+		thresholds []int
+		// counts are the group's occurrence counts of successive events
+		counts []int
+		want   []string
+	}{
+		{
+			name:       "each threshold fires once",
+			thresholds: []int{1, 10, 100},
+			counts:     seq(1, 150),
+			want:       []string{"/t1", "/t10", "/t100"},
+		},
+		{
+			name:       "only crossed thresholds",
+			thresholds: []int{5, 50},
+			counts:     seq(1, 20),
+			want:       []string{"/t5"},
+		},
+		{
+			name:       "thresholds crossed in order",
+			thresholds: []int{3, 2},
+			counts:     seq(1, 4),
+			want:       []string{"/t2", "/t3"},
+		},
+		{
+			// As after a restart: counts already past a threshold don't fire it again
+			name:       "already past threshold",
+			thresholds: []int{1, 10},
+			counts:     seq(11, 20),
+			want:       nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := newWebhookReceiver(t)
+			var rules []interface{}
+			for _, threshold := range tt.thresholds {
+				rules = append(rules, map[string]interface{}{
+					"threshold": float64(threshold),
+					"type":      "webhook",
+					"config":    map[string]interface{}{"url": receiver.URL + "/t" + strconv.Itoa(threshold)},
+				})
+			}
+			// The parent alert has no conditions, so only escalations send
+			alert := &Alert{ID: "alert-1", AppID: "app-1", Type: "webhook", Enabled: true, Config: map[string]interface{}{
+				"url":        receiver.URL + "/parent",
+				"escalation": rules,
+			}}
+			am := newTestAlertManager(t, alert)
+
+			now := time.Now()
+			for _, count := range tt.counts {
+				am.processEvent(crashEvent("app-1", "group-1", count, now))
+			}
+
+			if got := receiver.paths(); !slices.Equal(got, tt.want) {
+				t.Errorf("escalations sent = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEscalationThresholdsPerGroup(t *testing.T) {
+	receiver := newWebhookReceiver(t)
+	alert := &Alert{ID: "alert-1", Type: "webhook", Enabled: true, Config: map[string]interface{}{
+		"url": receiver.URL + "/parent",
+		"escalation": []interface{}{
+			map[string]interface{}{"threshold": float64(2), "type": "webhook", "config": map[string]interface{}{"url": receiver.URL + "/t2"}},
+		},
+	}}
+	am := newTestAlertManager(t, alert)
+
+	now := time.Now()
+	for _, group := range []string{"group-1", "group-2"} {
+		for count := 1; count <= 3; count++ {
+			am.processEvent(crashEvent("app-1", group, count, now))
+		}
+	}
+
+	if got := len(receiver.received()); got != 2 {
+		t.Errorf("escalations sent = %d, want one per group", got)
+	}
+}
+
+func TestEscalationCrossed(t *testing.T) {
+	tests := []struct {
+		threshold, count int
+		want             bool
+	}{
+		{1, 1, true},
+		{1, 2, false},
+		{10, 9, false},
+		{10, 10, true},
+		{10, 11, false},
+	}
+	for _, tt := range tests {
+		if got := escalationCrossed(tt.threshold, tt.count); got != tt.want {
+			t.Errorf("escalationCrossed(%d, %d) = %v, want %v", tt.threshold, tt.count, got, tt.want)
+		}
+	}
+}
+
+// seq returns the integers from first to last
+func seq(first, last int) []int {
+	var s []int
+	for i := first; i <= last; i++ {
+		s = append(s, i)
+	}
+	return s
+}
+
+// minutes returns times offset from start by each number of minutes
+func minutes(start time.Time, offsets ...int) []time.Time {
+	var times []time.Time
+	for _, m := range offsets {
+		times = append(times, start.Add(time.Duration(m)*time.Minute))
+	}
+	return times
+}
+
+func TestVelocityCondition(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	every := func(first, last, step int) []int {
+		var offsets []int
+		for m := first; m <= last; m += step {
+			offsets = append(offsets, m)
+		}
+		return offsets
+	}
+
+	tests := []struct {
+		name     string
+		velocity map[string]interface{}
+		crashes  []time.Time
+		want     int
+	}{
+		{
+			// One an hour for three hours, then ten in ten minutes
+			name:     "accelerating",
+			velocity: map[string]interface{}{"window": "1h", "factor": float64(5), "min_count": float64(5)},
+			crashes:  minutes(start, append([]int{30, 90, 150}, every(181, 190, 1)...)...),
+			want:     1,
+		},
+		{
+			// Ten an hour fires once as the group appears, then never again
+			name:     "steady rate",
+			velocity: map[string]interface{}{"window": "1h", "factor": float64(3), "min_count": float64(5)},
+			crashes:  minutes(start, every(3, 240, 6)...),
+			want:     1,
+		},
+		{
+			name:     "below min_count",
+			velocity: map[string]interface{}{"window": "1h", "factor": float64(2), "min_count": float64(20)},
+			crashes:  minutes(start, append([]int{30}, every(61, 70, 1)...)...),
+			want:     0,
+		},
+		{
+			name:     "short window",
+			velocity: map[string]interface{}{"window": "10m", "factor": float64(4), "min_count": float64(4)},
+			crashes:  minutes(start, 1, 11, 21, 31, 32, 33, 34),
+			want:     1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := newWebhookReceiver(t)
+			alert := &Alert{ID: "alert-1", Type: "webhook", Enabled: true, Config: map[string]interface{}{
+				"url":        receiver.URL,
+				"conditions": map[string]interface{}{"velocity": tt.velocity},
+			}}
+			am := newTestAlertManager(t, alert)
+
+			for i, at := range tt.crashes {
+				am.processEvent(crashEvent("app-1", "group-1", i+1, at))
+			}
+
+			if got := len(receiver.received()); got != tt.want {
+				t.Errorf("velocity alerts = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// newGroupEvent returns a new_group event for a group first seen at
+func newGroupEvent(appID, groupID string, at time.Time) AlertEvent {
+	event := crashEvent(appID, groupID, 1, at)
+	event.Type = AlertEventNewGroup
+	event.IsNewGroup = true
+	return event
+}
+
+func TestCoalesceWindow(t *testing.T) {
+	tests := []struct {
+		name   string
+		window string
+		// groups are the new groups of events sent within the window
+		groups int
+		// want are the event types sent, with the count of coalesced events
+		want []string
+	}{
+		{"burst coalesced", "1m", 5, []string{"coalesced:5"}},
+		{"lone event sent as is", "1m", 1, []string{"new_group"}},
+		{"no window", "", 3, []string{"new_group", "new_group", "new_group"}},
+		{"invalid window", "soon", 2, []string{"new_group", "new_group"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := newWebhookReceiver(t)
+			config := map[string]interface{}{
+				"url":        receiver.URL,
+				"conditions": map[string]interface{}{"on_new_group": true},
+			}
+			if tt.window != "" {
+				config["coalesce_window"] = tt.window
+			}
+			am := NewAlertManager(SMTPConfig{}, "")
+			am.SetAlerts([]*Alert{{ID: "alert-1", AppID: "app-1", Type: "webhook", Enabled: true, Config: config}})
+
+			now := time.Now()
+			for i := 0; i < tt.groups; i++ {
+				am.Notify(newGroupEvent("app-1", "group-"+strconv.Itoa(i), now))
+			}
+			// Shutting down sends buffered events without waiting out the window
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := am.Shutdown(ctx); err != nil {
+				t.Fatalf("shut down: %v", err)
+			}
+
+			var got []string
+			for _, req := range receiver.received() {
+				sent := fmt.Sprint(req.Payload["event_type"])
+				if sent == string(AlertEventCoalesced) {
+					sent += fmt.Sprintf(":%v", req.Payload["count"])
+					if want := fmt.Sprintf("%d new groups in the last 1m", tt.groups); req.Payload["summary"] != want {
+						t.Errorf("summary = %v, want %q", req.Payload["summary"], want)
+					}
+				}
+				got = append(got, sent)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("sent = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCoalesceWindowExpires(t *testing.T) {
+	receiver := newWebhookReceiver(t)
+	alert := &Alert{ID: "alert-1", AppID: "app-1", Type: "webhook", Enabled: true, Config: map[string]interface{}{
+		"url":             receiver.URL,
+		"conditions":      map[string]interface{}{"on_new_group": true},
+		"coalesce_window": "50ms",
+	}}
+	am := newTestAlertManager(t, alert)
+
+	// Two bursts a window apart are two messages
+	for burst := 0; burst < 2; burst++ {
+		for i := 0; i < 3; i++ {
+			am.Notify(newGroupEvent("app-1", fmt.Sprintf("group-%d-%d", burst, i), time.Now()))
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for len(receiver.received()) <= burst && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	received := receiver.received()
+	if len(received) != 2 {
+		t.Fatalf("messages = %d, want one per burst", len(received))
+	}
+	for _, req := range received {
+		if req.Payload["event_type"] != string(AlertEventCoalesced) || req.Payload["count"] != float64(3) {
+			t.Errorf("message = %v, want 3 coalesced events", req.Payload)
+		}
+	}
+}
+
+func TestWebhookPayloadVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		want    int
+		wantErr bool
+	}{
+		{"unpinned", map[string]interface{}{}, LatestWebhookPayloadVersion, false},
+		{"null", map[string]interface{}{"payload_version": nil}, LatestWebhookPayloadVersion, false},
+		{"number", map[string]interface{}{"payload_version": float64(1)}, WebhookPayloadV1, false},
+		{"string", map[string]interface{}{"payload_version": "1"}, WebhookPayloadV1, false},
+		{"prefixed string", map[string]interface{}{"payload_version": "V1"}, WebhookPayloadV1, false},
+		{"fraction", map[string]interface{}{"payload_version": 1.5}, 0, true},
+		{"unknown version", map[string]interface{}{"payload_version": float64(99)}, 0, true},
+		{"garbage", map[string]interface{}{"payload_version": "latest"}, 0, true},
+		{"wrong type", map[string]interface{}{"payload_version": true}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := WebhookPayloadVersion(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("version = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookPayloadVersionSent(t *testing.T) {
+	tests := []struct {
+		name    string
+		version interface{}
+		// want is the version sent, 0 when nothing should be sent
+		want int
+	}{
+		{"unpinned", nil, LatestWebhookPayloadVersion},
+		{"pinned", "v1", WebhookPayloadV1},
+		{"unsupported", float64(7), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := newWebhookReceiver(t)
+			config := map[string]interface{}{
+				"url":        receiver.URL,
+				"conditions": map[string]interface{}{"on_new_group": true},
+			}
+			if tt.version != nil {
+				config["payload_version"] = tt.version
+			}
+			am := newTestAlertManager(t, &Alert{ID: "alert-1", AppID: "app-1", Type: "webhook", Enabled: true, Config: config})
+			am.processEvent(newGroupEvent("app-1", "group-1", time.Now()))
+
+			received := receiver.received()
+			if tt.want == 0 {
+				if len(received) != 0 {
+					t.Errorf("sent %d webhooks, want none for an unsupported version", len(received))
+				}
+				return
+			}
+			if len(received) != 1 {
+				t.Fatalf("sent %d webhooks, want 1", len(received))
+			}
+			if got := received[0].Header.Get("X-Inceptor-Payload-Version"); got != strconv.Itoa(tt.want) {
+				t.Errorf("X-Inceptor-Payload-Version = %q, want %d", got, tt.want)
+			}
+			if got := received[0].Payload["payload_version"]; got != float64(tt.want) {
+				t.Errorf("payload_version = %v, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlertManagerShutdown(t *testing.T) {
+	tests := []struct {
+		name   string
+		events int
+		// delay is how long the webhook takes to respond
+		delay   time.Duration
+		wantErr error
+		// wantSent is the number of webhooks received once Shutdown returns
+		wantSent int
+	}{
+		{"nothing queued", 0, 0, nil, 0},
+		{"queued events sent", 5, 0, nil, 5},
+		{"deadline before the queue drains", 5, 300 * time.Millisecond, context.DeadlineExceeded, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			sent := 0
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(tt.delay)
+				mu.Lock()
+				sent++
+				mu.Unlock()
+			}))
+			defer receiver.Close()
+
+			am := NewAlertManager(SMTPConfig{}, "")
+			am.SetAlerts([]*Alert{{ID: "alert-1", AppID: "app-1", Type: "webhook", Enabled: true, Config: map[string]interface{}{
+				"url":        receiver.URL,
+				"conditions": map[string]interface{}{"on_new_group": true},
+			}}})
+			now := time.Now()
+			for i := 0; i < tt.events; i++ {
+				am.Notify(newGroupEvent("app-1", "group-"+strconv.Itoa(i), now))
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 400*time.Millisecond)
+			defer cancel()
+			if err := am.Shutdown(ctx); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Shutdown = %v, want %v", err, tt.wantErr)
+			}
+			mu.Lock()
+			got := sent
+			mu.Unlock()
+			if got != tt.wantSent {
+				t.Errorf("sent %d webhooks by shutdown, want %d", got, tt.wantSent)
+			}
+
+			// Events after shutdown are dropped rather than panicking on the
+			// closed queue
+			am.Notify(newGroupEvent("app-1", "late", now))
+			am.Close()
+		})
+	}
+}
+
+func TestAlertCollectors(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		events     int
+		wantSends  float64
+		wantErrors float64
+	}{
+		{"nothing sent", 0, 0, 0, 0},
+		{"sent", http.StatusOK, 2, 2, 0},
+		{"failed", http.StatusBadRequest, 1, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := newWebhookReceiver(t)
+			receiver.status = tt.status
+			am := newTestAlertManager(t, newGroupWebhook("alert-1", receiver.URL))
+			for i := 0; i < tt.events; i++ {
+				am.Notify(newGroupEvent("app-1", "group-"+strconv.Itoa(i), time.Now()))
+			}
+			if err := am.Shutdown(context.Background()); err != nil {
+				t.Fatalf("Shutdown: %v", err)
+			}
+
+			// The collectors register together without clashing
+			registry := prometheus.NewPedanticRegistry()
+			registry.MustRegister(am.Collectors()...)
+			if _, err := registry.Gather(); err != nil {
+				t.Fatalf("Gather: %v", err)
+			}
+			if got := testutil.ToFloat64(am.sends.WithLabelValues("webhook")); got != tt.wantSends {
+				t.Errorf("sends = %v, want %v", got, tt.wantSends)
+			}
+			if got := testutil.ToFloat64(am.sendErrors.WithLabelValues("webhook")); got != tt.wantErrors {
+				t.Errorf("send errors = %v, want %v", got, tt.wantErrors)
+			}
+			if got := testutil.ToFloat64(am.Collectors()[2]); got != 0 {
+				t.Errorf("queue depth = %v after shutdown, want 0", got)
+			}
+		})
+	}
+}
+
+func TestAlertCooldown(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+		want   time.Duration
+	}{
+		{"default", map[string]interface{}{}, defaultAlertCooldown},
+		{"set", map[string]interface{}{"cooldown": "15m"}, 15 * time.Minute},
+		{"disabled", map[string]interface{}{"cooldown": "0"}, 0},
+		{"invalid", map[string]interface{}{"cooldown": "soon"}, defaultAlertCooldown},
+		{"negative", map[string]interface{}{"cooldown": "-1m"}, defaultAlertCooldown},
+		{"not a string", map[string]interface{}{"cooldown": float64(60)}, defaultAlertCooldown},
+		{"capped", map[string]interface{}{"cooldown": "72h"}, maxAlertCooldown},
+	}
+	for _, tt := range tests {
+		if got := alertCooldown(tt.config); got != tt.want {
+			t.Errorf("%s: alertCooldown = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAlertCooldownPerGroup(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	// crashAt is a crash of group at the given minute
+	type crashAt struct {
+		group  string
+		minute int
+	}
+
+	tests := []struct {
+		name     string
+		cooldown interface{}
+		events   []crashAt
+		want     []string
+	}{
+		{
+			name:   "burst notifies once",
+			events: []crashAt{{"group-1", 0}, {"group-1", 1}, {"group-1", 4}},
+			want:   []string{"group-1"},
+		},
+		{
+			name:   "notifies again after the cooldown",
+			events: []crashAt{{"group-1", 0}, {"group-1", 4}, {"group-1", 5}, {"group-1", 9}},
+			want:   []string{"group-1", "group-1"},
+		},
+		{
+			name:   "per group",
+			events: []crashAt{{"group-1", 0}, {"group-2", 1}, {"group-1", 2}, {"group-2", 3}},
+			want:   []string{"group-1", "group-2"},
+		},
+		{
+			name:     "custom cooldown",
+			cooldown: "1h",
+			events:   []crashAt{{"group-1", 0}, {"group-1", 30}, {"group-1", 60}},
+			want:     []string{"group-1", "group-1"},
+		},
+		{
+			name:     "disabled",
+			cooldown: "0s",
+			events:   []crashAt{{"group-1", 0}, {"group-1", 0}, {"group-1", 1}},
+			want:     []string{"group-1", "group-1", "group-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := newWebhookReceiver(t)
+			config := map[string]interface{}{
+				"url":        receiver.URL,
+				"conditions": map[string]interface{}{"on_every_crash": true},
+			}
+			if tt.cooldown != nil {
+				config["cooldown"] = tt.cooldown
+			}
+			am := newTestAlertManager(t, &Alert{ID: "alert-1", Type: "webhook", Enabled: true, Config: config})
+
+			for i, e := range tt.events {
+				am.processEvent(crashEvent("app-1", e.group, i+1, start.Add(time.Duration(e.minute)*time.Minute)))
+			}
+
+			var got []string
+			for _, req := range receiver.received() {
+				group, _ := req.Payload["group"].(map[string]interface{})
+				id, _ := group["id"].(string)
+				got = append(got, id)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("notified groups = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Threshold events have a cooldown of their own
+	am := newTestAlertManager(t)
+	alert := &Alert{ID: "alert-1", Config: map[string]interface{}{"cooldown": "1h"}}
+	event := crashEvent("app-1", "group-1", 1, start)
+	event.Type = AlertEventThreshold
+	for i := 0; i < 2; i++ {
+		if am.inCooldown(alert, event, start) {
+			t.Errorf("threshold event %d held back by the alert cooldown", i)
+		}
+	}
+
+	// Notifications older than the longest cooldown are forgotten
+	am.inCooldown(alert, crashEvent("app-1", "old", 1, start), start)
+	am.inCooldown(alert, crashEvent("app-1", "new", 1, start), start.Add(maxAlertCooldown))
+	am.pruneNotified(start.Add(time.Hour))
+	am.stateMu.Lock()
+	defer am.stateMu.Unlock()
+	if _, ok := am.notified["alert-1|new"]; !ok || len(am.notified) != 1 {
+		t.Errorf("notified = %v, want only the new group", am.notified)
+	}
+}
+
+func TestRegressionAlerts(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	regression := func(minute int) AlertEvent {
+		event := crashEvent("app-1", "group-1", 2, start.Add(time.Duration(minute)*time.Minute))
+		event.Type = AlertEventRegression
+		event.PreviousStatus = "resolved"
+		return event
+	}
+
+	tests := []struct {
+		name       string
+		conditions map[string]interface{}
+		events     []AlertEvent
+		// want is the event types notified
+		want []string
+	}{
+		{"on_regression", map[string]interface{}{"on_regression": true}, []AlertEvent{regression(0)}, []string{"regression"}},
+		{"on_every_crash", map[string]interface{}{"on_every_crash": true}, []AlertEvent{regression(0)}, []string{"regression"}},
+		{"on_new_group", map[string]interface{}{"on_new_group": true}, []AlertEvent{regression(0)}, nil},
+		{"regressions only", map[string]interface{}{"on_regression": true}, []AlertEvent{crashEvent("app-1", "group-1", 1, start), regression(1)}, []string{"regression"}},
+		// A regression skips the cooldown, and starts a new one
+		{"during cooldown", map[string]interface{}{"on_every_crash": true}, []AlertEvent{
+			crashEvent("app-1", "group-1", 1, start),
+			regression(1),
+			crashEvent("app-1", "group-1", 3, start.Add(2*time.Minute)),
+		}, []string{"new_crash", "regression"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := newWebhookReceiver(t)
+			am := newTestAlertManager(t, &Alert{ID: "alert-1", Type: "webhook", Enabled: true, Config: map[string]interface{}{
+				"url":        receiver.URL,
+				"conditions": tt.conditions,
+			}})
+
+			for _, event := range tt.events {
+				am.processEvent(event)
+			}
+
+			var got []string
+			for _, req := range receiver.received() {
+				eventType, _ := req.Payload["event_type"].(string)
+				got = append(got, eventType)
+				wantStatus := ""
+				if eventType == string(AlertEventRegression) {
+					wantStatus = "resolved"
+				}
+				if status := req.Header.Get("X-Inceptor-Previous-Status"); status != wantStatus {
+					t.Errorf("%s: X-Inceptor-Previous-Status = %q, want %q", eventType, status, wantStatus)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("notified = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
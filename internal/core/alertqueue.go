@@ -0,0 +1,236 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// QueuedAlertEvent is an alert event stored in the durable alert queue
+type QueuedAlertEvent struct {
+	ID    int64
+	Event AlertEvent
+	// AlertID is set on an event retried after failing: only that alert is
+	// sent it, without evaluating its conditions again
+	AlertID  string
+	Attempts int
+	// NextAttemptAt is when the event is next due. Claiming an event
+	// postpones it, so an event claimed by a server that stopped before
+	// sending it becomes due again.
+	NextAttemptAt time.Time
+	LastError     string
+	CreatedAt     time.Time
+}
+
+// AlertQueueRepository defines the storage operations for the durable
+// alert queue
+type AlertQueueRepository interface {
+	// EnqueueAlertEvent stores an event, setting its ID
+	EnqueueAlertEvent(ctx context.Context, event *QueuedAlertEvent) error
+	// ClaimAlertEvents returns up to limit events due by dueBy, oldest due
+	// first, postponing each to claimUntil
+	ClaimAlertEvents(ctx context.Context, dueBy, claimUntil time.Time, limit int) ([]*QueuedAlertEvent, error)
+	// RetryAlertEvent records a failed attempt and when to try again
+	RetryAlertEvent(ctx context.Context, id int64, attempts int, nextAttemptAt time.Time, lastError string) error
+	DeleteAlertEvent(ctx context.Context, id int64) error
+}
+
+const (
+	// alertQueueClaimTTL is how long a claimed event waits before another
+	// claim may take it; it covers the time an event spends in the
+	// in-memory queue
+	alertQueueClaimTTL = 2 * time.Minute
+	// alertQueuePollInterval is how often the worker looks for due events
+	alertQueuePollInterval = 10 * time.Second
+	// alertQueueBatch bounds the events claimed at once
+	alertQueueBatch = 50
+	// alertQueueTimeout bounds each queue operation
+	alertQueueTimeout = 5 * time.Second
+)
+
+// An alert failing to send a queued event is retried with backoff, starting
+// at a minute, up to maxQueuedAlertAttempts times
+const (
+	maxQueuedAlertAttempts = 5
+	queuedAlertRetryDelay  = time.Minute
+	maxQueuedAlertDelay    = time.Hour
+)
+
+// alertFailure is an alert that failed to send an event
+type alertFailure struct {
+	alert *Alert
+	err   error
+}
+
+// StartDurableQueue stores queued events in repo until they are sent, so
+// they survive restarts. Events left by an earlier run are loaded right
+// away, and failed sends are retried with backoff. Without it events are
+// only held in memory.
+func (am *AlertManager) StartDurableQueue(repo AlertQueueRepository) {
+	am.queueMu.Lock()
+	am.queueRepo = repo
+	am.queueMu.Unlock()
+
+	// Events claimed by the last run are not due yet, so take everything
+	// that will be due within a claim
+	select {
+	case am.polls <- time.Now().Add(alertQueueClaimTTL):
+	case <-am.done:
+	}
+	log.Info().Msg("Durable alert queue started")
+}
+
+// durableQueue returns the durable queue's repository, or nil
+func (am *AlertManager) durableQueue() AlertQueueRepository {
+	am.queueMu.RLock()
+	defer am.queueMu.RUnlock()
+	return am.queueRepo
+}
+
+// persist stores a new event. An event going on the in-memory queue is
+// stored claimed, so that polling leaves it to the in-memory queue;
+// otherwise it is due right away. If it can't be stored, ID is left zero.
+func (am *AlertManager) persist(repo AlertQueueRepository, item *QueuedAlertEvent, inMemory bool) {
+	now := time.Now().UTC()
+	item.CreatedAt = now
+	item.NextAttemptAt = now
+	if inMemory {
+		item.NextAttemptAt = now.Add(alertQueueClaimTTL)
+	}
+
+	ctx, cancel := context.WithTimeout(am.ctx, alertQueueTimeout)
+	defer cancel()
+	if err := repo.EnqueueAlertEvent(ctx, item); err != nil {
+		log.Error().Err(err).Str("app_id", item.Event.AppID).Msg("Failed to store alert event, queueing in memory only")
+		item.ID = 0
+		return
+	}
+	if inMemory {
+		am.setInFlight(item.ID, true)
+	}
+}
+
+// setInFlight marks a stored event as waiting in the in-memory queue, or
+// clears the mark
+func (am *AlertManager) setInFlight(id int64, inFlight bool) {
+	am.inFlightMu.Lock()
+	defer am.inFlightMu.Unlock()
+	if inFlight {
+		am.inFlight[id] = true
+	} else {
+		delete(am.inFlight, id)
+	}
+}
+
+// isInFlight reports whether a stored event is waiting in the in-memory queue
+func (am *AlertManager) isInFlight(id int64) bool {
+	am.inFlightMu.Lock()
+	defer am.inFlightMu.Unlock()
+	return am.inFlight[id]
+}
+
+// pollQueue processes stored events due by dueBy that aren't already in the
+// in-memory queue: events that didn't fit in it, retries, and events left
+// by an earlier run. It runs on the worker.
+func (am *AlertManager) pollQueue(dueBy time.Time) {
+	repo := am.durableQueue()
+	if repo == nil {
+		return
+	}
+	for am.ctx.Err() == nil {
+		ctx, cancel := context.WithTimeout(am.ctx, alertQueueTimeout)
+		items, err := repo.ClaimAlertEvents(ctx, dueBy.UTC(), time.Now().UTC().Add(alertQueueClaimTTL), alertQueueBatch)
+		cancel()
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to claim queued alert events")
+			return
+		}
+		for _, item := range items {
+			if am.isInFlight(item.ID) {
+				continue
+			}
+			am.processQueued(item)
+		}
+		if len(items) < alertQueueBatch {
+			return
+		}
+	}
+}
+
+// processQueued processes an event from the in-memory queue or the durable
+// one. A stored event is deleted once processed; each alert that failed to
+// send it gets a stored retry.
+func (am *AlertManager) processQueued(item *QueuedAlertEvent) {
+	if item.AlertID != "" {
+		am.retryQueued(item)
+		return
+	}
+
+	failures := am.processEvent(item.Event)
+	if item.ID == 0 {
+		return
+	}
+	am.setInFlight(item.ID, false)
+
+	repo := am.durableQueue()
+	ctx, cancel := context.WithTimeout(context.Background(), alertQueueTimeout)
+	defer cancel()
+	for _, failure := range failures {
+		retry := &QueuedAlertEvent{
+			Event:         item.Event,
+			AlertID:       failure.alert.ID,
+			Attempts:      1,
+			NextAttemptAt: time.Now().UTC().Add(queuedAlertRetryDelay),
+			LastError:     failure.err.Error(),
+			CreatedAt:     time.Now().UTC(),
+		}
+		if err := repo.EnqueueAlertEvent(ctx, retry); err != nil {
+			log.Error().Err(err).Str("alert_id", failure.alert.ID).Msg("Failed to queue alert retry")
+		}
+	}
+	if err := repo.DeleteAlertEvent(ctx, item.ID); err != nil {
+		log.Error().Err(err).Int64("queue_id", item.ID).Msg("Failed to delete queued alert event")
+	}
+}
+
+// retryQueued sends a stored event again to the alert that failed it,
+// rescheduling it with backoff until it runs out of attempts
+func (am *AlertManager) retryQueued(item *QueuedAlertEvent) {
+	var alert *Alert
+	am.alertsMu.RLock()
+	for _, a := range am.alerts {
+		if a.ID == item.AlertID {
+			alert = a
+			break
+		}
+	}
+	am.alertsMu.RUnlock()
+
+	var err error
+	if alert != nil && alert.Enabled {
+		err = am.sendAlert(alert, item.Event)
+	}
+
+	repo := am.durableQueue()
+	ctx, cancel := context.WithTimeout(context.Background(), alertQueueTimeout)
+	defer cancel()
+	if err != nil && item.Attempts < maxQueuedAlertAttempts {
+		delay := min(queuedAlertRetryDelay<<item.Attempts, maxQueuedAlertDelay)
+		log.Warn().Err(err).
+			Str("alert_id", item.AlertID).
+			Int("attempt", item.Attempts+1).
+			Dur("retry_in", delay).
+			Msg("Queued alert failed, retrying")
+		if err := repo.RetryAlertEvent(ctx, item.ID, item.Attempts+1, time.Now().UTC().Add(delay), err.Error()); err != nil {
+			log.Error().Err(err).Int64("queue_id", item.ID).Msg("Failed to reschedule queued alert event")
+		}
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("alert_id", item.AlertID).Int("attempts", item.Attempts+1).Msg("Queued alert failed, giving up")
+	}
+	if err := repo.DeleteAlertEvent(ctx, item.ID); err != nil {
+		log.Error().Err(err).Int64("queue_id", item.ID).Msg("Failed to delete queued alert event")
+	}
+}
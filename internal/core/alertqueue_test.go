@@ -0,0 +1,249 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryAlertQueue is an AlertQueueRepository holding events in memory
+type memoryAlertQueue struct {
+	mu     sync.Mutex
+	nextID int64
+	events map[int64]*QueuedAlertEvent
+}
+
+func newMemoryAlertQueue() *memoryAlertQueue {
+	return &memoryAlertQueue{events: make(map[int64]*QueuedAlertEvent)}
+}
+
+func (q *memoryAlertQueue) EnqueueAlertEvent(ctx context.Context, event *QueuedAlertEvent) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.nextID++
+	event.ID = q.nextID
+	stored := *event
+	q.events[event.ID] = &stored
+	return nil
+}
+
+func (q *memoryAlertQueue) ClaimAlertEvents(ctx context.Context, dueBy, claimUntil time.Time, limit int) ([]*QueuedAlertEvent, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var due []*QueuedAlertEvent
+	for _, event := range q.events {
+		if !event.NextAttemptAt.After(dueBy) {
+			due = append(due, event)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].ID < due[j].ID })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	claimed := make([]*QueuedAlertEvent, len(due))
+	for i, event := range due {
+		event.NextAttemptAt = claimUntil
+		copied := *event
+		claimed[i] = &copied
+	}
+	return claimed, nil
+}
+
+func (q *memoryAlertQueue) RetryAlertEvent(ctx context.Context, id int64, attempts int, nextAttemptAt time.Time, lastError string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if event, ok := q.events[id]; ok {
+		event.Attempts = attempts
+		event.NextAttemptAt = nextAttemptAt
+		event.LastError = lastError
+	}
+	return nil
+}
+
+func (q *memoryAlertQueue) DeleteAlertEvent(ctx context.Context, id int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.events, id)
+	return nil
+}
+
+// stored returns the events in the queue, oldest first
+func (q *memoryAlertQueue) stored() []QueuedAlertEvent {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var events []QueuedAlertEvent
+	for _, event := range q.events {
+		events = append(events, *event)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	return events
+}
+
+// newGroupWebhook returns an alert posting new groups to url
+func newGroupWebhook(id, url string) *Alert {
+	return &Alert{ID: id, AppID: "app-1", Type: "webhook", Enabled: true, Config: map[string]interface{}{
+		"url":        url,
+		"conditions": map[string]interface{}{"on_new_group": true},
+	}}
+}
+
+func TestDurableQueue(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		// wantRetry is whether a retry for the alert is left in the queue
+		wantRetry bool
+	}{
+		{"sent", http.StatusOK, false},
+		{"failed", http.StatusBadRequest, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := newWebhookReceiver(t)
+			receiver.status = tt.status
+			queue := newMemoryAlertQueue()
+			am := newTestAlertManager(t, newGroupWebhook("alert-1", receiver.URL))
+			am.StartDurableQueue(queue)
+
+			before := time.Now()
+			am.Notify(newGroupEvent("app-1", "group-1", before))
+			if err := am.Shutdown(context.Background()); err != nil {
+				t.Fatalf("Shutdown: %v", err)
+			}
+
+			if got := len(receiver.received()); got != 1 {
+				t.Errorf("webhooks = %d, want 1", got)
+			}
+			stored := queue.stored()
+			if !tt.wantRetry {
+				if len(stored) != 0 {
+					t.Errorf("queue = %+v, want the sent event deleted", stored)
+				}
+				return
+			}
+			if len(stored) != 1 {
+				t.Fatalf("queue = %+v, want one retry", stored)
+			}
+			retry := stored[0]
+			if retry.AlertID != "alert-1" || retry.Attempts != 1 || retry.LastError != "webhook returned status 400" || retry.Event.Group.ID != "group-1" {
+				t.Errorf("retry = %+v, want attempt 1 of group-1 for alert-1", retry)
+			}
+			if retry.NextAttemptAt.Before(before.Add(queuedAlertRetryDelay)) {
+				t.Errorf("retry due at %s, want a delay of %s", retry.NextAttemptAt, queuedAlertRetryDelay)
+			}
+		})
+	}
+}
+
+func TestDurableQueueLoadsEarlierEvents(t *testing.T) {
+	receiver := newWebhookReceiver(t)
+	queue := newMemoryAlertQueue()
+	now := time.Now()
+	// Claimed by an earlier run that stopped before sending it
+	queue.EnqueueAlertEvent(context.Background(), &QueuedAlertEvent{Event: newGroupEvent("app-1", "group-1", now), NextAttemptAt: now.Add(time.Minute)})
+	// Not due for a while yet
+	queue.EnqueueAlertEvent(context.Background(), &QueuedAlertEvent{Event: newGroupEvent("app-1", "group-2", now), NextAttemptAt: now.Add(time.Hour)})
+
+	am := newTestAlertManager(t, newGroupWebhook("alert-1", receiver.URL))
+	am.StartDurableQueue(queue)
+	if err := am.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	received := receiver.received()
+	if len(received) != 1 {
+		t.Fatalf("webhooks = %d, want 1", len(received))
+	}
+	if group, _ := received[0].Payload["group"].(map[string]interface{}); group["id"] != "group-1" {
+		t.Errorf("webhook group = %v, want group-1", received[0].Payload["group"])
+	}
+	if stored := queue.stored(); len(stored) != 1 || stored[0].Event.Group.ID != "group-2" {
+		t.Errorf("queue = %+v, want only group-2 left", stored)
+	}
+}
+
+func TestRetryQueued(t *testing.T) {
+	tests := []struct {
+		name     string
+		alert    func(url string) *Alert
+		status   int
+		attempts int
+		// wantAttempts is the stored attempts afterwards, 0 when deleted
+		wantAttempts int
+		wantSent     int
+	}{
+		{"sent", func(url string) *Alert { return newGroupWebhook("alert-1", url) }, http.StatusOK, 1, 0, 1},
+		{"failed again", func(url string) *Alert { return newGroupWebhook("alert-1", url) }, http.StatusBadRequest, 1, 2, 1},
+		{"out of attempts", func(url string) *Alert { return newGroupWebhook("alert-1", url) }, http.StatusBadRequest, maxQueuedAlertAttempts, 0, 1},
+		{"alert deleted", func(url string) *Alert { return newGroupWebhook("alert-2", url) }, http.StatusOK, 1, 0, 0},
+		{"alert disabled", func(url string) *Alert {
+			alert := newGroupWebhook("alert-1", url)
+			alert.Enabled = false
+			return alert
+		}, http.StatusOK, 1, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := newWebhookReceiver(t)
+			receiver.status = tt.status
+			queue := newMemoryAlertQueue()
+			am := newTestAlertManager(t, tt.alert(receiver.URL))
+			am.queueRepo = queue
+			item := &QueuedAlertEvent{Event: newGroupEvent("app-1", "group-1", time.Now()), AlertID: "alert-1", Attempts: tt.attempts}
+			queue.EnqueueAlertEvent(context.Background(), item)
+
+			before := time.Now()
+			am.retryQueued(item)
+
+			if got := len(receiver.received()); got != tt.wantSent {
+				t.Errorf("webhooks = %d, want %d", got, tt.wantSent)
+			}
+			stored := queue.stored()
+			if tt.wantAttempts == 0 {
+				if len(stored) != 0 {
+					t.Errorf("queue = %+v, want the event deleted", stored)
+				}
+				return
+			}
+			if len(stored) != 1 || stored[0].Attempts != tt.wantAttempts {
+				t.Fatalf("queue = %+v, want attempt %d stored", stored, tt.wantAttempts)
+			}
+			// Backoff doubles with each attempt
+			if want := before.Add(queuedAlertRetryDelay << tt.attempts); stored[0].NextAttemptAt.Before(want) {
+				t.Errorf("next attempt at %s, want after %s", stored[0].NextAttemptAt, want)
+			}
+		})
+	}
+}
+
+func TestNotifyQueueFull(t *testing.T) {
+	queue := newMemoryAlertQueue()
+	am := newTestAlertManager(t)
+	// Stop the worker, so the in-memory queue fills up
+	am.cancel()
+	<-am.done
+	am.queueRepo = queue
+
+	for i := 0; i < cap(am.queue)+2; i++ {
+		am.Notify(newGroupEvent("app-1", "group-1", time.Now()))
+	}
+	if got := len(am.queue); got != cap(am.queue) {
+		t.Fatalf("in-memory queue = %d, want it full", got)
+	}
+	stored := queue.stored()
+	if len(stored) != cap(am.queue)+2 {
+		t.Fatalf("stored events = %d, want all %d", len(stored), cap(am.queue)+2)
+	}
+	// Events that didn't fit are due right away, for polling to pick up
+	for _, event := range stored[cap(am.queue):] {
+		if am.isInFlight(event.ID) || event.NextAttemptAt.After(time.Now()) {
+			t.Errorf("event %d left to the in-memory queue, want it due for polling", event.ID)
+		}
+	}
+	if !am.isInFlight(stored[0].ID) {
+		t.Errorf("queued event %d not marked in flight", stored[0].ID)
+	}
+}
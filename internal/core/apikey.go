@@ -0,0 +1,85 @@
+package core
+
+import (
+	"fmt"
+	"time"
+)
+
+// API key scopes
+const (
+	// ScopeSubmit allows submitting crashes
+	ScopeSubmit = "submit"
+	// ScopeRead allows reading the app's crashes, groups, stats and alerts
+	ScopeRead = "read"
+	// ScopeManage allows changing and deleting the app's crashes and groups
+	ScopeManage = "manage"
+)
+
+// APIKey is one of an app's API keys. An app can have several, so clients
+// can move to a new key while the old one still works.
+type APIKey struct {
+	ID      string `json:"id"`
+	AppID   string `json:"app_id"`
+	KeyHash string `json:"-"`
+	Label   string `json:"label"`
+	// Scopes limit what the key may do; empty allows everything
+	Scopes []string `json:"scopes"`
+	// ExpiresAt is when the key stops working; nil never
+	ExpiresAt  *time.Time `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// Expired reports whether the key has expired by now
+func (k *APIKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+// AllScopes lists every API key scope
+var AllScopes = []string{ScopeSubmit, ScopeRead, ScopeManage}
+
+// NormalizeScopes validates scopes and drops duplicates, keeping their order.
+// No scopes means all of them, as for keys created before scopes existed.
+func NormalizeScopes(scopes []string) ([]string, error) {
+	seen := make(map[string]bool, len(scopes))
+	normalized := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		switch scope {
+		case ScopeSubmit, ScopeRead, ScopeManage:
+		default:
+			return nil, fmt.Errorf("unknown scope %q: must be %s, %s or %s", scope, ScopeSubmit, ScopeRead, ScopeManage)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			normalized = append(normalized, scope)
+		}
+	}
+	return normalized, nil
+}
+
+// HasScope reports whether the app's API key grants scope
+func (a *App) HasScope(scope string) bool {
+	if len(a.APIKeyScopes) == 0 {
+		return true
+	}
+	for _, s := range a.APIKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyExpired reports whether the app's API key has expired by now
+func (a *App) APIKeyExpired(now time.Time) bool {
+	return a.APIKeyExpiresAt != nil && !now.Before(*a.APIKeyExpiresAt)
+}
+
+// WithAPIKey sets the app's API key fields from key, for requests that
+// authenticated with it
+func (a *App) WithAPIKey(key *APIKey) {
+	a.APIKeyID = key.ID
+	a.APIKeyHash = key.KeyHash
+	a.APIKeyScopes = key.Scopes
+	a.APIKeyExpiresAt = key.ExpiresAt
+}
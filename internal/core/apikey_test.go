@@ -0,0 +1,134 @@
+package core
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestNormalizeScopes(t *testing.T) {
+	tests := []struct {
+		name    string
+		scopes  []string
+		want    []string
+		wantErr bool
+	}{
+		{"none", nil, []string{}, false},
+		{"one", []string{ScopeSubmit}, []string{ScopeSubmit}, false},
+		{"order kept", []string{ScopeManage, ScopeRead}, []string{ScopeManage, ScopeRead}, false},
+		{"duplicates dropped", []string{ScopeRead, ScopeSubmit, ScopeRead}, []string{ScopeRead, ScopeSubmit}, false},
+		{"unknown", []string{ScopeRead, "admin"}, nil, true},
+		{"wrong case", []string{"Read"}, nil, true},
+		{"empty", []string{""}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeScopes(tt.scopes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NormalizeScopes = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !slices.Equal(got, tt.want) {
+				t.Errorf("NormalizeScopes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppHasScope(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes []string
+		want   map[string]bool
+	}{
+		// Keys from before scopes existed can do everything
+		{"no scopes", nil, map[string]bool{ScopeSubmit: true, ScopeRead: true, ScopeManage: true}},
+		{"submit only", []string{ScopeSubmit}, map[string]bool{ScopeSubmit: true, ScopeRead: false, ScopeManage: false}},
+		{"read and manage", []string{ScopeRead, ScopeManage}, map[string]bool{ScopeSubmit: false, ScopeRead: true, ScopeManage: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &App{APIKeyScopes: tt.scopes}
+			for scope, want := range tt.want {
+				if got := app.HasScope(scope); got != want {
+					t.Errorf("HasScope(%s) = %v, want %v", scope, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAppAPIKeyExpired(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+	tests := []struct {
+		name      string
+		expiresAt *time.Time
+		want      bool
+	}{
+		{"no expiry", nil, false},
+		{"expires later", at(time.Second), false},
+		{"expires now", at(0), true},
+		{"expired", at(-time.Hour), true},
+	}
+	for _, tt := range tests {
+		if got := (&App{APIKeyExpiresAt: tt.expiresAt}).APIKeyExpired(now); got != tt.want {
+			t.Errorf("%s: APIKeyExpired = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAPIKeyExpired(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	later, earlier := now.Add(time.Minute), now.Add(-time.Minute)
+
+	tests := []struct {
+		name      string
+		expiresAt *time.Time
+		want      bool
+	}{
+		{"no expiry", nil, false},
+		{"expires later", &later, false},
+		{"expires now", &now, true},
+		{"expired", &earlier, true},
+	}
+	for _, tt := range tests {
+		if got := (&APIKey{ExpiresAt: tt.expiresAt}).Expired(now); got != tt.want {
+			t.Errorf("%s: Expired = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAppWithAPIKey(t *testing.T) {
+	expiresAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		key  *APIKey
+	}{
+		{"unscoped key", &APIKey{ID: "key-2", KeyHash: "hash-2"}},
+		{"scoped key", &APIKey{ID: "key-3", KeyHash: "hash-3", Scopes: []string{ScopeRead}, ExpiresAt: &expiresAt}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The app as loaded carries its current key; a request made
+			// with another key sees that one instead
+			app := &App{ID: "app-1", APIKeyID: "key-1", APIKeyHash: "hash-1", APIKeyScopes: []string{ScopeSubmit}}
+			app.WithAPIKey(tt.key)
+
+			if app.APIKeyID != tt.key.ID || app.APIKeyHash != tt.key.KeyHash {
+				t.Errorf("key = %s (%s), want %s (%s)", app.APIKeyID, app.APIKeyHash, tt.key.ID, tt.key.KeyHash)
+			}
+			if !slices.Equal(app.APIKeyScopes, tt.key.Scopes) {
+				t.Errorf("scopes = %v, want %v", app.APIKeyScopes, tt.key.Scopes)
+			}
+			if app.APIKeyExpiresAt != tt.key.ExpiresAt {
+				t.Errorf("expires at = %v, want %v", app.APIKeyExpiresAt, tt.key.ExpiresAt)
+			}
+			if app.ID != "app-1" {
+				t.Errorf("app ID = %s, want app-1", app.ID)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// APIKeyUsageInterval is how often an API key's last use is written at most
+const APIKeyUsageInterval = time.Minute
+
+// apiKeyUsageWriteTimeout bounds each background last-use write
+const apiKeyUsageWriteTimeout = 5 * time.Second
+
+// APIKeyUsageRepository defines the storage operation for API key usage
+type APIKeyUsageRepository interface {
+	// TouchAPIKey sets the last use of an app's API key, and of the app
+	TouchAPIKey(ctx context.Context, appID, id string, usedAt time.Time) error
+}
+
+// APIKeyUsageTracker records when API keys are used. Writes happen in the
+// background, at most once per interval per key, so authenticating a
+// request never waits on them and busy keys don't write on every request.
+type APIKeyUsageTracker struct {
+	repo     APIKeyUsageRepository
+	interval time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	written map[string]time.Time
+	pending sync.WaitGroup
+}
+
+// NewAPIKeyUsageTracker creates a tracker writing to repo at most once per
+// interval per key
+func NewAPIKeyUsageTracker(repo APIKeyUsageRepository, interval time.Duration) *APIKeyUsageTracker {
+	return &APIKeyUsageTracker{
+		repo:     repo,
+		interval: interval,
+		now:      time.Now,
+		written:  make(map[string]time.Time),
+	}
+}
+
+// SetClock replaces the clock used for throttling writes
+func (t *APIKeyUsageTracker) SetClock(now func() time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.now = now
+}
+
+// Touch records that app authenticated with its API key now. It does
+// nothing for a nil tracker or an app without a key ID, such as the admin
+// app.
+func (t *APIKeyUsageTracker) Touch(app *App) {
+	if t == nil || app == nil || app.APIKeyID == "" {
+		return
+	}
+
+	t.mu.Lock()
+	now := t.now().UTC()
+	if last, ok := t.written[app.APIKeyID]; ok && now.Sub(last) < t.interval {
+		t.mu.Unlock()
+		return
+	}
+	// Marked before writing, so concurrent requests don't write too. A
+	// failed write is retried on the first use after the next interval.
+	t.written[app.APIKeyID] = now
+	t.mu.Unlock()
+
+	appID, keyID := app.ID, app.APIKeyID
+	t.pending.Add(1)
+	go func() {
+		defer t.pending.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), apiKeyUsageWriteTimeout)
+		defer cancel()
+		if err := t.repo.TouchAPIKey(ctx, appID, keyID, now); err != nil {
+			log.Warn().Err(err).Str("app_id", appID).Str("key_id", keyID).Msg("Failed to record API key use")
+		}
+	}()
+}
+
+// Wait blocks until writes already started have finished
+func (t *APIKeyUsageTracker) Wait() {
+	if t == nil {
+		return
+	}
+	t.pending.Wait()
+}
@@ -0,0 +1,131 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// touch is a last-use write seen by recordingUsageRepository
+type touch struct {
+	appID, keyID string
+	usedAt       time.Time
+}
+
+// recordingUsageRepository records last-use writes, failing while err is set
+type recordingUsageRepository struct {
+	mu      sync.Mutex
+	err     error
+	touches []touch
+}
+
+func (r *recordingUsageRepository) TouchAPIKey(ctx context.Context, appID, id string, usedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.touches = append(r.touches, touch{appID, id, usedAt})
+	return r.err
+}
+
+func TestAPIKeyUsageTracker(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	app := func(id, keyID string) *App { return &App{ID: id, APIKeyID: keyID} }
+
+	type use struct {
+		app   *App
+		after time.Duration
+	}
+	tests := []struct {
+		name string
+		uses []use
+		want []touch
+	}{
+		{
+			name: "first use",
+			uses: []use{{app("app-1", "key-1"), 0}},
+			want: []touch{{"app-1", "key-1", start}},
+		},
+		{
+			name: "within the interval",
+			uses: []use{{app("app-1", "key-1"), 0}, {app("app-1", "key-1"), 30 * time.Second}, {app("app-1", "key-1"), 59 * time.Second}},
+			want: []touch{{"app-1", "key-1", start}},
+		},
+		{
+			name: "after the interval",
+			uses: []use{{app("app-1", "key-1"), 0}, {app("app-1", "key-1"), 30 * time.Second}, {app("app-1", "key-1"), time.Minute}},
+			want: []touch{{"app-1", "key-1", start}, {"app-1", "key-1", start.Add(time.Minute)}},
+		},
+		{
+			name: "keys throttled separately",
+			uses: []use{{app("app-1", "key-1"), 0}, {app("app-1", "key-2"), time.Second}, {app("app-2", "key-3"), 2 * time.Second}},
+			want: []touch{{"app-1", "key-1", start}, {"app-1", "key-2", start.Add(time.Second)}, {"app-2", "key-3", start.Add(2 * time.Second)}},
+		},
+		{
+			name: "admin and missing apps",
+			uses: []use{{&App{ID: "admin"}, 0}, {nil, time.Second}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &recordingUsageRepository{}
+			tracker := NewAPIKeyUsageTracker(repo, time.Minute)
+			for _, u := range tt.uses {
+				tracker.SetClock(func() time.Time { return start.Add(u.after) })
+				tracker.Touch(u.app)
+				// Writes are in the background; wait for each so they
+				// can be compared in order
+				tracker.Wait()
+			}
+			if !slices.Equal(repo.touches, tt.want) {
+				t.Errorf("writes = %v, want %v", repo.touches, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIKeyUsageTrackerFailedWrite(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	repo := &recordingUsageRepository{err: errors.New("database is locked")}
+	tracker := NewAPIKeyUsageTracker(repo, time.Minute)
+	app := &App{ID: "app-1", APIKeyID: "key-1"}
+
+	// A failed write isn't retried until the interval has passed
+	for _, after := range []time.Duration{0, time.Second, time.Minute} {
+		tracker.SetClock(func() time.Time { return start.Add(after) })
+		tracker.Touch(app)
+		tracker.Wait()
+	}
+	want := []touch{{"app-1", "key-1", start}, {"app-1", "key-1", start.Add(time.Minute)}}
+	if !slices.Equal(repo.touches, want) {
+		t.Errorf("writes = %v, want %v", repo.touches, want)
+	}
+}
+
+func TestAPIKeyUsageTrackerNil(t *testing.T) {
+	var tracker *APIKeyUsageTracker
+	// Servers run without a tracker in tests and tools
+	tracker.Touch(&App{ID: "app-1", APIKeyID: "key-1"})
+	tracker.Wait()
+}
+
+func TestAPIKeyUsageTrackerConcurrentUses(t *testing.T) {
+	repo := &recordingUsageRepository{}
+	tracker := NewAPIKeyUsageTracker(repo, time.Minute)
+	app := &App{ID: "app-1", APIKeyID: "key-1"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tracker.Touch(app)
+		}()
+	}
+	wg.Wait()
+	tracker.Wait()
+	if len(repo.touches) != 1 {
+		t.Errorf("%d writes for concurrent uses, want 1", len(repo.touches))
+	}
+}
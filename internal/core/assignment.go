@@ -0,0 +1,73 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Assignment rule match fields
+const (
+	// AssignmentMatchErrorType matches the crash's error type exactly
+	AssignmentMatchErrorType = "error_type"
+	// AssignmentMatchFile matches a prefix of the culprit frame's file name,
+	// e.g. "lib/payments/"
+	AssignmentMatchFile = "file"
+	// AssignmentMatchPackage matches a prefix of the culprit frame's package,
+	// given either as a Dart-style file name ("package:payments/") or a
+	// class name ("com.example.payments.")
+	AssignmentMatchPackage = "package"
+)
+
+// AssignmentRule assigns new groups whose first crash matches to an owner
+type AssignmentRule struct {
+	Match    string `json:"match"`
+	Value    string `json:"value"`
+	Assignee string `json:"assignee"`
+}
+
+// Validate checks that the rule can be evaluated
+func (r AssignmentRule) Validate() error {
+	switch r.Match {
+	case AssignmentMatchErrorType, AssignmentMatchFile, AssignmentMatchPackage:
+	default:
+		return fmt.Errorf("unknown match %q: must be %s, %s or %s",
+			r.Match, AssignmentMatchErrorType, AssignmentMatchFile, AssignmentMatchPackage)
+	}
+	if strings.TrimSpace(r.Value) == "" {
+		return fmt.Errorf("value is required")
+	}
+	if strings.TrimSpace(r.Assignee) == "" {
+		return fmt.Errorf("assignee is required")
+	}
+	return nil
+}
+
+// Matches reports whether the rule applies to a crash
+func (r AssignmentRule) Matches(crash *Crash) bool {
+	if r.Match == AssignmentMatchErrorType {
+		return crash.ErrorType == r.Value
+	}
+
+	frame := GetTopFrame(crash)
+	if frame == nil {
+		return false
+	}
+	switch r.Match {
+	case AssignmentMatchFile:
+		return strings.HasPrefix(frame.FileName, r.Value)
+	case AssignmentMatchPackage:
+		return strings.HasPrefix(frame.FileName, r.Value) || strings.HasPrefix(frame.ClassName, r.Value)
+	}
+	return false
+}
+
+// ResolveAssignee returns the assignee of the first rule matching the crash,
+// or "" if none match
+func ResolveAssignee(rules []AssignmentRule, crash *Crash) string {
+	for _, rule := range rules {
+		if rule.Matches(crash) {
+			return rule.Assignee
+		}
+	}
+	return ""
+}
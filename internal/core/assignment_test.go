@@ -0,0 +1,67 @@
+package core
+
+import "testing"
+
+func TestAssignmentRuleValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    AssignmentRule
+		wantErr bool
+	}{
+		{"error type", AssignmentRule{Match: AssignmentMatchErrorType, Value: "StateError", Assignee: "alice"}, false},
+		{"file", AssignmentRule{Match: AssignmentMatchFile, Value: "lib/payments/", Assignee: "payments"}, false},
+		{"package", AssignmentRule{Match: AssignmentMatchPackage, Value: "com.example.", Assignee: "android"}, false},
+		{"unknown match", AssignmentRule{Match: "message", Value: "x", Assignee: "alice"}, true},
+		{"no value", AssignmentRule{Match: AssignmentMatchFile, Value: " ", Assignee: "alice"}, true},
+		{"no assignee", AssignmentRule{Match: AssignmentMatchFile, Value: "lib/"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rule.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolveAssignee(t *testing.T) {
+	rules := []AssignmentRule{
+		{Match: AssignmentMatchErrorType, Value: "PaymentError", Assignee: "billing"},
+		{Match: AssignmentMatchFile, Value: "lib/payments/", Assignee: "payments"},
+		{Match: AssignmentMatchPackage, Value: "package:auth/", Assignee: "identity"},
+		{Match: AssignmentMatchPackage, Value: "com.example.player.", Assignee: "media"},
+	}
+	crash := func(errorType string, frames ...StackFrame) *Crash {
+		return &Crash{ErrorType: errorType, StackTrace: frames}
+	}
+
+	tests := []struct {
+		name  string
+		crash *Crash
+		want  string
+	}{
+		{"error type", crash("PaymentError", StackFrame{FileName: "lib/main.dart"}), "billing"},
+		{"first matching rule wins", crash("PaymentError", StackFrame{FileName: "lib/payments/card.dart"}), "billing"},
+		{"file prefix", crash("StateError", StackFrame{FileName: "lib/payments/card.dart"}), "payments"},
+		{"dart package", crash("StateError", StackFrame{FileName: "package:auth/login.dart"}), "identity"},
+		{"class package", crash("NullPointerException", StackFrame{FileName: "Player.java", ClassName: "com.example.player.Player"}), "media"},
+		{
+			name: "culprit frame below native frames",
+			crash: crash("SIGSEGV",
+				StackFrame{FileName: "libc.so", MethodName: "abort", Native: true},
+				StackFrame{FileName: "lib/payments/card.dart"},
+			),
+			want: "payments",
+		},
+		{"only the culprit frame", crash("StateError", StackFrame{FileName: "lib/main.dart"}, StackFrame{FileName: "lib/payments/card.dart"}), ""},
+		{"no frames", crash("StateError"), ""},
+		{"no match", crash("StateError", StackFrame{FileName: "lib/home.dart"}), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ResolveAssignee(rules, tt.crash); got != tt.want {
+				t.Errorf("ResolveAssignee() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
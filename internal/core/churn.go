@@ -0,0 +1,79 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+// ChurnGuard watches how fast each app creates new crash groups. A burst of
+// new groups usually means fingerprints have stopped being stable (e.g. a
+// normalization bug or an unusual deploy), so the guard trips and can switch
+// the app to a coarse error-type-only fingerprint for a while.
+type ChurnGuard struct {
+	maxNewGroups int
+	window       time.Duration
+	fallback     time.Duration
+
+	mu      sync.Mutex
+	created map[string][]time.Time
+	tripped map[string]time.Time // app ID -> when the guard tripped
+}
+
+// NewChurnGuard creates a guard that trips when an app creates more than
+// maxNewGroups groups within window. While tripped, InFallback reports true
+// for fallback (zero disables the coarse fingerprint fallback).
+func NewChurnGuard(maxNewGroups int, window, fallback time.Duration) *ChurnGuard {
+	return &ChurnGuard{
+		maxNewGroups: maxNewGroups,
+		window:       window,
+		fallback:     fallback,
+		created:      make(map[string][]time.Time),
+		tripped:      make(map[string]time.Time),
+	}
+}
+
+// RecordNewGroup notes a new group for an app and reports whether this
+// pushed the app over the limit. It returns true once per trip.
+func (cg *ChurnGuard) RecordNewGroup(appID string, t time.Time) bool {
+	if cg == nil || cg.maxNewGroups <= 0 {
+		return false
+	}
+
+	cg.mu.Lock()
+	defer cg.mu.Unlock()
+
+	cutoff := t.Add(-cg.window)
+	recent := cg.created[appID][:0]
+	for _, ts := range cg.created[appID] {
+		if ts.After(cutoff) {
+			recent = append(recent, ts)
+		}
+	}
+	recent = append(recent, t)
+	cg.created[appID] = recent
+
+	if len(recent) <= cg.maxNewGroups {
+		return false
+	}
+
+	// Already tripped within this window; don't report again
+	if trippedt, ok := cg.tripped[appID]; ok && t.Sub(trippedt) < cg.window {
+		return false
+	}
+	cg.tripped[appID] = t
+	return true
+}
+
+// InFallback reports whether new crashes for an app should use the coarse
+// fingerprint because the guard tripped recently
+func (cg *ChurnGuard) InFallback(appID string, t time.Time) bool {
+	if cg == nil || cg.fallback <= 0 {
+		return false
+	}
+
+	cg.mu.Lock()
+	defer cg.mu.Unlock()
+
+	trippedt, ok := cg.tripped[appID]
+	return ok && t.Sub(trippedt) < cg.fallback
+}
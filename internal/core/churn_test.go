@@ -0,0 +1,59 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChurnGuard(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	every := func(n int, step time.Duration) []time.Time {
+		var times []time.Time
+		for i := 0; i < n; i++ {
+			times = append(times, start.Add(time.Duration(i)*step))
+		}
+		return times
+	}
+
+	tests := []struct {
+		name     string
+		max      int
+		fallback time.Duration
+		// created are when new groups were created, all within one app
+		created   []time.Time
+		wantTrips int
+		// checkAt is when InFallback is asked after the last group
+		checkAt      time.Duration
+		wantFallback bool
+	}{
+		{"under the limit", 5, time.Hour, every(5, time.Second), 0, time.Minute, false},
+		{"burst trips once", 5, time.Hour, every(20, time.Second), 1, time.Minute, true},
+		{"spread out", 5, time.Hour, every(20, 2*time.Minute), 0, time.Minute, false},
+		{"fallback expires", 5, time.Hour, every(6, time.Second), 1, 2 * time.Hour, false},
+		{"fallback disabled", 5, 0, every(6, time.Second), 1, time.Minute, false},
+		{"guard disabled", 0, time.Hour, every(100, time.Second), 0, time.Minute, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cg := NewChurnGuard(tt.max, 10*time.Minute, tt.fallback)
+			trips := 0
+			for _, at := range tt.created {
+				if cg.RecordNewGroup("app-1", at) {
+					trips++
+				}
+			}
+			if trips != tt.wantTrips {
+				t.Errorf("trips = %d, want %d", trips, tt.wantTrips)
+			}
+
+			last := tt.created[len(tt.created)-1]
+			if got := cg.InFallback("app-1", last.Add(tt.checkAt)); got != tt.wantFallback {
+				t.Errorf("InFallback = %v, want %v", got, tt.wantFallback)
+			}
+			if cg.InFallback("app-2", last) {
+				t.Error("another app is in fallback")
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+// GroupCounter keeps in-memory, time-bucketed occurrence counts per crash
+// group so alert conditions can ask "how many crashes in this window"
+// without querying the database
+type GroupCounter struct {
+	mu         sync.Mutex
+	buckets    map[string]map[int64]int
+	bucketSize time.Duration
+	retain     time.Duration
+}
+
+// NewGroupCounter creates a GroupCounter with the given bucket resolution,
+// keeping counts for at most retain
+func NewGroupCounter(bucketSize, retain time.Duration) *GroupCounter {
+	return &GroupCounter{
+		buckets:    make(map[string]map[int64]int),
+		bucketSize: bucketSize,
+		retain:     retain,
+	}
+}
+
+// Record adds one occurrence for a group at time t
+func (gc *GroupCounter) Record(groupID string, t time.Time) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	groupBuckets, ok := gc.buckets[groupID]
+	if !ok {
+		groupBuckets = make(map[int64]int)
+		gc.buckets[groupID] = groupBuckets
+	}
+	groupBuckets[gc.bucket(t)]++
+
+	// Drop buckets that have aged out of the retention window
+	oldest := gc.bucket(t.Add(-gc.retain))
+	for b := range groupBuckets {
+		if b < oldest {
+			delete(groupBuckets, b)
+		}
+	}
+}
+
+// Count returns the number of occurrences for a group in (from, to], at
+// bucket resolution. Adjacent windows sharing a boundary don't overlap.
+func (gc *GroupCounter) Count(groupID string, from, to time.Time) int {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	start, end := gc.bucket(from), gc.bucket(to)
+	total := 0
+	for b, n := range gc.buckets[groupID] {
+		if b > start && b <= end {
+			total += n
+		}
+	}
+	return total
+}
+
+// Prune drops groups with no occurrences since before
+func (gc *GroupCounter) Prune(before time.Time) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	cutoff := gc.bucket(before)
+	for groupID, groupBuckets := range gc.buckets {
+		stale := true
+		for b := range groupBuckets {
+			if b >= cutoff {
+				stale = false
+				break
+			}
+		}
+		if stale {
+			delete(gc.buckets, groupID)
+		}
+	}
+}
+
+// bucket returns the bucket index for a time
+func (gc *GroupCounter) bucket(t time.Time) int64 {
+	return t.UnixNano() / int64(gc.bucketSize)
+}
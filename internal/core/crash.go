@@ -1,38 +1,95 @@
 package core
 
 import (
+	"math"
 	"time"
 )
 
 // Crash represents a single crash report
 type Crash struct {
-	ID          string                 `json:"id"`
-	AppID       string                 `json:"app_id"`
-	AppVersion  string                 `json:"app_version"`
-	Platform    string                 `json:"platform"` // ios, android, web, etc.
-	OSVersion   string                 `json:"os_version"`
-	DeviceModel string                 `json:"device_model"`
-	ErrorType   string                 `json:"error_type"`
-	ErrorMessage string               `json:"error_message"`
-	StackTrace  []StackFrame           `json:"stack_trace"`
-	Fingerprint string                 `json:"fingerprint"`
-	GroupID     string                 `json:"group_id"`
-	UserID      string                 `json:"user_id,omitempty"`
-	Environment string                 `json:"environment"` // production, staging, dev
-	CreatedAt   time.Time              `json:"created_at"`
-	LogFilePath string                 `json:"log_file_path,omitempty"`
-	Metadata    map[string]interface{} `json:"metadata,omitempty"`
-	Breadcrumbs []Breadcrumb           `json:"breadcrumbs,omitempty"`
+	ID                 string                 `json:"id"`
+	AppID              string                 `json:"app_id"`
+	EventID            string                 `json:"event_id,omitempty"` // client-generated idempotency key
+	AppVersion         string                 `json:"app_version"`
+	Platform           string                 `json:"platform"` // ios, android, web, etc.
+	OSVersion          string                 `json:"os_version"`
+	DeviceModel        string                 `json:"device_model"`
+	ErrorType          string                 `json:"error_type"`
+	ErrorMessage       string                 `json:"error_message"`
+	StackTrace         []StackFrame           `json:"stack_trace"`
+	Fingerprint        string                 `json:"fingerprint"`
+	FingerprintVersion int                    `json:"fingerprint_version"`
+	GroupID            string                 `json:"group_id"`
+	UserID             string                 `json:"user_id,omitempty"`
+	Environment        string                 `json:"environment"` // production, staging, dev
+	CreatedAt          time.Time              `json:"created_at"`
+	LogFilePath        string                 `json:"log_file_path,omitempty"`
+	Metadata           map[string]interface{} `json:"metadata,omitempty"`
+	Breadcrumbs        []Breadcrumb           `json:"breadcrumbs,omitempty"`
+	// Country is the ISO country code of the submitting client's IP, when
+	// GeoIP enrichment is enabled
+	Country string `json:"country,omitempty"`
+	// OccurredAt is when the client says the crash happened, by its own
+	// clock; kept in the payload file only
+	OccurredAt *time.Time `json:"occurred_at,omitempty"`
+	// Historical is set when the crash occurred so long ago that it was
+	// attached to its existing group without counting as a new occurrence
+	// (see grouping.historical_after); kept in the payload file only
+	Historical bool `json:"historical,omitempty"`
+	// MetadataTrimmed is set when metadata or breadcrumb data were cut down
+	// to ingest.metadata_limits; kept in the payload file only
+	MetadataTrimmed bool `json:"metadata_trimmed,omitempty"`
+	// DebugImages are the binary images loaded in the crashed process, used
+	// to find dSYMs for symbolication; kept in the payload file only
+	DebugImages []DebugImage `json:"debug_images,omitempty"`
+	// Symbolicated is set for iOS crashes with raw address frames, and web
+	// crashes from apps with source maps: whether any frames were resolved
+	// with an uploaded dSYM or source map
+	Symbolicated *bool `json:"symbolicated,omitempty"`
+	// RawStackTrace is the stack trace as submitted, when symbolication,
+	// deobfuscation or source maps rewrote StackTrace; kept in the payload
+	// file only
+	RawStackTrace []StackFrame `json:"raw_stack_trace,omitempty"`
+	// QuarantineReason is set when ingest heuristics held the crash back from
+	// grouping; empty for normal crashes
+	QuarantineReason string `json:"quarantine_reason,omitempty"`
+	// FileStatus is set when the full payload file couldn't be loaded and the
+	// crash holds only its indexed (truncated) fields; never persisted
+	FileStatus string `json:"file_status,omitempty"`
+
+	// GroupTitle is the title given to the crash's group if ingesting it
+	// creates a new one; it is not persisted with the crash itself
+	GroupTitle string `json:"-"`
+	// GroupCulprit is the culprit (see Culprit) recorded for the crash's
+	// group if ingesting it creates a new one
+	GroupCulprit string `json:"-"`
+	// Released is set when an already stored quarantined crash is put
+	// through ingestion again: its row is updated rather than inserted
+	Released bool `json:"-"`
 }
 
+// Reasons a crash's full payload file couldn't be loaded
+const (
+	FileStatusMissing    = "missing"
+	FileStatusCorrupt    = "corrupt"
+	FileStatusUnreadable = "unreadable"
+)
+
 // StackFrame represents a single frame in a stack trace
 type StackFrame struct {
-	FileName   string `json:"file_name"`
-	LineNumber int    `json:"line_number"`
-	ColumnNumber int  `json:"column_number,omitempty"`
-	MethodName string `json:"method_name"`
-	ClassName  string `json:"class_name,omitempty"`
-	Native     bool   `json:"native,omitempty"`
+	FileName     string `json:"file_name"`
+	LineNumber   int    `json:"line_number"`
+	ColumnNumber int    `json:"column_number,omitempty"`
+	MethodName   string `json:"method_name"`
+	ClassName    string `json:"class_name,omitempty"`
+	Native       bool   `json:"native,omitempty"`
+}
+
+// DebugImage identifies a binary image loaded in a crashed Apple process.
+// Name matches the file name of the image's frames.
+type DebugImage struct {
+	Name string `json:"name"`
+	UUID string `json:"uuid"`
 }
 
 // Breadcrumb represents a user action or event leading up to a crash
@@ -50,6 +107,7 @@ type CrashGroup struct {
 	ID              string    `json:"id"`
 	AppID           string    `json:"app_id"`
 	Fingerprint     string    `json:"fingerprint"`
+	Title           string    `json:"title"`
 	ErrorType       string    `json:"error_type"`
 	ErrorMessage    string    `json:"error_message"`
 	FirstSeen       time.Time `json:"first_seen"`
@@ -58,6 +116,88 @@ type CrashGroup struct {
 	Status          string    `json:"status"` // open, resolved, ignored
 	AssignedTo      string    `json:"assigned_to,omitempty"`
 	Notes           string    `json:"notes,omitempty"`
+	// Culprit is "Class.method" of the most relevant frame of the crash
+	// that created the group; empty for groups created before it was kept
+	Culprit string `json:"culprit,omitempty"`
+	// AggregateOnly groups only count new occurrences; their crashes are
+	// not stored individually
+	AggregateOnly bool `json:"aggregate_only"`
+	// TrendKey is the stored form of the trending score (see TrendKey)
+	TrendKey float64 `json:"-"`
+	// RegressedAt is when a crash last reopened the group after it was
+	// resolved
+	RegressedAt *time.Time `json:"regressed_at,omitempty"`
+	// PreviousStatus is set by GetOrCreateGroup when the crash reopened the
+	// group, to the status it had
+	PreviousStatus string `json:"-"`
+	// SplitKey salts the fingerprint of a group split off another (see
+	// Grouper.SplitFingerprint); empty for groups created by ingestion
+	SplitKey string `json:"split_key,omitempty"`
+
+	// Set at read time from the group's stored crashes by
+	// SummarizeGroupCrashes: the number of distinct users they were
+	// reported for, and the most recent one
+	AffectedUsers int    `json:"affected_users,omitempty"`
+	LatestCrashID string `json:"latest_crash_id,omitempty"`
+
+	// Computed at read time, in seconds
+	Age                     int64 `json:"age"`
+	TimeSinceLastOccurrence int64 `json:"time_since_last_occurrence"`
+	// TrendingScore counts the group's occurrences weighted by how recent
+	// they are: one now counts 1, one a TrendHalfLife ago 0.5
+	TrendingScore float64 `json:"trending_score"`
+}
+
+// IsAggregating reports whether new crashes for the group should only be
+// counted: either it is set aggregate-only, or it is ignored and has reached
+// threshold occurrences (0 disables the threshold)
+func (g *CrashGroup) IsAggregating(threshold int) bool {
+	if g.AggregateOnly {
+		return true
+	}
+	return threshold > 0 && g.Status == string(GroupStatusIgnored) && g.OccurrenceCount >= threshold
+}
+
+// ComputeActivity fills in the group's age and time since it last occurred
+func (g *CrashGroup) ComputeActivity(now time.Time) {
+	g.Age = int64(now.Sub(g.FirstSeen).Seconds())
+	g.TimeSinceLastOccurrence = int64(now.Sub(g.LastSeen).Seconds())
+	if g.TrendKey != 0 {
+		g.TrendingScore = math.Round(math.Exp(g.TrendKey-TrendKey(now))*1000) / 1000
+	}
+}
+
+// TrendHalfLife is how long it takes a group's trending score to halve
+// once it stops occurring
+const TrendHalfLife = 24 * time.Hour
+
+// TrendKey returns the trend key of one occurrence at t. A group's key is
+// the log of the sum of e^TrendKey over its occurrences. Unlike the decayed
+// score itself, it doesn't change as time passes, yet sorting groups by it
+// sorts them by their current trending score. Zero means no occurrences.
+func TrendKey(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(TrendHalfLife) * math.Ln2
+}
+
+// AddTrend returns the trend key of the occurrences of two keys together
+func AddTrend(a, b float64) float64 {
+	if a == 0 || b == 0 {
+		return a + b
+	}
+	hi, lo := max(a, b), min(a, b)
+	return hi + math.Log1p(math.Exp(lo-hi))
+}
+
+// SubTrend returns the trend key of a's occurrences without b's, which must
+// be among them
+func SubTrend(a, b float64) float64 {
+	if b == 0 {
+		return a
+	}
+	if b >= a {
+		return 0
+	}
+	return a + math.Log1p(-math.Exp(b-a))
 }
 
 // App represents a registered application
@@ -68,6 +208,37 @@ type App struct {
 	APIKeyHash    string    `json:"-"` // Stored in DB, not exposed
 	CreatedAt     time.Time `json:"created_at"`
 	RetentionDays int       `json:"retention_days"`
+	// IncludeFrameworkFrames fingerprints native/framework frames instead of
+	// skipping them, for apps whose bugs are inside the framework
+	IncludeFrameworkFrames bool `json:"include_framework_frames"`
+	// FingerprintMetadataKeys lists crash metadata keys, such as http_status,
+	// whose values are added to the fingerprint so they group separately
+	FingerprintMetadataKeys []string `json:"fingerprint_metadata_keys"`
+	// FingerprintConfig overrides the server's fingerprinting rules
+	FingerprintConfig GrouperConfig `json:"fingerprint_config"`
+	// MaxGroups caps the app's crash groups; 0 uses the server default
+	MaxGroups int `json:"max_groups"`
+	// AssignmentRules set the assignee of new groups; the first matching
+	// rule wins
+	AssignmentRules []AssignmentRule `json:"assignment_rules"`
+	// Disabled apps reject new crashes but keep their data, which stays
+	// readable and subject to retention
+	Disabled bool `json:"disabled"`
+	// MaintenanceWindows suppress the app's alerts while active
+	MaintenanceWindows []MaintenanceWindow `json:"maintenance_windows"`
+	// RateLimit overrides the server's crash submission rate limit
+	RateLimit RateLimit `json:"rate_limit"`
+	// APIKeyID, APIKeyScopes and APIKeyExpiresAt describe the API key in
+	// APIKey or APIKeyHash: the key an app is created with, or the one a
+	// request authenticated with. Other keys are listed with ListAPIKeys.
+	APIKeyID string `json:"-"`
+	// APIKeyScopes limit what the API key may do; empty allows everything
+	APIKeyScopes []string `json:"api_key_scopes"`
+	// APIKeyExpiresAt is when the API key stops working; nil never
+	APIKeyExpiresAt *time.Time `json:"api_key_expires_at"`
+	// LastUsedAt is when any of the app's API keys was last used, to within
+	// APIKeyUsageInterval; nil if never
+	LastUsedAt *time.Time `json:"last_used_at"`
 }
 
 // Alert represents an alert configuration
@@ -82,15 +253,16 @@ type Alert struct {
 
 // CrashStats represents statistics for an app
 type CrashStats struct {
-	AppID           string         `json:"app_id"`
-	TotalCrashes    int            `json:"total_crashes"`
-	TotalGroups     int            `json:"total_groups"`
-	OpenGroups      int            `json:"open_groups"`
-	CrashesLast24h  int            `json:"crashes_last_24h"`
-	CrashesLast7d   int            `json:"crashes_last_7d"`
-	CrashesLast30d  int            `json:"crashes_last_30d"`
-	TopErrors       []ErrorSummary `json:"top_errors"`
-	CrashTrend      []TrendPoint   `json:"crash_trend"`
+	AppID               string         `json:"app_id"`
+	TotalCrashes        int            `json:"total_crashes"`
+	TotalGroups         int            `json:"total_groups"`
+	OpenGroups          int            `json:"open_groups"`
+	CrashesLast24h      int            `json:"crashes_last_24h"`
+	CrashesLast7d       int            `json:"crashes_last_7d"`
+	CrashesLast30d      int            `json:"crashes_last_30d"`
+	TopErrors           []ErrorSummary `json:"top_errors"`
+	FingerprintVersions map[int]int    `json:"fingerprint_versions"`
+	CrashTrend          []TrendPoint   `json:"crash_trend"`
 }
 
 // ErrorSummary represents a summary of an error type
@@ -101,6 +273,27 @@ type ErrorSummary struct {
 	Count        int    `json:"count"`
 }
 
+// UserCrashGroup is a crash group one user has hit, with how many of their
+// crashes are in it
+type UserCrashGroup struct {
+	ErrorSummary
+	Status GroupStatus `json:"status"`
+}
+
+// AppStatsSummary is the subset of an app's statistics shown in app listings
+type AppStatsSummary struct {
+	TotalCrashes   int `json:"total_crashes"`
+	OpenGroups     int `json:"open_groups"`
+	CrashesLast24h int `json:"crashes_last_24h"`
+}
+
+// AppVersion marks when a version of an app was first seen crashing, which
+// stands in for its deploy time
+type AppVersion struct {
+	Version   string    `json:"version"`
+	FirstSeen time.Time `json:"first_seen"`
+}
+
 // TrendPoint represents a single point in a crash trend
 type TrendPoint struct {
 	Date  string `json:"date"`
@@ -109,17 +302,21 @@ type TrendPoint struct {
 
 // CrashSubmission represents the incoming crash report from clients
 type CrashSubmission struct {
-	AppVersion   string                 `json:"app_version" binding:"required"`
-	Platform     string                 `json:"platform" binding:"required"`
-	OSVersion    string                 `json:"os_version"`
-	DeviceModel  string                 `json:"device_model"`
-	ErrorType    string                 `json:"error_type" binding:"required"`
-	ErrorMessage string                 `json:"error_message" binding:"required"`
-	StackTrace   []StackFrame           `json:"stack_trace" binding:"required"`
-	UserID       string                 `json:"user_id,omitempty"`
-	Environment  string                 `json:"environment"`
-	Metadata     map[string]interface{} `json:"metadata,omitempty"`
-	Breadcrumbs  []Breadcrumb           `json:"breadcrumbs,omitempty"`
+	EventID       string                 `json:"event_id,omitempty"`
+	AppVersion    string                 `json:"app_version" binding:"required"`
+	Platform      string                 `json:"platform" binding:"required"`
+	OSVersion     string                 `json:"os_version"`
+	DeviceModel   string                 `json:"device_model"`
+	ErrorType     string                 `json:"error_type"` // required unless grouping.error_type_fallback is set
+	ErrorMessage  string                 `json:"error_message" binding:"required"`
+	StackTrace    []StackFrame           `json:"stack_trace" binding:"required_without=RawStackTrace"`
+	UserID        string                 `json:"user_id,omitempty"`
+	Environment   string                 `json:"environment"`
+	Metadata      map[string]interface{} `json:"metadata,omitempty"`
+	Breadcrumbs   []Breadcrumb           `json:"breadcrumbs,omitempty"`
+	OccurredAt    *time.Time             `json:"occurred_at,omitempty"`
+	DebugImages   []DebugImage           `json:"debug_images,omitempty"`
+	RawStackTrace string                 `json:"raw_stack_trace,omitempty"` // text form, parsed when stack_trace is empty
 }
 
 // GroupStatus represents valid statuses for crash groups
@@ -138,6 +335,7 @@ const (
 	PlatformWeb     = "web"
 	PlatformDesktop = "desktop"
 	PlatformFlutter = "flutter"
+	PlatformPython  = "python"
 )
 
 // Environment constants
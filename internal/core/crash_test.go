@@ -0,0 +1,96 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComputeActivity(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name                   string
+		firstSeen, lastSeen    time.Time
+		wantAge, wantSinceLast int64
+	}{
+		{"just seen", now, now, 0, 0},
+		{"old and active", now.Add(-72 * time.Hour), now.Add(-time.Minute), 72 * 3600, 60},
+		{"old and quiet", now.Add(-30 * 24 * time.Hour), now.Add(-7 * 24 * time.Hour), 30 * 24 * 3600, 7 * 24 * 3600},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &CrashGroup{FirstSeen: tt.firstSeen, LastSeen: tt.lastSeen}
+			g.ComputeActivity(now)
+			if g.Age != tt.wantAge || g.TimeSinceLastOccurrence != tt.wantSinceLast {
+				t.Errorf("age, since last = %d, %d, want %d, %d", g.Age, g.TimeSinceLastOccurrence, tt.wantAge, tt.wantSinceLast)
+			}
+		})
+	}
+}
+
+func TestIsAggregating(t *testing.T) {
+	tests := []struct {
+		name      string
+		group     CrashGroup
+		threshold int
+		want      bool
+	}{
+		{"set manually", CrashGroup{AggregateOnly: true, Status: string(GroupStatusOpen), OccurrenceCount: 1}, 0, true},
+		{"ignored past threshold", CrashGroup{Status: string(GroupStatusIgnored), OccurrenceCount: 100}, 100, true},
+		{"ignored below threshold", CrashGroup{Status: string(GroupStatusIgnored), OccurrenceCount: 99}, 100, false},
+		{"open past threshold", CrashGroup{Status: string(GroupStatusOpen), OccurrenceCount: 1000}, 100, false},
+		{"threshold disabled", CrashGroup{Status: string(GroupStatusIgnored), OccurrenceCount: 1000}, 0, false},
+	}
+	for _, tt := range tests {
+		if got := tt.group.IsAggregating(tt.threshold); got != tt.want {
+			t.Errorf("%s: IsAggregating(%d) = %v, want %v", tt.name, tt.threshold, got, tt.want)
+		}
+	}
+}
+
+func TestTrendingScore(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	key := func(hoursAgo ...int) float64 {
+		var k float64
+		for _, h := range hoursAgo {
+			k = AddTrend(k, TrendKey(now.Add(-time.Duration(h)*time.Hour)))
+		}
+		return k
+	}
+
+	tests := []struct {
+		name      string
+		hoursAgo  []int
+		removed   []int
+		wantScore float64
+	}{
+		{"no occurrences", nil, nil, 0},
+		{"one now", []int{0}, nil, 1},
+		{"one a half-life ago", []int{24}, nil, 0.5},
+		{"mixed", []int{0, 0, 48}, nil, 2.25},
+		{"many long ago", []int{24 * 30, 24 * 30, 24 * 30}, nil, 0},
+		{"one removed", []int{0, 24, 48}, []int{24}, 1.25},
+		{"all removed", []int{0, 24}, []int{0, 24}, 0},
+		{"nothing removed", []int{0}, []int{}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &CrashGroup{FirstSeen: now, LastSeen: now, TrendKey: SubTrend(key(tt.hoursAgo...), key(tt.removed...))}
+			g.ComputeActivity(now)
+			if g.TrendingScore != tt.wantScore {
+				t.Errorf("trending score = %v, want %v", g.TrendingScore, tt.wantScore)
+			}
+		})
+	}
+
+	// Sorting by key sorts by the score at any later time
+	burst, steady := key(0, 0, 0), key(24, 24, 24, 24, 24, 24, 24)
+	for _, later := range []time.Duration{0, time.Hour, 5 * 24 * time.Hour} {
+		a := &CrashGroup{TrendKey: burst}
+		b := &CrashGroup{TrendKey: steady}
+		a.ComputeActivity(now.Add(later))
+		b.ComputeActivity(now.Add(later))
+		if (burst > steady) != (a.TrendingScore > b.TrendingScore) {
+			t.Errorf("%v later: scores %v, %v disagree with keys %v, %v", later, a.TrendingScore, b.TrendingScore, burst, steady)
+		}
+	}
+}
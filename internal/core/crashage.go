@@ -0,0 +1,6 @@
+package core
+
+// DefaultCrashAgeBuckets are the upper bounds, in seconds, of the crash age
+// histogram. Ages at or below zero mean the client's clock is ahead of the
+// server's; ages of hours or days point at clients replaying old reports.
+var DefaultCrashAgeBuckets = []float64{-3600, -60, 0, 1, 10, 60, 300, 3600, 21600, 86400, 604800}
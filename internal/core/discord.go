@@ -0,0 +1,126 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Discord embed limits. Field values are held well under Discord's own
+// 1024 so that a full embed stays within its 6000 character total.
+const (
+	discordTitleLimit       = 256
+	discordDescriptionLimit = 4096
+	discordFieldValueLimit  = 256
+)
+
+// Embed colors, matching the Slack attachment colors
+const (
+	discordColorCrash    = 0xff0000
+	discordColorNewGroup = 0xff6600
+)
+
+// sendDiscord posts an event as a Discord embed to the alert's webhook_url
+func (am *AlertManager) sendDiscord(alert *Alert, event AlertEvent) error {
+	webhookURL, _ := alert.Config["webhook_url"].(string)
+	if webhookURL == "" {
+		return fmt.Errorf("Discord webhook URL not configured")
+	}
+
+	color := discordColorCrash
+	if event.IsNewGroup {
+		color = discordColorNewGroup
+	}
+
+	title := fmt.Sprintf("Crash in %s", event.AppID)
+	if event.IsNewGroup {
+		title = fmt.Sprintf("🆕 NEW ERROR in %s", event.AppID)
+	}
+	if event.Threshold != nil {
+		title = fmt.Sprintf("📈 SPIKE in %s: %s", event.AppID, event.Threshold.Summary())
+	}
+	if event.Type == AlertEventRegression {
+		title = fmt.Sprintf("🔁 REGRESSION in %s (was %s)", event.AppID, event.PreviousStatus)
+	}
+	if event.Replay {
+		title = "[Replay] " + title
+	}
+
+	embed := map[string]interface{}{
+		"title":       TruncateField(title, discordTitleLimit),
+		"description": TruncateField(event.Crash.ErrorMessage, discordDescriptionLimit),
+		"color":       color,
+		"fields": []map[string]interface{}{
+			discordField("Error Type", event.Crash.ErrorType),
+			discordField("Platform", event.Crash.Platform),
+			discordField("App Version", event.Crash.AppVersion),
+			discordField("Environment", event.Crash.Environment),
+			discordField("Occurrences", fmt.Sprintf("%d", event.Group.OccurrenceCount)),
+		},
+		"footer":    map[string]interface{}{"text": "Inceptor Crash Logger"},
+		"timestamp": event.Crash.CreatedAt.UTC().Format(time.RFC3339),
+	}
+
+	return am.postDiscord(webhookURL, embed)
+}
+
+// sendCoalescedDiscord sends a batch of events as one Discord embed
+func (am *AlertManager) sendCoalescedDiscord(alert *Alert, events []AlertEvent, window time.Duration) error {
+	webhookURL, _ := alert.Config["webhook_url"].(string)
+	if webhookURL == "" {
+		return fmt.Errorf("Discord webhook URL not configured")
+	}
+
+	lines := make([]string, len(events))
+	for i, event := range events {
+		lines[i] = "• " + coalescedLine(event)
+	}
+
+	embed := map[string]interface{}{
+		"title":       TruncateField(fmt.Sprintf("%s in %s", coalescedSummary(events, window), events[0].AppID), discordTitleLimit),
+		"description": TruncateField(strings.Join(lines, "\n"), discordDescriptionLimit),
+		"color":       discordColorNewGroup,
+		"footer":      map[string]interface{}{"text": "Inceptor Crash Logger"},
+		"timestamp":   time.Now().UTC().Format(time.RFC3339),
+	}
+
+	return am.postDiscord(webhookURL, embed)
+}
+
+// discordField returns an inline embed field. Discord rejects empty values,
+// so those are shown as "-".
+func discordField(name, value string) map[string]interface{} {
+	if strings.TrimSpace(value) == "" {
+		value = "-"
+	}
+	return map[string]interface{}{
+		"name":   name,
+		"value":  TruncateField(value, discordFieldValueLimit),
+		"inline": true,
+	}
+}
+
+// postDiscord posts one embed to a Discord webhook
+func (am *AlertManager) postDiscord(webhookURL string, embed map[string]interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"username": "Inceptor",
+		"embeds":   []map[string]interface{}{embed},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	resp, err := am.client.Post(webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return retryable(fmt.Errorf("failed to send Discord message: %w", err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return statusError(resp, fmt.Errorf("Discord webhook returned status %d", resp.StatusCode))
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package core
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSendDiscord(t *testing.T) {
+	at := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	event := func(modify func(e *AlertEvent)) AlertEvent {
+		e := crashEvent("app-1", "group-1", 7, at)
+		e.Crash.ErrorMessage = "Bad state"
+		e.Crash.Platform = PlatformAndroid
+		e.Crash.AppVersion = "1.0.0"
+		if modify != nil {
+			modify(&e)
+		}
+		return e
+	}
+
+	tests := []struct {
+		name      string
+		event     AlertEvent
+		wantTitle string
+		wantColor float64
+	}{
+		{"crash", event(nil), "Crash in app-1", discordColorCrash},
+		{"new group", event(func(e *AlertEvent) { e.IsNewGroup = true }), "🆕 NEW ERROR in app-1", discordColorNewGroup},
+		{"threshold", event(func(e *AlertEvent) {
+			e.Threshold = &ThresholdCrossing{Count: 57, Window: 10 * time.Minute}
+		}), "📈 SPIKE in app-1: 57 crashes in the last 10m", discordColorCrash},
+		{"regression", event(func(e *AlertEvent) {
+			e.Type = AlertEventRegression
+			e.PreviousStatus = "resolved"
+		}), "🔁 REGRESSION in app-1 (was resolved)", discordColorCrash},
+		{"replay", event(func(e *AlertEvent) { e.Replay = true }), "[Replay] Crash in app-1", discordColorCrash},
+		{"long title", event(func(e *AlertEvent) { e.AppID = strings.Repeat("a", 300) }), "Crash in " + strings.Repeat("a", discordTitleLimit-len("Crash in ")-len(truncationMarker)) + truncationMarker, discordColorCrash},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := newWebhookReceiver(t)
+			am := newTestAlertManager(t)
+			alert := &Alert{ID: "alert-1", Type: "discord", Config: map[string]interface{}{"webhook_url": receiver.URL + "/discord"}}
+
+			if err := am.sendDiscord(alert, tt.event); err != nil {
+				t.Fatalf("sendDiscord: %v", err)
+			}
+			received := receiver.received()
+			if len(received) != 1 {
+				t.Fatalf("requests = %d, want 1", len(received))
+			}
+			payload := received[0].Payload
+			embeds, _ := payload["embeds"].([]interface{})
+			if payload["username"] != "Inceptor" || len(embeds) != 1 {
+				t.Fatalf("payload = %v, want one embed from Inceptor", payload)
+			}
+			embed := embeds[0].(map[string]interface{})
+			if embed["title"] != tt.wantTitle || embed["color"] != tt.wantColor {
+				t.Errorf("title, color = %q, %v; want %q, %v", embed["title"], embed["color"], tt.wantTitle, tt.wantColor)
+			}
+			if embed["description"] != "Bad state" || embed["timestamp"] != "2026-01-01T12:00:00Z" {
+				t.Errorf("description, timestamp = %v, %v", embed["description"], embed["timestamp"])
+			}
+
+			fields := make(map[string]interface{})
+			for _, f := range embed["fields"].([]interface{}) {
+				field := f.(map[string]interface{})
+				fields[field["name"].(string)] = field["value"]
+			}
+			// Discord rejects empty values
+			want := map[string]interface{}{"Error Type": "StateError", "Platform": PlatformAndroid, "App Version": "1.0.0", "Environment": "-", "Occurrences": "7"}
+			for name, value := range want {
+				if fields[name] != value {
+					t.Errorf("field %s = %v, want %v", name, fields[name], value)
+				}
+			}
+		})
+	}
+}
+
+func TestSendDiscordErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		noURL         bool
+		wantRetryable bool
+	}{
+		{"no webhook URL", 0, true, false},
+		{"rejected", http.StatusBadRequest, false, false},
+		{"rate limited", http.StatusTooManyRequests, false, true},
+		{"server error", http.StatusBadGateway, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := newWebhookReceiver(t)
+			receiver.status = tt.status
+			am := newTestAlertManager(t)
+			alert := &Alert{ID: "alert-1", Type: "discord", Config: map[string]interface{}{"webhook_url": receiver.URL}}
+			if tt.noURL {
+				alert.Config = map[string]interface{}{}
+			}
+
+			err := am.sendDiscord(alert, crashEvent("app-1", "group-1", 1, time.Now()))
+			if err == nil {
+				t.Fatal("sendDiscord succeeded, want an error")
+			}
+			var retry *retryableError
+			if isRetryable := errors.As(err, &retry); isRetryable != tt.wantRetryable {
+				t.Errorf("retryable = %v, want %v: %v", isRetryable, tt.wantRetryable, err)
+			}
+		})
+	}
+}
+
+func TestSendCoalescedDiscord(t *testing.T) {
+	receiver := newWebhookReceiver(t)
+	am := newTestAlertManager(t)
+	alert := &Alert{ID: "alert-1", Type: "discord", Config: map[string]interface{}{"webhook_url": receiver.URL}}
+	at := time.Now()
+	events := []AlertEvent{newGroupEvent("app-1", "group-1", at), newGroupEvent("app-1", "group-2", at)}
+
+	if err := am.sendCoalescedDiscord(alert, events, 5*time.Minute); err != nil {
+		t.Fatalf("sendCoalescedDiscord: %v", err)
+	}
+	received := receiver.received()
+	if len(received) != 1 {
+		t.Fatalf("requests = %d, want one for the batch", len(received))
+	}
+	embed := received[0].Payload["embeds"].([]interface{})[0].(map[string]interface{})
+	if want := coalescedSummary(events, 5*time.Minute) + " in app-1"; embed["title"] != want {
+		t.Errorf("title = %q, want %q", embed["title"], want)
+	}
+	if lines := strings.Split(embed["description"].(string), "\n"); len(lines) != 2 || !strings.HasPrefix(lines[0], "• ") {
+		t.Errorf("description = %q, want a line per event", embed["description"])
+	}
+}
@@ -0,0 +1,247 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ExportCheckpointSetting is the settings key holding the export cursor
+const ExportCheckpointSetting = "export_checkpoint"
+
+// ExportRepository defines the database operations needed for crash export
+type ExportRepository interface {
+	ListCrashesCreatedBetween(ctx context.Context, after time.Time, afterID string, before time.Time, limit int) ([]*Crash, error)
+	GetSetting(ctx context.Context, key string) (string, error)
+	SetSetting(ctx context.Context, key, value string) error
+}
+
+// ExportFileStore defines the file operations needed for crash export
+type ExportFileStore interface {
+	GetCrashLog(ctx context.Context, filePath string) (*Crash, error)
+}
+
+// ExportSink receives finished export files
+type ExportSink interface {
+	// WriteExport stores an export file, replacing any file with the same name
+	WriteExport(ctx context.Context, name string, data []byte) error
+}
+
+// ExportCursor is the position of the last exported crash. Crashes are
+// exported in (created_at, id) order, so the cursor identifies exactly
+// which crashes have been exported.
+type ExportCursor struct {
+	CreatedAt time.Time `json:"created_at"`
+	ID        string    `json:"id"`
+}
+
+// Exporter periodically writes crashes created since its last run to an
+// export sink as gzipped NDJSON, independently of retention.
+//
+// Each file holds one batch and is named after the cursor it starts from.
+// The cursor is saved in settings only after the file is written, so a run
+// interrupted in between rewrites the same file on restart instead of
+// exporting its crashes twice.
+type Exporter struct {
+	repo      ExportRepository
+	fileStore ExportFileStore
+	sink      ExportSink
+	interval  time.Duration
+	// delay holds back crashes this recent, giving asynchronously ingested
+	// crashes time to be stored before the cursor moves past them
+	delay     time.Duration
+	batchSize int
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+// NewExporter creates a new Exporter
+func NewExporter(repo ExportRepository, fileStore ExportFileStore, sink ExportSink, interval, delay time.Duration, batchSize int) *Exporter {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+
+	return &Exporter{
+		repo:      repo,
+		fileStore: fileStore,
+		sink:      sink,
+		interval:  interval,
+		delay:     delay,
+		batchSize: batchSize,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// Start begins the export worker
+func (e *Exporter) Start() {
+	e.wg.Add(1)
+	go e.worker()
+	log.Info().Dur("interval", e.interval).Msg("Crash exporter started")
+}
+
+// Stop gracefully stops the exporter, letting a running export finish its
+// current batch
+func (e *Exporter) Stop() {
+	e.cancel()
+	e.wg.Wait()
+	log.Info().Msg("Crash exporter stopped")
+}
+
+// worker runs the periodic export
+func (e *Exporter) worker() {
+	defer e.wg.Done()
+
+	// Run immediately on start to catch up after downtime
+	e.run()
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case <-ticker.C:
+			e.run()
+		}
+	}
+}
+
+// run exports and logs the outcome
+func (e *Exporter) run() {
+	startTime := time.Now()
+
+	exported, files, err := e.Export(e.ctx, startTime.Add(-e.delay))
+	if err != nil {
+		log.Error().Err(err).Int("exported", exported).Msg("Crash export failed")
+		return
+	}
+
+	log.Info().
+		Dur("duration", time.Since(startTime)).
+		Int("exported", exported).
+		Int("files", files).
+		Msg("Crash export completed")
+}
+
+// Export writes every crash after the saved cursor and created before
+// until, one file per batch, advancing the cursor after each file. It
+// returns the number of crashes and files written.
+func (e *Exporter) Export(ctx context.Context, until time.Time) (int, int, error) {
+	cursor, err := e.loadCursor(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	exported, files := 0, 0
+	for ctx.Err() == nil {
+		crashes, err := e.repo.ListCrashesCreatedBetween(ctx, cursor.CreatedAt, cursor.ID, until, e.batchSize)
+		if err != nil {
+			return exported, files, fmt.Errorf("failed to list crashes: %w", err)
+		}
+		if len(crashes) == 0 {
+			break
+		}
+
+		data, err := e.encodeBatch(ctx, crashes)
+		if err != nil {
+			return exported, files, err
+		}
+		if err := e.sink.WriteExport(ctx, exportFileName(cursor), data); err != nil {
+			return exported, files, fmt.Errorf("failed to write export: %w", err)
+		}
+
+		last := crashes[len(crashes)-1]
+		cursor = ExportCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+		if err := e.saveCursor(ctx, cursor); err != nil {
+			return exported, files, err
+		}
+
+		exported += len(crashes)
+		files++
+	}
+
+	return exported, files, nil
+}
+
+// encodeBatch renders crashes as gzipped NDJSON, using the full payload from
+// the file store where it can be read
+func (e *Exporter) encodeBatch(ctx context.Context, crashes []*Crash) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(gz)
+
+	for _, crash := range crashes {
+		record := crash
+		if crash.LogFilePath != "" {
+			full, err := e.fileStore.GetCrashLog(ctx, crash.LogFilePath)
+			switch {
+			case err != nil:
+				crash.FileStatus = FileStatusUnreadable
+			case full == nil:
+				crash.FileStatus = FileStatusMissing
+			default:
+				// Grouping and quarantine can change after the file is written
+				full.GroupID = crash.GroupID
+				full.QuarantineReason = crash.QuarantineReason
+				record = full
+			}
+		}
+		if err := enc.Encode(record); err != nil {
+			return nil, fmt.Errorf("failed to encode crash %s: %w", crash.ID, err)
+		}
+	}
+
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress export: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// loadCursor reads the saved cursor; without one, export starts from the
+// oldest stored crash
+func (e *Exporter) loadCursor(ctx context.Context) (ExportCursor, error) {
+	var cursor ExportCursor
+	raw, err := e.repo.GetSetting(ctx, ExportCheckpointSetting)
+	if err != nil {
+		return cursor, fmt.Errorf("failed to load export checkpoint: %w", err)
+	}
+	if raw == "" {
+		return cursor, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &cursor); err != nil {
+		return cursor, fmt.Errorf("invalid export checkpoint: %w", err)
+	}
+	return cursor, nil
+}
+
+func (e *Exporter) saveCursor(ctx context.Context, cursor ExportCursor) error {
+	raw, err := json.Marshal(cursor)
+	if err != nil {
+		return err
+	}
+	if err := e.repo.SetSetting(ctx, ExportCheckpointSetting, string(raw)); err != nil {
+		return fmt.Errorf("failed to save export checkpoint: %w", err)
+	}
+	return nil
+}
+
+// exportFileName names a batch after the cursor it starts from, so
+// re-running an interrupted batch replaces its file rather than adding one
+func exportFileName(cursor ExportCursor) string {
+	id := cursor.ID
+	if id == "" {
+		id = "start"
+	}
+	return fmt.Sprintf("crashes-%s-%s.ndjson.gz", cursor.CreatedAt.UTC().Format("20060102T150405.000000000Z"), id)
+}
@@ -0,0 +1,248 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+)
+
+// exportRepo is an in-memory ExportRepository
+type exportRepo struct {
+	crashes  []*Crash
+	settings map[string]string
+}
+
+func (r *exportRepo) ListCrashesCreatedBetween(ctx context.Context, after time.Time, afterID string, before time.Time, limit int) ([]*Crash, error) {
+	sort.Slice(r.crashes, func(i, j int) bool {
+		a, b := r.crashes[i], r.crashes[j]
+		return a.CreatedAt.Before(b.CreatedAt) || a.CreatedAt.Equal(b.CreatedAt) && a.ID < b.ID
+	})
+	var crashes []*Crash
+	for _, crash := range r.crashes {
+		afterCursor := crash.CreatedAt.After(after) || crash.CreatedAt.Equal(after) && crash.ID > afterID
+		if afterCursor && crash.CreatedAt.Before(before) && len(crashes) < limit {
+			copied := *crash
+			crashes = append(crashes, &copied)
+		}
+	}
+	return crashes, nil
+}
+
+func (r *exportRepo) GetSetting(ctx context.Context, key string) (string, error) {
+	return r.settings[key], nil
+}
+
+func (r *exportRepo) SetSetting(ctx context.Context, key, value string) error {
+	r.settings[key] = value
+	return nil
+}
+
+// exportFiles is an in-memory ExportFileStore of full crash payloads
+type exportFiles map[string]*Crash
+
+func (f exportFiles) GetCrashLog(ctx context.Context, filePath string) (*Crash, error) {
+	if filePath == "unreadable" {
+		return nil, errors.New("disk error")
+	}
+	crash, ok := f[filePath]
+	if !ok {
+		return nil, nil
+	}
+	copied := *crash
+	return &copied, nil
+}
+
+// exportSink is an ExportSink keeping files in memory. When failAfter is
+// set, writes fail once that many files have been written.
+type exportSink struct {
+	files     map[string][]byte
+	writes    []string
+	failAfter int
+}
+
+func (s *exportSink) WriteExport(ctx context.Context, name string, data []byte) error {
+	if s.failAfter > 0 && len(s.writes) >= s.failAfter {
+		return errors.New("sink unavailable")
+	}
+	s.files[name] = data
+	s.writes = append(s.writes, name)
+	return nil
+}
+
+// exported decodes every crash in the sink's files, in file name order
+func (s *exportSink) exported(t *testing.T) []*Crash {
+	t.Helper()
+
+	var names []string
+	for name := range s.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var crashes []*Crash
+	for _, name := range names {
+		gz, err := gzip.NewReader(bytes.NewReader(s.files[name]))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		scanner := bufio.NewScanner(gz)
+		for scanner.Scan() {
+			var crash Crash
+			if err := json.Unmarshal(scanner.Bytes(), &crash); err != nil {
+				t.Fatalf("%s: decode line: %v", name, err)
+			}
+			crashes = append(crashes, &crash)
+		}
+	}
+	return crashes
+}
+
+func exportedIDs(crashes []*Crash) []string {
+	var ids []string
+	for _, crash := range crashes {
+		ids = append(ids, crash.ID)
+	}
+	return ids
+}
+
+// newTestExporter returns an exporter of n crashes created a minute apart,
+// exported in batches of batchSize
+func newTestExporter(n, batchSize int) (*Exporter, *exportRepo, *exportSink, time.Time) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	repo := &exportRepo{settings: map[string]string{}}
+	for i := 0; i < n; i++ {
+		repo.crashes = append(repo.crashes, &Crash{ID: fmt.Sprintf("crash-%d", i), CreatedAt: start.Add(time.Duration(i) * time.Minute)})
+	}
+	sink := &exportSink{files: map[string][]byte{}}
+	return NewExporter(repo, exportFiles{}, sink, time.Hour, 0, batchSize), repo, sink, start
+}
+
+func TestExporterExport(t *testing.T) {
+	tests := []struct {
+		name      string
+		crashes   int
+		batchSize int
+		// until is minutes after the first crash
+		until     int
+		wantFiles int
+		wantIDs   []string
+	}{
+		{"nothing to export", 0, 2, 10, 0, nil},
+		{"batches", 5, 2, 10, 3, []string{"crash-0", "crash-1", "crash-2", "crash-3", "crash-4"}},
+		{"single batch", 3, 10, 10, 1, []string{"crash-0", "crash-1", "crash-2"}},
+		{"recent crashes held back", 5, 2, 3, 2, []string{"crash-0", "crash-1", "crash-2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter, _, sink, start := newTestExporter(tt.crashes, tt.batchSize)
+			exported, files, err := exporter.Export(context.Background(), start.Add(time.Duration(tt.until)*time.Minute))
+			if err != nil {
+				t.Fatalf("export: %v", err)
+			}
+			if exported != len(tt.wantIDs) || files != tt.wantFiles || len(sink.files) != tt.wantFiles {
+				t.Errorf("exported %d crashes in %d files (%d in sink), want %d in %d", exported, files, len(sink.files), len(tt.wantIDs), tt.wantFiles)
+			}
+			if got := exportedIDs(sink.exported(t)); !slices.Equal(got, tt.wantIDs) {
+				t.Errorf("exported = %v, want %v", got, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestExporterResumesFromCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	exporter, repo, sink, start := newTestExporter(3, 2)
+	until := start.Add(time.Hour)
+
+	if _, _, err := exporter.Export(ctx, until); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	// Nothing new: no files are written
+	if exported, files, err := exporter.Export(ctx, until); err != nil || exported != 0 || files != 0 {
+		t.Errorf("second export = %d crashes, %d files, %v; want nothing", exported, files, err)
+	}
+
+	// New crashes are picked up after the cursor, including one created at
+	// the same time as the last exported crash
+	repo.crashes = append(repo.crashes,
+		&Crash{ID: "crash-2a", CreatedAt: start.Add(2 * time.Minute)},
+		&Crash{ID: "crash-3", CreatedAt: start.Add(3 * time.Minute)},
+	)
+	if exported, _, err := exporter.Export(ctx, until); err != nil || exported != 2 {
+		t.Errorf("export after new crashes = %d, %v; want 2", exported, err)
+	}
+	want := []string{"crash-0", "crash-1", "crash-2", "crash-2a", "crash-3"}
+	if got := exportedIDs(sink.exported(t)); !slices.Equal(got, want) {
+		t.Errorf("exported = %v, want %v", got, want)
+	}
+}
+
+func TestExporterInterrupted(t *testing.T) {
+	ctx := context.Background()
+	exporter, _, sink, start := newTestExporter(4, 2)
+	until := start.Add(time.Hour)
+
+	// Fail the second file
+	sink.failAfter = 1
+	exported, files, err := exporter.Export(ctx, until)
+	if err == nil || exported != 2 || files != 1 {
+		t.Fatalf("export = %d crashes, %d files, %v; want an error after the first file", exported, files, err)
+	}
+
+	sink.failAfter = 0
+	if exported, _, err := exporter.Export(ctx, until); err != nil || exported != 2 {
+		t.Fatalf("resumed export = %d, %v; want the remaining 2", exported, err)
+	}
+	if got, want := exportedIDs(sink.exported(t)), []string{"crash-0", "crash-1", "crash-2", "crash-3"}; !slices.Equal(got, want) {
+		t.Errorf("exported = %v, want each crash once: %v", got, want)
+	}
+}
+
+func TestExporterFullPayloads(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	repo := &exportRepo{settings: map[string]string{}, crashes: []*Crash{
+		{ID: "stored", LogFilePath: "stored.json", GroupID: "group-new", CreatedAt: at},
+		{ID: "missing", LogFilePath: "missing.json", CreatedAt: at.Add(time.Second)},
+		{ID: "unreadable", LogFilePath: "unreadable", CreatedAt: at.Add(2 * time.Second)},
+		{ID: "no-file", CreatedAt: at.Add(3 * time.Second)},
+	}}
+	files := exportFiles{"stored.json": {ID: "stored", GroupID: "group-old", Breadcrumbs: []Breadcrumb{{Message: "tapped"}}, CreatedAt: at}}
+	sink := &exportSink{files: map[string][]byte{}}
+
+	exporter := NewExporter(repo, files, sink, time.Hour, 0, 10)
+	if _, _, err := exporter.Export(context.Background(), at.Add(time.Hour)); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	crashes := sink.exported(t)
+	if len(crashes) != 4 {
+		t.Fatalf("exported %d crashes, want 4", len(crashes))
+	}
+	tests := []struct {
+		crash           *Crash
+		wantFileStatus  string
+		wantBreadcrumbs int
+	}{
+		{crashes[0], "", 1},
+		{crashes[1], FileStatusMissing, 0},
+		{crashes[2], FileStatusUnreadable, 0},
+		{crashes[3], "", 0},
+	}
+	for _, tt := range tests {
+		if tt.crash.FileStatus != tt.wantFileStatus || len(tt.crash.Breadcrumbs) != tt.wantBreadcrumbs {
+			t.Errorf("%s: file status %q with %d breadcrumbs, want %q with %d", tt.crash.ID, tt.crash.FileStatus, len(tt.crash.Breadcrumbs), tt.wantFileStatus, tt.wantBreadcrumbs)
+		}
+	}
+	// The database's grouping wins over the stored payload's
+	if crashes[0].GroupID != "group-new" {
+		t.Errorf("group = %s, want group-new", crashes[0].GroupID)
+	}
+}
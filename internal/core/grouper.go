@@ -4,57 +4,610 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 )
 
+// FingerprintVersion identifies the fingerprinting algorithm. Bump it whenever
+// a change to GenerateFingerprint or the normalizers would regroup existing
+// crashes, so crashes fingerprinted under the old algorithm can be told apart.
+const FingerprintVersion = 1
+
+// Group title strategies
+const (
+	// TitleStrategyTypeMessage titles a group "ErrorType: first line of message"
+	TitleStrategyTypeMessage = "type_message"
+	// TitleStrategyTypeCulprit titles a group "ErrorType in Class.method"
+	TitleStrategyTypeCulprit = "type_culprit"
+	// TitleStrategyMessage titles a group with the first line of the message
+	TitleStrategyMessage = "message"
+)
+
+// Error type fallbacks derive an error type for crashes submitted without
+// one, so they don't all share a single group
+const (
+	// ErrorTypeFallbackNone leaves the error type empty. This is the default.
+	ErrorTypeFallbackNone = ""
+	// ErrorTypeFallbackTopFrame names the top in-app frame, e.g.
+	// "UnknownError at Class.method"
+	ErrorTypeFallbackTopFrame = "top_frame"
+	// ErrorTypeFallbackMessage uses the first word of the message, e.g.
+	// "TypeError" for "TypeError: x is undefined"
+	ErrorTypeFallbackMessage = "message"
+)
+
+// fallbackErrorTypePrefix starts error types derived from the top frame
+const fallbackErrorTypePrefix = "UnknownError at "
+
+// Frame position modes select which source positions a platform's stack
+// frames contribute to the fingerprint
+const (
+	// FramePositionNone ignores line and column, so unrelated edits to a
+	// file don't split groups. This is the default.
+	FramePositionNone = "none"
+	// FramePositionColumn keeps the column only, for minified web bundles
+	// where many functions share one line
+	FramePositionColumn = "column"
+	// FramePositionLine keeps the line only
+	FramePositionLine = "line"
+	// FramePositionLineColumn keeps both
+	FramePositionLineColumn = "line_column"
+)
+
+// Frame weighting schemes select how much each stack frame contributes to
+// the fingerprint
+const (
+	// FrameWeightingFlat hashes the first FrameLimit frames in full. This is
+	// the default.
+	FrameWeightingFlat = "flat"
+	// FrameWeightingWeighted lets the top in-app frame dominate: it and the
+	// next few frames are hashed in full, deeper frames by class or file
+	// only, and the rest not at all, so crashes reaching the same bug through
+	// different call paths group together
+	FrameWeightingWeighted = "weighted"
+)
+
+// FrameWeights configures the weighted frame scheme, counting frames from
+// the top in-app frame
+type FrameWeights struct {
+	// FullFrames are hashed in full, like every frame in the flat scheme
+	FullFrames int
+	// CoarseFrames follow the full frames and contribute only their class,
+	// or file when there's no class
+	CoarseFrames int
+}
+
+// AppleFrames configures normalization of Apple-format stack frames, whose
+// method is "<load address> <symbol> + <offset>" and whose file is the binary
+// image name. The load address changes with every launch and the offset with
+// every build, so without normalization such frames never group.
+type AppleFrames struct {
+	Enabled bool
+	// OffsetBucket groups unsymbolicated frames ("MyApp + 12345") by binary
+	// and offset / OffsetBucket, so small code shifts between builds still
+	// group. Zero groups them by binary alone. Symbolicated frames always
+	// group by symbol, ignoring the offset.
+	OffsetBucket uint64
+}
+
+// Bounds on GrouperConfig values
+const (
+	maxGrouperFrameLimit     = 50
+	maxIgnoreFramePatterns   = 20
+	maxIgnoreFramePatternLen = 256
+	maxRouteMetadataKeyLen   = 256
+)
+
+// GrouperConfig overrides the server's fingerprinting rules for one app.
+// Zero fields use the server defaults.
+type GrouperConfig struct {
+	// FrameLimit is how many stack frames are fingerprinted under flat
+	// frame weighting
+	FrameLimit int `json:"frame_limit,omitempty"`
+	// IncludeErrorMessage adds the first line of the error message to the
+	// fingerprint, so one error type with different messages groups
+	// separately
+	IncludeErrorMessage bool `json:"include_error_message,omitempty"`
+	// IgnoreFramePatterns are regular expressions. Frames whose
+	// "Class.method" or file name matches one are left out of the
+	// fingerprint and don't count toward the frame limit.
+	IgnoreFramePatterns []string `json:"ignore_frame_patterns,omitempty"`
+	// IncludeFileNames, when false, leaves file names out of frames that
+	// have a class or method, so moving code between files doesn't regroup
+	// its crashes. Nil means true.
+	IncludeFileNames *bool `json:"include_file_names,omitempty"`
+	// RouteMetadataKey names a metadata key holding the page URL. Its route,
+	// as given by NormalizeRoute, is added to the fingerprint, so a web
+	// error groups per route rather than per exact URL.
+	RouteMetadataKey string `json:"route_metadata_key,omitempty"`
+	// IncludeLastBreadcrumb adds the category and message of the crash's
+	// last breadcrumb, as given by normalizeBreadcrumb, to the fingerprint,
+	// so one stack reached through different user actions groups
+	// separately. It splits groups readily, so it is off by default.
+	IncludeLastBreadcrumb bool `json:"include_last_breadcrumb,omitempty"`
+}
+
+// Validate checks that the config is usable
+func (c GrouperConfig) Validate() error {
+	if c.FrameLimit < 0 || c.FrameLimit > maxGrouperFrameLimit {
+		return fmt.Errorf("frame_limit must be between 0 and %d", maxGrouperFrameLimit)
+	}
+	if len(c.IgnoreFramePatterns) > maxIgnoreFramePatterns {
+		return fmt.Errorf("at most %d ignore_frame_patterns are allowed", maxIgnoreFramePatterns)
+	}
+	for i, pattern := range c.IgnoreFramePatterns {
+		if pattern == "" || len(pattern) > maxIgnoreFramePatternLen {
+			return fmt.Errorf("ignore_frame_patterns[%d] must be 1 to %d characters", i, maxIgnoreFramePatternLen)
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("ignore_frame_patterns[%d]: %w", i, err)
+		}
+	}
+	if len(c.RouteMetadataKey) > maxRouteMetadataKeyLen {
+		return fmt.Errorf("route_metadata_key must be at most %d characters", maxRouteMetadataKeyLen)
+	}
+	return nil
+}
+
+// includeFileNames reports whether frames keep their file names
+func (c GrouperConfig) includeFileNames() bool {
+	return c.IncludeFileNames == nil || *c.IncludeFileNames
+}
+
+// appleFramePattern matches an Apple-format frame method: an optional load
+// address, the symbol (or the binary name or an address when
+// unsymbolicated) and the offset into it
+var appleFramePattern = regexp.MustCompile(`^(?:0x[0-9a-fA-F]+\s+)?(.+?)\s+\+\s+(0x[0-9a-fA-F]+|\d+)$`)
+
+// javaFramePattern matches a Java stack trace line: "at", an optional module
+// or class loader prefix ("java.base/", "app//"), the class and method, and
+// the location in parentheses
+var javaFramePattern = regexp.MustCompile(`^at\s+(?:[^\s(]*/)?([^\s/()]+)\.([^\s.()]+)\(([^)]*)\)`)
+
+// pythonFramePattern matches the location line of a Python traceback frame
+var pythonFramePattern = regexp.MustCompile(`^File "([^"]*)", line (\d+), in (.+)$`)
+
+// maxTitleLength bounds generated group titles
+const maxTitleLength = 200
+
+// maxMetadataValueLength bounds metadata values added to fingerprints
+const maxMetadataValueLength = 200
+
+// routeIDSegmentPattern matches URL path segments that identify a record
+// rather than a page: numbers and UUIDs
+var routeIDSegmentPattern = regexp.MustCompile(`^(?:\d+|[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12})$`)
+
 // Grouper handles crash fingerprinting and grouping logic
 type Grouper struct {
 	// Number of stack frames to use for fingerprinting
 	FrameLimit int
+
+	// TitleStrategy selects how new group titles are derived
+	TitleStrategy string
+	// TitleTemplates overrides TitleStrategy for specific error types. Templates
+	// may use {type}, {message}, {first_line} and {culprit} placeholders.
+	TitleTemplates map[string]string
+
+	// Limits truncates fields before they are fingerprinted, so a crash
+	// groups the same way whether or not it was truncated for storage
+	Limits FieldLimits
+
+	// FramePositions maps a platform to a FramePosition mode. Platforms
+	// not listed use FramePositionNone.
+	FramePositions map[string]string
+
+	// FrameWeighting is FrameWeightingFlat or FrameWeightingWeighted
+	FrameWeighting string
+	// Weights configures FrameWeightingWeighted
+	Weights FrameWeights
+
+	// AppleFrames configures normalization of Apple-format frames
+	AppleFrames AppleFrames
+
+	// ErrorTypeFallback is an ErrorTypeFallback mode used by
+	// FallbackErrorType
+	ErrorTypeFallback string
+
+	// ignorePatterns caches compiled GrouperConfig.IgnoreFramePatterns by
+	// source
+	ignorePatterns sync.Map
 }
 
 // NewGrouper creates a new Grouper with default settings
 func NewGrouper() *Grouper {
 	return &Grouper{
-		FrameLimit: 5,
+		FrameLimit:     5,
+		TitleStrategy:  TitleStrategyTypeMessage,
+		FrameWeighting: FrameWeightingFlat,
+		Weights:        FrameWeights{FullFrames: 1},
+	}
+}
+
+// breadcrumbVariablePattern matches the parts of a breadcrumb message that
+// vary between occurrences of the same action: UUIDs, hex and decimal numbers
+var breadcrumbVariablePattern = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}|0x[0-9a-fA-F]+|\d+`)
+
+// GenerateTitle derives a human-readable title for the group a crash creates
+func (g *Grouper) GenerateTitle(crash *Crash) string {
+	firstLine := strings.TrimSpace(strings.SplitN(crash.ErrorMessage, "\n", 2)[0])
+	culprit := Culprit(crash)
+
+	var title string
+	if tmpl, ok := g.TitleTemplates[crash.ErrorType]; ok && tmpl != "" {
+		title = strings.NewReplacer(
+			"{type}", crash.ErrorType,
+			"{message}", crash.ErrorMessage,
+			"{first_line}", firstLine,
+			"{culprit}", culprit,
+		).Replace(tmpl)
+	} else {
+		switch g.TitleStrategy {
+		case TitleStrategyTypeCulprit:
+			title = crash.ErrorType
+			if culprit != "" {
+				title += " in " + culprit
+			}
+		case TitleStrategyMessage:
+			title = firstLine
+		default:
+			title = crash.ErrorType
+			if firstLine != "" {
+				title += ": " + firstLine
+			}
+		}
+	}
+
+	// Fall back to the error type when the strategy produced nothing useful
+	title = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(title), ":"))
+	if title == "" {
+		title = crash.ErrorType
+	}
+
+	if len(title) > maxTitleLength {
+		title = title[:maxTitleLength] + "..."
+	}
+	return title
+}
+
+// FallbackErrorType derives an error type for a crash submitted without
+// one, using the source ErrorTypeFallback selects and then the other. It
+// returns "" when the fallback is disabled or neither source has anything
+// usable, and is bounded by Limits.ErrorType.
+func (g *Grouper) FallbackErrorType(crash *Crash) string {
+	var fromFrame string
+	if culprit := Culprit(crash); culprit != "" {
+		fromFrame = fallbackErrorTypePrefix + culprit
+	}
+	fromMessage := messageToken(crash.ErrorMessage)
+
+	var first, second string
+	switch g.ErrorTypeFallback {
+	case ErrorTypeFallbackTopFrame:
+		first, second = fromFrame, fromMessage
+	case ErrorTypeFallbackMessage:
+		first, second = fromMessage, fromFrame
+	default:
+		return ""
+	}
+	if first == "" {
+		first = second
+	}
+	return TruncateField(first, g.Limits.ErrorType)
+}
+
+// messageToken returns the first word of an error message's first line,
+// without trailing punctuation: "TypeError" for "TypeError: x is undefined"
+func messageToken(message string) string {
+	words := strings.Fields(strings.SplitN(message, "\n", 2)[0])
+	if len(words) == 0 {
+		return ""
+	}
+	token := strings.TrimRight(words[0], ":;,.!?")
+	if !printable(token) {
+		return ""
 	}
+	return token
+}
+
+// Culprit returns "Class.method" for the crash's most relevant frame, or
+// its file name if the frame has no method; empty without frames
+func Culprit(crash *Crash) string {
+	frame := GetTopFrame(crash)
+	if frame == nil {
+		return ""
+	}
+	name := frame.MethodName
+	if frame.ClassName != "" {
+		name = frame.ClassName + "." + name
+	}
+	if name == "" {
+		name = normalizeFileName(frame.FileName)
+	}
+	return name
+}
+
+// Reasons a stack frame is left out of the fingerprint
+const (
+	FrameSkipNative     = "native"
+	FrameSkipFrameLimit = "frame_limit"
+	// FrameSkipIgnored marks frames matching one of the app's ignore frame
+	// patterns
+	FrameSkipIgnored = "ignored"
+	// FrameSkipAboveTop marks framework frames above the top in-app frame
+	// under weighted frame weighting
+	FrameSkipAboveTop = "above_top_frame"
+)
+
+// FingerprintFrame describes how one stack frame contributed to a fingerprint
+type FingerprintFrame struct {
+	Index      int        `json:"index"`
+	Frame      StackFrame `json:"frame"`
+	Normalized string     `json:"normalized,omitempty"`
+	// Skipped is the reason the frame was left out, empty if it was used
+	Skipped string `json:"skipped,omitempty"`
+	// Framework marks frames from known frameworks. They are still
+	// fingerprinted but never chosen as the culprit frame.
+	Framework bool `json:"framework"`
+	// Coarse marks frames that contributed only their class or file under
+	// weighted frame weighting
+	Coarse bool `json:"coarse,omitempty"`
+}
+
+// FingerprintExplanation shows the inputs that produced a fingerprint
+type FingerprintExplanation struct {
+	Fingerprint        string `json:"fingerprint"`
+	FingerprintVersion int    `json:"fingerprint_version"`
+	ErrorType          string `json:"error_type"`
+	FrameLimit         int    `json:"frame_limit"`
+	FramePositions     string `json:"frame_positions"`
+	FrameWeighting     string `json:"frame_weighting"`
+	// ErrorMessage is the message line hashed when the app includes error
+	// messages
+	ErrorMessage string `json:"error_message,omitempty"`
+	// AppleFrames is set when Apple-format frames were normalized
+	AppleFrames bool `json:"apple_frames,omitempty"`
+	// IncludeFrameworkFrames is set when native frames were fingerprinted
+	// rather than skipped
+	IncludeFrameworkFrames bool `json:"include_framework_frames"`
+	// IncludeFileNames is unset when the app leaves file names out of frames
+	IncludeFileNames bool               `json:"include_file_names"`
+	Frames           []FingerprintFrame `json:"frames"`
+	// Metadata holds the app's fingerprint metadata keys present on the
+	// crash and the values that were hashed
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Route is the normalized route hashed when the app groups by route
+	Route string `json:"route,omitempty"`
+	// Breadcrumb is the normalized last breadcrumb hashed when the app
+	// includes it
+	Breadcrumb string `json:"breadcrumb,omitempty"`
 }
 
 // GenerateFingerprint creates a unique fingerprint for a crash
-// This is used to group similar crashes together
-func (g *Grouper) GenerateFingerprint(crash *Crash) string {
+// This is used to group similar crashes together. app may be nil, in which
+// case the default fingerprinting options apply.
+func (g *Grouper) GenerateFingerprint(crash *Crash, app *App) string {
+	return g.ExplainFingerprint(crash, app).Fingerprint
+}
+
+// ExplainFingerprint generates a crash's fingerprint along with the
+// normalized form of each stack frame and why any frames were skipped
+func (g *Grouper) ExplainFingerprint(crash *Crash, app *App) *FingerprintExplanation {
 	h := sha256.New()
 
 	// Include error type
-	h.Write([]byte(crash.ErrorType))
+	errorType := TruncateField(crash.ErrorType, g.Limits.ErrorType)
+	h.Write([]byte(errorType))
 	h.Write([]byte("|"))
 
-	// Include normalized stack frames
-	frameCount := g.FrameLimit
-	if len(crash.StackTrace) < frameCount {
-		frameCount = len(crash.StackTrace)
+	var config GrouperConfig
+	if app != nil {
+		config = app.FingerprintConfig
+	}
+
+	positions := g.framePositions(crash.Platform)
+	includeFramework := app != nil && app.IncludeFrameworkFrames
+	weighted := g.FrameWeighting == FrameWeightingWeighted
+	frameLimit := g.FrameLimit
+	if config.FrameLimit > 0 {
+		frameLimit = config.FrameLimit
+	}
+	weighting := FrameWeightingFlat
+	if weighted {
+		frameLimit = g.Weights.FullFrames + g.Weights.CoarseFrames
+		weighting = FrameWeightingWeighted
+	}
+	explanation := &FingerprintExplanation{
+		FingerprintVersion:     FingerprintVersion,
+		ErrorType:              errorType,
+		FrameLimit:             frameLimit,
+		FramePositions:         positions,
+		FrameWeighting:         weighting,
+		AppleFrames:            g.AppleFrames.Enabled,
+		IncludeFrameworkFrames: includeFramework,
+		IncludeFileNames:       config.includeFileNames(),
+		Frames:                 make([]FingerprintFrame, 0, len(crash.StackTrace)),
+	}
+
+	// Include the message's first line when the app groups by message
+	if config.IncludeErrorMessage {
+		message := TruncateField(crash.ErrorMessage, g.Limits.ErrorMessage)
+		explanation.ErrorMessage = strings.TrimSpace(strings.SplitN(message, "\n", 2)[0])
+		h.Write([]byte("msg:" + explanation.ErrorMessage))
+		h.Write([]byte("|"))
+	}
+	ignore := g.compileIgnorePatterns(config.IgnoreFramePatterns)
+
+	// Weighted depth counts from the top in-app frame
+	top := 0
+	if weighted {
+		top = topFrameIndex(crash, includeFramework)
 	}
 
-	for i := 0; i < frameCount; i++ {
+	// Include normalized stack frames. Ignored frames don't count toward
+	// the flat frame limit.
+	depth, ignored := 0, 0
+	for i := range crash.StackTrace {
 		frame := crash.StackTrace[i]
-		// Skip native/system frames for more consistent grouping
-		if frame.Native {
-			continue
+		entry := FingerprintFrame{
+			Index:     i,
+			Frame:     frame,
+			Framework: isFrameworkFrame(&frame),
+		}
+
+		switch {
+		case i < top:
+			entry.Skipped = FrameSkipAboveTop
+		case ignoreFrame(&frame, ignore):
+			entry.Skipped = FrameSkipIgnored
+			ignored++
+		case !weighted && i-ignored >= frameLimit:
+			entry.Skipped = FrameSkipFrameLimit
+		case frame.Native && !includeFramework:
+			// Skip native/system frames for more consistent grouping, unless
+			// the app's crashes really happen inside the framework
+			entry.Skipped = FrameSkipNative
+		case weighted && depth >= frameLimit:
+			// Weighted depth only counts hashed frames
+			entry.Skipped = FrameSkipFrameLimit
+		default:
+			truncated := g.Limits.TruncateFrame(frame)
+			if g.AppleFrames.Enabled {
+				truncated = g.normalizeAppleFrame(truncated)
+			}
+			if weighted && depth >= g.Weights.FullFrames {
+				entry.Normalized = coarseFrame(truncated)
+				entry.Coarse = true
+			} else {
+				entry.Normalized = g.normalizeFrame(truncated, positions, explanation.IncludeFileNames)
+			}
+			h.Write([]byte(entry.Normalized))
+			h.Write([]byte("|"))
+			depth++
+		}
+
+		explanation.Frames = append(explanation.Frames, entry)
+	}
+
+	// Include configured metadata values. Keys missing from the crash are
+	// skipped, so crashes without them fingerprint as before.
+	if app != nil {
+		for _, key := range app.FingerprintMetadataKeys {
+			value, ok := crash.Metadata[key]
+			if !ok || value == nil {
+				continue
+			}
+			formatted := TruncateField(fmt.Sprint(value), maxMetadataValueLength)
+			h.Write([]byte("meta:" + key + "=" + formatted))
+			h.Write([]byte("|"))
+			if explanation.Metadata == nil {
+				explanation.Metadata = make(map[string]string)
+			}
+			explanation.Metadata[key] = formatted
 		}
+	}
 
-		normalized := g.normalizeFrame(frame)
-		h.Write([]byte(normalized))
+	// Include the page route when the app groups by route
+	if config.RouteMetadataKey != "" {
+		if value, ok := crash.Metadata[config.RouteMetadataKey].(string); ok && value != "" {
+			explanation.Route = TruncateField(NormalizeRoute(value), maxMetadataValueLength)
+			h.Write([]byte("route:" + explanation.Route))
+			h.Write([]byte("|"))
+		}
+	}
+
+	// Include the last user action when the app groups by it. Crashes
+	// without breadcrumbs fingerprint as if it weren't set.
+	if config.IncludeLastBreadcrumb && len(crash.Breadcrumbs) > 0 {
+		explanation.Breadcrumb = normalizeBreadcrumb(crash.Breadcrumbs[len(crash.Breadcrumbs)-1])
+		h.Write([]byte("crumb:" + explanation.Breadcrumb))
 		h.Write([]byte("|"))
 	}
 
-	// Return first 16 characters of hex-encoded hash
+	// Use first 16 characters of hex-encoded hash
+	explanation.Fingerprint = hex.EncodeToString(h.Sum(nil))[:16]
+	return explanation
+}
+
+// GenerateCoarseFingerprint creates a fingerprint from the error type alone.
+// It is used as a fallback while fingerprint churn is suspected, trading
+// grouping precision for a bounded number of groups.
+func (g *Grouper) GenerateCoarseFingerprint(crash *Crash) string {
+	h := sha256.New()
+	h.Write([]byte("coarse|"))
+	h.Write([]byte(TruncateField(crash.ErrorType, g.Limits.ErrorType)))
 	return hex.EncodeToString(h.Sum(nil))[:16]
 }
 
+// SplitFingerprint creates the fingerprint of a group split off another.
+// The crash's own fingerprint is salted with the split key stored on the new
+// group, so the split group is reproducible but never matched by ingestion.
+func (g *Grouper) SplitFingerprint(crash *Crash, app *App, splitKey string) string {
+	h := sha256.New()
+	h.Write([]byte(g.GenerateFingerprint(crash, app)))
+	h.Write([]byte("|split:"))
+	h.Write([]byte(splitKey))
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// compileIgnorePatterns returns the compiled form of an app's ignore frame
+// patterns. Patterns that don't compile are skipped; they are validated when
+// the app is saved.
+func (g *Grouper) compileIgnorePatterns(patterns []string) []*regexp.Regexp {
+	if len(patterns) == 0 {
+		return nil
+	}
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		if re, ok := g.ignorePatterns.Load(pattern); ok {
+			compiled = append(compiled, re.(*regexp.Regexp))
+			continue
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			continue
+		}
+		g.ignorePatterns.Store(pattern, re)
+		compiled = append(compiled, re)
+	}
+	return compiled
+}
+
+// ignoreFrame reports whether a frame's "Class.method" or file name matches
+// any of the patterns
+func ignoreFrame(frame *StackFrame, patterns []*regexp.Regexp) bool {
+	if len(patterns) == 0 {
+		return false
+	}
+	name := frame.MethodName
+	if frame.ClassName != "" {
+		name = frame.ClassName + "." + name
+	}
+	for _, re := range patterns {
+		if re.MatchString(name) || (frame.FileName != "" && re.MatchString(frame.FileName)) {
+			return true
+		}
+	}
+	return false
+}
+
+// framePositions returns the frame position mode for a platform
+func (g *Grouper) framePositions(platform string) string {
+	switch mode := g.FramePositions[strings.ToLower(platform)]; mode {
+	case FramePositionColumn, FramePositionLine, FramePositionLineColumn:
+		return mode
+	default:
+		return FramePositionNone
+	}
+}
+
 // normalizeFrame normalizes a stack frame for consistent fingerprinting
-// Removes variable parts like line numbers, memory addresses, and closure IDs
-func (g *Grouper) normalizeFrame(frame StackFrame) string {
+// Removes variable parts like memory addresses and closure IDs, and line and
+// column numbers unless the positions mode keeps them. Without fileNames,
+// the file is dropped from frames that have a class or method.
+func (g *Grouper) normalizeFrame(frame StackFrame, positions string, fileNames bool) string {
 	var parts []string
 
 	// Include class name if present
@@ -68,13 +621,104 @@ func (g *Grouper) normalizeFrame(frame StackFrame) string {
 	}
 
 	// Include file name (without path and extension variations)
-	if frame.FileName != "" {
+	if frame.FileName != "" && (fileNames || len(parts) == 0) {
 		parts = append(parts, normalizeFileName(frame.FileName))
 	}
 
+	// Include source positions the platform's mode keeps
+	if (positions == FramePositionLine || positions == FramePositionLineColumn) && frame.LineNumber > 0 {
+		parts = append(parts, fmt.Sprintf("L%d", frame.LineNumber))
+	}
+	if (positions == FramePositionColumn || positions == FramePositionLineColumn) && frame.ColumnNumber > 0 {
+		parts = append(parts, fmt.Sprintf("C%d", frame.ColumnNumber))
+	}
+
 	return strings.Join(parts, ":")
 }
 
+// normalizeAppleFrame rewrites an Apple-format frame so it fingerprints the
+// same across launches and builds: the load address is dropped, and the
+// method becomes the symbol or, for unsymbolicated frames, the bucketed
+// offset into the binary. Other frames are returned unchanged.
+func (g *Grouper) normalizeAppleFrame(frame StackFrame) StackFrame {
+	symbol, offsetText, unsymbolicated, ok := parseAppleFrame(frame)
+	if !ok {
+		return frame
+	}
+	if !unsymbolicated {
+		frame.MethodName = symbol
+		return frame
+	}
+
+	frame.MethodName = ""
+	if g.AppleFrames.OffsetBucket > 0 {
+		offset, err := strconv.ParseUint(offsetText, 0, 64)
+		if err != nil {
+			return frame
+		}
+		frame.MethodName = fmt.Sprintf("+%d", offset/g.AppleFrames.OffsetBucket)
+	}
+	return frame
+}
+
+// parseAppleFrame splits an Apple-format frame method into its symbol and
+// offset, and reports whether the symbol is just the binary or an address
+func parseAppleFrame(frame StackFrame) (symbol, offset string, unsymbolicated, ok bool) {
+	match := appleFramePattern.FindStringSubmatch(strings.TrimSpace(frame.MethodName))
+	if match == nil {
+		return "", "", false, false
+	}
+	symbol, offset = match[1], match[2]
+	binary := normalizeFileName(frame.FileName)
+	unsymbolicated = symbol == binary || symbol == frame.ClassName || strings.HasPrefix(symbol, "0x")
+	return symbol, offset, unsymbolicated, true
+}
+
+// AppleFrameOffset returns the offset into its binary image of an
+// unsymbolicated Apple-format frame, such as "0x1a2b MyApp + 1234" with
+// file name "MyApp". ok is false for symbolicated and other frames.
+func AppleFrameOffset(frame StackFrame) (offset uint64, ok bool) {
+	_, offsetText, unsymbolicated, ok := parseAppleFrame(frame)
+	if !ok || !unsymbolicated {
+		return 0, false
+	}
+	offset, err := strconv.ParseUint(offsetText, 0, 64)
+	if err != nil {
+		return 0, false
+	}
+	return offset, true
+}
+
+// coarseFrame reduces a stack frame to its class, or file when there's no
+// class, for frames that only contribute weakly to the fingerprint
+func coarseFrame(frame StackFrame) string {
+	if frame.ClassName != "" {
+		return normalizeClassName(frame.ClassName)
+	}
+	if frame.FileName != "" {
+		return normalizeFileName(frame.FileName)
+	}
+	return normalizeMethodName(frame.MethodName)
+}
+
+// topFrameIndex returns the index of the crash's top in-app frame: the first
+// frame that is neither native nor from a known framework. Native frames
+// count as in-app when the app includes them. Without such a frame it
+// returns 0.
+func topFrameIndex(crash *Crash, includeFramework bool) int {
+	for i := range crash.StackTrace {
+		frame := &crash.StackTrace[i]
+		if frame.Native && !includeFramework {
+			continue
+		}
+		if isFrameworkFrame(frame) {
+			continue
+		}
+		return i
+	}
+	return 0
+}
+
 // normalizeClassName normalizes a class name
 func normalizeClassName(className string) string {
 	// Remove generic type parameters
@@ -125,9 +769,45 @@ func normalizeFileName(fileName string) string {
 	return fileName
 }
 
+// NormalizeRoute reduces a page URL, or a path, to its route: the path with
+// numeric and UUID segments replaced by ":id" and without the query or a
+// trailing slash, so "https://shop.example/orders/123?tab=items" becomes
+// "/orders/:id". Single-page apps routing in the fragment ("/#/orders/123")
+// use the fragment's path.
+func NormalizeRoute(rawURL string) string {
+	path := strings.TrimSpace(rawURL)
+	if u, err := url.Parse(path); err == nil {
+		path = u.Path
+		if (path == "" || path == "/") && strings.HasPrefix(u.Fragment, "/") {
+			path, _, _ = strings.Cut(u.Fragment, "?")
+		}
+	} else {
+		path, _, _ = strings.Cut(path, "?")
+		path, _, _ = strings.Cut(path, "#")
+	}
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range segments {
+		if routeIDSegmentPattern.MatchString(segment) {
+			segments[i] = ":id"
+		}
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// normalizeBreadcrumb returns "category:message" for a breadcrumb, with the
+// category lowercased and the message cut to its first line and stripped of
+// IDs and numbers, so repeats of the same action fingerprint the same
+func normalizeBreadcrumb(breadcrumb Breadcrumb) string {
+	category := strings.ToLower(strings.TrimSpace(breadcrumb.Category))
+	message := strings.TrimSpace(strings.SplitN(breadcrumb.Message, "\n", 2)[0])
+	message = breadcrumbVariablePattern.ReplaceAllString(message, "#")
+	return TruncateField(category+":"+message, maxMetadataValueLength)
+}
+
 // IsSimilar checks if two crashes are similar enough to be in the same group
 func (g *Grouper) IsSimilar(crash1, crash2 *Crash) bool {
-	return g.GenerateFingerprint(crash1) == g.GenerateFingerprint(crash2)
+	return g.GenerateFingerprint(crash1, nil) == g.GenerateFingerprint(crash2, nil)
 }
 
 // ExtractErrorSummary creates a short summary of the error
@@ -256,3 +936,121 @@ func parseFlutterFrame(line string) *StackFrame {
 
 	return frame
 }
+
+// ParseJavaStackTrace parses a Java/Android stack trace string, as printed by
+// Throwable.printStackTrace(), into StackFrames. Frames of the thrown
+// exception come first, followed by those of each "Caused by:" exception.
+// "... N more" lines are skipped since they stand for frames of the
+// enclosing trace, and so are suppressed exceptions.
+func ParseJavaStackTrace(stackTrace string) []StackFrame {
+	var frames []StackFrame
+
+	// Indentation of the suppressed exception being skipped, or -1
+	suppressed := -1
+	for _, line := range strings.Split(stackTrace, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		if suppressed >= 0 {
+			if indent > suppressed {
+				continue
+			}
+			suppressed = -1
+		}
+		if strings.HasPrefix(trimmed, "Suppressed:") {
+			suppressed = indent
+			continue
+		}
+
+		frame := parseJavaFrame(trimmed)
+		if frame != nil {
+			frames = append(frames, *frame)
+		}
+	}
+
+	return frames
+}
+
+// parseJavaFrame parses a single "at" line of a Java stack trace. Exception
+// headers, "Caused by:" and "... N more" lines return nil.
+func parseJavaFrame(line string) *StackFrame {
+	// Java format: at com.example.Foo.bar(Foo.java:42)
+	// Or: at com.example.Foo.bar(Native Method), (Unknown Source:4)
+	match := javaFramePattern.FindStringSubmatch(line)
+	if match == nil {
+		return nil
+	}
+
+	frame := &StackFrame{
+		ClassName:  match[1],
+		MethodName: match[2],
+	}
+
+	location := match[3]
+	if location == "Native Method" {
+		frame.Native = true
+		return frame
+	}
+	file, lineNumber, _ := strings.Cut(location, ":")
+	if file != "Unknown Source" {
+		frame.FileName = file
+	}
+	frame.LineNumber, _ = strconv.Atoi(lineNumber)
+
+	return frame
+}
+
+// ParsePythonStackTrace parses a Python traceback string into StackFrames.
+// Python prints the most recent call last, so the frames are reversed to
+// put the innermost call first, as in other platforms' traces. For chained
+// exceptions ("During handling of the above exception..." or "The above
+// exception was the direct cause..."), Python prints the exception that was
+// raised last, so its frames come first, followed by those of the exceptions
+// it was handling.
+func ParsePythonStackTrace(traceback string) []StackFrame {
+	var frames []StackFrame
+
+	lines := strings.Split(traceback, "\n")
+	for _, line := range lines {
+		// Exception groups indent their sub-tracebacks with "|"
+		line = strings.TrimSpace(strings.TrimLeft(line, " \t|"))
+		if line == "" {
+			continue
+		}
+
+		// Source lines, carets and "Traceback"/exception lines don't match
+		frame := parsePythonFrame(line)
+		if frame != nil {
+			frames = append(frames, *frame)
+		}
+	}
+
+	for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+		frames[i], frames[j] = frames[j], frames[i]
+	}
+	return frames
+}
+
+// parsePythonFrame parses the location line of a single Python traceback
+// frame
+func parsePythonFrame(line string) *StackFrame {
+	// Python format: File "/app/handlers.py", line 42, in handle
+	match := pythonFramePattern.FindStringSubmatch(line)
+	if match == nil {
+		return nil
+	}
+
+	frame := &StackFrame{
+		FileName:   match[1],
+		MethodName: match[3],
+	}
+	frame.LineNumber, _ = strconv.Atoi(match[2])
+
+	// Frozen and dynamically compiled code, e.g. "<frozen importlib._bootstrap>"
+	frame.Native = strings.HasPrefix(frame.FileName, "<")
+
+	return frame
+}
@@ -0,0 +1,854 @@
+package core
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// testFrames is an in-app stack trace
+var testFrames = []StackFrame{
+	{FileName: "lib/checkout.dart", LineNumber: 12, MethodName: "submit", ClassName: "CheckoutPage"},
+	{FileName: "lib/main.dart", LineNumber: 3, MethodName: "main"},
+}
+
+func TestGenerateTitle(t *testing.T) {
+	tests := []struct {
+		name      string
+		strategy  string
+		templates map[string]string
+		crash     Crash
+		want      string
+	}{
+		{
+			name:     "type and message",
+			strategy: TitleStrategyTypeMessage,
+			crash:    Crash{ErrorType: "StateError", ErrorMessage: "Bad state\nmore detail", StackTrace: testFrames},
+			want:     "StateError: Bad state",
+		},
+		{
+			name:     "type and message without message",
+			strategy: TitleStrategyTypeMessage,
+			crash:    Crash{ErrorType: "AssertionError", ErrorMessage: "", StackTrace: testFrames},
+			want:     "AssertionError",
+		},
+		{
+			name:     "type and culprit",
+			strategy: TitleStrategyTypeCulprit,
+			crash:    Crash{ErrorType: "AssertionError", ErrorMessage: "", StackTrace: testFrames},
+			want:     "AssertionError in CheckoutPage.submit",
+		},
+		{
+			name:     "type and culprit without frames",
+			strategy: TitleStrategyTypeCulprit,
+			crash:    Crash{ErrorType: "AssertionError"},
+			want:     "AssertionError",
+		},
+		{
+			name:     "message",
+			strategy: TitleStrategyMessage,
+			crash:    Crash{ErrorType: "Exception", ErrorMessage: "  Payment declined \nstack", StackTrace: testFrames},
+			want:     "Payment declined",
+		},
+		{
+			name:     "message falls back to type",
+			strategy: TitleStrategyMessage,
+			crash:    Crash{ErrorType: "Exception", ErrorMessage: "", StackTrace: testFrames},
+			want:     "Exception",
+		},
+		{
+			name:      "template for error type",
+			strategy:  TitleStrategyTypeMessage,
+			templates: map[string]string{"HttpException": "HTTP failure at {culprit}: {first_line}"},
+			crash:     Crash{ErrorType: "HttpException", ErrorMessage: "503\nbody", StackTrace: testFrames},
+			want:      "HTTP failure at CheckoutPage.submit: 503",
+		},
+		{
+			name:      "template for another type unused",
+			strategy:  TitleStrategyMessage,
+			templates: map[string]string{"HttpException": "{type}"},
+			crash:     Crash{ErrorType: "StateError", ErrorMessage: "Bad state", StackTrace: testFrames},
+			want:      "Bad state",
+		},
+		{
+			name:     "long titles truncated",
+			strategy: TitleStrategyMessage,
+			crash:    Crash{ErrorType: "E", ErrorMessage: strings.Repeat("x", 300)},
+			want:     strings.Repeat("x", maxTitleLength) + "...",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGrouper()
+			g.TitleStrategy = tt.strategy
+			g.TitleTemplates = tt.templates
+			if got := g.GenerateTitle(&tt.crash); got != tt.want {
+				t.Errorf("GenerateTitle() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFramePositions(t *testing.T) {
+	positions := map[string]string{
+		PlatformWeb:     FramePositionColumn,
+		PlatformAndroid: FramePositionLine,
+		PlatformDesktop: FramePositionLineColumn,
+	}
+	// frame returns a crash thrown from a minified bundle at line:column
+	frame := func(platform string, line, column int) *Crash {
+		return &Crash{ErrorType: "TypeError", Platform: platform, StackTrace: []StackFrame{
+			{FileName: "https://example.com/main.3f9a2c1b7d.js", MethodName: "n", LineNumber: line, ColumnNumber: column},
+		}}
+	}
+
+	tests := []struct {
+		name       string
+		a, b       *Crash
+		wantSame   bool
+		normalized string
+	}{
+		// Bundled web code is all on one line, so functions differ by column
+		{"web distinct columns", frame(PlatformWeb, 1, 1040), frame(PlatformWeb, 1, 5521), false, "n:main.js:C1040"},
+		{"web same column", frame(PlatformWeb, 1, 1040), frame(PlatformWeb, 2, 1040), true, "n:main.js:C1040"},
+		{"native ignores both", frame(PlatformIOS, 10, 4), frame(PlatformIOS, 12, 9), true, "n:main.js"},
+		{"line mode", frame(PlatformAndroid, 10, 4), frame(PlatformAndroid, 12, 4), false, "n:main.js:L10"},
+		{"line mode ignores columns", frame(PlatformAndroid, 10, 4), frame(PlatformAndroid, 10, 9), true, "n:main.js:L10"},
+		{"line and column", frame(PlatformDesktop, 10, 4), frame(PlatformDesktop, 10, 9), false, "n:main.js:L10:C4"},
+		{"platform case", frame("Web", 1, 1040), frame("WEB", 1, 5521), false, "n:main.js:C1040"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGrouper()
+			g.FramePositions = positions
+
+			explanation := g.ExplainFingerprint(tt.a, nil)
+			if got := explanation.Frames[0].Normalized; got != tt.normalized {
+				t.Errorf("normalized frame = %q, want %q", got, tt.normalized)
+			}
+			if same := explanation.Fingerprint == g.GenerateFingerprint(tt.b, nil); same != tt.wantSame {
+				t.Errorf("same fingerprint = %v, want %v", same, tt.wantSame)
+			}
+		})
+	}
+}
+
+func TestIncludeFrameworkFrames(t *testing.T) {
+	// crash returns a crash inside a native frame, below the same app frame
+	crash := func(nativeMethod string) *Crash {
+		return &Crash{ErrorType: "SIGSEGV", Platform: PlatformAndroid, StackTrace: []StackFrame{
+			{FileName: "libflutter.so", MethodName: nativeMethod, Native: true},
+			{FileName: "lib/player.dart", MethodName: "play", ClassName: "Player"},
+		}}
+	}
+
+	tests := []struct {
+		name     string
+		app      *App
+		wantSame bool
+	}{
+		{"no app", nil, true},
+		{"skipped", &App{}, true},
+		{"included", &App{IncludeFrameworkFrames: true}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGrouper()
+			a := g.GenerateFingerprint(crash("SkCanvas::drawRect"), tt.app)
+			b := g.GenerateFingerprint(crash("GrGLGpu::flush"), tt.app)
+			if same := a == b; same != tt.wantSame {
+				t.Errorf("same fingerprint = %v, want %v", same, tt.wantSame)
+			}
+		})
+	}
+}
+
+func TestFingerprintMetadataKeys(t *testing.T) {
+	crash := func(metadata map[string]interface{}) *Crash {
+		return &Crash{ErrorType: "HttpException", Platform: PlatformAndroid, Metadata: metadata, StackTrace: []StackFrame{
+			{FileName: "lib/api.dart", MethodName: "get", ClassName: "ApiClient"},
+		}}
+	}
+	app := &App{FingerprintMetadataKeys: []string{"http_status"}}
+
+	tests := []struct {
+		name     string
+		app      *App
+		a, b     map[string]interface{}
+		wantSame bool
+	}{
+		{"different status", app, map[string]interface{}{"http_status": 404}, map[string]interface{}{"http_status": 500}, false},
+		{"same status", app, map[string]interface{}{"http_status": 500}, map[string]interface{}{"http_status": float64(500), "user": "a"}, true},
+		{"key missing on both", app, nil, map[string]interface{}{"user": "a"}, true},
+		{"key missing on one", app, nil, map[string]interface{}{"http_status": 500}, false},
+		{"null value skipped", app, nil, map[string]interface{}{"http_status": nil}, true},
+		{"unconfigured key", &App{}, map[string]interface{}{"http_status": 404}, map[string]interface{}{"http_status": 500}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGrouper()
+			a := g.GenerateFingerprint(crash(tt.a), tt.app)
+			b := g.GenerateFingerprint(crash(tt.b), tt.app)
+			if same := a == b; same != tt.wantSame {
+				t.Errorf("same fingerprint = %v, want %v", same, tt.wantSame)
+			}
+		})
+	}
+
+	// Crashes without configured keys fingerprint as for an app without them
+	g := NewGrouper()
+	if got, want := g.GenerateFingerprint(crash(nil), app), g.GenerateFingerprint(crash(nil), nil); got != want {
+		t.Errorf("fingerprint without metadata = %s, want %s", got, want)
+	}
+
+	explanation := g.ExplainFingerprint(crash(map[string]interface{}{"http_status": 404}), app)
+	if explanation.Metadata["http_status"] != "404" {
+		t.Errorf("explained metadata = %v, want http_status 404", explanation.Metadata)
+	}
+}
+
+func TestFrameWeighting(t *testing.T) {
+	frame := func(class, method string) StackFrame {
+		return StackFrame{FileName: "lib/" + strings.ToLower(class) + ".dart", MethodName: method, ClassName: class}
+	}
+	// crash returns a crash through the given frames, below a framework frame
+	crash := func(frames ...StackFrame) *Crash {
+		stack := append([]StackFrame{{FileName: "dart:async/zone.dart", MethodName: "_rootRun"}}, frames...)
+		return &Crash{ErrorType: "StateError", Platform: PlatformAndroid, StackTrace: stack}
+	}
+	flat := func(limit int) *Grouper {
+		g := NewGrouper()
+		g.FrameLimit = limit
+		return g
+	}
+	weighted := func(full, coarse int) *Grouper {
+		g := NewGrouper()
+		g.FrameWeighting = FrameWeightingWeighted
+		g.Weights = FrameWeights{FullFrames: full, CoarseFrames: coarse}
+		return g
+	}
+
+	tests := []struct {
+		name     string
+		grouper  *Grouper
+		a, b     *Crash
+		wantSame bool
+	}{
+		{
+			name:     "flat: difference within the limit",
+			grouper:  flat(5),
+			a:        crash(frame("Cart", "add"), frame("Shop", "buy")),
+			b:        crash(frame("Cart", "add"), frame("Wishlist", "move")),
+			wantSame: false,
+		},
+		{
+			name:     "flat: difference past the limit",
+			grouper:  flat(2),
+			a:        crash(frame("Cart", "add"), frame("Shop", "buy")),
+			b:        crash(frame("Cart", "add"), frame("Wishlist", "move")),
+			wantSame: true,
+		},
+		{
+			name:     "weighted: different call paths to the same frame",
+			grouper:  weighted(1, 0),
+			a:        crash(frame("Cart", "add"), frame("Shop", "buy")),
+			b:        crash(frame("Cart", "add"), frame("Wishlist", "move")),
+			wantSame: true,
+		},
+		{
+			name:     "weighted: different top frames",
+			grouper:  weighted(1, 0),
+			a:        crash(frame("Cart", "add")),
+			b:        crash(frame("Cart", "remove")),
+			wantSame: false,
+		},
+		{
+			name:     "weighted: coarse frames ignore methods",
+			grouper:  weighted(1, 1),
+			a:        crash(frame("Cart", "add"), frame("Shop", "buy")),
+			b:        crash(frame("Cart", "add"), frame("Shop", "reorder")),
+			wantSame: true,
+		},
+		{
+			name:     "weighted: coarse frames keep classes",
+			grouper:  weighted(1, 1),
+			a:        crash(frame("Cart", "add"), frame("Shop", "buy")),
+			b:        crash(frame("Cart", "add"), frame("Wishlist", "buy")),
+			wantSame: false,
+		},
+		{
+			name:     "weighted: framework frames above the top frame",
+			grouper:  weighted(1, 0),
+			a:        crash(frame("Cart", "add")),
+			b:        &Crash{ErrorType: "StateError", Platform: PlatformAndroid, StackTrace: []StackFrame{frame("Cart", "add")}},
+			wantSame: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := tt.grouper.GenerateFingerprint(tt.a, nil)
+			b := tt.grouper.GenerateFingerprint(tt.b, nil)
+			if same := a == b; same != tt.wantSame {
+				t.Errorf("same fingerprint = %v, want %v", same, tt.wantSame)
+			}
+		})
+	}
+
+	explanation := weighted(1, 1).ExplainFingerprint(crash(frame("Cart", "add"), frame("Shop", "buy"), frame("App", "main")), nil)
+	var got []string
+	for _, f := range explanation.Frames {
+		switch {
+		case f.Skipped != "":
+			got = append(got, f.Skipped)
+		case f.Coarse:
+			got = append(got, "coarse")
+		default:
+			got = append(got, "full")
+		}
+	}
+	want := []string{FrameSkipAboveTop, "full", "coarse", FrameSkipFrameLimit}
+	if !slices.Equal(got, want) || explanation.FrameWeighting != FrameWeightingWeighted || explanation.FrameLimit != 2 {
+		t.Errorf("explained frames = %v with weighting %s and limit %d, want %v with weighted and 2", got, explanation.FrameWeighting, explanation.FrameLimit, want)
+	}
+}
+
+func TestAppleFrames(t *testing.T) {
+	// frame returns an iOS crash thrown from an Apple-format frame of binary
+	frame := func(binary, method string) *Crash {
+		return &Crash{ErrorType: "EXC_BAD_ACCESS", Platform: PlatformIOS, StackTrace: []StackFrame{
+			{FileName: binary, MethodName: method},
+		}}
+	}
+	apple := func(bucket uint64) *Grouper {
+		g := NewGrouper()
+		g.AppleFrames = AppleFrames{Enabled: true, OffsetBucket: bucket}
+		return g
+	}
+
+	tests := []struct {
+		name       string
+		grouper    *Grouper
+		a, b       *Crash
+		wantSame   bool
+		normalized string
+	}{
+		{
+			name:       "disabled",
+			grouper:    NewGrouper(),
+			a:          frame("MyApp", "0x0000000104a8c123 MyApp + 74051"),
+			b:          frame("MyApp", "0x0000000102f10123 MyApp + 74051"),
+			wantSame:   false,
+			normalized: "0x0000000104a8c123 MyApp + 74051:MyApp",
+		},
+		{
+			name:       "load address dropped",
+			grouper:    apple(4096),
+			a:          frame("MyApp", "0x0000000104a8c123 MyApp + 74051"),
+			b:          frame("MyApp", "0x0000000102f10123 MyApp + 74051"),
+			wantSame:   true,
+			normalized: "+18:MyApp",
+		},
+		{
+			name:       "offset within a bucket",
+			grouper:    apple(4096),
+			a:          frame("MyApp", "0x0000000104a8c123 MyApp + 74051"),
+			b:          frame("MyApp", "0x0000000104a8c123 MyApp + 74100"),
+			wantSame:   true,
+			normalized: "+18:MyApp",
+		},
+		{
+			name:       "offset in another bucket",
+			grouper:    apple(4096),
+			a:          frame("MyApp", "0x0000000104a8c123 MyApp + 74051"),
+			b:          frame("MyApp", "0x0000000104a8c123 MyApp + 90000"),
+			wantSame:   false,
+			normalized: "+18:MyApp",
+		},
+		{
+			name:       "hex offset",
+			grouper:    apple(4096),
+			a:          frame("MyApp", "MyApp + 0x12143"),
+			b:          frame("MyApp", "MyApp + 74051"),
+			wantSame:   true,
+			normalized: "+18:MyApp",
+		},
+		{
+			name:       "no buckets groups by binary",
+			grouper:    apple(0),
+			a:          frame("MyApp", "0x0000000104a8c123 MyApp + 74051"),
+			b:          frame("MyApp", "0x0000000104a8c123 MyApp + 900000"),
+			wantSame:   true,
+			normalized: "MyApp",
+		},
+		{
+			name:       "other binary",
+			grouper:    apple(0),
+			a:          frame("MyApp", "0x0000000104a8c123 MyApp + 74051"),
+			b:          frame("MyAppKit", "0x0000000104a8c123 MyAppKit + 74051"),
+			wantSame:   false,
+			normalized: "MyApp",
+		},
+		{
+			name:       "symbolicated frames ignore offsets",
+			grouper:    apple(4096),
+			a:          frame("MyApp", "0x0000000104a8c123 -[CartViewController checkout:] + 120"),
+			b:          frame("MyApp", "0x0000000102f10123 -[CartViewController checkout:] + 9000"),
+			wantSame:   true,
+			normalized: "-[CartViewController checkout:]:MyApp",
+		},
+		{
+			name:       "address as symbol",
+			grouper:    apple(4096),
+			a:          frame("MyApp", "0x0000000104a8c123 0x104a7a000 + 74051"),
+			b:          frame("MyApp", "0x0000000102f10123 0x102efe000 + 74051"),
+			wantSame:   true,
+			normalized: "+18:MyApp",
+		},
+		{
+			name:       "other formats unchanged",
+			grouper:    apple(4096),
+			a:          frame("lib/cart.dart", "checkout"),
+			b:          frame("lib/cart.dart", "add"),
+			wantSame:   false,
+			normalized: "checkout:cart.dart",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			explanation := tt.grouper.ExplainFingerprint(tt.a, nil)
+			if got := explanation.Frames[0].Normalized; got != tt.normalized {
+				t.Errorf("normalized frame = %q, want %q", got, tt.normalized)
+			}
+			if explanation.AppleFrames != tt.grouper.AppleFrames.Enabled {
+				t.Errorf("explanation apple_frames = %v, want %v", explanation.AppleFrames, tt.grouper.AppleFrames.Enabled)
+			}
+			if same := explanation.Fingerprint == tt.grouper.GenerateFingerprint(tt.b, nil); same != tt.wantSame {
+				t.Errorf("same fingerprint = %v, want %v", same, tt.wantSame)
+			}
+		})
+	}
+}
+
+func TestParseJavaStackTrace(t *testing.T) {
+	tests := []struct {
+		name  string
+		trace string
+		want  []StackFrame
+	}{
+		{
+			name: "printStackTrace",
+			trace: "java.lang.IllegalStateException: closed\n" +
+				"\tat com.example.app.Repository.fetch(Repository.java:12)\n" +
+				"\tat com.example.app.MainActivity.onCreate(MainActivity.kt:55)\n",
+			want: []StackFrame{
+				{ClassName: "com.example.app.Repository", MethodName: "fetch", FileName: "Repository.java", LineNumber: 12},
+				{ClassName: "com.example.app.MainActivity", MethodName: "onCreate", FileName: "MainActivity.kt", LineNumber: 55},
+			},
+		},
+		{
+			name: "native and unknown locations",
+			trace: "\tat java.lang.Thread.sleep(Native Method)\n" +
+				"\tat a.b.c(Unknown Source:4)\n" +
+				"\tat a.b.d(Unknown Source)\n",
+			want: []StackFrame{
+				{ClassName: "java.lang.Thread", MethodName: "sleep", Native: true},
+				{ClassName: "a.b", MethodName: "c", LineNumber: 4},
+				{ClassName: "a.b", MethodName: "d"},
+			},
+		},
+		{
+			name: "module and class loader prefixes",
+			trace: "\tat java.base/java.util.ArrayList.get(ArrayList.java:427)\n" +
+				"\tat app//com.example.Main.main(Main.java:5)\n",
+			want: []StackFrame{
+				{ClassName: "java.util.ArrayList", MethodName: "get", FileName: "ArrayList.java", LineNumber: 427},
+				{ClassName: "com.example.Main", MethodName: "main", FileName: "Main.java", LineNumber: 5},
+			},
+		},
+		{
+			name: "caused by",
+			trace: "java.lang.RuntimeException: wrapped\n" +
+				"\tat com.example.Outer.call(Outer.java:10)\n" +
+				"Caused by: java.io.IOException: disk\n" +
+				"\tat com.example.Inner.read(Inner.java:20)\n" +
+				"\t... 1 more\n",
+			want: []StackFrame{
+				{ClassName: "com.example.Outer", MethodName: "call", FileName: "Outer.java", LineNumber: 10},
+				{ClassName: "com.example.Inner", MethodName: "read", FileName: "Inner.java", LineNumber: 20},
+			},
+		},
+		{
+			name: "suppressed skipped",
+			trace: "java.lang.Exception: main\n" +
+				"\tat com.example.A.run(A.java:1)\n" +
+				"\tSuppressed: java.lang.Exception: close\n" +
+				"\t\tat com.example.Res.close(Res.java:9)\n" +
+				"\t\t... 1 more\n" +
+				"\tat com.example.B.run(B.java:2)\n",
+			want: []StackFrame{
+				{ClassName: "com.example.A", MethodName: "run", FileName: "A.java", LineNumber: 1},
+				{ClassName: "com.example.B", MethodName: "run", FileName: "B.java", LineNumber: 2},
+			},
+		},
+		{
+			name:  "no frames",
+			trace: "java.lang.OutOfMemoryError\n",
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseJavaStackTrace(tt.trace); !slices.Equal(got, tt.want) {
+				t.Errorf("frames:\n got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePythonStackTrace(t *testing.T) {
+	tests := []struct {
+		name  string
+		trace string
+		want  []StackFrame
+	}{
+		{
+			name: "innermost call first",
+			trace: "Traceback (most recent call last):\n" +
+				"  File \"/app/main.py\", line 10, in <module>\n" +
+				"    handle(request)\n" +
+				"  File \"/app/handlers.py\", line 42, in handle\n" +
+				"    return int(value)\n" +
+				"           ^^^^^^^^^^\n" +
+				"ValueError: invalid literal for int() with base 10: 'x'\n",
+			want: []StackFrame{
+				{FileName: "/app/handlers.py", LineNumber: 42, MethodName: "handle"},
+				{FileName: "/app/main.py", LineNumber: 10, MethodName: "<module>"},
+			},
+		},
+		{
+			name: "chained exceptions",
+			trace: "Traceback (most recent call last):\n" +
+				"  File \"/app/db.py\", line 5, in load\n" +
+				"KeyError: 'id'\n" +
+				"\n" +
+				"During handling of the above exception, another exception occurred:\n" +
+				"\n" +
+				"Traceback (most recent call last):\n" +
+				"  File \"/app/api.py\", line 20, in get\n" +
+				"RuntimeError: lookup failed\n",
+			want: []StackFrame{
+				{FileName: "/app/api.py", LineNumber: 20, MethodName: "get"},
+				{FileName: "/app/db.py", LineNumber: 5, MethodName: "load"},
+			},
+		},
+		{
+			name: "frozen modules are native",
+			trace: "Traceback (most recent call last):\n" +
+				"  File \"<frozen importlib._bootstrap>\", line 1176, in _find_and_load\n" +
+				"  File \"/app/plugin.py\", line 3, in <module>\n",
+			want: []StackFrame{
+				{FileName: "/app/plugin.py", LineNumber: 3, MethodName: "<module>"},
+				{FileName: "<frozen importlib._bootstrap>", LineNumber: 1176, MethodName: "_find_and_load", Native: true},
+			},
+		},
+		{
+			name: "exception groups",
+			trace: "  + Exception Group Traceback (most recent call last):\n" +
+				"  |   File \"/app/tasks.py\", line 8, in run\n" +
+				"  | ExceptionGroup: errors (1 sub-exception)\n" +
+				"  +-+---------------- 1 ----------------\n" +
+				"    | Traceback (most recent call last):\n" +
+				"    |   File \"/app/worker.py\", line 15, in work\n" +
+				"    | OSError: disk full\n",
+			want: []StackFrame{
+				{FileName: "/app/worker.py", LineNumber: 15, MethodName: "work"},
+				{FileName: "/app/tasks.py", LineNumber: 8, MethodName: "run"},
+			},
+		},
+		{
+			name:  "no frames",
+			trace: "ValueError: bad\n",
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParsePythonStackTrace(tt.trace); !slices.Equal(got, tt.want) {
+				t.Errorf("frames:\n got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGrouperConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  GrouperConfig
+		wantErr bool
+	}{
+		{"empty", GrouperConfig{}, false},
+		{"all fields", GrouperConfig{FrameLimit: 50, IncludeErrorMessage: true, IgnoreFramePatterns: []string{`^Logger\.`}, IncludeFileNames: new(bool)}, false},
+		{"negative frame limit", GrouperConfig{FrameLimit: -1}, true},
+		{"frame limit too high", GrouperConfig{FrameLimit: 51}, true},
+		{"too many patterns", GrouperConfig{IgnoreFramePatterns: strings.Split(strings.Repeat("a,", 20)+"a", ",")}, true},
+		{"empty pattern", GrouperConfig{IgnoreFramePatterns: []string{""}}, true},
+		{"long pattern", GrouperConfig{IgnoreFramePatterns: []string{strings.Repeat("a", 257)}}, true},
+		{"invalid pattern", GrouperConfig{IgnoreFramePatterns: []string{"(unclosed"}}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestFingerprintConfig(t *testing.T) {
+	frame := func(file, class, method string) StackFrame {
+		return StackFrame{FileName: file, ClassName: class, MethodName: method}
+	}
+	crash := func(message string, frames ...StackFrame) *Crash {
+		return &Crash{ErrorType: "StateError", ErrorMessage: message, Platform: PlatformAndroid, StackTrace: frames}
+	}
+	checkout := frame("lib/checkout.dart", "Checkout", "submit")
+	cart := frame("lib/cart.dart", "Cart", "add")
+	logger := frame("lib/logging.dart", "Logger", "error")
+	noFileNames := false
+
+	tests := []struct {
+		name     string
+		config   GrouperConfig
+		a, b     *Crash
+		wantSame bool
+	}{
+		{"default frame limit", GrouperConfig{}, crash("m", checkout, cart), crash("m", checkout, logger), false},
+		{"frame limit", GrouperConfig{FrameLimit: 1}, crash("m", checkout, cart), crash("m", checkout, logger), true},
+		{"messages ignored by default", GrouperConfig{}, crash("no element", checkout), crash("closed", checkout), true},
+		{"error message", GrouperConfig{IncludeErrorMessage: true}, crash("no element", checkout), crash("closed", checkout), false},
+		{"error message first line", GrouperConfig{IncludeErrorMessage: true}, crash("no element\n at 1", checkout), crash("no element \n at 2", checkout), true},
+		{"wrapper frames count by default", GrouperConfig{}, crash("m", logger, checkout), crash("m", checkout), false},
+		{"ignored class and method", GrouperConfig{IgnoreFramePatterns: []string{`^Logger\.`}}, crash("m", logger, checkout), crash("m", checkout), true},
+		{"ignored file name", GrouperConfig{IgnoreFramePatterns: []string{`logging\.dart$`}}, crash("m", logger, checkout), crash("m", checkout), true},
+		{"ignored frames outside the limit", GrouperConfig{FrameLimit: 1, IgnoreFramePatterns: []string{`^Logger\.`}}, crash("m", logger, checkout), crash("m", logger, cart), false},
+		{"file names by default", GrouperConfig{}, crash("m", checkout), crash("m", frame("lib/pay.dart", "Checkout", "submit")), false},
+		{"without file names", GrouperConfig{IncludeFileNames: &noFileNames}, crash("m", checkout), crash("m", frame("lib/pay.dart", "Checkout", "submit")), true},
+		{"file-only frames keep file names", GrouperConfig{IncludeFileNames: &noFileNames}, crash("m", frame("a.js", "", "")), crash("m", frame("b.js", "", "")), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGrouper()
+			app := &App{FingerprintConfig: tt.config}
+			a, b := g.GenerateFingerprint(tt.a, app), g.GenerateFingerprint(tt.b, app)
+			if same := a == b; same != tt.wantSame {
+				t.Errorf("same fingerprint = %v, want %v", same, tt.wantSame)
+			}
+		})
+	}
+
+	// The explanation shows the hashed message line and ignored frames
+	app := &App{FingerprintConfig: GrouperConfig{IncludeErrorMessage: true, IgnoreFramePatterns: []string{`^Logger\.`}, IncludeFileNames: &noFileNames}}
+	explanation := NewGrouper().ExplainFingerprint(crash(" no element \nmore", logger, checkout), app)
+	if explanation.ErrorMessage != "no element" || explanation.IncludeFileNames {
+		t.Errorf("error message, file names = %q, %v; want %q, false", explanation.ErrorMessage, explanation.IncludeFileNames, "no element")
+	}
+	var skipped []string
+	for _, f := range explanation.Frames {
+		skipped = append(skipped, f.Skipped)
+	}
+	if want := []string{FrameSkipIgnored, ""}; !slices.Equal(skipped, want) {
+		t.Errorf("skipped = %q, want %q", skipped, want)
+	}
+}
+
+func TestNormalizeRoute(t *testing.T) {
+	tests := []struct {
+		url, want string
+	}{
+		{"https://shop.example/orders/123?tab=items", "/orders/:id"},
+		{"/orders/456/", "/orders/:id"},
+		{"/users/3f2504e0-4f89-11d3-9a0c-0305e82c3301/settings", "/users/:id/settings"},
+		{"/products/shoes-42", "/products/shoes-42"},
+		{"https://app.example/#/orders/123?tab=items", "/orders/:id"},
+		{"https://app.example/checkout#summary", "/checkout"},
+		{"https://app.example", "/"},
+		{"  /cart  ", "/cart"},
+		{"/bad%zz/7?x=1", "/bad%zz/:id"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeRoute(tt.url); got != tt.want {
+			t.Errorf("NormalizeRoute(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestRouteFingerprint(t *testing.T) {
+	crash := func(metadata map[string]interface{}) *Crash {
+		return &Crash{ErrorType: "TypeError", Platform: PlatformWeb, Metadata: metadata, StackTrace: testFrames}
+	}
+	byRoute := &App{FingerprintConfig: GrouperConfig{RouteMetadataKey: "url"}}
+
+	tests := []struct {
+		name     string
+		app      *App
+		a, b     map[string]interface{}
+		wantSame bool
+	}{
+		{"same route", byRoute, map[string]interface{}{"url": "/orders/1"}, map[string]interface{}{"url": "https://shop.example/orders/2?tab=items"}, true},
+		{"other route", byRoute, map[string]interface{}{"url": "/orders/1"}, map[string]interface{}{"url": "/cart"}, false},
+		{"key missing on both", byRoute, nil, map[string]interface{}{"page": "/cart"}, true},
+		{"key missing on one", byRoute, nil, map[string]interface{}{"url": "/cart"}, false},
+		{"not a string", byRoute, nil, map[string]interface{}{"url": 42.0}, true},
+		{"not configured", &App{}, map[string]interface{}{"url": "/orders/1"}, map[string]interface{}{"url": "/cart"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGrouper()
+			a := g.GenerateFingerprint(crash(tt.a), tt.app)
+			b := g.GenerateFingerprint(crash(tt.b), tt.app)
+			if same := a == b; same != tt.wantSame {
+				t.Errorf("same fingerprint = %v, want %v", same, tt.wantSame)
+			}
+		})
+	}
+
+	explanation := NewGrouper().ExplainFingerprint(crash(map[string]interface{}{"url": "/orders/9"}), byRoute)
+	if explanation.Route != "/orders/:id" {
+		t.Errorf("explained route = %q, want /orders/:id", explanation.Route)
+	}
+	if err := (GrouperConfig{RouteMetadataKey: strings.Repeat("k", 257)}).Validate(); err == nil {
+		t.Error("Validate accepted a 257 character route_metadata_key")
+	}
+}
+
+func TestNormalizeBreadcrumb(t *testing.T) {
+	tests := []struct {
+		breadcrumb Breadcrumb
+		want       string
+	}{
+		{Breadcrumb{Category: " Navigation ", Message: "Opened /orders/42"}, "navigation:Opened /orders/#"},
+		{Breadcrumb{Category: "ui.click", Message: "Tapped order 9b2f1c3e-1a2b-4c3d-8e9f-0a1b2c3d4e5f at 0x7fff"}, "ui.click:Tapped order # at #"},
+		{Breadcrumb{Category: "http", Message: "GET /api/cart failed\nstatus 500"}, "http:GET /api/cart failed"},
+		{Breadcrumb{}, ":"},
+		{Breadcrumb{Category: "log", Message: strings.Repeat("x", 2*maxMetadataValueLength)}, TruncateField("log:"+strings.Repeat("x", 2*maxMetadataValueLength), maxMetadataValueLength)},
+	}
+	for _, tt := range tests {
+		if got := normalizeBreadcrumb(tt.breadcrumb); got != tt.want {
+			t.Errorf("normalizeBreadcrumb(%+v) = %q, want %q", tt.breadcrumb, got, tt.want)
+		}
+	}
+}
+
+func TestLastBreadcrumbFingerprint(t *testing.T) {
+	crash := func(breadcrumbs ...Breadcrumb) *Crash {
+		return &Crash{ErrorType: "StateError", Platform: PlatformAndroid, Breadcrumbs: breadcrumbs, StackTrace: testFrames}
+	}
+	tap := func(message string) Breadcrumb {
+		return Breadcrumb{Category: "ui.click", Message: message}
+	}
+	byBreadcrumb := &App{FingerprintConfig: GrouperConfig{IncludeLastBreadcrumb: true}}
+
+	tests := []struct {
+		name     string
+		app      *App
+		a, b     []Breadcrumb
+		wantSame bool
+	}{
+		{"other action", byBreadcrumb, []Breadcrumb{tap("Tapped checkout")}, []Breadcrumb{tap("Tapped cancel")}, false},
+		{"same action, other ID", byBreadcrumb, []Breadcrumb{tap("Tapped order 17")}, []Breadcrumb{tap("Tapped order 23")}, true},
+		{"only the last counts", byBreadcrumb, []Breadcrumb{tap("Tapped cancel"), tap("Tapped checkout")}, []Breadcrumb{tap("Tapped checkout")}, true},
+		{"other category", byBreadcrumb, []Breadcrumb{tap("checkout")}, []Breadcrumb{{Category: "navigation", Message: "checkout"}}, false},
+		{"none on one", byBreadcrumb, nil, []Breadcrumb{tap("Tapped checkout")}, false},
+		{"not configured", &App{}, []Breadcrumb{tap("Tapped checkout")}, []Breadcrumb{tap("Tapped cancel")}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGrouper()
+			a := g.GenerateFingerprint(crash(tt.a...), tt.app)
+			b := g.GenerateFingerprint(crash(tt.b...), tt.app)
+			if same := a == b; same != tt.wantSame {
+				t.Errorf("same fingerprint = %v, want %v", same, tt.wantSame)
+			}
+		})
+	}
+
+	// Crashes without breadcrumbs fingerprint as for an app without the option
+	g := NewGrouper()
+	if got, want := g.GenerateFingerprint(crash(), byBreadcrumb), g.GenerateFingerprint(crash(), nil); got != want {
+		t.Errorf("fingerprint without breadcrumbs = %s, want %s", got, want)
+	}
+	explanation := g.ExplainFingerprint(crash(tap("Tapped order 17")), byBreadcrumb)
+	if explanation.Breadcrumb != "ui.click:Tapped order #" {
+		t.Errorf("explained breadcrumb = %q, want ui.click:Tapped order #", explanation.Breadcrumb)
+	}
+}
+
+func TestSplitFingerprint(t *testing.T) {
+	crash := func(errorType string) *Crash {
+		return &Crash{ErrorType: errorType, StackTrace: testFrames}
+	}
+
+	tests := []struct {
+		name       string
+		a, b       *Crash
+		keyA, keyB string
+		wantSame   bool
+	}{
+		{"same crash and key", crash("StateError"), crash("StateError"), "group-1:crash-1", "group-1:crash-1", true},
+		{"other key", crash("StateError"), crash("StateError"), "group-1:crash-1", "group-1:crash-2", false},
+		{"other crash", crash("StateError"), crash("RangeError"), "group-1:crash-1", "group-1:crash-1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGrouper()
+			a := g.SplitFingerprint(tt.a, nil, tt.keyA)
+			b := g.SplitFingerprint(tt.b, nil, tt.keyB)
+			if same := a == b; same != tt.wantSame {
+				t.Errorf("same fingerprint = %v (%s, %s), want %v", same, a, b, tt.wantSame)
+			}
+			if len(a) != 16 {
+				t.Errorf("fingerprint %q has length %d, want 16", a, len(a))
+			}
+			// Ingestion never produces a split fingerprint
+			if plain := g.GenerateFingerprint(tt.a, nil); a == plain {
+				t.Errorf("split fingerprint = plain fingerprint %s", plain)
+			}
+		})
+	}
+}
+
+func TestFallbackErrorType(t *testing.T) {
+	noFrames := []StackFrame{}
+	fileOnly := []StackFrame{{FileName: "/app/src/handler.js", LineNumber: 7}}
+
+	tests := []struct {
+		name     string
+		fallback string
+		message  string
+		frames   []StackFrame
+		limit    int
+		want     string
+	}{
+		{"disabled", ErrorTypeFallbackNone, "TypeError: x is undefined", testFrames, 0, ""},
+		{"top frame", ErrorTypeFallbackTopFrame, "TypeError: x is undefined", testFrames, 0, "UnknownError at CheckoutPage.submit"},
+		{"top frame without a method", ErrorTypeFallbackTopFrame, "boom", fileOnly, 0, "UnknownError at handler.js"},
+		{"top frame falls back to the message", ErrorTypeFallbackTopFrame, "TypeError: x is undefined", noFrames, 0, "TypeError"},
+		{"message", ErrorTypeFallbackMessage, "TypeError: x is undefined\nat line 2", testFrames, 0, "TypeError"},
+		{"message punctuation", ErrorTypeFallbackMessage, "  Timeout!  after 30s", testFrames, 0, "Timeout"},
+		{"message falls back to the top frame", ErrorTypeFallbackMessage, " \nTypeError", testFrames, 0, "UnknownError at CheckoutPage.submit"},
+		{"unprintable message", ErrorTypeFallbackMessage, "bad\x00byte", noFrames, 0, ""},
+		{"nothing usable", ErrorTypeFallbackTopFrame, "", noFrames, 0, ""},
+		{"truncated", ErrorTypeFallbackTopFrame, "", testFrames, 20, "UnknownError at C..."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGrouper()
+			g.ErrorTypeFallback = tt.fallback
+			g.Limits.ErrorType = tt.limit
+			crash := &Crash{ErrorMessage: tt.message, StackTrace: tt.frames}
+			if got := g.FallbackErrorType(crash); got != tt.want {
+				t.Errorf("FallbackErrorType = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
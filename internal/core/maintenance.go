@@ -0,0 +1,76 @@
+package core
+
+import (
+	"fmt"
+	"time"
+)
+
+// Maintenance window recurrences
+const (
+	MaintenanceOnce   = "once"
+	MaintenanceDaily  = "daily"
+	MaintenanceWeekly = "weekly"
+)
+
+// MaintenanceWindow is a period, such as a deploy, during which an app's
+// alerts are suppressed. Recurring windows repeat every day or week from
+// their first occurrence, in UTC.
+type MaintenanceWindow struct {
+	ID         string    `json:"id"`
+	StartsAt   time.Time `json:"starts_at"`
+	EndsAt     time.Time `json:"ends_at"`
+	Recurrence string    `json:"recurrence"`
+	Reason     string    `json:"reason,omitempty"`
+	// AllowEscalations lets escalation rules still fire during the window,
+	// so a crash that keeps climbing past a threshold isn't hidden
+	AllowEscalations bool      `json:"allow_escalations"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// period is how often the window repeats; zero for one-off windows
+func (w MaintenanceWindow) period() time.Duration {
+	switch w.Recurrence {
+	case MaintenanceDaily:
+		return 24 * time.Hour
+	case MaintenanceWeekly:
+		return 7 * 24 * time.Hour
+	}
+	return 0
+}
+
+// Validate checks that the window can be evaluated
+func (w MaintenanceWindow) Validate() error {
+	switch w.Recurrence {
+	case MaintenanceOnce, MaintenanceDaily, MaintenanceWeekly:
+	default:
+		return fmt.Errorf("unknown recurrence %q: must be %s, %s or %s",
+			w.Recurrence, MaintenanceOnce, MaintenanceDaily, MaintenanceWeekly)
+	}
+	if w.StartsAt.IsZero() || w.EndsAt.IsZero() {
+		return fmt.Errorf("starts_at and ends_at are required")
+	}
+	if !w.EndsAt.After(w.StartsAt) {
+		return fmt.Errorf("ends_at must be after starts_at")
+	}
+	if period := w.period(); period > 0 && w.EndsAt.Sub(w.StartsAt) >= period {
+		return fmt.Errorf("a %s window must be shorter than %s", w.Recurrence, period)
+	}
+	return nil
+}
+
+// Active reports whether t falls inside the window or one of its repeats
+func (w MaintenanceWindow) Active(t time.Time) bool {
+	if t.Before(w.StartsAt) {
+		return false
+	}
+	elapsed := t.Sub(w.StartsAt)
+	if period := w.period(); period > 0 {
+		elapsed %= period
+	}
+	return elapsed < w.EndsAt.Sub(w.StartsAt)
+}
+
+// Expired reports whether a one-off window has ended for good
+func (w MaintenanceWindow) Expired(t time.Time) bool {
+	return w.period() == 0 && !t.Before(w.EndsAt)
+}
@@ -0,0 +1,162 @@
+package core
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaintenanceWindowValidate(t *testing.T) {
+	start := time.Date(2026, 3, 2, 22, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		recurrence string
+		length     time.Duration
+		zeroStart  bool
+		wantErr    string
+	}{
+		{"once", MaintenanceOnce, 2 * time.Hour, false, ""},
+		{"daily", MaintenanceDaily, 23 * time.Hour, false, ""},
+		{"weekly", MaintenanceWeekly, 3 * 24 * time.Hour, false, ""},
+		{"long one-off", MaintenanceOnce, 30 * 24 * time.Hour, false, ""},
+		{"unknown recurrence", "monthly", time.Hour, false, "unknown recurrence"},
+		{"missing start", MaintenanceOnce, time.Hour, true, "required"},
+		{"ends before it starts", MaintenanceOnce, -time.Hour, false, "after starts_at"},
+		{"empty", MaintenanceOnce, 0, false, "after starts_at"},
+		{"daily covering the day", MaintenanceDaily, 24 * time.Hour, false, "shorter than"},
+		{"weekly covering the week", MaintenanceWeekly, 8 * 24 * time.Hour, false, "shorter than"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := MaintenanceWindow{StartsAt: start, EndsAt: start.Add(tt.length), Recurrence: tt.recurrence}
+			if tt.zeroStart {
+				w.StartsAt = time.Time{}
+			}
+			err := w.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMaintenanceWindowActive(t *testing.T) {
+	// Monday 22:00 to 02:00 UTC
+	start := time.Date(2026, 3, 2, 22, 0, 0, 0, time.UTC)
+	end := start.Add(4 * time.Hour)
+	at := func(days, hours int) time.Time {
+		return start.AddDate(0, 0, days).Add(time.Duration(hours) * time.Hour)
+	}
+
+	tests := []struct {
+		name        string
+		recurrence  string
+		t           time.Time
+		wantActive  bool
+		wantExpired bool
+	}{
+		{"once: before", MaintenanceOnce, at(0, -1), false, false},
+		{"once: at the start", MaintenanceOnce, start, true, false},
+		{"once: past midnight", MaintenanceOnce, at(0, 3), true, false},
+		{"once: at the end", MaintenanceOnce, end, false, true},
+		{"once: next day", MaintenanceOnce, at(1, 1), false, true},
+		{"daily: next day", MaintenanceDaily, at(1, 1), true, false},
+		{"daily: between repeats", MaintenanceDaily, at(1, 6), false, false},
+		{"daily: before the first", MaintenanceDaily, at(-1, 1), false, false},
+		{"weekly: next day", MaintenanceWeekly, at(1, 1), false, false},
+		{"weekly: a week later", MaintenanceWeekly, at(7, 1), true, false},
+		{"weekly: months later", MaintenanceWeekly, at(70, 3), true, false},
+		// Offsets follow UTC, whatever zone t is in
+		{"other zone", MaintenanceDaily, at(3, 1).In(time.FixedZone("PST", -8*3600)), true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := MaintenanceWindow{StartsAt: start, EndsAt: end, Recurrence: tt.recurrence}
+			if got := w.Active(tt.t); got != tt.wantActive {
+				t.Errorf("Active(%v) = %v, want %v", tt.t, got, tt.wantActive)
+			}
+			if got := w.Expired(tt.t); got != tt.wantExpired {
+				t.Errorf("Expired(%v) = %v, want %v", tt.t, got, tt.wantExpired)
+			}
+		})
+	}
+}
+
+func TestMaintenanceSuppressesAlerts(t *testing.T) {
+	now := time.Now().UTC()
+	active := MaintenanceWindow{ID: "deploy", StartsAt: now.Add(-time.Hour), EndsAt: now.Add(time.Hour), Recurrence: MaintenanceOnce}
+	escalation := func(url string) []interface{} {
+		return []interface{}{map[string]interface{}{"threshold": float64(2), "type": "webhook", "config": map[string]interface{}{"url": url}}}
+	}
+
+	tests := []struct {
+		name   string
+		window *MaintenanceWindow
+		// allowEscalations and fire set the window's and alert's overrides
+		allowEscalations bool
+		fire             bool
+		// crashAt is when the crashes happened, relative to now
+		crashAt        time.Duration
+		want           []string
+		wantSuppressed int
+	}{
+		{"no window", nil, false, false, 0, []string{"/alert", "/escalation"}, 0},
+		{"active window", &active, false, false, 0, nil, 2},
+		{"escalations allowed", &active, true, false, 0, []string{"/escalation"}, 1},
+		{"alert fires during maintenance", &active, false, true, 0, []string{"/alert", "/escalation"}, 0},
+		// Queued events are matched by when their crash happened
+		{"crash before the window", &active, false, false, -2 * time.Hour, []string{"/alert", "/escalation"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := newWebhookReceiver(t)
+			alert := &Alert{ID: "alert-1", AppID: "app-1", Type: "webhook", Enabled: true, Config: map[string]interface{}{
+				"url":                     receiver.URL + "/alert",
+				"conditions":              map[string]interface{}{"on_new_group": true},
+				"escalation":              escalation(receiver.URL + "/escalation"),
+				"fire_during_maintenance": tt.fire,
+			}}
+			am := newTestAlertManager(t, alert)
+			if tt.window != nil {
+				window := *tt.window
+				window.AllowEscalations = tt.allowEscalations
+				am.SetMaintenanceWindows("app-1", []MaintenanceWindow{window})
+			}
+			// Another app's window doesn't apply
+			am.SetMaintenanceWindows("app-2", []MaintenanceWindow{active})
+
+			at := now.Add(tt.crashAt)
+			am.processEvent(newGroupEvent("app-1", "group-1", at))
+			am.processEvent(crashEvent("app-1", "group-1", 2, at))
+
+			got := receiver.paths()
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("sent = %v, want %v", got, tt.want)
+			}
+			if got := am.SuppressedCount("deploy"); got != tt.wantSuppressed {
+				t.Errorf("suppressed = %d, want %d", got, tt.wantSuppressed)
+			}
+		})
+	}
+
+	// Clearing an app's windows lifts suppression
+	receiver := newWebhookReceiver(t)
+	am := newTestAlertManager(t, &Alert{ID: "alert-1", AppID: "app-1", Type: "webhook", Enabled: true, Config: map[string]interface{}{
+		"url":        receiver.URL,
+		"conditions": map[string]interface{}{"on_new_group": true},
+	}})
+	am.SetMaintenanceWindows("app-1", []MaintenanceWindow{active})
+	am.SetMaintenanceWindows("app-1", nil)
+	am.processEvent(newGroupEvent("app-1", "group-1", now))
+	if got := len(receiver.received()); got != 1 {
+		t.Errorf("sent %d alerts after clearing the window, want 1", got)
+	}
+}
@@ -0,0 +1,243 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Errors returned by MetadataLimits.Check
+var (
+	ErrMetadataTooDeep  = errors.New("metadata is nested too deeply")
+	ErrMetadataTooLarge = errors.New("metadata is too large")
+)
+
+// Actions for crashes over the metadata limits
+const (
+	// MetadataLimitTrim cuts the maps down and stores the crash
+	MetadataLimitTrim = "trim"
+	// MetadataLimitReject rejects the submission
+	MetadataLimitReject = "reject"
+)
+
+// depthExceededMarker replaces values nested deeper than the depth limit
+const depthExceededMarker = "[max depth exceeded]"
+
+// MetadataLimits bounds the client-supplied maps of a crash: metadata and
+// breadcrumb data. MaxDepth is how many levels of nested objects and arrays
+// a map may have, counting the map itself. MaxBytes bounds the JSON size of
+// the metadata, and separately of all breadcrumb data together. Zero means
+// unlimited.
+type MetadataLimits struct {
+	MaxDepth int
+	MaxBytes int
+}
+
+// Check returns ErrMetadataTooDeep or ErrMetadataTooLarge, wrapped with
+// where the limit was exceeded, if the crash's maps are over the limits
+func (l MetadataLimits) Check(crash *Crash) error {
+	size, deep := l.measure(crash.Metadata)
+	if deep {
+		return fmt.Errorf("%w: metadata deeper than %d levels", ErrMetadataTooDeep, l.MaxDepth)
+	}
+	if l.MaxBytes > 0 && size > l.MaxBytes {
+		return fmt.Errorf("%w: metadata is %d bytes, limit is %d", ErrMetadataTooLarge, size, l.MaxBytes)
+	}
+
+	total := 0
+	for i, breadcrumb := range crash.Breadcrumbs {
+		size, deep := l.measure(breadcrumb.Data)
+		if deep {
+			return fmt.Errorf("%w: breadcrumb %d data deeper than %d levels", ErrMetadataTooDeep, i, l.MaxDepth)
+		}
+		total += size
+	}
+	if l.MaxBytes > 0 && total > l.MaxBytes {
+		return fmt.Errorf("%w: breadcrumb data is %d bytes, limit is %d", ErrMetadataTooLarge, total, l.MaxBytes)
+	}
+	return nil
+}
+
+// Trim brings the crash's maps within the limits and reports whether
+// anything was removed. Values nested too deeply are replaced with a
+// marker. Oversized metadata loses its largest keys first, and oversized
+// breadcrumb data is dropped from the oldest breadcrumbs first.
+func (l MetadataLimits) Trim(crash *Crash) bool {
+	trimmed := false
+	if l.MaxDepth > 0 {
+		if m, cut := trimDepth(crash.Metadata, 1, l.MaxDepth); cut {
+			crash.Metadata, trimmed = m.(map[string]interface{}), true
+		}
+		for i := range crash.Breadcrumbs {
+			if m, cut := trimDepth(crash.Breadcrumbs[i].Data, 1, l.MaxDepth); cut {
+				crash.Breadcrumbs[i].Data, trimmed = m.(map[string]interface{}), true
+			}
+		}
+	}
+	if l.MaxBytes <= 0 {
+		return trimmed
+	}
+
+	if size := jsonSize(crash.Metadata); size > l.MaxBytes {
+		type entry struct {
+			key  string
+			size int
+		}
+		entries := make([]entry, 0, len(crash.Metadata))
+		for key, value := range crash.Metadata {
+			entries = append(entries, entry{key, len(key) + 4 + jsonSize(value)})
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].size != entries[j].size {
+				return entries[i].size > entries[j].size
+			}
+			return entries[i].key < entries[j].key
+		})
+		metadata := make(map[string]interface{}, len(crash.Metadata))
+		for key, value := range crash.Metadata {
+			metadata[key] = value
+		}
+		for _, e := range entries {
+			if size <= l.MaxBytes {
+				break
+			}
+			delete(metadata, e.key)
+			size -= e.size
+		}
+		crash.Metadata, trimmed = metadata, true
+	}
+
+	total := 0
+	for _, breadcrumb := range crash.Breadcrumbs {
+		total += jsonSize(breadcrumb.Data)
+	}
+	for i := 0; i < len(crash.Breadcrumbs) && total > l.MaxBytes; i++ {
+		if crash.Breadcrumbs[i].Data != nil {
+			total -= jsonSize(crash.Breadcrumbs[i].Data)
+			crash.Breadcrumbs[i].Data = nil
+			trimmed = true
+		}
+	}
+	return trimmed
+}
+
+// measure returns the JSON size of a map and whether it is nested deeper
+// than MaxDepth. Measuring stops at the depth limit, so a deep value costs
+// no more than the limit to check.
+func (l MetadataLimits) measure(m map[string]interface{}) (int, bool) {
+	if l.MaxDepth > 0 && tooDeep(m, 1, l.MaxDepth) {
+		return 0, true
+	}
+	if l.MaxBytes <= 0 {
+		return 0, false
+	}
+	return jsonSize(m), false
+}
+
+// tooDeep reports whether v, found at the given depth, has objects or
+// arrays nested below limit
+func tooDeep(v interface{}, depth, limit int) bool {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if depth > limit {
+			return true
+		}
+		for _, item := range v {
+			if tooDeep(item, depth+1, limit) {
+				return true
+			}
+		}
+	case []interface{}:
+		if depth > limit {
+			return true
+		}
+		for _, item := range v {
+			if tooDeep(item, depth+1, limit) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// trimDepth returns v with containers nested below limit replaced by a
+// marker, and whether any were. Containers are copied only when changed.
+func trimDepth(v interface{}, depth, limit int) (interface{}, bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if depth > limit {
+			return depthExceededMarker, true
+		}
+		var out map[string]interface{}
+		for key, item := range v {
+			if trimmedItem, cut := trimDepth(item, depth+1, limit); cut {
+				if out == nil {
+					out = make(map[string]interface{}, len(v))
+					for k, value := range v {
+						out[k] = value
+					}
+				}
+				out[key] = trimmedItem
+			}
+		}
+		if out != nil {
+			return out, true
+		}
+	case []interface{}:
+		if depth > limit {
+			return depthExceededMarker, true
+		}
+		var out []interface{}
+		for i, item := range v {
+			if trimmedItem, cut := trimDepth(item, depth+1, limit); cut {
+				if out == nil {
+					out = append([]interface{}(nil), v...)
+				}
+				out[i] = trimmedItem
+			}
+		}
+		if out != nil {
+			return out, true
+		}
+	}
+	return v, false
+}
+
+// jsonSize estimates the encoded JSON size of a decoded value without
+// encoding it. Strings are counted unescaped.
+func jsonSize(v interface{}) int {
+	switch v := v.(type) {
+	case nil:
+		return 4
+	case bool:
+		if v {
+			return 4
+		}
+		return 5
+	case string:
+		return len(v) + 2
+	case json.Number:
+		return len(v)
+	case float64:
+		return len(strconv.FormatFloat(v, 'g', -1, 64))
+	case map[string]interface{}:
+		if v == nil {
+			return 0 // omitted
+		}
+		size := 2 + max(len(v)-1, 0)
+		for key, item := range v {
+			size += len(key) + 3 + jsonSize(item)
+		}
+		return size
+	case []interface{}:
+		size := 2 + max(len(v)-1, 0)
+		for _, item := range v {
+			size += jsonSize(item)
+		}
+		return size
+	default:
+		return len(fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,165 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// nested returns a map with the given number of levels of nested maps
+func nested(levels int) map[string]interface{} {
+	m := map[string]interface{}{"leaf": "x"}
+	for i := 1; i < levels; i++ {
+		m = map[string]interface{}{"child": m}
+	}
+	return m
+}
+
+// sized returns breadcrumbs whose data are 22 bytes of JSON each
+func sized(n int) []Breadcrumb {
+	breadcrumbs := make([]Breadcrumb, n)
+	for i := range breadcrumbs {
+		breadcrumbs[i] = Breadcrumb{Message: "tap", Data: map[string]interface{}{"k": "12345678901234"}}
+	}
+	return breadcrumbs
+}
+
+func TestJSONSize(t *testing.T) {
+	values := []interface{}{
+		nil,
+		true,
+		false,
+		"hello",
+		json.Number("12345678901234567890"),
+		1.5,
+		float64(42),
+		map[string]interface{}{},
+		map[string]interface{}{"a": 1.0, "b": []interface{}{"x", nil, false}, "c": map[string]interface{}{"d": "e"}},
+		[]interface{}{},
+		[]interface{}{1.0, "two", map[string]interface{}{"three": 3.0}},
+	}
+	for _, v := range values {
+		data, _ := json.Marshal(v)
+		if got := jsonSize(v); got != len(data) {
+			t.Errorf("jsonSize(%s) = %d, want %d", data, got, len(data))
+		}
+	}
+}
+
+func TestMetadataLimitsCheck(t *testing.T) {
+	tests := []struct {
+		name        string
+		limits      MetadataLimits
+		metadata    map[string]interface{}
+		breadcrumbs []Breadcrumb
+		wantErr     error
+	}{
+		{"within limits", MetadataLimits{3, 1000}, nested(3), []Breadcrumb{{Data: nested(3)}}, nil},
+		{"metadata too deep", MetadataLimits{2, 0}, nested(3), nil, ErrMetadataTooDeep},
+		{"arrays count as levels", MetadataLimits{2, 0}, map[string]interface{}{"a": []interface{}{[]interface{}{1.0}}}, nil, ErrMetadataTooDeep},
+		{"metadata too large", MetadataLimits{0, 20}, map[string]interface{}{"k": strings.Repeat("x", 30)}, nil, ErrMetadataTooLarge},
+		{"breadcrumb data too deep", MetadataLimits{2, 0}, nil, []Breadcrumb{{}, {Data: nested(3)}}, ErrMetadataTooDeep},
+		{"breadcrumb data too large together", MetadataLimits{0, 30}, nil, sized(2), ErrMetadataTooLarge},
+		{"breadcrumb data within limit", MetadataLimits{0, 44}, nil, sized(2), nil},
+		{"unlimited", MetadataLimits{}, nested(50), sized(1000), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.limits.Check(&Crash{Metadata: tt.metadata, Breadcrumbs: tt.breadcrumbs})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Check = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMetadataLimitsTrim(t *testing.T) {
+	big := map[string]interface{}{
+		"big":   strings.Repeat("b", 50),
+		"mid":   strings.Repeat("m", 20),
+		"small": "x",
+	}
+
+	tests := []struct {
+		name            string
+		limits          MetadataLimits
+		metadata        map[string]interface{}
+		breadcrumbs     []Breadcrumb
+		wantMetadata    map[string]interface{}
+		wantBreadcrumbs []Breadcrumb
+		wantTrimmed     bool
+	}{
+		{
+			name:         "within limits",
+			limits:       MetadataLimits{3, 1000},
+			metadata:     nested(3),
+			wantMetadata: nested(3),
+		},
+		{
+			name:         "deep values replaced",
+			limits:       MetadataLimits{MaxDepth: 2},
+			metadata:     map[string]interface{}{"a": map[string]interface{}{"b": map[string]interface{}{"c": 1.0}}, "x": 1.0},
+			wantMetadata: map[string]interface{}{"a": map[string]interface{}{"b": depthExceededMarker}, "x": 1.0},
+			wantTrimmed:  true,
+		},
+		{
+			name:         "deep arrays replaced",
+			limits:       MetadataLimits{MaxDepth: 1},
+			metadata:     map[string]interface{}{"list": []interface{}{map[string]interface{}{"a": 1.0}}},
+			wantMetadata: map[string]interface{}{"list": depthExceededMarker},
+			wantTrimmed:  true,
+		},
+		{
+			name:            "deep breadcrumb data replaced",
+			limits:          MetadataLimits{MaxDepth: 1},
+			breadcrumbs:     []Breadcrumb{{Data: nested(2)}},
+			wantBreadcrumbs: []Breadcrumb{{Data: map[string]interface{}{"child": depthExceededMarker}}},
+			wantTrimmed:     true,
+		},
+		{
+			name:         "largest keys dropped first",
+			limits:       MetadataLimits{MaxBytes: 45},
+			metadata:     big,
+			wantMetadata: map[string]interface{}{"mid": strings.Repeat("m", 20), "small": "x"},
+			wantTrimmed:  true,
+		},
+		{
+			name:         "until within the limit",
+			limits:       MetadataLimits{MaxBytes: 40},
+			metadata:     big,
+			wantMetadata: map[string]interface{}{"small": "x"},
+			wantTrimmed:  true,
+		},
+		{
+			name:            "oldest breadcrumb data dropped first",
+			limits:          MetadataLimits{MaxBytes: 30},
+			breadcrumbs:     sized(3),
+			wantBreadcrumbs: append([]Breadcrumb{{Message: "tap"}, {Message: "tap"}}, sized(1)...),
+			wantTrimmed:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crash := &Crash{Metadata: tt.metadata, Breadcrumbs: tt.breadcrumbs}
+			if trimmed := tt.limits.Trim(crash); trimmed != tt.wantTrimmed {
+				t.Errorf("Trim = %v, want %v", trimmed, tt.wantTrimmed)
+			}
+			if !reflect.DeepEqual(crash.Metadata, tt.wantMetadata) {
+				t.Errorf("metadata = %v, want %v", crash.Metadata, tt.wantMetadata)
+			}
+			if !reflect.DeepEqual(crash.Breadcrumbs, tt.wantBreadcrumbs) {
+				t.Errorf("breadcrumbs = %+v, want %+v", crash.Breadcrumbs, tt.wantBreadcrumbs)
+			}
+			if err := tt.limits.Check(crash); err != nil {
+				t.Errorf("Check after Trim = %v", err)
+			}
+		})
+	}
+
+	// Trimming leaves the submitted map alone
+	if len(big) != 3 {
+		t.Errorf("submitted metadata = %v, want it unchanged", big)
+	}
+}
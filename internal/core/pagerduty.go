@@ -0,0 +1,186 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// pagerDutyEventsURL is the PagerDuty Events API v2 endpoint. Alerts may
+// override it with events_url, e.g. for the EU service region.
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// pagerDutySummaryLimit is the longest summary PagerDuty accepts
+const pagerDutySummaryLimit = 1024
+
+// pagerDutySeverities are the severities PagerDuty accepts
+var pagerDutySeverities = map[string]bool{"critical": true, "error": true, "warning": true, "info": true}
+
+// PagerDutyDedupKey identifies the PagerDuty incident of a crash group, so
+// repeated crashes update one incident and resolving the group closes it
+func PagerDutyDedupKey(groupID string) string {
+	return "inceptor-group-" + groupID
+}
+
+// sendPagerDuty sends a trigger event for the crash's group. Alert config:
+//
+//	"routing_key": "<integration key>", "severity": "critical"
+//
+// severity is critical, error (default), warning or info.
+func (am *AlertManager) sendPagerDuty(alert *Alert, event AlertEvent) error {
+	severity, _ := alert.Config["severity"].(string)
+	if !pagerDutySeverities[severity] {
+		severity = "error"
+	}
+
+	title := event.Group.Title
+	if title == "" {
+		title = event.Crash.ErrorType
+	}
+	summary := fmt.Sprintf("Crash in %s: %s", event.AppID, title)
+	if event.Threshold != nil {
+		summary = fmt.Sprintf("Spike in %s: %s, %s", event.AppID, event.Crash.ErrorType, event.Threshold.Summary())
+	}
+	if event.Type == AlertEventRegression {
+		summary = fmt.Sprintf("Regression in %s: %s", event.AppID, title)
+	}
+	if event.Replay {
+		summary = "[Replay] " + summary
+	}
+
+	details := map[string]interface{}{
+		"group_id":         event.Group.ID,
+		"crash_id":         event.Crash.ID,
+		"error_message":    ExtractErrorSummary(event.Crash),
+		"platform":         event.Crash.Platform,
+		"app_version":      event.Crash.AppVersion,
+		"occurrence_count": event.Group.OccurrenceCount,
+		"is_new_group":     event.IsNewGroup,
+	}
+	if event.PreviousStatus != "" {
+		details["previous_status"] = event.PreviousStatus
+	}
+	body := map[string]interface{}{
+		"event_action": "trigger",
+		"dedup_key":    PagerDutyDedupKey(event.Group.ID),
+		"payload": map[string]interface{}{
+			"summary":        TruncateField(summary, pagerDutySummaryLimit),
+			"source":         event.AppID,
+			"severity":       severity,
+			"timestamp":      event.Crash.CreatedAt.UTC().Format(time.RFC3339),
+			"component":      event.Crash.Platform,
+			"group":          event.Crash.Environment,
+			"class":          event.Crash.ErrorType,
+			"custom_details": details,
+		},
+	}
+	if am.dashboardURL != "" && event.Crash.ID != "" {
+		body["links"] = []map[string]string{
+			{"href": am.dashboardURL + "/crashes/" + event.Crash.ID, "text": "View in Dashboard"},
+		}
+	}
+
+	return am.postPagerDuty(alert.Config, body)
+}
+
+// sendCoalescedPagerDuty sends a trigger event for each event's group;
+// incidents are per group, so a batch can't share one
+func (am *AlertManager) sendCoalescedPagerDuty(alert *Alert, events []AlertEvent) error {
+	var errs []error
+	for _, event := range events {
+		errs = append(errs, am.sendPagerDuty(alert, event))
+	}
+	return errors.Join(errs...)
+}
+
+// processResolved sends a resolve event for a resolved group to every
+// PagerDuty routing key its app's alerts, or their escalation rules, could
+// have opened an incident on. Resolutions are never held back by
+// maintenance windows, cooldowns or coalescing.
+func (am *AlertManager) processResolved(event AlertEvent) {
+	am.alertsMu.RLock()
+	alerts := make([]*Alert, len(am.alerts))
+	copy(alerts, am.alerts)
+	am.alertsMu.RUnlock()
+
+	sent := make(map[string]bool)
+	resolve := func(alert *Alert, config map[string]interface{}) {
+		key, _ := config["routing_key"].(string)
+		if key == "" || sent[key] {
+			return
+		}
+		sent[key] = true
+		body := map[string]interface{}{
+			"event_action": "resolve",
+			"dedup_key":    PagerDutyDedupKey(event.Group.ID),
+		}
+		if err := am.postPagerDuty(config, body); err != nil {
+			log.Error().Err(err).Str("alert_id", alert.ID).Str("group_id", event.Group.ID).Msg("Failed to resolve PagerDuty incident")
+		}
+	}
+
+	for _, alert := range alerts {
+		if !alert.Enabled || (alert.AppID != "" && alert.AppID != event.AppID) {
+			continue
+		}
+		if alert.Type == "pagerduty" {
+			resolve(alert, alert.Config)
+		}
+		for _, rule := range parseEscalationRules(alert.Config) {
+			if rule.Type != "pagerduty" {
+				continue
+			}
+			// Rule config overrides the parent alert config, as when escalating
+			config := make(map[string]interface{}, len(alert.Config)+len(rule.Config))
+			for k, v := range alert.Config {
+				config[k] = v
+			}
+			for k, v := range rule.Config {
+				config[k] = v
+			}
+			resolve(alert, config)
+		}
+	}
+}
+
+// postPagerDuty sends one event to the Events API with the alert config's
+// routing_key. PagerDuty explains rejected events in the response body,
+// which is included in the error.
+func (am *AlertManager) postPagerDuty(config map[string]interface{}, body map[string]interface{}) error {
+	routingKey, _ := config["routing_key"].(string)
+	if routingKey == "" {
+		return fmt.Errorf("PagerDuty routing key not configured")
+	}
+	url := pagerDutyEventsURL
+	if u, ok := config["events_url"].(string); ok && u != "" {
+		url = u
+	}
+	body["routing_key"] = routingKey
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	resp, err := am.client.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to send PagerDuty event: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		if text := strings.TrimSpace(string(detail)); text != "" {
+			return fmt.Errorf("PagerDuty returned status %d: %s", resp.StatusCode, text)
+		}
+		return fmt.Errorf("PagerDuty returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,173 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSendPagerDuty(t *testing.T) {
+	at := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	event := func(modify func(e *AlertEvent)) AlertEvent {
+		e := crashEvent("app-1", "group-1", 7, at)
+		e.Group.Title = "StateError in App.main"
+		e.Crash.Platform = PlatformAndroid
+		e.Crash.Environment = "production"
+		if modify != nil {
+			modify(&e)
+		}
+		return e
+	}
+
+	tests := []struct {
+		name         string
+		config       map[string]interface{}
+		dashboardURL string
+		event        AlertEvent
+		wantSummary  string
+		wantSeverity string
+		wantLink     string
+	}{
+		{"crash", nil, "", event(nil), "Crash in app-1: StateError in App.main", "error", ""},
+		{"untitled group", nil, "", event(func(e *AlertEvent) { e.Group.Title = "" }), "Crash in app-1: StateError", "error", ""},
+		{"threshold", nil, "", event(func(e *AlertEvent) {
+			e.Threshold = &ThresholdCrossing{Count: 57, Window: 10 * time.Minute}
+		}), "Spike in app-1: StateError, 57 crashes in the last 10m", "error", ""},
+		{"regression", nil, "", event(func(e *AlertEvent) {
+			e.Type = AlertEventRegression
+			e.PreviousStatus = "resolved"
+		}), "Regression in app-1: StateError in App.main", "error", ""},
+		{"replay", nil, "", event(func(e *AlertEvent) { e.Replay = true }), "[Replay] Crash in app-1: StateError in App.main", "error", ""},
+		{"severity", map[string]interface{}{"severity": "critical"}, "", event(nil), "Crash in app-1: StateError in App.main", "critical", ""},
+		{"unknown severity", map[string]interface{}{"severity": "fatal"}, "", event(nil), "Crash in app-1: StateError in App.main", "error", ""},
+		{"dashboard link", nil, "https://crashes.example.com", event(nil), "Crash in app-1: StateError in App.main", "error", "https://crashes.example.com/crashes/crash-group-1"},
+		{"long summary", nil, "", event(func(e *AlertEvent) { e.Group.Title = strings.Repeat("x", 2000) }), TruncateField("Crash in app-1: "+strings.Repeat("x", 2000), pagerDutySummaryLimit), "error", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := newWebhookReceiver(t)
+			am := newTestAlertManager(t)
+			am.SetDashboardURL(tt.dashboardURL)
+			config := map[string]interface{}{"routing_key": "key-1", "events_url": receiver.URL + "/v2/enqueue"}
+			for k, v := range tt.config {
+				config[k] = v
+			}
+
+			if err := am.sendPagerDuty(&Alert{ID: "alert-1", Type: "pagerduty", Config: config}, tt.event); err != nil {
+				t.Fatalf("sendPagerDuty: %v", err)
+			}
+			received := receiver.received()
+			if len(received) != 1 || received[0].Path != "/v2/enqueue" {
+				t.Fatalf("requests = %v, want one to events_url", receiver.paths())
+			}
+			body := received[0].Payload
+			if body["event_action"] != "trigger" || body["routing_key"] != "key-1" || body["dedup_key"] != "inceptor-group-group-1" {
+				t.Errorf("event = %v, want a trigger for group-1 with key-1", body)
+			}
+			payload := body["payload"].(map[string]interface{})
+			if payload["summary"] != tt.wantSummary || payload["severity"] != tt.wantSeverity {
+				t.Errorf("summary, severity = %q, %v; want %q, %s", payload["summary"], payload["severity"], tt.wantSummary, tt.wantSeverity)
+			}
+			if payload["source"] != "app-1" || payload["component"] != PlatformAndroid || payload["group"] != "production" || payload["class"] != "StateError" {
+				t.Errorf("payload = %v", payload)
+			}
+			details := payload["custom_details"].(map[string]interface{})
+			if details["occurrence_count"] != float64(7) || details["group_id"] != "group-1" {
+				t.Errorf("custom details = %v", details)
+			}
+			if _, ok := details["previous_status"]; ok != (tt.event.PreviousStatus != "") {
+				t.Errorf("previous_status = %v, want it only on regressions", details["previous_status"])
+			}
+
+			var link string
+			if links, ok := body["links"].([]interface{}); ok {
+				link, _ = links[0].(map[string]interface{})["href"].(string)
+			}
+			if link != tt.wantLink {
+				t.Errorf("link = %q, want %q", link, tt.wantLink)
+			}
+		})
+	}
+}
+
+func TestSendPagerDutyErrors(t *testing.T) {
+	receiver := newWebhookReceiver(t)
+	receiver.status = http.StatusBadRequest
+	am := newTestAlertManager(t)
+	event := crashEvent("app-1", "group-1", 1, time.Now())
+
+	if err := am.sendPagerDuty(&Alert{Type: "pagerduty", Config: map[string]interface{}{"events_url": receiver.URL}}, event); err == nil || err.Error() != "PagerDuty routing key not configured" {
+		t.Errorf("without a routing key: %v", err)
+	}
+	if err := am.sendPagerDuty(&Alert{Type: "pagerduty", Config: map[string]interface{}{"routing_key": "key-1", "events_url": receiver.URL}}, event); err == nil || err.Error() != "PagerDuty returned status 400" {
+		t.Errorf("rejected event: %v", err)
+	}
+}
+
+func TestSendCoalescedPagerDuty(t *testing.T) {
+	receiver := newWebhookReceiver(t)
+	am := newTestAlertManager(t)
+	alert := &Alert{ID: "alert-1", Type: "pagerduty", Config: map[string]interface{}{"routing_key": "key-1", "events_url": receiver.URL}}
+	at := time.Now()
+
+	// Incidents are per group, so each event gets its own
+	if err := am.sendCoalescedPagerDuty(alert, []AlertEvent{newGroupEvent("app-1", "group-1", at), newGroupEvent("app-1", "group-2", at)}); err != nil {
+		t.Fatalf("sendCoalescedPagerDuty: %v", err)
+	}
+	var keys []string
+	for _, req := range receiver.received() {
+		keys = append(keys, req.Payload["dedup_key"].(string))
+	}
+	slices.Sort(keys)
+	if want := []string{"inceptor-group-group-1", "inceptor-group-group-2"}; !slices.Equal(keys, want) {
+		t.Errorf("dedup keys = %v, want %v", keys, want)
+	}
+}
+
+func TestProcessResolved(t *testing.T) {
+	receiver := newWebhookReceiver(t)
+	pagerDuty := func(id, appID, key string, enabled bool) *Alert {
+		return &Alert{ID: id, AppID: appID, Type: "pagerduty", Enabled: enabled, Config: map[string]interface{}{
+			"routing_key": key, "events_url": receiver.URL,
+		}}
+	}
+	escalating := &Alert{ID: "escalating", Type: "webhook", Enabled: true, Config: map[string]interface{}{
+		"url":         receiver.URL + "/webhook",
+		"routing_key": "inherited-key",
+		"events_url":  receiver.URL,
+		"escalation": []interface{}{
+			map[string]interface{}{"threshold": float64(10), "type": "pagerduty", "config": map[string]interface{}{"routing_key": "escalation-key"}},
+			map[string]interface{}{"threshold": float64(100), "type": "pagerduty"},
+			// Already resolved through app-key
+			map[string]interface{}{"threshold": float64(1000), "type": "pagerduty", "config": map[string]interface{}{"routing_key": "app-key"}},
+			map[string]interface{}{"threshold": float64(5), "type": "slack"},
+		},
+	}}
+	am := newTestAlertManager(t,
+		pagerDuty("app", "app-1", "app-key", true),
+		pagerDuty("all-apps", "", "global-key", true),
+		pagerDuty("other-app", "app-2", "other-key", true),
+		pagerDuty("disabled", "app-1", "disabled-key", false),
+		escalating,
+	)
+
+	am.Notify(AlertEvent{Type: AlertEventResolved, AppID: "app-1", Group: &CrashGroup{ID: "group-1", AppID: "app-1"}})
+	if err := am.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	var keys []string
+	for _, req := range receiver.received() {
+		if req.Payload["event_action"] != "resolve" || req.Payload["dedup_key"] != "inceptor-group-group-1" {
+			t.Errorf("request to %s = %v, want a resolve for group-1", req.Path, req.Payload)
+		}
+		keys = append(keys, req.Payload["routing_key"].(string))
+	}
+	slices.Sort(keys)
+	if want := []string{"app-key", "escalation-key", "global-key", "inherited-key"}; !slices.Equal(keys, want) {
+		t.Errorf("resolved with keys %v, want %v", keys, want)
+	}
+}
@@ -0,0 +1,138 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Reasons a crash is quarantined at ingest
+const (
+	QuarantineBlankErrorType = "blank_error_type"
+	QuarantineGarbageStack   = "garbage_stack"
+	QuarantineFlood          = "flood"
+	// QuarantineGroupCap marks crashes that would have created a new group
+	// while their app was at its group cap with nothing left to evict
+	QuarantineGroupCap = "group_cap"
+)
+
+// Quarantiner applies ingest heuristics that set suspicious submissions aside.
+// Quarantined crashes are stored but not grouped, alerted on or counted in
+// stats until an admin releases them.
+type Quarantiner struct {
+	// floodThreshold is how many identical submissions are accepted per
+	// floodWindow before further ones are quarantined (0 disables)
+	floodThreshold int
+	floodWindow    time.Duration
+	counter        *GroupCounter
+
+	mu        sync.Mutex
+	lastPrune time.Time
+}
+
+// NewQuarantiner creates a Quarantiner. Identical submissions beyond
+// floodThreshold within floodWindow are treated as a flood.
+func NewQuarantiner(floodThreshold int, floodWindow time.Duration) *Quarantiner {
+	q := &Quarantiner{
+		floodThreshold: floodThreshold,
+		floodWindow:    floodWindow,
+	}
+	if floodThreshold > 0 && floodWindow > 0 {
+		bucketSize := floodWindow / 10
+		if bucketSize < time.Second {
+			bucketSize = time.Second
+		}
+		q.counter = NewGroupCounter(bucketSize, floodWindow)
+	}
+	return q
+}
+
+// Check returns the reason a crash should be quarantined, or "" if it looks
+// genuine. The crash must already be fingerprinted.
+func (q *Quarantiner) Check(crash *Crash, now time.Time) string {
+	if q == nil {
+		return ""
+	}
+
+	if strings.TrimSpace(crash.ErrorType) == "" || !printable(crash.ErrorType) {
+		return QuarantineBlankErrorType
+	}
+
+	if garbageStack(crash.StackTrace) {
+		return QuarantineGarbageStack
+	}
+
+	if q.counter != nil {
+		key := submissionKey(crash)
+		q.counter.Record(key, now)
+		q.prune(now)
+		if q.counter.Count(key, now.Add(-q.floodWindow), now) > q.floodThreshold {
+			return QuarantineFlood
+		}
+	}
+
+	return ""
+}
+
+// prune drops flood counters for submissions not seen within the window
+func (q *Quarantiner) prune(now time.Time) {
+	q.mu.Lock()
+	due := now.Sub(q.lastPrune) >= q.floodWindow
+	if due {
+		q.lastPrune = now
+	}
+	q.mu.Unlock()
+
+	if due {
+		q.counter.Prune(now.Add(-q.floodWindow))
+	}
+}
+
+// garbageStack reports whether a stack trace has frames but none of them
+// identify any code, or contains unprintable names
+func garbageStack(frames []StackFrame) bool {
+	if len(frames) == 0 {
+		return false
+	}
+
+	identified := false
+	for _, frame := range frames {
+		if !printable(frame.FileName) || !printable(frame.MethodName) || !printable(frame.ClassName) {
+			return true
+		}
+		if frame.FileName != "" || frame.MethodName != "" || frame.ClassName != "" {
+			identified = true
+		}
+	}
+	return !identified
+}
+
+// printable reports whether s is valid UTF-8 without control characters
+func printable(s string) bool {
+	if !utf8.ValidString(s) {
+		return false
+	}
+	for _, r := range s {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' {
+			return false
+		}
+	}
+	return true
+}
+
+// submissionKey identifies byte-for-byte repeats of the same report from the
+// same device, as opposed to many users hitting the same crash
+func submissionKey(crash *Crash) string {
+	h := sha256.New()
+	for _, part := range []string{
+		crash.AppID, crash.Fingerprint, crash.ErrorMessage,
+		crash.AppVersion, crash.DeviceModel, crash.UserID,
+	} {
+		h.Write([]byte(part))
+		h.Write([]byte("|"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
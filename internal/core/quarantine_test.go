@@ -0,0 +1,57 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuarantinerCheck(t *testing.T) {
+	frames := []StackFrame{{FileName: "lib/main.dart", MethodName: "main", LineNumber: 10}}
+
+	tests := []struct {
+		name  string
+		crash Crash
+		want  string
+	}{
+		{"genuine", Crash{ErrorType: "StateError", StackTrace: frames}, ""},
+		{"no frames", Crash{ErrorType: "StateError"}, ""},
+		{"blank error type", Crash{ErrorType: "  ", StackTrace: frames}, QuarantineBlankErrorType},
+		{"unprintable error type", Crash{ErrorType: "State\x00Error", StackTrace: frames}, QuarantineBlankErrorType},
+		{"unidentified frames", Crash{ErrorType: "StateError", StackTrace: []StackFrame{{LineNumber: 1}, {LineNumber: 2}}}, QuarantineGarbageStack},
+		{"unprintable frame", Crash{ErrorType: "StateError", StackTrace: []StackFrame{{FileName: "lib/\x07.dart", MethodName: "main"}}}, QuarantineGarbageStack},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := NewQuarantiner(0, 0)
+			if got := q.Check(&tt.crash, time.Now()); got != tt.want {
+				t.Errorf("Check() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuarantinerFlood(t *testing.T) {
+	q := NewQuarantiner(3, time.Minute)
+	now := time.Now()
+	submission := func(device string) *Crash {
+		return &Crash{AppID: "app-1", ErrorType: "StateError", Fingerprint: "fp-1", DeviceModel: device}
+	}
+
+	for i := 0; i < 3; i++ {
+		if got := q.Check(submission("pixel"), now); got != "" {
+			t.Fatalf("submission %d quarantined as %q, want accepted", i+1, got)
+		}
+	}
+	if got := q.Check(submission("pixel"), now); got != QuarantineFlood {
+		t.Errorf("repeat past the threshold = %q, want %q", got, QuarantineFlood)
+	}
+	// The same crash from another device is a different submission
+	if got := q.Check(submission("iphone"), now); got != "" {
+		t.Errorf("other device = %q, want accepted", got)
+	}
+	// Once the window has passed, repeats are accepted again
+	if got := q.Check(submission("pixel"), now.Add(2*time.Minute)); got != "" {
+		t.Errorf("repeat after the window = %q, want accepted", got)
+	}
+}
@@ -0,0 +1,143 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimit overrides the API rate limit for one app. Zero fields use the
+// server defaults.
+type RateLimit struct {
+	// Rate is the sustained number of requests allowed per second
+	Rate float64 `json:"rate"`
+	// Burst is how many requests may arrive at once after a quiet period
+	Burst int `json:"burst"`
+}
+
+// Validate checks that the override is usable
+func (r RateLimit) Validate() error {
+	if r.Rate < 0 || math.IsNaN(r.Rate) || math.IsInf(r.Rate, 0) {
+		return fmt.Errorf("rate must be a non-negative number")
+	}
+	if r.Burst < 0 {
+		return fmt.Errorf("burst must not be negative")
+	}
+	return nil
+}
+
+// rateLimitSweepInterval is how often idle buckets are dropped
+const rateLimitSweepInterval = time.Minute
+
+// RateLimiter is a token-bucket limiter keyed by app, shared by every
+// transport that accepts crashes. It can also limit callers that aren't
+// apps, such as admin keys, with AllowKey.
+type RateLimiter struct {
+	// rate and burst are the defaults; they are guarded by mu, as they can
+	// change while running
+	rate  float64
+	burst int
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	// fullAt is when the bucket refills completely; from then on it is
+	// indistinguishable from a new bucket and can be dropped
+	fullAt time.Time
+}
+
+// NewRateLimiter creates a limiter allowing rate submissions per second with
+// the given burst for apps without their own limit. A rate of zero or less
+// disables limiting for those apps.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		rate:    rate,
+		burst:   burst,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// SetDefaults changes the rate and burst of apps without their own limit.
+// Buckets keep their tokens and refill at the new rate.
+func (l *RateLimiter) SetDefaults(rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate, l.burst = rate, burst
+}
+
+// defaults returns the default rate and burst
+func (l *RateLimiter) defaults() (float64, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate, l.burst
+}
+
+// limits returns the rate and burst that apply to an app
+func (l *RateLimiter) limits(app *App) (float64, int) {
+	rate, burst := l.defaults()
+	if app.RateLimit.Rate > 0 {
+		rate = app.RateLimit.Rate
+	}
+	if app.RateLimit.Burst > 0 {
+		burst = app.RateLimit.Burst
+	}
+	return rate, max(burst, 1)
+}
+
+// Allow takes a token for one request from the app's bucket at time t. If
+// the bucket is empty it returns false and how long until a token is free.
+func (l *RateLimiter) Allow(app *App, t time.Time) (time.Duration, bool) {
+	rate, burst := l.limits(app)
+	return l.take(app.ID, rate, burst, t)
+}
+
+// AllowKey is Allow for a caller identified by key, which always gets the
+// default limits. Keys share a namespace with app IDs, so callers should
+// prefix them.
+func (l *RateLimiter) AllowKey(key string, t time.Time) (time.Duration, bool) {
+	rate, burst := l.defaults()
+	return l.take(key, rate, max(burst, 1), t)
+}
+
+func (l *RateLimiter) take(key string, rate float64, burst int, t time.Time) (time.Duration, bool) {
+	if rate <= 0 {
+		return 0, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if t.Sub(l.lastSweep) >= rateLimitSweepInterval {
+		for id, b := range l.buckets {
+			if !t.Before(b.fullAt) {
+				delete(l.buckets, id)
+			}
+		}
+		l.lastSweep = t
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(burst), last: t}
+		l.buckets[key] = b
+	} else if elapsed := t.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(float64(burst), b.tokens+elapsed.Seconds()*rate)
+		b.last = t
+	}
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	b.fullAt = b.last.Add(time.Duration((float64(burst) - b.tokens) / rate * float64(time.Second)))
+	if !allowed {
+		return time.Duration((1 - b.tokens) / rate * float64(time.Second)), false
+	}
+	return 0, true
+}
@@ -0,0 +1,213 @@
+package core
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRateLimitValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   RateLimit
+		wantErr bool
+	}{
+		{"defaults", RateLimit{}, false},
+		{"override", RateLimit{Rate: 0.5, Burst: 10}, false},
+		{"negative rate", RateLimit{Rate: -1}, true},
+		{"NaN rate", RateLimit{Rate: math.NaN()}, true},
+		{"infinite rate", RateLimit{Rate: math.Inf(1)}, true},
+		{"negative burst", RateLimit{Burst: -1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.limit.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	type request struct {
+		appID string
+		// at is milliseconds after start
+		at     int
+		wantOK bool
+		// wantWait is in milliseconds
+		wantWait int
+	}
+	tests := []struct {
+		name     string
+		rate     float64
+		burst    int
+		apps     map[string]RateLimit
+		requests []request
+	}{
+		{
+			name:  "burst then blocked",
+			rate:  2,
+			burst: 3,
+			requests: []request{
+				{"app-1", 0, true, 0},
+				{"app-1", 0, true, 0},
+				{"app-1", 0, true, 0},
+				{"app-1", 0, false, 500},
+				{"app-1", 100, false, 400},
+			},
+		},
+		{
+			name:  "refills over time",
+			rate:  2,
+			burst: 2,
+			requests: []request{
+				{"app-1", 0, true, 0},
+				{"app-1", 0, true, 0},
+				{"app-1", 0, false, 500},
+				{"app-1", 500, true, 0},
+				{"app-1", 500, false, 500},
+				// Refilling stops at the burst
+				{"app-1", 60000, true, 0},
+				{"app-1", 60000, true, 0},
+				{"app-1", 60000, false, 500},
+			},
+		},
+		{
+			name:  "buckets are per app",
+			rate:  1,
+			burst: 1,
+			requests: []request{
+				{"app-1", 0, true, 0},
+				{"app-1", 0, false, 1000},
+				{"app-2", 0, true, 0},
+			},
+		},
+		{
+			name:  "app override",
+			rate:  1,
+			burst: 1,
+			apps:  map[string]RateLimit{"app-1": {Rate: 4, Burst: 2}},
+			requests: []request{
+				{"app-1", 0, true, 0},
+				{"app-1", 0, true, 0},
+				{"app-1", 0, false, 250},
+				{"app-2", 0, true, 0},
+				{"app-2", 0, false, 1000},
+			},
+		},
+		{
+			name:  "override only the burst",
+			rate:  1,
+			burst: 1,
+			apps:  map[string]RateLimit{"app-1": {Burst: 3}},
+			requests: []request{
+				{"app-1", 0, true, 0},
+				{"app-1", 0, true, 0},
+				{"app-1", 0, true, 0},
+				{"app-1", 0, false, 1000},
+			},
+		},
+		{
+			name:  "zero burst allows one request",
+			rate:  1,
+			burst: 0,
+			requests: []request{
+				{"app-1", 0, true, 0},
+				{"app-1", 0, false, 1000},
+			},
+		},
+		{
+			name:  "zero rate disables limiting",
+			rate:  0,
+			burst: 1,
+			requests: []request{
+				{"app-1", 0, true, 0},
+				{"app-1", 0, true, 0},
+				{"app-1", 0, true, 0},
+			},
+		},
+		{
+			name:  "app rate when the default is disabled",
+			rate:  0,
+			burst: 1,
+			apps:  map[string]RateLimit{"app-1": {Rate: 1}},
+			requests: []request{
+				{"app-1", 0, true, 0},
+				{"app-1", 0, false, 1000},
+				{"app-2", 0, true, 0},
+				{"app-2", 0, true, 0},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewRateLimiter(tt.rate, tt.burst)
+			for i, r := range tt.requests {
+				app := &App{ID: r.appID, RateLimit: tt.apps[r.appID]}
+				wait, ok := limiter.Allow(app, start.Add(time.Duration(r.at)*time.Millisecond))
+				want := time.Duration(r.wantWait) * time.Millisecond
+				if ok != r.wantOK || wait != want {
+					t.Errorf("request %d (%s at +%dms) = wait %v, ok %v; want %v, %v", i, r.appID, r.at, wait, ok, want, r.wantOK)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterSetDefaults(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(1, 1)
+	app := &App{ID: "app-1"}
+
+	if _, ok := limiter.AllowKey("admin:key", start); !ok {
+		t.Fatal("first keyed request was limited")
+	}
+	if wait, ok := limiter.AllowKey("admin:key", start); ok || wait != time.Second {
+		t.Errorf("second keyed request = wait %v, ok %v; want limited for 1s", wait, ok)
+	}
+	if _, ok := limiter.Allow(app, start); !ok {
+		t.Fatal("keys and apps share a bucket")
+	}
+
+	// The emptied bucket now refills at the new rate
+	limiter.SetDefaults(10, 5)
+	if wait, ok := limiter.Allow(app, start); ok || wait != 100*time.Millisecond {
+		t.Errorf("after SetDefaults = wait %v, ok %v; want limited for 100ms", wait, ok)
+	}
+	if _, ok := limiter.Allow(app, start.Add(100*time.Millisecond)); !ok {
+		t.Error("bucket didn't refill at the new rate")
+	}
+
+	limiter.SetDefaults(0, 0)
+	for i := 0; i < 3; i++ {
+		if _, ok := limiter.AllowKey("admin:key", start); !ok {
+			t.Errorf("request %d limited after disabling the default limit", i)
+		}
+	}
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(1, 2)
+
+	limiter.Allow(&App{ID: "idle"}, start)
+	limiter.Allow(&App{ID: "busy"}, start)
+	limiter.Allow(&App{ID: "busy"}, start)
+
+	// The idle app refilled after a second, the busy one is still refilling
+	// when the next sweep runs
+	limiter.Allow(&App{ID: "busy"}, start.Add(rateLimitSweepInterval))
+	limiter.Allow(&App{ID: "busy"}, start.Add(rateLimitSweepInterval))
+	limiter.Allow(&App{ID: "busy"}, start.Add(rateLimitSweepInterval))
+
+	limiter.mu.Lock()
+	_, idle := limiter.buckets["idle"]
+	_, busy := limiter.buckets["busy"]
+	limiter.mu.Unlock()
+	if idle || !busy {
+		t.Errorf("after sweep: idle bucket kept %v, busy bucket kept %v; want false, true", idle, busy)
+	}
+}
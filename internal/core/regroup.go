@@ -0,0 +1,125 @@
+package core
+
+import "sort"
+
+// maxGroupingExamples bounds the merges and splits a GroupingPreview lists
+const maxGroupingExamples = 10
+
+// GroupingPreview summarizes how a sample of crashes would regroup under
+// proposed fingerprinting rules. Both groupings are computed fresh, so the
+// preview shows the effect of the rule change alone.
+type GroupingPreview struct {
+	SampledCrashes int `json:"sampled_crashes"`
+	CurrentGroups  int `json:"current_groups"`
+	ProposedGroups int `json:"proposed_groups"`
+	// Merges counts proposed groups combining two or more current groups
+	Merges int `json:"merges"`
+	// Splits counts current groups divided among two or more proposed groups
+	Splits int `json:"splits"`
+	// ChangedCrashes counts crashes in a merged or split group
+	ChangedCrashes int          `json:"changed_crashes"`
+	MergeExamples  []GroupMerge `json:"merge_examples"`
+	SplitExamples  []GroupSplit `json:"split_examples"`
+}
+
+// GroupMerge is a proposed group combining several current groups,
+// identified by the stored group of their crashes
+type GroupMerge struct {
+	GroupIDs []string `json:"group_ids"`
+	Crashes  int      `json:"crashes"`
+}
+
+// GroupSplit is a current group divided into several proposed groups
+type GroupSplit struct {
+	GroupID string `json:"group_id"`
+	Into    int    `json:"into"`
+	Crashes int    `json:"crashes"`
+}
+
+// PreviewGrouping fingerprints crashes under both the current app and the
+// proposed one, which differ only in their fingerprinting options, and
+// compares the resulting groups
+func (g *Grouper) PreviewGrouping(crashes []*Crash, current, proposed *App) *GroupingPreview {
+	type group struct {
+		crashes int
+		// linked holds the fingerprints of the other grouping this group's
+		// crashes fall into
+		linked map[string]bool
+		// groupID is the stored group of the group's first crash
+		groupID string
+	}
+	currentGroups := make(map[string]*group)
+	proposedGroups := make(map[string]*group)
+	add := func(groups map[string]*group, fingerprint, linked, groupID string) {
+		grp, ok := groups[fingerprint]
+		if !ok {
+			grp = &group{linked: make(map[string]bool), groupID: groupID}
+			groups[fingerprint] = grp
+		}
+		grp.crashes++
+		grp.linked[linked] = true
+	}
+
+	for _, crash := range crashes {
+		from := g.GenerateFingerprint(crash, current)
+		to := g.GenerateFingerprint(crash, proposed)
+		add(currentGroups, from, to, crash.GroupID)
+		add(proposedGroups, to, from, crash.GroupID)
+	}
+
+	preview := &GroupingPreview{
+		SampledCrashes: len(crashes),
+		CurrentGroups:  len(currentGroups),
+		ProposedGroups: len(proposedGroups),
+		MergeExamples:  make([]GroupMerge, 0),
+		SplitExamples:  make([]GroupSplit, 0),
+	}
+	changed := make(map[string]bool) // current fingerprints of changed groups
+	for _, grp := range proposedGroups {
+		if len(grp.linked) < 2 {
+			continue
+		}
+		preview.Merges++
+		merge := GroupMerge{Crashes: grp.crashes}
+		for fingerprint := range grp.linked {
+			changed[fingerprint] = true
+			merge.GroupIDs = append(merge.GroupIDs, currentGroups[fingerprint].groupID)
+		}
+		sort.Strings(merge.GroupIDs)
+		preview.MergeExamples = append(preview.MergeExamples, merge)
+	}
+	for fingerprint, grp := range currentGroups {
+		if len(grp.linked) < 2 {
+			continue
+		}
+		preview.Splits++
+		changed[fingerprint] = true
+		preview.SplitExamples = append(preview.SplitExamples, GroupSplit{GroupID: grp.groupID, Into: len(grp.linked), Crashes: grp.crashes})
+	}
+	for fingerprint := range changed {
+		preview.ChangedCrashes += currentGroups[fingerprint].crashes
+	}
+
+	// Largest first, so the examples show the biggest changes
+	sort.Slice(preview.MergeExamples, func(i, j int) bool {
+		a, b := preview.MergeExamples[i], preview.MergeExamples[j]
+		if a.Crashes != b.Crashes {
+			return a.Crashes > b.Crashes
+		}
+		return a.GroupIDs[0] < b.GroupIDs[0]
+	})
+	sort.Slice(preview.SplitExamples, func(i, j int) bool {
+		a, b := preview.SplitExamples[i], preview.SplitExamples[j]
+		if a.Crashes != b.Crashes {
+			return a.Crashes > b.Crashes
+		}
+		return a.GroupID < b.GroupID
+	})
+	if len(preview.MergeExamples) > maxGroupingExamples {
+		preview.MergeExamples = preview.MergeExamples[:maxGroupingExamples]
+	}
+	if len(preview.SplitExamples) > maxGroupingExamples {
+		preview.SplitExamples = preview.SplitExamples[:maxGroupingExamples]
+	}
+	return preview
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestPreviewGrouping(t *testing.T) {
+	cartFrames := []StackFrame{
+		{FileName: "lib/cart.dart", LineNumber: 40, MethodName: "add", ClassName: "Cart"},
+		{FileName: "lib/main.dart", LineNumber: 3, MethodName: "main"},
+	}
+	crash := func(groupID, message string, frames []StackFrame) *Crash {
+		return &Crash{AppID: "app-1", GroupID: groupID, ErrorType: "StateError", ErrorMessage: message, StackTrace: frames}
+	}
+	// group-1 holds two messages from one stack; group-2 another stack
+	crashes := []*Crash{
+		crash("group-1", "closed", testFrames),
+		crash("group-1", "no element", testFrames),
+		crash("group-2", "closed", cartFrames),
+	}
+	current := &App{ID: "app-1"}
+	ignoreTop := GrouperConfig{IgnoreFramePatterns: []string{`^(CheckoutPage|Cart)\.`}}
+
+	tests := []struct {
+		name       string
+		config     GrouperConfig
+		want       GroupingPreview
+		wantMerges []GroupMerge
+		wantSplits []GroupSplit
+	}{
+		{"unchanged", GrouperConfig{}, GroupingPreview{SampledCrashes: 3, CurrentGroups: 2, ProposedGroups: 2}, nil, nil},
+		{"split", GrouperConfig{IncludeErrorMessage: true},
+			GroupingPreview{SampledCrashes: 3, CurrentGroups: 2, ProposedGroups: 3, Splits: 1, ChangedCrashes: 2},
+			nil, []GroupSplit{{GroupID: "group-1", Into: 2, Crashes: 2}}},
+		{"merge", ignoreTop,
+			GroupingPreview{SampledCrashes: 3, CurrentGroups: 2, ProposedGroups: 1, Merges: 1, ChangedCrashes: 3},
+			[]GroupMerge{{GroupIDs: []string{"group-1", "group-2"}, Crashes: 3}}, nil},
+		{"split and merge", GrouperConfig{IncludeErrorMessage: true, IgnoreFramePatterns: ignoreTop.IgnoreFramePatterns},
+			GroupingPreview{SampledCrashes: 3, CurrentGroups: 2, ProposedGroups: 2, Merges: 1, Splits: 1, ChangedCrashes: 3},
+			[]GroupMerge{{GroupIDs: []string{"group-1", "group-2"}, Crashes: 2}}, []GroupSplit{{GroupID: "group-1", Into: 2, Crashes: 2}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proposed := *current
+			proposed.FingerprintConfig = tt.config
+
+			got := NewGrouper().PreviewGrouping(crashes, current, &proposed)
+			counts := *got
+			counts.MergeExamples, counts.SplitExamples = nil, nil
+			if !reflect.DeepEqual(counts, tt.want) {
+				t.Errorf("preview = %+v, want %+v", counts, tt.want)
+			}
+			if !slices.EqualFunc(got.MergeExamples, tt.wantMerges, func(a, b GroupMerge) bool {
+				return slices.Equal(a.GroupIDs, b.GroupIDs) && a.Crashes == b.Crashes
+			}) {
+				t.Errorf("merges = %+v, want %+v", got.MergeExamples, tt.wantMerges)
+			}
+			if !slices.Equal(got.SplitExamples, tt.wantSplits) {
+				t.Errorf("splits = %+v, want %+v", got.SplitExamples, tt.wantSplits)
+			}
+		})
+	}
+}
+
+func TestPreviewGroupingExamples(t *testing.T) {
+	// Dropping the message merges each error type's two groups
+	var crashes []*Crash
+	for i := 0; i < 12; i++ {
+		errorType := fmt.Sprintf("Error%02d", i)
+		for _, message := range []string{"a", "b"} {
+			n := 1
+			if i == 7 {
+				n = 3 // the largest merge is listed first
+			}
+			for j := 0; j < n; j++ {
+				crashes = append(crashes, &Crash{GroupID: errorType + "-" + message, ErrorType: errorType, ErrorMessage: message, StackTrace: testFrames})
+			}
+		}
+	}
+	current := &App{ID: "app-1", FingerprintConfig: GrouperConfig{IncludeErrorMessage: true}}
+	proposed := &App{ID: "app-1"}
+
+	preview := NewGrouper().PreviewGrouping(crashes, current, proposed)
+	if preview.Merges != 12 || preview.ChangedCrashes != len(crashes) {
+		t.Errorf("merges, changed crashes = %d, %d; want 12, %d", preview.Merges, preview.ChangedCrashes, len(crashes))
+	}
+	if len(preview.MergeExamples) != maxGroupingExamples {
+		t.Fatalf("merge examples = %d, want %d", len(preview.MergeExamples), maxGroupingExamples)
+	}
+	first := preview.MergeExamples[0]
+	if !slices.Equal(first.GroupIDs, []string{"Error07-a", "Error07-b"}) || first.Crashes != 6 {
+		t.Errorf("first merge = %+v, want Error07's", first)
+	}
+	if second := preview.MergeExamples[1]; second.GroupIDs[0] != "Error00-a" {
+		t.Errorf("second merge = %+v, want Error00's", second)
+	}
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+// ReplayGuard limits how often alerts may be replayed for past crashes, so a
+// retried request or a script looping over crashes can't flood alert
+// channels. Each crash may be replayed once per cooldown, and at most
+// maxPerHour replays are allowed across all crashes.
+type ReplayGuard struct {
+	cooldown   time.Duration
+	maxPerHour int
+
+	mu     sync.Mutex
+	last   map[string]time.Time // crash ID -> when it was last replayed
+	recent []time.Time
+}
+
+// NewReplayGuard creates a guard allowing one replay per crash per cooldown
+// and maxPerHour replays in total (zero disables either limit)
+func NewReplayGuard(cooldown time.Duration, maxPerHour int) *ReplayGuard {
+	return &ReplayGuard{
+		cooldown:   cooldown,
+		maxPerHour: maxPerHour,
+		last:       make(map[string]time.Time),
+	}
+}
+
+// Allow records a replay of a crash at t if both limits permit it. Otherwise
+// it records nothing and returns how long to wait, and whether the crash's
+// own cooldown is what blocked it.
+func (g *ReplayGuard) Allow(crashID string, t time.Time) (wait time.Duration, perCrash bool, ok bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for id, last := range g.last {
+		if t.Sub(last) >= g.cooldown {
+			delete(g.last, id)
+		}
+	}
+	if last, seen := g.last[crashID]; seen {
+		return g.cooldown - t.Sub(last), true, false
+	}
+
+	cutoff := t.Add(-time.Hour)
+	recent := g.recent[:0]
+	for _, ts := range g.recent {
+		if ts.After(cutoff) {
+			recent = append(recent, ts)
+		}
+	}
+	g.recent = recent
+	if g.maxPerHour > 0 && len(recent) >= g.maxPerHour {
+		return recent[0].Sub(cutoff), false, false
+	}
+
+	if g.cooldown > 0 {
+		g.last[crashID] = t
+	}
+	g.recent = append(g.recent, t)
+	return 0, false, true
+}
@@ -0,0 +1,151 @@
+package core
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestReplayGuard(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	type replay struct {
+		crashID string
+		// at is minutes after start
+		at           int
+		wantOK       bool
+		wantPerCrash bool
+		// wantWait is in minutes
+		wantWait int
+	}
+	tests := []struct {
+		name       string
+		cooldown   time.Duration
+		maxPerHour int
+		replays    []replay
+	}{
+		{
+			name:     "per crash cooldown",
+			cooldown: time.Hour,
+			replays: []replay{
+				{"crash-1", 0, true, false, 0},
+				{"crash-1", 20, false, true, 40},
+				{"crash-2", 20, true, false, 0},
+				{"crash-1", 60, true, false, 0},
+			},
+		},
+		{
+			name:       "hourly cap",
+			maxPerHour: 2,
+			replays: []replay{
+				{"crash-1", 0, true, false, 0},
+				{"crash-2", 10, true, false, 0},
+				{"crash-3", 15, false, false, 45},
+				{"crash-3", 61, true, false, 0},
+			},
+		},
+		{
+			// A crash blocked by its cooldown doesn't use up the hourly cap
+			name:       "blocked replays aren't counted",
+			cooldown:   time.Hour,
+			maxPerHour: 2,
+			replays: []replay{
+				{"crash-1", 0, true, false, 0},
+				{"crash-1", 1, false, true, 59},
+				{"crash-1", 2, false, true, 58},
+				{"crash-2", 3, true, false, 0},
+				{"crash-3", 4, false, false, 56},
+			},
+		},
+		{
+			name: "no limits",
+			replays: []replay{
+				{"crash-1", 0, true, false, 0},
+				{"crash-1", 0, true, false, 0},
+				{"crash-1", 1, true, false, 0},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			guard := NewReplayGuard(tt.cooldown, tt.maxPerHour)
+			for i, r := range tt.replays {
+				wait, perCrash, ok := guard.Allow(r.crashID, start.Add(time.Duration(r.at)*time.Minute))
+				want := time.Duration(r.wantWait) * time.Minute
+				if ok != r.wantOK || perCrash != r.wantPerCrash || wait != want {
+					t.Errorf("replay %d (%s at +%dm) = wait %v, per crash %v, ok %v; want %v, %v, %v",
+						i, r.crashID, r.at, wait, perCrash, ok, want, r.wantPerCrash, r.wantOK)
+				}
+			}
+		})
+	}
+}
+
+func TestAlertManagerReplay(t *testing.T) {
+	receiver := newWebhookReceiver(t)
+	webhook := func(id, appID string, enabled bool, conditions map[string]interface{}) *Alert {
+		return &Alert{ID: id, AppID: appID, Type: "webhook", Enabled: enabled, Config: map[string]interface{}{
+			"url":        receiver.URL + "/" + id,
+			"conditions": conditions,
+		}}
+	}
+	am := newTestAlertManager(t,
+		webhook("new-group", "app-1", true, map[string]interface{}{"on_new_group": true}),
+		webhook("all-apps", "", true, map[string]interface{}{"on_new_group": true}),
+		webhook("disabled", "app-1", false, map[string]interface{}{"on_new_group": true}),
+		webhook("other-app", "app-2", true, map[string]interface{}{"on_new_group": true}),
+		// Velocity depends on live traffic, not on the replayed crash
+		webhook("velocity", "app-1", true, map[string]interface{}{
+			"velocity": map[string]interface{}{"window": "1h", "factor": float64(1), "min_count": float64(1)},
+		}),
+	)
+
+	results := am.Replay(newGroupEvent("app-1", "group-1", time.Now()))
+
+	var alertIDs []string
+	for _, result := range results {
+		if result.Error != "" {
+			t.Errorf("%s: %s", result.AlertID, result.Error)
+		}
+		alertIDs = append(alertIDs, result.AlertID)
+	}
+	tests := []struct {
+		alertID string
+		want    bool
+	}{
+		{"new-group", true},
+		{"all-apps", true},
+		{"disabled", false},
+		{"other-app", false},
+		{"velocity", false},
+	}
+	received := receiver.received()
+	for _, tt := range tests {
+		sent := false
+		for _, req := range received {
+			if req.Path == "/"+tt.alertID {
+				sent = true
+				if got := req.Header.Get("X-Inceptor-Replay"); got != "true" {
+					t.Errorf("%s: X-Inceptor-Replay = %q, want true", tt.alertID, got)
+				}
+			}
+		}
+		if sent != tt.want {
+			t.Errorf("%s: replayed = %v, want %v (results %v)", tt.alertID, sent, tt.want, alertIDs)
+		}
+	}
+	if len(results) != 2 {
+		t.Errorf("results = %v, want one per replayed alert", alertIDs)
+	}
+
+	// Failures are reported per alert rather than stopping the replay
+	receiver.mu.Lock()
+	receiver.status = http.StatusBadRequest
+	receiver.mu.Unlock()
+	for _, result := range am.Replay(newGroupEvent("app-1", "group-1", time.Now())) {
+		if result.Error == "" {
+			t.Errorf("%s: no error for a failing webhook", result.AlertID)
+		}
+	}
+}
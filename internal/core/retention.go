@@ -10,9 +10,11 @@ import (
 
 // RetentionManager handles automatic cleanup of old crash data
 type RetentionManager struct {
-	repo        RetentionRepository
-	fileStore   RetentionFileStore
+	repo      RetentionRepository
+	fileStore RetentionFileStore
+	// defaultDays is guarded by mu, as it can change while running
 	defaultDays int
+	mu          sync.Mutex
 	interval    time.Duration
 	ctx         context.Context
 	cancel      context.CancelFunc
@@ -102,7 +104,7 @@ func (rm *RetentionManager) cleanup() {
 		// Determine retention period for this app
 		retentionDays := app.RetentionDays
 		if retentionDays <= 0 {
-			retentionDays = rm.defaultDays
+			retentionDays = rm.DefaultDays()
 		}
 
 		cutoffDate := time.Now().AddDate(0, 0, -retentionDays)
@@ -141,6 +143,21 @@ func (rm *RetentionManager) cleanup() {
 		Msg("Retention cleanup completed")
 }
 
+// DefaultDays returns the retention period of apps without their own
+func (rm *RetentionManager) DefaultDays() int {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	return rm.defaultDays
+}
+
+// SetDefaultDays changes the retention period of apps without their own,
+// from the next cleanup on
+func (rm *RetentionManager) SetDefaultDays(days int) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.defaultDays = days
+}
+
 // RunNow triggers an immediate cleanup (useful for testing or manual triggering)
 func (rm *RetentionManager) RunNow() {
 	go rm.cleanup()
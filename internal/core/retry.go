@@ -0,0 +1,122 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Retry defaults for alerts without max_retries or retry_base_delay
+const (
+	defaultAlertRetries    = 3
+	defaultAlertRetryDelay = time.Second
+	maxAlertRetries        = 10
+)
+
+// maxAlertRetryDelay bounds the wait before one retry. A server asking to
+// wait longer with Retry-After fails the alert instead, since retries hold
+// up the alert queue.
+const maxAlertRetryDelay = 30 * time.Second
+
+// retryableError is a failed delivery worth retrying: a connection error,
+// or a 429 or 5xx response. retryAfter is the wait the server asked for in
+// Retry-After, if any.
+type retryableError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+
+func (e *retryableError) Unwrap() error { return e.err }
+
+// retryable marks a connection error as worth retrying
+func retryable(err error) error {
+	return &retryableError{err: err}
+}
+
+// statusError returns err for a failed response, marked as worth retrying
+// when the status is 429 or 5xx
+func statusError(resp *http.Response, err error) error {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+		return err
+	}
+	return &retryableError{err: err, retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())}
+}
+
+// parseRetryAfter reads a Retry-After header in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(t.Sub(now), 0)
+	}
+	return 0
+}
+
+// alertRetryPolicy reads how often an alert retries a failed delivery, e.g.
+//
+//	"max_retries": 5, "retry_base_delay": "2s"
+//
+// max_retries defaults to 3 and is capped at 10; 0 disables retries.
+// retry_base_delay, 1s by default, doubles with each retry.
+func alertRetryPolicy(config map[string]interface{}) (int, time.Duration) {
+	retries := defaultAlertRetries
+	if n, ok := config["max_retries"].(float64); ok && n >= 0 {
+		retries = min(int(n), maxAlertRetries)
+	}
+	delay := defaultAlertRetryDelay
+	if d, ok := config["retry_base_delay"].(string); ok {
+		if parsed, err := time.ParseDuration(d); err == nil && parsed > 0 {
+			delay = min(parsed, maxAlertRetryDelay)
+		}
+	}
+	return retries, delay
+}
+
+// withRetry calls send until it succeeds, fails with an error not worth
+// retrying, or runs out of the alert's retries. Each retry waits for the
+// base delay doubled per attempt, with jitter, or longer if the server asked
+// with Retry-After. Waiting stops when the manager is closed.
+func (am *AlertManager) withRetry(alert *Alert, send func() error) error {
+	retries, baseDelay := alertRetryPolicy(alert.Config)
+	for attempt := 0; ; attempt++ {
+		err := send()
+		var retry *retryableError
+		if err == nil || !errors.As(err, &retry) || attempt >= retries {
+			return err
+		}
+
+		// Equal jitter: half the backoff, plus up to the other half at random
+		backoff := min(baseDelay<<attempt, maxAlertRetryDelay)
+		delay := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		if retry.retryAfter > maxAlertRetryDelay {
+			return fmt.Errorf("%w; not retrying after %s", err, retry.retryAfter)
+		}
+		delay = max(delay, retry.retryAfter)
+
+		log.Warn().Err(err).
+			Str("alert_id", alert.ID).
+			Str("type", alert.Type).
+			Int("attempt", attempt+1).
+			Dur("retry_in", delay).
+			Msg("Alert delivery failed, retrying")
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-am.ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package core
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"5", 5 * time.Second},
+		{"0", 0},
+		{"-3", 0},
+		{"Thu, 01 Jan 2026 12:00:30 GMT", 30 * time.Second},
+		{"Thu, 01 Jan 2026 11:59:00 GMT", 0},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestAlertRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      map[string]interface{}
+		wantRetries int
+		wantDelay   time.Duration
+	}{
+		{"defaults", map[string]interface{}{}, defaultAlertRetries, defaultAlertRetryDelay},
+		{"configured", map[string]interface{}{"max_retries": float64(5), "retry_base_delay": "2s"}, 5, 2 * time.Second},
+		{"disabled", map[string]interface{}{"max_retries": float64(0)}, 0, defaultAlertRetryDelay},
+		{"too many retries", map[string]interface{}{"max_retries": float64(50)}, maxAlertRetries, defaultAlertRetryDelay},
+		{"negative retries", map[string]interface{}{"max_retries": float64(-1)}, defaultAlertRetries, defaultAlertRetryDelay},
+		{"retries as string", map[string]interface{}{"max_retries": "5"}, defaultAlertRetries, defaultAlertRetryDelay},
+		{"long delay", map[string]interface{}{"retry_base_delay": "5m"}, defaultAlertRetries, maxAlertRetryDelay},
+		{"invalid delay", map[string]interface{}{"retry_base_delay": "soon"}, defaultAlertRetries, defaultAlertRetryDelay},
+		{"zero delay", map[string]interface{}{"retry_base_delay": "0s"}, defaultAlertRetries, defaultAlertRetryDelay},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retries, delay := alertRetryPolicy(tt.config)
+			if retries != tt.wantRetries || delay != tt.wantDelay {
+				t.Errorf("alertRetryPolicy = %d, %s; want %d, %s", retries, delay, tt.wantRetries, tt.wantDelay)
+			}
+		})
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	tests := []struct {
+		status         int
+		retryAfter     string
+		wantRetryable  bool
+		wantRetryAfter time.Duration
+	}{
+		{http.StatusBadRequest, "", false, 0},
+		{http.StatusNotFound, "10", false, 0},
+		{http.StatusTooManyRequests, "", true, 0},
+		{http.StatusTooManyRequests, "10", true, 10 * time.Second},
+		{http.StatusInternalServerError, "", true, 0},
+		{http.StatusServiceUnavailable, "2", true, 2 * time.Second},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+		if tt.retryAfter != "" {
+			resp.Header.Set("Retry-After", tt.retryAfter)
+		}
+		cause := errors.New("rejected")
+
+		err := statusError(resp, cause)
+		if !errors.Is(err, cause) {
+			t.Errorf("status %d: error %v doesn't wrap the cause", tt.status, err)
+		}
+		var retry *retryableError
+		if isRetryable := errors.As(err, &retry); isRetryable != tt.wantRetryable {
+			t.Errorf("status %d: retryable = %v, want %v", tt.status, isRetryable, tt.wantRetryable)
+		} else if isRetryable && retry.retryAfter != tt.wantRetryAfter {
+			t.Errorf("status %d: retryAfter = %s, want %s", tt.status, retry.retryAfter, tt.wantRetryAfter)
+		}
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    map[string]interface{}
+		failures  int
+		status    int
+		header    string
+		wantErr   bool
+		wantCalls int32
+	}{
+		{"succeeds", nil, 0, 0, "", false, 1},
+		{"recovers", nil, 2, http.StatusServiceUnavailable, "", false, 3},
+		{"rate limited", nil, 1, http.StatusTooManyRequests, "", false, 2},
+		{"out of retries", nil, 10, http.StatusBadGateway, "", true, 4},
+		{"more retries", map[string]interface{}{"max_retries": float64(5)}, 5, http.StatusBadGateway, "", false, 6},
+		{"retries disabled", map[string]interface{}{"max_retries": float64(0)}, 1, http.StatusBadGateway, "", true, 1},
+		{"not retryable", nil, 1, http.StatusBadRequest, "", true, 1},
+		{"long Retry-After", nil, 1, http.StatusTooManyRequests, "120", true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if int(calls.Add(1)) <= tt.failures {
+					if tt.header != "" {
+						w.Header().Set("Retry-After", tt.header)
+					}
+					w.WriteHeader(tt.status)
+				}
+			}))
+			t.Cleanup(server.Close)
+			config := map[string]interface{}{"url": server.URL, "retry_base_delay": "1ms"}
+			for k, v := range tt.config {
+				config[k] = v
+			}
+			am := newTestAlertManager(t)
+
+			err := am.deliverAlert(&Alert{ID: "alert-1", Type: "webhook", Config: config}, crashEvent("app-1", "group-1", 1, time.Now()))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("deliverAlert = %v, want error %v", err, tt.wantErr)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("requests = %d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestWithRetryStopsOnClose(t *testing.T) {
+	am := newTestAlertManager(t)
+	alert := &Alert{ID: "alert-1", Type: "webhook", Config: map[string]interface{}{"retry_base_delay": "30s"}}
+	var calls int
+	send := func() error {
+		calls++
+		if calls == 1 {
+			am.Close()
+		}
+		return retryable(errors.New("connection refused"))
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- am.withRetry(alert, send) }()
+	select {
+	case err := <-done:
+		if err == nil || calls != 1 {
+			t.Errorf("withRetry = %v after %d calls, want the first error", err, calls)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("withRetry kept waiting after Close")
+	}
+}
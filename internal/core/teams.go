@@ -0,0 +1,129 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// teamsTextLimit bounds the error message and coalesced list shown in a
+// Teams card, keeping the payload well under Teams' 28 KB limit
+const teamsTextLimit = 4096
+
+// Card theme colors, matching the Slack attachment colors
+const (
+	teamsColorCrash    = "FF0000"
+	teamsColorNewGroup = "FF6600"
+)
+
+// sendTeams posts an event as a MessageCard to the alert's Microsoft Teams
+// incoming webhook_url
+func (am *AlertManager) sendTeams(alert *Alert, event AlertEvent) error {
+	webhookURL, _ := alert.Config["webhook_url"].(string)
+	if webhookURL == "" {
+		return fmt.Errorf("Teams webhook URL not configured")
+	}
+
+	color := teamsColorCrash
+	if event.IsNewGroup {
+		color = teamsColorNewGroup
+	}
+
+	title := fmt.Sprintf("Crash in %s", event.AppID)
+	if event.IsNewGroup {
+		title = fmt.Sprintf("🆕 NEW ERROR in %s", event.AppID)
+	}
+	if event.Threshold != nil {
+		title = fmt.Sprintf("📈 SPIKE in %s: %s", event.AppID, event.Threshold.Summary())
+	}
+	if event.Type == AlertEventRegression {
+		title = fmt.Sprintf("🔁 REGRESSION in %s (was %s)", event.AppID, event.PreviousStatus)
+	}
+	if event.Replay {
+		title = "[Replay] " + title
+	}
+
+	card := teamsCard(title, color)
+	card["sections"] = []map[string]interface{}{
+		{
+			"activityTitle": event.Crash.ErrorType,
+			"text":          TruncateField(event.Crash.ErrorMessage, teamsTextLimit),
+			"facts": []map[string]string{
+				{"name": "Error Type", "value": event.Crash.ErrorType},
+				{"name": "Occurrences", "value": fmt.Sprintf("%d", event.Group.OccurrenceCount)},
+				{"name": "Environment", "value": event.Crash.Environment},
+				{"name": "Platform", "value": event.Crash.Platform},
+				{"name": "App Version", "value": event.Crash.AppVersion},
+			},
+		},
+	}
+	if am.dashboardURL != "" && event.Crash.ID != "" {
+		card["potentialAction"] = []map[string]interface{}{
+			{
+				"@type": "OpenUri",
+				"name":  "View in Dashboard",
+				"targets": []map[string]string{
+					{"os": "default", "uri": am.dashboardURL + "/crashes/" + event.Crash.ID},
+				},
+			},
+		}
+	}
+
+	return am.postTeams(webhookURL, card)
+}
+
+// sendCoalescedTeams sends a batch of events as one Teams card
+func (am *AlertManager) sendCoalescedTeams(alert *Alert, events []AlertEvent, window time.Duration) error {
+	webhookURL, _ := alert.Config["webhook_url"].(string)
+	if webhookURL == "" {
+		return fmt.Errorf("Teams webhook URL not configured")
+	}
+
+	lines := make([]string, len(events))
+	for i, event := range events {
+		lines[i] = "- " + coalescedLine(event)
+	}
+
+	card := teamsCard(fmt.Sprintf("%s in %s", coalescedSummary(events, window), events[0].AppID), teamsColorNewGroup)
+	card["text"] = TruncateField(strings.Join(lines, "\n"), teamsTextLimit)
+	return am.postTeams(webhookURL, card)
+}
+
+// teamsCard returns a MessageCard with the fields Teams requires
+func teamsCard(title, color string) map[string]interface{} {
+	return map[string]interface{}{
+		"@type":      "MessageCard",
+		"@context":   "https://schema.org/extensions",
+		"themeColor": color,
+		"summary":    title,
+		"title":      title,
+	}
+}
+
+// postTeams posts a card to a Teams incoming webhook. Teams explains
+// rejected cards in the response body, which is included in the error.
+func (am *AlertManager) postTeams(webhookURL string, card map[string]interface{}) error {
+	body, err := json.Marshal(card)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	resp, err := am.client.Post(webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return retryable(fmt.Errorf("failed to send Teams message: %w", err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		if text := strings.TrimSpace(string(detail)); text != "" {
+			return statusError(resp, fmt.Errorf("Teams webhook returned status %d: %s", resp.StatusCode, text))
+		}
+		return statusError(resp, fmt.Errorf("Teams webhook returned status %d", resp.StatusCode))
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package core
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSendTeams(t *testing.T) {
+	at := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	event := func(modify func(e *AlertEvent)) AlertEvent {
+		e := crashEvent("app-1", "group-1", 7, at)
+		e.Crash.ErrorMessage = "Bad state"
+		e.Crash.Environment = "production"
+		if modify != nil {
+			modify(&e)
+		}
+		return e
+	}
+
+	tests := []struct {
+		name         string
+		dashboardURL string
+		event        AlertEvent
+		wantTitle    string
+		wantColor    string
+		wantLink     string
+	}{
+		{"crash", "", event(nil), "Crash in app-1", teamsColorCrash, ""},
+		{"new group", "", event(func(e *AlertEvent) { e.IsNewGroup = true }), "🆕 NEW ERROR in app-1", teamsColorNewGroup, ""},
+		{"threshold", "", event(func(e *AlertEvent) {
+			e.Threshold = &ThresholdCrossing{Count: 3, Window: time.Hour}
+		}), "📈 SPIKE in app-1: 3 crashes in the last 1h", teamsColorCrash, ""},
+		{"regression", "", event(func(e *AlertEvent) {
+			e.Type = AlertEventRegression
+			e.PreviousStatus = "ignored"
+		}), "🔁 REGRESSION in app-1 (was ignored)", teamsColorCrash, ""},
+		{"replay", "", event(func(e *AlertEvent) { e.Replay = true }), "[Replay] Crash in app-1", teamsColorCrash, ""},
+		{"dashboard link", "https://crashes.example.com/", event(nil), "Crash in app-1", teamsColorCrash, "https://crashes.example.com/crashes/crash-group-1"},
+		{"aggregated crash", "https://crashes.example.com", event(func(e *AlertEvent) { e.Crash.ID = "" }), "Crash in app-1", teamsColorCrash, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := newWebhookReceiver(t)
+			am := newTestAlertManager(t)
+			am.SetDashboardURL(tt.dashboardURL)
+			alert := &Alert{ID: "alert-1", Type: "teams", Config: map[string]interface{}{"webhook_url": receiver.URL}}
+
+			if err := am.sendTeams(alert, tt.event); err != nil {
+				t.Fatalf("sendTeams: %v", err)
+			}
+			received := receiver.received()
+			if len(received) != 1 {
+				t.Fatalf("requests = %d, want 1", len(received))
+			}
+			card := received[0].Payload
+			if card["@type"] != "MessageCard" || card["title"] != tt.wantTitle || card["summary"] != tt.wantTitle || card["themeColor"] != tt.wantColor {
+				t.Errorf("card = %v, want %q in %s", card, tt.wantTitle, tt.wantColor)
+			}
+
+			sections, _ := card["sections"].([]interface{})
+			if len(sections) != 1 {
+				t.Fatalf("sections = %v, want 1", card["sections"])
+			}
+			section := sections[0].(map[string]interface{})
+			if section["activityTitle"] != "StateError" || section["text"] != "Bad state" {
+				t.Errorf("section = %v", section)
+			}
+			facts := make(map[string]interface{})
+			for _, f := range section["facts"].([]interface{}) {
+				fact := f.(map[string]interface{})
+				facts[fact["name"].(string)] = fact["value"]
+			}
+			if facts["Occurrences"] != "7" || facts["Environment"] != "production" {
+				t.Errorf("facts = %v", facts)
+			}
+
+			var link string
+			if actions, ok := card["potentialAction"].([]interface{}); ok {
+				targets := actions[0].(map[string]interface{})["targets"].([]interface{})
+				link, _ = targets[0].(map[string]interface{})["uri"].(string)
+			}
+			if link != tt.wantLink {
+				t.Errorf("dashboard link = %q, want %q", link, tt.wantLink)
+			}
+		})
+	}
+}
+
+func TestSendTeamsErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		body          string
+		noURL         bool
+		wantErr       string
+		wantRetryable bool
+	}{
+		{"no webhook URL", 0, "", true, "Teams webhook URL not configured", false},
+		{"rejected card", http.StatusBadRequest, "Summary or Text is required.\n", false, "Teams webhook returned status 400: Summary or Text is required.", false},
+		{"rejected without detail", http.StatusForbidden, "", false, "Teams webhook returned status 403", false},
+		{"throttled", http.StatusTooManyRequests, "Too many requests", false, "Teams webhook returned status 429: Too many requests", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			t.Cleanup(server.Close)
+			am := newTestAlertManager(t)
+			alert := &Alert{ID: "alert-1", Type: "teams", Config: map[string]interface{}{"webhook_url": server.URL}}
+			if tt.noURL {
+				alert.Config = map[string]interface{}{}
+			}
+
+			err := am.sendTeams(alert, crashEvent("app-1", "group-1", 1, time.Now()))
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("sendTeams = %v, want %q", err, tt.wantErr)
+			}
+			var retry *retryableError
+			if isRetryable := errors.As(err, &retry); isRetryable != tt.wantRetryable {
+				t.Errorf("retryable = %v, want %v", isRetryable, tt.wantRetryable)
+			}
+		})
+	}
+}
+
+func TestSendCoalescedTeams(t *testing.T) {
+	receiver := newWebhookReceiver(t)
+	am := newTestAlertManager(t)
+	alert := &Alert{ID: "alert-1", Type: "teams", Config: map[string]interface{}{"webhook_url": receiver.URL}}
+	at := time.Now()
+	events := []AlertEvent{newGroupEvent("app-1", "group-1", at), newGroupEvent("app-1", "group-2", at), newGroupEvent("app-1", "group-3", at)}
+
+	if err := am.sendCoalescedTeams(alert, events, time.Minute); err != nil {
+		t.Fatalf("sendCoalescedTeams: %v", err)
+	}
+	received := receiver.received()
+	if len(received) != 1 {
+		t.Fatalf("requests = %d, want one for the batch", len(received))
+	}
+	card := received[0].Payload
+	if want := coalescedSummary(events, time.Minute) + " in app-1"; card["title"] != want || card["themeColor"] != teamsColorNewGroup {
+		t.Errorf("card = %v, want %q", card, want)
+	}
+	if lines := strings.Split(card["text"].(string), "\n"); len(lines) != 3 || !strings.HasPrefix(lines[2], "- ") {
+		t.Errorf("text = %q, want a line per event", card["text"])
+	}
+}
@@ -0,0 +1,211 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// telegramAPIURL is the Telegram Bot API. Alerts may override it with
+// api_url, e.g. for a self-hosted Bot API server.
+const telegramAPIURL = "https://api.telegram.org"
+
+// telegramMessageLimit is the longest message text Telegram accepts, in
+// characters
+const telegramMessageLimit = 4096
+
+// telegramErrorTypeLimit bounds the error type, leaving most of the message
+// for the error message
+const telegramErrorTypeLimit = 256
+
+// telegramMaxRetryAfter is the longest a rate-limited message waits before
+// its one retry; longer waits fail the alert instead of holding up the
+// alert queue
+const telegramMaxRetryAfter = 30 * time.Second
+
+// telegramSpecialChars are the characters MarkdownV2 requires escaping
+const telegramSpecialChars = "_*[]()~`>#+-=|{}.!\\"
+
+// sendTelegram sends an event as a message to a Telegram chat. Alert config:
+//
+//	"bot_token": "123456:ABC...", "chat_id": "-1001234567890"
+func (am *AlertManager) sendTelegram(alert *Alert, event AlertEvent) error {
+	title := fmt.Sprintf("Crash in %s", event.AppID)
+	if event.IsNewGroup {
+		title = fmt.Sprintf("🆕 NEW ERROR in %s", event.AppID)
+	}
+	if event.Threshold != nil {
+		title = fmt.Sprintf("📈 SPIKE in %s: %s", event.AppID, event.Threshold.Summary())
+	}
+	if event.Type == AlertEventRegression {
+		title = fmt.Sprintf("🔁 REGRESSION in %s (was %s)", event.AppID, event.PreviousStatus)
+	}
+	if event.Replay {
+		title = "[Replay] " + title
+	}
+
+	var header strings.Builder
+	fmt.Fprintf(&header, "*%s*\n\n", telegramEscape(title))
+	fmt.Fprintf(&header, "*Error Type:* `%s`\n", telegramEscapeCode(TruncateField(event.Crash.ErrorType, telegramErrorTypeLimit)))
+	fmt.Fprintf(&header, "*Occurrences:* %d\n", event.Group.OccurrenceCount)
+	fmt.Fprintf(&header, "*Environment:* %s\n", telegramEscape(event.Crash.Environment))
+	fmt.Fprintf(&header, "*Platform:* %s\n", telegramEscape(event.Crash.Platform))
+	fmt.Fprintf(&header, "*App Version:* %s\n\n", telegramEscape(event.Crash.AppVersion))
+
+	footer := ""
+	if am.dashboardURL != "" && event.Crash.ID != "" {
+		link := am.dashboardURL + "/crashes/" + event.Crash.ID
+		footer = fmt.Sprintf("\n\n[View in Dashboard](%s)", strings.NewReplacer(`\`, `\\`, ")", `\)`).Replace(link))
+	}
+
+	budget := max(telegramMessageLimit-utf8.RuneCountInString(header.String())-utf8.RuneCountInString(footer), 1)
+	text := header.String() + telegramEscapeLimit(event.Crash.ErrorMessage, budget) + footer
+	return am.postTelegram(alert.Config, text)
+}
+
+// sendCoalescedTelegram sends a batch of events as one Telegram message
+func (am *AlertManager) sendCoalescedTelegram(alert *Alert, events []AlertEvent, window time.Duration) error {
+	lines := make([]string, len(events))
+	for i, event := range events {
+		lines[i] = "• " + coalescedLine(event)
+	}
+
+	header := fmt.Sprintf("*%s*\n\n", telegramEscape(fmt.Sprintf("%s in %s", coalescedSummary(events, window), events[0].AppID)))
+	budget := max(telegramMessageLimit-utf8.RuneCountInString(header), 1)
+	return am.postTelegram(alert.Config, header+telegramEscapeLimit(strings.Join(lines, "\n"), budget))
+}
+
+// telegramEscape escapes text for MarkdownV2
+func telegramEscape(s string) string {
+	return telegramEscapeLimit(s, 0)
+}
+
+// telegramEscapeLimit escapes text for MarkdownV2, cutting it with an
+// ellipsis so the escaped text is at most limit characters. A limit of zero
+// disables it. Text is never cut inside an escape sequence.
+func telegramEscapeLimit(s string, limit int) string {
+	var b strings.Builder
+	n := 0
+	for i, r := range s {
+		width := 1
+		if strings.ContainsRune(telegramSpecialChars, r) {
+			width = 2
+		}
+		// Leave room for the ellipsis unless this is the last rune
+		room := limit - n
+		if i+utf8.RuneLen(r) < len(s) {
+			room--
+		}
+		if limit > 0 && width > room {
+			if limit-n >= 1 {
+				b.WriteRune('…')
+			}
+			break
+		}
+		if width == 2 {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+		n += width
+	}
+	return b.String()
+}
+
+// telegramEscapeCode escapes text for a MarkdownV2 code span
+func telegramEscapeCode(s string) string {
+	return strings.NewReplacer("`", "\\`", `\`, `\\`).Replace(s)
+}
+
+// postTelegram sends a MarkdownV2 message to the alert's chat. A message
+// Telegram rate limits is retried once after the retry_after it asks for,
+// if that is at most telegramMaxRetryAfter.
+func (am *AlertManager) postTelegram(config map[string]interface{}, text string) error {
+	token, _ := config["bot_token"].(string)
+	if token == "" {
+		return fmt.Errorf("Telegram bot token not configured")
+	}
+	var chatID string
+	switch id := config["chat_id"].(type) {
+	case string:
+		chatID = id
+	case float64:
+		chatID = strconv.FormatInt(int64(id), 10)
+	}
+	if chatID == "" {
+		return fmt.Errorf("Telegram chat ID not configured")
+	}
+	apiURL := telegramAPIURL
+	if u, ok := config["api_url"].(string); ok && u != "" {
+		apiURL = strings.TrimSuffix(u, "/")
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"chat_id":                  chatID,
+		"text":                     text,
+		"parse_mode":               "MarkdownV2",
+		"disable_web_page_preview": true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	endpoint := apiURL + "/bot" + token + "/sendMessage"
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := am.postTelegramOnce(endpoint, body)
+		if retryAfter == 0 || attempt > 0 {
+			return err
+		}
+		if retryAfter > telegramMaxRetryAfter {
+			return fmt.Errorf("%w; not retrying after %s", err, retryAfter)
+		}
+		select {
+		case <-am.ctx.Done():
+			return err
+		case <-time.After(retryAfter):
+		}
+	}
+}
+
+// postTelegramOnce sends one sendMessage request. When Telegram rate limits
+// it, the returned duration is how long it asked to wait.
+func (am *AlertManager) postTelegramOnce(endpoint string, body []byte) (time.Duration, error) {
+	resp, err := am.client.Post(endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// The bot token is part of the URL, so keep it out of the error
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return 0, fmt.Errorf("failed to send Telegram message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 400 {
+		return 0, nil
+	}
+
+	var result struct {
+		Description string `json:"description"`
+		Parameters  struct {
+			RetryAfter int `json:"retry_after"`
+		} `json:"parameters"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	json.Unmarshal(data, &result)
+
+	err = fmt.Errorf("Telegram returned status %d", resp.StatusCode)
+	if result.Description != "" {
+		err = fmt.Errorf("Telegram returned status %d: %s", resp.StatusCode, result.Description)
+	}
+	if resp.StatusCode == 429 {
+		return max(time.Duration(result.Parameters.RetryAfter)*time.Second, time.Second), err
+	}
+	return 0, err
+}
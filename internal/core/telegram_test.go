@@ -0,0 +1,181 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestTelegramEscapeLimit(t *testing.T) {
+	tests := []struct {
+		s     string
+		limit int
+		want  string
+	}{
+		{"Bad state: no element", 0, "Bad state: no element"},
+		{"v1.2.3 (beta)", 0, `v1\.2\.3 \(beta\)`},
+		{`a_b*c[d]e~f>g#h+i-j=k|l{m}n!o\p`, 0, `a\_b\*c\[d\]e\~f\>g\#h\+i\-j\=k\|l\{m\}n\!o\\p`},
+		{"abcdef", 6, "abcdef"},
+		{"abcdefg", 6, "abcde…"},
+		// An escape sequence is never cut in half
+		{"abcd.ef", 6, "abcd…"},
+		{"abc.", 5, `abc\.`},
+		{"ünïcödé", 4, "ünï…"},
+		{"abc", 1, "…"},
+	}
+	for _, tt := range tests {
+		got := telegramEscapeLimit(tt.s, tt.limit)
+		if got != tt.want {
+			t.Errorf("telegramEscapeLimit(%q, %d) = %q, want %q", tt.s, tt.limit, got, tt.want)
+		}
+		if tt.limit > 0 && utf8.RuneCountInString(got) > tt.limit {
+			t.Errorf("telegramEscapeLimit(%q, %d) is %d characters", tt.s, tt.limit, utf8.RuneCountInString(got))
+		}
+	}
+
+	if got := telegramEscapeCode("Foo`Bar\\Baz"); got != "Foo\\`Bar\\\\Baz" {
+		t.Errorf("telegramEscapeCode = %q", got)
+	}
+}
+
+func TestSendTelegram(t *testing.T) {
+	at := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	event := func(modify func(e *AlertEvent)) AlertEvent {
+		e := crashEvent("app-1", "group-1", 7, at)
+		e.Crash.ErrorMessage = "Bad state."
+		e.Crash.AppVersion = "1.0.0"
+		if modify != nil {
+			modify(&e)
+		}
+		return e
+	}
+
+	tests := []struct {
+		name         string
+		chatID       interface{}
+		dashboardURL string
+		event        AlertEvent
+		wantChat     string
+		wantText     []string
+	}{
+		{"crash", "-1001234567890", "", event(nil), "-1001234567890", []string{"*Crash in app\\-1*\n\n", "*Error Type:* `StateError`\n", "*Occurrences:* 7\n", "*App Version:* 1\\.0\\.0\n\n", "Bad state\\."}},
+		{"numeric chat ID", float64(-1001234567890), "", event(nil), "-1001234567890", []string{"*Crash in app\\-1*"}},
+		{"new group", "42", "", event(func(e *AlertEvent) { e.IsNewGroup = true }), "42", []string{"*🆕 NEW ERROR in app\\-1*"}},
+		{"regression", "42", "", event(func(e *AlertEvent) {
+			e.Type = AlertEventRegression
+			e.PreviousStatus = "resolved"
+		}), "42", []string{"*🔁 REGRESSION in app\\-1 \\(was resolved\\)*"}},
+		{"replay", "42", "", event(func(e *AlertEvent) { e.Replay = true }), "42", []string{"*\\[Replay\\] Crash in app\\-1*"}},
+		{"dashboard link", "42", "https://crashes.example.com", event(nil), "42", []string{"\n\n[View in Dashboard](https://crashes.example.com/crashes/crash-group-1)"}},
+		{"long message", "42", "https://crashes.example.com", event(func(e *AlertEvent) { e.Crash.ErrorMessage = strings.Repeat("x.", 5000) }), "42", []string{"…\n\n[View in Dashboard]"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := newWebhookReceiver(t)
+			am := newTestAlertManager(t)
+			am.SetDashboardURL(tt.dashboardURL)
+			alert := &Alert{ID: "alert-1", Type: "telegram", Config: map[string]interface{}{
+				"bot_token": "123:abc", "chat_id": tt.chatID, "api_url": receiver.URL + "/",
+			}}
+
+			if err := am.sendTelegram(alert, tt.event); err != nil {
+				t.Fatalf("sendTelegram: %v", err)
+			}
+			received := receiver.received()
+			if len(received) != 1 || received[0].Path != "/bot123:abc/sendMessage" {
+				t.Fatalf("requests = %v, want one to sendMessage", receiver.paths())
+			}
+			payload := received[0].Payload
+			if payload["chat_id"] != tt.wantChat || payload["parse_mode"] != "MarkdownV2" {
+				t.Errorf("chat_id, parse_mode = %v, %v; want %s, MarkdownV2", payload["chat_id"], payload["parse_mode"], tt.wantChat)
+			}
+			text, _ := payload["text"].(string)
+			for _, want := range tt.wantText {
+				if !strings.Contains(text, want) {
+					t.Errorf("text = %q, want it to contain %q", text, want)
+				}
+			}
+			if n := utf8.RuneCountInString(text); n > telegramMessageLimit {
+				t.Errorf("text is %d characters, over the %d limit", n, telegramMessageLimit)
+			}
+		})
+	}
+}
+
+func TestSendTelegramErrors(t *testing.T) {
+	// telegramServer answers with each of responses in turn, then 200
+	telegramServer := func(responses ...string) (*httptest.Server, *atomic.Int32) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := int(calls.Add(1))
+			if n > len(responses) {
+				return
+			}
+			var result struct {
+				ErrorCode int `json:"error_code"`
+			}
+			json.Unmarshal([]byte(responses[n-1]), &result)
+			w.WriteHeader(result.ErrorCode)
+			w.Write([]byte(responses[n-1]))
+		}))
+		t.Cleanup(server.Close)
+		return server, &calls
+	}
+	const rateLimited = `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 1","parameters":{"retry_after":1}}`
+
+	tests := []struct {
+		name      string
+		config    map[string]interface{}
+		responses []string
+		wantErr   string
+		wantCalls int32
+	}{
+		{"no bot token", map[string]interface{}{"chat_id": "42"}, nil, "Telegram bot token not configured", 0},
+		{"no chat ID", map[string]interface{}{"bot_token": "123:abc"}, nil, "Telegram chat ID not configured", 0},
+		{"rejected", nil, []string{`{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`}, "Telegram returned status 400: Bad Request: chat not found", 1},
+		{"rate limited once", nil, []string{rateLimited}, "", 2},
+		{"rate limited twice", nil, []string{rateLimited, rateLimited}, "Telegram returned status 429: Too Many Requests: retry after 1", 2},
+		{"long wait", nil, []string{`{"ok":false,"error_code":429,"description":"Too Many Requests","parameters":{"retry_after":120}}`}, "Telegram returned status 429: Too Many Requests; not retrying after 2m0s", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, calls := telegramServer(tt.responses...)
+			config := tt.config
+			if config == nil {
+				config = map[string]interface{}{"bot_token": "123:abc", "chat_id": "42"}
+			}
+			config["api_url"] = server.URL
+			am := newTestAlertManager(t)
+
+			err := am.sendTelegram(&Alert{ID: "alert-1", Type: "telegram", Config: config}, crashEvent("app-1", "group-1", 1, time.Now()))
+			if got := errorString(err); got != tt.wantErr {
+				t.Errorf("sendTelegram = %q, want %q", got, tt.wantErr)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("requests = %d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+
+	// The bot token is part of the URL, so it is kept out of connection errors
+	server, _ := telegramServer()
+	server.Close()
+	am := newTestAlertManager(t)
+	err := am.sendTelegram(&Alert{Type: "telegram", Config: map[string]interface{}{"bot_token": "123:secret-token", "chat_id": "42", "api_url": server.URL}}, crashEvent("app-1", "group-1", 1, time.Now()))
+	if err == nil || strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("connection error = %v, want one without the bot token", err)
+	}
+}
+
+// errorString returns err's message, or "" for nil
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
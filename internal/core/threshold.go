@@ -0,0 +1,230 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// maxThresholdWindow is the longest window or cooldown a threshold condition
+// may use; groups are evaluated for this long after their last crash
+const maxThresholdWindow = 24 * time.Hour
+
+// ThresholdRepository defines the database operations needed to evaluate
+// threshold conditions
+type ThresholdRepository interface {
+	CountCrashesInWindow(ctx context.Context, groupID string, since time.Time) (int, error)
+}
+
+// ThresholdCrossing describes a group that went over an alert's threshold
+type ThresholdCrossing struct {
+	// AlertID is the alert whose condition was crossed; the event is only
+	// sent to that alert
+	AlertID   string
+	Count     int
+	Threshold int
+	Window    time.Duration
+}
+
+// Summary describes the crossing, e.g. "57 crashes in the last 10m"
+func (t *ThresholdCrossing) Summary() string {
+	return fmt.Sprintf("%d crashes in the last %s", t.Count, formatWindow(t.Window))
+}
+
+// thresholdCondition is a parsed threshold condition
+type thresholdCondition struct {
+	count    int
+	window   time.Duration
+	cooldown time.Duration
+}
+
+// parseThresholdCondition reads an alert's threshold condition, e.g.
+//
+//	"threshold": {"count": 50, "window": "10m", "cooldown": "1h"}
+//
+// It fires when a group has at least count stored crashes within the
+// sliding window. window defaults to 10m and cooldown to the window; both
+// are capped at maxThresholdWindow.
+func parseThresholdCondition(conditions map[string]interface{}) (thresholdCondition, bool) {
+	raw, ok := conditions["threshold"].(map[string]interface{})
+	if !ok {
+		return thresholdCondition{}, false
+	}
+	count, _ := raw["count"].(float64)
+	if count < 1 {
+		return thresholdCondition{}, false
+	}
+
+	cond := thresholdCondition{count: int(count), window: 10 * time.Minute}
+	if w, ok := raw["window"].(string); ok {
+		if d, err := time.ParseDuration(w); err == nil && d > 0 {
+			cond.window = d
+		}
+	}
+	cond.window = min(cond.window, maxThresholdWindow)
+	cond.cooldown = cond.window
+	if c, ok := raw["cooldown"].(string); ok {
+		if d, err := time.ParseDuration(c); err == nil && d >= 0 {
+			cond.cooldown = min(d, maxThresholdWindow)
+		}
+	}
+	return cond, true
+}
+
+// thresholdState tracks one alert's threshold condition for one group
+type thresholdState struct {
+	// above is set while the group's count is at or over the threshold
+	above   bool
+	firedAt time.Time
+}
+
+// StartThresholdEvaluator checks threshold conditions every interval,
+// counting crashes in repo, until the manager is closed
+func (am *AlertManager) StartThresholdEvaluator(repo ThresholdRepository, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-am.ctx.Done():
+				return
+			case now := <-ticker.C:
+				am.evaluateThresholds(repo, now)
+			}
+		}
+	}()
+	log.Info().Dur("interval", interval).Msg("Alert threshold evaluator started")
+}
+
+// recordRecentGroup remembers the latest crash event of a group, making
+// the group a candidate for threshold conditions
+func (am *AlertManager) recordRecentGroup(event AlertEvent) {
+	am.stateMu.Lock()
+	defer am.stateMu.Unlock()
+	if last, ok := am.recentGroups[event.Group.ID]; !ok || !event.Crash.CreatedAt.Before(last.Crash.CreatedAt) {
+		am.recentGroups[event.Group.ID] = event
+	}
+}
+
+// pruneRecentGroups forgets groups without a crash since before, along with
+// their threshold state
+func (am *AlertManager) pruneRecentGroups(before time.Time) {
+	am.stateMu.Lock()
+	defer am.stateMu.Unlock()
+	for groupID, event := range am.recentGroups {
+		if event.Crash.CreatedAt.Before(before) {
+			delete(am.recentGroups, groupID)
+		}
+	}
+	for key, state := range am.thresholdStates {
+		if _, ok := am.recentGroups[key.groupID]; !ok && state.firedAt.Before(before) {
+			delete(am.thresholdStates, key)
+		}
+	}
+}
+
+// thresholdKey identifies one alert's threshold state for one group
+type thresholdKey struct {
+	alertID, groupID string
+}
+
+// evaluateThresholds counts the crashes of every recently crashing group
+// over each threshold alert's window and queues an event for the groups
+// that crossed it
+func (am *AlertManager) evaluateThresholds(repo ThresholdRepository, now time.Time) {
+	am.alertsMu.RLock()
+	alerts := make([]*Alert, len(am.alerts))
+	copy(alerts, am.alerts)
+	am.alertsMu.RUnlock()
+
+	am.stateMu.Lock()
+	groups := make([]AlertEvent, 0, len(am.recentGroups))
+	for _, event := range am.recentGroups {
+		groups = append(groups, event)
+	}
+	am.stateMu.Unlock()
+
+	// Alerts sharing a window share one count per group
+	type countKey struct {
+		groupID string
+		window  time.Duration
+	}
+	counts := make(map[countKey]int)
+
+	for _, alert := range alerts {
+		if !alert.Enabled {
+			continue
+		}
+		conditions, _ := alert.Config["conditions"].(map[string]interface{})
+		cond, ok := parseThresholdCondition(conditions)
+		if !ok {
+			continue
+		}
+
+		for _, event := range groups {
+			if alert.AppID != "" && alert.AppID != event.AppID {
+				continue
+			}
+
+			since := now.Add(-cond.window)
+			key := countKey{event.Group.ID, cond.window}
+			count, counted := counts[key]
+			if !counted && !event.Crash.CreatedAt.Before(since) {
+				var err error
+				count, err = repo.CountCrashesInWindow(am.ctx, event.Group.ID, since)
+				if err != nil {
+					log.Error().Err(err).Str("group_id", event.Group.ID).Msg("Failed to count crashes for threshold alert")
+					continue
+				}
+			}
+			counts[key] = count
+
+			if !am.thresholdCrossed(thresholdKey{alert.ID, event.Group.ID}, count >= cond.count, now, cond.cooldown) {
+				continue
+			}
+			log.Info().
+				Str("alert_id", alert.ID).
+				Str("group_id", event.Group.ID).
+				Int("count", count).
+				Dur("window", cond.window).
+				Msg("Crash threshold crossed")
+			am.Notify(AlertEvent{
+				Type:  AlertEventThreshold,
+				AppID: event.AppID,
+				Crash: event.Crash,
+				Group: event.Group,
+				Threshold: &ThresholdCrossing{
+					AlertID:   alert.ID,
+					Count:     count,
+					Threshold: cond.count,
+					Window:    cond.window,
+				},
+			})
+		}
+	}
+}
+
+// thresholdCrossed updates a threshold state with whether the group is now
+// at or over the threshold, reporting true when the condition should fire:
+// the group has just gone over, and the condition last fired at least
+// cooldown ago. A group that stays over the threshold fires once; it must
+// drop below it before it can fire again.
+func (am *AlertManager) thresholdCrossed(key thresholdKey, above bool, now time.Time, cooldown time.Duration) bool {
+	am.stateMu.Lock()
+	defer am.stateMu.Unlock()
+
+	state := am.thresholdStates[key]
+	wasAbove := state.above
+	state.above = above
+	fire := above && !wasAbove && (state.firedAt.IsZero() || now.Sub(state.firedAt) >= cooldown)
+	if fire {
+		state.firedAt = now
+	}
+	am.thresholdStates[key] = state
+	return fire
+}
@@ -0,0 +1,192 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseThresholdCondition(t *testing.T) {
+	tests := []struct {
+		name       string
+		conditions map[string]interface{}
+		want       thresholdCondition
+		wantOK     bool
+	}{
+		{"none", map[string]interface{}{"on_new_group": true}, thresholdCondition{}, false},
+		{"no count", map[string]interface{}{"threshold": map[string]interface{}{"window": "5m"}}, thresholdCondition{}, false},
+		{"zero count", map[string]interface{}{"threshold": map[string]interface{}{"count": float64(0)}}, thresholdCondition{}, false},
+		{"defaults", map[string]interface{}{"threshold": map[string]interface{}{"count": float64(50)}}, thresholdCondition{50, 10 * time.Minute, 10 * time.Minute}, true},
+		{"window and cooldown", map[string]interface{}{"threshold": map[string]interface{}{"count": float64(5), "window": "1m", "cooldown": "1h"}}, thresholdCondition{5, time.Minute, time.Hour}, true},
+		{"cooldown follows the window", map[string]interface{}{"threshold": map[string]interface{}{"count": float64(5), "window": "30m"}}, thresholdCondition{5, 30 * time.Minute, 30 * time.Minute}, true},
+		{"no cooldown", map[string]interface{}{"threshold": map[string]interface{}{"count": float64(5), "cooldown": "0s"}}, thresholdCondition{5, 10 * time.Minute, 0}, true},
+		{"invalid durations", map[string]interface{}{"threshold": map[string]interface{}{"count": float64(5), "window": "soon", "cooldown": "-1m"}}, thresholdCondition{5, 10 * time.Minute, 10 * time.Minute}, true},
+		{"capped", map[string]interface{}{"threshold": map[string]interface{}{"count": float64(5), "window": "72h", "cooldown": "168h"}}, thresholdCondition{5, maxThresholdWindow, maxThresholdWindow}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseThresholdCondition(tt.conditions)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("parseThresholdCondition = %+v, %v; want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+// fakeThresholdRepository counts crashes from the times recorded per group
+type fakeThresholdRepository struct {
+	crashes map[string][]time.Time
+}
+
+func (r *fakeThresholdRepository) CountCrashesInWindow(ctx context.Context, groupID string, since time.Time) (int, error) {
+	count := 0
+	for _, at := range r.crashes[groupID] {
+		if !at.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func TestEvaluateThresholds(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	// step records crashes of group-1 at the given minutes, then evaluates
+	// the conditions at minute evalAt
+	type step struct {
+		crashes []int
+		evalAt  int
+	}
+
+	tests := []struct {
+		name      string
+		appID     string
+		enabled   bool
+		threshold map[string]interface{}
+		steps     []step
+		want      int
+	}{
+		{
+			name:      "crossed",
+			enabled:   true,
+			threshold: map[string]interface{}{"count": float64(3), "window": "10m"},
+			steps:     []step{{[]int{0, 1, 2}, 3}},
+			want:      1,
+		},
+		{
+			name:      "staying over fires once",
+			enabled:   true,
+			threshold: map[string]interface{}{"count": float64(3), "window": "10m"},
+			steps:     []step{{[]int{0, 1, 2}, 3}, {[]int{4, 5}, 6}, {nil, 9}},
+			want:      1,
+		},
+		{
+			name:      "crossed again after dropping below",
+			enabled:   true,
+			threshold: map[string]interface{}{"count": float64(3), "window": "10m"},
+			steps:     []step{{[]int{0, 1, 2}, 3}, {nil, 20}, {[]int{21, 22, 23}, 24}},
+			want:      2,
+		},
+		{
+			name:      "held back by the cooldown",
+			enabled:   true,
+			threshold: map[string]interface{}{"count": float64(3), "window": "10m", "cooldown": "1h"},
+			steps:     []step{{[]int{0, 1, 2}, 3}, {nil, 20}, {[]int{21, 22, 23}, 24}},
+			want:      1,
+		},
+		{
+			name:      "below the count",
+			enabled:   true,
+			threshold: map[string]interface{}{"count": float64(3), "window": "10m"},
+			steps:     []step{{[]int{0, 1}, 2}, {[]int{15}, 16}},
+			want:      0,
+		},
+		{
+			name:      "other app",
+			appID:     "app-2",
+			enabled:   true,
+			threshold: map[string]interface{}{"count": float64(3), "window": "10m"},
+			steps:     []step{{[]int{0, 1, 2}, 3}},
+			want:      0,
+		},
+		{
+			name:      "disabled",
+			threshold: map[string]interface{}{"count": float64(3), "window": "10m"},
+			steps:     []step{{[]int{0, 1, 2}, 3}},
+			want:      0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := newWebhookReceiver(t)
+			am := NewAlertManager(SMTPConfig{}, "")
+			am.SetAlerts([]*Alert{{ID: "alert-1", AppID: tt.appID, Type: "webhook", Enabled: tt.enabled, Config: map[string]interface{}{
+				"url":        receiver.URL,
+				"conditions": map[string]interface{}{"threshold": tt.threshold},
+			}}})
+			repo := &fakeThresholdRepository{crashes: make(map[string][]time.Time)}
+
+			for _, s := range tt.steps {
+				for _, m := range s.crashes {
+					at := start.Add(time.Duration(m) * time.Minute)
+					repo.crashes["group-1"] = append(repo.crashes["group-1"], at)
+					am.recordRecentGroup(crashEvent("app-1", "group-1", len(repo.crashes["group-1"]), at))
+				}
+				am.evaluateThresholds(repo, start.Add(time.Duration(s.evalAt)*time.Minute))
+			}
+			if err := am.Shutdown(context.Background()); err != nil {
+				t.Fatalf("Shutdown: %v", err)
+			}
+
+			received := receiver.received()
+			if len(received) != tt.want {
+				t.Fatalf("threshold alerts = %d, want %d", len(received), tt.want)
+			}
+			for _, req := range received {
+				if req.Header.Get("X-Inceptor-Threshold-Count") != "3" || req.Header.Get("X-Inceptor-Threshold-Window") != "10m" {
+					t.Errorf("threshold headers = %q, %q; want 3, 10m",
+						req.Header.Get("X-Inceptor-Threshold-Count"), req.Header.Get("X-Inceptor-Threshold-Window"))
+				}
+			}
+		})
+	}
+}
+
+func TestPruneRecentGroups(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	am := newTestAlertManager(t)
+
+	am.recordRecentGroup(crashEvent("app-1", "recent", 1, now))
+	am.recordRecentGroup(crashEvent("app-1", "quiet", 1, now.Add(-2*maxThresholdWindow)))
+	// An older event doesn't replace a group's latest one
+	am.recordRecentGroup(crashEvent("app-1", "recent", 1, now.Add(-2*maxThresholdWindow)))
+	am.thresholdCrossed(thresholdKey{"alert-1", "quiet"}, true, now.Add(-2*maxThresholdWindow), 0)
+	am.thresholdCrossed(thresholdKey{"alert-1", "fired"}, true, now, 0)
+
+	am.pruneRecentGroups(now.Add(-maxThresholdWindow))
+
+	am.stateMu.Lock()
+	defer am.stateMu.Unlock()
+	if _, ok := am.recentGroups["recent"]; !ok || len(am.recentGroups) != 1 {
+		t.Errorf("recent groups = %v, want only recent", am.recentGroups)
+	}
+	// A state that fired within the window is kept for its cooldown
+	if _, ok := am.thresholdStates[thresholdKey{"alert-1", "fired"}]; !ok || len(am.thresholdStates) != 1 {
+		t.Errorf("threshold states = %v, want only the recently fired one", am.thresholdStates)
+	}
+}
+
+func TestThresholdSummary(t *testing.T) {
+	tests := []struct {
+		crossing ThresholdCrossing
+		want     string
+	}{
+		{ThresholdCrossing{Count: 57, Window: 10 * time.Minute}, "57 crashes in the last 10m"},
+		{ThresholdCrossing{Count: 3, Window: 2 * time.Hour}, "3 crashes in the last 2h"},
+		{ThresholdCrossing{Count: 8, Window: 90 * time.Second}, "8 crashes in the last 1m30s"},
+	}
+	for _, tt := range tests {
+		if got := tt.crossing.Summary(); got != tt.want {
+			t.Errorf("Summary() = %q, want %q", got, tt.want)
+		}
+	}
+}
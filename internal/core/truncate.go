@@ -0,0 +1,57 @@
+package core
+
+import "unicode/utf8"
+
+// truncationMarker is appended to fields shortened by FieldLimits
+const truncationMarker = "..."
+
+// FieldLimits bounds the length (in bytes) of crash fields kept in the index
+// and used for fingerprinting. Zero means unlimited.
+type FieldLimits struct {
+	ErrorType    int
+	ErrorMessage int
+	FrameField   int
+}
+
+// Truncated returns a copy of crash with oversized fields shortened. The
+// original is left untouched so its full content can still be stored in the
+// crash payload file.
+func (l FieldLimits) Truncated(crash *Crash) *Crash {
+	c := *crash
+	c.ErrorType = TruncateField(c.ErrorType, l.ErrorType)
+	c.ErrorMessage = TruncateField(c.ErrorMessage, l.ErrorMessage)
+
+	if l.FrameField > 0 && len(c.StackTrace) > 0 {
+		c.StackTrace = make([]StackFrame, len(crash.StackTrace))
+		for i, frame := range crash.StackTrace {
+			c.StackTrace[i] = l.TruncateFrame(frame)
+		}
+	}
+
+	return &c
+}
+
+// TruncateFrame shortens the text fields of a stack frame
+func (l FieldLimits) TruncateFrame(frame StackFrame) StackFrame {
+	frame.FileName = TruncateField(frame.FileName, l.FrameField)
+	frame.MethodName = TruncateField(frame.MethodName, l.FrameField)
+	frame.ClassName = TruncateField(frame.ClassName, l.FrameField)
+	return frame
+}
+
+// TruncateField shortens s to at most max bytes including the truncation
+// marker, without splitting a UTF-8 sequence. A max of zero disables it.
+func TruncateField(s string, max int) string {
+	if max <= 0 || len(s) <= max {
+		return s
+	}
+	if max <= len(truncationMarker) {
+		return truncationMarker[:max]
+	}
+
+	cut := max - len(truncationMarker)
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + truncationMarker
+}
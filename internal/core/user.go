@@ -0,0 +1,48 @@
+package core
+
+import "time"
+
+// User is a dashboard account
+type User struct {
+	ID           string   `json:"id"`
+	Username     string   `json:"username"`
+	PasswordHash string   `json:"-"`
+	Role         UserRole `json:"role"`
+	// MustChangePassword is set while the account has the default password
+	MustChangePassword bool      `json:"must_change_password"`
+	CreatedAt          time.Time `json:"created_at"`
+}
+
+// UserRole is what a dashboard user may do
+type UserRole string
+
+const (
+	// UserRoleAdmin has full access, as with the admin API key
+	UserRoleAdmin UserRole = "admin"
+	// UserRoleViewer can read everything but change nothing
+	UserRoleViewer UserRole = "viewer"
+)
+
+// Valid reports whether the role is a known one
+func (r UserRole) Valid() bool {
+	return r == UserRoleAdmin || r == UserRoleViewer
+}
+
+// Session is a logged-in dashboard user's session
+type Session struct {
+	// Token is only known when the session is created; stores keep
+	// TokenHash so a leaked store can't be used to log in
+	Token     string
+	TokenHash string
+	// UserID is empty for proxy users without an account
+	UserID    string
+	Username  string
+	Role      UserRole
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// IsAdmin reports whether the session's user may change things
+func (s *Session) IsAdmin() bool {
+	return s.Role == UserRoleAdmin
+}
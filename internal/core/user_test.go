@@ -0,0 +1,37 @@
+package core
+
+import "testing"
+
+func TestUserRoleValid(t *testing.T) {
+	tests := []struct {
+		role UserRole
+		want bool
+	}{
+		{UserRoleAdmin, true},
+		{UserRoleViewer, true},
+		{"", false},
+		{"Admin", false},
+		{"owner", false},
+	}
+	for _, tt := range tests {
+		if got := tt.role.Valid(); got != tt.want {
+			t.Errorf("UserRole(%q).Valid() = %v, want %v", tt.role, got, tt.want)
+		}
+	}
+}
+
+func TestSessionIsAdmin(t *testing.T) {
+	tests := []struct {
+		role UserRole
+		want bool
+	}{
+		{UserRoleAdmin, true},
+		{UserRoleViewer, false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := (&Session{Role: tt.role}).IsAdmin(); got != tt.want {
+			t.Errorf("IsAdmin with role %q = %v, want %v", tt.role, got, tt.want)
+		}
+	}
+}
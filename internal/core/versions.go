@@ -0,0 +1,90 @@
+package core
+
+import "sort"
+
+// VersionComparison contrasts the crash groups seen in two app versions
+type VersionComparison struct {
+	Base             string `json:"base"`
+	Candidate        string `json:"candidate"`
+	BaseCrashes      int    `json:"base_crashes"`
+	CandidateCrashes int    `json:"candidate_crashes"`
+	// NewGroups have crashes in the candidate but not the base
+	NewGroups []ErrorSummary `json:"new_groups"`
+	// ResolvedGroups have crashes in the base but not the candidate
+	ResolvedGroups []ErrorSummary `json:"resolved_groups"`
+	// SharedGroups appear in both versions, largest change first
+	SharedGroups []GroupDelta `json:"shared_groups"`
+}
+
+// GroupDelta is the change in a group's crash count between two versions
+type GroupDelta struct {
+	GroupID        string `json:"group_id"`
+	ErrorType      string `json:"error_type"`
+	ErrorMessage   string `json:"error_message"`
+	BaseCount      int    `json:"base_count"`
+	CandidateCount int    `json:"candidate_count"`
+	Delta          int    `json:"delta"`
+}
+
+// CompareVersions builds a VersionComparison from per-group crash counts
+// for each version
+func CompareVersions(base, candidate string, baseCounts, candidateCounts []ErrorSummary) *VersionComparison {
+	cmp := &VersionComparison{
+		Base:           base,
+		Candidate:      candidate,
+		NewGroups:      []ErrorSummary{},
+		ResolvedGroups: []ErrorSummary{},
+		SharedGroups:   []GroupDelta{},
+	}
+
+	inBase := make(map[string]ErrorSummary, len(baseCounts))
+	for _, s := range baseCounts {
+		inBase[s.GroupID] = s
+		cmp.BaseCrashes += s.Count
+	}
+
+	inCandidate := make(map[string]bool, len(candidateCounts))
+	for _, s := range candidateCounts {
+		inCandidate[s.GroupID] = true
+		cmp.CandidateCrashes += s.Count
+
+		b, shared := inBase[s.GroupID]
+		if !shared {
+			cmp.NewGroups = append(cmp.NewGroups, s)
+			continue
+		}
+		cmp.SharedGroups = append(cmp.SharedGroups, GroupDelta{
+			GroupID:        s.GroupID,
+			ErrorType:      s.ErrorType,
+			ErrorMessage:   s.ErrorMessage,
+			BaseCount:      b.Count,
+			CandidateCount: s.Count,
+			Delta:          s.Count - b.Count,
+		})
+	}
+
+	for _, s := range baseCounts {
+		if !inCandidate[s.GroupID] {
+			cmp.ResolvedGroups = append(cmp.ResolvedGroups, s)
+		}
+	}
+
+	sort.SliceStable(cmp.NewGroups, func(i, j int) bool {
+		return cmp.NewGroups[i].Count > cmp.NewGroups[j].Count
+	})
+	sort.SliceStable(cmp.ResolvedGroups, func(i, j int) bool {
+		return cmp.ResolvedGroups[i].Count > cmp.ResolvedGroups[j].Count
+	})
+	sort.SliceStable(cmp.SharedGroups, func(i, j int) bool {
+		return abs(cmp.SharedGroups[i].Delta) > abs(cmp.SharedGroups[j].Delta)
+	})
+
+	return cmp
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
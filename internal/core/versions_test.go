@@ -0,0 +1,61 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	summary := func(groupID string, count int) ErrorSummary {
+		return ErrorSummary{GroupID: groupID, ErrorType: "Error" + groupID, Count: count}
+	}
+	delta := func(groupID string, base, candidate int) GroupDelta {
+		return GroupDelta{GroupID: groupID, ErrorType: "Error" + groupID, BaseCount: base, CandidateCount: candidate, Delta: candidate - base}
+	}
+
+	tests := []struct {
+		name      string
+		base      []ErrorSummary
+		candidate []ErrorSummary
+		want      VersionComparison
+	}{
+		{
+			name: "no crashes",
+			want: VersionComparison{NewGroups: []ErrorSummary{}, ResolvedGroups: []ErrorSummary{}, SharedGroups: []GroupDelta{}},
+		},
+		{
+			name:      "new, resolved and shared",
+			base:      []ErrorSummary{summary("a", 5), summary("b", 1), summary("c", 4)},
+			candidate: []ErrorSummary{summary("a", 2), summary("d", 3), summary("e", 7)},
+			want: VersionComparison{
+				BaseCrashes:      10,
+				CandidateCrashes: 12,
+				NewGroups:        []ErrorSummary{summary("e", 7), summary("d", 3)},
+				ResolvedGroups:   []ErrorSummary{summary("c", 4), summary("b", 1)},
+				SharedGroups:     []GroupDelta{delta("a", 5, 2)},
+			},
+		},
+		{
+			name:      "shared by largest change",
+			base:      []ErrorSummary{summary("a", 10), summary("b", 2), summary("c", 3)},
+			candidate: []ErrorSummary{summary("a", 9), summary("b", 8), summary("c", 3)},
+			want: VersionComparison{
+				BaseCrashes:      15,
+				CandidateCrashes: 20,
+				NewGroups:        []ErrorSummary{},
+				ResolvedGroups:   []ErrorSummary{},
+				SharedGroups:     []GroupDelta{delta("b", 2, 8), delta("a", 10, 9), delta("c", 3, 3)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.want.Base, tt.want.Candidate = "2.0.0", "2.1.0"
+			got := CompareVersions("2.0.0", "2.1.0", tt.base, tt.candidate)
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("CompareVersions() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+// Package dsym stores uploaded Apple dSYM debug files and uses them to
+// symbolicate raw address frames in iOS crashes.
+package dsym
+
+import (
+	"archive/zip"
+	"bytes"
+	"debug/macho"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrNoDebugInfo is returned for uploads that contain no Mach-O files with
+// DWARF debug info
+var ErrNoDebugInfo = errors.New("no DWARF debug info found")
+
+// ErrTooLarge is returned when a zip archive expands past the size limit
+var ErrTooLarge = errors.New("archive contents exceed the size limit")
+
+// loadCmdUUID is the Mach-O LC_UUID load command
+const loadCmdUUID = 0x1b
+
+// dwarfDir is where a .dSYM bundle keeps its DWARF files
+const dwarfDir = "Contents/Resources/DWARF/"
+
+// Image is the debug info for one architecture of a binary, identified by
+// the build UUID that crash reports list for the binary
+type Image struct {
+	UUID string `json:"uuid"`
+	Arch string `json:"arch"`
+	Name string `json:"name"`
+	Size int    `json:"size_bytes"`
+
+	data []byte
+}
+
+// NormalizeUUID returns a build UUID in the uppercase, hyphenated form used
+// by dwarfdump and crash reports, or "" if it isn't a UUID
+func NormalizeUUID(uuid string) string {
+	raw, err := hex.DecodeString(strings.ReplaceAll(strings.TrimSpace(uuid), "-", ""))
+	if err != nil || len(raw) != 16 {
+		return ""
+	}
+	return formatUUID(raw)
+}
+
+func formatUUID(raw []byte) string {
+	s := strings.ToUpper(hex.EncodeToString(raw))
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:32]
+}
+
+// Parse extracts the per-architecture debug images from an upload: a DWARF
+// Mach-O file (thin or universal) from inside a .dSYM bundle, or a zip
+// archive of one or more .dSYM bundles. name is the uploaded file's name,
+// used as the binary name for bare Mach-O files. maxExtracted bounds the
+// bytes read out of a zip archive (0 = unlimited).
+func Parse(data []byte, name string, maxExtracted int64) ([]Image, error) {
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return parseZip(data, maxExtracted)
+	}
+	name = strings.TrimSuffix(path.Base(filepath.ToSlash(name)), ".dSYM")
+	return parseMachO(data, name)
+}
+
+// parseZip reads the DWARF files of every .dSYM bundle in a zip archive
+func parseZip(data []byte, maxExtracted int64) ([]Image, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid zip archive: %w", err)
+	}
+
+	var images []Image
+	for _, file := range archive.File {
+		dir, name := path.Split(file.Name)
+		if name == "" || !strings.HasSuffix(dir, dwarfDir) || file.FileInfo().IsDir() {
+			continue
+		}
+		// Skip the resource forks Finder adds to the archives it creates
+		if strings.HasPrefix(file.Name, "__MACOSX/") || strings.HasPrefix(name, "._") {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file.Name, err)
+		}
+		// Don't trust the declared size of an entry
+		reader := io.Reader(rc)
+		if maxExtracted > 0 {
+			reader = io.LimitReader(rc, maxExtracted+1)
+		}
+		content, err := io.ReadAll(reader)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file.Name, err)
+		}
+		if maxExtracted > 0 {
+			if maxExtracted -= int64(len(content)); maxExtracted < 0 {
+				return nil, ErrTooLarge
+			}
+		}
+		found, err := parseMachO(content, name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file.Name, err)
+		}
+		images = append(images, found...)
+	}
+	if len(images) == 0 {
+		return nil, ErrNoDebugInfo
+	}
+	return images, nil
+}
+
+// parseMachO splits a thin or universal Mach-O file into its architectures
+func parseMachO(data []byte, name string) ([]Image, error) {
+	fat, err := macho.NewFatFile(bytes.NewReader(data))
+	if err == nil {
+		defer fat.Close()
+		images := make([]Image, 0, len(fat.Arches))
+		for _, arch := range fat.Arches {
+			end := uint64(arch.Offset) + uint64(arch.Size)
+			if end > uint64(len(data)) {
+				return nil, fmt.Errorf("truncated universal binary")
+			}
+			image, err := parseThin(data[arch.Offset:end], name)
+			if err != nil {
+				return nil, err
+			}
+			images = append(images, image)
+		}
+		return images, nil
+	}
+	if !errors.Is(err, macho.ErrNotFat) {
+		return nil, fmt.Errorf("not a Mach-O file: %w", err)
+	}
+
+	image, err := parseThin(data, name)
+	if err != nil {
+		return nil, err
+	}
+	return []Image{image}, nil
+}
+
+// parseThin reads the UUID of a single-architecture Mach-O file and checks
+// that it has debug info
+func parseThin(data []byte, name string) (Image, error) {
+	f, err := macho.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, fmt.Errorf("not a Mach-O file: %w", err)
+	}
+	defer f.Close()
+
+	image := Image{Arch: archName(f.Cpu), Name: name, Size: len(data), data: data}
+	for _, load := range f.Loads {
+		raw := load.Raw()
+		if len(raw) >= 24 && f.ByteOrder.Uint32(raw) == loadCmdUUID {
+			image.UUID = formatUUID(raw[8:24])
+			break
+		}
+	}
+	if image.UUID == "" {
+		return Image{}, fmt.Errorf("%s (%s) has no UUID", name, image.Arch)
+	}
+	if f.Section("__debug_info") == nil && f.Section("__zdebug_info") == nil {
+		return Image{}, fmt.Errorf("%s (%s): %w", name, image.Arch, ErrNoDebugInfo)
+	}
+	return image, nil
+}
+
+// archName returns the architecture name Apple tools use for a CPU type
+func archName(cpu macho.Cpu) string {
+	switch cpu {
+	case macho.CpuArm64:
+		return "arm64"
+	case macho.CpuAmd64:
+		return "x86_64"
+	case macho.CpuArm:
+		return "armv7"
+	case macho.Cpu386:
+		return "i386"
+	}
+	return strings.ToLower(strings.TrimPrefix(cpu.String(), "Cpu"))
+}
+
+// Store keeps uploaded debug images on disk, one file per app and UUID
+type Store struct {
+	basePath string
+}
+
+// NewStore creates a store in basePath, creating the directory if needed
+func NewStore(basePath string) (*Store, error) {
+	if err := os.MkdirAll(basePath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create dSYM directory: %w", err)
+	}
+	return &Store{basePath: basePath}, nil
+}
+
+func (s *Store) path(appID, uuid string) string {
+	return filepath.Join(s.basePath, appID, uuid)
+}
+
+// Save writes an app's debug images, replacing any with the same UUID
+func (s *Store) Save(appID string, images []Image) error {
+	dir := filepath.Join(s.basePath, appID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	for _, image := range images {
+		// Write then rename, so a symbolication never reads a partial file
+		tmp, err := os.CreateTemp(dir, ".upload-*")
+		if err != nil {
+			return fmt.Errorf("failed to create file: %w", err)
+		}
+		_, err = tmp.Write(image.data)
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(tmp.Name(), s.path(appID, image.UUID))
+		}
+		if err != nil {
+			os.Remove(tmp.Name())
+			return fmt.Errorf("failed to write dSYM %s: %w", image.UUID, err)
+		}
+	}
+	return nil
+}
+
+// StoredImage describes a debug image kept in a Store
+type StoredImage struct {
+	UUID       string
+	Size       int64
+	UploadedAt time.Time
+}
+
+// List returns the debug images uploaded for an app
+func (s *Store) List(appID string) ([]StoredImage, error) {
+	entries, err := os.ReadDir(filepath.Join(s.basePath, appID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var images []StoredImage
+	for _, entry := range entries {
+		// Skip uploads still being written
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		images = append(images, StoredImage{UUID: entry.Name(), Size: info.Size(), UploadedAt: info.ModTime()})
+	}
+	return images, nil
+}
+
+// Delete removes an app's debug image, reporting whether it existed
+func (s *Store) Delete(appID, uuid string) (bool, error) {
+	if NormalizeUUID(uuid) != uuid {
+		return false, nil
+	}
+	err := os.Remove(s.path(appID, uuid))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	// Drop the app's directory once it is empty
+	os.Remove(filepath.Join(s.basePath, appID))
+	return true, nil
+}
+
+// open opens the Mach-O file for an app's debug image, or returns nil if
+// none was uploaded
+func (s *Store) open(appID, uuid string) (*macho.File, error) {
+	f, err := macho.Open(s.path(appID, uuid))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return f, err
+}
@@ -0,0 +1,278 @@
+package dsym
+
+import (
+	"archive/zip"
+	"bytes"
+	"debug/dwarf"
+	"debug/macho"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/flakerimi/inceptor/internal/dsym/dsymtest"
+)
+
+const (
+	appUUID    = "6A3B1C2D-0000-4000-8000-00000000A001"
+	widgetUUID = "6A3B1C2D-0000-4000-8000-00000000A002"
+)
+
+// appImage is the dSYM of a small app binary:
+//
+//	0x1000-0x1100 HomeViewController.viewDidLoad, line 10
+//	0x1100-0x1200 main, line 20, with helper inlined at 0x1140-0x1180,
+//	              lines 30 and 25 after it
+//	0x1200-0x1300 Store.save, defined outside its struct, line 40
+func appImage(uuid string) dsymtest.Image {
+	return dsymtest.Image{
+		Cpu:  macho.CpuArm64,
+		UUID: uuid,
+		File: "/src/MyApp/Home.swift",
+		Lines: []dsymtest.Line{
+			{Offset: 0x1000, Line: 10},
+			{Offset: 0x1100, Line: 20},
+			{Offset: 0x1140, Line: 30},
+			{Offset: 0x1180, Line: 25},
+			{Offset: 0x1200, Line: 40},
+			{Offset: 0x1300},
+		},
+		DIEs: []dsymtest.DIE{
+			{Tag: dwarf.TagClassType, Name: "HomeViewController", Children: []dsymtest.DIE{
+				{Tag: dwarf.TagSubprogram, Name: "viewDidLoad", Low: 0x1000, High: 0x1100},
+			}},
+			{Tag: dwarf.TagStructType, Name: "Store", Children: []dsymtest.DIE{
+				{Tag: dwarf.TagSubprogram, Name: "save", ID: "save"},
+			}},
+			{Tag: dwarf.TagSubprogram, Name: "helper", ID: "helper"},
+			{Tag: dwarf.TagSubprogram, Name: "main", Low: 0x1100, High: 0x1200, Children: []dsymtest.DIE{
+				{Tag: dwarf.TagInlinedSubroutine, Origin: "helper", Low: 0x1140, High: 0x1180},
+			}},
+			{Tag: dwarf.TagSubprogram, Spec: "save", Low: 0x1200, High: 0x1300},
+		},
+	}
+}
+
+// zipFiles returns a zip archive of the given files
+func zipFiles(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("zip %s: %v", name, err)
+		}
+		f.Write(files[name])
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestNormalizeUUID(t *testing.T) {
+	tests := []struct {
+		uuid, want string
+	}{
+		{"6A3B1C2D-0000-4000-8000-00000000A001", appUUID},
+		{"6a3b1c2d00004000800000000000a001", appUUID},
+		{" 6a3b1c2d-0000-4000-8000-00000000a001 ", appUUID},
+		{"6A3B1C2D-0000-4000-8000", ""},
+		{"not-a-uuid", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeUUID(tt.uuid); got != tt.want {
+			t.Errorf("NormalizeUUID(%q) = %q, want %q", tt.uuid, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	app := dsymtest.MachO(appImage(appUUID))
+	x86 := appImage(widgetUUID)
+	x86.Cpu = macho.CpuAmd64
+	widget := appImage(widgetUUID)
+	noDebugInfo := appImage(appUUID)
+	noDebugInfo.NoDebugInfo = true
+	noUUID := appImage("")
+
+	tests := []struct {
+		name         string
+		data         []byte
+		fileName     string
+		maxExtracted int64
+		// want is the UUID, arch and name of each image
+		want [][3]string
+		// wantErr is a sentinel error, or an error with the same message
+		wantErr error
+	}{
+		{
+			name:     "thin",
+			data:     app,
+			fileName: "MyApp",
+			want:     [][3]string{{appUUID, "arm64", "MyApp"}},
+		},
+		{
+			name:     "bundle path",
+			data:     app,
+			fileName: "build/MyApp.dSYM",
+			want:     [][3]string{{appUUID, "arm64", "MyApp"}},
+		},
+		{
+			name:     "universal",
+			data:     dsymtest.Fat(app, dsymtest.MachO(x86)),
+			fileName: "MyApp",
+			want:     [][3]string{{appUUID, "arm64", "MyApp"}, {widgetUUID, "x86_64", "MyApp"}},
+		},
+		{
+			name: "zip of bundles",
+			data: zipFiles(t, map[string][]byte{
+				"MyApp.app.dSYM/Contents/Info.plist":                       []byte("<plist/>"),
+				"MyApp.app.dSYM/Contents/Resources/DWARF/MyApp":            app,
+				"Widget.appex.dSYM/Contents/Resources/DWARF/Widget":        dsymtest.MachO(widget),
+				"Widget.appex.dSYM/Contents/Resources/Relocations/x":       []byte("ignored"),
+				"__MACOSX/MyApp.app.dSYM/Contents/Resources/DWARF/._MyApp": []byte("fork"),
+			}),
+			want: [][3]string{{appUUID, "arm64", "MyApp"}, {widgetUUID, "arm64", "Widget"}},
+		},
+		{
+			name:    "zip without bundles",
+			data:    zipFiles(t, map[string][]byte{"MyApp": app}),
+			wantErr: ErrNoDebugInfo,
+		},
+		{
+			name:         "zip too large",
+			data:         zipFiles(t, map[string][]byte{"MyApp.app.dSYM/Contents/Resources/DWARF/MyApp": app}),
+			maxExtracted: int64(len(app) - 1),
+			wantErr:      ErrTooLarge,
+		},
+		{
+			name:         "zip within limit",
+			data:         zipFiles(t, map[string][]byte{"MyApp.app.dSYM/Contents/Resources/DWARF/MyApp": app}),
+			maxExtracted: int64(len(app)),
+			want:         [][3]string{{appUUID, "arm64", "MyApp"}},
+		},
+		{
+			name:     "no debug info",
+			data:     dsymtest.MachO(noDebugInfo),
+			fileName: "MyApp",
+			wantErr:  ErrNoDebugInfo,
+		},
+		{
+			name:     "no UUID",
+			data:     dsymtest.MachO(noUUID),
+			fileName: "MyApp",
+			wantErr:  errors.New("no UUID"),
+		},
+		{
+			name:     "not Mach-O",
+			data:     []byte("hello"),
+			fileName: "MyApp",
+			wantErr:  errors.New("not a Mach-O file"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			images, err := Parse(tt.data, tt.fileName, tt.maxExtracted)
+			if tt.wantErr != nil {
+				if err == nil || !errors.Is(err, tt.wantErr) && !strings.Contains(err.Error(), tt.wantErr.Error()) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			var got [][3]string
+			for _, image := range images {
+				got = append(got, [3]string{image.UUID, image.Arch, image.Name})
+				if image.Size != len(image.data) || image.Size == 0 {
+					t.Errorf("%s: size %d for %d bytes", image.UUID, image.Size, len(image.data))
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("images = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	images, err := Parse(dsymtest.Fat(dsymtest.MachO(appImage(appUUID)), func() []byte {
+		x86 := appImage(widgetUUID)
+		x86.Cpu = macho.CpuAmd64
+		return dsymtest.MachO(x86)
+	}()), "MyApp", 0)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if err := store.Save("app-1", images); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	// Saving again replaces the files
+	if err := store.Save("app-1", images); err != nil {
+		t.Fatalf("Save again: %v", err)
+	}
+
+	listed := func(appID string) []string {
+		stored, err := store.List(appID)
+		if err != nil {
+			t.Fatalf("List(%s): %v", appID, err)
+		}
+		var uuids []string
+		for _, image := range stored {
+			uuids = append(uuids, image.UUID)
+		}
+		slices.Sort(uuids)
+		return uuids
+	}
+	if got := listed("app-1"); !slices.Equal(got, []string{appUUID, widgetUUID}) {
+		t.Errorf("app-1 images = %v", got)
+	}
+	if got := listed("app-2"); got != nil {
+		t.Errorf("app-2 images = %v, want none", got)
+	}
+
+	// An upload in progress isn't listed
+	if err := os.WriteFile(filepath.Join(dir, "app-1", ".upload-1"), []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := listed("app-1"); len(got) != 2 {
+		t.Errorf("app-1 images = %v, want the partial upload skipped", got)
+	}
+
+	tests := []struct {
+		name, appID, uuid string
+		want              bool
+	}{
+		{"other app", "app-2", appUUID, false},
+		{"not normalized", "app-1", "6a3b1c2d00004000800000000000a001", false},
+		{"path traversal", "app-1", "../app-1/" + appUUID, false},
+		{"uploaded", "app-1", appUUID, true},
+		{"already deleted", "app-1", appUUID, false},
+	}
+	for _, tt := range tests {
+		if deleted, err := store.Delete(tt.appID, tt.uuid); err != nil || deleted != tt.want {
+			t.Errorf("%s: Delete = %v, %v; want %v", tt.name, deleted, err, tt.want)
+		}
+	}
+	if got := listed("app-1"); !slices.Equal(got, []string{widgetUUID}) {
+		t.Errorf("app-1 images after delete = %v", got)
+	}
+}
@@ -0,0 +1,302 @@
+// Package dsymtest builds minimal dSYM Mach-O files for tests: a __TEXT
+// segment, an LC_UUID and hand-assembled DWARF line and function info.
+package dsymtest
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"debug/macho"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+)
+
+// TextAddr is the __TEXT segment address of built images; DWARF addresses
+// are TextAddr plus the offsets given in an Image
+const TextAddr = 0x100000000
+
+// Line maps the offsets from Offset up to the next row to a source line
+type Line struct {
+	Offset uint64
+	Line   int
+}
+
+// DIE is a debug entry of an image's compile unit
+type DIE struct {
+	Tag  dwarf.Tag
+	Name string
+	// ID names the entry for Spec and Origin of other entries
+	ID string
+	// Low and High are the entry's offset range; there is none when High
+	// is zero
+	Low, High uint64
+	// Spec and Origin are the IDs of the entries referenced by
+	// DW_AT_specification and DW_AT_abstract_origin
+	Spec, Origin string
+	Children     []DIE
+}
+
+// Image describes one architecture of a dSYM
+type Image struct {
+	Cpu  macho.Cpu
+	UUID string
+	// File is the source file of every line row
+	File string
+	// Lines are the line rows; the sequence ends at the last one's offset
+	Lines []Line
+	DIEs  []DIE
+	// NoDebugInfo leaves out the DWARF sections
+	NoDebugInfo bool
+}
+
+// MachO returns a thin Mach-O dSYM file for image
+func MachO(image Image) []byte {
+	var sections [][2]string
+	var contents [][]byte
+	if !image.NoDebugInfo {
+		abbrev, info := image.info()
+		sections = [][2]string{{"__debug_abbrev", "__DWARF"}, {"__debug_info", "__DWARF"}, {"__debug_line", "__DWARF"}}
+		contents = [][]byte{abbrev, info, image.lineProgram()}
+	}
+
+	le := binary.LittleEndian
+	var cmds bytes.Buffer
+	ncmds := uint32(0)
+	if image.UUID != "" {
+		raw, _ := hex.DecodeString(strings.ReplaceAll(image.UUID, "-", ""))
+		binary.Write(&cmds, le, [2]uint32{0x1b, 24})
+		cmds.Write(raw)
+		ncmds++
+	}
+
+	segment := func(name string, addr, size uint64, nsects int) {
+		binary.Write(&cmds, le, [2]uint32{uint32(macho.LoadCmdSegment64), uint32(72 + 80*nsects)})
+		cmds.Write(fixed(name))
+		binary.Write(&cmds, le, [4]uint64{addr, size, 0, 0})
+		binary.Write(&cmds, le, [4]uint32{5, 5, uint32(nsects), 0})
+	}
+	segment("__TEXT", TextAddr, 0x100000, 0)
+	ncmds++
+	if len(sections) > 0 {
+		segment("__DWARF", 0, 0, len(sections))
+		ncmds++
+	}
+
+	// Section contents follow the header and load commands
+	const headerSize = 32
+	offset := headerSize + cmds.Len() + 80*len(sections)
+	for i, section := range sections {
+		cmds.Write(fixed(section[0]))
+		cmds.Write(fixed(section[1]))
+		binary.Write(&cmds, le, [2]uint64{0, uint64(len(contents[i]))})
+		binary.Write(&cmds, le, [8]uint32{uint32(offset)})
+		offset += len(contents[i])
+	}
+
+	var out bytes.Buffer
+	binary.Write(&out, le, [8]uint32{uint32(macho.Magic64), uint32(image.Cpu), 0, typeDSYM, ncmds, uint32(cmds.Len())})
+	out.Write(cmds.Bytes())
+	for _, content := range contents {
+		out.Write(content)
+	}
+	return out.Bytes()
+}
+
+// Fat returns a universal Mach-O file of thin files built by MachO
+func Fat(thin ...[]byte) []byte {
+	const align = 0x1000
+	be := binary.BigEndian
+	var out bytes.Buffer
+	binary.Write(&out, be, [2]uint32{macho.MagicFat, uint32(len(thin))})
+	offset := align
+	for _, file := range thin {
+		cpu := binary.LittleEndian.Uint32(file[4:])
+		binary.Write(&out, be, [5]uint32{cpu, 0, uint32(offset), uint32(len(file)), 12})
+		offset += (len(file) + align - 1) / align * align
+	}
+	for _, file := range thin {
+		out.Write(make([]byte, (align-out.Len()%align)%align))
+		out.Write(file)
+	}
+	return out.Bytes()
+}
+
+// typeDSYM is the MH_DSYM file type
+const typeDSYM = 0xa
+
+// fixed returns a 16 byte, zero padded Mach-O name
+func fixed(name string) []byte {
+	b := make([]byte, 16)
+	copy(b, name)
+	return b
+}
+
+// DWARF forms and attributes used in the debug info
+const (
+	formAddr      = 0x01
+	formData8     = 0x07
+	formString    = 0x08
+	formRef4      = 0x13
+	formSecOffset = 0x17
+)
+
+// info returns the .debug_abbrev and .debug_info of the image's compile
+// unit. Each entry gets its own abbreviation.
+func (image Image) info() ([]byte, []byte) {
+	le := binary.LittleEndian
+	var abbrev, body bytes.Buffer
+	code := uint64(0)
+	ids := map[string]uint32{}
+	type patch struct {
+		at int
+		id string
+	}
+	var patches []patch
+
+	// Offsets into the unit count its 11 byte header
+	const unitHeader = 11
+	var write func(tag dwarf.Tag, die DIE, children []DIE, unit bool)
+	write = func(tag dwarf.Tag, die DIE, children []DIE, unit bool) {
+		code++
+		if die.ID != "" {
+			ids[die.ID] = uint32(unitHeader + body.Len())
+		}
+		abbrev.Write(uleb(code))
+		abbrev.Write(uleb(uint64(tag)))
+		if len(children) > 0 {
+			abbrev.WriteByte(1)
+		} else {
+			abbrev.WriteByte(0)
+		}
+		body.Write(uleb(code))
+
+		attr := func(at dwarf.Attr, form uint64) {
+			abbrev.Write(uleb(uint64(at)))
+			abbrev.Write(uleb(form))
+		}
+		if die.Name != "" {
+			attr(dwarf.AttrName, formString)
+			body.WriteString(die.Name)
+			body.WriteByte(0)
+		}
+		if unit {
+			attr(dwarf.AttrStmtList, formSecOffset)
+			binary.Write(&body, le, uint32(0))
+		}
+		if die.High > 0 {
+			attr(dwarf.AttrLowpc, formAddr)
+			binary.Write(&body, le, TextAddr+die.Low)
+			// A constant high_pc is the length of the range
+			attr(dwarf.AttrHighpc, formData8)
+			binary.Write(&body, le, die.High-die.Low)
+		}
+		for _, ref := range []struct {
+			at dwarf.Attr
+			id string
+		}{{dwarf.AttrSpecification, die.Spec}, {dwarf.AttrAbstractOrigin, die.Origin}} {
+			if ref.id != "" {
+				attr(ref.at, formRef4)
+				patches = append(patches, patch{body.Len(), ref.id})
+				binary.Write(&body, le, uint32(0))
+			}
+		}
+		abbrev.Write([]byte{0, 0})
+
+		for _, child := range children {
+			write(child.Tag, child, child.Children, false)
+		}
+		if len(children) > 0 {
+			body.WriteByte(0)
+		}
+	}
+
+	unit := DIE{Name: image.File}
+	if n := len(image.Lines); n > 0 {
+		unit.Low, unit.High = image.Lines[0].Offset, image.Lines[n-1].Offset
+	}
+	write(dwarf.TagCompileUnit, unit, image.DIEs, true)
+	abbrev.WriteByte(0)
+
+	data := body.Bytes()
+	for _, p := range patches {
+		le.PutUint32(data[p.at:], ids[p.id])
+	}
+
+	var info bytes.Buffer
+	binary.Write(&info, le, uint32(unitHeader-4+len(data)))
+	binary.Write(&info, le, uint16(4)) // version
+	binary.Write(&info, le, uint32(0)) // abbrev offset
+	info.WriteByte(8)                  // address size
+	info.Write(data)
+	return abbrev.Bytes(), info.Bytes()
+}
+
+// lineProgram returns a version 4 .debug_line program of the image's rows
+func (image Image) lineProgram() []byte {
+	le := binary.LittleEndian
+
+	var header bytes.Buffer
+	header.Write([]byte{1, 1, 1, 0xfb, 14, 13})              // min inst length, max ops, default is_stmt, line base, line range, opcode base
+	header.Write([]byte{0, 1, 1, 1, 1, 0, 0, 0, 1, 0, 0, 1}) // standard opcode lengths
+	header.WriteByte(0)                                      // no include directories
+	header.WriteString(image.File)
+	header.Write([]byte{0, 0, 0, 0}) // directory, mtime, length
+	header.WriteByte(0)
+
+	var program bytes.Buffer
+	setAddress := func(offset uint64) {
+		program.Write([]byte{0, 9, 2})
+		binary.Write(&program, le, TextAddr+offset)
+	}
+	line := 1
+	for i, row := range image.Lines {
+		setAddress(row.Offset)
+		if i == len(image.Lines)-1 {
+			program.Write([]byte{0, 1, 1}) // end sequence
+			break
+		}
+		program.WriteByte(3) // advance line
+		program.Write(sleb(int64(row.Line - line)))
+		line = row.Line
+		program.WriteByte(1) // copy
+	}
+
+	var out bytes.Buffer
+	binary.Write(&out, le, uint32(2+4+header.Len()+program.Len()))
+	binary.Write(&out, le, uint16(4))
+	binary.Write(&out, le, uint32(header.Len()))
+	out.Write(header.Bytes())
+	out.Write(program.Bytes())
+	return out.Bytes()
+}
+
+func uleb(v uint64) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v != 0 {
+			c |= 0x80
+		}
+		b = append(b, c)
+		if v == 0 {
+			return b
+		}
+	}
+}
+
+func sleb(v int64) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		done := v == 0 && c&0x40 == 0 || v == -1 && c&0x40 != 0
+		if !done {
+			c |= 0x80
+		}
+		b = append(b, c)
+		if done {
+			return b
+		}
+	}
+}
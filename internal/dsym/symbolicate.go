@@ -0,0 +1,399 @@
+package dsym
+
+import (
+	"debug/dwarf"
+	"debug/macho"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/flakerimi/inceptor/internal/core"
+)
+
+// symbolTable resolves addresses in one debug image, like atos
+type symbolTable struct {
+	// textAddr is the __TEXT segment's address; frame offsets are relative
+	// to it
+	textAddr uint64
+	lines    []lineRow  // sorted by address
+	funcs    []function // sorted by low address
+}
+
+// lineRow maps the addresses from addr up to the next row to a source line
+type lineRow struct {
+	addr   uint64
+	file   string
+	line   int
+	column int
+	// end marks the end of a sequence; its addresses have no line
+	end bool
+}
+
+// function is an address range of a function, with the ranges of functions
+// inlined into it in DWARF order, so later (deeper) ranges win
+type function struct {
+	low, high uint64
+	name      string
+	class     string
+	inlined   []function
+}
+
+// symbol is where an address resolved to
+type symbol struct {
+	function, class, file string
+	line, column          int
+}
+
+// dieName is what a debug entry says about a function's name, or where to
+// look for it
+type dieName struct {
+	name, class string
+	ref         dwarf.Offset // DW_AT_specification or DW_AT_abstract_origin
+}
+
+// loadSymbolTable reads the line table and function ranges of a dSYM
+func loadSymbolTable(f *macho.File) (*symbolTable, error) {
+	text := f.Segment("__TEXT")
+	if text == nil {
+		return nil, fmt.Errorf("no __TEXT segment")
+	}
+	data, err := f.DWARF()
+	if err != nil {
+		return nil, err
+	}
+
+	table := &symbolTable{textAddr: text.Addr}
+	names := make(map[dwarf.Offset]dieName)
+	// pendingFunc is a function range whose name is resolved once every
+	// entry has been read, since references can point forward
+	type pendingFunc struct {
+		fn      function
+		offset  dwarf.Offset
+		inlined []*pendingFunc
+	}
+	var funcs, inlined []*pendingFunc
+
+	// parents tracks the enclosing entries, to find a function's type and
+	// the ranges an inlined call belongs to
+	type parent struct {
+		tag    dwarf.Tag
+		name   string
+		ranges []*pendingFunc
+	}
+	var parents []parent
+	enclosing := func() (class string, ranges []*pendingFunc) {
+		if len(parents) > 0 {
+			ranges = parents[len(parents)-1].ranges
+		}
+		for i := len(parents) - 1; i >= 0 && class == ""; i-- {
+			switch parents[i].tag {
+			case dwarf.TagClassType, dwarf.TagStructType, dwarf.TagUnionType, dwarf.TagEnumerationType:
+				class = parents[i].name
+			}
+		}
+		return class, ranges
+	}
+
+	reader := data.Reader()
+	for {
+		entry, err := reader.Next()
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			break
+		}
+		if entry.Tag == 0 {
+			if len(parents) > 0 {
+				parents = parents[:len(parents)-1]
+			}
+			continue
+		}
+
+		name, _ := entry.Val(dwarf.AttrName).(string)
+		var ranges []*pendingFunc
+		switch entry.Tag {
+		case dwarf.TagCompileUnit:
+			if err := table.readLines(data, entry); err != nil {
+				return nil, err
+			}
+		case dwarf.TagSubprogram, dwarf.TagInlinedSubroutine:
+			class, owners := enclosing()
+			info := dieName{name: name, class: class}
+			if ref, ok := entry.Val(dwarf.AttrSpecification).(dwarf.Offset); ok {
+				info.ref = ref
+			} else if ref, ok := entry.Val(dwarf.AttrAbstractOrigin).(dwarf.Offset); ok {
+				info.ref = ref
+			}
+			names[entry.Offset] = info
+
+			pcs, err := data.Ranges(entry)
+			if err != nil {
+				break
+			}
+			for _, pc := range pcs {
+				pending := &pendingFunc{fn: function{low: pc[0], high: pc[1]}, offset: entry.Offset}
+				ranges = append(ranges, pending)
+				if entry.Tag == dwarf.TagSubprogram || owners == nil {
+					funcs = append(funcs, pending)
+					continue
+				}
+				inlined = append(inlined, pending)
+				for _, owner := range owners {
+					if pc[0] >= owner.fn.low && pc[0] < owner.fn.high {
+						owner.inlined = append(owner.inlined, pending)
+						break
+					}
+				}
+			}
+		}
+		if entry.Children {
+			if entry.Tag != dwarf.TagSubprogram && len(parents) > 0 {
+				// Inlined calls, lexical blocks and the like belong to their
+				// function, so nested inlined calls are attached to it too
+				ranges = parents[len(parents)-1].ranges
+			}
+			parents = append(parents, parent{tag: entry.Tag, name: name, ranges: ranges})
+		}
+	}
+
+	resolve := func(offset dwarf.Offset) (string, string) {
+		// Follow specification and abstract origin links to the declaration
+		for i := 0; i < 8; i++ {
+			info, ok := names[offset]
+			if !ok {
+				return "", ""
+			}
+			if info.name != "" || info.ref == 0 {
+				return info.name, info.class
+			}
+			offset = info.ref
+		}
+		return "", ""
+	}
+	for _, pending := range inlined {
+		pending.fn.name, pending.fn.class = resolve(pending.offset)
+	}
+	for _, pending := range funcs {
+		pending.fn.name, pending.fn.class = resolve(pending.offset)
+		for _, inner := range pending.inlined {
+			pending.fn.inlined = append(pending.fn.inlined, inner.fn)
+		}
+		table.funcs = append(table.funcs, pending.fn)
+	}
+
+	sort.SliceStable(table.lines, func(i, j int) bool { return table.lines[i].addr < table.lines[j].addr })
+	sort.SliceStable(table.funcs, func(i, j int) bool { return table.funcs[i].low < table.funcs[j].low })
+	return table, nil
+}
+
+// readLines appends a compile unit's line table
+func (t *symbolTable) readLines(data *dwarf.Data, unit *dwarf.Entry) error {
+	lr, err := data.LineReader(unit)
+	if err != nil || lr == nil {
+		return err
+	}
+	var entry dwarf.LineEntry
+	for {
+		if err := lr.Next(&entry); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		row := lineRow{addr: entry.Address, line: entry.Line, column: entry.Column, end: entry.EndSequence}
+		if entry.File != nil {
+			row.file = entry.File.Name
+		}
+		t.lines = append(t.lines, row)
+	}
+}
+
+// lookup resolves an offset from the start of the binary
+func (t *symbolTable) lookup(offset uint64) (symbol, bool) {
+	addr := t.textAddr + offset
+	var sym symbol
+	found := false
+
+	if i := sort.Search(len(t.lines), func(i int) bool { return t.lines[i].addr > addr }) - 1; i >= 0 {
+		if row := t.lines[i]; !row.end && row.file != "" {
+			sym.file, sym.line, sym.column = row.file, row.line, row.column
+			found = true
+		}
+	}
+
+	if i := sort.Search(len(t.funcs), func(i int) bool { return t.funcs[i].low > addr }) - 1; i >= 0 {
+		if fn := t.funcs[i]; addr < fn.high {
+			sym.function, sym.class = fn.name, fn.class
+			for _, inlined := range fn.inlined {
+				if addr >= inlined.low && addr < inlined.high {
+					sym.function, sym.class = inlined.name, inlined.class
+				}
+			}
+			found = found || sym.function != ""
+		}
+	}
+	return sym, found
+}
+
+// Symbolicator resolves raw Apple frames in crashes using the dSYMs in a
+// Store. Parsed dSYMs are cached, since apps crash with the same build over
+// and over.
+type Symbolicator struct {
+	store     *Store
+	maxCached int
+
+	mu    sync.Mutex
+	cache map[string]*symbolTable
+	order []string // cache keys, least recently used first
+}
+
+// NewSymbolicator creates a symbolicator keeping up to maxCached parsed
+// dSYMs in memory
+func NewSymbolicator(store *Store, maxCached int) *Symbolicator {
+	return &Symbolicator{
+		store:     store,
+		maxCached: max(maxCached, 1),
+		cache:     make(map[string]*symbolTable),
+	}
+}
+
+// Forget drops cached copies of an app's images, after they are replaced
+func (s *Symbolicator) Forget(appID string, images []Image) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, image := range images {
+		s.remove(appID + "/" + image.UUID)
+	}
+}
+
+func (s *Symbolicator) remove(key string) {
+	delete(s.cache, key)
+	for i, k := range s.order {
+		if k == key {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// table returns the parsed dSYM for an app's image, or nil if none was
+// uploaded
+func (s *Symbolicator) table(appID, uuid string) (*symbolTable, error) {
+	key := appID + "/" + uuid
+	s.mu.Lock()
+	if table, ok := s.cache[key]; ok {
+		s.remove(key)
+		s.cache[key] = table
+		s.order = append(s.order, key)
+		s.mu.Unlock()
+		return table, nil
+	}
+	s.mu.Unlock()
+
+	f, err := s.store.open(appID, uuid)
+	if err != nil || f == nil {
+		return nil, err
+	}
+	defer f.Close()
+	table, err := loadSymbolTable(f)
+	if err != nil {
+		return nil, fmt.Errorf("dSYM %s: %w", uuid, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(key)
+	s.cache[key] = table
+	s.order = append(s.order, key)
+	for len(s.order) > s.maxCached {
+		delete(s.cache, s.order[0])
+		s.order = s.order[1:]
+	}
+	return table, nil
+}
+
+// Symbolicate resolves an iOS crash's raw address frames ("0x1a2b MyApp +
+// 1234") to function, file and line using the dSYMs of its debug images.
+// Resolved frames count as in-app code rather than native frames. When any
+// frame resolves, StackTrace is replaced with the symbolicated frames and
+// the original is kept in RawStackTrace. Symbolicated records
+// whether that happened; it stays nil for crashes without raw frames. The
+// returned error reports a dSYM that couldn't be read; the crash is still
+// symbolicated as far as possible.
+func (s *Symbolicator) Symbolicate(crash *core.Crash) error {
+	if !strings.EqualFold(crash.Platform, core.PlatformIOS) {
+		return nil
+	}
+
+	images := make(map[string]string, len(crash.DebugImages))
+	for _, image := range crash.DebugImages {
+		if uuid := NormalizeUUID(image.UUID); uuid != "" {
+			images[path.Base(image.Name)] = uuid
+		}
+	}
+
+	var firstErr error
+	hasRaw := false
+	resolved := 0
+	frames := append([]core.StackFrame(nil), crash.StackTrace...)
+	for i, frame := range crash.StackTrace {
+		offset, ok := core.AppleFrameOffset(frame)
+		if !ok {
+			continue
+		}
+		hasRaw = true
+
+		uuid := images[path.Base(frame.FileName)]
+		if uuid == "" {
+			continue
+		}
+		table, err := s.table(crash.AppID, uuid)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if table == nil {
+			continue
+		}
+
+		// Callers' addresses are return addresses, just past the call
+		if i > 0 && offset > 0 {
+			offset--
+		}
+		sym, ok := table.lookup(offset)
+		if !ok {
+			continue
+		}
+		if sym.function != "" {
+			frames[i].MethodName = sym.function
+			frames[i].ClassName = sym.class
+		}
+		if sym.file != "" {
+			frames[i].FileName = sym.file
+			frames[i].LineNumber = sym.line
+			frames[i].ColumnNumber = sym.column
+		}
+		// dSYMs are uploaded for the app's own binaries, so the frame is
+		// in-app code rather than a native framework frame
+		frames[i].Native = false
+		resolved++
+	}
+	if !hasRaw {
+		return firstErr
+	}
+
+	symbolicated := resolved > 0
+	crash.Symbolicated = &symbolicated
+	if symbolicated {
+		crash.RawStackTrace = crash.StackTrace
+		crash.StackTrace = frames
+	}
+	return firstErr
+}
@@ -0,0 +1,229 @@
+package dsym
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/flakerimi/inceptor/internal/core"
+	"github.com/flakerimi/inceptor/internal/dsym/dsymtest"
+)
+
+// newTestSymbolicator returns a symbolicator over a store with appImage
+// uploaded for app-1
+func newTestSymbolicator(t *testing.T, maxCached int) (*Symbolicator, *Store) {
+	t.Helper()
+
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	images, err := Parse(dsymtest.MachO(appImage(appUUID)), "MyApp", 0)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if err := store.Save("app-1", images); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	return NewSymbolicator(store, maxCached), store
+}
+
+// rawFrame is an unsymbolicated frame of binary at offset
+func rawFrame(binary, offset string) core.StackFrame {
+	return core.StackFrame{FileName: binary, MethodName: "0x0000000104a8c000 " + binary + " + " + offset, Native: true}
+}
+
+func TestSymbolicate(t *testing.T) {
+	symbolicator, store := newTestSymbolicator(t, 4)
+	// A dSYM that is no longer readable
+	const brokenUUID = "6A3B1C2D-0000-4000-8000-00000000BAD0"
+	if err := os.WriteFile(filepath.Join(store.basePath, "app-1", brokenUUID), []byte("garbage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rawStack := []core.StackFrame{
+		rawFrame("MyApp", "4112"), // 0x1010, the crashing frame
+		rawFrame("MyApp", "4417"), // return address 0x1141, in the inlined helper
+		rawFrame("MyApp", "4352"), // return address 0x1100, just past viewDidLoad
+		rawFrame("MyApp", "4689"), // return address 0x1251
+		rawFrame("MyApp", "20480"),
+		rawFrame("UIKitCore", "1000"),
+		{FileName: "main.swift", MethodName: "main", LineNumber: 3},
+	}
+	symbolicated := []core.StackFrame{
+		{FileName: "/src/MyApp/Home.swift", LineNumber: 10, MethodName: "viewDidLoad", ClassName: "HomeViewController"},
+		{FileName: "/src/MyApp/Home.swift", LineNumber: 30, MethodName: "helper"},
+		{FileName: "/src/MyApp/Home.swift", LineNumber: 10, MethodName: "viewDidLoad", ClassName: "HomeViewController"},
+		{FileName: "/src/MyApp/Home.swift", LineNumber: 40, MethodName: "save", ClassName: "Store"},
+		rawStack[4],
+		rawStack[5],
+		rawStack[6],
+	}
+	images := []core.DebugImage{
+		{Name: "/private/var/containers/Bundle/Application/X/MyApp.app/MyApp", UUID: "6a3b1c2d00004000800000000000a001"},
+		{Name: "UIKitCore", UUID: "6A3B1C2D-0000-4000-8000-00000000FFFF"},
+	}
+
+	tests := []struct {
+		name        string
+		appID       string
+		platform    string
+		images      []core.DebugImage
+		stack       []core.StackFrame
+		want        []core.StackFrame
+		wantResult  *bool
+		wantRawKept bool
+		wantErr     bool
+	}{
+		{
+			name:        "resolved",
+			appID:       "app-1",
+			platform:    core.PlatformIOS,
+			images:      images,
+			stack:       rawStack,
+			want:        symbolicated,
+			wantResult:  ptr(true),
+			wantRawKept: true,
+		},
+		{
+			name:       "no dSYM for the image",
+			appID:      "app-1",
+			platform:   core.PlatformIOS,
+			images:     []core.DebugImage{{Name: "MyApp", UUID: widgetUUID}},
+			stack:      rawStack,
+			want:       rawStack,
+			wantResult: ptr(false),
+		},
+		{
+			name:       "no debug images",
+			appID:      "app-1",
+			platform:   core.PlatformIOS,
+			stack:      rawStack,
+			want:       rawStack,
+			wantResult: ptr(false),
+		},
+		{
+			name:       "another app's dSYM",
+			appID:      "app-2",
+			platform:   core.PlatformIOS,
+			images:     images,
+			stack:      rawStack,
+			want:       rawStack,
+			wantResult: ptr(false),
+		},
+		{
+			name:       "unreadable dSYM",
+			appID:      "app-1",
+			platform:   core.PlatformIOS,
+			images:     []core.DebugImage{{Name: "MyApp", UUID: brokenUUID}},
+			stack:      rawStack,
+			want:       rawStack,
+			wantResult: ptr(false),
+			wantErr:    true,
+		},
+		{
+			name:     "no raw frames",
+			appID:    "app-1",
+			platform: core.PlatformIOS,
+			images:   images,
+			stack:    rawStack[6:],
+			want:     rawStack[6:],
+		},
+		{
+			name:     "not iOS",
+			appID:    "app-1",
+			platform: core.PlatformAndroid,
+			images:   images,
+			stack:    rawStack,
+			want:     rawStack,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crash := &core.Crash{
+				AppID:       tt.appID,
+				Platform:    tt.platform,
+				DebugImages: tt.images,
+				StackTrace:  append([]core.StackFrame(nil), tt.stack...),
+			}
+			err := symbolicator.Symbolicate(crash)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Symbolicate: %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(crash.StackTrace, tt.want) {
+				t.Errorf("stack trace:\n got %+v\nwant %+v", crash.StackTrace, tt.want)
+			}
+			if !reflect.DeepEqual(crash.Symbolicated, tt.wantResult) {
+				t.Errorf("Symbolicated = %v, want %v", deref(crash.Symbolicated), deref(tt.wantResult))
+			}
+			if tt.wantRawKept != (crash.RawStackTrace != nil) || tt.wantRawKept && !reflect.DeepEqual(crash.RawStackTrace, tt.stack) {
+				t.Errorf("raw stack trace = %+v, want kept: %v", crash.RawStackTrace, tt.wantRawKept)
+			}
+		})
+	}
+}
+
+func TestSymbolicatorCache(t *testing.T) {
+	symbolicator, store := newTestSymbolicator(t, 1)
+	method := func(uuid string) string {
+		t.Helper()
+		crash := &core.Crash{
+			AppID:       "app-1",
+			Platform:    core.PlatformIOS,
+			DebugImages: []core.DebugImage{{Name: "MyApp", UUID: uuid}},
+			StackTrace:  []core.StackFrame{rawFrame("MyApp", "4112")},
+		}
+		if err := symbolicator.Symbolicate(crash); err != nil {
+			t.Fatalf("Symbolicate: %v", err)
+		}
+		return crash.StackTrace[0].MethodName
+	}
+
+	if got := method(appUUID); got != "viewDidLoad" {
+		t.Fatalf("method = %q, want viewDidLoad", got)
+	}
+
+	// A replaced dSYM is only picked up once the cached copy is forgotten
+	renamed := appImage(appUUID)
+	renamed.DIEs[0].Children[0].Name = "viewWillAppear"
+	images, err := Parse(dsymtest.MachO(renamed), "MyApp", 0)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if err := store.Save("app-1", images); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if got := method(appUUID); got != "viewDidLoad" {
+		t.Errorf("method before Forget = %q, want the cached viewDidLoad", got)
+	}
+	symbolicator.Forget("app-1", images)
+	if got := method(appUUID); got != "viewWillAppear" {
+		t.Errorf("method after Forget = %q, want viewWillAppear", got)
+	}
+
+	// Only maxCached dSYMs stay in memory
+	widget, err := Parse(dsymtest.MachO(appImage(widgetUUID)), "MyApp", 0)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if err := store.Save("app-1", widget); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	method(widgetUUID)
+	symbolicator.mu.Lock()
+	cached := append([]string(nil), symbolicator.order...)
+	symbolicator.mu.Unlock()
+	if want := []string{"app-1/" + widgetUUID}; !reflect.DeepEqual(cached, want) {
+		t.Errorf("cached = %v, want %v", cached, want)
+	}
+}
+
+func ptr(b bool) *bool { return &b }
+
+func deref(b *bool) interface{} {
+	if b == nil {
+		return nil
+	}
+	return *b
+}
@@ -0,0 +1,128 @@
+// Package geoip resolves client IP addresses to countries for crash
+// enrichment.
+package geoip
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Locator resolves an IP address to an ISO 3166-1 alpha-2 country code
+type Locator interface {
+	// Country returns the country code for ip, or "" if it is unknown
+	Country(ip net.IP) string
+}
+
+// ipRange maps an inclusive range of addresses, in 16-byte form, to a country
+type ipRange struct {
+	start, end net.IP
+	country    string
+}
+
+// CSVDatabase is a Locator backed by a CSV file of IP ranges, one
+// "start,end,country" row per range, such as the DB-IP or IP2Location
+// country "lite" databases. Addresses may be written as IPs or as decimal
+// integers. Extra columns are ignored.
+type CSVDatabase struct {
+	ranges []ipRange
+}
+
+// Open loads a CSV IP range database into memory
+func Open(path string) (*CSVDatabase, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	db := &CSVDatabase{}
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 3 {
+			return nil, fmt.Errorf("%s:%d: expected start,end,country", path, line)
+		}
+
+		start, err := parseAddress(record[0])
+		if err != nil {
+			if line == 1 {
+				continue // header row
+			}
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		end, err := parseAddress(record[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+
+		country := strings.ToUpper(strings.TrimSpace(record[2]))
+		if country == "" || country == "-" || country == "ZZ" {
+			continue // unassigned or reserved space
+		}
+		db.ranges = append(db.ranges, ipRange{start: start, end: end, country: country})
+	}
+
+	sort.Slice(db.ranges, func(i, j int) bool {
+		return bytes.Compare(db.ranges[i].start, db.ranges[j].start) < 0
+	})
+	return db, nil
+}
+
+// Len returns the number of ranges loaded
+func (db *CSVDatabase) Len() int {
+	return len(db.ranges)
+}
+
+// Country returns the country of the range containing ip
+func (db *CSVDatabase) Country(ip net.IP) string {
+	ip = ip.To16()
+	if ip == nil {
+		return ""
+	}
+
+	// Last range starting at or before ip
+	i := sort.Search(len(db.ranges), func(i int) bool {
+		return bytes.Compare(db.ranges[i].start, ip) > 0
+	}) - 1
+	if i < 0 || bytes.Compare(ip, db.ranges[i].end) > 0 {
+		return ""
+	}
+	return db.ranges[i].country
+}
+
+// parseAddress parses an IP or decimal integer address into 16-byte form.
+// Integers that fit in 32 bits are IPv4 addresses.
+func parseAddress(s string) (net.IP, error) {
+	s = strings.TrimSpace(s)
+	if ip := net.ParseIP(s); ip != nil {
+		return ip.To16(), nil
+	}
+
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok || n.Sign() < 0 || n.BitLen() > 128 {
+		return nil, fmt.Errorf("invalid address %q", s)
+	}
+	if n.BitLen() <= 32 {
+		v := uint32(n.Uint64())
+		return net.IPv4(byte(v>>24), byte(v>>16), byte(v>>8), byte(v)).To16(), nil
+	}
+	ip := make(net.IP, net.IPv6len)
+	n.FillBytes(ip)
+	return ip, nil
+}
@@ -0,0 +1,82 @@
+package geoip
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeDatabase writes a CSV database to a temporary file
+func writeDatabase(t *testing.T, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "ranges.csv")
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("write database: %v", err)
+	}
+	return path
+}
+
+func TestCountry(t *testing.T) {
+	path := writeDatabase(t, `ip_from,ip_to,country_code,country_name
+"16777216","16777471","AU","Australia"
+192.0.2.0,192.0.2.255,de
+198.51.100.0,198.51.100.255,-
+203.0.113.0,203.0.113.127,ZZ
+2001:db8::,2001:db8::ffff,FR
+`)
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if db.Len() != 3 {
+		t.Errorf("ranges = %d, want 3 with unassigned ranges skipped", db.Len())
+	}
+
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"1.0.0.0", "AU"},
+		{"1.0.0.255", "AU"},
+		{"1.0.1.0", ""},
+		{"192.0.2.77", "DE"},
+		{"198.51.100.1", ""},
+		{"203.0.113.5", ""},
+		{"2001:db8::1", "FR"},
+		{"2001:db8::1:0", ""},
+		{"10.0.0.1", ""},
+	}
+	for _, tt := range tests {
+		if got := db.Country(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("Country(%s) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+	if got := db.Country(nil); got != "" {
+		t.Errorf("Country(nil) = %q, want none", got)
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+	}{
+		{"too few columns", "192.0.2.0,192.0.2.255\n"},
+		{"bad start past the header", "start,end,country\n192.0.2.0,192.0.2.255,DE\nnot-an-ip,192.0.3.255,DE\n"},
+		{"bad end", "192.0.2.0,192.0.2.x,DE\n"},
+		{"negative address", "192.0.2.0,192.0.2.255,DE\n-1,5,DE\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Open(writeDatabase(t, tt.contents)); err == nil {
+				t.Error("opened an invalid database")
+			}
+		})
+	}
+
+	if _, err := Open(filepath.Join(t.TempDir(), "missing.csv")); err == nil {
+		t.Error("opened a missing database")
+	}
+}
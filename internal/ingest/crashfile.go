@@ -0,0 +1,42 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+
+	"github.com/flakerimi/inceptor/internal/core"
+	"github.com/flakerimi/inceptor/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// WithCrashFile returns the full payload read from a crash's file, or the
+// crash with FileStatus set to why the file couldn't be loaded. fullCrash
+// and err are what the file store returned for the crash's file.
+func WithCrashFile(crash, fullCrash *core.Crash, err error) *core.Crash {
+	switch {
+	case err == nil && fullCrash != nil:
+		// Grouping and quarantine can change after the file is written
+		fullCrash.GroupID = crash.GroupID
+		fullCrash.QuarantineReason = crash.QuarantineReason
+		return fullCrash
+	case err == nil:
+		crash.FileStatus = core.FileStatusMissing
+	case errors.Is(err, storage.ErrCorruptCrashLog):
+		log.Warn().Err(err).Str("crash_id", crash.ID).Str("path", crash.LogFilePath).Msg("Crash log file is corrupt")
+		crash.FileStatus = core.FileStatusCorrupt
+	default:
+		log.Warn().Err(err).Str("crash_id", crash.ID).Str("path", crash.LogFilePath).Msg("Failed to read crash log file")
+		crash.FileStatus = core.FileStatusUnreadable
+	}
+	return crash
+}
+
+// LoadCrashFile returns a crash's full payload from fileStore, as
+// WithCrashFile does; crashes stored without a file are returned as they are
+func LoadCrashFile(ctx context.Context, fileStore storage.FileStore, crash *core.Crash) *core.Crash {
+	if crash.LogFilePath == "" {
+		return crash
+	}
+	fullCrash, err := fileStore.GetCrashLog(ctx, crash.LogFilePath)
+	return WithCrashFile(crash, fullCrash, err)
+}
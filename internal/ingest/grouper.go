@@ -0,0 +1,48 @@
+// Package ingest holds the crash ingestion steps shared by the REST and gRPC
+// servers
+package ingest
+
+import (
+	"github.com/flakerimi/inceptor/internal/config"
+	"github.com/flakerimi/inceptor/internal/core"
+)
+
+// NewGrouper creates a Grouper configured from the grouping settings. Both
+// servers build theirs once at startup, so grouping changes need a restart.
+func NewGrouper(cfg *config.Config) *core.Grouper {
+	grouper := core.NewGrouper()
+	if cfg.Grouping.TitleStrategy != "" {
+		grouper.TitleStrategy = cfg.Grouping.TitleStrategy
+	}
+	grouper.TitleTemplates = cfg.Grouping.TitleTemplates
+	grouper.FramePositions = cfg.Grouping.FramePositions
+	if cfg.Grouping.FrameLimit > 0 {
+		grouper.FrameLimit = cfg.Grouping.FrameLimit
+	}
+	if cfg.Grouping.FrameWeighting == core.FrameWeightingWeighted {
+		grouper.FrameWeighting = core.FrameWeightingWeighted
+		grouper.Weights = core.FrameWeights{
+			FullFrames:   max(cfg.Grouping.Weighted.FullFrames, 1),
+			CoarseFrames: max(cfg.Grouping.Weighted.CoarseFrames, 0),
+		}
+	}
+	grouper.AppleFrames = core.AppleFrames{
+		Enabled:      cfg.Grouping.AppleFrames.Enabled,
+		OffsetBucket: cfg.Grouping.AppleFrames.OffsetBucket,
+	}
+	switch cfg.Grouping.ErrorTypeFallback {
+	case core.ErrorTypeFallbackTopFrame, core.ErrorTypeFallbackMessage:
+		grouper.ErrorTypeFallback = cfg.Grouping.ErrorTypeFallback
+	}
+	grouper.Limits = FieldLimits(cfg)
+	return grouper
+}
+
+// FieldLimits returns the configured ingest field length limits
+func FieldLimits(cfg *config.Config) core.FieldLimits {
+	return core.FieldLimits{
+		ErrorType:    cfg.Ingest.MaxLengths.ErrorType,
+		ErrorMessage: cfg.Ingest.MaxLengths.ErrorMessage,
+		FrameField:   cfg.Ingest.MaxLengths.FrameField,
+	}
+}
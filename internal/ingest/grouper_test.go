@@ -0,0 +1,44 @@
+package ingest
+
+import (
+	"testing"
+
+	"github.com/flakerimi/inceptor/internal/config"
+	"github.com/flakerimi/inceptor/internal/core"
+)
+
+func TestNewGrouper(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Grouping.TitleStrategy = core.TitleStrategyTypeCulprit
+	cfg.Grouping.TitleTemplates = map[string]string{"HttpException": "{type}: {first_line}"}
+	cfg.Grouping.FrameLimit = 3
+	cfg.Grouping.FrameWeighting = core.FrameWeightingWeighted
+	cfg.Grouping.Weighted.FullFrames = 0
+	cfg.Grouping.Weighted.CoarseFrames = 2
+	cfg.Grouping.ErrorTypeFallback = "bogus"
+	cfg.Ingest.MaxLengths.ErrorType = 64
+
+	g := NewGrouper(cfg)
+	if g.TitleStrategy != core.TitleStrategyTypeCulprit || g.TitleTemplates["HttpException"] == "" {
+		t.Errorf("titles = %q %v, want the configured strategy and templates", g.TitleStrategy, g.TitleTemplates)
+	}
+	if g.FrameLimit != 3 {
+		t.Errorf("FrameLimit = %d, want 3", g.FrameLimit)
+	}
+	if g.FrameWeighting != core.FrameWeightingWeighted || g.Weights != (core.FrameWeights{FullFrames: 1, CoarseFrames: 2}) {
+		t.Errorf("weighting = %q %+v, want weighted with at least one full frame", g.FrameWeighting, g.Weights)
+	}
+	if g.ErrorTypeFallback != "" {
+		t.Errorf("ErrorTypeFallback = %q, want an unknown mode ignored", g.ErrorTypeFallback)
+	}
+	if g.Limits.ErrorType != 64 {
+		t.Errorf("Limits = %+v, want the configured max lengths", g.Limits)
+	}
+
+	// Unset settings keep the Grouper's defaults
+	defaults := NewGrouper(&config.Config{})
+	if want := core.NewGrouper(); defaults.TitleStrategy != want.TitleStrategy || defaults.FrameLimit != want.FrameLimit {
+		t.Errorf("unconfigured grouper = %q/%d, want defaults %q/%d",
+			defaults.TitleStrategy, defaults.FrameLimit, want.TitleStrategy, want.FrameLimit)
+	}
+}
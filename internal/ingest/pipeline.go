@@ -0,0 +1,608 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/flakerimi/inceptor/internal/config"
+	"github.com/flakerimi/inceptor/internal/core"
+	"github.com/flakerimi/inceptor/internal/dsym"
+	"github.com/flakerimi/inceptor/internal/geoip"
+	"github.com/flakerimi/inceptor/internal/proguard"
+	"github.com/flakerimi/inceptor/internal/sourcemap"
+	"github.com/flakerimi/inceptor/internal/storage"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// ErrNoStackFrames reports a raw stack trace without any frames
+	ErrNoStackFrames = errors.New("no stack frames found in raw_stack_trace")
+	// ErrErrorTypeRequired reports a submission without an error type when
+	// no error type fallback is configured
+	ErrErrorTypeRequired = errors.New("error_type is required")
+	// ErrDeferred reports that a crash couldn't be stored but was kept in
+	// the dead-letter queue for reprocessing
+	ErrDeferred = errors.New("Crash stored for later processing")
+)
+
+// Pipeline takes crash submissions from either server through
+// deduplication, metadata limits, frame resolution, fingerprinting,
+// quarantine and grouping to storage and alerts. It is safe for concurrent
+// use; both servers share one so churn, flood and symbol cache state apply
+// to every submission.
+type Pipeline struct {
+	repo      storage.Repository
+	fileStore storage.FileStore
+	alerter   *core.AlertManager
+	cfg       *config.Config
+	grouper   *core.Grouper
+	churn     *core.ChurnGuard
+	// quarantine is nil when ingest quarantine is disabled
+	quarantine *core.Quarantiner
+	// deadLetters is nil when dead-lettering is disabled
+	deadLetters *storage.DeadLetterQueue
+	// geo is nil when GeoIP enrichment is disabled
+	geo geoip.Locator
+	// crashAge is, per app, how long before the server received it each
+	// crash says it occurred, so clock skew and replayed submissions show up
+	// operationally without storing anything extra
+	crashAge *prometheus.HistogramVec
+	// crashesReceived counts accepted crashes by app, platform and
+	// environment
+	crashesReceived *prometheus.CounterVec
+	// dsyms and symbolicator are nil when symbolication is disabled
+	dsyms        *dsym.Store
+	symbolicator *dsym.Symbolicator
+	// mappings and retracer are nil when deobfuscation is disabled
+	mappings *proguard.Store
+	retracer *proguard.Retracer
+	// sourceMaps and sourceMapResolver are nil when source maps are disabled
+	sourceMaps        *sourcemap.Store
+	sourceMapResolver *sourcemap.Resolver
+}
+
+// NewPipeline creates a Pipeline from the ingest and grouping settings.
+// Optional steps whose stores can't be opened are disabled with an error
+// logged.
+func NewPipeline(repo storage.Repository, fileStore storage.FileStore, alerter *core.AlertManager, cfg *config.Config) *Pipeline {
+	p := &Pipeline{
+		repo:      repo,
+		fileStore: fileStore,
+		alerter:   alerter,
+		cfg:       cfg,
+		grouper:   NewGrouper(cfg),
+		churn: core.NewChurnGuard(
+			cfg.Grouping.Churn.MaxNewGroups,
+			cfg.Grouping.Churn.Window,
+			cfg.Grouping.Churn.FallbackDuration,
+		),
+		crashAge: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "inceptor_crash_age_seconds",
+			Help:    "Server receipt time minus client-reported crash time (occurred_at).",
+			Buckets: core.DefaultCrashAgeBuckets,
+		}, []string{"app_id"}),
+		crashesReceived: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "inceptor_crashes_received_total",
+			Help: "Crashes accepted for ingestion.",
+		}, []string{"app_id", "platform", "environment"}),
+	}
+
+	if cfg.Storage.DeadLetterPath != "" {
+		deadLetters, err := storage.NewDeadLetterQueue(cfg.Storage.DeadLetterPath)
+		if err != nil {
+			log.Error().Err(err).Msg("Dead-letter queue disabled")
+		} else {
+			p.deadLetters = deadLetters
+		}
+	}
+
+	if cfg.Ingest.Quarantine.Enabled {
+		p.quarantine = core.NewQuarantiner(cfg.Ingest.Quarantine.FloodThreshold, cfg.Ingest.Quarantine.FloodWindow)
+	}
+
+	if cfg.Ingest.GeoIP.Enabled {
+		db, err := geoip.Open(cfg.Ingest.GeoIP.DatabasePath)
+		if err != nil {
+			log.Error().Err(err).Str("path", cfg.Ingest.GeoIP.DatabasePath).Msg("GeoIP enrichment disabled")
+		} else {
+			log.Info().Int("ranges", db.Len()).Msg("GeoIP database loaded")
+			p.geo = db
+		}
+	}
+
+	if cfg.Ingest.Symbolication.Enabled {
+		store, err := dsym.NewStore(cfg.Ingest.Symbolication.DSYMPath)
+		if err != nil {
+			log.Error().Err(err).Str("path", cfg.Ingest.Symbolication.DSYMPath).Msg("Symbolication disabled")
+		} else {
+			p.dsyms = store
+			p.symbolicator = dsym.NewSymbolicator(store, cfg.Ingest.Symbolication.CacheSize)
+		}
+	}
+
+	if cfg.Ingest.Deobfuscation.Enabled {
+		store, err := proguard.NewStore(cfg.Ingest.Deobfuscation.MappingPath)
+		if err != nil {
+			log.Error().Err(err).Str("path", cfg.Ingest.Deobfuscation.MappingPath).Msg("Deobfuscation disabled")
+		} else {
+			p.mappings = store
+			p.retracer = proguard.NewRetracer(store, cfg.Ingest.Deobfuscation.CacheSize)
+		}
+	}
+
+	if cfg.Ingest.SourceMaps.Enabled {
+		store, err := sourcemap.NewStore(cfg.Ingest.SourceMaps.Path)
+		if err != nil {
+			log.Error().Err(err).Str("path", cfg.Ingest.SourceMaps.Path).Msg("Source maps disabled")
+		} else {
+			p.sourceMaps = store
+			p.sourceMapResolver = sourcemap.NewResolver(store, cfg.Ingest.SourceMaps.CacheSize)
+		}
+	}
+
+	return p
+}
+
+// Grouper returns the pipeline's grouper
+func (p *Pipeline) Grouper() *core.Grouper {
+	return p.grouper
+}
+
+// DeadLetters returns the dead-letter queue, nil when it is disabled
+func (p *Pipeline) DeadLetters() *storage.DeadLetterQueue {
+	return p.deadLetters
+}
+
+// Collectors returns the ingest metrics: crash ages at receipt, and
+// accepted crashes by app, platform and environment
+func (p *Pipeline) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{p.crashAge, p.crashesReceived}
+}
+
+// Symbolication returns the dSYM store and symbolicator, both nil when
+// symbolication is disabled
+func (p *Pipeline) Symbolication() (*dsym.Store, *dsym.Symbolicator) {
+	return p.dsyms, p.symbolicator
+}
+
+// Deobfuscation returns the mapping store and retracer, both nil when
+// deobfuscation is disabled
+func (p *Pipeline) Deobfuscation() (*proguard.Store, *proguard.Retracer) {
+	return p.mappings, p.retracer
+}
+
+// SourceMaps returns the source map store and resolver, both nil when
+// source maps are disabled
+func (p *Pipeline) SourceMaps() (*sourcemap.Store, *sourcemap.Resolver) {
+	return p.sourceMaps, p.sourceMapResolver
+}
+
+// Validate fills in the frames of a submission that sent its stack trace as
+// text, and checks it has an error type unless a fallback is configured. It
+// returns ErrNoStackFrames or ErrErrorTypeRequired for submissions that
+// can't be ingested.
+func (p *Pipeline) Validate(submission *core.CrashSubmission) error {
+	if len(submission.StackTrace) == 0 && submission.RawStackTrace != "" {
+		// Dart traces for Flutter, tracebacks for Python and Java traces otherwise
+		switch submission.Platform {
+		case core.PlatformFlutter:
+			submission.StackTrace = core.ParseFlutterStackTrace(submission.RawStackTrace)
+		case core.PlatformPython:
+			submission.StackTrace = core.ParsePythonStackTrace(submission.RawStackTrace)
+		default:
+			submission.StackTrace = core.ParseJavaStackTrace(submission.RawStackTrace)
+		}
+		if len(submission.StackTrace) == 0 {
+			return ErrNoStackFrames
+		}
+	}
+	if submission.ErrorType == "" && p.grouper.ErrorTypeFallback == core.ErrorTypeFallbackNone {
+		return ErrErrorTypeRequired
+	}
+	return nil
+}
+
+// Accept turns a validated submission into a fingerprinted crash ready to
+// store. A retried submission already stored within the dedup window is
+// returned as the duplicate instead, with a nil crash. ip is the client's
+// address for GeoIP enrichment and may be nil. Errors wrapping
+// core.ErrMetadataTooDeep or core.ErrMetadataTooLarge reject the
+// submission; any other error is safe to show to clients.
+func (p *Pipeline) Accept(ctx context.Context, app *core.App, submission *core.CrashSubmission, ip net.IP) (crash, duplicate *core.Crash, err error) {
+	receivedAt := time.Now().UTC()
+	if submission.OccurredAt != nil {
+		// Retried duplicates count too: late resends are what the histogram is for
+		p.crashAge.WithLabelValues(app.ID).Observe(receivedAt.Sub(*submission.OccurredAt).Seconds())
+	}
+
+	// Deduplicate retried submissions by client event ID
+	if submission.EventID != "" && p.cfg.Ingest.DedupWindow > 0 {
+		since := receivedAt.Add(-p.cfg.Ingest.DedupWindow)
+		existing, err := p.repo.GetCrashByEventID(ctx, app.ID, submission.EventID, since)
+		if err != nil {
+			log.Error().Err(err).Str("app_id", app.ID).Msg("Failed to check for duplicate crash")
+			return nil, nil, errors.New("Failed to check for duplicate crash")
+		}
+		if existing != nil {
+			return nil, existing, nil
+		}
+	}
+
+	crash = &core.Crash{
+		ID:           uuid.New().String(),
+		AppID:        app.ID,
+		EventID:      submission.EventID,
+		AppVersion:   submission.AppVersion,
+		Platform:     submission.Platform,
+		OSVersion:    submission.OSVersion,
+		DeviceModel:  submission.DeviceModel,
+		ErrorType:    submission.ErrorType,
+		ErrorMessage: submission.ErrorMessage,
+		StackTrace:   submission.StackTrace,
+		UserID:       submission.UserID,
+		Environment:  submission.Environment,
+		CreatedAt:    receivedAt,
+		OccurredAt:   submission.OccurredAt,
+		Metadata:     submission.Metadata,
+		Breadcrumbs:  submission.Breadcrumbs,
+		DebugImages:  submission.DebugImages,
+	}
+
+	// Bound client maps before anything walks or stores them
+	if err := p.applyMetadataLimits(crash); err != nil {
+		return nil, nil, err
+	}
+
+	// Set default environment if not provided
+	if crash.Environment == "" {
+		crash.Environment = core.EnvironmentProduction
+	}
+	p.crashesReceived.WithLabelValues(app.ID, crash.Platform, crash.Environment).Inc()
+	crash.Historical = p.isHistorical(app, crash)
+
+	// Only the country is kept; the client IP is not stored
+	if p.geo != nil && ip != nil {
+		crash.Country = p.geo.Country(ip)
+	}
+
+	// Fingerprint on resolved frames, so crashes group the same across builds
+	p.ResolveFrames(crash, "crash_id", crash.ID)
+	p.ApplyErrorTypeFallback(crash)
+
+	crash.Fingerprint = p.grouper.GenerateFingerprint(crash, app)
+	if p.CoarseFallback(app.ID) {
+		// Fingerprints are churning for this app; group by error type until it settles
+		crash.Fingerprint = p.grouper.GenerateCoarseFingerprint(crash)
+	}
+	crash.FingerprintVersion = core.FingerprintVersion
+	crash.GroupTitle = p.grouper.GenerateTitle(crash)
+	crash.GroupCulprit = core.Culprit(crash)
+	crash.QuarantineReason = p.quarantine.Check(crash, time.Now())
+	return crash, nil, nil
+}
+
+// applyMetadataLimits enforces ingest.metadata_limits on a new crash,
+// trimming its maps or returning why it is rejected
+func (p *Pipeline) applyMetadataLimits(crash *core.Crash) error {
+	cfg := p.cfg.Ingest.MetadataLimits
+	limits := core.MetadataLimits{MaxDepth: cfg.MaxDepth, MaxBytes: cfg.MaxBytes}
+	if cfg.Action != core.MetadataLimitReject {
+		crash.MetadataTrimmed = limits.Trim(crash)
+		return nil
+	}
+	return limits.Check(crash)
+}
+
+// ResolveFrames symbolicates iOS, deobfuscates Android and source maps web
+// frames in place. Failing to read a dSYM, mapping or source map only leaves
+// frames raw, so it is logged with the given context field.
+func (p *Pipeline) ResolveFrames(crash *core.Crash, logKey, logValue string) {
+	if p.symbolicator != nil {
+		if err := p.symbolicator.Symbolicate(crash); err != nil {
+			log.Warn().Err(err).Str(logKey, logValue).Msg("Failed to load dSYM")
+		}
+	}
+	if p.retracer != nil {
+		if err := p.retracer.Deobfuscate(crash); err != nil {
+			log.Warn().Err(err).Str(logKey, logValue).Msg("Failed to load mapping file")
+		}
+	}
+	if p.sourceMapResolver != nil {
+		if err := p.sourceMapResolver.Resolve(crash); err != nil {
+			log.Warn().Err(err).Str(logKey, logValue).Msg("Failed to load source map")
+		}
+	}
+}
+
+// ApplyErrorTypeFallback gives a crash with a blank error type one derived
+// from its top frame or message, when an error type fallback is configured.
+// Frames must already be resolved.
+func (p *Pipeline) ApplyErrorTypeFallback(crash *core.Crash) {
+	if strings.TrimSpace(crash.ErrorType) != "" {
+		return
+	}
+	if errorType := p.grouper.FallbackErrorType(crash); errorType != "" {
+		crash.ErrorType = errorType
+	}
+}
+
+// CoarseFallback reports whether an app's fingerprints are churning, so its
+// crashes are grouped by error type for now
+func (p *Pipeline) CoarseFallback(appID string) bool {
+	return p.churn.InFallback(appID, time.Now())
+}
+
+// isHistorical reports whether a crash occurred, by the client's clock, longer
+// ago than grouping.historical_after or else the app's retention period, so
+// that it shouldn't bump an existing group's last_seen or count
+func (p *Pipeline) isHistorical(app *core.App, crash *core.Crash) bool {
+	if crash.OccurredAt == nil {
+		return false
+	}
+	ttl := p.cfg.Grouping.HistoricalAfter
+	if ttl <= 0 {
+		days := app.RetentionDays
+		if days <= 0 {
+			days = p.cfg.Retention.DefaultDays
+		}
+		ttl = time.Duration(days) * 24 * time.Hour
+	}
+	return ttl > 0 && crash.CreatedAt.Sub(*crash.OccurredAt) > ttl
+}
+
+// Store groups a fingerprinted crash, saves its payload and index row, and
+// notifies the alerter. The group is nil for quarantined crashes. A released
+// crash (see core.Crash.Released) keeps its existing row. It returns
+// ErrDeferred when the crash was dead-lettered instead; any other error is
+// safe to show to clients.
+func (p *Pipeline) Store(ctx context.Context, crash *core.Crash) (*core.CrashGroup, bool, error) {
+	// The group and index row get length-bounded fields; the file keeps everything
+	indexed := FieldLimits(p.cfg).Truncated(crash)
+
+	// Quarantined crashes are kept for review but not grouped or alerted on
+	if crash.QuarantineReason != "" {
+		log.Warn().
+			Str("crash_id", crash.ID).
+			Str("app_id", crash.AppID).
+			Str("reason", crash.QuarantineReason).
+			Msg("Crash quarantined")
+		if err := p.saveCrash(ctx, crash, indexed); err != nil {
+			return nil, false, p.deadLetter(crash, err, "Failed to save crash")
+		}
+		return nil, false, nil
+	}
+
+	// Reprocessed dead letters may already have been grouped
+	var group *core.CrashGroup
+	var err error
+	if crash.GroupID != "" {
+		if group, err = p.repo.GetGroup(ctx, crash.GroupID); err != nil {
+			log.Error().Err(err).Str("crash_id", crash.ID).Msg("Failed to process crash group")
+			return nil, false, p.deadLetter(crash, err, "Failed to process crash group")
+		}
+	}
+
+	// Get or create group
+	isNewGroup := false
+	if group == nil {
+		indexed.GroupID = uuid.New().String() // Pre-generate in case new group needed
+		group, isNewGroup, err = p.repo.GetOrCreateGroup(ctx, indexed)
+		if err != nil {
+			log.Error().Err(err).Str("crash_id", crash.ID).Msg("Failed to process crash group")
+			return nil, false, p.deadLetter(crash, err, "Failed to process crash group")
+		}
+
+		if isNewGroup {
+			app, err := p.repo.GetApp(ctx, crash.AppID)
+			if err != nil {
+				log.Error().Err(err).Str("app_id", crash.AppID).Msg("Failed to retrieve app for new group")
+			}
+
+			overCap, err := p.enforceGroupCap(ctx, crash.AppID, app)
+			if err != nil {
+				// The cap is a last resort; keep the group rather than lose the crash
+				log.Error().Err(err).Str("app_id", crash.AppID).Msg("Failed to enforce group cap")
+			} else if overCap {
+				// Nothing left to evict: undo the new group and set the crash aside
+				if _, err := p.repo.DeleteGroup(ctx, group.ID); err != nil {
+					log.Error().Err(err).Str("group_id", group.ID).Msg("Failed to remove group over cap")
+				}
+				crash.QuarantineReason = core.QuarantineGroupCap
+				return p.Store(ctx, crash)
+			}
+
+			p.AutoAssignGroup(ctx, group, crash, app)
+		}
+	}
+	crash.GroupID = group.ID
+	indexed.GroupID = group.ID
+
+	// Aggregating groups keep no new rows, but a released crash's row exists
+	// already and still needs its group
+	if group.IsAggregating(p.cfg.Ingest.AggregateThreshold) && !crash.Released {
+		// GetOrCreateGroup already counted the occurrence (unless historical)
+		return group, false, nil
+	}
+
+	if err := p.saveCrash(ctx, crash, indexed); err != nil {
+		return nil, false, p.deadLetter(crash, err, "Failed to save crash")
+	}
+
+	isNewVersion := false
+	if crash.AppVersion != "" {
+		isNewVersion, err = p.repo.RecordAppVersion(ctx, crash.AppID, crash.AppVersion, crash.CreatedAt)
+		if err != nil {
+			// Only affects new-version alerts; the crash itself is stored
+			log.Error().Err(err).Str("crash_id", crash.ID).Msg("Failed to record app version")
+		}
+	}
+
+	if isNewGroup && p.churn.RecordNewGroup(crash.AppID, time.Now()) {
+		log.Warn().
+			Str("app_id", crash.AppID).
+			Int("max_new_groups", p.cfg.Grouping.Churn.MaxNewGroups).
+			Dur("window", p.cfg.Grouping.Churn.Window).
+			Dur("fallback", p.cfg.Grouping.Churn.FallbackDuration).
+			Msg("New group churn detected")
+		if p.alerter != nil {
+			p.alerter.Notify(core.AlertEvent{
+				Type:       core.AlertEventChurn,
+				AppID:      crash.AppID,
+				Crash:      indexed,
+				Group:      group,
+				IsNewGroup: true,
+			})
+		}
+	}
+
+	// Send alert; a historical crash isn't a new occurrence of its group
+	if p.alerter != nil && (isNewGroup || !crash.Historical) {
+		eventType := core.AlertEventNewCrash
+		if isNewGroup {
+			eventType = core.AlertEventNewGroup
+		}
+		if group.PreviousStatus != "" {
+			eventType = core.AlertEventRegression
+		}
+		p.alerter.Notify(core.AlertEvent{
+			Type:           eventType,
+			AppID:          crash.AppID,
+			Crash:          indexed,
+			Group:          group,
+			IsNewGroup:     isNewGroup,
+			IsNewVersion:   isNewVersion,
+			PreviousStatus: group.PreviousStatus,
+		})
+	}
+
+	return group, isNewGroup, nil
+}
+
+// enforceGroupCap keeps an app within its group cap after a new group is
+// created, deleting its least recently seen resolved or ignored groups and
+// their crashes. It reports whether the app is still over the cap. app may
+// be nil, in which case the server default cap applies.
+func (p *Pipeline) enforceGroupCap(ctx context.Context, appID string, app *core.App) (bool, error) {
+	limit := p.cfg.Grouping.MaxGroupsPerApp
+	if app != nil && app.MaxGroups > 0 {
+		limit = app.MaxGroups
+	}
+	if limit <= 0 {
+		return false, nil
+	}
+
+	count, err := p.repo.CountGroups(ctx, appID)
+	if err != nil || count <= limit {
+		return false, err
+	}
+
+	evicted, paths, err := p.repo.EvictClosedGroups(ctx, appID, count-limit)
+	if err != nil {
+		return false, err
+	}
+	for _, path := range paths {
+		if err := p.fileStore.DeleteCrashLog(ctx, path); err != nil {
+			log.Warn().Err(err).Str("path", path).Msg("Failed to delete evicted crash log")
+		}
+	}
+	if evicted > 0 {
+		log.Warn().
+			Str("app_id", appID).
+			Int("max_groups", limit).
+			Int("evicted_groups", evicted).
+			Int("evicted_crashes", len(paths)).
+			Msg("Evicted crash groups over the app's group cap")
+	}
+
+	return count-evicted > limit, nil
+}
+
+// AutoAssignGroup assigns a newly created group using the app's assignment
+// rules, matched against the crash that created it
+func (p *Pipeline) AutoAssignGroup(ctx context.Context, group *core.CrashGroup, crash *core.Crash, app *core.App) {
+	if app == nil {
+		return
+	}
+	assignee := core.ResolveAssignee(app.AssignmentRules, crash)
+	if assignee == "" {
+		return
+	}
+
+	group.AssignedTo = assignee
+	if err := p.repo.UpdateGroup(ctx, group); err != nil {
+		// The group is still usable unassigned
+		log.Error().Err(err).Str("group_id", group.ID).Msg("Failed to auto-assign group")
+		group.AssignedTo = ""
+		return
+	}
+	log.Info().
+		Str("group_id", group.ID).
+		Str("assigned_to", assignee).
+		Msg("Group auto-assigned")
+}
+
+// saveCrash writes the full crash payload to the file store and its
+// truncated copy to the database. A payload file that already exists, as
+// for a reprocessed dead letter, is not written again. A released crash is
+// already stored: its row is attached to its group, or left in quarantine
+// if it was quarantined again.
+func (p *Pipeline) saveCrash(ctx context.Context, crash, indexed *core.Crash) error {
+	if crash.Released {
+		if crash.QuarantineReason != "" {
+			return nil
+		}
+		if err := p.repo.ReleaseCrash(ctx, crash.ID, indexed.GroupID); err != nil {
+			log.Error().Err(err).Str("crash_id", crash.ID).Msg("Failed to release crash")
+			return err
+		}
+		return nil
+	}
+
+	// Save full crash log to file
+	if crash.LogFilePath == "" {
+		logPath, err := p.fileStore.SaveCrashLog(ctx, crash)
+		if err != nil {
+			// Log error but continue - file storage is secondary
+			log.Error().Err(err).Str("crash_id", crash.ID).Msg("Failed to save crash log file")
+		} else {
+			crash.LogFilePath = logPath
+			indexed.LogFilePath = logPath
+		}
+	}
+
+	// Save crash to database
+	if err := p.repo.CreateCrash(ctx, indexed); err != nil {
+		log.Error().Err(err).Str("crash_id", crash.ID).Msg("Failed to save crash")
+		return err
+	}
+	return nil
+}
+
+// deadLetter keeps a crash that couldn't be stored so it can be reprocessed
+// later. It returns ErrDeferred if the crash was kept, otherwise an error
+// with clientMsg.
+func (p *Pipeline) deadLetter(crash *core.Crash, cause error, clientMsg string) error {
+	// A released crash is still stored in quarantine and can be released again
+	if p.deadLetters == nil || crash.Released {
+		return errors.New(clientMsg)
+	}
+
+	letter := &storage.DeadLetter{
+		ID:       crash.ID,
+		Crash:    crash,
+		Error:    cause.Error(),
+		FailedAt: time.Now().UTC(),
+	}
+	if err := p.deadLetters.Add(letter); err != nil {
+		log.Error().Err(err).Str("crash_id", crash.ID).Msg("Failed to dead-letter crash")
+		return errors.New(clientMsg)
+	}
+
+	log.Warn().Str("crash_id", crash.ID).Msg("Crash dead-lettered for reprocessing")
+	return ErrDeferred
+}
@@ -0,0 +1,588 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flakerimi/inceptor/internal/config"
+	"github.com/flakerimi/inceptor/internal/core"
+	"github.com/flakerimi/inceptor/internal/storage"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rs/zerolog"
+)
+
+func TestMain(m *testing.M) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	os.Exit(m.Run())
+}
+
+// testPipeline is a Pipeline backed by a SQLite database and local file
+// store in a temporary directory
+type testPipeline struct {
+	*Pipeline
+	repo  *storage.SQLiteRepository
+	files *storage.LocalFileStore
+	app   *core.App
+}
+
+// newTestPipeline creates a pipeline with ingest settings set by configure
+// and an app to submit crashes for
+func newTestPipeline(t *testing.T, configure func(cfg *config.Config)) *testPipeline {
+	t.Helper()
+
+	dir := t.TempDir()
+	repo, err := storage.NewSQLiteRepository(filepath.Join(dir, "inceptor.db"))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	files, err := storage.NewLocalFileStore(filepath.Join(dir, "crashes"))
+	if err != nil {
+		t.Fatalf("open file store: %v", err)
+	}
+
+	app := &core.App{
+		ID:         uuid.New().String(),
+		Name:       "Test App",
+		APIKeyID:   uuid.New().String(),
+		APIKeyHash: "hash",
+		CreatedAt:  time.Now().UTC(),
+	}
+	if err := repo.CreateApp(context.Background(), app); err != nil {
+		t.Fatalf("create app: %v", err)
+	}
+
+	cfg := &config.Config{}
+	if configure != nil {
+		configure(cfg)
+	}
+	return &testPipeline{NewPipeline(repo, files, nil, cfg), repo, files, app}
+}
+
+// ingest validates, accepts and stores a submission, failing the test on
+// any error
+func (tp *testPipeline) ingest(t *testing.T, submission *core.CrashSubmission) (*core.Crash, *core.CrashGroup) {
+	t.Helper()
+
+	ctx := context.Background()
+	if err := tp.Validate(submission); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	crash, duplicate, err := tp.Accept(ctx, tp.app, submission, nil)
+	if err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	if duplicate != nil {
+		return duplicate, nil
+	}
+	group, _, err := tp.Store(ctx, crash)
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	return crash, group
+}
+
+// testSubmission returns a crash submission with one app frame
+func testSubmission() *core.CrashSubmission {
+	return &core.CrashSubmission{
+		AppVersion:   "1.0.0",
+		Platform:     core.PlatformAndroid,
+		ErrorType:    "StateError",
+		ErrorMessage: "Bad state: no element",
+		StackTrace: []core.StackFrame{
+			{FileName: "lib/main.dart", LineNumber: 10, MethodName: "main", ClassName: "App"},
+		},
+	}
+}
+
+func TestStoreTruncatesOversizedFields(t *testing.T) {
+	long := func(prefix string, n int) string { return prefix + strings.Repeat("x", n) }
+
+	tests := []struct {
+		name   string
+		change func(s *core.CrashSubmission, tail string)
+		// field returns the checked field of a crash
+		field func(c *core.Crash) string
+		// indexed is whether the field is kept in the database; frames
+		// are only truncated for fingerprinting
+		indexed bool
+		limit   int
+	}{
+		{
+			name:    "error message",
+			change:  func(s *core.CrashSubmission, tail string) { s.ErrorMessage = long("Bad state: ", 50000) + tail },
+			field:   func(c *core.Crash) string { return c.ErrorMessage },
+			indexed: true,
+			limit:   100,
+		},
+		{
+			name:    "error type",
+			change:  func(s *core.CrashSubmission, tail string) { s.ErrorType = long("StateError", 500) + tail },
+			field:   func(c *core.Crash) string { return c.ErrorType },
+			indexed: true,
+			limit:   32,
+		},
+		{
+			name:   "frame method",
+			change: func(s *core.CrashSubmission, tail string) { s.StackTrace[0].MethodName = long("main", 5000) + tail },
+			field:  func(c *core.Crash) string { return c.StackTrace[0].MethodName },
+			limit:  64,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tp := newTestPipeline(t, func(cfg *config.Config) {
+				cfg.Ingest.MaxLengths.ErrorType = 32
+				cfg.Ingest.MaxLengths.ErrorMessage = 100
+				cfg.Ingest.MaxLengths.FrameField = 64
+			})
+			ctx := context.Background()
+
+			first := testSubmission()
+			tt.change(first, "first")
+			crash, group := tp.ingest(t, first)
+
+			full, err := tp.files.GetCrashLog(ctx, crash.LogFilePath)
+			if err != nil {
+				t.Fatalf("read payload: %v", err)
+			}
+			if got := tt.field(full); !strings.HasSuffix(got, "first") {
+				t.Errorf("payload field is %d bytes, want it whole", len(got))
+			}
+
+			if tt.indexed {
+				stored, err := tp.repo.GetCrash(ctx, crash.ID)
+				if err != nil || stored == nil {
+					t.Fatalf("get crash: %v", err)
+				}
+				if got := tt.field(stored); len(got) > tt.limit || !strings.HasSuffix(got, "...") {
+					t.Errorf("indexed field = %q (%d bytes), want at most %d ending in ...", got, len(got), tt.limit)
+				}
+			}
+
+			// Crashes differing only past the limit group together
+			second := testSubmission()
+			tt.change(second, "second")
+			other, otherGroup := tp.ingest(t, second)
+			if other.Fingerprint != crash.Fingerprint || otherGroup.ID != group.ID {
+				t.Errorf("fingerprints %s, %s differ; want truncation to keep grouping stable", crash.Fingerprint, other.Fingerprint)
+			}
+		})
+	}
+}
+
+func TestAcceptMetadataLimits(t *testing.T) {
+	deep := map[string]interface{}{"a": map[string]interface{}{"b": map[string]interface{}{"c": 1}}}
+
+	tests := []struct {
+		name        string
+		action      string
+		metadata    map[string]interface{}
+		wantErr     error
+		wantTrimmed bool
+	}{
+		{"within limits", core.MetadataLimitReject, map[string]interface{}{"a": 1}, nil, false},
+		{"reject too deep", core.MetadataLimitReject, deep, core.ErrMetadataTooDeep, false},
+		{"reject too large", core.MetadataLimitReject, map[string]interface{}{"a": strings.Repeat("x", 200)}, core.ErrMetadataTooLarge, false},
+		{"trim too deep", core.MetadataLimitTrim, deep, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tp := newTestPipeline(t, func(cfg *config.Config) {
+				cfg.Ingest.MetadataLimits.Action = tt.action
+				cfg.Ingest.MetadataLimits.MaxDepth = 2
+				cfg.Ingest.MetadataLimits.MaxBytes = 100
+			})
+
+			submission := testSubmission()
+			submission.Metadata = tt.metadata
+			crash, _, err := tp.Accept(context.Background(), tp.app, submission, nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && crash.MetadataTrimmed != tt.wantTrimmed {
+				t.Errorf("MetadataTrimmed = %v, want %v", crash.MetadataTrimmed, tt.wantTrimmed)
+			}
+		})
+	}
+}
+
+func TestAcceptDeduplicatesEventIDs(t *testing.T) {
+	tp := newTestPipeline(t, func(cfg *config.Config) {
+		cfg.Ingest.DedupWindow = time.Hour
+	})
+
+	submission := testSubmission()
+	submission.EventID = "event-1"
+	first, _ := tp.ingest(t, submission)
+
+	retry := testSubmission()
+	retry.EventID = "event-1"
+	crash, duplicate, err := tp.Accept(context.Background(), tp.app, retry, nil)
+	if err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	if crash != nil || duplicate == nil || duplicate.ID != first.ID {
+		t.Errorf("retry = %v, duplicate %v; want the first crash as the duplicate", crash, duplicate)
+	}
+}
+
+func TestAcceptMetrics(t *testing.T) {
+	tp := newTestPipeline(t, func(cfg *config.Config) {
+		cfg.Ingest.DedupWindow = time.Hour
+	})
+	occurredAt := func(age time.Duration) *time.Time {
+		at := time.Now().UTC().Add(-age)
+		return &at
+	}
+
+	tests := []struct {
+		name        string
+		eventID     string
+		environment string
+		occurredAt  *time.Time
+		// wantReceived is the accepted crashes counted for the
+		// environment afterwards
+		wantReceived float64
+		// wantAges and wantRecent are the crash ages observed afterwards,
+		// and those of a minute or less
+		wantAges   uint64
+		wantRecent uint64
+	}{
+		{"no occurred_at", "", "", nil, 1, 0, 0},
+		{"occurred_at", "event-1", "", occurredAt(30 * time.Second), 2, 1, 1},
+		// Retried duplicates aren't received again, but their age counts
+		{"duplicate", "event-1", "", occurredAt(30 * time.Second), 2, 2, 2},
+		{"environment", "", "staging", occurredAt(time.Hour), 1, 3, 2},
+	}
+	for _, tt := range tests {
+		submission := testSubmission()
+		submission.EventID = tt.eventID
+		submission.Environment = tt.environment
+		submission.OccurredAt = tt.occurredAt
+		tp.ingest(t, submission)
+
+		environment := tt.environment
+		if environment == "" {
+			environment = core.EnvironmentProduction
+		}
+		if got := testutil.ToFloat64(tp.crashesReceived.WithLabelValues(tp.app.ID, core.PlatformAndroid, environment)); got != tt.wantReceived {
+			t.Errorf("%s: crashes received = %v, want %v", tt.name, got, tt.wantReceived)
+		}
+
+		var ages dto.Metric
+		if err := tp.crashAge.WithLabelValues(tp.app.ID).(prometheus.Metric).Write(&ages); err != nil {
+			t.Fatalf("%s: read crash ages: %v", tt.name, err)
+		}
+		var recent uint64
+		for _, bucket := range ages.GetHistogram().GetBucket() {
+			if bucket.GetUpperBound() == 60 {
+				recent = bucket.GetCumulativeCount()
+			}
+		}
+		if got := ages.GetHistogram().GetSampleCount(); got != tt.wantAges || recent != tt.wantRecent {
+			t.Errorf("%s: crash ages = %d, %d within a minute; want %d, %d", tt.name, got, recent, tt.wantAges, tt.wantRecent)
+		}
+	}
+
+	if got := testutil.CollectAndCount(tp.Collectors()[0]); got != 1 {
+		t.Errorf("crash age series = %d, want one for the app", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		fallback  string
+		change    func(s *core.CrashSubmission)
+		wantErr   error
+		wantFrame string
+	}{
+		{"structured frames", "", func(s *core.CrashSubmission) {}, nil, "main"},
+		{
+			name: "raw java trace",
+			change: func(s *core.CrashSubmission) {
+				s.StackTrace, s.RawStackTrace = nil, "\tat com.example.App.run(App.java:12)"
+			},
+			wantErr:   nil,
+			wantFrame: "run",
+		},
+		{"raw trace without frames", "", func(s *core.CrashSubmission) { s.StackTrace, s.RawStackTrace = nil, "nothing here" }, ErrNoStackFrames, ""},
+		{"missing error type", "", func(s *core.CrashSubmission) { s.ErrorType = "" }, ErrErrorTypeRequired, ""},
+		{"missing error type with fallback", core.ErrorTypeFallbackTopFrame, func(s *core.CrashSubmission) { s.ErrorType = "" }, nil, "main"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tp := newTestPipeline(t, func(cfg *config.Config) {
+				cfg.Grouping.ErrorTypeFallback = tt.fallback
+			})
+			submission := testSubmission()
+			tt.change(submission)
+
+			err := tp.Validate(submission)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && submission.StackTrace[0].MethodName != tt.wantFrame {
+				t.Errorf("top frame = %q, want %q", submission.StackTrace[0].MethodName, tt.wantFrame)
+			}
+		})
+	}
+}
+
+func TestStoreGroupSteps(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(cfg *config.Config)
+		app       func(app *core.App)
+		// errorTypes are submitted in order; the last one is checked
+		errorTypes     []string
+		wantQuarantine string
+		wantAssignee   string
+	}{
+		{
+			name:       "plain",
+			errorTypes: []string{"StateError"},
+		},
+		{
+			name: "flood quarantine",
+			configure: func(cfg *config.Config) {
+				cfg.Ingest.Quarantine.Enabled = true
+				cfg.Ingest.Quarantine.FloodThreshold = 1
+				cfg.Ingest.Quarantine.FloodWindow = time.Minute
+			},
+			errorTypes:     []string{"StateError", "StateError"},
+			wantQuarantine: core.QuarantineFlood,
+		},
+		{
+			name:           "group cap",
+			configure:      func(cfg *config.Config) { cfg.Grouping.MaxGroupsPerApp = 1 },
+			errorTypes:     []string{"StateError", "RangeError"},
+			wantQuarantine: core.QuarantineGroupCap,
+		},
+		{
+			name: "auto-assign",
+			app: func(app *core.App) {
+				app.AssignmentRules = []core.AssignmentRule{{Match: core.AssignmentMatchErrorType, Value: "RangeError", Assignee: "alice"}}
+			},
+			errorTypes:   []string{"RangeError"},
+			wantAssignee: "alice",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tp := newTestPipeline(t, tt.configure)
+			ctx := context.Background()
+			if tt.app != nil {
+				tt.app(tp.app)
+				if err := tp.repo.UpdateApp(ctx, tp.app); err != nil {
+					t.Fatalf("update app: %v", err)
+				}
+			}
+
+			var crash *core.Crash
+			var group *core.CrashGroup
+			for _, errorType := range tt.errorTypes {
+				submission := testSubmission()
+				submission.ErrorType = errorType
+				crash, group = tp.ingest(t, submission)
+			}
+
+			if crash.QuarantineReason != tt.wantQuarantine {
+				t.Errorf("quarantine reason = %q, want %q", crash.QuarantineReason, tt.wantQuarantine)
+			}
+			if quarantined := group == nil; quarantined != (tt.wantQuarantine != "") {
+				t.Fatalf("group = %v, want one only for crashes not quarantined", group)
+			}
+			if group != nil && group.AssignedTo != tt.wantAssignee {
+				t.Errorf("assigned to %q, want %q", group.AssignedTo, tt.wantAssignee)
+			}
+		})
+	}
+}
+
+func TestChurnFallsBackToCoarseFingerprints(t *testing.T) {
+	tp := newTestPipeline(t, func(cfg *config.Config) {
+		cfg.Grouping.Churn.MaxNewGroups = 3
+		cfg.Grouping.Churn.Window = time.Minute
+		cfg.Grouping.Churn.FallbackDuration = time.Hour
+	})
+	crashIn := func(method string) *core.CrashSubmission {
+		s := testSubmission()
+		s.StackTrace[0].MethodName = method
+		return s
+	}
+
+	// Every crash has a new top frame, as when fingerprints stop being stable
+	groups := map[string]bool{}
+	for i := 0; i < 4; i++ {
+		_, group := tp.ingest(t, crashIn(fmt.Sprintf("handler%d", i)))
+		groups[group.ID] = true
+	}
+	if len(groups) != 4 {
+		t.Fatalf("groups = %d, want one per crash before the guard trips", len(groups))
+	}
+	if !tp.CoarseFallback(tp.app.ID) {
+		t.Fatal("churn guard didn't trip")
+	}
+
+	first, firstGroup := tp.ingest(t, crashIn("handler10"))
+	second, secondGroup := tp.ingest(t, crashIn("handler11"))
+	if first.Fingerprint != tp.Grouper().GenerateCoarseFingerprint(first) {
+		t.Errorf("fingerprint = %s, want the coarse fingerprint", first.Fingerprint)
+	}
+	if firstGroup.ID != secondGroup.ID || second.Fingerprint != first.Fingerprint {
+		t.Error("crashes of the same error type got separate groups during fallback")
+	}
+}
+
+func TestNewVersionAlerts(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		versions []string
+	)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Crash struct {
+				AppVersion string `json:"app_version"`
+			} `json:"crash"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		versions = append(versions, payload.Crash.AppVersion)
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+
+	tp := newTestPipeline(t, nil)
+	// submit ingests crashes of each version through a fresh pipeline and
+	// alert manager, as after a restart, returning the versions alerted on
+	submit := func(appVersions ...string) []string {
+		alerter := core.NewAlertManager(core.SMTPConfig{}, "")
+		alerter.SetAlerts([]*core.Alert{{
+			ID:      "alert-1",
+			AppID:   tp.app.ID,
+			Type:    "webhook",
+			Enabled: true,
+			Config: map[string]interface{}{
+				"url":        receiver.URL,
+				"conditions": map[string]interface{}{"on_new_version_crash": true},
+			},
+		}})
+		tp.Pipeline = NewPipeline(tp.repo, tp.files, alerter, tp.cfg)
+
+		mu.Lock()
+		versions = nil
+		mu.Unlock()
+		for _, v := range appVersions {
+			submission := testSubmission()
+			submission.AppVersion = v
+			tp.ingest(t, submission)
+		}
+		if err := alerter.Shutdown(context.Background()); err != nil {
+			t.Fatalf("shut down alerter: %v", err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), versions...)
+	}
+
+	if got := submit("1.0.0", "1.0.0", "1.1.0", "1.0.0", "1.1.0", "2.0.0"); !slices.Equal(got, []string{"1.0.0", "1.1.0", "2.0.0"}) {
+		t.Errorf("alerted versions = %v, want one alert per new version", got)
+	}
+	// Seen versions are persisted, so they don't fire again after a restart
+	if got := submit("1.1.0", "2.0.0", "2.1.0"); !slices.Equal(got, []string{"2.1.0"}) {
+		t.Errorf("alerted versions after restart = %v, want only 2.1.0", got)
+	}
+}
+
+func TestGroupCapEvictsClosedGroups(t *testing.T) {
+	tests := []struct {
+		name      string
+		serverCap int
+		appCap    int
+		// closed are the indexes of groups resolved or ignored before the
+		// next crash creates a new group
+		closed      []int
+		wantEvicted []int
+		wantStored  bool
+	}{
+		{"oldest closed group evicted", 2, 0, []int{0, 1}, []int{0}, true},
+		{"open groups kept", 2, 0, []int{1}, []int{1}, true},
+		{"nothing to evict", 2, 0, nil, nil, false},
+		{"app cap overrides server cap", 0, 2, []int{0}, []int{0}, true},
+		{"under the cap", 5, 0, []int{0}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tp := newTestPipeline(t, func(cfg *config.Config) { cfg.Grouping.MaxGroupsPerApp = tt.serverCap })
+			ctx := context.Background()
+			if tt.appCap > 0 {
+				tp.app.MaxGroups = tt.appCap
+				if err := tp.repo.UpdateApp(ctx, tp.app); err != nil {
+					t.Fatalf("update app: %v", err)
+				}
+			}
+
+			var crashes []*core.Crash
+			var groups []*core.CrashGroup
+			for _, errorType := range []string{"StateError", "RangeError"} {
+				submission := testSubmission()
+				submission.ErrorType = errorType
+				crash, group := tp.ingest(t, submission)
+				crashes = append(crashes, crash)
+				groups = append(groups, group)
+			}
+			for _, i := range tt.closed {
+				if err := tp.repo.UpdateGroupStatus(ctx, groups[i].ID, string(core.GroupStatusResolved)); err != nil {
+					t.Fatalf("resolve group: %v", err)
+				}
+			}
+
+			submission := testSubmission()
+			submission.ErrorType = "FormatException"
+			crash, group := tp.ingest(t, submission)
+			if stored := group != nil; stored != tt.wantStored {
+				t.Errorf("new group stored = %v, want %v (quarantine %q)", stored, tt.wantStored, crash.QuarantineReason)
+			}
+
+			for i, g := range groups {
+				evicted := slices.Contains(tt.wantEvicted, i)
+				got, err := tp.repo.GetGroup(ctx, g.ID)
+				if err != nil {
+					t.Fatalf("get group: %v", err)
+				}
+				if (got == nil) != evicted {
+					t.Errorf("group %d deleted = %v, want %v", i, got == nil, evicted)
+				}
+				log, err := tp.files.GetCrashLog(ctx, crashes[i].LogFilePath)
+				if err != nil {
+					t.Fatalf("get crash log: %v", err)
+				}
+				if deleted := log == nil; deleted != evicted {
+					t.Errorf("group %d crash log deleted = %v, want %v", i, deleted, evicted)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+// Package proguard stores ProGuard and R8 mapping files and uses them to
+// retrace obfuscated frames in Android crashes.
+package proguard
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/flakerimi/inceptor/internal/core"
+)
+
+// ErrNoClasses is returned for mapping files without any class mappings
+var ErrNoClasses = errors.New("no class mappings found")
+
+// maxLineLength bounds a single line of a mapping file
+const maxLineLength = 1 << 20
+
+// methodPattern matches a method line of a mapping file, without the
+// obfuscated name: "[start:end:]type name(args)[:origStart[:origEnd]]"
+var methodPattern = regexp.MustCompile(`^(?:(\d+):(\d+):)?\S+\s+([^\s(]+)\([^)]*\)(?::(\d+)(?::(\d+))?)?$`)
+
+// Mapping is a parsed mapping file, from obfuscated to original names
+type Mapping struct {
+	classes map[string]*classMapping // by obfuscated name
+	// sourceFiles maps original class names to the source files R8 recorded
+	sourceFiles map[string]string
+}
+
+type classMapping struct {
+	original string
+	methods  map[string][]methodMapping // by obfuscated name, in file order
+}
+
+// methodMapping is one method line. Lines with the same obfuscated range
+// are an inline chain, innermost call first.
+type methodMapping struct {
+	startLine, endLine int // obfuscated line range; 0 when absent
+	class              string
+	name               string
+	origStart, origEnd int
+}
+
+// Parse reads a ProGuard or R8 mapping file
+func Parse(r io.Reader) (*Mapping, error) {
+	m := &Mapping{
+		classes:     make(map[string]*classMapping),
+		sourceFiles: make(map[string]string),
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineLength)
+	var current *classMapping
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			continue
+		case strings.HasPrefix(trimmed, "#"):
+			// R8 records the class's source file in a JSON comment
+			if current != nil && strings.Contains(trimmed, "sourceFile") {
+				var info struct {
+					ID       string `json:"id"`
+					FileName string `json:"fileName"`
+				}
+				if json.Unmarshal([]byte(strings.TrimSpace(trimmed[1:])), &info) == nil && info.ID == "sourceFile" {
+					m.sourceFiles[current.original] = info.FileName
+				}
+			}
+			continue
+		}
+
+		original, obfuscated, ok := strings.Cut(trimmed, " -> ")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"original -> obfuscated\"", lineNo)
+		}
+
+		if line[0] != ' ' && line[0] != '\t' {
+			obfuscated, ok = strings.CutSuffix(obfuscated, ":")
+			if !ok {
+				return nil, fmt.Errorf("line %d: class mapping must end with ':'", lineNo)
+			}
+			current = &classMapping{original: original, methods: make(map[string][]methodMapping)}
+			m.classes[obfuscated] = current
+			continue
+		}
+
+		if current == nil {
+			return nil, fmt.Errorf("line %d: member mapping before any class", lineNo)
+		}
+		if !strings.Contains(original, "(") {
+			continue // field
+		}
+		match := methodPattern.FindStringSubmatch(original)
+		if match == nil {
+			return nil, fmt.Errorf("line %d: invalid method mapping", lineNo)
+		}
+		method := methodMapping{name: match[3]}
+		method.startLine, _ = strconv.Atoi(match[1])
+		method.endLine, _ = strconv.Atoi(match[2])
+		method.origStart, _ = strconv.Atoi(match[4])
+		method.origEnd, _ = strconv.Atoi(match[5])
+		// Methods inlined from other classes are qualified
+		if i := strings.LastIndex(method.name, "."); i > 0 {
+			method.class, method.name = method.name[:i], method.name[i+1:]
+		}
+		current.methods[obfuscated] = append(current.methods[obfuscated], method)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(m.classes) == 0 {
+		return nil, ErrNoClasses
+	}
+	return m, nil
+}
+
+// Classes returns the number of classes in the mapping
+func (m *Mapping) Classes() int {
+	return len(m.classes)
+}
+
+// Class returns the original name of an obfuscated class, if it is mapped
+func (m *Mapping) Class(obfuscated string) (string, bool) {
+	class, ok := m.classes[obfuscated]
+	if !ok {
+		return "", false
+	}
+	return class.original, true
+}
+
+// retraceFrame returns the original frames for an obfuscated frame: more
+// than one when the obfuscated method has other methods inlined into it at
+// that line, innermost first. ok is false when the frame's class isn't in
+// the mapping.
+func (m *Mapping) retraceFrame(frame core.StackFrame) ([]core.StackFrame, bool) {
+	className, methodName := frame.ClassName, frame.MethodName
+	if className == "" {
+		// Some SDKs send the qualified method only
+		i := strings.LastIndex(methodName, ".")
+		if i <= 0 {
+			return nil, false
+		}
+		className, methodName = methodName[:i], methodName[i+1:]
+	}
+	class, ok := m.classes[className]
+	if !ok {
+		return nil, false
+	}
+
+	candidates := class.methods[methodName]
+	var chain []methodMapping
+	if frame.LineNumber > 0 {
+		for _, method := range candidates {
+			if method.startLine > 0 && method.startLine <= frame.LineNumber && frame.LineNumber <= method.endLine {
+				if len(chain) > 0 && (method.startLine != chain[0].startLine || method.endLine != chain[0].endLine) {
+					break // the next range; the chain is complete
+				}
+				chain = append(chain, method)
+			}
+		}
+	}
+
+	if len(chain) == 0 {
+		// Without a line range to go by, the method is only known if every
+		// candidate has the same name
+		names := make(map[string]bool)
+		for _, method := range candidates {
+			names[method.name] = true
+		}
+		retraced := frame
+		retraced.ClassName = class.original
+		retraced.MethodName = methodName
+		if len(names) > 0 {
+			alternatives := make([]string, 0, len(names))
+			for name := range names {
+				alternatives = append(alternatives, name)
+			}
+			sort.Strings(alternatives)
+			retraced.MethodName = strings.Join(alternatives, " | ")
+			if len(candidates) == 1 {
+				retraced.LineNumber = candidates[0].originalLine(frame.LineNumber)
+			}
+		}
+		retraced.FileName = m.sourceFile(class.original, frame.FileName)
+		return []core.StackFrame{retraced}, true
+	}
+
+	frames := make([]core.StackFrame, len(chain))
+	for i, method := range chain {
+		frames[i] = frame
+		frames[i].ClassName = class.original
+		if method.class != "" {
+			frames[i].ClassName = method.class
+		}
+		frames[i].MethodName = method.name
+		frames[i].LineNumber = method.originalLine(frame.LineNumber)
+		frames[i].FileName = m.sourceFile(frames[i].ClassName, frame.FileName)
+	}
+	return frames, true
+}
+
+// originalLine maps an obfuscated line number to the original source
+func (method methodMapping) originalLine(line int) int {
+	switch {
+	case method.origStart == 0:
+		// No original range: lines weren't changed
+		return line
+	case method.startLine > 0 && method.origEnd-method.origStart == method.endLine-method.startLine:
+		return method.origStart + line - method.startLine
+	}
+	return method.origStart
+}
+
+// sourceFile returns the source file of an original class: the one R8
+// recorded, or else the outer class name with the extension of the
+// obfuscated frame's file (.java unless it was .kt)
+func (m *Mapping) sourceFile(class, frameFile string) string {
+	if file := m.sourceFiles[class]; file != "" {
+		return file
+	}
+	name := class[strings.LastIndex(class, ".")+1:]
+	if i := strings.Index(name, "$"); i > 0 {
+		name = name[:i]
+	}
+	if strings.HasSuffix(frameFile, ".kt") {
+		return name + ".kt"
+	}
+	return name + ".java"
+}
@@ -0,0 +1,190 @@
+package proguard
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/flakerimi/inceptor/internal/core"
+)
+
+// testMapping is an R8 mapping with line ranges, an inline chain, an
+// overloaded obfuscated name and a method without line info
+const testMapping = `# compiler: R8
+# compiler_version: 8.2.42
+com.example.app.MainActivity -> a.a:
+# {"id":"sourceFile","fileName":"MainActivity.kt"}
+    android.widget.TextView title -> a
+    1:1:void onCreate(android.os.Bundle):42:42 -> a
+    2:4:void loadData():50:52 -> b
+    5:5:void com.example.app.Repository.fetch():12:12 -> b
+    5:5:void loadData():55 -> b
+    6:6:void render():60 -> c
+    void helper() -> d
+    void other(int) -> d
+com.example.app.Repository -> a.b:
+    void fetch() -> a
+com.example.app.MainActivity$Adapter -> a.d:
+    3:3:void bind(int):80:80 -> a
+com.example.app.CrashException -> a.c:
+`
+
+func parseTestMapping(t *testing.T) *Mapping {
+	t.Helper()
+
+	m, err := Parse(strings.NewReader(testMapping))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	return m
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping string
+		// wantErr is a sentinel error, or an error with the same message
+		wantErr error
+	}{
+		{"R8 mapping", testMapping, nil},
+		{"tabs", "com.example.A -> a:\n\tvoid run() -> a\n", nil},
+		{"empty", "", ErrNoClasses},
+		{"comments only", "# compiler: R8\n\n", ErrNoClasses},
+		{"no arrow", "com.example.A a:\n", errors.New(`line 1: expected "original -> obfuscated"`)},
+		{"class without colon", "com.example.A -> a\n", errors.New("line 1: class mapping must end with ':'")},
+		{"member before class", "    void run() -> a\n", errors.New("line 1: member mapping before any class")},
+		{"invalid method", "com.example.A -> a:\n    void (run -> a\n", errors.New("line 2: invalid method mapping")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.mapping))
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Errorf("Parse: %v", err)
+				}
+				return
+			}
+			if err == nil || !errors.Is(err, tt.wantErr) && err.Error() != tt.wantErr.Error() {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMappingClass(t *testing.T) {
+	m := parseTestMapping(t)
+	if m.Classes() != 4 {
+		t.Errorf("Classes() = %d, want 4", m.Classes())
+	}
+	tests := []struct {
+		obfuscated, want string
+		wantOK           bool
+	}{
+		{"a.a", "com.example.app.MainActivity", true},
+		{"a.c", "com.example.app.CrashException", true},
+		{"com.example.app.MainActivity", "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := m.Class(tt.obfuscated); got != tt.want || ok != tt.wantOK {
+			t.Errorf("Class(%q) = %q, %v; want %q, %v", tt.obfuscated, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestRetraceFrame(t *testing.T) {
+	m := parseTestMapping(t)
+
+	// frame is an original frame as file:class.method:line
+	type frame struct {
+		file, class, method string
+		line                int
+	}
+	tests := []struct {
+		name   string
+		frame  core.StackFrame
+		want   []frame
+		wantOK bool
+	}{
+		{
+			name:   "line range",
+			frame:  core.StackFrame{FileName: "SourceFile", ClassName: "a.a", MethodName: "a", LineNumber: 1},
+			want:   []frame{{"MainActivity.kt", "com.example.app.MainActivity", "onCreate", 42}},
+			wantOK: true,
+		},
+		{
+			name:   "line within a range",
+			frame:  core.StackFrame{FileName: "SourceFile", ClassName: "a.a", MethodName: "b", LineNumber: 3},
+			want:   []frame{{"MainActivity.kt", "com.example.app.MainActivity", "loadData", 51}},
+			wantOK: true,
+		},
+		{
+			name:  "inline chain",
+			frame: core.StackFrame{FileName: "SourceFile", ClassName: "a.a", MethodName: "b", LineNumber: 5},
+			want: []frame{
+				{"Repository.java", "com.example.app.Repository", "fetch", 12},
+				{"MainActivity.kt", "com.example.app.MainActivity", "loadData", 55},
+			},
+			wantOK: true,
+		},
+		{
+			name:   "single original line",
+			frame:  core.StackFrame{FileName: "SourceFile", ClassName: "a.a", MethodName: "c", LineNumber: 6},
+			want:   []frame{{"MainActivity.kt", "com.example.app.MainActivity", "render", 60}},
+			wantOK: true,
+		},
+		{
+			name:   "ambiguous without line",
+			frame:  core.StackFrame{FileName: "SourceFile", ClassName: "a.a", MethodName: "d"},
+			want:   []frame{{"MainActivity.kt", "com.example.app.MainActivity", "helper | other", 0}},
+			wantOK: true,
+		},
+		{
+			name:   "no line info kept",
+			frame:  core.StackFrame{FileName: "SourceFile", ClassName: "a.b", MethodName: "a", LineNumber: 7},
+			want:   []frame{{"Repository.java", "com.example.app.Repository", "fetch", 7}},
+			wantOK: true,
+		},
+		{
+			name:   "inner class in a Kotlin file",
+			frame:  core.StackFrame{FileName: "Unknown.kt", ClassName: "a.d", MethodName: "a", LineNumber: 3},
+			want:   []frame{{"MainActivity.kt", "com.example.app.MainActivity$Adapter", "bind", 80}},
+			wantOK: true,
+		},
+		{
+			name:   "qualified method",
+			frame:  core.StackFrame{FileName: "SourceFile", MethodName: "a.a.a", LineNumber: 1},
+			want:   []frame{{"MainActivity.kt", "com.example.app.MainActivity", "onCreate", 42}},
+			wantOK: true,
+		},
+		{
+			name:   "unmapped method keeps its name",
+			frame:  core.StackFrame{FileName: "SourceFile", ClassName: "a.a", MethodName: "z", LineNumber: 9},
+			want:   []frame{{"MainActivity.kt", "com.example.app.MainActivity", "z", 9}},
+			wantOK: true,
+		},
+		{
+			name:  "unmapped class",
+			frame: core.StackFrame{FileName: "View.java", ClassName: "android.view.View", MethodName: "performClick", LineNumber: 7},
+		},
+		{
+			name:  "unqualified method",
+			frame: core.StackFrame{FileName: "SourceFile", MethodName: "a", LineNumber: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frames, ok := m.retraceFrame(tt.frame)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			var got []frame
+			for _, f := range frames {
+				got = append(got, frame{f.FileName, f.ClassName, f.MethodName, f.LineNumber})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("frames = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package proguard
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flakerimi/inceptor/internal/core"
+)
+
+// MetadataKey is the crash metadata key recording whether an Android crash
+// from an app with mappings was deobfuscated
+const MetadataKey = "deobfuscated"
+
+// Store keeps uploaded mapping files on disk, one per app version
+type Store struct {
+	basePath string
+}
+
+// NewStore creates a store in basePath, creating the directory if needed
+func NewStore(basePath string) (*Store, error) {
+	if err := os.MkdirAll(basePath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create mapping directory: %w", err)
+	}
+	return &Store{basePath: basePath}, nil
+}
+
+// path returns the file for an app version; versions are encoded so they
+// can't escape the app's directory
+func (s *Store) path(appID, appVersion string) string {
+	return filepath.Join(s.basePath, appID, base64.RawURLEncoding.EncodeToString([]byte(appVersion))+".txt")
+}
+
+// Save writes the mapping file for an app version, replacing any earlier one
+func (s *Store) Save(appID, appVersion string, data []byte) error {
+	dir := filepath.Join(s.basePath, appID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	// Write then rename, so a retrace never reads a partial file
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path(appID, appVersion))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write mapping: %w", err)
+	}
+	return nil
+}
+
+// StoredMapping describes a mapping file kept in a Store
+type StoredMapping struct {
+	AppVersion string
+	Size       int64
+	UploadedAt time.Time
+}
+
+// List returns the mapping files uploaded for an app
+func (s *Store) List(appID string) ([]StoredMapping, error) {
+	entries, err := os.ReadDir(filepath.Join(s.basePath, appID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var mappings []StoredMapping
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".txt")
+		if entry.IsDir() || !ok {
+			continue
+		}
+		version, err := base64.RawURLEncoding.DecodeString(name)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		mappings = append(mappings, StoredMapping{AppVersion: string(version), Size: info.Size(), UploadedAt: info.ModTime()})
+	}
+	return mappings, nil
+}
+
+// Delete removes the mapping file of an app version, reporting whether it
+// existed
+func (s *Store) Delete(appID, appVersion string) (bool, error) {
+	err := os.Remove(s.path(appID, appVersion))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	// Drop the app's directory once it is empty, so the app counts as
+	// having no mappings again
+	os.Remove(filepath.Join(s.basePath, appID))
+	return true, nil
+}
+
+// hasMappings reports whether any mapping was uploaded for an app
+func (s *Store) hasMappings(appID string) bool {
+	_, err := os.Stat(filepath.Join(s.basePath, appID))
+	return err == nil
+}
+
+// load parses the mapping for an app version, or returns nil if none was
+// uploaded
+func (s *Store) load(appID, appVersion string) (*Mapping, error) {
+	f, err := os.Open(s.path(appID, appVersion))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Retracer deobfuscates Android crashes using the mappings in a Store.
+// Parsed mappings are cached, since most crashes come from a few versions.
+type Retracer struct {
+	store     *Store
+	maxCached int
+
+	mu    sync.Mutex
+	cache map[string]*Mapping
+	order []string // cache keys, least recently used first
+}
+
+// NewRetracer creates a retracer keeping up to maxCached parsed mappings in
+// memory
+func NewRetracer(store *Store, maxCached int) *Retracer {
+	return &Retracer{
+		store:     store,
+		maxCached: max(maxCached, 1),
+		cache:     make(map[string]*Mapping),
+	}
+}
+
+func cacheKey(appID, appVersion string) string {
+	return appID + "\x00" + appVersion
+}
+
+// Forget drops the cached mapping of an app version, after it is replaced
+func (r *Retracer) Forget(appID, appVersion string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.remove(cacheKey(appID, appVersion))
+}
+
+func (r *Retracer) remove(key string) {
+	delete(r.cache, key)
+	for i, k := range r.order {
+		if k == key {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// mapping returns the parsed mapping for an app version, or nil if none was
+// uploaded
+func (r *Retracer) mapping(appID, appVersion string) (*Mapping, error) {
+	key := cacheKey(appID, appVersion)
+	r.mu.Lock()
+	if m, ok := r.cache[key]; ok {
+		r.remove(key)
+		r.cache[key] = m
+		r.order = append(r.order, key)
+		r.mu.Unlock()
+		return m, nil
+	}
+	r.mu.Unlock()
+
+	m, err := r.store.load(appID, appVersion)
+	if err != nil || m == nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.remove(key)
+	r.cache[key] = m
+	r.order = append(r.order, key)
+	for len(r.order) > r.maxCached {
+		delete(r.cache, r.order[0])
+		r.order = r.order[1:]
+	}
+	return m, nil
+}
+
+// Deobfuscate retraces an Android crash's obfuscated frames and error type
+// with the mapping uploaded for its app version. The retraced frames
+// replace StackTrace and the original is kept in RawStackTrace. For apps
+// with any mapping uploaded, metadata "deobfuscated" records whether this
+// succeeded; it is false, with the raw frames kept, when the version has no
+// mapping or none of the frames are in it.
+func (r *Retracer) Deobfuscate(crash *core.Crash) error {
+	if !strings.EqualFold(crash.Platform, core.PlatformAndroid) || !r.store.hasMappings(crash.AppID) {
+		return nil
+	}
+
+	m, err := r.mapping(crash.AppID, crash.AppVersion)
+	if err != nil {
+		setDeobfuscated(crash, false)
+		return fmt.Errorf("mapping for version %q: %w", crash.AppVersion, err)
+	}
+	if m == nil {
+		setDeobfuscated(crash, false)
+		return nil
+	}
+
+	frames := make([]core.StackFrame, 0, len(crash.StackTrace))
+	retraced := false
+	for _, frame := range crash.StackTrace {
+		original, ok := m.retraceFrame(frame)
+		if !ok {
+			frames = append(frames, frame)
+			continue
+		}
+		frames = append(frames, original...)
+		retraced = true
+	}
+	if !retraced {
+		setDeobfuscated(crash, false)
+		return nil
+	}
+
+	crash.RawStackTrace = crash.StackTrace
+	crash.StackTrace = frames
+	if errorType, ok := m.Class(crash.ErrorType); ok {
+		crash.ErrorType = errorType
+	}
+	setDeobfuscated(crash, true)
+	return nil
+}
+
+func setDeobfuscated(crash *core.Crash, deobfuscated bool) {
+	if crash.Metadata == nil {
+		crash.Metadata = make(map[string]interface{})
+	}
+	crash.Metadata[MetadataKey] = deobfuscated
+}
@@ -0,0 +1,194 @@
+package proguard
+
+import (
+	"os"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/flakerimi/inceptor/internal/core"
+)
+
+// newTestRetracer returns a retracer over a store with testMapping
+// uploaded for version 1.0.0 of app-1
+func newTestRetracer(t *testing.T, maxCached int) (*Retracer, *Store) {
+	t.Helper()
+
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	if err := store.Save("app-1", "1.0.0", []byte(testMapping)); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	return NewRetracer(store, maxCached), store
+}
+
+func TestStore(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	versions := []string{"1.0.0", "2.0.0 (42)", "../../escape"}
+	for _, version := range versions {
+		if err := store.Save("app-1", version, []byte(testMapping)); err != nil {
+			t.Fatalf("Save(%q): %v", version, err)
+		}
+	}
+
+	listed := func(appID string) []string {
+		mappings, err := store.List(appID)
+		if err != nil {
+			t.Fatalf("List(%s): %v", appID, err)
+		}
+		var got []string
+		for _, mapping := range mappings {
+			got = append(got, mapping.AppVersion)
+			if mapping.Size != int64(len(testMapping)) {
+				t.Errorf("%s: size %d, want %d", mapping.AppVersion, mapping.Size, len(testMapping))
+			}
+		}
+		slices.Sort(got)
+		return got
+	}
+	if got, want := listed("app-1"), []string{"../../escape", "1.0.0", "2.0.0 (42)"}; !slices.Equal(got, want) {
+		t.Errorf("versions = %v, want %v", got, want)
+	}
+	if got := listed("app-2"); got != nil {
+		t.Errorf("app-2 versions = %v, want none", got)
+	}
+
+	tests := []struct {
+		appID, version string
+		want           bool
+	}{
+		{"app-2", "1.0.0", false},
+		{"app-1", "3.0.0", false},
+		{"app-1", "../../escape", true},
+		{"app-1", "1.0.0", true},
+		{"app-1", "1.0.0", false},
+	}
+	for _, tt := range tests {
+		if deleted, err := store.Delete(tt.appID, tt.version); err != nil || deleted != tt.want {
+			t.Errorf("Delete(%s, %q) = %v, %v; want %v", tt.appID, tt.version, deleted, err, tt.want)
+		}
+	}
+	if got := listed("app-1"); !slices.Equal(got, []string{"2.0.0 (42)"}) {
+		t.Errorf("versions after delete = %v", got)
+	}
+
+	// Deleting the last mapping means the app has none
+	store.Delete("app-1", "2.0.0 (42)")
+	if store.hasMappings("app-1") {
+		t.Error("app-1 still has mappings")
+	}
+}
+
+func TestDeobfuscate(t *testing.T) {
+	retracer, store := newTestRetracer(t, 4)
+	if err := os.WriteFile(store.path("app-1", "broken"), []byte("not a mapping"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	obfuscated := []core.StackFrame{
+		{FileName: "SourceFile", ClassName: "a.a", MethodName: "b", LineNumber: 5},
+		{FileName: "View.java", ClassName: "android.view.View", MethodName: "performClick", LineNumber: 7},
+	}
+	retraced := []core.StackFrame{
+		{FileName: "Repository.java", ClassName: "com.example.app.Repository", MethodName: "fetch", LineNumber: 12},
+		{FileName: "MainActivity.kt", ClassName: "com.example.app.MainActivity", MethodName: "loadData", LineNumber: 55},
+		obfuscated[1],
+	}
+	unmapped := obfuscated[1:]
+
+	tests := []struct {
+		name          string
+		appID         string
+		platform      string
+		version       string
+		stack         []core.StackFrame
+		want          []core.StackFrame
+		wantErrorType string
+		// wantMetadata is the "deobfuscated" metadata, nil when not set
+		wantMetadata interface{}
+		wantErr      bool
+	}{
+		{"retraced", "app-1", core.PlatformAndroid, "1.0.0", obfuscated, retraced, "com.example.app.CrashException", true, false},
+		{"no frames in the mapping", "app-1", core.PlatformAndroid, "1.0.0", unmapped, unmapped, "a.c", false, false},
+		{"version without a mapping", "app-1", core.PlatformAndroid, "1.1.0", obfuscated, obfuscated, "a.c", false, false},
+		{"unreadable mapping", "app-1", core.PlatformAndroid, "broken", obfuscated, obfuscated, "a.c", false, true},
+		{"app without mappings", "app-2", core.PlatformAndroid, "1.0.0", obfuscated, obfuscated, "a.c", nil, false},
+		{"not Android", "app-1", core.PlatformIOS, "1.0.0", obfuscated, obfuscated, "a.c", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crash := &core.Crash{
+				AppID:      tt.appID,
+				Platform:   tt.platform,
+				AppVersion: tt.version,
+				ErrorType:  "a.c",
+				StackTrace: slices.Clone(tt.stack),
+			}
+			if err := retracer.Deobfuscate(crash); (err != nil) != tt.wantErr {
+				t.Fatalf("Deobfuscate: %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(crash.StackTrace, tt.want) {
+				t.Errorf("stack trace:\n got %+v\nwant %+v", crash.StackTrace, tt.want)
+			}
+			if crash.ErrorType != tt.wantErrorType {
+				t.Errorf("error type = %q, want %q", crash.ErrorType, tt.wantErrorType)
+			}
+			if got := crash.Metadata[MetadataKey]; got != tt.wantMetadata {
+				t.Errorf("metadata %s = %v, want %v", MetadataKey, got, tt.wantMetadata)
+			}
+			if wantRaw := tt.wantMetadata == true; wantRaw != reflect.DeepEqual(crash.RawStackTrace, tt.stack) {
+				t.Errorf("raw stack trace = %+v, want kept: %v", crash.RawStackTrace, wantRaw)
+			}
+		})
+	}
+}
+
+func TestRetracerCache(t *testing.T) {
+	retracer, store := newTestRetracer(t, 1)
+	method := func(version string) string {
+		t.Helper()
+		crash := &core.Crash{
+			AppID:      "app-1",
+			Platform:   core.PlatformAndroid,
+			AppVersion: version,
+			StackTrace: []core.StackFrame{{ClassName: "a.a", MethodName: "a", LineNumber: 1}},
+		}
+		if err := retracer.Deobfuscate(crash); err != nil {
+			t.Fatalf("Deobfuscate: %v", err)
+		}
+		return crash.StackTrace[0].MethodName
+	}
+
+	if got := method("1.0.0"); got != "onCreate" {
+		t.Fatalf("method = %q, want onCreate", got)
+	}
+
+	// A replaced mapping is only picked up once the cached copy is forgotten
+	if err := store.Save("app-1", "1.0.0", []byte("com.example.app.MainActivity -> a.a:\n    1:1:void onResume():42:42 -> a\n")); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if got := method("1.0.0"); got != "onCreate" {
+		t.Errorf("method before Forget = %q, want the cached onCreate", got)
+	}
+	retracer.Forget("app-1", "1.0.0")
+	if got := method("1.0.0"); got != "onResume" {
+		t.Errorf("method after Forget = %q, want onResume", got)
+	}
+
+	// Only maxCached mappings stay in memory
+	if err := store.Save("app-1", "2.0.0", []byte(testMapping)); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	method("2.0.0")
+	retracer.mu.Lock()
+	cached := slices.Clone(retracer.order)
+	retracer.mu.Unlock()
+	if want := []string{cacheKey("app-1", "2.0.0")}; !slices.Equal(cached, want) {
+		t.Errorf("cached = %q, want %q", cached, want)
+	}
+}
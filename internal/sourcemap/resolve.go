@@ -0,0 +1,303 @@
+package sourcemap
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flakerimi/inceptor/internal/core"
+)
+
+// BundleName returns the name a frame's script is stored under: the last
+// path element of its URL or path, without query string or fragment
+func BundleName(fileName string) string {
+	if u, err := url.Parse(fileName); err == nil && u.Path != "" {
+		fileName = u.Path
+	} else if i := strings.IndexAny(fileName, "?#"); i >= 0 {
+		fileName = fileName[:i]
+	}
+	name := path.Base(filepath.ToSlash(fileName))
+	if name == "." || name == "/" {
+		return ""
+	}
+	return name
+}
+
+// Store keeps uploaded source maps on disk, one per app version and bundle
+type Store struct {
+	basePath string
+}
+
+// NewStore creates a store in basePath, creating the directory if needed
+func NewStore(basePath string) (*Store, error) {
+	if err := os.MkdirAll(basePath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create source map directory: %w", err)
+	}
+	return &Store{basePath: basePath}, nil
+}
+
+// dir returns the directory for an app version; names are encoded so they
+// can't escape the app's directory
+func (s *Store) dir(appID, appVersion string) string {
+	return filepath.Join(s.basePath, appID, base64.RawURLEncoding.EncodeToString([]byte(appVersion)))
+}
+
+func (s *Store) path(appID, appVersion, bundle string) string {
+	return filepath.Join(s.dir(appID, appVersion), base64.RawURLEncoding.EncodeToString([]byte(bundle))+".map")
+}
+
+// Save writes the source map of a bundle, replacing any earlier one
+func (s *Store) Save(appID, appVersion, bundle string, data []byte) error {
+	dir := s.dir(appID, appVersion)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	// Write then rename, so a lookup never reads a partial file
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path(appID, appVersion, bundle))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write source map: %w", err)
+	}
+	return nil
+}
+
+// StoredMap describes a source map kept in a Store
+type StoredMap struct {
+	AppVersion string
+	Bundle     string
+	Size       int64
+	UploadedAt time.Time
+}
+
+// List returns the source maps uploaded for an app
+func (s *Store) List(appID string) ([]StoredMap, error) {
+	versions, err := os.ReadDir(filepath.Join(s.basePath, appID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var maps []StoredMap
+	for _, versionDir := range versions {
+		version, err := base64.RawURLEncoding.DecodeString(versionDir.Name())
+		if !versionDir.IsDir() || err != nil {
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(s.basePath, appID, versionDir.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			name, ok := strings.CutSuffix(entry.Name(), ".map")
+			if entry.IsDir() || !ok {
+				continue
+			}
+			bundle, err := base64.RawURLEncoding.DecodeString(name)
+			if err != nil {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			maps = append(maps, StoredMap{
+				AppVersion: string(version),
+				Bundle:     string(bundle),
+				Size:       info.Size(),
+				UploadedAt: info.ModTime(),
+			})
+		}
+	}
+	return maps, nil
+}
+
+// Delete removes the source map of a bundle, reporting whether it existed
+func (s *Store) Delete(appID, appVersion, bundle string) (bool, error) {
+	err := os.Remove(s.path(appID, appVersion, bundle))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	// Drop the version's and app's directories once they are empty, so the
+	// app counts as having no source maps again
+	os.Remove(s.dir(appID, appVersion))
+	os.Remove(filepath.Join(s.basePath, appID))
+	return true, nil
+}
+
+// hasMaps reports whether any source map was uploaded for an app
+func (s *Store) hasMaps(appID string) bool {
+	_, err := os.Stat(filepath.Join(s.basePath, appID))
+	return err == nil
+}
+
+// load parses the source map of a bundle, or returns nil if none was
+// uploaded
+func (s *Store) load(appID, appVersion, bundle string) (*Map, error) {
+	data, err := os.ReadFile(s.path(appID, appVersion, bundle))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Resolver maps minified web crash frames to original source using the
+// source maps in a Store. Parsed maps are cached, since a release's crashes
+// come from a handful of bundles.
+type Resolver struct {
+	store     *Store
+	maxCached int
+
+	mu    sync.Mutex
+	cache map[string]*Map
+	order []string // cache keys, least recently used first
+}
+
+// NewResolver creates a resolver keeping up to maxCached parsed source maps
+// in memory
+func NewResolver(store *Store, maxCached int) *Resolver {
+	return &Resolver{
+		store:     store,
+		maxCached: max(maxCached, 1),
+		cache:     make(map[string]*Map),
+	}
+}
+
+func cacheKey(appID, appVersion, bundle string) string {
+	return appID + "\x00" + appVersion + "\x00" + bundle
+}
+
+// Forget drops the cached source map of a bundle, after it is replaced
+func (r *Resolver) Forget(appID, appVersion, bundle string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.remove(cacheKey(appID, appVersion, bundle))
+}
+
+func (r *Resolver) remove(key string) {
+	delete(r.cache, key)
+	for i, k := range r.order {
+		if k == key {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// sourceMap returns the parsed source map of a bundle, or nil if none was
+// uploaded
+func (r *Resolver) sourceMap(appID, appVersion, bundle string) (*Map, error) {
+	key := cacheKey(appID, appVersion, bundle)
+	r.mu.Lock()
+	if m, ok := r.cache[key]; ok {
+		r.remove(key)
+		r.cache[key] = m
+		r.order = append(r.order, key)
+		r.mu.Unlock()
+		return m, nil
+	}
+	r.mu.Unlock()
+
+	m, err := r.store.load(appID, appVersion, bundle)
+	if err != nil {
+		return nil, fmt.Errorf("source map for %s: %w", bundle, err)
+	}
+	if m == nil {
+		return nil, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.remove(key)
+	r.cache[key] = m
+	r.order = append(r.order, key)
+	for len(r.order) > r.maxCached {
+		delete(r.cache, r.order[0])
+		r.order = r.order[1:]
+	}
+	return m, nil
+}
+
+// Resolve maps a web crash's minified frames to original file, line and
+// column using the source maps uploaded for its app version. A frame's
+// function is named by the source map token at its caller's call site,
+// since minified function names change between deploys. Frames from bundles
+// without a map are left as they are. For apps with any source map
+// uploaded, Symbolicated records whether a frame was resolved; if so, the
+// original StackTrace is kept in RawStackTrace. The returned error reports a
+// source map that couldn't be read; the crash is still resolved as far as
+// possible.
+func (r *Resolver) Resolve(crash *core.Crash) error {
+	if !strings.EqualFold(crash.Platform, core.PlatformWeb) || !r.store.hasMaps(crash.AppID) {
+		return nil
+	}
+
+	var firstErr error
+	frames := append([]core.StackFrame(nil), crash.StackTrace...)
+	resolved := make([]bool, len(frames))
+	names := make([]string, len(frames))
+	count := 0
+	for i, frame := range crash.StackTrace {
+		bundle := BundleName(frame.FileName)
+		if bundle == "" || frame.LineNumber <= 0 {
+			continue
+		}
+		m, err := r.sourceMap(crash.AppID, crash.AppVersion, bundle)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if m == nil {
+			continue
+		}
+		pos, ok := m.lookup(frame.LineNumber, frame.ColumnNumber)
+		if !ok {
+			continue
+		}
+		frames[i].FileName = pos.file
+		frames[i].LineNumber = pos.line
+		frames[i].ColumnNumber = pos.column
+		names[i] = pos.name
+		resolved[i] = true
+		count++
+	}
+	// Frames are innermost first, so a frame's caller is the next one
+	for i := 0; i+1 < len(frames); i++ {
+		if resolved[i] && names[i+1] != "" {
+			frames[i].MethodName = names[i+1]
+		}
+	}
+
+	symbolicated := count > 0
+	crash.Symbolicated = &symbolicated
+	if symbolicated {
+		crash.RawStackTrace = crash.StackTrace
+		crash.StackTrace = frames
+	}
+	return firstErr
+}
@@ -0,0 +1,217 @@
+package sourcemap
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/flakerimi/inceptor/internal/core"
+)
+
+func TestBundleName(t *testing.T) {
+	tests := []struct {
+		fileName, want string
+	}{
+		{"https://cdn.example.com/static/app.min.js?v=3#x", "app.min.js"},
+		{"/static/js/app.min.js", "app.min.js"},
+		{"app.min.js?v=3", "app.min.js"},
+		{"webpack-internal:///./src/app.ts", "app.ts"},
+		{"app.min.js", "app.min.js"},
+		{"https://example.com/", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := BundleName(tt.fileName); got != tt.want {
+			t.Errorf("BundleName(%q) = %q, want %q", tt.fileName, got, tt.want)
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	uploads := [][2]string{{"1.0.0", "app.min.js"}, {"1.0.0", "vendor.js"}, {"2.0.0", "../app.min.js"}}
+	for _, upload := range uploads {
+		if err := store.Save("app-1", upload[0], upload[1], []byte("{}")); err != nil {
+			t.Fatalf("Save(%q, %q): %v", upload[0], upload[1], err)
+		}
+	}
+
+	listed := func() [][2]string {
+		maps, err := store.List("app-1")
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		var got [][2]string
+		for _, m := range maps {
+			got = append(got, [2]string{m.AppVersion, m.Bundle})
+		}
+		slices.SortFunc(got, func(a, b [2]string) int {
+			return strings.Compare(a[0]+"\x00"+a[1], b[0]+"\x00"+b[1])
+		})
+		return got
+	}
+	if got := listed(); !reflect.DeepEqual(got, uploads) {
+		t.Errorf("maps = %v, want %v", got, uploads)
+	}
+	if maps, err := store.List("app-2"); err != nil || maps != nil {
+		t.Errorf("app-2 maps = %v, %v; want none", maps, err)
+	}
+
+	tests := []struct {
+		version, bundle string
+		want            bool
+	}{
+		{"1.0.0", "missing.js", false},
+		{"3.0.0", "app.min.js", false},
+		{"1.0.0", "app.min.js", true},
+		{"1.0.0", "app.min.js", false},
+		{"2.0.0", "../app.min.js", true},
+	}
+	for _, tt := range tests {
+		if deleted, err := store.Delete("app-1", tt.version, tt.bundle); err != nil || deleted != tt.want {
+			t.Errorf("Delete(%q, %q) = %v, %v; want %v", tt.version, tt.bundle, deleted, err, tt.want)
+		}
+	}
+	if got, want := listed(), [][2]string{{"1.0.0", "vendor.js"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("maps after delete = %v, want %v", got, want)
+	}
+
+	store.Delete("app-1", "1.0.0", "vendor.js")
+	if store.hasMaps("app-1") {
+		t.Error("app-1 still has source maps")
+	}
+}
+
+// newTestResolver returns a resolver over a store with testMap uploaded for
+// app.min.js of version 1.0.0 of app-1
+func newTestResolver(t *testing.T, maxCached int) (*Resolver, *Store) {
+	t.Helper()
+
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	if err := store.Save("app-1", "1.0.0", "app.min.js", marshal(t, testMap())); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	return NewResolver(store, maxCached), store
+}
+
+func TestResolve(t *testing.T) {
+	resolver, store := newTestResolver(t, 4)
+	if err := store.Save("app-1", "broken", "app.min.js", []byte("{")); err != nil {
+		t.Fatal(err)
+	}
+
+	const bundle = "https://cdn.example.com/static/app.min.js?v=3"
+	minified := []core.StackFrame{
+		{FileName: bundle, MethodName: "t", LineNumber: 1, ColumnNumber: 25},
+		{FileName: bundle, MethodName: "e", LineNumber: 1, ColumnNumber: 1},
+		{FileName: bundle, MethodName: "n", LineNumber: 2, ColumnNumber: 6},
+		{FileName: "https://cdn.example.com/static/vendor.js", MethodName: "r", LineNumber: 1, ColumnNumber: 1},
+		{FileName: bundle, MethodName: "o", LineNumber: 1, ColumnNumber: 45},
+	}
+	resolved := []core.StackFrame{
+		// Functions are named by the token at their caller's call site
+		{FileName: "webpack:///src/util.ts", MethodName: "handleClick", LineNumber: 3, ColumnNumber: 1},
+		{FileName: "webpack:///src/app.ts", MethodName: "render", LineNumber: 10, ColumnNumber: 5},
+		{FileName: "webpack:///src/app.ts", MethodName: "n", LineNumber: 31, ColumnNumber: 3},
+		minified[3],
+		minified[4],
+	}
+	unmapped := minified[3:]
+
+	tests := []struct {
+		name       string
+		appID      string
+		platform   string
+		version    string
+		stack      []core.StackFrame
+		want       []core.StackFrame
+		wantResult *bool
+		wantErr    bool
+	}{
+		{"resolved", "app-1", core.PlatformWeb, "1.0.0", minified, resolved, ptr(true), false},
+		{"no frames in a map", "app-1", core.PlatformWeb, "1.0.0", unmapped, unmapped, ptr(false), false},
+		{"version without maps", "app-1", core.PlatformWeb, "1.1.0", minified, minified, ptr(false), false},
+		{"unreadable map", "app-1", core.PlatformWeb, "broken", minified, minified, ptr(false), true},
+		{"app without maps", "app-2", core.PlatformWeb, "1.0.0", minified, minified, nil, false},
+		{"not web", "app-1", core.PlatformAndroid, "1.0.0", minified, minified, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crash := &core.Crash{
+				AppID:      tt.appID,
+				Platform:   tt.platform,
+				AppVersion: tt.version,
+				StackTrace: slices.Clone(tt.stack),
+			}
+			if err := resolver.Resolve(crash); (err != nil) != tt.wantErr {
+				t.Fatalf("Resolve: %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(crash.StackTrace, tt.want) {
+				t.Errorf("stack trace:\n got %+v\nwant %+v", crash.StackTrace, tt.want)
+			}
+			if !reflect.DeepEqual(crash.Symbolicated, tt.wantResult) {
+				t.Errorf("Symbolicated = %v, want %v", crash.Symbolicated, tt.wantResult)
+			}
+			wantRaw := tt.wantResult != nil && *tt.wantResult
+			if wantRaw != reflect.DeepEqual(crash.RawStackTrace, tt.stack) {
+				t.Errorf("raw stack trace = %+v, want kept: %v", crash.RawStackTrace, wantRaw)
+			}
+		})
+	}
+}
+
+func TestResolverCache(t *testing.T) {
+	resolver, store := newTestResolver(t, 1)
+	file := func(version string) string {
+		t.Helper()
+		crash := &core.Crash{
+			AppID:      "app-1",
+			Platform:   core.PlatformWeb,
+			AppVersion: version,
+			StackTrace: []core.StackFrame{{FileName: "app.min.js", LineNumber: 1, ColumnNumber: 1}},
+		}
+		if err := resolver.Resolve(crash); err != nil {
+			t.Fatalf("Resolve: %v", err)
+		}
+		return crash.StackTrace[0].FileName
+	}
+
+	if got := file("1.0.0"); got != "webpack:///src/app.ts" {
+		t.Fatalf("file = %q, want webpack:///src/app.ts", got)
+	}
+
+	// A replaced map is only picked up once the cached copy is forgotten
+	replaced := testMap()
+	replaced["sourceRoot"] = "/build/"
+	if err := store.Save("app-1", "1.0.0", "app.min.js", marshal(t, replaced)); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if got := file("1.0.0"); got != "webpack:///src/app.ts" {
+		t.Errorf("file before Forget = %q, want the cached map's", got)
+	}
+	resolver.Forget("app-1", "1.0.0", "app.min.js")
+	if got := file("1.0.0"); got != "/build/src/app.ts" {
+		t.Errorf("file after Forget = %q, want /build/src/app.ts", got)
+	}
+
+	// Only maxCached maps stay in memory
+	if err := store.Save("app-1", "2.0.0", "app.min.js", marshal(t, testMap())); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	file("2.0.0")
+	resolver.mu.Lock()
+	cached := slices.Clone(resolver.order)
+	resolver.mu.Unlock()
+	if want := []string{cacheKey("app-1", "2.0.0", "app.min.js")}; !slices.Equal(cached, want) {
+		t.Errorf("cached = %q, want %q", cached, want)
+	}
+}
+
+func ptr(b bool) *bool { return &b }
@@ -0,0 +1,254 @@
+// Package sourcemap stores uploaded JavaScript source maps and uses them to
+// resolve minified frames in web crashes to their original source.
+package sourcemap
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// ErrNoSourceMap is returned for uploads that are neither a source map nor a
+// script with an inline one
+var ErrNoSourceMap = errors.New("no source map found")
+
+// inlinePrefix starts the comment a bundler appends to a script to point at
+// its source map
+const inlinePrefix = "sourceMappingURL="
+
+// Map is a parsed source map, from generated positions to original source
+type Map struct {
+	// File is the generated file the map describes, if it says
+	File    string
+	sources []string
+	names   []string
+	// lines holds the segments of each generated line, sorted by column
+	lines [][]segment
+}
+
+// segment maps a generated column onward to an original position. source
+// is -1 for generated code with no original.
+type segment struct {
+	column            int32
+	source            int32
+	origLine, origCol int32
+	name              int32 // -1 when the segment has no name
+}
+
+// rawMap is the JSON form of a version 3 source map, or of an index map
+// made of sections
+type rawMap struct {
+	Version    int      `json:"version"`
+	File       string   `json:"file"`
+	SourceRoot string   `json:"sourceRoot"`
+	Sources    []string `json:"sources"`
+	Names      []string `json:"names"`
+	Mappings   string   `json:"mappings"`
+	Sections   []struct {
+		Offset struct {
+			Line   int `json:"line"`
+			Column int `json:"column"`
+		} `json:"offset"`
+		Map *rawMap `json:"map"`
+	} `json:"sections"`
+}
+
+// Extract returns the source map JSON of an upload: a .map file, or a script
+// whose sourceMappingURL comment holds the map inline as a data URL
+func Extract(data []byte) ([]byte, error) {
+	// Maps may start with an XSSI guard line
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(bytes.TrimSpace(data), []byte(")]}'")))
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		return trimmed, nil
+	}
+
+	i := bytes.LastIndex(data, []byte(inlinePrefix))
+	if i < 0 {
+		return nil, ErrNoSourceMap
+	}
+	ref := data[i+len(inlinePrefix):]
+	if end := bytes.IndexAny(ref, " \t\r\n*"); end >= 0 {
+		ref = ref[:end]
+	}
+	mediaType, payload, ok := strings.Cut(string(ref), ",")
+	if !ok || !strings.HasPrefix(mediaType, "data:application/json") {
+		// The map lives in a separate file that must be uploaded instead
+		return nil, ErrNoSourceMap
+	}
+	if strings.HasSuffix(mediaType, ";base64") {
+		decoded, err := base64.StdEncoding.DecodeString(payload)
+		if err != nil {
+			return nil, fmt.Errorf("invalid inline source map: %w", err)
+		}
+		return decoded, nil
+	}
+	decoded, err := url.PathUnescape(payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid inline source map: %w", err)
+	}
+	return []byte(decoded), nil
+}
+
+// Parse reads a version 3 source map, including index maps
+func Parse(data []byte) (*Map, error) {
+	var raw rawMap
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if raw.Version != 3 {
+		return nil, fmt.Errorf("unsupported source map version %d", raw.Version)
+	}
+
+	m := &Map{File: raw.File}
+	if len(raw.Sections) == 0 {
+		if err := m.add(&raw, 0, 0); err != nil {
+			return nil, err
+		}
+	}
+	for i, section := range raw.Sections {
+		if section.Map == nil {
+			return nil, fmt.Errorf("section %d: only embedded maps are supported", i)
+		}
+		if err := m.add(section.Map, section.Offset.Line, section.Offset.Column); err != nil {
+			return nil, fmt.Errorf("section %d: %w", i, err)
+		}
+	}
+	if len(m.sources) == 0 {
+		return nil, ErrNoSourceMap
+	}
+	for _, segments := range m.lines {
+		sort.SliceStable(segments, func(i, j int) bool { return segments[i].column < segments[j].column })
+	}
+	return m, nil
+}
+
+// add decodes a map's mappings into m, shifted to start at the given
+// generated line and column
+func (m *Map) add(raw *rawMap, lineOffset, columnOffset int) error {
+	sourceBase, nameBase := int32(len(m.sources)), int32(len(m.names))
+	for _, source := range raw.Sources {
+		if raw.SourceRoot != "" && !strings.Contains(source, "://") && !strings.HasPrefix(source, "/") {
+			source = strings.TrimSuffix(raw.SourceRoot, "/") + "/" + source
+		}
+		m.sources = append(m.sources, source)
+	}
+	m.names = append(m.names, raw.Names...)
+
+	var source, origLine, origCol, name int32
+	line := lineOffset
+	for _, group := range strings.Split(raw.Mappings, ";") {
+		var column int32
+		if line == lineOffset {
+			column = int32(columnOffset)
+		}
+		for _, text := range strings.Split(group, ",") {
+			if text == "" {
+				continue
+			}
+			fields, err := decodeVLQ(text)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", line+1, err)
+			}
+			column += fields[0]
+			seg := segment{column: column, source: -1, name: -1}
+			switch len(fields) {
+			case 1:
+			case 4, 5:
+				source += fields[1]
+				origLine += fields[2]
+				origCol += fields[3]
+				if source < 0 || int(source) >= len(raw.Sources) {
+					return fmt.Errorf("line %d: source index %d out of range", line+1, source)
+				}
+				seg.source, seg.origLine, seg.origCol = sourceBase+source, origLine, origCol
+				if len(fields) == 5 {
+					name += fields[4]
+					if name >= 0 && int(name) < len(raw.Names) {
+						seg.name = nameBase + name
+					}
+				}
+			default:
+				return fmt.Errorf("line %d: segment with %d fields", line+1, len(fields))
+			}
+			for len(m.lines) <= line {
+				m.lines = append(m.lines, nil)
+			}
+			m.lines[line] = append(m.lines[line], seg)
+		}
+		line++
+	}
+	return nil
+}
+
+// decodeVLQ decodes the base64 VLQ values of one mappings segment
+func decodeVLQ(text string) ([]int32, error) {
+	var values []int32
+	var value, shift int64
+	for i := 0; i < len(text); i++ {
+		digit := strings.IndexByte(base64Digits, text[i])
+		if digit < 0 {
+			return nil, fmt.Errorf("invalid mappings character %q", text[i])
+		}
+		value |= int64(digit&31) << shift
+		if digit&32 != 0 {
+			if shift += 5; shift > 31 {
+				return nil, fmt.Errorf("mappings value out of range")
+			}
+			continue
+		}
+		negative := value&1 != 0
+		value >>= 1
+		if negative {
+			value = -value
+		}
+		values = append(values, int32(value))
+		value, shift = 0, 0
+	}
+	if shift != 0 {
+		return nil, fmt.Errorf("truncated mappings value")
+	}
+	return values, nil
+}
+
+const base64Digits = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// Sources returns the number of original source files in the map
+func (m *Map) Sources() int {
+	return len(m.sources)
+}
+
+// position is an original source position a generated one maps to
+type position struct {
+	file         string
+	line, column int // 1-based
+	name         string
+}
+
+// lookup maps a 1-based generated line and column, as JavaScript stack
+// traces report them, to the original source
+func (m *Map) lookup(line, column int) (position, bool) {
+	if line < 1 || line > len(m.lines) {
+		return position{}, false
+	}
+	segments := m.lines[line-1]
+	col := int32(max(column-1, 0))
+	i := sort.Search(len(segments), func(i int) bool { return segments[i].column > col }) - 1
+	if i < 0 || segments[i].source < 0 {
+		return position{}, false
+	}
+	seg := segments[i]
+	pos := position{
+		file:   m.sources[seg.source],
+		line:   int(seg.origLine) + 1,
+		column: int(seg.origCol) + 1,
+	}
+	if seg.name >= 0 {
+		pos.name = m.names[seg.name]
+	}
+	return pos, true
+}
@@ -0,0 +1,209 @@
+package sourcemap
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// vlq encodes mappings segment values as base64 VLQ
+func vlq(values ...int) string {
+	var b strings.Builder
+	for _, v := range values {
+		x := v << 1
+		if v < 0 {
+			x = -v<<1 | 1
+		}
+		for {
+			digit := x & 31
+			if x >>= 5; x > 0 {
+				digit |= 32
+			}
+			b.WriteByte(base64Digits[digit])
+			if x == 0 {
+				break
+			}
+		}
+	}
+	return b.String()
+}
+
+// testMap is a source map of app.min.js. Generated line 1 maps column 0 to
+// src/app.ts:10:5 (handleClick) and column 20 to src/util.ts:3:1
+// (fetchData), with generated-only code from column 40. Line 2 maps column
+// 5 on to src/app.ts:31:3 (render).
+func testMap() map[string]interface{} {
+	return map[string]interface{}{
+		"version":    3,
+		"file":       "app.min.js",
+		"sourceRoot": "webpack:///",
+		"sources":    []string{"src/app.ts", "src/util.ts"},
+		"names":      []string{"handleClick", "fetchData", "render"},
+		"mappings":   vlq(0, 0, 9, 4, 0) + "," + vlq(20, 1, -7, -4, 1) + "," + vlq(20) + ";" + vlq(5, -1, 28, 2, 1),
+	}
+}
+
+func marshal(t *testing.T, v interface{}) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDecodeVLQ(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    []int32
+		wantErr bool
+	}{
+		{"AAAA", []int32{0, 0, 0, 0}, false},
+		{"CDgB", []int32{1, -1, 16}, false},
+		{"2H", []int32{123}, false},
+		{vlq(-1000000, 5), []int32{-1000000, 5}, false},
+		{"A!", nil, true},
+		{"g", nil, true},
+		{"gggggggB", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := decodeVLQ(tt.text)
+		if (err != nil) != tt.wantErr || !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("decodeVLQ(%q) = %v, %v; want %v, error %v", tt.text, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	mapJSON := marshal(t, testMap())
+	script := func(comment string) []byte {
+		return []byte("!function(){console.log(1)}();\n//# " + comment + "\n")
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []byte
+		wantErr error
+	}{
+		{"source map", mapJSON, mapJSON, nil},
+		{"XSSI guard", append([]byte(")]}'\n"), mapJSON...), mapJSON, nil},
+		{"inline base64", script(inlinePrefix + "data:application/json;charset=utf-8;base64," + base64.StdEncoding.EncodeToString(mapJSON)), mapJSON, nil},
+		{"inline URL encoded", script(inlinePrefix + "data:application/json," + url.PathEscape(string(mapJSON))), mapJSON, nil},
+		{"external map", script(inlinePrefix + "app.min.js.map"), nil, ErrNoSourceMap},
+		{"no comment", []byte("console.log(1)"), nil, ErrNoSourceMap},
+		{"invalid base64", script(inlinePrefix + "data:application/json;base64,!!!"), nil, errors.New("invalid inline source map")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Extract(tt.data)
+			if tt.wantErr != nil {
+				if err == nil || !errors.Is(err, tt.wantErr) && !strings.HasPrefix(err.Error(), tt.wantErr.Error()) {
+					t.Errorf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || string(got) != string(tt.want) {
+				t.Errorf("Extract = %s, %v; want %s", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	with := func(changes map[string]interface{}) []byte {
+		m := testMap()
+		for k, v := range changes {
+			m[k] = v
+		}
+		return marshal(t, m)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{"valid", with(nil), nil},
+		{"invalid JSON", []byte("{"), errors.New("invalid JSON")},
+		{"version 2", with(map[string]interface{}{"version": 2}), errors.New("unsupported source map version 2")},
+		{"no sources", with(map[string]interface{}{"sources": []string{}, "mappings": vlq(0)}), ErrNoSourceMap},
+		{"invalid character", with(map[string]interface{}{"mappings": "AA!A"}), errors.New("line 1: invalid mappings character")},
+		{"source out of range", with(map[string]interface{}{"mappings": ";" + vlq(0, 2, 0, 0)}), errors.New("line 2: source index 2 out of range")},
+		{"two fields", with(map[string]interface{}{"mappings": vlq(0, 0)}), errors.New("line 1: segment with 2 fields")},
+		{"section without map", marshal(t, map[string]interface{}{
+			"version":  3,
+			"sections": []interface{}{map[string]interface{}{"offset": map[string]int{}, "url": "a.map"}},
+		}), errors.New("section 0: only embedded maps are supported")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := Parse(tt.data)
+			if tt.wantErr == nil {
+				if err != nil || m.Sources() != 2 || m.File != "app.min.js" {
+					t.Errorf("Parse = %v, %v", m, err)
+				}
+				return
+			}
+			if err == nil || !errors.Is(err, tt.wantErr) && !strings.HasPrefix(err.Error(), tt.wantErr.Error()) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	m, err := Parse(marshal(t, testMap()))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	// An index map placing testMap first and a second map from line 2,
+	// column 10
+	index, err := Parse(marshal(t, map[string]interface{}{
+		"version": 3,
+		"sections": []interface{}{
+			map[string]interface{}{"offset": map[string]int{"line": 0, "column": 0}, "map": testMap()},
+			map[string]interface{}{"offset": map[string]int{"line": 2, "column": 10}, "map": map[string]interface{}{
+				"version": 3, "sources": []string{"/abs/vendor.ts"}, "names": []string{"init"}, "mappings": vlq(0, 0, 0, 0, 0) + ";" + vlq(4, 0, 1, 0),
+			}},
+		},
+	}))
+	if err != nil {
+		t.Fatalf("Parse index map: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		m            *Map
+		line, column int
+		want         position
+		wantOK       bool
+	}{
+		{"segment start", m, 1, 1, position{"webpack:///src/app.ts", 10, 5, "handleClick"}, true},
+		{"within segment", m, 1, 15, position{"webpack:///src/app.ts", 10, 5, "handleClick"}, true},
+		{"second source", m, 1, 21, position{"webpack:///src/util.ts", 3, 1, "fetchData"}, true},
+		{"generated only", m, 1, 45, position{}, false},
+		{"before first segment", m, 2, 3, position{}, false},
+		{"next line", m, 2, 6, position{"webpack:///src/app.ts", 31, 3, "render"}, true},
+		{"no column", m, 2, 0, position{}, false},
+		{"past last line", m, 3, 1, position{}, false},
+		{"line zero", m, 0, 1, position{}, false},
+		{"first section", index, 2, 6, position{"webpack:///src/app.ts", 31, 3, "render"}, true},
+		{"before section column", index, 3, 5, position{}, false},
+		{"second section", index, 3, 11, position{"/abs/vendor.ts", 1, 1, "init"}, true},
+		{"second section next line", index, 4, 5, position{"/abs/vendor.ts", 2, 1, ""}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.m.lookup(tt.line, tt.column)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("lookup(%d, %d) = %+v, %v; want %+v, %v", tt.line, tt.column, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidCursor is returned for a cursor not produced by EncodeCursor
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrCursorSort is returned when a cursor is given for a group listing
+	// that isn't sorted by a time
+	ErrCursorSort = errors.New("cursor pagination requires sort_by first_seen or last_seen")
+)
+
+// EncodeCursor returns an opaque keyset pagination cursor for the row with
+// the given sort time and ID. The next page starts after that row.
+func EncodeCursor(t time.Time, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(t.UTC().Format(time.RFC3339Nano) + "|" + id))
+}
+
+// DecodeCursor returns the sort time and ID encoded in a cursor
+func DecodeCursor(cursor string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	timestamp, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	t, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	// Stored times are UTC, and SQLite compares them as text
+	return t.UTC(), id, nil
+}
@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCursor(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 30, 0, 123456789, time.UTC)
+
+	tests := []struct {
+		name    string
+		cursor  string
+		want    time.Time
+		wantID  string
+		wantErr error
+	}{
+		{"round trip", EncodeCursor(at, "crash-1"), at, "crash-1", nil},
+		{"converted to UTC", EncodeCursor(at.In(time.FixedZone("CET", 3600)), "crash-1"), at, "crash-1", nil},
+		{"ID containing the separator", EncodeCursor(at, "a|b"), at, "a|b", nil},
+		{"not base64", "not a cursor!", time.Time{}, "", ErrInvalidCursor},
+		{"no separator", base64.RawURLEncoding.EncodeToString([]byte("2026-03-01T12:30:00Z")), time.Time{}, "", ErrInvalidCursor},
+		{"no ID", base64.RawURLEncoding.EncodeToString([]byte("2026-03-01T12:30:00Z|")), time.Time{}, "", ErrInvalidCursor},
+		{"bad time", base64.RawURLEncoding.EncodeToString([]byte("yesterday|crash-1")), time.Time{}, "", ErrInvalidCursor},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, id, err := DecodeCursor(tt.cursor)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) || got.Location() != time.UTC || id != tt.wantID {
+				t.Errorf("DecodeCursor = %v, %q; want %v, %q in UTC", got, id, tt.want, tt.wantID)
+			}
+		})
+	}
+}
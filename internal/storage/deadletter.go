@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flakerimi/inceptor/internal/core"
+)
+
+// DeadLetter is a crash that could not be written to the database, kept
+// with the error so it can be reprocessed once the database recovers
+type DeadLetter struct {
+	ID       string      `json:"id"`
+	Crash    *core.Crash `json:"crash"`
+	Error    string      `json:"error"`
+	FailedAt time.Time   `json:"failed_at"`
+}
+
+// DeadLetterQueue stores dead letters as JSON files. It deliberately avoids
+// the database, since database failures are what put crashes here.
+type DeadLetterQueue struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewDeadLetterQueue creates a DeadLetterQueue in dir, creating it if needed
+func NewDeadLetterQueue(dir string) (*DeadLetterQueue, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create dead letter directory: %w", err)
+	}
+	return &DeadLetterQueue{dir: dir}, nil
+}
+
+// Add writes a dead letter. The file is written under a temporary name and
+// renamed, so a crash mid-write never leaves a half-written entry.
+func (q *DeadLetterQueue) Add(letter *DeadLetter) error {
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter: %w", err)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	path := q.path(letter.ID)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write dead letter: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write dead letter: %w", err)
+	}
+	return nil
+}
+
+// List returns all dead letters, oldest first
+func (q *DeadLetterQueue) List() ([]*DeadLetter, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dead letter directory: %w", err)
+	}
+
+	var letters []*DeadLetter
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(q.dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read dead letter: %w", err)
+		}
+		var letter DeadLetter
+		if err := decodeJSONNumbers(data, &letter); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal dead letter %s: %w", entry.Name(), err)
+		}
+		letters = append(letters, &letter)
+	}
+
+	sort.Slice(letters, func(i, j int) bool {
+		return letters[i].FailedAt.Before(letters[j].FailedAt)
+	})
+	return letters, nil
+}
+
+// Remove deletes a dead letter
+func (q *DeadLetterQueue) Remove(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := os.Remove(q.path(id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove dead letter: %w", err)
+	}
+	return nil
+}
+
+// path returns the file path for a dead letter ID
+func (q *DeadLetterQueue) path(id string) string {
+	return filepath.Join(q.dir, filepath.Base(id)+".json")
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/flakerimi/inceptor/internal/core"
+)
+
+func TestDeadLetterQueue(t *testing.T) {
+	q, err := NewDeadLetterQueue(t.TempDir())
+	if err != nil {
+		t.Fatalf("create queue: %v", err)
+	}
+	start := time.Now().UTC()
+	for i, id := range []string{"crash-b", "crash-a", "crash-c"} {
+		letter := &DeadLetter{
+			ID:       id,
+			Crash:    &core.Crash{ID: id, Metadata: map[string]interface{}{"user_id": json.Number("9007199254740993")}},
+			Error:    "database is locked",
+			FailedAt: start.Add(time.Duration(i) * time.Second),
+		}
+		if err := q.Add(letter); err != nil {
+			t.Fatalf("add %s: %v", id, err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		remove string
+		want   []string
+	}{
+		{"oldest first", "", []string{"crash-b", "crash-a", "crash-c"}},
+		{"removed", "crash-a", []string{"crash-b", "crash-c"}},
+		{"removed twice", "crash-a", []string{"crash-b", "crash-c"}},
+		{"unknown", "crash-x", []string{"crash-b", "crash-c"}},
+	}
+	for _, tt := range tests {
+		if tt.remove != "" {
+			if err := q.Remove(tt.remove); err != nil {
+				t.Fatalf("%s: remove %s: %v", tt.name, tt.remove, err)
+			}
+		}
+		letters, err := q.List()
+		if err != nil {
+			t.Fatalf("%s: list: %v", tt.name, err)
+		}
+		var ids []string
+		for _, letter := range letters {
+			ids = append(ids, letter.ID)
+			if letter.Error != "database is locked" || letter.Crash == nil || letter.Crash.ID != letter.ID {
+				t.Errorf("%s: letter %s = %+v, want its crash and error", tt.name, letter.ID, letter)
+			}
+			if got := letter.Crash.Metadata["user_id"]; got != json.Number("9007199254740993") {
+				t.Errorf("%s: user_id = %v (%T), want the exact number", tt.name, got, got)
+			}
+		}
+		if !slices.Equal(ids, tt.want) {
+			t.Errorf("%s: letters = %v, want %v", tt.name, ids, tt.want)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// LocalExportSink writes crash export files to a local directory
+type LocalExportSink struct {
+	dir string
+}
+
+// NewLocalExportSink creates a LocalExportSink in dir, creating it if needed
+func NewLocalExportSink(dir string) (*LocalExportSink, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create export directory: %w", err)
+	}
+	return &LocalExportSink{dir: dir}, nil
+}
+
+// WriteExport writes an export file under a temporary name and renames it,
+// so downstream readers never see a partial file
+func (s *LocalExportSink) WriteExport(ctx context.Context, name string, data []byte) error {
+	path := filepath.Join(s.dir, name)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/flakerimi/inceptor/internal/config"
+	"github.com/flakerimi/inceptor/internal/core"
+)
+
+// Storage backend names
+const (
+	DriverSQLite    = "sqlite"
+	DriverPostgres  = "postgres"
+	FileStoreLocal  = "local"
+	FileStoreS3     = "s3"
+	ExportSinkLocal = "local"
+)
+
+// NewRepository creates the Repository implementation selected by cfg.Driver
+func NewRepository(cfg config.StorageConfig) (Repository, error) {
+	switch cfg.Driver {
+	case DriverSQLite, "":
+		if cfg.SQLitePath == "" {
+			return nil, fmt.Errorf("storage.sqlite_path is required for the sqlite driver")
+		}
+		return NewSQLiteRepository(cfg.SQLitePath)
+	case DriverPostgres:
+		if cfg.DSN == "" {
+			return nil, fmt.Errorf("storage.dsn is required for the postgres driver")
+		}
+		return NewPostgresRepository(cfg.DSN, cfg.ReplicaDSNs, cfg.ReplicaReadAfterWrite)
+	default:
+		return nil, fmt.Errorf("unknown storage driver: %q", cfg.Driver)
+	}
+}
+
+// NewFileStore creates the FileStore implementation selected by cfg.FileStore
+func NewFileStore(cfg config.StorageConfig) (FileStore, error) {
+	switch cfg.FileStore {
+	case FileStoreLocal, "":
+		if cfg.LogsPath == "" {
+			return nil, fmt.Errorf("storage.logs_path is required for the local file store")
+		}
+		store, err := NewLocalFileStore(cfg.LogsPath)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.ReadConcurrency > 0 {
+			store.SetReadConcurrency(cfg.ReadConcurrency)
+		}
+		return store, nil
+	case FileStoreS3:
+		if cfg.S3.Bucket == "" {
+			return nil, fmt.Errorf("storage.s3.bucket is required for the s3 file store")
+		}
+		store, err := NewS3FileStore(cfg.S3)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.ReadConcurrency > 0 {
+			store.SetReadConcurrency(cfg.ReadConcurrency)
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown file store: %q", cfg.FileStore)
+	}
+}
+
+// NewExportSink creates the crash export destination selected by cfg.Destination
+func NewExportSink(cfg config.ExportConfig) (core.ExportSink, error) {
+	switch cfg.Destination {
+	case ExportSinkLocal, "":
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("export.path is required for the local export destination")
+		}
+		return NewLocalExportSink(cfg.Path)
+	default:
+		return nil, fmt.Errorf("unknown export destination: %q", cfg.Destination)
+	}
+}
@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/flakerimi/inceptor/internal/config"
+)
+
+func TestNewRepository(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		cfg     config.StorageConfig
+		wantErr string
+	}{
+		{"sqlite", config.StorageConfig{Driver: DriverSQLite, SQLitePath: filepath.Join(dir, "a.db")}, ""},
+		{"sqlite by default", config.StorageConfig{SQLitePath: filepath.Join(dir, "b.db")}, ""},
+		{"sqlite without path", config.StorageConfig{Driver: DriverSQLite}, "storage.sqlite_path is required"},
+		{"postgres without dsn", config.StorageConfig{Driver: DriverPostgres}, "storage.dsn is required"},
+		{"unknown driver", config.StorageConfig{Driver: "mongodb"}, "unknown storage driver"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, err := NewRepository(tt.cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewRepository: %v", err)
+			}
+			defer repo.Close()
+			if _, ok := repo.(*SQLiteRepository); !ok {
+				t.Errorf("repository = %T, want *SQLiteRepository", repo)
+			}
+		})
+	}
+}
+
+func TestNewFileStore(t *testing.T) {
+	dir := t.TempDir()
+	// Answers the bucket check of the S3 file store
+	s3 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s3.Close()
+	s3Config := config.S3Config{Bucket: "crashes", Region: "us-east-1", Endpoint: s3.URL, AccessKeyID: "key", SecretAccessKey: "secret"}
+
+	tests := []struct {
+		name    string
+		cfg     config.StorageConfig
+		want    string
+		wantErr string
+	}{
+		{"local", config.StorageConfig{FileStore: FileStoreLocal, LogsPath: filepath.Join(dir, "a")}, "*storage.LocalFileStore", ""},
+		{"local by default", config.StorageConfig{LogsPath: filepath.Join(dir, "b")}, "*storage.LocalFileStore", ""},
+		{"local without path", config.StorageConfig{FileStore: FileStoreLocal}, "", "storage.logs_path is required"},
+		{"s3 without bucket", config.StorageConfig{FileStore: FileStoreS3}, "", "storage.s3.bucket is required"},
+		{"s3", config.StorageConfig{FileStore: FileStoreS3, S3: s3Config}, "*storage.S3FileStore", ""},
+		{"unknown store", config.StorageConfig{FileStore: "ftp"}, "", "unknown file store"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := NewFileStore(tt.cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewFileStore: %v", err)
+			}
+			if got := fmt.Sprintf("%T", store); got != tt.want {
+				t.Errorf("file store = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewExportSink(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.ExportConfig
+		wantErr string
+	}{
+		{"local", config.ExportConfig{Destination: ExportSinkLocal, Path: t.TempDir()}, ""},
+		{"local without path", config.ExportConfig{Destination: ExportSinkLocal}, "export.path is required"},
+		{"unknown destination", config.ExportConfig{Destination: "tape"}, "unknown export destination"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewExportSink(tt.cfg)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("NewExportSink: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
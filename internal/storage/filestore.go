@@ -3,16 +3,26 @@ package storage
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/flakerimi/inceptor/internal/core"
 )
 
+// ErrCorruptCrashLog is returned when a stored crash file exists but can't
+// be decoded
+var ErrCorruptCrashLog = errors.New("corrupt crash log")
+
+// defaultReadConcurrency bounds parallel file reads in GetCrashLogs
+const defaultReadConcurrency = 8
+
 type LocalFileStore struct {
-	basePath string
+	basePath        string
+	readConcurrency int
 }
 
 func NewLocalFileStore(basePath string) (*LocalFileStore, error) {
@@ -20,7 +30,15 @@ func NewLocalFileStore(basePath string) (*LocalFileStore, error) {
 	if err := os.MkdirAll(basePath, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create base directory: %w", err)
 	}
-	return &LocalFileStore{basePath: basePath}, nil
+	return &LocalFileStore{basePath: basePath, readConcurrency: defaultReadConcurrency}, nil
+}
+
+// SetReadConcurrency sets how many files GetCrashLogs reads in parallel
+func (fs *LocalFileStore) SetReadConcurrency(n int) {
+	if n < 1 {
+		n = 1
+	}
+	fs.readConcurrency = n
 }
 
 // SaveCrashLog saves the full crash payload to a file
@@ -66,13 +84,69 @@ func (fs *LocalFileStore) GetCrashLog(ctx context.Context, relativePath string)
 	}
 
 	var crash core.Crash
-	if err := json.Unmarshal(data, &crash); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal crash: %w", err)
+	if err := decodeJSONNumbers(data, &crash); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptCrashLog, err)
 	}
 
 	return &crash, nil
 }
 
+// GetCrashLogs reads several crash payloads in parallel. Crashes and errors
+// are in the same order as relativePaths; missing or unreadable files leave
+// a nil crash, with the read error (if any) at the same index.
+func (fs *LocalFileStore) GetCrashLogs(ctx context.Context, relativePaths []string) ([]*core.Crash, []error) {
+	return readCrashLogs(ctx, relativePaths, fs.readConcurrency, fs.GetCrashLog)
+}
+
+// readCrashLogs calls read for each path using up to workers goroutines,
+// returning results in path order. Paths not started before ctx is done get
+// ctx's error.
+func readCrashLogs(ctx context.Context, paths []string, workers int, read func(context.Context, string) (*core.Crash, error)) ([]*core.Crash, []error) {
+	crashes := make([]*core.Crash, len(paths))
+	errs := make([]error, len(paths))
+
+	if workers > len(paths) {
+		workers = len(paths)
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				crashes[i], errs[i] = read(ctx, paths[i])
+			}
+		}()
+	}
+
+	sent := 0
+	for ; sent < len(paths) && ctx.Err() == nil; sent++ {
+		indexes <- sent
+	}
+	close(indexes)
+	wg.Wait()
+
+	for i := sent; i < len(paths); i++ {
+		errs[i] = ctx.Err()
+	}
+	return crashes, errs
+}
+
+// HealthCheck writes and removes a temporary file in the base directory
+func (fs *LocalFileStore) HealthCheck(ctx context.Context) error {
+	file, err := os.CreateTemp(fs.basePath, ".health-*")
+	if err != nil {
+		return fmt.Errorf("base directory is not writable: %w", err)
+	}
+	file.Close()
+	if err := os.Remove(file.Name()); err != nil {
+		return fmt.Errorf("failed to remove health check file: %w", err)
+	}
+	return nil
+}
+
 // DeleteCrashLog deletes a crash log file
 func (fs *LocalFileStore) DeleteCrashLog(ctx context.Context, relativePath string) error {
 	filePath := filepath.Join(fs.basePath, relativePath)
@@ -157,6 +231,43 @@ func (fs *LocalFileStore) GetStorageStats(ctx context.Context, appID string) (*S
 	return stats, err
 }
 
+// ScanCorruptLogs decodes every stored crash file for an app and reports
+// the ones that can't be read back, along with how many files were checked
+func (fs *LocalFileStore) ScanCorruptLogs(ctx context.Context, appID string) (int, []CorruptLog, error) {
+	appDir := filepath.Join(fs.basePath, appID)
+	if _, err := os.Stat(appDir); os.IsNotExist(err) {
+		return 0, nil, nil
+	}
+
+	scanned := 0
+	var corrupt []CorruptLog
+	err := filepath.Walk(appDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if info.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+
+		scanned++
+		relativePath, _ := filepath.Rel(fs.basePath, path)
+		if _, err := fs.GetCrashLog(ctx, relativePath); err != nil {
+			corrupt = append(corrupt, CorruptLog{
+				Path:       relativePath,
+				Size:       info.Size(),
+				ModifiedAt: info.ModTime().UTC(),
+				Error:      err.Error(),
+			})
+		}
+		return nil
+	})
+
+	return scanned, corrupt, err
+}
+
 // cleanEmptyDirs removes empty parent directories up to the base path
 func (fs *LocalFileStore) cleanEmptyDirs(dirPath string) {
 	for dirPath != fs.basePath && dirPath != "." && dirPath != "/" {
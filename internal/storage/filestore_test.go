@@ -0,0 +1,239 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flakerimi/inceptor/internal/core"
+)
+
+// newTestFileStore opens a local file store in a temporary directory
+func newTestFileStore(t *testing.T) *LocalFileStore {
+	t.Helper()
+
+	fs, err := NewLocalFileStore(filepath.Join(t.TempDir(), "crashes"))
+	if err != nil {
+		t.Fatalf("open file store: %v", err)
+	}
+	return fs
+}
+
+func TestLargeIntegersRoundTrip(t *testing.T) {
+	// 2^53 + 1 can't be represented as a float64
+	const large = "9007199254740993"
+
+	tests := []struct {
+		name string
+		// read saves a crash and reads it back
+		read func(t *testing.T, crash *core.Crash) *core.Crash
+		// breadcrumbs is whether breadcrumbs are stored; the database
+		// leaves them to the payload file
+		breadcrumbs bool
+	}{
+		{
+			name: "file store",
+			read: func(t *testing.T, crash *core.Crash) *core.Crash {
+				fs := newTestFileStore(t)
+				path, err := fs.SaveCrashLog(context.Background(), crash)
+				if err != nil {
+					t.Fatalf("save crash log: %v", err)
+				}
+				got, err := fs.GetCrashLog(context.Background(), path)
+				if err != nil {
+					t.Fatalf("get crash log: %v", err)
+				}
+				return got
+			},
+			breadcrumbs: true,
+		},
+		{
+			name: "database",
+			read: func(t *testing.T, crash *core.Crash) *core.Crash {
+				repo := newTestRepository(t)
+				createTestApp(t, repo, crash.AppID)
+				addTestCrash(t, repo, crash)
+				got, err := repo.GetCrash(context.Background(), crash.ID)
+				if err != nil || got == nil {
+					t.Fatalf("get crash: %v", err)
+				}
+				return got
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crash := &core.Crash{
+				ID:        "crash-1",
+				AppID:     "app-1",
+				ErrorType: "StateError",
+				CreatedAt: time.Now().UTC(),
+				Metadata:  map[string]interface{}{"user_id": json.Number(large), "ratio": json.Number("0.5")},
+				Breadcrumbs: []core.Breadcrumb{
+					{Message: "tap", Data: map[string]interface{}{"id": json.Number(large)}},
+				},
+			}
+
+			got := tt.read(t, crash)
+			metadata := got.Metadata
+			if got := metadata["user_id"]; got != json.Number(large) {
+				t.Errorf("metadata user_id = %#v, want %s", got, large)
+			}
+			if got := metadata["ratio"]; got != json.Number("0.5") {
+				t.Errorf("metadata ratio = %#v, want 0.5", got)
+			}
+			if tt.breadcrumbs {
+				if got := got.Breadcrumbs[0].Data["id"]; got != json.Number(large) {
+					t.Errorf("breadcrumb id = %#v, want %s", got, large)
+				}
+			}
+		})
+	}
+}
+
+// saveTestCrashLogs saves n crash payloads, returning their paths
+func saveTestCrashLogs(tb testing.TB, fs *LocalFileStore, n int) []string {
+	tb.Helper()
+
+	paths := make([]string, n)
+	for i := range paths {
+		crash := &core.Crash{
+			ID:           fmt.Sprintf("crash-%d", i),
+			AppID:        "app-1",
+			ErrorType:    "StateError",
+			ErrorMessage: strings.Repeat("Bad state ", 200),
+			StackTrace:   []core.StackFrame{{FileName: "lib/main.dart", LineNumber: i, MethodName: "main"}},
+			CreatedAt:    time.Now().UTC(),
+		}
+		path, err := fs.SaveCrashLog(context.Background(), crash)
+		if err != nil {
+			tb.Fatalf("save crash log: %v", err)
+		}
+		paths[i] = path
+	}
+	return paths
+}
+
+func TestGetCrashLogs(t *testing.T) {
+	fs := newTestFileStore(t)
+	paths := saveTestCrashLogs(t, fs, 20)
+	corrupt := filepath.Join("app-1", "corrupt.json")
+	if err := os.WriteFile(filepath.Join(fs.basePath, corrupt), []byte("{not json"), 0644); err != nil {
+		t.Fatalf("write corrupt file: %v", err)
+	}
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		concurrency int
+		paths       []string
+		// want is the crash ID read for each path, "" for a missing file
+		// and "error" where reading fails
+		want []string
+	}{
+		{"sequential", context.Background(), 1, paths[:3], []string{"crash-0", "crash-1", "crash-2"}},
+		{"in path order", context.Background(), 8, paths, nil},
+		{"missing and corrupt files", context.Background(), 4, []string{paths[5], "app-1/gone.json", corrupt, paths[6]}, []string{"crash-5", "", "error", "crash-6"}},
+		{"no paths", context.Background(), 4, nil, nil},
+		{"canceled", canceled, 4, paths[:2], []string{"error", "error"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.want == nil {
+				for i := range tt.paths {
+					tt.want = append(tt.want, fmt.Sprintf("crash-%d", i))
+				}
+			}
+			fs.SetReadConcurrency(tt.concurrency)
+
+			crashes, errs := fs.GetCrashLogs(tt.ctx, tt.paths)
+			if len(crashes) != len(tt.paths) || len(errs) != len(tt.paths) {
+				t.Fatalf("got %d crashes and %d errors, want %d of each", len(crashes), len(errs), len(tt.paths))
+			}
+			for i, want := range tt.want {
+				switch want {
+				case "error":
+					if errs[i] == nil || crashes[i] != nil {
+						t.Errorf("path %d: crash %v, error %v, want an error", i, crashes[i], errs[i])
+					}
+				case "":
+					if errs[i] != nil || crashes[i] != nil {
+						t.Errorf("path %d: crash %v, error %v, want neither", i, crashes[i], errs[i])
+					}
+				default:
+					if errs[i] != nil || crashes[i] == nil || crashes[i].ID != want {
+						t.Errorf("path %d: crash %v, error %v, want %s", i, crashes[i], errs[i], want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGetCrashLogs(b *testing.B) {
+	fs, err := NewLocalFileStore(filepath.Join(b.TempDir(), "crashes"))
+	if err != nil {
+		b.Fatalf("open file store: %v", err)
+	}
+	paths := saveTestCrashLogs(b, fs, 50)
+
+	for _, bm := range []struct {
+		name        string
+		concurrency int
+	}{
+		{"sequential", 1},
+		{"concurrent", defaultReadConcurrency},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			fs.SetReadConcurrency(bm.concurrency)
+			for i := 0; i < b.N; i++ {
+				if _, errs := fs.GetCrashLogs(context.Background(), paths); errs[0] != nil {
+					b.Fatalf("read crash logs: %v", errs[0])
+				}
+			}
+		})
+	}
+}
+
+func TestLocalFileStoreHealthCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		breakFn func(fs *LocalFileStore)
+		wantErr bool
+	}{
+		{"writable", nil, false},
+		{"directory removed", func(fs *LocalFileStore) { os.RemoveAll(fs.basePath) }, true},
+		{"not a directory", func(fs *LocalFileStore) {
+			os.RemoveAll(fs.basePath)
+			os.WriteFile(fs.basePath, []byte("x"), 0o644)
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := newTestFileStore(t)
+			if tt.breakFn != nil {
+				tt.breakFn(fs)
+			}
+
+			err := fs.HealthCheck(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("HealthCheck = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			// The check file is removed
+			if entries, err := os.ReadDir(fs.basePath); err != nil || len(entries) != 0 {
+				t.Errorf("base directory = %v, %v; want it empty", entries, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,1692 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flakerimi/inceptor/internal/core"
+	"github.com/lib/pq"
+)
+
+// PostgresRepository is a Repository backed by PostgreSQL. Unlike SQLite it
+// allows concurrent writers, for deployments with many apps submitting at
+// once.
+//
+// Dashboard reads (crash and group lists, lookups by ID and stats) can be
+// served by read replicas. Writes, and the reads that ingestion and
+// authentication depend on, always use the primary.
+type PostgresRepository struct {
+	*readRouter
+}
+
+// NewPostgresRepository connects to the primary database at dsn, and to
+// read replicas at replicaDSNs if any. Crashes and groups are read from the
+// primary for readAfterWrite after they are written.
+func NewPostgresRepository(dsn string, replicaDSNs []string, readAfterWrite time.Duration) (*PostgresRepository, error) {
+	db, err := openPostgres(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	var replicas []*sql.DB
+	closeAll := func() {
+		db.Close()
+		for _, replica := range replicas {
+			replica.Close()
+		}
+	}
+	for i, replicaDSN := range replicaDSNs {
+		replica, err := openPostgres(replicaDSN)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("replica %d: %w", i+1, err)
+		}
+		replicas = append(replicas, replica)
+	}
+
+	repo := newPostgresRepository(db, replicas, readAfterWrite)
+	if err := repo.Migrate(); err != nil {
+		closeAll()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	return repo, nil
+}
+
+// newPostgresRepository creates a PostgresRepository on open connection pools
+func newPostgresRepository(db *sql.DB, replicas []*sql.DB, readAfterWrite time.Duration) *PostgresRepository {
+	return &PostgresRepository{readRouter: newReadRouter(db, replicas, readAfterWrite)}
+}
+
+// openPostgres opens a connection pool to dsn and checks it can connect
+func openPostgres(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// Set connection pool settings
+	db.SetMaxOpenConns(25)
+	db.SetMaxIdleConns(5)
+	db.SetConnMaxLifetime(time.Hour)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	return db, nil
+}
+
+func (r *PostgresRepository) Migrate() error {
+	migrations := []string{
+		`CREATE TABLE IF NOT EXISTS apps (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			api_key_hash TEXT UNIQUE NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL,
+			retention_days INTEGER DEFAULT 30,
+			include_framework_frames BOOLEAN DEFAULT FALSE,
+			fingerprint_metadata_keys TEXT,
+			max_groups INTEGER DEFAULT 0,
+			assignment_rules TEXT,
+			disabled BOOLEAN DEFAULT FALSE
+		)`,
+		`CREATE TABLE IF NOT EXISTS crash_groups (
+			id TEXT PRIMARY KEY,
+			app_id TEXT NOT NULL REFERENCES apps(id),
+			fingerprint TEXT NOT NULL,
+			title TEXT,
+			error_type TEXT,
+			error_message TEXT,
+			first_seen TIMESTAMPTZ NOT NULL,
+			last_seen TIMESTAMPTZ NOT NULL,
+			occurrence_count INTEGER DEFAULT 1,
+			status TEXT DEFAULT 'open',
+			assigned_to TEXT,
+			notes TEXT,
+			aggregate_only BOOLEAN DEFAULT FALSE,
+			UNIQUE(app_id, fingerprint)
+		)`,
+		// group_id is not a foreign key since quarantined crashes have none
+		`CREATE TABLE IF NOT EXISTS crashes (
+			id TEXT PRIMARY KEY,
+			app_id TEXT NOT NULL REFERENCES apps(id),
+			event_id TEXT,
+			app_version TEXT,
+			platform TEXT,
+			os_version TEXT,
+			device_model TEXT,
+			error_type TEXT,
+			error_message TEXT,
+			fingerprint TEXT NOT NULL,
+			fingerprint_version INTEGER DEFAULT 1,
+			group_id TEXT,
+			user_id TEXT,
+			environment TEXT,
+			created_at TIMESTAMPTZ NOT NULL,
+			log_file_path TEXT,
+			metadata JSONB,
+			quarantine_reason TEXT,
+			country TEXT
+		)`,
+		`CREATE TABLE IF NOT EXISTS alerts (
+			id TEXT PRIMARY KEY,
+			app_id TEXT NOT NULL REFERENCES apps(id),
+			type TEXT NOT NULL,
+			config TEXT,
+			enabled BOOLEAN DEFAULT TRUE,
+			created_at TIMESTAMPTZ NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS settings (
+			key TEXT PRIMARY KEY,
+			value TEXT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS app_versions (
+			app_id TEXT NOT NULL,
+			version TEXT NOT NULL,
+			first_seen TIMESTAMPTZ NOT NULL,
+			PRIMARY KEY (app_id, version)
+		)`,
+		// Fingerprints of groups merged into another, so their later
+		// crashes join the group they were merged into
+		`CREATE TABLE IF NOT EXISTS merged_fingerprints (
+			app_id TEXT NOT NULL,
+			fingerprint TEXT NOT NULL,
+			group_id TEXT NOT NULL,
+			PRIMARY KEY (app_id, fingerprint)
+		)`,
+		// Dashboard accounts
+		`CREATE TABLE IF NOT EXISTS users (
+			id TEXT PRIMARY KEY,
+			username TEXT UNIQUE NOT NULL,
+			password_hash TEXT NOT NULL,
+			role TEXT NOT NULL,
+			must_change_password BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at TIMESTAMPTZ NOT NULL
+		)`,
+		// Dashboard sessions, keyed by the SHA256 of their token
+		`CREATE TABLE IF NOT EXISTS sessions (
+			token_hash TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			username TEXT NOT NULL,
+			role TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL,
+			expires_at TIMESTAMPTZ NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_sessions_expires ON sessions(expires_at)`,
+		// Alert events waiting to be sent, see core.AlertQueueRepository
+		`CREATE TABLE IF NOT EXISTS alert_queue (
+			id BIGSERIAL PRIMARY KEY,
+			event TEXT NOT NULL,
+			alert_id TEXT NOT NULL DEFAULT '',
+			attempts INTEGER NOT NULL DEFAULT 0,
+			next_attempt_at TIMESTAMPTZ NOT NULL,
+			last_error TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_alert_queue_next_attempt_at ON alert_queue(next_attempt_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_crashes_app_id ON crashes(app_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_crashes_group_id ON crashes(group_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_crashes_created_at ON crashes(created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_crashes_fingerprint ON crashes(fingerprint)`,
+		`CREATE INDEX IF NOT EXISTS idx_crashes_event_id ON crashes(app_id, event_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_crashes_app_version ON crashes(app_id, app_version)`,
+		`CREATE INDEX IF NOT EXISTS idx_crashes_country ON crashes(app_id, country)`,
+		`CREATE INDEX IF NOT EXISTS idx_crashes_user_id ON crashes(app_id, user_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_crash_groups_app_id ON crash_groups(app_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_crash_groups_status ON crash_groups(status)`,
+	}
+
+	// Columns added later go here as ALTER TABLE ... ADD COLUMN IF NOT EXISTS,
+	// after the tables above
+	migrations = append(migrations,
+		`ALTER TABLE apps ADD COLUMN IF NOT EXISTS maintenance_windows TEXT`,
+		`ALTER TABLE apps ADD COLUMN IF NOT EXISTS rate_limit TEXT`,
+		`ALTER TABLE apps ADD COLUMN IF NOT EXISTS fingerprint_config TEXT`,
+		`ALTER TABLE crash_groups ADD COLUMN IF NOT EXISTS trend_key DOUBLE PRECISION`,
+		`ALTER TABLE crash_groups ADD COLUMN IF NOT EXISTS regressed_at TIMESTAMPTZ`,
+		`ALTER TABLE apps ADD COLUMN IF NOT EXISTS api_key_scopes TEXT`,
+		`ALTER TABLE apps ADD COLUMN IF NOT EXISTS api_key_expires_at TIMESTAMPTZ`,
+		`ALTER TABLE apps ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMPTZ`,
+		`ALTER TABLE crash_groups ADD COLUMN IF NOT EXISTS split_key TEXT`,
+		`ALTER TABLE crash_groups ADD COLUMN IF NOT EXISTS culprit TEXT`,
+		// Treat existing groups' occurrences as if they all happened when
+		// last seen
+		fmt.Sprintf(`UPDATE crash_groups
+			SET trend_key = EXTRACT(EPOCH FROM last_seen) / %f * LN(2) + LN(GREATEST(occurrence_count, 1))
+			WHERE trend_key IS NULL`, core.TrendHalfLife.Seconds()),
+		`CREATE INDEX IF NOT EXISTS idx_crash_groups_trend_key ON crash_groups(app_id, trend_key)`,
+	)
+
+	for _, migration := range migrations {
+		if _, err := r.db.Exec(migration); err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+	}
+	if err := r.createAPIKeys(); err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+	return nil
+}
+
+// createAPIKeys is SQLiteRepository.createAPIKeys for Postgres
+func (r *PostgresRepository) createAPIKeys() error {
+	var exists bool
+	if err := r.db.QueryRow(`SELECT to_regclass('api_keys') IS NOT NULL`).Scan(&exists); err != nil || exists {
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{
+		`CREATE TABLE api_keys (
+			id TEXT PRIMARY KEY,
+			app_id TEXT NOT NULL REFERENCES apps(id),
+			key_hash TEXT UNIQUE NOT NULL,
+			label TEXT NOT NULL DEFAULT '',
+			scopes TEXT NOT NULL DEFAULT '[]',
+			expires_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ NOT NULL,
+			last_used_at TIMESTAMPTZ
+		)`,
+		`CREATE INDEX idx_api_keys_app_id ON api_keys(app_id)`,
+		seedAPIKeysSQL,
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Ping runs a trivial query, which fails once the database is closed or
+// unreachable
+func (r *PostgresRepository) Ping(ctx context.Context) error {
+	var one int
+	return r.db.QueryRowContext(ctx, "SELECT 1").Scan(&one)
+}
+
+func (r *PostgresRepository) Close() error {
+	return r.close()
+}
+
+// App operations
+
+// pgAppColumns is appColumns for Postgres, in scanApp order
+const pgAppColumns = `id, name, api_key_hash, created_at, retention_days, COALESCE(include_framework_frames, FALSE), COALESCE(fingerprint_metadata_keys, '[]'), COALESCE(max_groups, 0), COALESCE(assignment_rules, '[]'), COALESCE(disabled, FALSE), COALESCE(maintenance_windows, '[]'), COALESCE(rate_limit, '{}'), COALESCE(fingerprint_config, '{}'), last_used_at`
+
+// pgInsertAppSQL is insertAppSQL for Postgres
+const pgInsertAppSQL = `INSERT INTO apps (id, name, api_key_hash, created_at, retention_days, include_framework_frames, fingerprint_metadata_keys, max_groups, assignment_rules, disabled, maintenance_windows, rate_limit, fingerprint_config)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+
+func (r *PostgresRepository) CreateApp(ctx context.Context, app *core.App) error {
+	return r.CreateApps(ctx, []*core.App{app})
+}
+
+func (r *PostgresRepository) CreateApps(ctx context.Context, apps []*core.App) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, app := range apps {
+		if _, err := tx.ExecContext(ctx, pgInsertAppSQL, appInsertArgs(app)...); err != nil {
+			return fmt.Errorf("failed to create app %q: %w", app.Name, err)
+		}
+		if _, err := tx.ExecContext(ctx, pgInsertAPIKeySQL, apiKeyArgs(appAPIKey(app))...); err != nil {
+			return fmt.Errorf("failed to create app %q: %w", app.Name, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *PostgresRepository) GetApp(ctx context.Context, id string) (*core.App, error) {
+	app, err := scanApp(r.db.QueryRowContext(ctx,
+		`SELECT `+pgAppColumns+` FROM apps WHERE id = $1`, id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return app, err
+}
+
+func (r *PostgresRepository) GetAppByAPIKey(ctx context.Context, apiKeyHash string) (*core.App, error) {
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = $1`, apiKeyHash,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	app, err := r.GetApp(ctx, key.AppID)
+	if err != nil || app == nil {
+		return nil, err
+	}
+	app.WithAPIKey(key)
+	return app, nil
+}
+
+func (r *PostgresRepository) ListApps(ctx context.Context) ([]*core.App, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+pgAppColumns+` FROM apps ORDER BY created_at DESC`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var apps []*core.App
+	for rows.Next() {
+		app, err := scanApp(rows)
+		if err != nil {
+			return nil, err
+		}
+		apps = append(apps, app)
+	}
+	return apps, rows.Err()
+}
+
+func (r *PostgresRepository) UpdateApp(ctx context.Context, app *core.App) error {
+	metadataKeys, _ := json.Marshal(app.FingerprintMetadataKeys)
+	assignmentRules, _ := json.Marshal(app.AssignmentRules)
+	maintenanceWindows, _ := json.Marshal(app.MaintenanceWindows)
+	rateLimit, _ := json.Marshal(app.RateLimit)
+	fingerprintConfig, _ := json.Marshal(app.FingerprintConfig)
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE apps SET name = $1, retention_days = $2, include_framework_frames = $3, fingerprint_metadata_keys = $4, max_groups = $5,
+		assignment_rules = $6, disabled = $7, maintenance_windows = $8, rate_limit = $9, fingerprint_config = $10 WHERE id = $11`,
+		app.Name, app.RetentionDays, app.IncludeFrameworkFrames, string(metadataKeys), app.MaxGroups, string(assignmentRules),
+		app.Disabled, string(maintenanceWindows), string(rateLimit), string(fingerprintConfig), app.ID,
+	)
+	return err
+}
+
+func (r *PostgresRepository) DeleteApp(ctx context.Context, id string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Children first, to satisfy the app_id foreign keys
+	for _, query := range []string{
+		`DELETE FROM alerts WHERE app_id = $1`,
+		`DELETE FROM crashes WHERE app_id = $1`,
+		`DELETE FROM merged_fingerprints WHERE app_id = $1`,
+		`DELETE FROM crash_groups WHERE app_id = $1`,
+		`DELETE FROM app_versions WHERE app_id = $1`,
+		`DELETE FROM api_keys WHERE app_id = $1`,
+		`DELETE FROM apps WHERE id = $1`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// API key operations
+
+// pgInsertAPIKeySQL inserts a key's apiKeyArgs
+const pgInsertAPIKeySQL = `INSERT INTO api_keys (` + apiKeyColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+func (r *PostgresRepository) RotateAPIKey(ctx context.Context, key *core.APIKey, oldKeysExpireAt time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE api_keys SET expires_at = $1 WHERE app_id = $2 AND (expires_at IS NULL OR expires_at > $1)`,
+		oldKeysExpireAt, key.AppID,
+	); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, pgInsertAPIKeySQL, apiKeyArgs(key)...); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE apps SET api_key_hash = $1 WHERE id = $2`, key.KeyHash, key.AppID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *PostgresRepository) GetAPIKey(ctx context.Context, appID, id string) (*core.APIKey, error) {
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE app_id = $1 AND id = $2`, appID, id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return key, err
+}
+
+func (r *PostgresRepository) ListAPIKeys(ctx context.Context, appID string) ([]*core.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE app_id = $1 ORDER BY created_at DESC, id`, appID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]*core.APIKey, 0)
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (r *PostgresRepository) UpdateAPIKey(ctx context.Context, key *core.APIKey) error {
+	scopes, _ := json.Marshal(key.Scopes)
+	if key.Scopes == nil {
+		scopes = []byte("[]")
+	}
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE api_keys SET label = $1, scopes = $2, expires_at = $3 WHERE id = $4`,
+		key.Label, string(scopes), key.ExpiresAt, key.ID,
+	)
+	return err
+}
+
+func (r *PostgresRepository) DeleteAPIKey(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM api_keys WHERE id = $1`, id)
+	return err
+}
+
+func (r *PostgresRepository) TouchAPIKey(ctx context.Context, appID, id string, usedAt time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE api_keys SET last_used_at = $1 WHERE id = $2`, usedAt, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE apps SET last_used_at = $1 WHERE id = $2`, usedAt, appID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RecordAppVersion marks an app version as seen, reporting true only the
+// first time the version is recorded
+func (r *PostgresRepository) RecordAppVersion(ctx context.Context, appID, version string, seenAt time.Time) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		`INSERT INTO app_versions (app_id, version, first_seen) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`,
+		appID, version, seenAt,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// ListAppVersions returns an app's most recently first seen versions,
+// newest first
+func (r *PostgresRepository) ListAppVersions(ctx context.Context, appID string, limit int) ([]core.AppVersion, error) {
+	rows, err := r.reader().QueryContext(ctx,
+		`SELECT version, first_seen FROM app_versions WHERE app_id = $1 ORDER BY first_seen DESC, version DESC LIMIT $2`,
+		appID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []core.AppVersion
+	for rows.Next() {
+		var v core.AppVersion
+		if err := rows.Scan(&v.Version, &v.FirstSeen); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// Crash operations
+
+// pgCrashColumns is crashColumns for Postgres, in scanCrash order
+const pgCrashColumns = `id, app_id, COALESCE(event_id, ''), app_version, platform, os_version, device_model, error_type, error_message, fingerprint, COALESCE(fingerprint_version, 1), COALESCE(group_id, ''), user_id, environment, created_at, log_file_path, COALESCE(metadata, '{}'), COALESCE(quarantine_reason, ''), COALESCE(country, '')`
+
+func (r *PostgresRepository) CreateCrash(ctx context.Context, crash *core.Crash) error {
+	metadata, _ := json.Marshal(crash.Metadata)
+	quarantineReason := sql.NullString{String: crash.QuarantineReason, Valid: crash.QuarantineReason != ""}
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO crashes (id, app_id, event_id, app_version, platform, os_version, device_model, error_type, error_message, fingerprint, fingerprint_version, group_id, user_id, environment, created_at, log_file_path, metadata, quarantine_reason, country)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)`,
+		crash.ID, crash.AppID, crash.EventID, crash.AppVersion, crash.Platform, crash.OSVersion, crash.DeviceModel,
+		crash.ErrorType, crash.ErrorMessage, crash.Fingerprint, crash.FingerprintVersion, crash.GroupID, crash.UserID,
+		crash.Environment, crash.CreatedAt, crash.LogFilePath, string(metadata), quarantineReason, crash.Country,
+	)
+	if err == nil {
+		r.wrote(crash.ID)
+	}
+	return err
+}
+
+// ReleaseCrash clears a crash's quarantine and attaches it to a group
+func (r *PostgresRepository) ReleaseCrash(ctx context.Context, id, groupID string) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE crashes SET quarantine_reason = NULL, group_id = $1 WHERE id = $2`,
+		groupID, id,
+	)
+	if err == nil {
+		r.wrote(id, groupID)
+	}
+	return err
+}
+
+func (r *PostgresRepository) GetCrash(ctx context.Context, id string) (*core.Crash, error) {
+	crash, err := scanCrash(r.reader(id).QueryRowContext(ctx,
+		`SELECT `+pgCrashColumns+` FROM crashes WHERE id = $1`, id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return crash, nil
+}
+
+// GetCrashesByIDs returns the crashes with the given IDs, in no particular
+// order. Unknown IDs are skipped.
+func (r *PostgresRepository) GetCrashesByIDs(ctx context.Context, ids []string) ([]*core.Crash, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	rows, err := r.reader(ids...).QueryContext(ctx,
+		`SELECT `+pgCrashColumns+` FROM crashes WHERE id = ANY($1)`, pq.Array(ids),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanCrashRows(rows)
+}
+
+// ListCrashesCreatedBetween reads from the primary: exports page through
+// crashes by cursor, and a lagging replica would skip the crashes it hasn't
+// caught up with yet.
+func (r *PostgresRepository) ListCrashesCreatedBetween(ctx context.Context, after time.Time, afterID string, before time.Time, limit int) ([]*core.Crash, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+pgCrashColumns+` FROM crashes
+		WHERE (created_at, id) > ($1, $2) AND created_at < $3
+		ORDER BY created_at ASC, id ASC LIMIT $4`,
+		after, afterID, before, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanCrashRows(rows)
+}
+
+// GetCrashByEventID returns the most recent crash for an app with the given
+// client event ID created at or after since. A zero since matches any age.
+func (r *PostgresRepository) GetCrashByEventID(ctx context.Context, appID, eventID string, since time.Time) (*core.Crash, error) {
+	query := `SELECT ` + pgCrashColumns + ` FROM crashes WHERE app_id = $1 AND event_id = $2`
+	args := []interface{}{appID, eventID}
+	if !since.IsZero() {
+		query += " AND created_at >= $3"
+		args = append(args, since)
+	}
+	query += " ORDER BY created_at DESC LIMIT 1"
+
+	crash, err := scanCrash(r.db.QueryRowContext(ctx, query, args...))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return crash, nil
+}
+
+// pgArgs collects query arguments, returning the placeholder for each
+type pgArgs []interface{}
+
+func (a *pgArgs) add(v interface{}) string {
+	*a = append(*a, v)
+	return fmt.Sprintf("$%d", len(*a))
+}
+
+func (r *PostgresRepository) ListCrashes(ctx context.Context, filter CrashFilter) ([]*core.Crash, int, error) {
+	var conditions []string
+	var args pgArgs
+
+	if filter.Quarantined {
+		conditions = append(conditions, "quarantine_reason IS NOT NULL")
+	} else {
+		conditions = append(conditions, "quarantine_reason IS NULL")
+	}
+	if filter.AppID != "" {
+		conditions = append(conditions, "app_id = "+args.add(filter.AppID))
+	}
+	if filter.GroupID != "" {
+		conditions = append(conditions, "group_id = "+args.add(filter.GroupID))
+	}
+	if filter.Platform != "" {
+		conditions = append(conditions, "platform = "+args.add(filter.Platform))
+	}
+	if filter.Environment != "" {
+		conditions = append(conditions, "environment = "+args.add(filter.Environment))
+	}
+	if filter.ErrorType != "" {
+		conditions = append(conditions, "error_type = "+args.add(filter.ErrorType))
+	}
+	if filter.UserID != "" {
+		conditions = append(conditions, "user_id = "+args.add(filter.UserID))
+	}
+	if filter.Country != "" {
+		conditions = append(conditions, "country = "+args.add(strings.ToUpper(filter.Country)))
+	}
+	if filter.FromDate != nil {
+		conditions = append(conditions, "created_at >= "+args.add(*filter.FromDate))
+	}
+	if filter.ToDate != nil {
+		conditions = append(conditions, "created_at <= "+args.add(*filter.ToDate))
+	}
+	if filter.Search != "" {
+		// ILIKE matches SQLite's case-insensitive LIKE
+		searchTerm := args.add("%" + filter.Search + "%")
+		conditions = append(conditions, "(error_type ILIKE "+searchTerm+" OR error_message ILIKE "+searchTerm+")")
+	}
+	for _, key := range filter.HasMetadata {
+		// ? matches keys whose value is JSON null too
+		conditions = append(conditions, "metadata ? "+args.add(key))
+	}
+
+	whereClause := ""
+	if len(conditions) > 0 {
+		whereClause = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	// The count and the page come from the same pool, so they agree
+	db := r.reader()
+
+	// Get total count
+	var total int
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM crashes %s", whereClause)
+	if err := db.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// Get paginated results
+	if filter.Limit == 0 {
+		filter.Limit = 50
+	}
+	if filter.Cursor != "" {
+		after, afterID, err := DecodeCursor(filter.Cursor)
+		if err != nil {
+			return nil, 0, err
+		}
+		cursorCondition := fmt.Sprintf("(created_at, id) < (%s, %s)", args.add(after), args.add(afterID))
+		if whereClause == "" {
+			whereClause = "WHERE " + cursorCondition
+		} else {
+			whereClause += " AND " + cursorCondition
+		}
+		filter.Offset = 0
+	}
+	query := fmt.Sprintf(
+		`SELECT %s FROM crashes %s ORDER BY created_at DESC, id DESC LIMIT %s OFFSET %s`,
+		pgCrashColumns, whereClause, args.add(filter.Limit), args.add(filter.Offset),
+	)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	crashes, err := scanCrashRows(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	return crashes, total, nil
+}
+
+// scanCrashRows scans and closes rows selected with pgCrashColumns
+func scanCrashRows(rows *sql.Rows) ([]*core.Crash, error) {
+	defer rows.Close()
+
+	var crashes []*core.Crash
+	for rows.Next() {
+		crash, err := scanCrash(rows)
+		if err != nil {
+			return nil, err
+		}
+		crashes = append(crashes, crash)
+	}
+	return crashes, rows.Err()
+}
+
+func (r *PostgresRepository) CountCrashesInWindow(ctx context.Context, groupID string, since time.Time) (int, error) {
+	var count int
+	err := r.reader().QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM crashes WHERE group_id = $1 AND created_at >= $2`, groupID, since,
+	).Scan(&count)
+	return count, err
+}
+
+func (r *PostgresRepository) DeleteCrash(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM crashes WHERE id = $1`, id)
+	if err == nil {
+		r.wrote(id)
+	}
+	return err
+}
+
+func (r *PostgresRepository) DeleteCrashesOlderThan(ctx context.Context, appID string, before time.Time) (int, error) {
+	result, err := r.db.ExecContext(ctx,
+		`DELETE FROM crashes WHERE app_id = $1 AND created_at < $2`, appID, before,
+	)
+	if err != nil {
+		return 0, err
+	}
+	count, _ := result.RowsAffected()
+	return int(count), nil
+}
+
+// Crash group operations
+
+// pgGroupColumns is groupColumns for Postgres, in scanGroup order
+const pgGroupColumns = `id, app_id, fingerprint, COALESCE(title, ''), error_type, error_message, first_seen, last_seen, occurrence_count, status, assigned_to, notes, COALESCE(aggregate_only, FALSE), COALESCE(trend_key, 0), regressed_at, COALESCE(split_key, ''), COALESCE(culprit, '')`
+
+func (r *PostgresRepository) GetOrCreateGroup(ctx context.Context, crash *core.Crash) (*core.CrashGroup, bool, error) {
+	// A single upsert, so concurrent writers can't both create the group.
+	// xmax is 0 only for freshly inserted rows. Historical crashes leave an
+	// existing group untouched. A fingerprint merged into another group
+	// conflicts with that group's. A resolved group is reopened, and only
+	// the crash that reopened it finds regressed_at set to its own time.
+	row := r.db.QueryRowContext(ctx,
+		`INSERT INTO crash_groups (id, app_id, fingerprint, title, error_type, error_message, first_seen, last_seen, occurrence_count, status, trend_key, culprit)
+		VALUES ($1, $2, COALESCE((
+			SELECT g.fingerprint FROM merged_fingerprints m JOIN crash_groups g ON g.id = m.group_id
+			WHERE m.app_id = $2 AND m.fingerprint = $3
+		), $3), $4, $5, $6, $7, $7, 1, $8, $10, $12)
+		ON CONFLICT (app_id, fingerprint) DO UPDATE
+			SET last_seen = CASE WHEN $9 THEN crash_groups.last_seen ELSE EXCLUDED.last_seen END,
+				occurrence_count = crash_groups.occurrence_count + CASE WHEN $9 THEN 0 ELSE 1 END,
+				trend_key = CASE WHEN $9 THEN crash_groups.trend_key ELSE `+pgAddTrend("crash_groups.trend_key", "$10")+` END,
+				status = CASE WHEN NOT $9 AND crash_groups.status = $11 THEN $8 ELSE crash_groups.status END,
+				regressed_at = CASE WHEN NOT $9 AND crash_groups.status = $11 THEN EXCLUDED.last_seen ELSE crash_groups.regressed_at END
+		RETURNING `+pgGroupColumns+`, (xmax = 0), COALESCE(regressed_at = $7, FALSE)`,
+		crash.GroupID, crash.AppID, crash.Fingerprint, crash.GroupTitle, crash.ErrorType, crash.ErrorMessage,
+		crash.CreatedAt, string(core.GroupStatusOpen), crash.Historical, core.TrendKey(crash.CreatedAt),
+		string(core.GroupStatusResolved), crash.GroupCulprit,
+	)
+
+	group := &core.CrashGroup{}
+	var assignedTo, notes sql.NullString
+	var regressedAt sql.NullTime
+	var created, regressed bool
+	if err := row.Scan(&group.ID, &group.AppID, &group.Fingerprint, &group.Title, &group.ErrorType, &group.ErrorMessage,
+		&group.FirstSeen, &group.LastSeen, &group.OccurrenceCount, &group.Status, &assignedTo, &notes,
+		&group.AggregateOnly, &group.TrendKey, &regressedAt, &group.SplitKey, &group.Culprit, &created, &regressed); err != nil {
+		return nil, false, err
+	}
+	group.AssignedTo = assignedTo.String
+	group.Notes = notes.String
+	if regressedAt.Valid {
+		group.RegressedAt = &regressedAt.Time
+	}
+	if regressed && !created {
+		group.PreviousStatus = string(core.GroupStatusResolved)
+	}
+	r.wrote(group.ID)
+	return group, created, nil
+}
+
+// pgAddTrend returns SQL adding the occurrences of trend key param to a
+// trend key column (see core.AddTrend). EXP is bounded since Postgres
+// raises an error on underflow.
+func pgAddTrend(column, param string) string {
+	key := "COALESCE(" + column + ", 0)"
+	return "GREATEST(" + key + ", " + param + ") + LN(1 + EXP(-LEAST(ABS(" + key + " - " + param + "), 700)))"
+}
+
+func (r *PostgresRepository) GetGroup(ctx context.Context, id string) (*core.CrashGroup, error) {
+	group, err := scanGroup(r.reader(id).QueryRowContext(ctx,
+		`SELECT `+pgGroupColumns+` FROM crash_groups WHERE id = $1`, id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return group, err
+}
+
+// SummarizeGroupCrashes sets each group's AffectedUsers and LatestCrashID
+// from its stored crashes
+func (r *PostgresRepository) SummarizeGroupCrashes(ctx context.Context, groups []*core.CrashGroup) error {
+	if len(groups) == 0 {
+		return nil
+	}
+	byID := make(map[string]*core.CrashGroup, len(groups))
+	ids := make([]string, 0, len(groups))
+	for _, g := range groups {
+		byID[g.ID] = g
+		ids = append(ids, g.ID)
+	}
+
+	rows, err := r.reader(ids...).QueryContext(ctx,
+		`SELECT s.group_id, s.users, latest.id FROM (
+			SELECT group_id, COUNT(DISTINCT NULLIF(user_id, '')) AS users FROM crashes
+			WHERE group_id = ANY($1) GROUP BY group_id
+		) s CROSS JOIN LATERAL (
+			SELECT id FROM crashes WHERE group_id = s.group_id ORDER BY created_at DESC, id DESC LIMIT 1
+		) latest`, pq.Array(ids),
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var groupID, latestID string
+		var users int
+		if err := rows.Scan(&groupID, &users, &latestID); err != nil {
+			return err
+		}
+		if g := byID[groupID]; g != nil {
+			g.AffectedUsers = users
+			g.LatestCrashID = latestID
+		}
+	}
+	return rows.Err()
+}
+
+func (r *PostgresRepository) CountGroups(ctx context.Context, appID string) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM crash_groups WHERE app_id = $1`, appID,
+	).Scan(&count)
+	return count, err
+}
+
+func (r *PostgresRepository) DeleteGroup(ctx context.Context, id string) ([]string, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	paths, err := pgDeleteGroupTx(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	r.wrote(id)
+	return paths, nil
+}
+
+func (r *PostgresRepository) EvictClosedGroups(ctx context.Context, appID string, limit int) (int, []string, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+
+	// SKIP LOCKED lets concurrent evictions pick different groups
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id FROM crash_groups WHERE app_id = $1 AND status IN ($2, $3)
+		ORDER BY last_seen ASC LIMIT $4 FOR UPDATE SKIP LOCKED`,
+		appID, string(core.GroupStatusResolved), string(core.GroupStatusIgnored), limit,
+	)
+	if err != nil {
+		return 0, nil, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	var paths []string
+	for _, id := range ids {
+		groupPaths, err := pgDeleteGroupTx(ctx, tx, id)
+		if err != nil {
+			return 0, nil, err
+		}
+		paths = append(paths, groupPaths...)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, nil, err
+	}
+	r.wrote(ids...)
+	return len(ids), paths, nil
+}
+
+// pgDeleteGroupTx deletes a group and its crashes within tx, returning the
+// deleted crashes' log file paths
+func pgDeleteGroupTx(ctx context.Context, tx *sql.Tx, id string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx,
+		`DELETE FROM crashes WHERE group_id = $1 RETURNING COALESCE(log_file_path, '')`, id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM merged_fingerprints WHERE group_id = $1`, id); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM crash_groups WHERE id = $1`, id); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+func (r *PostgresRepository) MergeGroups(ctx context.Context, sourceID, targetID string) (*core.CrashGroup, error) {
+	if sourceID == targetID {
+		return nil, ErrMergeSameGroup
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Lock both groups so concurrent crashes can't count toward the source
+	// after its occurrences are moved
+	var groups [2]*core.CrashGroup
+	for i, id := range []string{sourceID, targetID} {
+		groups[i], err = scanGroup(tx.QueryRowContext(ctx,
+			`SELECT `+pgGroupColumns+` FROM crash_groups WHERE id = $1 FOR UPDATE`, id,
+		))
+		if err == sql.ErrNoRows {
+			return nil, ErrGroupNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	source, target := groups[0], groups[1]
+	if source.AppID != target.AppID {
+		return nil, ErrMergeDifferentApps
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE crashes SET group_id = $1 WHERE group_id = $2`, target.ID, source.ID,
+	); err != nil {
+		return nil, err
+	}
+
+	target.OccurrenceCount += source.OccurrenceCount
+	if source.FirstSeen.Before(target.FirstSeen) {
+		target.FirstSeen = source.FirstSeen
+	}
+	if source.LastSeen.After(target.LastSeen) {
+		target.LastSeen = source.LastSeen
+	}
+	target.TrendKey = core.AddTrend(target.TrendKey, source.TrendKey)
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE crash_groups SET occurrence_count = $1, first_seen = $2, last_seen = $3, trend_key = $4 WHERE id = $5`,
+		target.OccurrenceCount, target.FirstSeen, target.LastSeen, target.TrendKey, target.ID,
+	); err != nil {
+		return nil, err
+	}
+
+	// Route the source's fingerprint, and any merged into it earlier, to the target
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE merged_fingerprints SET group_id = $1 WHERE group_id = $2`, target.ID, source.ID,
+	); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO merged_fingerprints (app_id, fingerprint, group_id) VALUES ($1, $2, $3)
+		ON CONFLICT (app_id, fingerprint) DO UPDATE SET group_id = EXCLUDED.group_id`,
+		source.AppID, source.Fingerprint, target.ID,
+	); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM crash_groups WHERE id = $1`, source.ID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	r.wrote(source.ID, target.ID)
+	return target, nil
+}
+
+func (r *PostgresRepository) SplitGroup(ctx context.Context, groupID string, crashIDs []string, newGroup *core.CrashGroup) (*core.CrashGroup, *core.CrashGroup, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	// Lock the group so concurrent crashes wait for the recomputed count
+	group, err := scanGroup(tx.QueryRowContext(ctx,
+		`SELECT `+pgGroupColumns+` FROM crash_groups WHERE id = $1 FOR UPDATE`, groupID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil, ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO crash_groups (id, app_id, fingerprint, title, error_type, error_message, first_seen, last_seen, occurrence_count, status, assigned_to, notes, split_key, culprit)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, 0, $9, $10, $11, $12, $13)`,
+		newGroup.ID, group.AppID, newGroup.Fingerprint, newGroup.Title, newGroup.ErrorType, newGroup.ErrorMessage,
+		group.FirstSeen, group.LastSeen, newGroup.Status, newGroup.AssignedTo, newGroup.Notes, newGroup.SplitKey, newGroup.Culprit,
+	); err != nil {
+		return nil, nil, err
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE crashes SET group_id = $1 WHERE group_id = $2 AND id = ANY($3)`,
+		newGroup.ID, group.ID, pq.Array(crashIDs),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	if moved, err := result.RowsAffected(); err != nil {
+		return nil, nil, err
+	} else if int(moved) != len(crashIDs) {
+		return nil, nil, ErrCrashNotInGroup
+	}
+
+	split := *newGroup
+	split.AppID = group.AppID
+	var remaining int
+	for _, g := range []*core.CrashGroup{group, &split} {
+		var count int
+		var first, last sql.NullTime
+		if err := tx.QueryRowContext(ctx,
+			`SELECT COUNT(*), MIN(created_at), MAX(created_at) FROM crashes WHERE group_id = $1`, g.ID,
+		).Scan(&count, &first, &last); err != nil {
+			return nil, nil, err
+		}
+		if g == group {
+			remaining = count
+			// Occurrences without a stored crash, such as aggregate-only
+			// ones, stay with the original group
+			count = max(group.OccurrenceCount-len(crashIDs), count)
+		}
+		g.OccurrenceCount, g.FirstSeen, g.LastSeen = count, first.Time, last.Time
+	}
+	if remaining == 0 {
+		return nil, nil, ErrSplitWholeGroup
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT created_at FROM crashes WHERE group_id = $1`, split.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for rows.Next() {
+		var createdAt time.Time
+		if err := rows.Scan(&createdAt); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		split.TrendKey = core.AddTrend(split.TrendKey, core.TrendKey(createdAt))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	group.TrendKey = core.SubTrend(group.TrendKey, split.TrendKey)
+
+	for _, g := range []*core.CrashGroup{group, &split} {
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE crash_groups SET occurrence_count = $1, first_seen = $2, last_seen = $3, trend_key = $4 WHERE id = $5`,
+			g.OccurrenceCount, g.FirstSeen, g.LastSeen, g.TrendKey, g.ID,
+		); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	r.wrote(append([]string{group.ID, split.ID}, crashIDs...)...)
+	return group, &split, nil
+}
+
+func (r *PostgresRepository) ListGroups(ctx context.Context, filter GroupFilter) ([]*core.CrashGroup, int, error) {
+	var conditions []string
+	var args pgArgs
+
+	if filter.AppID != "" {
+		conditions = append(conditions, "app_id = "+args.add(filter.AppID))
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = "+args.add(filter.Status))
+	}
+	if filter.ErrorType != "" {
+		conditions = append(conditions, "error_type = "+args.add(filter.ErrorType))
+	}
+	if filter.Search != "" {
+		searchTerm := args.add("%" + filter.Search + "%")
+		conditions = append(conditions,
+			"(error_type ILIKE "+searchTerm+" OR error_message ILIKE "+searchTerm+" OR title ILIKE "+searchTerm+")")
+	}
+	if filter.StaleDays > 0 {
+		conditions = append(conditions, fmt.Sprintf(
+			"status = %s AND first_seen <= %s AND (assigned_to IS NULL OR assigned_to = '')",
+			args.add(string(core.GroupStatusOpen)), args.add(time.Now().AddDate(0, 0, -filter.StaleDays)),
+		))
+	}
+
+	whereClause := ""
+	if len(conditions) > 0 {
+		whereClause = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	// The count and the page come from the same pool, so they agree
+	db := r.reader()
+
+	// Get total count
+	var total int
+	if err := db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM crash_groups %s", whereClause), args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// Determine sort
+	sortBy := "last_seen"
+	if filter.SortBy == "trending" {
+		sortBy = "trend_key"
+	} else if filter.SortBy != "" {
+		sortBy = filter.SortBy
+	}
+	sortOrder := "DESC"
+	if filter.SortOrder == "asc" {
+		sortOrder = "ASC"
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = 50
+	}
+	if filter.Cursor != "" {
+		if sortBy != "first_seen" && sortBy != "last_seen" {
+			return nil, 0, ErrCursorSort
+		}
+		after, afterID, err := DecodeCursor(filter.Cursor)
+		if err != nil {
+			return nil, 0, err
+		}
+		op := "<"
+		if sortOrder == "ASC" {
+			op = ">"
+		}
+		cursorCondition := fmt.Sprintf("(%s, id) %s (%s, %s)", sortBy, op, args.add(after), args.add(afterID))
+		if whereClause == "" {
+			whereClause = "WHERE " + cursorCondition
+		} else {
+			whereClause += " AND " + cursorCondition
+		}
+		filter.Offset = 0
+	}
+
+	query := fmt.Sprintf(
+		`SELECT %s FROM crash_groups %s ORDER BY %s %s, id %s LIMIT %s OFFSET %s`,
+		pgGroupColumns, whereClause, sortBy, sortOrder, sortOrder, args.add(filter.Limit), args.add(filter.Offset),
+	)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var groups []*core.CrashGroup
+	for rows.Next() {
+		group, err := scanGroup(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		groups = append(groups, group)
+	}
+	return groups, total, rows.Err()
+}
+
+func (r *PostgresRepository) UpdateGroupStatus(ctx context.Context, id string, status string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE crash_groups SET status = $1 WHERE id = $2`, status, id)
+	if err == nil {
+		r.wrote(id)
+	}
+	return err
+}
+
+func (r *PostgresRepository) UpdateGroup(ctx context.Context, group *core.CrashGroup) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE crash_groups SET status = $1, assigned_to = $2, notes = $3, aggregate_only = $4 WHERE id = $5`,
+		group.Status, group.AssignedTo, group.Notes, group.AggregateOnly, group.ID,
+	)
+	if err == nil {
+		r.wrote(group.ID)
+	}
+	return err
+}
+
+func (r *PostgresRepository) IncrementGroupCount(ctx context.Context, id string) error {
+	now := time.Now()
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE crash_groups SET occurrence_count = occurrence_count + 1, last_seen = $1,
+			trend_key = `+pgAddTrend("trend_key", "$2")+`
+		WHERE id = $3`,
+		now, core.TrendKey(now), id,
+	)
+	if err == nil {
+		r.wrote(id)
+	}
+	return err
+}
+
+// Alert operations
+func (r *PostgresRepository) CreateAlert(ctx context.Context, alert *core.Alert) error {
+	config, _ := json.Marshal(alert.Config)
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO alerts (id, app_id, type, config, enabled, created_at) VALUES ($1, $2, $3, $4, $5, $6)`,
+		alert.ID, alert.AppID, alert.Type, string(config), alert.Enabled, alert.CreatedAt,
+	)
+	return err
+}
+
+func (r *PostgresRepository) GetAlert(ctx context.Context, id string) (*core.Alert, error) {
+	alert := &core.Alert{}
+	var config string
+	err := r.db.QueryRowContext(ctx,
+		`SELECT id, app_id, type, config, enabled, created_at FROM alerts WHERE id = $1`, id,
+	).Scan(&alert.ID, &alert.AppID, &alert.Type, &config, &alert.Enabled, &alert.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	json.Unmarshal([]byte(config), &alert.Config)
+	return alert, nil
+}
+
+func (r *PostgresRepository) ListAlerts(ctx context.Context, appID string) ([]*core.Alert, error) {
+	query := `SELECT id, app_id, type, config, enabled, created_at FROM alerts`
+	var args []interface{}
+	if appID != "" {
+		query += " WHERE app_id = $1"
+		args = append(args, appID)
+	}
+	query += " ORDER BY created_at DESC"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []*core.Alert
+	for rows.Next() {
+		alert := &core.Alert{}
+		var config string
+		if err := rows.Scan(&alert.ID, &alert.AppID, &alert.Type, &config, &alert.Enabled, &alert.CreatedAt); err != nil {
+			return nil, err
+		}
+		json.Unmarshal([]byte(config), &alert.Config)
+		alerts = append(alerts, alert)
+	}
+	return alerts, rows.Err()
+}
+
+func (r *PostgresRepository) UpdateAlert(ctx context.Context, alert *core.Alert) error {
+	config, _ := json.Marshal(alert.Config)
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE alerts SET type = $1, config = $2, enabled = $3 WHERE id = $4`,
+		alert.Type, string(config), alert.Enabled, alert.ID,
+	)
+	return err
+}
+
+func (r *PostgresRepository) DeleteAlert(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM alerts WHERE id = $1`, id)
+	return err
+}
+
+// Stats
+func (r *PostgresRepository) GetAppStats(ctx context.Context, appID string) (*core.CrashStats, error) {
+	stats := &core.CrashStats{AppID: appID}
+	db := r.reader()
+
+	// Crash and group counts in one round trip
+	now := time.Now()
+	db.QueryRowContext(ctx,
+		`SELECT
+			(SELECT COUNT(*) FROM crashes WHERE app_id = $1 AND quarantine_reason IS NULL),
+			(SELECT COUNT(*) FROM crash_groups WHERE app_id = $1),
+			(SELECT COUNT(*) FROM crash_groups WHERE app_id = $1 AND status = 'open'),
+			(SELECT COUNT(*) FROM crashes WHERE app_id = $1 AND quarantine_reason IS NULL AND created_at >= $2),
+			(SELECT COUNT(*) FROM crashes WHERE app_id = $1 AND quarantine_reason IS NULL AND created_at >= $3),
+			(SELECT COUNT(*) FROM crashes WHERE app_id = $1 AND quarantine_reason IS NULL AND created_at >= $4)`,
+		appID, now.Add(-24*time.Hour), now.Add(-7*24*time.Hour), now.Add(-30*24*time.Hour),
+	).Scan(&stats.TotalCrashes, &stats.TotalGroups, &stats.OpenGroups,
+		&stats.CrashesLast24h, &stats.CrashesLast7d, &stats.CrashesLast30d)
+
+	// Top errors
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, error_type, error_message, occurrence_count FROM crash_groups
+		WHERE app_id = $1 ORDER BY occurrence_count DESC LIMIT 5`, appID)
+	if err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var summary core.ErrorSummary
+			rows.Scan(&summary.GroupID, &summary.ErrorType, &summary.ErrorMessage, &summary.Count)
+			stats.TopErrors = append(stats.TopErrors, summary)
+		}
+	}
+
+	// Crashes per fingerprint algorithm version
+	if versions, err := r.CountCrashesByFingerprintVersion(ctx, appID); err == nil {
+		stats.FingerprintVersions = versions
+	}
+
+	// Crash trend (last 30 days), by UTC day
+	rows, err = db.QueryContext(ctx,
+		`SELECT TO_CHAR(DATE_TRUNC('day', created_at AT TIME ZONE 'UTC'), 'YYYY-MM-DD') AS date, COUNT(*) AS count FROM crashes
+		WHERE app_id = $1 AND quarantine_reason IS NULL AND created_at >= $2 GROUP BY date ORDER BY date`,
+		appID, now.Add(-30*24*time.Hour))
+	if err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var point core.TrendPoint
+			rows.Scan(&point.Date, &point.Count)
+			stats.CrashTrend = append(stats.CrashTrend, point)
+		}
+	}
+
+	return stats, nil
+}
+
+func (r *PostgresRepository) ListAppStatsSummaries(ctx context.Context) (map[string]core.AppStatsSummary, error) {
+	// Same counts as GetAppStats, computed for all apps at once
+	rows, err := r.reader().QueryContext(ctx,
+		`SELECT a.id,
+			(SELECT COUNT(*) FROM crashes WHERE app_id = a.id AND quarantine_reason IS NULL),
+			(SELECT COUNT(*) FROM crash_groups WHERE app_id = a.id AND status = 'open'),
+			(SELECT COUNT(*) FROM crashes WHERE app_id = a.id AND quarantine_reason IS NULL AND created_at >= $1)
+		FROM apps a`,
+		time.Now().Add(-24*time.Hour),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summaries := make(map[string]core.AppStatsSummary)
+	for rows.Next() {
+		var appID string
+		var summary core.AppStatsSummary
+		if err := rows.Scan(&appID, &summary.TotalCrashes, &summary.OpenGroups, &summary.CrashesLast24h); err != nil {
+			return nil, err
+		}
+		summaries[appID] = summary
+	}
+	return summaries, rows.Err()
+}
+
+// CountCrashesByGroupForVersion returns the number of crashes in each group
+// for one app version, busiest first
+func (r *PostgresRepository) CountCrashesByGroupForVersion(ctx context.Context, appID, appVersion string) ([]core.ErrorSummary, error) {
+	rows, err := r.reader().QueryContext(ctx,
+		`SELECT g.id, g.error_type, g.error_message, COUNT(*) AS count
+		FROM crashes c JOIN crash_groups g ON g.id = c.group_id
+		WHERE c.app_id = $1 AND c.app_version = $2 AND c.quarantine_reason IS NULL
+		GROUP BY g.id ORDER BY count DESC`,
+		appID, appVersion,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summaries []core.ErrorSummary
+	for rows.Next() {
+		var summary core.ErrorSummary
+		if err := rows.Scan(&summary.GroupID, &summary.ErrorType, &summary.ErrorMessage, &summary.Count); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, rows.Err()
+}
+
+// CountCrashesByGroupForUser returns the number of a user's crashes in each
+// group, busiest first
+func (r *PostgresRepository) CountCrashesByGroupForUser(ctx context.Context, appID, userID string) ([]core.UserCrashGroup, error) {
+	rows, err := r.reader().QueryContext(ctx,
+		`SELECT g.id, g.error_type, g.error_message, g.status, COUNT(*) AS count
+		FROM crashes c JOIN crash_groups g ON g.id = c.group_id
+		WHERE c.app_id = $1 AND c.user_id = $2 AND c.quarantine_reason IS NULL
+		GROUP BY g.id ORDER BY count DESC`,
+		appID, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []core.UserCrashGroup
+	for rows.Next() {
+		var group core.UserCrashGroup
+		if err := rows.Scan(&group.GroupID, &group.ErrorType, &group.ErrorMessage, &group.Status, &group.Count); err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	return groups, rows.Err()
+}
+
+// CountCrashesByFingerprintVersion returns the number of crashes recorded under
+// each fingerprint algorithm version, optionally scoped to an app
+func (r *PostgresRepository) CountCrashesByFingerprintVersion(ctx context.Context, appID string) (map[int]int, error) {
+	query := `SELECT COALESCE(fingerprint_version, 1) AS version, COUNT(*) FROM crashes WHERE quarantine_reason IS NULL`
+	var args []interface{}
+	if appID != "" {
+		query += " AND app_id = $1"
+		args = append(args, appID)
+	}
+	query += " GROUP BY version"
+
+	rows, err := r.reader().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var version, count int
+		if err := rows.Scan(&version, &count); err != nil {
+			return nil, err
+		}
+		counts[version] = count
+	}
+	return counts, rows.Err()
+}
+
+// Settings operations
+func (r *PostgresRepository) GetSetting(ctx context.Context, key string) (string, error) {
+	var value string
+	err := r.db.QueryRowContext(ctx, `SELECT value FROM settings WHERE key = $1`, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return value, err
+}
+
+func (r *PostgresRepository) SetSetting(ctx context.Context, key, value string) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO settings (key, value) VALUES ($1, $2) ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value`,
+		key, value,
+	)
+	return err
+}
+
+// User operations
+
+func (r *PostgresRepository) CreateUser(ctx context.Context, user *core.User) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO users (`+userColumns+`) VALUES ($1, $2, $3, $4, $5, $6)`,
+		user.ID, user.Username, user.PasswordHash, user.Role, user.MustChangePassword, user.CreatedAt,
+	)
+	return err
+}
+
+func (r *PostgresRepository) GetUser(ctx context.Context, id string) (*core.User, error) {
+	user, err := scanUser(r.db.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return user, err
+}
+
+func (r *PostgresRepository) GetUserByUsername(ctx context.Context, username string) (*core.User, error) {
+	user, err := scanUser(r.db.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE username = $1`, username))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return user, err
+}
+
+func (r *PostgresRepository) ListUsers(ctx context.Context) ([]*core.User, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+userColumns+` FROM users ORDER BY username`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]*core.User, 0)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (r *PostgresRepository) UpdateUserPassword(ctx context.Context, id, passwordHash string, mustChange bool) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE users SET password_hash = $1, must_change_password = $2 WHERE id = $3`,
+		passwordHash, mustChange, id,
+	)
+	return err
+}
+
+// Session operations
+
+func (r *PostgresRepository) CreateSession(ctx context.Context, session *core.Session) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO sessions (`+sessionColumns+`) VALUES ($1, $2, $3, $4, $5, $6)`,
+		session.TokenHash, session.UserID, session.Username, session.Role, session.CreatedAt, session.ExpiresAt,
+	)
+	return err
+}
+
+func (r *PostgresRepository) GetSession(ctx context.Context, tokenHash string) (*core.Session, error) {
+	var session core.Session
+	err := r.db.QueryRowContext(ctx, `SELECT `+sessionColumns+` FROM sessions WHERE token_hash = $1`, tokenHash).Scan(
+		&session.TokenHash, &session.UserID, &session.Username, &session.Role, &session.CreatedAt, &session.ExpiresAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *PostgresRepository) DeleteSession(ctx context.Context, tokenHash string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM sessions WHERE token_hash = $1`, tokenHash)
+	return err
+}
+
+func (r *PostgresRepository) DeleteExpiredSessions(ctx context.Context, now time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM sessions WHERE expires_at < $1`, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (r *PostgresRepository) CountActiveSessions(ctx context.Context, now time.Time) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM sessions WHERE expires_at >= $1`, now).Scan(&count)
+	return count, err
+}
+
+// Alert queue operations
+
+func (r *PostgresRepository) EnqueueAlertEvent(ctx context.Context, event *core.QueuedAlertEvent) error {
+	data, err := json.Marshal(event.Event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert event: %w", err)
+	}
+	return r.db.QueryRowContext(ctx,
+		`INSERT INTO alert_queue (event, alert_id, attempts, next_attempt_at, last_error, created_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		string(data), event.AlertID, event.Attempts, event.NextAttemptAt, event.LastError, event.CreatedAt,
+	).Scan(&event.ID)
+}
+
+// ClaimAlertEvents skips rows locked by a concurrent claim, so several
+// servers sharing the database don't claim the same events
+func (r *PostgresRepository) ClaimAlertEvents(ctx context.Context, dueBy, claimUntil time.Time, limit int) ([]*core.QueuedAlertEvent, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`UPDATE alert_queue SET next_attempt_at = $1
+		WHERE id IN (
+			SELECT id FROM alert_queue WHERE next_attempt_at <= $2
+			ORDER BY next_attempt_at, id LIMIT $3 FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, event, alert_id, attempts, next_attempt_at, last_error, created_at`,
+		claimUntil, dueBy, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*core.QueuedAlertEvent
+	for rows.Next() {
+		var event core.QueuedAlertEvent
+		var data string
+		if err := rows.Scan(&event.ID, &data, &event.AlertID, &event.Attempts, &event.NextAttemptAt, &event.LastError, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(data), &event.Event); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal alert event %d: %w", event.ID, err)
+		}
+		events = append(events, &event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// RETURNING doesn't keep the subquery's order
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	return events, nil
+}
+
+func (r *PostgresRepository) RetryAlertEvent(ctx context.Context, id int64, attempts int, nextAttemptAt time.Time, lastError string) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE alert_queue SET attempts = $1, next_attempt_at = $2, last_error = $3 WHERE id = $4`,
+		attempts, nextAttemptAt, lastError, id,
+	)
+	return err
+}
+
+func (r *PostgresRepository) DeleteAlertEvent(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM alert_queue WHERE id = $1`, id)
+	return err
+}
@@ -0,0 +1,236 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flakerimi/inceptor/internal/core"
+)
+
+// recordingDriver is a database/sql driver standing in for Postgres
+// servers. It counts the statements run on each data source name, and
+// answers every query with no rows.
+type recordingDriver struct {
+	mu         sync.Mutex
+	statements map[string]int
+}
+
+var poolRecorder = &recordingDriver{statements: make(map[string]int)}
+
+func init() {
+	sql.Register("recording", poolRecorder)
+}
+
+func (d *recordingDriver) Open(name string) (driver.Conn, error) {
+	return &recordingConn{driver: d, name: name}, nil
+}
+
+func (d *recordingDriver) record(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.statements[name]++
+}
+
+// take returns the statements run per data source name since the last call
+func (d *recordingDriver) take() map[string]int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	statements := d.statements
+	d.statements = make(map[string]int)
+	return statements
+}
+
+type recordingConn struct {
+	driver *recordingDriver
+	name   string
+}
+
+func (c *recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return &recordingStmt{conn: c}, nil
+}
+
+func (c *recordingConn) Close() error              { return nil }
+func (c *recordingConn) Begin() (driver.Tx, error) { return recordingTx{}, nil }
+
+func (c *recordingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.driver.record(c.name)
+	return driver.RowsAffected(1), nil
+}
+
+func (c *recordingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.driver.record(c.name)
+	return noRows{}, nil
+}
+
+type recordingStmt struct {
+	conn *recordingConn
+}
+
+func (s *recordingStmt) Close() error  { return nil }
+func (s *recordingStmt) NumInput() int { return -1 }
+
+func (s *recordingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.conn.driver.record(s.conn.name)
+	return driver.RowsAffected(1), nil
+}
+
+func (s *recordingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.conn.driver.record(s.conn.name)
+	return noRows{}, nil
+}
+
+type recordingTx struct{}
+
+func (recordingTx) Commit() error   { return nil }
+func (recordingTx) Rollback() error { return nil }
+
+type noRows struct{}
+
+func (noRows) Columns() []string              { return nil }
+func (noRows) Close() error                   { return nil }
+func (noRows) Next(dest []driver.Value) error { return io.EOF }
+
+// newRecordingRepository returns a PostgresRepository on recording pools
+// named "primary", "replica-1" and "replica-2"
+func newRecordingRepository(t *testing.T, readAfterWrite time.Duration) *PostgresRepository {
+	t.Helper()
+
+	open := func(name string) *sql.DB {
+		db, err := sql.Open("recording", name)
+		if err != nil {
+			t.Fatalf("open %s: %v", name, err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+	repo := newPostgresRepository(open("primary"), []*sql.DB{open("replica-1"), open("replica-2")}, readAfterWrite)
+	poolRecorder.take()
+	return repo
+}
+
+func TestPostgresReplicaRouting(t *testing.T) {
+	ctx := context.Background()
+	repo := newRecordingRepository(t, time.Minute)
+
+	tests := []struct {
+		name string
+		call func()
+		// replica is whether the statements should run on a replica
+		replica bool
+	}{
+		{"get crash", func() { repo.GetCrash(ctx, "crash-1") }, true},
+		{"get crashes", func() { repo.GetCrashesByIDs(ctx, []string{"crash-1", "crash-2"}) }, true},
+		{"list crashes", func() { repo.ListCrashes(ctx, CrashFilter{AppID: "app-1"}) }, true},
+		{"get group", func() { repo.GetGroup(ctx, "group-1") }, true},
+		{"list groups", func() { repo.ListGroups(ctx, GroupFilter{AppID: "app-1"}) }, true},
+		{"app stats", func() { repo.GetAppStats(ctx, "app-1") }, true},
+		{"app versions", func() { repo.ListAppVersions(ctx, "app-1", 5) }, true},
+		{"version counts", func() { repo.CountCrashesByGroupForVersion(ctx, "app-1", "1.0.0") }, true},
+		{"group crash summaries", func() { repo.SummarizeGroupCrashes(ctx, []*core.CrashGroup{{ID: "group-1"}}) }, true},
+		{"create crash", func() { repo.CreateCrash(ctx, &core.Crash{ID: "crash-9", AppID: "app-1"}) }, false},
+		{"update group", func() { repo.UpdateGroup(ctx, &core.CrashGroup{ID: "group-9", Status: "resolved"}) }, false},
+		{"delete crash", func() { repo.DeleteCrash(ctx, "crash-8") }, false},
+		// Ingestion and authentication can't act on stale data
+		{"get app", func() { repo.GetApp(ctx, "app-1") }, false},
+		{"get app by key", func() { repo.GetAppByAPIKey(ctx, "hash") }, false},
+		{"event ID lookup", func() { repo.GetCrashByEventID(ctx, "app-1", "event-1", time.Time{}) }, false},
+		{"count groups", func() { repo.CountGroups(ctx, "app-1") }, false},
+		{"get session", func() { repo.GetSession(ctx, "token") }, false},
+		// Exports page by cursor, so lag would skip crashes
+		{"export page", func() { repo.ListCrashesCreatedBetween(ctx, time.Time{}, "", time.Now(), 100) }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.call()
+			statements := poolRecorder.take()
+			onReplicas := statements["replica-1"] + statements["replica-2"]
+			if statements["primary"]+onReplicas == 0 {
+				t.Fatalf("no statements recorded")
+			}
+			if tt.replica && statements["primary"] > 0 {
+				t.Errorf("statements = %v, want reads only on replicas", statements)
+			}
+			if !tt.replica && onReplicas > 0 {
+				t.Errorf("statements = %v, want only the primary used", statements)
+			}
+		})
+	}
+}
+
+func TestPostgresReplicasTakeTurns(t *testing.T) {
+	repo := newRecordingRepository(t, time.Minute)
+	for i := 0; i < 6; i++ {
+		repo.GetCrash(context.Background(), "crash-1")
+	}
+	if statements := poolRecorder.take(); statements["replica-1"] != 3 || statements["replica-2"] != 3 {
+		t.Errorf("statements = %v, want reads spread evenly over the replicas", statements)
+	}
+}
+
+func TestPostgresReadYourWrites(t *testing.T) {
+	ctx := context.Background()
+	const window = 100 * time.Millisecond
+	repo := newRecordingRepository(t, window)
+
+	repo.CreateCrash(ctx, &core.Crash{ID: "crash-new", AppID: "app-1"})
+	repo.IncrementGroupCount(ctx, "group-new")
+	poolRecorder.take()
+
+	// pool returns which kind of pool served read
+	pool := func(read func()) string {
+		read()
+		statements := poolRecorder.take()
+		if statements["primary"] > 0 {
+			return "primary"
+		}
+		return "replica"
+	}
+
+	tests := []struct {
+		name string
+		read func()
+		want string
+	}{
+		{"written crash", func() { repo.GetCrash(ctx, "crash-new") }, "primary"},
+		{"batch with a written crash", func() { repo.GetCrashesByIDs(ctx, []string{"crash-old", "crash-new"}) }, "primary"},
+		{"written group", func() { repo.GetGroup(ctx, "group-new") }, "primary"},
+		{"other crash", func() { repo.GetCrash(ctx, "crash-old") }, "replica"},
+		{"other group", func() { repo.GetGroup(ctx, "group-old") }, "replica"},
+	}
+	for _, tt := range tests {
+		if got := pool(tt.read); got != tt.want {
+			t.Errorf("%s: read from %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	// Once the window has passed, replicas are trusted to have caught up
+	time.Sleep(window)
+	if got := pool(func() { repo.GetCrash(ctx, "crash-new") }); got != "replica" {
+		t.Errorf("written crash after the window: read from %s, want replica", got)
+	}
+}
+
+func TestPostgresWithoutReplicas(t *testing.T) {
+	db, err := sql.Open("recording", "primary")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	repo := newPostgresRepository(db, nil, time.Minute)
+	poolRecorder.take()
+
+	repo.GetCrash(context.Background(), "crash-1")
+	repo.ListGroups(context.Background(), GroupFilter{})
+	if statements := poolRecorder.take(); len(statements) != 1 || statements["primary"] == 0 {
+		t.Errorf("statements = %v, want all on the primary", statements)
+	}
+	if len(repo.recentWrites) != 0 {
+		t.Errorf("recent writes tracked without replicas: %v", repo.recentWrites)
+	}
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"database/sql"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// readRouter picks the connection pool for each query of a repository with
+// read replicas. Dashboard reads (crash and group lists, lookups by ID and
+// stats) take turns across the replicas; writes, and the reads that
+// ingestion and authentication depend on, use the primary.
+type readRouter struct {
+	db *sql.DB
+	// replicas take turns serving dashboard reads; empty reads from db
+	replicas []*sql.DB
+	next     atomic.Uint64
+
+	// readAfterWrite is how long a crash or group written through the
+	// repository is read back from the primary, while replicas catch up
+	readAfterWrite time.Duration
+	mu             sync.Mutex
+	// recentWrites maps the IDs of recently written crashes and groups to
+	// when they were written
+	recentWrites map[string]time.Time
+	lastPrune    time.Time
+}
+
+// newReadRouter creates a readRouter for a primary pool and its replicas
+func newReadRouter(db *sql.DB, replicas []*sql.DB, readAfterWrite time.Duration) *readRouter {
+	return &readRouter{
+		db:             db,
+		replicas:       replicas,
+		readAfterWrite: readAfterWrite,
+		recentWrites:   make(map[string]time.Time),
+	}
+}
+
+// reader returns the pool for a dashboard read: the next replica, or the
+// primary when there are no replicas or one of the crashes or groups with
+// ids was written within the read-after-write window, as replicas may not
+// have it yet
+func (r *readRouter) reader(ids ...string) *sql.DB {
+	if len(r.replicas) == 0 {
+		return r.db
+	}
+
+	if len(ids) > 0 {
+		now := time.Now()
+		r.mu.Lock()
+		for _, id := range ids {
+			if at, ok := r.recentWrites[id]; ok && now.Sub(at) < r.readAfterWrite {
+				r.mu.Unlock()
+				return r.db
+			}
+		}
+		r.mu.Unlock()
+	}
+
+	n := r.next.Add(1) - 1
+	return r.replicas[n%uint64(len(r.replicas))]
+}
+
+// wrote records that the crashes or groups with ids were just written, so
+// reader sends reads of them to the primary for the read-after-write window
+func (r *readRouter) wrote(ids ...string) {
+	if len(r.replicas) == 0 || r.readAfterWrite <= 0 {
+		return
+	}
+
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range ids {
+		if id != "" {
+			r.recentWrites[id] = now
+		}
+	}
+
+	// Forget writes the replicas have had time to catch up with
+	if now.Sub(r.lastPrune) >= r.readAfterWrite {
+		for id, at := range r.recentWrites {
+			if now.Sub(at) >= r.readAfterWrite {
+				delete(r.recentWrites, id)
+			}
+		}
+		r.lastPrune = now
+	}
+}
+
+// close closes the replicas and then the primary
+func (r *readRouter) close() error {
+	for _, replica := range r.replicas {
+		replica.Close()
+	}
+	return r.db.Close()
+}
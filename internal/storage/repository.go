@@ -2,19 +2,40 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/flakerimi/inceptor/internal/core"
 )
 
+// Errors returned by MergeGroups and SplitGroup
+var (
+	ErrGroupNotFound      = errors.New("group not found")
+	ErrMergeSameGroup     = errors.New("cannot merge a group into itself")
+	ErrMergeDifferentApps = errors.New("cannot merge groups of different apps")
+	ErrCrashNotInGroup    = errors.New("crash is not in the group")
+	ErrSplitWholeGroup    = errors.New("cannot split every crash off a group")
+)
+
 // Repository defines the interface for all storage operations
 type Repository interface {
 	// Crash operations
 	CreateCrash(ctx context.Context, crash *core.Crash) error
 	GetCrash(ctx context.Context, id string) (*core.Crash, error)
+	GetCrashesByIDs(ctx context.Context, ids []string) ([]*core.Crash, error)
+	GetCrashByEventID(ctx context.Context, appID, eventID string, since time.Time) (*core.Crash, error)
 	ListCrashes(ctx context.Context, filter CrashFilter) ([]*core.Crash, int, error)
 	DeleteCrash(ctx context.Context, id string) error
+	ReleaseCrash(ctx context.Context, id, groupID string) error
 	DeleteCrashesOlderThan(ctx context.Context, appID string, before time.Time) (int, error)
+	CountCrashesByFingerprintVersion(ctx context.Context, appID string) (map[int]int, error)
+	CountCrashesByGroupForVersion(ctx context.Context, appID, appVersion string) ([]core.ErrorSummary, error)
+	CountCrashesByGroupForUser(ctx context.Context, appID, userID string) ([]core.UserCrashGroup, error)
+	// CountCrashesInWindow counts a group's stored crashes created since since
+	CountCrashesInWindow(ctx context.Context, groupID string, since time.Time) (int, error)
+	// ListCrashesCreatedBetween lists crashes in (created_at, id) order that
+	// come after the (after, afterID) position and were created before before
+	ListCrashesCreatedBetween(ctx context.Context, after time.Time, afterID string, before time.Time, limit int) ([]*core.Crash, error)
 
 	// Crash group operations
 	GetOrCreateGroup(ctx context.Context, crash *core.Crash) (*core.CrashGroup, bool, error)
@@ -23,16 +44,57 @@ type Repository interface {
 	UpdateGroupStatus(ctx context.Context, id string, status string) error
 	UpdateGroup(ctx context.Context, group *core.CrashGroup) error
 	IncrementGroupCount(ctx context.Context, id string) error
+	CountGroups(ctx context.Context, appID string) (int, error)
+	// SummarizeGroupCrashes sets each group's AffectedUsers and
+	// LatestCrashID from its stored crashes
+	SummarizeGroupCrashes(ctx context.Context, groups []*core.CrashGroup) error
+	// DeleteGroup deletes a group and its crashes, returning the deleted
+	// crashes' log file paths
+	DeleteGroup(ctx context.Context, id string) ([]string, error)
+	// EvictClosedGroups deletes up to limit of an app's resolved or ignored
+	// groups, least recently seen first, along with their crashes. It returns
+	// the number of groups deleted and the deleted crashes' log file paths.
+	EvictClosedGroups(ctx context.Context, appID string, limit int) (int, []string, error)
+	// MergeGroups moves the source group's crashes and occurrences into the
+	// target and deletes the source, returning the updated target. Later
+	// crashes with the source's fingerprint join the target.
+	MergeGroups(ctx context.Context, sourceID, targetID string) (*core.CrashGroup, error)
+	// SplitGroup moves the given crashes of a group into newGroup, which is
+	// created, and recomputes both groups' occurrence counts and first and
+	// last seen times from their crashes. It returns the updated groups.
+	SplitGroup(ctx context.Context, groupID string, crashIDs []string, newGroup *core.CrashGroup) (*core.CrashGroup, *core.CrashGroup, error)
 
 	// App operations
 	CreateApp(ctx context.Context, app *core.App) error
+	// CreateApps creates several apps in one transaction: either all are
+	// created or none are
+	CreateApps(ctx context.Context, apps []*core.App) error
 	GetApp(ctx context.Context, id string) (*core.App, error)
 	GetAppByAPIKey(ctx context.Context, apiKeyHash string) (*core.App, error)
 	ListApps(ctx context.Context) ([]*core.App, error)
 	UpdateApp(ctx context.Context, app *core.App) error
-	UpdateAppAPIKey(ctx context.Context, id string, newKeyHash string) error
 	DeleteApp(ctx context.Context, id string) error
+
+	// API key operations. GetAppByAPIKey accepts any of an app's keys.
+	// RotateAPIKey adds key as its app's current key and caps the expiry
+	// of the app's other keys at oldKeysExpireAt.
+	RotateAPIKey(ctx context.Context, key *core.APIKey, oldKeysExpireAt time.Time) error
+	GetAPIKey(ctx context.Context, appID, id string) (*core.APIKey, error)
+	ListAPIKeys(ctx context.Context, appID string) ([]*core.APIKey, error)
+	UpdateAPIKey(ctx context.Context, key *core.APIKey) error
+	DeleteAPIKey(ctx context.Context, id string) error
+	// TouchAPIKey sets the last use of an app's API key, and of the app;
+	// see core.APIKeyUsageTracker
+	TouchAPIKey(ctx context.Context, appID, id string, usedAt time.Time) error
+
 	GetAppStats(ctx context.Context, appID string) (*core.CrashStats, error)
+	// ListAppStatsSummaries returns summary statistics for every app, keyed
+	// by app ID, in a single query
+	ListAppStatsSummaries(ctx context.Context) (map[string]core.AppStatsSummary, error)
+	RecordAppVersion(ctx context.Context, appID, version string, seenAt time.Time) (bool, error)
+	// ListAppVersions returns an app's most recently first seen versions,
+	// newest first
+	ListAppVersions(ctx context.Context, appID string, limit int) ([]core.AppVersion, error)
 
 	// Alert operations
 	CreateAlert(ctx context.Context, alert *core.Alert) error
@@ -45,9 +107,31 @@ type Repository interface {
 	GetSetting(ctx context.Context, key string) (string, error)
 	SetSetting(ctx context.Context, key, value string) error
 
+	// User operations, for dashboard accounts
+	CreateUser(ctx context.Context, user *core.User) error
+	GetUser(ctx context.Context, id string) (*core.User, error)
+	GetUserByUsername(ctx context.Context, username string) (*core.User, error)
+	ListUsers(ctx context.Context) ([]*core.User, error)
+	UpdateUserPassword(ctx context.Context, id, passwordHash string, mustChange bool) error
+
+	// Session operations, for dashboard sessions that survive restarts
+	CreateSession(ctx context.Context, session *core.Session) error
+	GetSession(ctx context.Context, tokenHash string) (*core.Session, error)
+	DeleteSession(ctx context.Context, tokenHash string) error
+	DeleteExpiredSessions(ctx context.Context, now time.Time) (int64, error)
+	CountActiveSessions(ctx context.Context, now time.Time) (int, error)
+
+	// Alert queue operations, for the durable alert queue
+	EnqueueAlertEvent(ctx context.Context, event *core.QueuedAlertEvent) error
+	ClaimAlertEvents(ctx context.Context, dueBy, claimUntil time.Time, limit int) ([]*core.QueuedAlertEvent, error)
+	RetryAlertEvent(ctx context.Context, id int64, attempts int, nextAttemptAt time.Time, lastError string) error
+	DeleteAlertEvent(ctx context.Context, id int64) error
+
 	// Lifecycle
 	Close() error
 	Migrate() error
+	// Ping checks that the database answers a query
+	Ping(ctx context.Context) error
 }
 
 // CrashFilter defines filters for listing crashes
@@ -58,11 +142,17 @@ type CrashFilter struct {
 	Environment string
 	ErrorType   string
 	UserID      string
+	Country     string // ISO country code from GeoIP enrichment
 	FromDate    *time.Time
 	ToDate      *time.Time
 	Search      string
+	HasMetadata []string // metadata keys that must be present, regardless of value
+	Quarantined bool     // list quarantined crashes instead of normal ones
 	Offset      int
 	Limit       int
+	// Cursor, when set, pages by keyset from the crash it encodes (see
+	// EncodeCursor) and Offset is ignored
+	Cursor string
 }
 
 // GroupFilter defines filters for listing crash groups
@@ -71,10 +161,16 @@ type GroupFilter struct {
 	Status    string
 	ErrorType string
 	Search    string
+	// StaleDays, when set, limits results to open, unassigned groups first
+	// seen at least this many days ago
+	StaleDays int
 	Offset    int
 	Limit     int
-	SortBy    string // first_seen, last_seen, occurrence_count
+	SortBy    string // first_seen, last_seen, occurrence_count, trending
 	SortOrder string // asc, desc
+	// Cursor, when set, pages by keyset from the group it encodes (see
+	// EncodeCursor) and Offset is ignored. It requires a time SortBy.
+	Cursor string
 }
 
 // FileStore defines the interface for file-based storage
@@ -85,6 +181,10 @@ type FileStore interface {
 	// GetCrashLog retrieves the full crash payload from a file
 	GetCrashLog(ctx context.Context, filePath string) (*core.Crash, error)
 
+	// GetCrashLogs retrieves several crash payloads concurrently, in order,
+	// with a per-file error for any that couldn't be read
+	GetCrashLogs(ctx context.Context, filePaths []string) ([]*core.Crash, []error)
+
 	// DeleteCrashLog deletes a crash log file
 	DeleteCrashLog(ctx context.Context, filePath string) error
 
@@ -93,6 +193,22 @@ type FileStore interface {
 
 	// GetStorageStats returns storage statistics
 	GetStorageStats(ctx context.Context, appID string) (*StorageStats, error)
+
+	// ScanCorruptLogs reports an app's crash files that can't be decoded,
+	// along with the number of files scanned
+	ScanCorruptLogs(ctx context.Context, appID string) (int, []CorruptLog, error)
+
+	// HealthCheck checks that crash payloads can be written, by writing and
+	// removing a small file
+	HealthCheck(ctx context.Context) error
+}
+
+// CorruptLog describes a stored crash file that can't be decoded
+type CorruptLog struct {
+	Path       string    `json:"path"`
+	Size       int64     `json:"size_bytes"`
+	ModifiedAt time.Time `json:"modified_at"`
+	Error      string    `json:"error"`
 }
 
 // StorageStats represents storage usage statistics
@@ -0,0 +1,1350 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flakerimi/inceptor/internal/core"
+	"github.com/google/uuid"
+)
+
+// testPostgresDSNEnv names the environment variable holding a Postgres
+// server's DSN; the repository contract tests also run against Postgres
+// when it is set
+const testPostgresDSNEnv = "INCEPTOR_TEST_POSTGRES_DSN"
+
+// newTestPostgresRepository opens a Postgres repository in a schema of its
+// own, dropped when the test ends. It skips the test unless a server is
+// configured.
+func newTestPostgresRepository(t *testing.T) *PostgresRepository {
+	t.Helper()
+
+	dsn := os.Getenv(testPostgresDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s not set", testPostgresDSNEnv)
+	}
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	schema := "test_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() { admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	separator := " "
+	if strings.Contains(dsn, "://") {
+		separator = "&"
+		if !strings.Contains(dsn, "?") {
+			separator = "?"
+		}
+	}
+	repo, err := NewPostgresRepository(dsn+separator+"search_path="+schema, nil, 0)
+	if err != nil {
+		t.Fatalf("open repository: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	return repo
+}
+
+// forEachRepository runs test against a fresh repository of each backend
+func forEachRepository(t *testing.T, test func(t *testing.T, repo Repository)) {
+	t.Run("sqlite", func(t *testing.T) { test(t, newTestRepository(t)) })
+	t.Run("postgres", func(t *testing.T) { test(t, newTestPostgresRepository(t)) })
+}
+
+func TestRepositoryApps(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, repo Repository) {
+		ctx := context.Background()
+		app := createTestApp(t, repo, "app-1")
+
+		byKey, err := repo.GetAppByAPIKey(ctx, app.APIKeyHash)
+		if err != nil || byKey == nil || byKey.ID != app.ID {
+			t.Fatalf("GetAppByAPIKey = %v, %v; want %s", byKey, err, app.ID)
+		}
+
+		app.Name = "Renamed"
+		app.RetentionDays = 7
+		if err := repo.UpdateApp(ctx, app); err != nil {
+			t.Fatalf("UpdateApp: %v", err)
+		}
+		got, err := repo.GetApp(ctx, app.ID)
+		if err != nil || got == nil {
+			t.Fatalf("GetApp = %v, %v", got, err)
+		}
+		if got.Name != "Renamed" || got.RetentionDays != 7 {
+			t.Errorf("app = %q with %d days retention, want the update", got.Name, got.RetentionDays)
+		}
+
+		if err := repo.DeleteApp(ctx, app.ID); err != nil {
+			t.Fatalf("DeleteApp: %v", err)
+		}
+		tests := []struct {
+			name string
+			get  func() (*core.App, error)
+		}{
+			{"deleted app", func() (*core.App, error) { return repo.GetApp(ctx, app.ID) }},
+			{"deleted app's key", func() (*core.App, error) { return repo.GetAppByAPIKey(ctx, app.APIKeyHash) }},
+			{"unknown app", func() (*core.App, error) { return repo.GetApp(ctx, "app-2") }},
+		}
+		for _, tt := range tests {
+			if got, err := tt.get(); got != nil || err != nil {
+				t.Errorf("%s: got %v, %v; want nil, nil", tt.name, got, err)
+			}
+		}
+	})
+}
+
+func TestRepositoryAPIKeyScopes(t *testing.T) {
+	expiresAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		scopes    []string
+		expiresAt *time.Time
+	}{
+		{"unscoped", nil, nil},
+		{"scoped", []string{core.ScopeSubmit, core.ScopeRead}, nil},
+		{"expiring", []string{core.ScopeManage}, &expiresAt},
+	}
+	forEachRepository(t, func(t *testing.T, repo Repository) {
+		ctx := context.Background()
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				app := &core.App{
+					ID:              uuid.New().String(),
+					Name:            tt.name,
+					APIKeyID:        uuid.New().String(),
+					APIKeyHash:      "hash-" + tt.name,
+					CreatedAt:       time.Now().UTC(),
+					RetentionDays:   30,
+					APIKeyScopes:    tt.scopes,
+					APIKeyExpiresAt: tt.expiresAt,
+				}
+				if err := repo.CreateApp(ctx, app); err != nil {
+					t.Fatalf("CreateApp: %v", err)
+				}
+
+				got, err := repo.GetAppByAPIKey(ctx, app.APIKeyHash)
+				if err != nil || got == nil {
+					t.Fatalf("GetAppByAPIKey = %v, %v", got, err)
+				}
+				if !slices.Equal(got.APIKeyScopes, tt.scopes) {
+					t.Errorf("scopes = %v, want %v", got.APIKeyScopes, tt.scopes)
+				}
+				if (got.APIKeyExpiresAt == nil) != (tt.expiresAt == nil) ||
+					(tt.expiresAt != nil && !got.APIKeyExpiresAt.Equal(*tt.expiresAt)) {
+					t.Errorf("expires at = %v, want %v", got.APIKeyExpiresAt, tt.expiresAt)
+				}
+			})
+		}
+	})
+}
+
+func TestRepositoryAPIKeys(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, repo Repository) {
+		ctx := context.Background()
+		now := time.Now().UTC().Truncate(time.Second)
+		at := func(d time.Duration) *time.Time {
+			t := now.Add(d)
+			return &t
+		}
+		app := createTestApp(t, repo, "app-1")
+		other := createTestApp(t, repo, "app-2")
+
+		// A key expiring before the grace period ends keeps its expiry
+		early := &core.APIKey{ID: "key-early", AppID: app.ID, KeyHash: "hash-early", CreatedAt: now.Add(-time.Hour), ExpiresAt: at(time.Hour)}
+		if err := repo.RotateAPIKey(ctx, early, now.Add(48*time.Hour)); err != nil {
+			t.Fatalf("RotateAPIKey: %v", err)
+		}
+		graceEnds := now.Add(24 * time.Hour)
+		current := &core.APIKey{ID: "key-current", AppID: app.ID, KeyHash: "hash-current", Label: "ci", Scopes: []string{core.ScopeSubmit}, CreatedAt: now.Add(time.Minute)}
+		if err := repo.RotateAPIKey(ctx, current, graceEnds); err != nil {
+			t.Fatalf("RotateAPIKey: %v", err)
+		}
+
+		keys, err := repo.ListAPIKeys(ctx, app.ID)
+		if err != nil {
+			t.Fatalf("ListAPIKeys: %v", err)
+		}
+		var ids []string
+		expiry := map[string]*time.Time{}
+		for _, key := range keys {
+			ids = append(ids, key.ID)
+			expiry[key.ID] = key.ExpiresAt
+		}
+		if want := []string{current.ID, app.APIKeyID, early.ID}; !slices.Equal(ids, want) {
+			t.Fatalf("keys = %v, want newest first %v", ids, want)
+		}
+
+		tests := []struct {
+			name          string
+			hash          string
+			wantKey       string
+			wantExpiresAt *time.Time
+		}{
+			{"current key", current.KeyHash, current.ID, nil},
+			{"replaced key", app.APIKeyHash, app.APIKeyID, &graceEnds},
+			{"key expiring first", early.KeyHash, early.ID, early.ExpiresAt},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if got := expiry[tt.wantKey]; (got == nil) != (tt.wantExpiresAt == nil) || (got != nil && !got.Equal(*tt.wantExpiresAt)) {
+					t.Errorf("listed expiry = %v, want %v", got, tt.wantExpiresAt)
+				}
+				got, err := repo.GetAppByAPIKey(ctx, tt.hash)
+				if err != nil || got == nil {
+					t.Fatalf("GetAppByAPIKey = %v, %v", got, err)
+				}
+				if got.ID != app.ID || got.APIKeyID != tt.wantKey {
+					t.Errorf("GetAppByAPIKey = app %s with key %s, want app %s with key %s", got.ID, got.APIKeyID, app.ID, tt.wantKey)
+				}
+			})
+		}
+
+		// Keys are looked up within their app
+		if key, err := repo.GetAPIKey(ctx, other.ID, current.ID); key != nil || err != nil {
+			t.Errorf("GetAPIKey from another app = %v, %v; want nil, nil", key, err)
+		}
+
+		current.Label = "deploys"
+		current.Scopes = []string{core.ScopeRead, core.ScopeManage}
+		current.ExpiresAt = at(time.Hour)
+		if err := repo.UpdateAPIKey(ctx, current); err != nil {
+			t.Fatalf("UpdateAPIKey: %v", err)
+		}
+		got, err := repo.GetAPIKey(ctx, app.ID, current.ID)
+		if err != nil || got == nil {
+			t.Fatalf("GetAPIKey = %v, %v", got, err)
+		}
+		if got.Label != "deploys" || !slices.Equal(got.Scopes, current.Scopes) || got.ExpiresAt == nil || !got.ExpiresAt.Equal(*current.ExpiresAt) {
+			t.Errorf("key = %q %v expiring %v, want the update", got.Label, got.Scopes, got.ExpiresAt)
+		}
+
+		if err := repo.DeleteAPIKey(ctx, current.ID); err != nil {
+			t.Fatalf("DeleteAPIKey: %v", err)
+		}
+		if got, err := repo.GetAppByAPIKey(ctx, current.KeyHash); got != nil || err != nil {
+			t.Errorf("GetAppByAPIKey with a deleted key = %v, %v; want nil, nil", got, err)
+		}
+		if got, err := repo.GetAppByAPIKey(ctx, app.APIKeyHash); got == nil || err != nil {
+			t.Errorf("GetAppByAPIKey with the app's other key = %v, %v", got, err)
+		}
+	})
+}
+
+func TestRepositoryTouchAPIKey(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, repo Repository) {
+		ctx := context.Background()
+		now := time.Now().UTC().Truncate(time.Second)
+		app := createTestApp(t, repo, "app-1")
+		other := createTestApp(t, repo, "app-2")
+		rotated := &core.APIKey{ID: "key-2", AppID: app.ID, KeyHash: "hash-2", CreatedAt: now}
+		if err := repo.RotateAPIKey(ctx, rotated, now.Add(time.Hour)); err != nil {
+			t.Fatalf("RotateAPIKey: %v", err)
+		}
+
+		if err := repo.TouchAPIKey(ctx, app.ID, app.APIKeyID, now.Add(-time.Minute)); err != nil {
+			t.Fatalf("TouchAPIKey: %v", err)
+		}
+		if err := repo.TouchAPIKey(ctx, app.ID, rotated.ID, now); err != nil {
+			t.Fatalf("TouchAPIKey: %v", err)
+		}
+
+		keys, err := repo.ListAPIKeys(ctx, app.ID)
+		if err != nil {
+			t.Fatalf("ListAPIKeys: %v", err)
+		}
+		lastUsed := map[string]*time.Time{}
+		for _, key := range keys {
+			lastUsed[key.ID] = key.LastUsedAt
+		}
+
+		tests := []struct {
+			name string
+			get  func() *time.Time
+			want *time.Time
+		}{
+			{"first key", func() *time.Time { return lastUsed[app.APIKeyID] }, timeAt(now.Add(-time.Minute))},
+			{"rotated key", func() *time.Time { return lastUsed[rotated.ID] }, &now},
+			// The app was last used with whichever key was used last
+			{"app", func() *time.Time { return mustGetApp(t, repo, app.ID).LastUsedAt }, &now},
+			{"unused app", func() *time.Time { return mustGetApp(t, repo, other.ID).LastUsedAt }, nil},
+		}
+		for _, tt := range tests {
+			got := tt.get()
+			if (got == nil) != (tt.want == nil) || (got != nil && !got.Equal(*tt.want)) {
+				t.Errorf("%s: last used at = %v, want %v", tt.name, got, tt.want)
+			}
+		}
+	})
+}
+
+// timeAt returns a pointer to t
+func timeAt(t time.Time) *time.Time {
+	return &t
+}
+
+// mustGetApp loads an app, failing the test if it can't
+func mustGetApp(t *testing.T, repo Repository, id string) *core.App {
+	t.Helper()
+
+	app, err := repo.GetApp(context.Background(), id)
+	if err != nil || app == nil {
+		t.Fatalf("GetApp(%s) = %v, %v", id, app, err)
+	}
+	return app
+}
+
+func TestRepositoryListCrashes(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, repo Repository) {
+		ctx := context.Background()
+		createTestApp(t, repo, "app-1")
+		createTestApp(t, repo, "app-2")
+
+		ios := addTestCrash(t, repo, &core.Crash{AppID: "app-1", Platform: "ios", ErrorType: "NSRangeException", ErrorMessage: "index 3 beyond bounds", Country: "DE"})
+		android := addTestCrash(t, repo, &core.Crash{AppID: "app-1", Platform: "android", ErrorType: "NullPointerException", Environment: "staging", Country: "FR"})
+		web := addTestCrash(t, repo, &core.Crash{AppID: "app-1", Platform: "web", ErrorType: "TypeError", UserID: "user-1"})
+		addTestCrash(t, repo, &core.Crash{AppID: "app-2", Platform: "ios", ErrorType: "NSRangeException"})
+
+		tests := []struct {
+			name   string
+			filter CrashFilter
+			want   []string
+		}{
+			{"app", CrashFilter{AppID: "app-1"}, []string{ios.ID, android.ID, web.ID}},
+			{"platform", CrashFilter{AppID: "app-1", Platform: "ios"}, []string{ios.ID}},
+			{"environment", CrashFilter{AppID: "app-1", Environment: "staging"}, []string{android.ID}},
+			{"error type", CrashFilter{AppID: "app-1", ErrorType: "TypeError"}, []string{web.ID}},
+			{"user", CrashFilter{AppID: "app-1", UserID: "user-1"}, []string{web.ID}},
+			{"country", CrashFilter{AppID: "app-1", Country: "FR"}, []string{android.ID}},
+			{"search", CrashFilter{AppID: "app-1", Search: "beyond"}, []string{ios.ID}},
+			{"group", CrashFilter{GroupID: android.GroupID}, []string{android.ID}},
+			{"no match", CrashFilter{AppID: "app-1", Platform: "windows"}, nil},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				tt.filter.Limit = 50
+				crashes, total, err := repo.ListCrashes(ctx, tt.filter)
+				if err != nil {
+					t.Fatalf("ListCrashes: %v", err)
+				}
+				var got []string
+				for _, crash := range crashes {
+					got = append(got, crash.ID)
+				}
+				slices.Sort(got)
+				want := slices.Clone(tt.want)
+				slices.Sort(want)
+				if !slices.Equal(got, want) || total != len(want) {
+					t.Errorf("crashes = %v (total %d), want %v", got, total, want)
+				}
+			})
+		}
+
+		got, err := repo.GetCrash(ctx, ios.ID)
+		if err != nil || got == nil {
+			t.Fatalf("GetCrash = %v, %v", got, err)
+		}
+		if got.ErrorMessage != ios.ErrorMessage || got.GroupID != ios.GroupID || got.Country != "DE" {
+			t.Errorf("crash = %+v, want it as stored", got)
+		}
+		if got, err := repo.GetCrash(ctx, "missing"); got != nil || err != nil {
+			t.Errorf("unknown crash = %v, %v; want nil, nil", got, err)
+		}
+	})
+}
+
+func TestRepositoryGrouping(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, repo Repository) {
+		ctx := context.Background()
+		createTestApp(t, repo, "app-1")
+		createTestApp(t, repo, "app-2")
+		at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+		tests := []struct {
+			name        string
+			appID       string
+			fingerprint string
+			historical  bool
+			wantCreated bool
+			wantCount   int
+		}{
+			{"new fingerprint", "app-1", "fp-a", false, true, 1},
+			{"same fingerprint", "app-1", "fp-a", false, false, 2},
+			{"other fingerprint", "app-1", "fp-b", false, true, 1},
+			{"same fingerprint in another app", "app-2", "fp-a", false, true, 1},
+			{"again", "app-1", "fp-a", false, false, 3},
+			// Historical crashes attach to the group without counting
+			{"historical", "app-1", "fp-a", true, false, 3},
+			{"historical new fingerprint", "app-1", "fp-c", true, true, 1},
+		}
+		groups := map[string]string{}
+		lastSeen := map[string]time.Time{}
+		for i, tt := range tests {
+			crash := &core.Crash{
+				ID:          uuid.New().String(),
+				AppID:       tt.appID,
+				ErrorType:   "Error",
+				Fingerprint: tt.fingerprint,
+				GroupID:     uuid.New().String(),
+				Historical:  tt.historical,
+				CreatedAt:   at.Add(time.Duration(i) * time.Minute),
+				// Only the crash creating a group sets its culprit
+				GroupCulprit: fmt.Sprintf("App.step%d", i),
+			}
+			group, created, err := repo.GetOrCreateGroup(ctx, crash)
+			if err != nil {
+				t.Fatalf("%s: GetOrCreateGroup: %v", tt.name, err)
+			}
+			if created != tt.wantCreated || group.OccurrenceCount != tt.wantCount {
+				t.Errorf("%s: created = %v with %d occurrences, want %v with %d", tt.name, created, group.OccurrenceCount, tt.wantCreated, tt.wantCount)
+			}
+			key := tt.appID + "/" + tt.fingerprint
+			wantLastSeen := crash.CreatedAt
+			if tt.historical && !tt.wantCreated {
+				wantLastSeen = lastSeen[key]
+			}
+			if !group.LastSeen.Equal(wantLastSeen) {
+				t.Errorf("%s: last seen %v, want %v", tt.name, group.LastSeen, wantLastSeen)
+			}
+			if id, ok := groups[key]; ok && id != group.ID {
+				t.Errorf("%s: group %s, want the existing %s", tt.name, group.ID, id)
+			}
+			groups[key] = group.ID
+			lastSeen[key] = group.LastSeen
+		}
+
+		if group, err := repo.GetGroup(ctx, groups["app-1/fp-a"]); err != nil || group.Culprit != "App.step0" {
+			t.Errorf("group = %+v, %v; want the culprit of its first crash", group, err)
+		}
+
+		if count, err := repo.CountGroups(ctx, "app-1"); err != nil || count != 3 {
+			t.Errorf("CountGroups = %d, %v; want 3", count, err)
+		}
+
+		resolved := groups["app-1/fp-b"]
+		if err := repo.UpdateGroupStatus(ctx, resolved, string(core.GroupStatusResolved)); err != nil {
+			t.Fatalf("UpdateGroupStatus: %v", err)
+		}
+		list, total, err := repo.ListGroups(ctx, GroupFilter{AppID: "app-1", Status: string(core.GroupStatusResolved), Limit: 50})
+		if err != nil || total != 1 || len(list) != 1 || list[0].ID != resolved {
+			t.Errorf("resolved groups = %v (total %d, %v), want %s", list, total, err, resolved)
+		}
+	})
+}
+
+func TestRepositoryRegression(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, repo Repository) {
+		ctx := context.Background()
+		createTestApp(t, repo, "app-1")
+		at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+		// crash records a crash of fp-a at the given minute
+		crash := func(minute int, historical bool) *core.CrashGroup {
+			t.Helper()
+			group, _, err := repo.GetOrCreateGroup(ctx, &core.Crash{
+				ID:          uuid.New().String(),
+				AppID:       "app-1",
+				ErrorType:   "Error",
+				Fingerprint: "fp-a",
+				GroupID:     uuid.New().String(),
+				Historical:  historical,
+				CreatedAt:   at.Add(time.Duration(minute) * time.Minute),
+			})
+			if err != nil {
+				t.Fatalf("GetOrCreateGroup: %v", err)
+			}
+			return group
+		}
+		groupID := crash(0, false).ID
+
+		tests := []struct {
+			name       string
+			setStatus  core.GroupStatus
+			minute     int
+			historical bool
+			// wantPrevious is the status the crash reopened, if any
+			wantPrevious  string
+			wantStatus    core.GroupStatus
+			wantRegressed int // minute of regressed_at, -1 for none
+		}{
+			{"open", "", 1, false, "", core.GroupStatusOpen, -1},
+			{"ignored", core.GroupStatusIgnored, 2, false, "", core.GroupStatusIgnored, -1},
+			{"resolved", core.GroupStatusResolved, 3, false, "resolved", core.GroupStatusOpen, 3},
+			{"reopened", "", 4, false, "", core.GroupStatusOpen, 3},
+			{"historical", core.GroupStatusResolved, 5, true, "", core.GroupStatusResolved, 3},
+			{"resolved again", core.GroupStatusResolved, 6, false, "resolved", core.GroupStatusOpen, 6},
+		}
+		for _, tt := range tests {
+			if tt.setStatus != "" {
+				if err := repo.UpdateGroupStatus(ctx, groupID, string(tt.setStatus)); err != nil {
+					t.Fatalf("%s: UpdateGroupStatus: %v", tt.name, err)
+				}
+			}
+			group := crash(tt.minute, tt.historical)
+			if group.PreviousStatus != tt.wantPrevious || group.Status != string(tt.wantStatus) {
+				t.Errorf("%s: status %s, previous %q; want %s, %q", tt.name, group.Status, group.PreviousStatus, tt.wantStatus, tt.wantPrevious)
+			}
+
+			stored, err := repo.GetGroup(ctx, groupID)
+			if err != nil {
+				t.Fatalf("%s: GetGroup: %v", tt.name, err)
+			}
+			if stored.Status != string(tt.wantStatus) {
+				t.Errorf("%s: stored status %s, want %s", tt.name, stored.Status, tt.wantStatus)
+			}
+			switch {
+			case tt.wantRegressed < 0 && stored.RegressedAt != nil:
+				t.Errorf("%s: regressed at %v, want never", tt.name, stored.RegressedAt)
+			case tt.wantRegressed >= 0 && (stored.RegressedAt == nil || !stored.RegressedAt.Equal(at.Add(time.Duration(tt.wantRegressed)*time.Minute))):
+				t.Errorf("%s: regressed at %v, want minute %d", tt.name, stored.RegressedAt, tt.wantRegressed)
+			}
+		}
+	})
+}
+
+func TestRepositoryDeleteGroup(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, repo Repository) {
+		ctx := context.Background()
+		createTestApp(t, repo, "app-1")
+
+		first := addTestCrash(t, repo, &core.Crash{AppID: "app-1", ErrorType: "A", LogFilePath: "a/1.json"})
+		second := addTestCrash(t, repo, &core.Crash{AppID: "app-1", ErrorType: "A", LogFilePath: "a/2.json"})
+		other := addTestCrash(t, repo, &core.Crash{AppID: "app-1", ErrorType: "B", LogFilePath: "b/1.json"})
+
+		paths, err := repo.DeleteGroup(ctx, first.GroupID)
+		if err != nil {
+			t.Fatalf("DeleteGroup: %v", err)
+		}
+		slices.Sort(paths)
+		if want := []string{"a/1.json", "a/2.json"}; !slices.Equal(paths, want) {
+			t.Errorf("paths = %v, want %v", paths, want)
+		}
+
+		tests := []struct {
+			id   string
+			want bool
+		}{
+			{first.ID, false},
+			{second.ID, false},
+			{other.ID, true},
+		}
+		for _, tt := range tests {
+			crash, err := repo.GetCrash(ctx, tt.id)
+			if err != nil {
+				t.Fatalf("GetCrash: %v", err)
+			}
+			if (crash != nil) != tt.want {
+				t.Errorf("crash %s exists = %v, want %v", tt.id, crash != nil, tt.want)
+			}
+		}
+		if group, err := repo.GetGroup(ctx, first.GroupID); group != nil || err != nil {
+			t.Errorf("deleted group = %v, %v; want nil, nil", group, err)
+		}
+	})
+}
+
+func TestRepositorySettings(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, repo Repository) {
+		ctx := context.Background()
+
+		tests := []struct {
+			name  string
+			key   string
+			value string
+			set   bool
+		}{
+			{"unset", "export.cursor", "", false},
+			{"set", "export.cursor", "first", true},
+			{"overwritten", "export.cursor", "second", true},
+			{"other key untouched", "other", "", false},
+		}
+		for _, tt := range tests {
+			if tt.set {
+				if err := repo.SetSetting(ctx, tt.key, tt.value); err != nil {
+					t.Fatalf("%s: SetSetting: %v", tt.name, err)
+				}
+			}
+			if got, err := repo.GetSetting(ctx, tt.key); err != nil || got != tt.value {
+				t.Errorf("%s: GetSetting = %q, %v; want %q", tt.name, got, err, tt.value)
+			}
+		}
+	})
+}
+
+func TestRepositorySessions(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, repo Repository) {
+		ctx := context.Background()
+		now := time.Now().UTC().Truncate(time.Second)
+
+		user := &core.User{ID: "user-1", Username: "alice", PasswordHash: "hash", Role: core.UserRoleAdmin, CreatedAt: now}
+		if err := repo.CreateUser(ctx, user); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		if got, err := repo.GetUserByUsername(ctx, "alice"); err != nil || got == nil || got.ID != user.ID || got.Role != core.UserRoleAdmin {
+			t.Errorf("GetUserByUsername = %v, %v; want %s", got, err, user.ID)
+		}
+
+		for i, expiresAt := range []time.Time{now.Add(time.Hour), now.Add(-time.Hour)} {
+			session := &core.Session{TokenHash: fmt.Sprintf("token-%d", i), UserID: user.ID, Username: user.Username, Role: user.Role, CreatedAt: now, ExpiresAt: expiresAt}
+			if err := repo.CreateSession(ctx, session); err != nil {
+				t.Fatalf("CreateSession: %v", err)
+			}
+		}
+		if count, err := repo.CountActiveSessions(ctx, now); err != nil || count != 1 {
+			t.Errorf("CountActiveSessions = %d, %v; want 1", count, err)
+		}
+		if deleted, err := repo.DeleteExpiredSessions(ctx, now); err != nil || deleted != 1 {
+			t.Errorf("DeleteExpiredSessions = %d, %v; want 1", deleted, err)
+		}
+
+		tests := []struct {
+			tokenHash string
+			want      bool
+		}{
+			{"token-0", true},
+			{"token-1", false},
+			{"unknown", false},
+		}
+		for _, tt := range tests {
+			session, err := repo.GetSession(ctx, tt.tokenHash)
+			if err != nil {
+				t.Fatalf("GetSession: %v", err)
+			}
+			if (session != nil) != tt.want {
+				t.Errorf("session %s found = %v, want %v", tt.tokenHash, session != nil, tt.want)
+			}
+		}
+
+		session, _ := repo.GetSession(ctx, "token-0")
+		want := core.Session{TokenHash: "token-0", UserID: user.ID, Username: "alice", Role: core.UserRoleAdmin, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+		if session.TokenHash != want.TokenHash || session.UserID != want.UserID || session.Username != want.Username || session.Role != want.Role ||
+			!session.CreatedAt.Equal(want.CreatedAt) || !session.ExpiresAt.Equal(want.ExpiresAt) {
+			t.Errorf("session = %+v, want %+v", session, want)
+		}
+
+		if err := repo.DeleteSession(ctx, "token-0"); err != nil {
+			t.Fatalf("DeleteSession: %v", err)
+		}
+		if session, err := repo.GetSession(ctx, "token-0"); err != nil || session != nil {
+			t.Errorf("GetSession after DeleteSession = %+v, %v; want nil", session, err)
+		}
+		// Deleting a missing session succeeds
+		if err := repo.DeleteSession(ctx, "token-0"); err != nil {
+			t.Errorf("DeleteSession again: %v", err)
+		}
+	})
+}
+
+func TestRepositoryAlertQueue(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, repo Repository) {
+		ctx := context.Background()
+		now := time.Now().UTC().Truncate(time.Second)
+
+		// Enqueued out of due order
+		due := []time.Duration{time.Minute, -time.Minute, -2 * time.Minute, time.Hour}
+		ids := make(map[string]int64)
+		for i, offset := range due {
+			groupID := fmt.Sprintf("group-%d", i)
+			event := &core.QueuedAlertEvent{
+				Event:         core.AlertEvent{Type: core.AlertEventNewGroup, AppID: "app-1", Group: &core.CrashGroup{ID: groupID, AppID: "app-1"}},
+				NextAttemptAt: now.Add(offset),
+				CreatedAt:     now,
+			}
+			if err := repo.EnqueueAlertEvent(ctx, event); err != nil {
+				t.Fatalf("EnqueueAlertEvent: %v", err)
+			}
+			if event.ID == 0 {
+				t.Fatal("EnqueueAlertEvent left ID unset")
+			}
+			ids[groupID] = event.ID
+		}
+
+		// claim returns the groups of the events claimed
+		claim := func(dueBy time.Time, limit int) []string {
+			t.Helper()
+			events, err := repo.ClaimAlertEvents(ctx, dueBy, now.Add(2*time.Minute), limit)
+			if err != nil {
+				t.Fatalf("ClaimAlertEvents: %v", err)
+			}
+			groups := make([]string, 0, len(events))
+			for _, event := range events {
+				if event.Event.Type != core.AlertEventNewGroup || event.Event.AppID != "app-1" {
+					t.Errorf("event = %+v, want it read back", event.Event)
+				}
+				if !event.NextAttemptAt.Equal(now.Add(2 * time.Minute)) {
+					t.Errorf("claimed event next attempt = %s, want the claim", event.NextAttemptAt)
+				}
+				groups = append(groups, event.Event.Group.ID)
+			}
+			return groups
+		}
+
+		tests := []struct {
+			name  string
+			dueBy time.Time
+			limit int
+			want  []string
+		}{
+			{"oldest due first", now, 1, []string{"group-2"}},
+			{"claimed events skipped", now, 10, []string{"group-1"}},
+			{"nothing due", now, 10, []string{}},
+			{"claims run out", now.Add(2 * time.Minute), 10, []string{"group-0", "group-1", "group-2"}},
+		}
+		for _, tt := range tests {
+			if got := claim(tt.dueBy, tt.limit); !slices.Equal(got, tt.want) {
+				t.Errorf("%s: claimed %v, want %v", tt.name, got, tt.want)
+			}
+		}
+
+		// A retry is postponed and keeps its attempts
+		if err := repo.RetryAlertEvent(ctx, ids["group-3"], 2, now.Add(-time.Second), "webhook returned status 503"); err != nil {
+			t.Fatalf("RetryAlertEvent: %v", err)
+		}
+		events, err := repo.ClaimAlertEvents(ctx, now, now.Add(2*time.Minute), 10)
+		if err != nil || len(events) != 1 {
+			t.Fatalf("ClaimAlertEvents = %d events, %v; want the retry", len(events), err)
+		}
+		if retry := events[0]; retry.ID != ids["group-3"] || retry.Attempts != 2 || retry.LastError != "webhook returned status 503" {
+			t.Errorf("retry = %+v", retry)
+		}
+
+		for _, id := range ids {
+			if err := repo.DeleteAlertEvent(ctx, id); err != nil {
+				t.Fatalf("DeleteAlertEvent: %v", err)
+			}
+		}
+		if got := claim(now.Add(24*time.Hour), 10); len(got) != 0 {
+			t.Errorf("claimed %v after deleting every event", got)
+		}
+	})
+}
+
+func TestPgArgs(t *testing.T) {
+	var args pgArgs
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{"app-1", "$1"},
+		{42, "$2"},
+		{nil, "$3"},
+	}
+	for _, tt := range tests {
+		if got := args.add(tt.value); got != tt.want {
+			t.Errorf("add(%v) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+	if len(args) != 3 || args[0] != "app-1" || args[1] != 42 {
+		t.Errorf("args = %v, want the values in order", args)
+	}
+}
+
+func TestRepositoryCursorPagination(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, repo Repository) {
+		ctx := context.Background()
+		createTestApp(t, repo, "app-1")
+		at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+		// Five crashes, the middle three created at the same time, each in
+		// a group of its own
+		var newestFirst []string
+		for i, minute := range []int{0, 1, 1, 1, 2} {
+			crash := addTestCrash(t, repo, &core.Crash{
+				ID:        fmt.Sprintf("crash-%d", i),
+				AppID:     "app-1",
+				ErrorType: fmt.Sprintf("Error%d", i),
+				CreatedAt: at.Add(time.Duration(minute) * time.Minute),
+			})
+			newestFirst = append([]string{crash.ID}, newestFirst...)
+		}
+
+		t.Run("crashes", func(t *testing.T) {
+			var got []string
+			cursor := ""
+			for page := 0; page < 5; page++ {
+				filter := CrashFilter{AppID: "app-1", Limit: 2, Cursor: cursor}
+				if cursor != "" {
+					// Ignored in favor of the cursor
+					filter.Offset = 3
+				}
+				crashes, total, err := repo.ListCrashes(ctx, filter)
+				if err != nil {
+					t.Fatalf("page %d: %v", page, err)
+				}
+				if total != 5 {
+					t.Errorf("page %d: total = %d, want every match", page, total)
+				}
+				if len(crashes) == 0 {
+					break
+				}
+				for _, crash := range crashes {
+					got = append(got, crash.ID)
+				}
+				last := crashes[len(crashes)-1]
+				cursor = EncodeCursor(last.CreatedAt, last.ID)
+			}
+			if !slices.Equal(got, newestFirst) {
+				t.Errorf("paged crashes = %v, want each once, newest first: %v", got, newestFirst)
+			}
+		})
+
+		tests := []struct {
+			name   string
+			sortBy string
+			order  string
+		}{
+			{"groups by last seen", "last_seen", "desc"},
+			{"groups by first seen ascending", "first_seen", "asc"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				filter := GroupFilter{AppID: "app-1", SortBy: tt.sortBy, SortOrder: tt.order, Limit: 50}
+				all, _, err := repo.ListGroups(ctx, filter)
+				if err != nil {
+					t.Fatalf("ListGroups: %v", err)
+				}
+				var want []string
+				for _, group := range all {
+					want = append(want, group.ID)
+				}
+
+				filter.Limit = 2
+				var got []string
+				for page := 0; page < 5; page++ {
+					groups, _, err := repo.ListGroups(ctx, filter)
+					if err != nil {
+						t.Fatalf("page %d: %v", page, err)
+					}
+					if len(groups) == 0 {
+						break
+					}
+					for _, group := range groups {
+						got = append(got, group.ID)
+					}
+					last := groups[len(groups)-1]
+					sortTime := last.LastSeen
+					if tt.sortBy == "first_seen" {
+						sortTime = last.FirstSeen
+					}
+					filter.Cursor = EncodeCursor(sortTime, last.ID)
+				}
+				if len(got) != 5 || !slices.Equal(got, want) {
+					t.Errorf("paged groups = %v, want %v", got, want)
+				}
+			})
+		}
+
+		if _, _, err := repo.ListGroups(ctx, GroupFilter{AppID: "app-1", SortBy: "occurrence_count", Cursor: EncodeCursor(at, "group-1")}); !errors.Is(err, ErrCursorSort) {
+			t.Errorf("cursor sorted by count: err = %v, want ErrCursorSort", err)
+		}
+		if _, _, err := repo.ListCrashes(ctx, CrashFilter{AppID: "app-1", Cursor: "bogus!"}); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("invalid cursor: err = %v, want ErrInvalidCursor", err)
+		}
+	})
+}
+
+func TestRepositoryCreateApps(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, repo Repository) {
+		ctx := context.Background()
+		app := func(id string) *core.App {
+			return &core.App{ID: id, Name: "App " + id, APIKeyID: uuid.New().String(), APIKeyHash: "hash-" + id, CreatedAt: time.Now().UTC(), RetentionDays: 30}
+		}
+
+		if err := repo.CreateApps(ctx, []*core.App{app("app-1"), app("app-2")}); err != nil {
+			t.Fatalf("CreateApps: %v", err)
+		}
+		// A batch with a conflicting app creates none of its apps
+		if err := repo.CreateApps(ctx, []*core.App{app("app-3"), app("app-1")}); err == nil {
+			t.Errorf("CreateApps with an existing ID succeeded")
+		}
+
+		tests := []struct {
+			id   string
+			want bool
+		}{
+			{"app-1", true},
+			{"app-2", true},
+			{"app-3", false},
+		}
+		for _, tt := range tests {
+			got, err := repo.GetApp(ctx, tt.id)
+			if err != nil {
+				t.Fatalf("GetApp: %v", err)
+			}
+			if (got != nil) != tt.want {
+				t.Errorf("%s exists = %v, want %v", tt.id, got != nil, tt.want)
+			}
+			// Each app's key authenticates it
+			if tt.want {
+				if byKey, err := repo.GetAppByAPIKey(ctx, "hash-"+tt.id); err != nil || byKey == nil || byKey.ID != tt.id {
+					t.Errorf("%s: GetAppByAPIKey = %v, %v", tt.id, byKey, err)
+				}
+			}
+		}
+	})
+}
+
+func TestRepositoryCountCrashesByGroupForUser(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, repo Repository) {
+		ctx := context.Background()
+		createTestApp(t, repo, "app-1")
+		createTestApp(t, repo, "app-2")
+
+		var typeError, formatError string
+		for i := 0; i < 3; i++ {
+			typeError = addTestCrash(t, repo, &core.Crash{AppID: "app-1", UserID: "user-1", ErrorType: "TypeError", ErrorMessage: "null"}).GroupID
+		}
+		for i := 0; i < 2; i++ {
+			formatError = addTestCrash(t, repo, &core.Crash{AppID: "app-1", UserID: "user-1", ErrorType: "FormatException"}).GroupID
+		}
+		addTestCrash(t, repo, &core.Crash{AppID: "app-1", UserID: "user-1", ErrorType: "Flood", QuarantineReason: "flood"})
+		addTestCrash(t, repo, &core.Crash{AppID: "app-1", UserID: "user-2", ErrorType: "StateError"})
+		addTestCrash(t, repo, &core.Crash{AppID: "app-2", UserID: "user-1", ErrorType: "StateError"})
+
+		tests := []struct {
+			name          string
+			appID, userID string
+			want          []core.UserCrashGroup
+		}{
+			{"busiest group first", "app-1", "user-1", []core.UserCrashGroup{
+				{ErrorSummary: core.ErrorSummary{GroupID: typeError, ErrorType: "TypeError", ErrorMessage: "null", Count: 3}, Status: core.GroupStatusOpen},
+				{ErrorSummary: core.ErrorSummary{GroupID: formatError, ErrorType: "FormatException", Count: 2}, Status: core.GroupStatusOpen},
+			}},
+			{"other app", "app-2", "user-2", nil},
+			{"unknown user", "app-1", "user-3", nil},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				got, err := repo.CountCrashesByGroupForUser(ctx, tt.appID, tt.userID)
+				if err != nil {
+					t.Fatalf("CountCrashesByGroupForUser: %v", err)
+				}
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("groups = %+v, want %+v", got, tt.want)
+				}
+			})
+		}
+	})
+}
+
+func TestRepositorySummarizeGroupCrashes(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, repo Repository) {
+		ctx := context.Background()
+		createTestApp(t, repo, "app-1")
+		now := time.Now().UTC()
+
+		var latest *core.Crash
+		for i, user := range []string{"user-1", "user-2", "user-1", ""} {
+			latest = addTestCrash(t, repo, &core.Crash{AppID: "app-1", UserID: user, ErrorType: "TypeError", CreatedAt: now.Add(time.Duration(i) * time.Minute)})
+		}
+		anonymous := addTestCrash(t, repo, &core.Crash{AppID: "app-1", ErrorType: "StateError", CreatedAt: now})
+		// A group whose crashes were all deleted, e.g. by retention
+		deleted := addTestCrash(t, repo, &core.Crash{AppID: "app-1", UserID: "user-1", ErrorType: "RangeError", CreatedAt: now})
+		if err := repo.DeleteCrash(ctx, deleted.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		var groups []*core.CrashGroup
+		for _, id := range []string{latest.GroupID, anonymous.GroupID, deleted.GroupID} {
+			group, err := repo.GetGroup(ctx, id)
+			if err != nil || group == nil {
+				t.Fatalf("get group %s: %v, %v", id, group, err)
+			}
+			groups = append(groups, group)
+		}
+		if err := repo.SummarizeGroupCrashes(ctx, groups); err != nil {
+			t.Fatalf("SummarizeGroupCrashes: %v", err)
+		}
+
+		tests := []struct {
+			name       string
+			group      *core.CrashGroup
+			wantUsers  int
+			wantLatest string
+		}{
+			{"distinct users", groups[0], 2, latest.ID},
+			{"no user IDs", groups[1], 0, anonymous.ID},
+			{"no stored crashes", groups[2], 0, ""},
+		}
+		for _, tt := range tests {
+			if tt.group.AffectedUsers != tt.wantUsers || tt.group.LatestCrashID != tt.wantLatest {
+				t.Errorf("%s: affected users %d, latest crash %q; want %d, %q",
+					tt.name, tt.group.AffectedUsers, tt.group.LatestCrashID, tt.wantUsers, tt.wantLatest)
+			}
+		}
+	})
+}
+
+func TestRepositoryMergeGroups(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, repo Repository) {
+		ctx := context.Background()
+		createTestApp(t, repo, "app-1")
+		createTestApp(t, repo, "app-2")
+		at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+		seed := func(appID, errorType string, offsets ...int) *core.Crash {
+			var crash *core.Crash
+			for _, offset := range offsets {
+				crash = addTestCrash(t, repo, &core.Crash{AppID: appID, ErrorType: errorType, CreatedAt: at.Add(time.Duration(offset) * time.Hour)})
+			}
+			return crash
+		}
+		target := seed("app-1", "StateError", 2, 3, 4)
+		source := seed("app-1", "RangeError", 1, 5)
+		later := seed("app-1", "FormatException", 6)
+		other := seed("app-2", "StateError", 0)
+
+		tests := []struct {
+			name                string
+			source, target      string
+			wantErr             error
+			wantCount           int
+			wantFirst, wantLast int
+		}{
+			{"into itself", target.GroupID, target.GroupID, ErrMergeSameGroup, 0, 0, 0},
+			{"other app", source.GroupID, other.GroupID, ErrMergeDifferentApps, 0, 0, 0},
+			{"unknown source", "missing", target.GroupID, ErrGroupNotFound, 0, 0, 0},
+			{"unknown target", source.GroupID, "missing", ErrGroupNotFound, 0, 0, 0},
+			{"merged", source.GroupID, target.GroupID, nil, 5, 1, 5},
+			{"merged again", source.GroupID, target.GroupID, ErrGroupNotFound, 0, 0, 0},
+			// Merging the target on carries the fingerprints merged into it
+			{"chained", target.GroupID, later.GroupID, nil, 6, 1, 6},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				merged, err := repo.MergeGroups(ctx, tt.source, tt.target)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("MergeGroups = %v, want %v", err, tt.wantErr)
+				}
+				if err != nil {
+					return
+				}
+				if merged.ID != tt.target || merged.OccurrenceCount != tt.wantCount {
+					t.Errorf("merged = %s with %d occurrences, want %s with %d", merged.ID, merged.OccurrenceCount, tt.target, tt.wantCount)
+				}
+				wantFirst, wantLast := at.Add(time.Duration(tt.wantFirst)*time.Hour), at.Add(time.Duration(tt.wantLast)*time.Hour)
+				if !merged.FirstSeen.Equal(wantFirst) || !merged.LastSeen.Equal(wantLast) {
+					t.Errorf("seen %v to %v, want %v to %v", merged.FirstSeen, merged.LastSeen, wantFirst, wantLast)
+				}
+				if group, err := repo.GetGroup(ctx, tt.source); group != nil || err != nil {
+					t.Errorf("source group = %v, %v; want it deleted", group, err)
+				}
+				if _, total, err := repo.ListCrashes(ctx, CrashFilter{GroupID: tt.target, Limit: 50}); err != nil || total != tt.wantCount {
+					t.Errorf("target crashes = %d, %v; want %d", total, err, tt.wantCount)
+				}
+			})
+		}
+
+		// Later crashes with a merged fingerprint join the final group
+		for _, fingerprint := range []string{source.Fingerprint, target.Fingerprint} {
+			crash := &core.Crash{ID: uuid.New().String(), AppID: "app-1", ErrorType: "Error", Fingerprint: fingerprint, GroupID: uuid.New().String(), CreatedAt: at.Add(7 * time.Hour)}
+			group, created, err := repo.GetOrCreateGroup(ctx, crash)
+			if err != nil || created || group.ID != later.GroupID {
+				t.Errorf("crash with %s: group %v (created %v, %v), want %s", fingerprint, group, created, err, later.GroupID)
+			}
+		}
+		// The other app's group with the same fingerprint is untouched
+		if group, err := repo.GetGroup(ctx, other.GroupID); err != nil || group == nil || group.OccurrenceCount != 1 {
+			t.Errorf("other app's group = %v, %v; want 1 occurrence", group, err)
+		}
+	})
+}
+
+func TestRepositorySplitGroup(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, repo Repository) {
+		ctx := context.Background()
+		createTestApp(t, repo, "app-1")
+		at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+		var crashes []*core.Crash
+		for offset := 1; offset <= 4; offset++ {
+			crashes = append(crashes, addTestCrash(t, repo, &core.Crash{AppID: "app-1", ErrorType: "StateError", CreatedAt: at.Add(time.Duration(offset) * time.Hour)}))
+		}
+		groupID := crashes[0].GroupID
+		other := addTestCrash(t, repo, &core.Crash{AppID: "app-1", ErrorType: "RangeError", CreatedAt: at})
+
+		tests := []struct {
+			name                          string
+			groupID                       string
+			crashIDs                      []string
+			wantErr                       error
+			wantCount, wantSplitCount     int
+			wantFirst, wantLast           int
+			wantSplitFirst, wantSplitLast int
+		}{
+			{"unknown group", "missing", []string{crashes[1].ID}, ErrGroupNotFound, 0, 0, 0, 0, 0, 0},
+			{"crash not in group", groupID, []string{crashes[1].ID, other.ID}, ErrCrashNotInGroup, 0, 0, 0, 0, 0, 0},
+			{"whole group", groupID, []string{crashes[0].ID, crashes[1].ID, crashes[2].ID, crashes[3].ID}, ErrSplitWholeGroup, 0, 0, 0, 0, 0, 0},
+			{"split", groupID, []string{crashes[1].ID, crashes[3].ID}, nil, 2, 2, 1, 3, 2, 4},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				newGroup := &core.CrashGroup{
+					ID:          uuid.New().String(),
+					Fingerprint: "fp-split-" + tt.name,
+					Title:       "StateError: split",
+					ErrorType:   "StateError",
+					Status:      string(core.GroupStatusOpen),
+					SplitKey:    groupID + ":" + tt.crashIDs[0],
+				}
+				group, split, err := repo.SplitGroup(ctx, tt.groupID, tt.crashIDs, newGroup)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("SplitGroup = %v, want %v", err, tt.wantErr)
+				}
+				if err != nil {
+					if group, err := repo.GetGroup(ctx, newGroup.ID); group != nil || err != nil {
+						t.Errorf("new group = %v, %v; want nothing stored", group, err)
+					}
+					if original, _ := repo.GetGroup(ctx, groupID); original.OccurrenceCount != 4 {
+						t.Errorf("original group has %d occurrences, want 4", original.OccurrenceCount)
+					}
+					return
+				}
+
+				for _, g := range []struct {
+					group              *core.CrashGroup
+					count, first, last int
+				}{
+					{group, tt.wantCount, tt.wantFirst, tt.wantLast},
+					{split, tt.wantSplitCount, tt.wantSplitFirst, tt.wantSplitLast},
+				} {
+					wantFirst, wantLast := at.Add(time.Duration(g.first)*time.Hour), at.Add(time.Duration(g.last)*time.Hour)
+					if g.group.OccurrenceCount != g.count || !g.group.FirstSeen.Equal(wantFirst) || !g.group.LastSeen.Equal(wantLast) {
+						t.Errorf("group %s: %d occurrences from %v to %v, want %d from %v to %v",
+							g.group.ID, g.group.OccurrenceCount, g.group.FirstSeen, g.group.LastSeen, g.count, wantFirst, wantLast)
+					}
+				}
+				stored, err := repo.GetGroup(ctx, newGroup.ID)
+				if err != nil || stored == nil {
+					t.Fatalf("GetGroup(new) = %v, %v", stored, err)
+				}
+				if stored.SplitKey != newGroup.SplitKey || stored.Fingerprint != newGroup.Fingerprint || stored.AppID != "app-1" {
+					t.Errorf("stored group = %+v, want split key %q", stored, newGroup.SplitKey)
+				}
+				for _, id := range tt.crashIDs {
+					if crash, err := repo.GetCrash(ctx, id); err != nil || crash.GroupID != newGroup.ID {
+						t.Errorf("crash %s in group %v (%v), want %s", id, crash.GroupID, err, newGroup.ID)
+					}
+				}
+			})
+		}
+
+		// Later crashes with the original fingerprint stay in the original group
+		crash := &core.Crash{ID: uuid.New().String(), AppID: "app-1", ErrorType: "StateError", Fingerprint: crashes[0].Fingerprint, GroupID: uuid.New().String(), CreatedAt: at.Add(5 * time.Hour)}
+		if group, created, err := repo.GetOrCreateGroup(ctx, crash); err != nil || created || group.ID != groupID || group.SplitKey != "" {
+			t.Errorf("later crash: group %v (created %v, %v), want %s", group, created, err, groupID)
+		}
+	})
+}
+
+func TestRepositoryTrendingGroups(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, repo Repository) {
+		ctx := context.Background()
+		createTestApp(t, repo, "app-1")
+		now := time.Now().UTC().Truncate(time.Second)
+
+		seed := func(errorType string, hoursAgo ...int) *core.Crash {
+			var crash *core.Crash
+			for _, h := range hoursAgo {
+				crash = addTestCrash(t, repo, &core.Crash{AppID: "app-1", ErrorType: errorType, CreatedAt: now.Add(-time.Duration(h) * time.Hour)})
+			}
+			return crash
+		}
+		old := seed("OldError", 720, 720, 720, 720, 720, 720, 720, 720, 720, 720)
+		hot := seed("HotError", 0, 0, 0)
+		warm := seed("WarmError", 24, 24)
+
+		score := func(groupID string) float64 {
+			t.Helper()
+			group, err := repo.GetGroup(ctx, groupID)
+			if err != nil || group == nil {
+				t.Fatalf("GetGroup(%s) = %v, %v", groupID, group, err)
+			}
+			group.ComputeActivity(now)
+			return group.TrendingScore
+		}
+		order := func() []string {
+			t.Helper()
+			groups, _, err := repo.ListGroups(ctx, GroupFilter{AppID: "app-1", SortBy: "trending", SortOrder: "desc", Limit: 10})
+			if err != nil {
+				t.Fatalf("ListGroups: %v", err)
+			}
+			var ids []string
+			for _, g := range groups {
+				ids = append(ids, g.ID)
+			}
+			return ids
+		}
+		if got, want := order(), []string{hot.GroupID, warm.GroupID, old.GroupID}; !slices.Equal(got, want) {
+			t.Errorf("trending order = %v, want hot, warm, old %v", got, want)
+		}
+
+		steps := []struct {
+			name    string
+			apply   func() error
+			groupID string
+			want    float64
+		}{
+			{"new occurrence", func() error { return repo.IncrementGroupCount(ctx, warm.GroupID) }, warm.GroupID, 2},
+			{"merged", func() error {
+				_, err := repo.MergeGroups(ctx, old.GroupID, warm.GroupID)
+				return err
+			}, warm.GroupID, 2},
+			{"split off the recent crashes", func() error {
+				crashes, _, err := repo.ListCrashes(ctx, CrashFilter{GroupID: hot.GroupID, Limit: 10})
+				if err != nil {
+					return err
+				}
+				_, _, err = repo.SplitGroup(ctx, hot.GroupID, []string{crashes[0].ID, crashes[1].ID}, &core.CrashGroup{
+					ID: uuid.New().String(), Fingerprint: "fp-hot-split", ErrorType: "HotError", Status: string(core.GroupStatusOpen),
+				})
+				return err
+			}, hot.GroupID, 1},
+		}
+		for _, step := range steps {
+			if err := step.apply(); err != nil {
+				t.Fatalf("%s: %v", step.name, err)
+			}
+			if got := score(step.groupID); got < step.want-0.01 || got > step.want+0.01 {
+				t.Errorf("%s: score = %v, want %v", step.name, got, step.want)
+			}
+		}
+		if got := order(); slices.Index(got, warm.GroupID) > slices.Index(got, hot.GroupID) {
+			t.Errorf("trending order after changes = %v, want %s before %s", got, warm.GroupID, hot.GroupID)
+		}
+	})
+}
+
+func TestRepositoryCountCrashesInWindow(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, repo Repository) {
+		ctx := context.Background()
+		createTestApp(t, repo, "app-1")
+		at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+		var groupID string
+		for _, minutes := range []int{0, 5, 9, 10} {
+			groupID = addTestCrash(t, repo, &core.Crash{AppID: "app-1", ErrorType: "StateError", CreatedAt: at.Add(time.Duration(minutes) * time.Minute)}).GroupID
+		}
+		other := addTestCrash(t, repo, &core.Crash{AppID: "app-1", ErrorType: "RangeError", CreatedAt: at.Add(10 * time.Minute)}).GroupID
+
+		tests := []struct {
+			name    string
+			groupID string
+			since   int
+			want    int
+		}{
+			{"all", groupID, 0, 4},
+			{"since a crash", groupID, 5, 3},
+			{"between crashes", groupID, 6, 2},
+			{"after the last", groupID, 11, 0},
+			{"other group", other, 0, 1},
+			{"unknown group", "missing", 0, 0},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				got, err := repo.CountCrashesInWindow(ctx, tt.groupID, at.Add(time.Duration(tt.since)*time.Minute))
+				if err != nil || got != tt.want {
+					t.Errorf("CountCrashesInWindow = %d, %v; want %d", got, err, tt.want)
+				}
+			})
+		}
+	})
+}
+
+func TestRepositoryPing(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, repo Repository) {
+		ctx := context.Background()
+		if err := repo.Ping(ctx); err != nil {
+			t.Fatalf("Ping: %v", err)
+		}
+
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		if err := repo.Ping(canceled); err == nil {
+			t.Error("Ping with a canceled context succeeded, want an error")
+		}
+
+		repo.Close()
+		if err := repo.Ping(ctx); err == nil {
+			t.Error("Ping after Close succeeded, want an error")
+		}
+	})
+}
+
+func TestRepositoryUsers(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, repo Repository) {
+		ctx := context.Background()
+		now := time.Now().UTC().Truncate(time.Second)
+
+		for _, user := range []*core.User{
+			{ID: "user-1", Username: "zoe", PasswordHash: "hash-1", Role: core.UserRoleViewer, CreatedAt: now},
+			{ID: "user-2", Username: "alice", PasswordHash: "hash-2", Role: core.UserRoleAdmin, MustChangePassword: true, CreatedAt: now},
+		} {
+			if err := repo.CreateUser(ctx, user); err != nil {
+				t.Fatalf("CreateUser %s: %v", user.Username, err)
+			}
+		}
+		// Usernames are unique
+		if err := repo.CreateUser(ctx, &core.User{ID: "user-3", Username: "alice", PasswordHash: "hash", Role: core.UserRoleViewer, CreatedAt: now}); err == nil {
+			t.Error("CreateUser with a taken username succeeded, want an error")
+		}
+
+		tests := []struct {
+			name   string
+			get    func() (*core.User, error)
+			wantID string
+		}{
+			{"by ID", func() (*core.User, error) { return repo.GetUser(ctx, "user-1") }, "user-1"},
+			{"by username", func() (*core.User, error) { return repo.GetUserByUsername(ctx, "alice") }, "user-2"},
+			{"unknown ID", func() (*core.User, error) { return repo.GetUser(ctx, "user-9") }, ""},
+			{"unknown username", func() (*core.User, error) { return repo.GetUserByUsername(ctx, "bob") }, ""},
+		}
+		for _, tt := range tests {
+			user, err := tt.get()
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if tt.wantID == "" {
+				if user != nil {
+					t.Errorf("%s = %+v, want nil", tt.name, user)
+				}
+				continue
+			}
+			if user == nil || user.ID != tt.wantID {
+				t.Errorf("%s = %+v, want %s", tt.name, user, tt.wantID)
+			}
+		}
+
+		alice, _ := repo.GetUserByUsername(ctx, "alice")
+		if alice.PasswordHash != "hash-2" || alice.Role != core.UserRoleAdmin || !alice.MustChangePassword || !alice.CreatedAt.Equal(now) {
+			t.Errorf("alice = %+v, want the stored fields", alice)
+		}
+
+		users, err := repo.ListUsers(ctx)
+		if err != nil || len(users) != 2 || users[0].Username != "alice" || users[1].Username != "zoe" {
+			t.Errorf("ListUsers = %+v, %v; want alice and zoe by username", users, err)
+		}
+
+		if err := repo.UpdateUserPassword(ctx, "user-2", "new-hash", false); err != nil {
+			t.Fatalf("UpdateUserPassword: %v", err)
+		}
+		if got, _ := repo.GetUser(ctx, "user-2"); got.PasswordHash != "new-hash" || got.MustChangePassword {
+			t.Errorf("after UpdateUserPassword = %+v, want new-hash without a required change", got)
+		}
+		if got, _ := repo.GetUser(ctx, "user-1"); got.PasswordHash != "hash-1" {
+			t.Errorf("other user's hash = %q, want it unchanged", got.PasswordHash)
+		}
+	})
+}
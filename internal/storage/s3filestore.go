@@ -0,0 +1,283 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/flakerimi/inceptor/internal/config"
+	"github.com/flakerimi/inceptor/internal/core"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// s3ConnectTimeout bounds the bucket check when the store is created
+const s3ConnectTimeout = 10 * time.Second
+
+// S3FileStore keeps crash payloads in an S3-compatible bucket, laid out like
+// LocalFileStore under an optional key prefix:
+// {prefix}/{app_id}/{YYYY-MM-DD}/{crash_id}.json. Paths returned and accepted
+// by its methods are relative to the prefix, so they match the local layout.
+type S3FileStore struct {
+	client          *minio.Client
+	bucket          string
+	prefix          string
+	readConcurrency int
+}
+
+// NewS3FileStore connects to the configured bucket, failing if it doesn't
+// exist or isn't accessible
+func NewS3FileStore(cfg config.S3Config) (*S3FileStore, error) {
+	endpoint, secure := "s3.amazonaws.com", true
+	if cfg.Endpoint != "" {
+		endpoint = cfg.Endpoint
+		if strings.HasPrefix(endpoint, "http://") {
+			secure = false
+		}
+		endpoint = strings.TrimPrefix(strings.TrimPrefix(endpoint, "http://"), "https://")
+		endpoint = strings.TrimSuffix(endpoint, "/")
+	}
+
+	creds := credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey, "")
+	if cfg.AccessKeyID == "" {
+		creds = credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.EnvMinio{},
+			&credentials.FileAWSCredentials{},
+			// Set up as credentials.NewIAM does; a nil client panics
+			&credentials.IAM{Client: &http.Client{Transport: http.DefaultTransport}},
+		})
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  creds,
+		Secure: secure,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s3ConnectTimeout)
+	defer cancel()
+	exists, err := client.BucketExists(ctx, cfg.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to access bucket %q: %w", cfg.Bucket, err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("bucket %q does not exist", cfg.Bucket)
+	}
+
+	return &S3FileStore{
+		client:          client,
+		bucket:          cfg.Bucket,
+		prefix:          strings.Trim(cfg.Prefix, "/"),
+		readConcurrency: defaultReadConcurrency,
+	}, nil
+}
+
+// SetReadConcurrency sets how many objects GetCrashLogs fetches in parallel
+func (fs *S3FileStore) SetReadConcurrency(n int) {
+	if n < 1 {
+		n = 1
+	}
+	fs.readConcurrency = n
+}
+
+// key returns the object key for a path relative to the prefix
+func (fs *S3FileStore) key(relativePath string) string {
+	return path.Join(fs.prefix, relativePath)
+}
+
+// relative returns the path of an object key relative to the prefix
+func (fs *S3FileStore) relative(key string) string {
+	if fs.prefix == "" {
+		return key
+	}
+	return strings.TrimPrefix(key, fs.prefix+"/")
+}
+
+// appPrefix returns the key prefix, ending in "/", of an app's objects
+func (fs *S3FileStore) appPrefix(appID string) string {
+	return fs.key(appID) + "/"
+}
+
+// SaveCrashLog uploads the full crash payload and returns its relative path
+func (fs *S3FileStore) SaveCrashLog(ctx context.Context, crash *core.Crash) (string, error) {
+	relativePath := path.Join(crash.AppID, crash.CreatedAt.Format("2006-01-02"), crash.ID+".json")
+
+	data, err := json.MarshalIndent(crash, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal crash: %w", err)
+	}
+
+	_, err = fs.client.PutObject(ctx, fs.bucket, fs.key(relativePath), bytes.NewReader(data), int64(len(data)),
+		minio.PutObjectOptions{ContentType: "application/json"})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload object: %w", err)
+	}
+
+	return relativePath, nil
+}
+
+// GetCrashLog downloads a crash payload. A missing object returns nil, nil.
+func (fs *S3FileStore) GetCrashLog(ctx context.Context, relativePath string) (*core.Crash, error) {
+	object, err := fs.client.GetObject(ctx, fs.bucket, fs.key(relativePath), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object: %w", err)
+	}
+	defer object.Close()
+
+	// The request is only sent on the first read
+	data, err := io.ReadAll(object)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read object: %w", err)
+	}
+
+	var crash core.Crash
+	if err := decodeJSONNumbers(data, &crash); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptCrashLog, err)
+	}
+
+	return &crash, nil
+}
+
+// GetCrashLogs fetches several crash payloads in parallel, with the same
+// ordering and error semantics as LocalFileStore.GetCrashLogs
+func (fs *S3FileStore) GetCrashLogs(ctx context.Context, relativePaths []string) ([]*core.Crash, []error) {
+	return readCrashLogs(ctx, relativePaths, fs.readConcurrency, fs.GetCrashLog)
+}
+
+// healthCheckKey is the object HealthCheck writes, outside any app's prefix
+const healthCheckKey = ".health-check"
+
+// HealthCheck uploads and removes a small object under the store's prefix
+func (fs *S3FileStore) HealthCheck(ctx context.Context) error {
+	data := []byte("ok")
+	_, err := fs.client.PutObject(ctx, fs.bucket, fs.key(healthCheckKey), bytes.NewReader(data), int64(len(data)),
+		minio.PutObjectOptions{ContentType: "text/plain"})
+	if err != nil {
+		return fmt.Errorf("failed to upload object: %w", err)
+	}
+	if err := fs.client.RemoveObject(ctx, fs.bucket, fs.key(healthCheckKey), minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	return nil
+}
+
+// DeleteCrashLog deletes a crash payload. Deleting a missing object succeeds.
+func (fs *S3FileStore) DeleteCrashLog(ctx context.Context, relativePath string) error {
+	if err := fs.client.RemoveObject(ctx, fs.bucket, fs.key(relativePath), minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	return nil
+}
+
+// DeleteOldLogs deletes an app's payloads stored under dates before the
+// given one
+func (fs *S3FileStore) DeleteOldLogs(ctx context.Context, appID string, before time.Time) (int, error) {
+	appPrefix := fs.appPrefix(appID)
+	cutoffDate := before.Format("2006-01-02")
+
+	// Date "directories" are the common prefixes one level below the app
+	var datePrefixes []string
+	for object := range fs.client.ListObjects(ctx, fs.bucket, minio.ListObjectsOptions{Prefix: appPrefix}) {
+		if object.Err != nil {
+			return 0, fmt.Errorf("failed to list objects: %w", object.Err)
+		}
+		date := strings.TrimSuffix(strings.TrimPrefix(object.Key, appPrefix), "/")
+		if strings.HasSuffix(object.Key, "/") && date < cutoffDate {
+			datePrefixes = append(datePrefixes, object.Key)
+		}
+	}
+
+	deleted := 0
+	for _, datePrefix := range datePrefixes {
+		var keys []string
+		for object := range fs.client.ListObjects(ctx, fs.bucket, minio.ListObjectsOptions{Prefix: datePrefix, Recursive: true}) {
+			if object.Err != nil {
+				return deleted, fmt.Errorf("failed to list objects: %w", object.Err)
+			}
+			keys = append(keys, object.Key)
+		}
+
+		objects := make(chan minio.ObjectInfo, len(keys))
+		for _, key := range keys {
+			objects <- minio.ObjectInfo{Key: key}
+		}
+		close(objects)
+
+		// RemoveObjects deletes in batches and reports only failures
+		failed := 0
+		var firstErr error
+		for removeErr := range fs.client.RemoveObjects(ctx, fs.bucket, objects, minio.RemoveObjectsOptions{}) {
+			failed++
+			if firstErr == nil {
+				firstErr = removeErr.Err
+			}
+		}
+		deleted += len(keys) - failed
+		if firstErr != nil {
+			return deleted, fmt.Errorf("failed to delete objects under %s: %w", datePrefix, firstErr)
+		}
+		if err := ctx.Err(); err != nil {
+			return deleted, err
+		}
+	}
+
+	return deleted, nil
+}
+
+// GetStorageStats sums the sizes of an app's stored payloads
+func (fs *S3FileStore) GetStorageStats(ctx context.Context, appID string) (*StorageStats, error) {
+	stats := &StorageStats{}
+	for object := range fs.client.ListObjects(ctx, fs.bucket, minio.ListObjectsOptions{Prefix: fs.appPrefix(appID), Recursive: true}) {
+		if object.Err != nil {
+			return stats, fmt.Errorf("failed to list objects: %w", object.Err)
+		}
+		if path.Ext(object.Key) == ".json" {
+			stats.TotalFiles++
+			stats.TotalSize += object.Size
+		}
+	}
+	return stats, ctx.Err()
+}
+
+// ScanCorruptLogs downloads and decodes every stored payload for an app and
+// reports the ones that can't be read back, along with how many were checked
+func (fs *S3FileStore) ScanCorruptLogs(ctx context.Context, appID string) (int, []CorruptLog, error) {
+	scanned := 0
+	var corrupt []CorruptLog
+	for object := range fs.client.ListObjects(ctx, fs.bucket, minio.ListObjectsOptions{Prefix: fs.appPrefix(appID), Recursive: true}) {
+		if object.Err != nil {
+			return scanned, corrupt, fmt.Errorf("failed to list objects: %w", object.Err)
+		}
+		if path.Ext(object.Key) != ".json" {
+			continue
+		}
+
+		scanned++
+		relativePath := fs.relative(object.Key)
+		if _, err := fs.GetCrashLog(ctx, relativePath); err != nil {
+			if ctx.Err() != nil {
+				return scanned, corrupt, ctx.Err()
+			}
+			corrupt = append(corrupt, CorruptLog{
+				Path:       relativePath,
+				Size:       object.Size,
+				ModifiedAt: object.LastModified.UTC(),
+				Error:      err.Error(),
+			})
+		}
+	}
+	return scanned, corrupt, ctx.Err()
+}
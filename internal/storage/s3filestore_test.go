@@ -0,0 +1,408 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flakerimi/inceptor/internal/config"
+	"github.com/flakerimi/inceptor/internal/core"
+)
+
+// fakeS3 is an in-memory S3 endpoint serving one bucket, with just enough
+// of the API for S3FileStore
+type fakeS3 struct {
+	*httptest.Server
+	bucket string
+
+	mu      sync.Mutex
+	objects map[string][]byte
+	// authorization is the Authorization header of the last request
+	authorization string
+}
+
+func newFakeS3(t *testing.T, bucket string) *fakeS3 {
+	t.Helper()
+
+	s := &fakeS3{bucket: bucket, objects: make(map[string][]byte)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// store returns an S3FileStore on the fake bucket under prefix
+func (s *fakeS3) store(t *testing.T, prefix string) *S3FileStore {
+	t.Helper()
+
+	store, err := NewS3FileStore(config.S3Config{
+		Bucket:          s.bucket,
+		Prefix:          prefix,
+		Region:          "us-east-1",
+		Endpoint:        s.URL,
+		AccessKeyID:     "key",
+		SecretAccessKey: "secret",
+	})
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	return store
+}
+
+// put stores an object directly
+func (s *fakeS3) put(key string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = data
+}
+
+// keys returns the stored object keys, sorted
+func (s *fakeS3) keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for key := range s.objects {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func (s *fakeS3) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authorization = r.Header.Get("Authorization")
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != s.bucket {
+		s.error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	switch {
+	case key == "" && r.Method == http.MethodHead:
+	case key == "" && r.Method == http.MethodGet:
+		s.list(w, r.URL.Query().Get("prefix"), r.URL.Query().Get("delimiter"))
+	case key == "" && r.Method == http.MethodPost && r.URL.Query().Has("delete"):
+		var request struct {
+			Objects []struct{ Key string } `xml:"Object"`
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&request); err != nil {
+			s.error(w, http.StatusBadRequest, "MalformedXML")
+			return
+		}
+		for _, object := range request.Objects {
+			delete(s.objects, object.Key)
+		}
+		w.Write([]byte(`<DeleteResult></DeleteResult>`))
+	case r.Method == http.MethodPut:
+		data, err := readPayload(r)
+		if err != nil {
+			s.error(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		s.objects[key] = data
+		w.Header().Set("ETag", `"etag"`)
+	case r.Method == http.MethodGet:
+		data, ok := s.objects[key]
+		if !ok {
+			s.error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Write(data)
+	case r.Method == http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s.error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+// list answers ListObjectsV2, rolling keys up to the delimiter into common
+// prefixes
+func (s *fakeS3) list(w http.ResponseWriter, prefix, delimiter string) {
+	type content struct {
+		Key          string
+		LastModified string
+		ETag         string
+		Size         int
+	}
+	type commonPrefix struct {
+		Prefix string
+	}
+	result := struct {
+		XMLName        xml.Name `xml:"ListBucketResult"`
+		Name           string
+		Prefix         string
+		KeyCount       int
+		MaxKeys        int
+		IsTruncated    bool
+		Contents       []content
+		CommonPrefixes []commonPrefix
+	}{Name: s.bucket, Prefix: prefix, MaxKeys: 1000}
+
+	var keys []string
+	for key := range s.objects {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	seen := map[string]bool{}
+	for _, key := range keys {
+		rest, ok := strings.CutPrefix(key, prefix)
+		if !ok {
+			continue
+		}
+		if i := strings.Index(rest, delimiter); delimiter != "" && i >= 0 {
+			if p := prefix + rest[:i+1]; !seen[p] {
+				seen[p] = true
+				result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{p})
+			}
+			continue
+		}
+		result.Contents = append(result.Contents, content{key, time.Now().UTC().Format(time.RFC3339), `"etag"`, len(s.objects[key])})
+	}
+	result.KeyCount = len(result.Contents) + len(result.CommonPrefixes)
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
+
+func (s *fakeS3) error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+}
+
+// readPayload reads an upload's body, decoding the aws-chunked encoding
+// clients use to sign payloads sent over plain HTTP
+func readPayload(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+
+	var data bytes.Buffer
+	reader := bufio.NewReader(r.Body)
+	for {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeText, _, _ := strings.Cut(strings.TrimSpace(header), ";")
+		size, err := strconv.ParseInt(sizeText, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return data.Bytes(), nil
+		}
+		if _, err := io.CopyN(&data, reader, size); err != nil {
+			return nil, err
+		}
+		if _, err := reader.Discard(2); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func TestS3FileStoreCrashLogs(t *testing.T) {
+	ctx := context.Background()
+	s3 := newFakeS3(t, "crashes")
+	store := s3.store(t, "/inceptor/")
+
+	crash := &core.Crash{
+		ID:           "crash-1",
+		AppID:        "app-1",
+		ErrorType:    "StateError",
+		ErrorMessage: "Bad state",
+		CreatedAt:    time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		Metadata:     map[string]interface{}{"cart_items": 3},
+	}
+	path, err := store.SaveCrashLog(ctx, crash)
+	if err != nil {
+		t.Fatalf("SaveCrashLog: %v", err)
+	}
+	// Paths match the local layout; keys add the prefix
+	if want := "app-1/2026-03-01/crash-1.json"; path != want {
+		t.Errorf("path = %s, want %s", path, want)
+	}
+	if keys := s3.keys(); !slices.Equal(keys, []string{"inceptor/app-1/2026-03-01/crash-1.json"}) {
+		t.Errorf("keys = %v, want the payload under the prefix", keys)
+	}
+	s3.put("inceptor/app-1/2026-03-01/corrupt.json", []byte("{not json"))
+
+	tests := []struct {
+		name    string
+		path    string
+		wantID  string
+		wantErr error
+	}{
+		{"stored", path, "crash-1", nil},
+		{"missing", "app-1/2026-03-01/missing.json", "", nil},
+		{"corrupt", "app-1/2026-03-01/corrupt.json", "", ErrCorruptCrashLog},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.GetCrashLog(ctx, tt.path)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			gotID := ""
+			if got != nil {
+				gotID = got.ID
+			}
+			if gotID != tt.wantID {
+				t.Errorf("crash = %q, want %q", gotID, tt.wantID)
+			}
+		})
+	}
+
+	got, err := store.GetCrashLog(ctx, path)
+	if err != nil || got == nil {
+		t.Fatalf("GetCrashLog = %v, %v", got, err)
+	}
+	if got.ErrorMessage != "Bad state" || fmt.Sprint(got.Metadata["cart_items"]) != "3" {
+		t.Errorf("crash = %+v, want it as saved", got)
+	}
+
+	scanned, corrupt, err := store.ScanCorruptLogs(ctx, "app-1")
+	if err != nil || scanned != 2 || len(corrupt) != 1 || corrupt[0].Path != "app-1/2026-03-01/corrupt.json" {
+		t.Errorf("ScanCorruptLogs = %d scanned, %v, %v; want the corrupt payload of 2", scanned, corrupt, err)
+	}
+
+	for _, p := range []string{path, "app-1/2026-03-01/missing.json"} {
+		if err := store.DeleteCrashLog(ctx, p); err != nil {
+			t.Errorf("DeleteCrashLog(%s): %v", p, err)
+		}
+	}
+	if got, err := store.GetCrashLog(ctx, path); got != nil || err != nil {
+		t.Errorf("deleted payload = %v, %v; want nil, nil", got, err)
+	}
+}
+
+func TestS3FileStoreDeleteOldLogs(t *testing.T) {
+	ctx := context.Background()
+	s3 := newFakeS3(t, "crashes")
+	store := s3.store(t, "")
+
+	for _, key := range []string{
+		"app-1/2026-02-27/a.json",
+		"app-1/2026-02-28/b.json",
+		"app-1/2026-02-28/c.json",
+		"app-1/2026-03-01/d.json",
+		"app-1/2026-03-02/e.json",
+		"app-2/2026-02-27/f.json",
+		".health-check",
+	} {
+		s3.put(key, []byte("{}"))
+	}
+
+	stats, err := store.GetStorageStats(ctx, "app-1")
+	if err != nil || stats.TotalFiles != 5 || stats.TotalSize != 10 {
+		t.Errorf("GetStorageStats = %+v, %v; want 5 files of 10 bytes", stats, err)
+	}
+
+	deleted, err := store.DeleteOldLogs(ctx, "app-1", time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("DeleteOldLogs: %v", err)
+	}
+	want := []string{".health-check", "app-1/2026-03-01/d.json", "app-1/2026-03-02/e.json", "app-2/2026-02-27/f.json"}
+	if keys := s3.keys(); deleted != 3 || !slices.Equal(keys, want) {
+		t.Errorf("deleted %d, leaving %v; want 3 deleted, leaving %v", deleted, keys, want)
+	}
+}
+
+func TestNewS3FileStore(t *testing.T) {
+	s3 := newFakeS3(t, "crashes")
+
+	// Serves instance role credentials, as the container credentials
+	// endpoint does
+	roles := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"Code":"Success","AccessKeyId":"role-key","SecretAccessKey":"role-secret","Token":"token","Expiration":%q}`,
+			time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	}))
+	defer roles.Close()
+	for _, env := range []string{"AWS_ACCESS_KEY_ID", "AWS_ACCESS_KEY", "AWS_SECRET_ACCESS_KEY", "AWS_SECRET_KEY", "MINIO_ACCESS_KEY", "MINIO_SECRET_KEY", "MINIO_ROOT_USER", "MINIO_ROOT_PASSWORD"} {
+		t.Setenv(env, "")
+	}
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", t.TempDir()+"/missing")
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", roles.URL)
+
+	tests := []struct {
+		name      string
+		cfg       config.S3Config
+		wantErr   string
+		wantKeyID string
+	}{
+		{"static keys", config.S3Config{Bucket: "crashes", AccessKeyID: "key", SecretAccessKey: "secret"}, "", "key"},
+		{"instance role", config.S3Config{Bucket: "crashes"}, "", "role-key"},
+		{"missing bucket", config.S3Config{Bucket: "other", AccessKeyID: "key", SecretAccessKey: "secret"}, "does not exist", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Endpoint = s3.URL
+			tt.cfg.Region = "us-east-1"
+			_, err := NewS3FileStore(tt.cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewS3FileStore: %v", err)
+			}
+			s3.mu.Lock()
+			defer s3.mu.Unlock()
+			if want := "Credential=" + tt.wantKeyID + "/"; !strings.Contains(s3.authorization, want) {
+				t.Errorf("Authorization = %q, want it signed with %s", s3.authorization, tt.wantKeyID)
+			}
+		})
+	}
+}
+
+func TestS3FileStoreHealthCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		prefix  string
+		down    bool
+		wantErr bool
+	}{
+		{"no prefix", "", false, false},
+		{"prefix", "inceptor", false, false},
+		{"unreachable", "", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s3 := newFakeS3(t, "crashes")
+			s3.put("app-1/2026-03-01/a.json", []byte("{}"))
+			store := s3.store(t, tt.prefix)
+			if tt.down {
+				s3.Close()
+			}
+
+			// Bounded as in /ready, rather than waiting out the client's retries
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			if err := store.HealthCheck(ctx); (err != nil) != tt.wantErr {
+				t.Fatalf("HealthCheck = %v, want error %v", err, tt.wantErr)
+			}
+			// The check object is removed, and nothing else is touched
+			if keys := s3.keys(); !slices.Equal(keys, []string{"app-1/2026-03-01/a.json"}) {
+				t.Errorf("objects = %v, want only the crash", keys)
+			}
+		})
+	}
+}
@@ -1,10 +1,12 @@
 package storage
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"math"
 	"strings"
 	"time"
 
@@ -91,6 +93,7 @@ func (r *SQLiteRepository) Migrate() error {
 		`CREATE INDEX IF NOT EXISTS idx_crashes_group_id ON crashes(group_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_crashes_created_at ON crashes(created_at)`,
 		`CREATE INDEX IF NOT EXISTS idx_crashes_fingerprint ON crashes(fingerprint)`,
+		`CREATE INDEX IF NOT EXISTS idx_crashes_user_id ON crashes(app_id, user_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_crash_groups_app_id ON crash_groups(app_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_crash_groups_fingerprint ON crash_groups(app_id, fingerprint)`,
 		`CREATE INDEX IF NOT EXISTS idx_crash_groups_status ON crash_groups(status)`,
@@ -98,6 +101,57 @@ func (r *SQLiteRepository) Migrate() error {
 			key TEXT PRIMARY KEY,
 			value TEXT NOT NULL
 		)`,
+		`CREATE TABLE IF NOT EXISTS app_versions (
+			app_id TEXT NOT NULL,
+			version TEXT NOT NULL,
+			first_seen DATETIME NOT NULL,
+			PRIMARY KEY (app_id, version)
+		)`,
+		// Fingerprints of groups merged into another, so their later
+		// crashes join the group they were merged into
+		`CREATE TABLE IF NOT EXISTS merged_fingerprints (
+			app_id TEXT NOT NULL,
+			fingerprint TEXT NOT NULL,
+			group_id TEXT NOT NULL,
+			PRIMARY KEY (app_id, fingerprint)
+		)`,
+		// Dashboard accounts
+		`CREATE TABLE IF NOT EXISTS users (
+			id TEXT PRIMARY KEY,
+			username TEXT UNIQUE NOT NULL,
+			password_hash TEXT NOT NULL,
+			role TEXT NOT NULL,
+			must_change_password INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME NOT NULL
+		)`,
+		// Dashboard sessions, keyed by the SHA256 of their token
+		`CREATE TABLE IF NOT EXISTS sessions (
+			token_hash TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			username TEXT NOT NULL,
+			role TEXT NOT NULL,
+			created_at DATETIME NOT NULL,
+			expires_at DATETIME NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_sessions_expires ON sessions(expires_at)`,
+		// Alert events waiting to be sent, see core.AlertQueueRepository
+		`CREATE TABLE IF NOT EXISTS alert_queue (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			event TEXT NOT NULL,
+			alert_id TEXT NOT NULL DEFAULT '',
+			attempts INTEGER NOT NULL DEFAULT 0,
+			next_attempt_at DATETIME NOT NULL,
+			last_error TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_alert_queue_next_attempt_at ON alert_queue(next_attempt_at)`,
+		// Seed seen versions from existing crashes so upgrading doesn't
+		// report every known version as new
+		`INSERT OR IGNORE INTO app_versions (app_id, version, first_seen)
+		SELECT app_id, app_version, MIN(created_at) FROM crashes
+		WHERE app_version IS NOT NULL AND app_version != ''
+			AND NOT EXISTS (SELECT 1 FROM app_versions)
+		GROUP BY app_id, app_version`,
 	}
 
 	for _, migration := range migrations {
@@ -106,27 +160,281 @@ func (r *SQLiteRepository) Migrate() error {
 		}
 	}
 
+	// Columns added after the initial schema
+	columns := []struct{ table, column, definition string }{
+		{"crashes", "fingerprint_version", "INTEGER DEFAULT 1"},
+		{"crashes", "event_id", "TEXT"},
+		{"crash_groups", "title", "TEXT"},
+		{"crashes", "quarantine_reason", "TEXT"},
+		{"crash_groups", "aggregate_only", "INTEGER DEFAULT 0"},
+		{"apps", "include_framework_frames", "INTEGER DEFAULT 0"},
+		{"apps", "fingerprint_metadata_keys", "TEXT"},
+		{"apps", "max_groups", "INTEGER DEFAULT 0"},
+		{"apps", "assignment_rules", "TEXT"},
+		{"apps", "disabled", "INTEGER DEFAULT 0"},
+		{"apps", "maintenance_windows", "TEXT"},
+		{"apps", "rate_limit", "TEXT"},
+		{"apps", "fingerprint_config", "TEXT"},
+		{"crashes", "country", "TEXT"},
+		{"crash_groups", "trend_key", "REAL"},
+		{"crash_groups", "regressed_at", "DATETIME"},
+		{"apps", "api_key_scopes", "TEXT"},
+		{"apps", "api_key_expires_at", "DATETIME"},
+		{"apps", "last_used_at", "DATETIME"},
+		{"crash_groups", "split_key", "TEXT"},
+		{"crash_groups", "culprit", "TEXT"},
+	}
+	for _, col := range columns {
+		if err := r.addColumnIfMissing(col.table, col.column, col.definition); err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+	}
+
+	if err := r.seedTrendKeys(); err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+	if err := r.createAPIKeys(); err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+
+	// Indexes on columns added above
+	indexes := []string{
+		`CREATE INDEX IF NOT EXISTS idx_crashes_event_id ON crashes(app_id, event_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_crashes_app_version ON crashes(app_id, app_version)`,
+		`CREATE INDEX IF NOT EXISTS idx_crashes_country ON crashes(app_id, country)`,
+		`CREATE INDEX IF NOT EXISTS idx_crash_groups_trend_key ON crash_groups(app_id, trend_key)`,
+	}
+	for _, index := range indexes {
+		if _, err := r.db.Exec(index); err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+	}
+
 	return nil
 }
 
+// seedAPIKeysSQL copies each app's key into the new api_keys table, with the
+// app's ID as the key's. The apps columns holding the key's scopes and expiry
+// are not used after that.
+const seedAPIKeysSQL = `INSERT INTO api_keys (id, app_id, key_hash, label, scopes, expires_at, created_at)
+	SELECT id, id, api_key_hash, 'default', COALESCE(api_key_scopes, '[]'), api_key_expires_at, created_at FROM apps`
+
+// createAPIKeys creates the api_keys table, which holds several keys per app
+// so keys can be rotated without downtime; apps.api_key_hash is the most
+// recently issued one. The table is created and seeded in one transaction,
+// once, so an app whose keys have all been revoked doesn't get its old key
+// back on the next start.
+func (r *SQLiteRepository) createAPIKeys() error {
+	var exists int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'api_keys'`).Scan(&exists); err != nil || exists > 0 {
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{
+		`CREATE TABLE api_keys (
+			id TEXT PRIMARY KEY,
+			app_id TEXT NOT NULL,
+			key_hash TEXT UNIQUE NOT NULL,
+			label TEXT NOT NULL DEFAULT '',
+			scopes TEXT NOT NULL DEFAULT '[]',
+			expires_at DATETIME,
+			created_at DATETIME NOT NULL,
+			last_used_at DATETIME,
+			FOREIGN KEY (app_id) REFERENCES apps(id)
+		)`,
+		`CREATE INDEX idx_api_keys_app_id ON api_keys(app_id)`,
+		seedAPIKeysSQL,
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// addColumnIfMissing adds a column to an existing table if it isn't there yet
+func (r *SQLiteRepository) addColumnIfMissing(table, column, definition string) error {
+	rows, err := r.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	_, err = r.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// seedTrendKeys gives groups created before trend keys were stored one,
+// treating their occurrences as if they all happened when last seen
+func (r *SQLiteRepository) seedTrendKeys() error {
+	rows, err := r.db.Query(`SELECT id, last_seen, occurrence_count FROM crash_groups WHERE trend_key IS NULL`)
+	if err != nil {
+		return err
+	}
+	keys := make(map[string]float64)
+	for rows.Next() {
+		var id string
+		var lastSeen time.Time
+		var count int
+		if err := rows.Scan(&id, &lastSeen, &count); err != nil {
+			rows.Close()
+			return err
+		}
+		keys[id] = core.TrendKey(lastSeen) + math.Log(float64(max(count, 1)))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(keys) == 0 {
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for id, key := range keys {
+		if _, err := tx.Exec(`UPDATE crash_groups SET trend_key = ? WHERE id = ?`, key, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Close checkpoints the write-ahead log into the database file, so a clean
+// shutdown leaves no WAL to replay, and closes the database
+// Ping runs a trivial query, which fails once the database is closed or
+// unreachable
+func (r *SQLiteRepository) Ping(ctx context.Context) error {
+	var one int
+	return r.db.QueryRowContext(ctx, "SELECT 1").Scan(&one)
+}
+
 func (r *SQLiteRepository) Close() error {
-	return r.db.Close()
+	_, checkpointErr := r.db.Exec("PRAGMA wal_checkpoint(TRUNCATE)")
+	if err := r.db.Close(); err != nil {
+		return err
+	}
+	if checkpointErr != nil {
+		return fmt.Errorf("failed to checkpoint database: %w", checkpointErr)
+	}
+	return nil
 }
 
 // App operations
+
+// appColumns is the column list shared by all app SELECTs, in scanApp order
+const appColumns = `id, name, api_key_hash, created_at, retention_days, COALESCE(include_framework_frames, 0), COALESCE(fingerprint_metadata_keys, '[]'), COALESCE(max_groups, 0), COALESCE(assignment_rules, '[]'), COALESCE(disabled, 0), COALESCE(maintenance_windows, '[]'), COALESCE(rate_limit, '{}'), COALESCE(fingerprint_config, '{}'), last_used_at`
+
+// scanApp scans a row selected with appColumns
+func scanApp(row rowScanner) (*core.App, error) {
+	app := &core.App{}
+	var metadataKeys, assignmentRules, maintenanceWindows, rateLimit, fingerprintConfig string
+	var lastUsedAt sql.NullTime
+	if err := row.Scan(&app.ID, &app.Name, &app.APIKeyHash, &app.CreatedAt, &app.RetentionDays,
+		&app.IncludeFrameworkFrames, &metadataKeys, &app.MaxGroups, &assignmentRules, &app.Disabled,
+		&maintenanceWindows, &rateLimit, &fingerprintConfig, &lastUsedAt); err != nil {
+		return nil, err
+	}
+	if lastUsedAt.Valid {
+		app.LastUsedAt = &lastUsedAt.Time
+	}
+	json.Unmarshal([]byte(metadataKeys), &app.FingerprintMetadataKeys)
+	json.Unmarshal([]byte(assignmentRules), &app.AssignmentRules)
+	json.Unmarshal([]byte(maintenanceWindows), &app.MaintenanceWindows)
+	json.Unmarshal([]byte(rateLimit), &app.RateLimit)
+	json.Unmarshal([]byte(fingerprintConfig), &app.FingerprintConfig)
+	return app, nil
+}
+
+// appInsertArgs returns an app's values for INSERT INTO apps, in the column
+// order used by CreateApp
+func appInsertArgs(app *core.App) []interface{} {
+	metadataKeys, _ := json.Marshal(app.FingerprintMetadataKeys)
+	assignmentRules, _ := json.Marshal(app.AssignmentRules)
+	maintenanceWindows, _ := json.Marshal(app.MaintenanceWindows)
+	rateLimit, _ := json.Marshal(app.RateLimit)
+	fingerprintConfig, _ := json.Marshal(app.FingerprintConfig)
+	return []interface{}{
+		app.ID, app.Name, app.APIKeyHash, app.CreatedAt, app.RetentionDays, app.IncludeFrameworkFrames, string(metadataKeys), app.MaxGroups,
+		string(assignmentRules), app.Disabled, string(maintenanceWindows), string(rateLimit), string(fingerprintConfig),
+	}
+}
+
+// appAPIKey returns the API key an app is created with. Its ID defaults to
+// the app's, as for keys of apps created before api_keys existed.
+func appAPIKey(app *core.App) *core.APIKey {
+	id := app.APIKeyID
+	if id == "" {
+		id = app.ID
+	}
+	return &core.APIKey{
+		ID:        id,
+		AppID:     app.ID,
+		KeyHash:   app.APIKeyHash,
+		Label:     "default",
+		Scopes:    app.APIKeyScopes,
+		ExpiresAt: app.APIKeyExpiresAt,
+		CreatedAt: app.CreatedAt,
+	}
+}
+
+const insertAppSQL = `INSERT INTO apps (id, name, api_key_hash, created_at, retention_days, include_framework_frames, fingerprint_metadata_keys, max_groups, assignment_rules, disabled, maintenance_windows, rate_limit, fingerprint_config)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
 func (r *SQLiteRepository) CreateApp(ctx context.Context, app *core.App) error {
-	_, err := r.db.ExecContext(ctx,
-		`INSERT INTO apps (id, name, api_key_hash, created_at, retention_days) VALUES (?, ?, ?, ?, ?)`,
-		app.ID, app.Name, app.APIKeyHash, app.CreatedAt, app.RetentionDays,
-	)
-	return err
+	return r.CreateApps(ctx, []*core.App{app})
+}
+
+func (r *SQLiteRepository) CreateApps(ctx context.Context, apps []*core.App) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, app := range apps {
+		if _, err := tx.ExecContext(ctx, insertAppSQL, appInsertArgs(app)...); err != nil {
+			return fmt.Errorf("failed to create app %q: %w", app.Name, err)
+		}
+		key := appAPIKey(app)
+		if _, err := tx.ExecContext(ctx, `INSERT INTO api_keys (`+apiKeyColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`, apiKeyArgs(key)...); err != nil {
+			return fmt.Errorf("failed to create app %q: %w", app.Name, err)
+		}
+	}
+	return tx.Commit()
 }
 
 func (r *SQLiteRepository) GetApp(ctx context.Context, id string) (*core.App, error) {
-	app := &core.App{}
-	err := r.db.QueryRowContext(ctx,
-		`SELECT id, name, api_key_hash, created_at, retention_days FROM apps WHERE id = ?`, id,
-	).Scan(&app.ID, &app.Name, &app.APIKeyHash, &app.CreatedAt, &app.RetentionDays)
+	app, err := scanApp(r.db.QueryRowContext(ctx,
+		`SELECT `+appColumns+` FROM apps WHERE id = ?`, id,
+	))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -134,19 +442,27 @@ func (r *SQLiteRepository) GetApp(ctx context.Context, id string) (*core.App, er
 }
 
 func (r *SQLiteRepository) GetAppByAPIKey(ctx context.Context, apiKeyHash string) (*core.App, error) {
-	app := &core.App{}
-	err := r.db.QueryRowContext(ctx,
-		`SELECT id, name, api_key_hash, created_at, retention_days FROM apps WHERE api_key_hash = ?`, apiKeyHash,
-	).Scan(&app.ID, &app.Name, &app.APIKeyHash, &app.CreatedAt, &app.RetentionDays)
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = ?`, apiKeyHash,
+	))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
-	return app, err
+	if err != nil {
+		return nil, err
+	}
+
+	app, err := r.GetApp(ctx, key.AppID)
+	if err != nil || app == nil {
+		return nil, err
+	}
+	app.WithAPIKey(key)
+	return app, nil
 }
 
 func (r *SQLiteRepository) ListApps(ctx context.Context) ([]*core.App, error) {
 	rows, err := r.db.QueryContext(ctx,
-		`SELECT id, name, api_key_hash, created_at, retention_days FROM apps ORDER BY created_at DESC`,
+		`SELECT `+appColumns+` FROM apps ORDER BY created_at DESC`,
 	)
 	if err != nil {
 		return nil, err
@@ -155,8 +471,8 @@ func (r *SQLiteRepository) ListApps(ctx context.Context) ([]*core.App, error) {
 
 	var apps []*core.App
 	for rows.Next() {
-		app := &core.App{}
-		if err := rows.Scan(&app.ID, &app.Name, &app.APIKeyHash, &app.CreatedAt, &app.RetentionDays); err != nil {
+		app, err := scanApp(rows)
+		if err != nil {
 			return nil, err
 		}
 		apps = append(apps, app)
@@ -165,21 +481,136 @@ func (r *SQLiteRepository) ListApps(ctx context.Context) ([]*core.App, error) {
 }
 
 func (r *SQLiteRepository) UpdateApp(ctx context.Context, app *core.App) error {
+	metadataKeys, _ := json.Marshal(app.FingerprintMetadataKeys)
+	assignmentRules, _ := json.Marshal(app.AssignmentRules)
+	maintenanceWindows, _ := json.Marshal(app.MaintenanceWindows)
+	rateLimit, _ := json.Marshal(app.RateLimit)
+	fingerprintConfig, _ := json.Marshal(app.FingerprintConfig)
 	_, err := r.db.ExecContext(ctx,
-		`UPDATE apps SET name = ?, retention_days = ? WHERE id = ?`,
-		app.Name, app.RetentionDays, app.ID,
+		`UPDATE apps SET name = ?, retention_days = ?, include_framework_frames = ?, fingerprint_metadata_keys = ?, max_groups = ?,
+		assignment_rules = ?, disabled = ?, maintenance_windows = ?, rate_limit = ?, fingerprint_config = ? WHERE id = ?`,
+		app.Name, app.RetentionDays, app.IncludeFrameworkFrames, string(metadataKeys), app.MaxGroups, string(assignmentRules),
+		app.Disabled, string(maintenanceWindows), string(rateLimit), string(fingerprintConfig), app.ID,
 	)
 	return err
 }
 
-func (r *SQLiteRepository) UpdateAppAPIKey(ctx context.Context, id string, newKeyHash string) error {
+// API key operations
+
+const apiKeyColumns = `id, app_id, key_hash, label, scopes, expires_at, created_at, last_used_at`
+
+// scanAPIKey scans a row selected with apiKeyColumns
+func scanAPIKey(row rowScanner) (*core.APIKey, error) {
+	var key core.APIKey
+	var scopes string
+	var expiresAt, lastUsedAt sql.NullTime
+	if err := row.Scan(&key.ID, &key.AppID, &key.KeyHash, &key.Label, &scopes, &expiresAt, &key.CreatedAt, &lastUsedAt); err != nil {
+		return nil, err
+	}
+	json.Unmarshal([]byte(scopes), &key.Scopes)
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	return &key, nil
+}
+
+// apiKeyArgs returns a key's values in apiKeyColumns order
+func apiKeyArgs(key *core.APIKey) []interface{} {
+	scopes, _ := json.Marshal(key.Scopes)
+	if key.Scopes == nil {
+		scopes = []byte("[]")
+	}
+	return []interface{}{key.ID, key.AppID, key.KeyHash, key.Label, string(scopes), key.ExpiresAt, key.CreatedAt, key.LastUsedAt}
+}
+
+func (r *SQLiteRepository) RotateAPIKey(ctx context.Context, key *core.APIKey, oldKeysExpireAt time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE api_keys SET expires_at = ? WHERE app_id = ? AND (expires_at IS NULL OR expires_at > ?)`,
+		oldKeysExpireAt, key.AppID, oldKeysExpireAt,
+	); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO api_keys (`+apiKeyColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`, apiKeyArgs(key)...); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE apps SET api_key_hash = ? WHERE id = ?`, key.KeyHash, key.AppID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *SQLiteRepository) GetAPIKey(ctx context.Context, appID, id string) (*core.APIKey, error) {
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE app_id = ? AND id = ?`, appID, id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return key, err
+}
+
+func (r *SQLiteRepository) ListAPIKeys(ctx context.Context, appID string) ([]*core.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE app_id = ? ORDER BY created_at DESC, id`, appID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]*core.APIKey, 0)
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (r *SQLiteRepository) UpdateAPIKey(ctx context.Context, key *core.APIKey) error {
+	scopes, _ := json.Marshal(key.Scopes)
+	if key.Scopes == nil {
+		scopes = []byte("[]")
+	}
 	_, err := r.db.ExecContext(ctx,
-		`UPDATE apps SET api_key_hash = ? WHERE id = ?`,
-		newKeyHash, id,
+		`UPDATE api_keys SET label = ?, scopes = ?, expires_at = ? WHERE id = ?`,
+		key.Label, string(scopes), key.ExpiresAt, key.ID,
 	)
 	return err
 }
 
+func (r *SQLiteRepository) DeleteAPIKey(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM api_keys WHERE id = ?`, id)
+	return err
+}
+
+func (r *SQLiteRepository) TouchAPIKey(ctx context.Context, appID, id string, usedAt time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE api_keys SET last_used_at = ? WHERE id = ?`, usedAt, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE apps SET last_used_at = ? WHERE id = ?`, usedAt, appID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 func (r *SQLiteRepository) DeleteApp(ctx context.Context, id string) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -198,10 +629,23 @@ func (r *SQLiteRepository) DeleteApp(ctx context.Context, id string) error {
 	}
 
 	// Delete crash groups
+	if _, err := tx.ExecContext(ctx, `DELETE FROM merged_fingerprints WHERE app_id = ?`, id); err != nil {
+		return err
+	}
 	if _, err := tx.ExecContext(ctx, `DELETE FROM crash_groups WHERE app_id = ?`, id); err != nil {
 		return err
 	}
 
+	// Delete seen versions
+	if _, err := tx.ExecContext(ctx, `DELETE FROM app_versions WHERE app_id = ?`, id); err != nil {
+		return err
+	}
+
+	// Delete API keys
+	if _, err := tx.ExecContext(ctx, `DELETE FROM api_keys WHERE app_id = ?`, id); err != nil {
+		return err
+	}
+
 	// Delete app
 	if _, err := tx.ExecContext(ctx, `DELETE FROM apps WHERE id = ?`, id); err != nil {
 		return err
@@ -210,35 +654,186 @@ func (r *SQLiteRepository) DeleteApp(ctx context.Context, id string) error {
 	return tx.Commit()
 }
 
+// RecordAppVersion marks an app version as seen, reporting true only the
+// first time the version is recorded
+func (r *SQLiteRepository) RecordAppVersion(ctx context.Context, appID, version string, seenAt time.Time) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		`INSERT OR IGNORE INTO app_versions (app_id, version, first_seen) VALUES (?, ?, ?)`,
+		appID, version, seenAt,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// ListAppVersions returns an app's most recently first seen versions,
+// newest first
+func (r *SQLiteRepository) ListAppVersions(ctx context.Context, appID string, limit int) ([]core.AppVersion, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT version, first_seen FROM app_versions WHERE app_id = ? ORDER BY first_seen DESC, version DESC LIMIT ?`,
+		appID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []core.AppVersion
+	for rows.Next() {
+		var v core.AppVersion
+		if err := rows.Scan(&v.Version, &v.FirstSeen); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
 // Crash operations
+
+// crashColumns is the column list shared by all crash SELECTs, in scanCrash order
+const crashColumns = `id, app_id, COALESCE(event_id, ''), app_version, platform, os_version, device_model, error_type, error_message, fingerprint, COALESCE(fingerprint_version, 1), group_id, user_id, environment, created_at, log_file_path, COALESCE(metadata, '{}'), COALESCE(quarantine_reason, ''), COALESCE(country, '')`
+
+// decodeJSONNumbers unmarshals JSON keeping numbers as json.Number, so
+// integers stored in free-form maps read back without float64 rounding
+func decodeJSONNumbers(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanCrash scans a row selected with crashColumns
+func scanCrash(row rowScanner) (*core.Crash, error) {
+	crash := &core.Crash{}
+	var metadata string
+	if err := row.Scan(&crash.ID, &crash.AppID, &crash.EventID, &crash.AppVersion, &crash.Platform, &crash.OSVersion,
+		&crash.DeviceModel, &crash.ErrorType, &crash.ErrorMessage, &crash.Fingerprint, &crash.FingerprintVersion,
+		&crash.GroupID, &crash.UserID, &crash.Environment, &crash.CreatedAt, &crash.LogFilePath, &metadata,
+		&crash.QuarantineReason, &crash.Country); err != nil {
+		return nil, err
+	}
+	decodeJSONNumbers([]byte(metadata), &crash.Metadata)
+	return crash, nil
+}
+
 func (r *SQLiteRepository) CreateCrash(ctx context.Context, crash *core.Crash) error {
 	metadata, _ := json.Marshal(crash.Metadata)
+	quarantineReason := sql.NullString{String: crash.QuarantineReason, Valid: crash.QuarantineReason != ""}
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO crashes (id, app_id, event_id, app_version, platform, os_version, device_model, error_type, error_message, fingerprint, fingerprint_version, group_id, user_id, environment, created_at, log_file_path, metadata, quarantine_reason, country)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		crash.ID, crash.AppID, crash.EventID, crash.AppVersion, crash.Platform, crash.OSVersion, crash.DeviceModel,
+		crash.ErrorType, crash.ErrorMessage, crash.Fingerprint, crash.FingerprintVersion, crash.GroupID, crash.UserID,
+		crash.Environment, crash.CreatedAt, crash.LogFilePath, string(metadata), quarantineReason, crash.Country,
+	)
+	return err
+}
+
+// ReleaseCrash clears a crash's quarantine and attaches it to a group
+func (r *SQLiteRepository) ReleaseCrash(ctx context.Context, id, groupID string) error {
 	_, err := r.db.ExecContext(ctx,
-		`INSERT INTO crashes (id, app_id, app_version, platform, os_version, device_model, error_type, error_message, fingerprint, group_id, user_id, environment, created_at, log_file_path, metadata)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		crash.ID, crash.AppID, crash.AppVersion, crash.Platform, crash.OSVersion, crash.DeviceModel,
-		crash.ErrorType, crash.ErrorMessage, crash.Fingerprint, crash.GroupID, crash.UserID,
-		crash.Environment, crash.CreatedAt, crash.LogFilePath, string(metadata),
+		`UPDATE crashes SET quarantine_reason = NULL, group_id = ? WHERE id = ?`,
+		groupID, id,
 	)
 	return err
 }
 
 func (r *SQLiteRepository) GetCrash(ctx context.Context, id string) (*core.Crash, error) {
-	crash := &core.Crash{}
-	var metadata string
-	err := r.db.QueryRowContext(ctx,
-		`SELECT id, app_id, app_version, platform, os_version, device_model, error_type, error_message, fingerprint, group_id, user_id, environment, created_at, log_file_path, COALESCE(metadata, '{}')
-		FROM crashes WHERE id = ?`, id,
-	).Scan(&crash.ID, &crash.AppID, &crash.AppVersion, &crash.Platform, &crash.OSVersion,
-		&crash.DeviceModel, &crash.ErrorType, &crash.ErrorMessage, &crash.Fingerprint,
-		&crash.GroupID, &crash.UserID, &crash.Environment, &crash.CreatedAt, &crash.LogFilePath, &metadata)
+	crash, err := scanCrash(r.db.QueryRowContext(ctx,
+		`SELECT `+crashColumns+` FROM crashes WHERE id = ?`, id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return crash, nil
+}
+
+// GetCrashesByIDs returns the crashes with the given IDs, in no particular
+// order. Unknown IDs are skipped.
+func (r *SQLiteRepository) GetCrashesByIDs(ctx context.Context, ids []string) ([]*core.Crash, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+crashColumns+` FROM crashes WHERE id IN (`+placeholders+`)`, args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var crashes []*core.Crash
+	for rows.Next() {
+		crash, err := scanCrash(rows)
+		if err != nil {
+			return nil, err
+		}
+		crashes = append(crashes, crash)
+	}
+	return crashes, rows.Err()
+}
+
+func (r *SQLiteRepository) ListCrashesCreatedBetween(ctx context.Context, after time.Time, afterID string, before time.Time, limit int) ([]*core.Crash, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+crashColumns+` FROM crashes
+		WHERE (created_at > ? OR (created_at = ? AND id > ?)) AND created_at < ?
+		ORDER BY created_at ASC, id ASC LIMIT ?`,
+		after.UTC(), after.UTC(), afterID, before.UTC(), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var crashes []*core.Crash
+	for rows.Next() {
+		crash, err := scanCrash(rows)
+		if err != nil {
+			return nil, err
+		}
+		crashes = append(crashes, crash)
+	}
+	return crashes, rows.Err()
+}
+
+// GetCrashByEventID returns the most recent crash for an app with the given
+// client event ID created at or after since. A zero since matches any age.
+func (r *SQLiteRepository) GetCrashByEventID(ctx context.Context, appID, eventID string, since time.Time) (*core.Crash, error) {
+	query := `SELECT ` + crashColumns + ` FROM crashes WHERE app_id = ? AND event_id = ?`
+	args := []interface{}{appID, eventID}
+	if !since.IsZero() {
+		query += " AND created_at >= ?"
+		args = append(args, since)
+	}
+	query += " ORDER BY created_at DESC LIMIT 1"
+
+	crash, err := scanCrash(r.db.QueryRowContext(ctx, query, args...))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, err
 	}
-	json.Unmarshal([]byte(metadata), &crash.Metadata)
 	return crash, nil
 }
 
@@ -246,6 +841,11 @@ func (r *SQLiteRepository) ListCrashes(ctx context.Context, filter CrashFilter)
 	var conditions []string
 	var args []interface{}
 
+	if filter.Quarantined {
+		conditions = append(conditions, "quarantine_reason IS NOT NULL")
+	} else {
+		conditions = append(conditions, "quarantine_reason IS NULL")
+	}
 	if filter.AppID != "" {
 		conditions = append(conditions, "app_id = ?")
 		args = append(args, filter.AppID)
@@ -270,6 +870,10 @@ func (r *SQLiteRepository) ListCrashes(ctx context.Context, filter CrashFilter)
 		conditions = append(conditions, "user_id = ?")
 		args = append(args, filter.UserID)
 	}
+	if filter.Country != "" {
+		conditions = append(conditions, "country = ?")
+		args = append(args, strings.ToUpper(filter.Country))
+	}
 	if filter.FromDate != nil {
 		conditions = append(conditions, "created_at >= ?")
 		args = append(args, filter.FromDate)
@@ -283,6 +887,11 @@ func (r *SQLiteRepository) ListCrashes(ctx context.Context, filter CrashFilter)
 		searchTerm := "%" + filter.Search + "%"
 		args = append(args, searchTerm, searchTerm)
 	}
+	for _, key := range filter.HasMetadata {
+		// json_each matches keys whose value is JSON null, unlike json_extract
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM json_each(crashes.metadata) WHERE json_each.key = ?)")
+		args = append(args, key)
+	}
 
 	whereClause := ""
 	if len(conditions) > 0 {
@@ -300,10 +909,24 @@ func (r *SQLiteRepository) ListCrashes(ctx context.Context, filter CrashFilter)
 	if filter.Limit == 0 {
 		filter.Limit = 50
 	}
+	if filter.Cursor != "" {
+		// Keyset pagination doesn't scan the rows before the page, unlike OFFSET
+		after, afterID, err := DecodeCursor(filter.Cursor)
+		if err != nil {
+			return nil, 0, err
+		}
+		cursorCondition := "(created_at < ? OR (created_at = ? AND id < ?))"
+		if whereClause == "" {
+			whereClause = "WHERE " + cursorCondition
+		} else {
+			whereClause += " AND " + cursorCondition
+		}
+		args = append(args, after, after, afterID)
+		filter.Offset = 0
+	}
 	query := fmt.Sprintf(
-		`SELECT id, app_id, app_version, platform, os_version, device_model, error_type, error_message, fingerprint, group_id, user_id, environment, created_at, log_file_path, COALESCE(metadata, '{}')
-		FROM crashes %s ORDER BY created_at DESC LIMIT ? OFFSET ?`,
-		whereClause,
+		`SELECT %s FROM crashes %s ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?`,
+		crashColumns, whereClause,
 	)
 	args = append(args, filter.Limit, filter.Offset)
 
@@ -315,110 +938,474 @@ func (r *SQLiteRepository) ListCrashes(ctx context.Context, filter CrashFilter)
 
 	var crashes []*core.Crash
 	for rows.Next() {
-		crash := &core.Crash{}
-		var metadata string
-		if err := rows.Scan(&crash.ID, &crash.AppID, &crash.AppVersion, &crash.Platform, &crash.OSVersion,
-			&crash.DeviceModel, &crash.ErrorType, &crash.ErrorMessage, &crash.Fingerprint,
-			&crash.GroupID, &crash.UserID, &crash.Environment, &crash.CreatedAt, &crash.LogFilePath, &metadata); err != nil {
+		crash, err := scanCrash(rows)
+		if err != nil {
 			return nil, 0, err
 		}
-		json.Unmarshal([]byte(metadata), &crash.Metadata)
 		crashes = append(crashes, crash)
 	}
 	return crashes, total, rows.Err()
 }
 
+func (r *SQLiteRepository) CountCrashesInWindow(ctx context.Context, groupID string, since time.Time) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM crashes WHERE group_id = ? AND created_at >= ?`, groupID, since,
+	).Scan(&count)
+	return count, err
+}
+
 func (r *SQLiteRepository) DeleteCrash(ctx context.Context, id string) error {
 	_, err := r.db.ExecContext(ctx, `DELETE FROM crashes WHERE id = ?`, id)
 	return err
 }
 
-func (r *SQLiteRepository) DeleteCrashesOlderThan(ctx context.Context, appID string, before time.Time) (int, error) {
-	result, err := r.db.ExecContext(ctx,
-		`DELETE FROM crashes WHERE app_id = ? AND created_at < ?`, appID, before,
-	)
-	if err != nil {
-		return 0, err
+func (r *SQLiteRepository) DeleteCrashesOlderThan(ctx context.Context, appID string, before time.Time) (int, error) {
+	result, err := r.db.ExecContext(ctx,
+		`DELETE FROM crashes WHERE app_id = ? AND created_at < ?`, appID, before,
+	)
+	if err != nil {
+		return 0, err
+	}
+	count, _ := result.RowsAffected()
+	return int(count), nil
+}
+
+// Crash group operations
+
+// groupColumns is the column list shared by all crash group SELECTs, in scanGroup order
+const groupColumns = `id, app_id, fingerprint, COALESCE(title, ''), error_type, error_message, first_seen, last_seen, occurrence_count, status, assigned_to, notes, COALESCE(aggregate_only, 0), COALESCE(trend_key, 0), regressed_at, COALESCE(split_key, ''), COALESCE(culprit, '')`
+
+// scanGroup scans a row selected with groupColumns
+func scanGroup(row rowScanner) (*core.CrashGroup, error) {
+	group := &core.CrashGroup{}
+	var assignedTo, notes sql.NullString
+	var regressedAt sql.NullTime
+	if err := row.Scan(&group.ID, &group.AppID, &group.Fingerprint, &group.Title, &group.ErrorType, &group.ErrorMessage,
+		&group.FirstSeen, &group.LastSeen, &group.OccurrenceCount, &group.Status, &assignedTo, &notes,
+		&group.AggregateOnly, &group.TrendKey, &regressedAt, &group.SplitKey, &group.Culprit); err != nil {
+		return nil, err
+	}
+	group.AssignedTo = assignedTo.String
+	group.Notes = notes.String
+	if regressedAt.Valid {
+		group.RegressedAt = &regressedAt.Time
+	}
+	return group, nil
+}
+
+func (r *SQLiteRepository) GetOrCreateGroup(ctx context.Context, crash *core.Crash) (*core.CrashGroup, bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	// Try to find existing group, or the group this fingerprint's was merged into
+	group, err := scanGroup(tx.QueryRowContext(ctx,
+		`SELECT `+groupColumns+` FROM crash_groups WHERE app_id = ? AND (fingerprint = ?
+			OR id = (SELECT group_id FROM merged_fingerprints WHERE app_id = ? AND fingerprint = ?))
+		LIMIT 1`,
+		crash.AppID, crash.Fingerprint, crash.AppID, crash.Fingerprint,
+	))
+
+	if err == nil {
+		if crash.Historical {
+			// Old replayed crashes don't make the group look recent again
+			return group, false, tx.Commit()
+		}
+		// Group exists, update it
+		group.TrendKey = core.AddTrend(group.TrendKey, core.TrendKey(crash.CreatedAt))
+		_, err = tx.ExecContext(ctx,
+			`UPDATE crash_groups SET last_seen = ?, occurrence_count = occurrence_count + 1, trend_key = ? WHERE id = ?`,
+			crash.CreatedAt, group.TrendKey, group.ID,
+		)
+		if err != nil {
+			return nil, false, err
+		}
+		group.LastSeen = crash.CreatedAt
+		group.OccurrenceCount++
+
+		// A crash in a resolved group is a regression: reopen it
+		if group.Status == string(core.GroupStatusResolved) {
+			_, err = tx.ExecContext(ctx,
+				`UPDATE crash_groups SET status = ?, regressed_at = ? WHERE id = ?`,
+				string(core.GroupStatusOpen), crash.CreatedAt, group.ID,
+			)
+			if err != nil {
+				return nil, false, err
+			}
+			group.PreviousStatus = group.Status
+			group.Status = string(core.GroupStatusOpen)
+			group.RegressedAt = &crash.CreatedAt
+		}
+		return group, false, tx.Commit()
+	}
+
+	if err != sql.ErrNoRows {
+		return nil, false, err
+	}
+
+	// Create new group
+	group = &core.CrashGroup{
+		ID:              crash.GroupID,
+		AppID:           crash.AppID,
+		Fingerprint:     crash.Fingerprint,
+		Title:           crash.GroupTitle,
+		Culprit:         crash.GroupCulprit,
+		ErrorType:       crash.ErrorType,
+		ErrorMessage:    crash.ErrorMessage,
+		FirstSeen:       crash.CreatedAt,
+		LastSeen:        crash.CreatedAt,
+		OccurrenceCount: 1,
+		Status:          string(core.GroupStatusOpen),
+		TrendKey:        core.TrendKey(crash.CreatedAt),
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO crash_groups (id, app_id, fingerprint, title, error_type, error_message, first_seen, last_seen, occurrence_count, status, trend_key, culprit)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		group.ID, group.AppID, group.Fingerprint, group.Title, group.ErrorType, group.ErrorMessage,
+		group.FirstSeen, group.LastSeen, group.OccurrenceCount, group.Status, group.TrendKey, group.Culprit,
+	)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return group, true, tx.Commit()
+}
+
+func (r *SQLiteRepository) GetGroup(ctx context.Context, id string) (*core.CrashGroup, error) {
+	group, err := scanGroup(r.db.QueryRowContext(ctx,
+		`SELECT `+groupColumns+` FROM crash_groups WHERE id = ?`, id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return group, err
+}
+
+// SummarizeGroupCrashes sets each group's AffectedUsers and LatestCrashID
+// from its stored crashes
+func (r *SQLiteRepository) SummarizeGroupCrashes(ctx context.Context, groups []*core.CrashGroup) error {
+	if len(groups) == 0 {
+		return nil
+	}
+	byID := make(map[string]*core.CrashGroup, len(groups))
+	args := make([]interface{}, 0, len(groups))
+	for _, g := range groups {
+		byID[g.ID] = g
+		args = append(args, g.ID)
+	}
+
+	// With MAX(), SQLite takes the bare id column from the row holding the
+	// maximum, i.e. the most recent crash
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(groups)), ",")
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT group_id, COUNT(DISTINCT NULLIF(user_id, '')), id, MAX(created_at) FROM crashes
+		WHERE group_id IN (`+placeholders+`) GROUP BY group_id`, args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var groupID, latestID string
+		var users int
+		var latest interface{}
+		if err := rows.Scan(&groupID, &users, &latestID, &latest); err != nil {
+			return err
+		}
+		if g := byID[groupID]; g != nil {
+			g.AffectedUsers = users
+			g.LatestCrashID = latestID
+		}
+	}
+	return rows.Err()
+}
+
+func (r *SQLiteRepository) CountGroups(ctx context.Context, appID string) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM crash_groups WHERE app_id = ?`, appID,
+	).Scan(&count)
+	return count, err
+}
+
+func (r *SQLiteRepository) DeleteGroup(ctx context.Context, id string) ([]string, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	paths, err := deleteGroupTx(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	return paths, tx.Commit()
+}
+
+func (r *SQLiteRepository) EvictClosedGroups(ctx context.Context, appID string, limit int) (int, []string, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id FROM crash_groups WHERE app_id = ? AND status IN (?, ?) ORDER BY last_seen ASC LIMIT ?`,
+		appID, string(core.GroupStatusResolved), string(core.GroupStatusIgnored), limit,
+	)
+	if err != nil {
+		return 0, nil, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	var paths []string
+	for _, id := range ids {
+		groupPaths, err := deleteGroupTx(ctx, tx, id)
+		if err != nil {
+			return 0, nil, err
+		}
+		paths = append(paths, groupPaths...)
+	}
+	return len(ids), paths, tx.Commit()
+}
+
+// deleteGroupTx deletes a group and its crashes within tx, returning the
+// deleted crashes' log file paths
+func deleteGroupTx(ctx context.Context, tx *sql.Tx, id string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT log_file_path FROM crashes WHERE group_id = ? AND log_file_path IS NOT NULL AND log_file_path != ''`, id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM crashes WHERE group_id = ?`, id); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM merged_fingerprints WHERE group_id = ?`, id); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM crash_groups WHERE id = ?`, id); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+func (r *SQLiteRepository) MergeGroups(ctx context.Context, sourceID, targetID string) (*core.CrashGroup, error) {
+	if sourceID == targetID {
+		return nil, ErrMergeSameGroup
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var groups [2]*core.CrashGroup
+	for i, id := range []string{sourceID, targetID} {
+		groups[i], err = scanGroup(tx.QueryRowContext(ctx,
+			`SELECT `+groupColumns+` FROM crash_groups WHERE id = ?`, id,
+		))
+		if err == sql.ErrNoRows {
+			return nil, ErrGroupNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	source, target := groups[0], groups[1]
+	if source.AppID != target.AppID {
+		return nil, ErrMergeDifferentApps
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE crashes SET group_id = ? WHERE group_id = ?`, target.ID, source.ID,
+	); err != nil {
+		return nil, err
+	}
+
+	target.OccurrenceCount += source.OccurrenceCount
+	if source.FirstSeen.Before(target.FirstSeen) {
+		target.FirstSeen = source.FirstSeen
+	}
+	if source.LastSeen.After(target.LastSeen) {
+		target.LastSeen = source.LastSeen
+	}
+	target.TrendKey = core.AddTrend(target.TrendKey, source.TrendKey)
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE crash_groups SET occurrence_count = ?, first_seen = ?, last_seen = ?, trend_key = ? WHERE id = ?`,
+		target.OccurrenceCount, target.FirstSeen, target.LastSeen, target.TrendKey, target.ID,
+	); err != nil {
+		return nil, err
+	}
+
+	// Route the source's fingerprint, and any merged into it earlier, to the target
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE merged_fingerprints SET group_id = ? WHERE group_id = ?`, target.ID, source.ID,
+	); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT OR REPLACE INTO merged_fingerprints (app_id, fingerprint, group_id) VALUES (?, ?, ?)`,
+		source.AppID, source.Fingerprint, target.ID,
+	); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM crash_groups WHERE id = ?`, source.ID); err != nil {
+		return nil, err
 	}
-	count, _ := result.RowsAffected()
-	return int(count), nil
+	return target, tx.Commit()
 }
 
-// Crash group operations
-func (r *SQLiteRepository) GetOrCreateGroup(ctx context.Context, crash *core.Crash) (*core.CrashGroup, bool, error) {
+func (r *SQLiteRepository) SplitGroup(ctx context.Context, groupID string, crashIDs []string, newGroup *core.CrashGroup) (*core.CrashGroup, *core.CrashGroup, error) {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, false, err
+		return nil, nil, err
 	}
 	defer tx.Rollback()
 
-	// Try to find existing group
-	group := &core.CrashGroup{}
-	err = tx.QueryRowContext(ctx,
-		`SELECT id, app_id, fingerprint, error_type, error_message, first_seen, last_seen, occurrence_count, status, assigned_to, notes
-		FROM crash_groups WHERE app_id = ? AND fingerprint = ?`,
-		crash.AppID, crash.Fingerprint,
-	).Scan(&group.ID, &group.AppID, &group.Fingerprint, &group.ErrorType, &group.ErrorMessage,
-		&group.FirstSeen, &group.LastSeen, &group.OccurrenceCount, &group.Status, &group.AssignedTo, &group.Notes)
+	group, err := scanGroup(tx.QueryRowContext(ctx,
+		`SELECT `+groupColumns+` FROM crash_groups WHERE id = ?`, groupID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil, ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
 
-	if err == nil {
-		// Group exists, update it
-		_, err = tx.ExecContext(ctx,
-			`UPDATE crash_groups SET last_seen = ?, occurrence_count = occurrence_count + 1 WHERE id = ?`,
-			crash.CreatedAt, group.ID,
-		)
-		if err != nil {
-			return nil, false, err
-		}
-		group.LastSeen = crash.CreatedAt
-		group.OccurrenceCount++
-		return group, false, tx.Commit()
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO crash_groups (id, app_id, fingerprint, title, error_type, error_message, first_seen, last_seen, occurrence_count, status, assigned_to, notes, split_key, culprit)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, 0, ?, ?, ?, ?, ?)`,
+		newGroup.ID, group.AppID, newGroup.Fingerprint, newGroup.Title, newGroup.ErrorType, newGroup.ErrorMessage,
+		group.FirstSeen, group.LastSeen, newGroup.Status, newGroup.AssignedTo, newGroup.Notes, newGroup.SplitKey, newGroup.Culprit,
+	); err != nil {
+		return nil, nil, err
 	}
 
-	if err != sql.ErrNoRows {
-		return nil, false, err
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(crashIDs)), ",")
+	args := []interface{}{newGroup.ID, group.ID}
+	for _, id := range crashIDs {
+		args = append(args, id)
+	}
+	result, err := tx.ExecContext(ctx,
+		`UPDATE crashes SET group_id = ? WHERE group_id = ? AND id IN (`+placeholders+`)`, args...,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	if moved, err := result.RowsAffected(); err != nil {
+		return nil, nil, err
+	} else if int(moved) != len(crashIDs) {
+		return nil, nil, ErrCrashNotInGroup
 	}
 
-	// Create new group
-	group = &core.CrashGroup{
-		ID:              crash.GroupID,
-		AppID:           crash.AppID,
-		Fingerprint:     crash.Fingerprint,
-		ErrorType:       crash.ErrorType,
-		ErrorMessage:    crash.ErrorMessage,
-		FirstSeen:       crash.CreatedAt,
-		LastSeen:        crash.CreatedAt,
-		OccurrenceCount: 1,
-		Status:          string(core.GroupStatusOpen),
+	remaining, first, last, err := crashSpanTx(ctx, tx, group.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if remaining == 0 {
+		return nil, nil, ErrSplitWholeGroup
+	}
+	// Occurrences without a stored crash, such as aggregate-only ones, stay
+	// with the original group
+	group.OccurrenceCount = max(group.OccurrenceCount-len(crashIDs), remaining)
+	group.FirstSeen, group.LastSeen = first, last
+
+	split := *newGroup
+	split.AppID = group.AppID
+	if split.OccurrenceCount, split.FirstSeen, split.LastSeen, err = crashSpanTx(ctx, tx, split.ID); err != nil {
+		return nil, nil, err
+	}
+	if split.TrendKey, err = crashTrendKeyTx(ctx, tx, split.ID); err != nil {
+		return nil, nil, err
 	}
+	group.TrendKey = core.SubTrend(group.TrendKey, split.TrendKey)
+
+	for _, g := range []*core.CrashGroup{group, &split} {
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE crash_groups SET occurrence_count = ?, first_seen = ?, last_seen = ?, trend_key = ? WHERE id = ?`,
+			g.OccurrenceCount, g.FirstSeen, g.LastSeen, g.TrendKey, g.ID,
+		); err != nil {
+			return nil, nil, err
+		}
+	}
+	return group, &split, tx.Commit()
+}
 
-	_, err = tx.ExecContext(ctx,
-		`INSERT INTO crash_groups (id, app_id, fingerprint, error_type, error_message, first_seen, last_seen, occurrence_count, status)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		group.ID, group.AppID, group.Fingerprint, group.ErrorType, group.ErrorMessage,
-		group.FirstSeen, group.LastSeen, group.OccurrenceCount, group.Status,
-	)
+// crashTrendKeyTx returns the trend key of a group's stored crashes
+func crashTrendKeyTx(ctx context.Context, tx *sql.Tx, groupID string) (float64, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT created_at FROM crashes WHERE group_id = ?`, groupID)
 	if err != nil {
-		return nil, false, err
+		return 0, err
 	}
+	defer rows.Close()
 
-	return group, true, tx.Commit()
+	var key float64
+	for rows.Next() {
+		var createdAt time.Time
+		if err := rows.Scan(&createdAt); err != nil {
+			return 0, err
+		}
+		key = core.AddTrend(key, core.TrendKey(createdAt))
+	}
+	return key, rows.Err()
 }
 
-func (r *SQLiteRepository) GetGroup(ctx context.Context, id string) (*core.CrashGroup, error) {
-	group := &core.CrashGroup{}
-	var assignedTo, notes sql.NullString
-	err := r.db.QueryRowContext(ctx,
-		`SELECT id, app_id, fingerprint, error_type, error_message, first_seen, last_seen, occurrence_count, status, assigned_to, notes
-		FROM crash_groups WHERE id = ?`, id,
-	).Scan(&group.ID, &group.AppID, &group.Fingerprint, &group.ErrorType, &group.ErrorMessage,
-		&group.FirstSeen, &group.LastSeen, &group.OccurrenceCount, &group.Status, &assignedTo, &notes)
-	if err == sql.ErrNoRows {
-		return nil, nil
+// crashSpanTx returns the number of crashes in a group and the creation
+// times of its first and last. The times are read as columns rather than
+// aggregates so the driver scans them as times.
+func crashSpanTx(ctx context.Context, tx *sql.Tx, groupID string) (int, time.Time, time.Time, error) {
+	var count int
+	var first, last time.Time
+	if err := tx.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM crashes WHERE group_id = ?`, groupID,
+	).Scan(&count); err != nil || count == 0 {
+		return 0, first, last, err
 	}
-	group.AssignedTo = assignedTo.String
-	group.Notes = notes.String
-	return group, err
+	if err := tx.QueryRowContext(ctx,
+		`SELECT created_at FROM crashes WHERE group_id = ? ORDER BY created_at ASC LIMIT 1`, groupID,
+	).Scan(&first); err != nil {
+		return 0, first, last, err
+	}
+	if err := tx.QueryRowContext(ctx,
+		`SELECT created_at FROM crashes WHERE group_id = ? ORDER BY created_at DESC LIMIT 1`, groupID,
+	).Scan(&last); err != nil {
+		return 0, first, last, err
+	}
+	return count, first, last, nil
 }
 
 func (r *SQLiteRepository) ListGroups(ctx context.Context, filter GroupFilter) ([]*core.CrashGroup, int, error) {
@@ -438,9 +1425,13 @@ func (r *SQLiteRepository) ListGroups(ctx context.Context, filter GroupFilter) (
 		args = append(args, filter.ErrorType)
 	}
 	if filter.Search != "" {
-		conditions = append(conditions, "(error_type LIKE ? OR error_message LIKE ?)")
+		conditions = append(conditions, "(error_type LIKE ? OR error_message LIKE ? OR title LIKE ?)")
 		searchTerm := "%" + filter.Search + "%"
-		args = append(args, searchTerm, searchTerm)
+		args = append(args, searchTerm, searchTerm, searchTerm)
+	}
+	if filter.StaleDays > 0 {
+		conditions = append(conditions, "status = ? AND first_seen <= ? AND (assigned_to IS NULL OR assigned_to = '')")
+		args = append(args, string(core.GroupStatusOpen), time.Now().AddDate(0, 0, -filter.StaleDays))
 	}
 
 	whereClause := ""
@@ -456,7 +1447,9 @@ func (r *SQLiteRepository) ListGroups(ctx context.Context, filter GroupFilter) (
 
 	// Determine sort
 	sortBy := "last_seen"
-	if filter.SortBy != "" {
+	if filter.SortBy == "trending" {
+		sortBy = "trend_key"
+	} else if filter.SortBy != "" {
 		sortBy = filter.SortBy
 	}
 	sortOrder := "DESC"
@@ -467,11 +1460,31 @@ func (r *SQLiteRepository) ListGroups(ctx context.Context, filter GroupFilter) (
 	if filter.Limit == 0 {
 		filter.Limit = 50
 	}
+	if filter.Cursor != "" {
+		if sortBy != "first_seen" && sortBy != "last_seen" {
+			return nil, 0, ErrCursorSort
+		}
+		after, afterID, err := DecodeCursor(filter.Cursor)
+		if err != nil {
+			return nil, 0, err
+		}
+		op := "<"
+		if sortOrder == "ASC" {
+			op = ">"
+		}
+		cursorCondition := fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", sortBy, op)
+		if whereClause == "" {
+			whereClause = "WHERE " + cursorCondition
+		} else {
+			whereClause += " AND " + cursorCondition
+		}
+		args = append(args, after, after, afterID)
+		filter.Offset = 0
+	}
 
 	query := fmt.Sprintf(
-		`SELECT id, app_id, fingerprint, error_type, error_message, first_seen, last_seen, occurrence_count, status, assigned_to, notes
-		FROM crash_groups %s ORDER BY %s %s LIMIT ? OFFSET ?`,
-		whereClause, sortBy, sortOrder,
+		`SELECT %s FROM crash_groups %s ORDER BY %s %s, id %s LIMIT ? OFFSET ?`,
+		groupColumns, whereClause, sortBy, sortOrder, sortOrder,
 	)
 	args = append(args, filter.Limit, filter.Offset)
 
@@ -483,14 +1496,10 @@ func (r *SQLiteRepository) ListGroups(ctx context.Context, filter GroupFilter) (
 
 	var groups []*core.CrashGroup
 	for rows.Next() {
-		group := &core.CrashGroup{}
-		var assignedTo, notes sql.NullString
-		if err := rows.Scan(&group.ID, &group.AppID, &group.Fingerprint, &group.ErrorType, &group.ErrorMessage,
-			&group.FirstSeen, &group.LastSeen, &group.OccurrenceCount, &group.Status, &assignedTo, &notes); err != nil {
+		group, err := scanGroup(rows)
+		if err != nil {
 			return nil, 0, err
 		}
-		group.AssignedTo = assignedTo.String
-		group.Notes = notes.String
 		groups = append(groups, group)
 	}
 	return groups, total, rows.Err()
@@ -503,16 +1512,20 @@ func (r *SQLiteRepository) UpdateGroupStatus(ctx context.Context, id string, sta
 
 func (r *SQLiteRepository) UpdateGroup(ctx context.Context, group *core.CrashGroup) error {
 	_, err := r.db.ExecContext(ctx,
-		`UPDATE crash_groups SET status = ?, assigned_to = ?, notes = ? WHERE id = ?`,
-		group.Status, group.AssignedTo, group.Notes, group.ID,
+		`UPDATE crash_groups SET status = ?, assigned_to = ?, notes = ?, aggregate_only = ? WHERE id = ?`,
+		group.Status, group.AssignedTo, group.Notes, group.AggregateOnly, group.ID,
 	)
 	return err
 }
 
 func (r *SQLiteRepository) IncrementGroupCount(ctx context.Context, id string) error {
+	now := time.Now()
+	key := core.TrendKey(now)
 	_, err := r.db.ExecContext(ctx,
-		`UPDATE crash_groups SET occurrence_count = occurrence_count + 1, last_seen = ? WHERE id = ?`,
-		time.Now(), id,
+		`UPDATE crash_groups SET occurrence_count = occurrence_count + 1, last_seen = ?,
+			trend_key = MAX(COALESCE(trend_key, 0), ?) + LN(1 + EXP(-ABS(COALESCE(trend_key, 0) - ?)))
+		WHERE id = ?`,
+		now, key, key, id,
 	)
 	return err
 }
@@ -595,7 +1608,7 @@ func (r *SQLiteRepository) GetAppStats(ctx context.Context, appID string) (*core
 	stats := &core.CrashStats{AppID: appID}
 
 	// Total crashes
-	r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM crashes WHERE app_id = ?`, appID).Scan(&stats.TotalCrashes)
+	r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM crashes WHERE app_id = ? AND quarantine_reason IS NULL`, appID).Scan(&stats.TotalCrashes)
 
 	// Total groups
 	r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM crash_groups WHERE app_id = ?`, appID).Scan(&stats.TotalGroups)
@@ -605,11 +1618,11 @@ func (r *SQLiteRepository) GetAppStats(ctx context.Context, appID string) (*core
 
 	// Crashes in time periods
 	now := time.Now()
-	r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM crashes WHERE app_id = ? AND created_at >= ?`,
+	r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM crashes WHERE app_id = ? AND quarantine_reason IS NULL AND created_at >= ?`,
 		appID, now.Add(-24*time.Hour)).Scan(&stats.CrashesLast24h)
-	r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM crashes WHERE app_id = ? AND created_at >= ?`,
+	r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM crashes WHERE app_id = ? AND quarantine_reason IS NULL AND created_at >= ?`,
 		appID, now.Add(-7*24*time.Hour)).Scan(&stats.CrashesLast7d)
-	r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM crashes WHERE app_id = ? AND created_at >= ?`,
+	r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM crashes WHERE app_id = ? AND quarantine_reason IS NULL AND created_at >= ?`,
 		appID, now.Add(-30*24*time.Hour)).Scan(&stats.CrashesLast30d)
 
 	// Top errors
@@ -625,10 +1638,15 @@ func (r *SQLiteRepository) GetAppStats(ctx context.Context, appID string) (*core
 		}
 	}
 
+	// Crashes per fingerprint algorithm version
+	if versions, err := r.CountCrashesByFingerprintVersion(ctx, appID); err == nil {
+		stats.FingerprintVersions = versions
+	}
+
 	// Crash trend (last 30 days)
 	rows, err = r.db.QueryContext(ctx,
 		`SELECT DATE(created_at) as date, COUNT(*) as count FROM crashes
-		WHERE app_id = ? AND created_at >= ? GROUP BY DATE(created_at) ORDER BY date`,
+		WHERE app_id = ? AND quarantine_reason IS NULL AND created_at >= ? GROUP BY DATE(created_at) ORDER BY date`,
 		appID, now.Add(-30*24*time.Hour))
 	if err == nil {
 		defer rows.Close()
@@ -642,6 +1660,113 @@ func (r *SQLiteRepository) GetAppStats(ctx context.Context, appID string) (*core
 	return stats, nil
 }
 
+func (r *SQLiteRepository) ListAppStatsSummaries(ctx context.Context) (map[string]core.AppStatsSummary, error) {
+	// Same counts as GetAppStats, computed for all apps at once
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT a.id,
+			(SELECT COUNT(*) FROM crashes WHERE app_id = a.id AND quarantine_reason IS NULL),
+			(SELECT COUNT(*) FROM crash_groups WHERE app_id = a.id AND status = 'open'),
+			(SELECT COUNT(*) FROM crashes WHERE app_id = a.id AND quarantine_reason IS NULL AND created_at >= ?)
+		FROM apps a`,
+		time.Now().Add(-24*time.Hour),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summaries := make(map[string]core.AppStatsSummary)
+	for rows.Next() {
+		var appID string
+		var summary core.AppStatsSummary
+		if err := rows.Scan(&appID, &summary.TotalCrashes, &summary.OpenGroups, &summary.CrashesLast24h); err != nil {
+			return nil, err
+		}
+		summaries[appID] = summary
+	}
+	return summaries, rows.Err()
+}
+
+// CountCrashesByGroupForVersion returns the number of crashes in each group
+// for one app version, busiest first
+func (r *SQLiteRepository) CountCrashesByGroupForVersion(ctx context.Context, appID, appVersion string) ([]core.ErrorSummary, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT g.id, g.error_type, g.error_message, COUNT(*) AS count
+		FROM crashes c JOIN crash_groups g ON g.id = c.group_id
+		WHERE c.app_id = ? AND c.app_version = ? AND c.quarantine_reason IS NULL
+		GROUP BY g.id ORDER BY count DESC`,
+		appID, appVersion,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summaries []core.ErrorSummary
+	for rows.Next() {
+		var summary core.ErrorSummary
+		if err := rows.Scan(&summary.GroupID, &summary.ErrorType, &summary.ErrorMessage, &summary.Count); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, rows.Err()
+}
+
+// CountCrashesByGroupForUser returns the number of a user's crashes in each
+// group, busiest first
+func (r *SQLiteRepository) CountCrashesByGroupForUser(ctx context.Context, appID, userID string) ([]core.UserCrashGroup, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT g.id, g.error_type, g.error_message, g.status, COUNT(*) AS count
+		FROM crashes c JOIN crash_groups g ON g.id = c.group_id
+		WHERE c.app_id = ? AND c.user_id = ? AND c.quarantine_reason IS NULL
+		GROUP BY g.id ORDER BY count DESC`,
+		appID, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []core.UserCrashGroup
+	for rows.Next() {
+		var group core.UserCrashGroup
+		if err := rows.Scan(&group.GroupID, &group.ErrorType, &group.ErrorMessage, &group.Status, &group.Count); err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	return groups, rows.Err()
+}
+
+// CountCrashesByFingerprintVersion returns the number of crashes recorded under
+// each fingerprint algorithm version, optionally scoped to an app
+func (r *SQLiteRepository) CountCrashesByFingerprintVersion(ctx context.Context, appID string) (map[int]int, error) {
+	query := `SELECT COALESCE(fingerprint_version, 1) AS version, COUNT(*) FROM crashes WHERE quarantine_reason IS NULL`
+	var args []interface{}
+	if appID != "" {
+		query += " AND app_id = ?"
+		args = append(args, appID)
+	}
+	query += " GROUP BY version"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var version, count int
+		if err := rows.Scan(&version, &count); err != nil {
+			return nil, err
+		}
+		counts[version] = count
+	}
+	return counts, rows.Err()
+}
+
 // Settings operations
 func (r *SQLiteRepository) GetSetting(ctx context.Context, key string) (string, error) {
 	var value string
@@ -659,3 +1784,188 @@ func (r *SQLiteRepository) SetSetting(ctx context.Context, key, value string) er
 	)
 	return err
 }
+
+// User operations
+
+const userColumns = `id, username, password_hash, role, must_change_password, created_at`
+
+func scanUser(row rowScanner) (*core.User, error) {
+	var user core.User
+	if err := row.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.MustChangePassword, &user.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *SQLiteRepository) CreateUser(ctx context.Context, user *core.User) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO users (`+userColumns+`) VALUES (?, ?, ?, ?, ?, ?)`,
+		user.ID, user.Username, user.PasswordHash, user.Role, user.MustChangePassword, user.CreatedAt,
+	)
+	return err
+}
+
+func (r *SQLiteRepository) GetUser(ctx context.Context, id string) (*core.User, error) {
+	user, err := scanUser(r.db.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return user, err
+}
+
+func (r *SQLiteRepository) GetUserByUsername(ctx context.Context, username string) (*core.User, error) {
+	user, err := scanUser(r.db.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE username = ?`, username))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return user, err
+}
+
+func (r *SQLiteRepository) ListUsers(ctx context.Context) ([]*core.User, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+userColumns+` FROM users ORDER BY username`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]*core.User, 0)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (r *SQLiteRepository) UpdateUserPassword(ctx context.Context, id, passwordHash string, mustChange bool) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE users SET password_hash = ?, must_change_password = ? WHERE id = ?`,
+		passwordHash, mustChange, id,
+	)
+	return err
+}
+
+// Session operations
+
+const sessionColumns = `token_hash, user_id, username, role, created_at, expires_at`
+
+func (r *SQLiteRepository) CreateSession(ctx context.Context, session *core.Session) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO sessions (`+sessionColumns+`) VALUES (?, ?, ?, ?, ?, ?)`,
+		session.TokenHash, session.UserID, session.Username, session.Role, session.CreatedAt, session.ExpiresAt,
+	)
+	return err
+}
+
+func (r *SQLiteRepository) GetSession(ctx context.Context, tokenHash string) (*core.Session, error) {
+	var session core.Session
+	err := r.db.QueryRowContext(ctx, `SELECT `+sessionColumns+` FROM sessions WHERE token_hash = ?`, tokenHash).Scan(
+		&session.TokenHash, &session.UserID, &session.Username, &session.Role, &session.CreatedAt, &session.ExpiresAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *SQLiteRepository) DeleteSession(ctx context.Context, tokenHash string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM sessions WHERE token_hash = ?`, tokenHash)
+	return err
+}
+
+func (r *SQLiteRepository) DeleteExpiredSessions(ctx context.Context, now time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM sessions WHERE expires_at < ?`, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (r *SQLiteRepository) CountActiveSessions(ctx context.Context, now time.Time) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM sessions WHERE expires_at >= ?`, now).Scan(&count)
+	return count, err
+}
+
+// Alert queue operations
+
+func (r *SQLiteRepository) EnqueueAlertEvent(ctx context.Context, event *core.QueuedAlertEvent) error {
+	data, err := json.Marshal(event.Event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert event: %w", err)
+	}
+	result, err := r.db.ExecContext(ctx,
+		`INSERT INTO alert_queue (event, alert_id, attempts, next_attempt_at, last_error, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		string(data), event.AlertID, event.Attempts, event.NextAttemptAt, event.LastError, event.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	event.ID, err = result.LastInsertId()
+	return err
+}
+
+func (r *SQLiteRepository) ClaimAlertEvents(ctx context.Context, dueBy, claimUntil time.Time, limit int) ([]*core.QueuedAlertEvent, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, event, alert_id, attempts, next_attempt_at, last_error, created_at FROM alert_queue
+		WHERE next_attempt_at <= ? ORDER BY next_attempt_at, id LIMIT ?`,
+		dueBy, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	events, err := scanQueuedAlertEvents(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, event := range events {
+		if _, err := tx.ExecContext(ctx, `UPDATE alert_queue SET next_attempt_at = ? WHERE id = ?`, claimUntil, event.ID); err != nil {
+			return nil, err
+		}
+		event.NextAttemptAt = claimUntil
+	}
+	return events, tx.Commit()
+}
+
+// scanQueuedAlertEvents scans and closes rows of alert queue entries
+func scanQueuedAlertEvents(rows *sql.Rows) ([]*core.QueuedAlertEvent, error) {
+	defer rows.Close()
+	var events []*core.QueuedAlertEvent
+	for rows.Next() {
+		var event core.QueuedAlertEvent
+		var data string
+		if err := rows.Scan(&event.ID, &data, &event.AlertID, &event.Attempts, &event.NextAttemptAt, &event.LastError, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(data), &event.Event); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal alert event %d: %w", event.ID, err)
+		}
+		events = append(events, &event)
+	}
+	return events, rows.Err()
+}
+
+func (r *SQLiteRepository) RetryAlertEvent(ctx context.Context, id int64, attempts int, nextAttemptAt time.Time, lastError string) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE alert_queue SET attempts = ?, next_attempt_at = ?, last_error = ? WHERE id = ?`,
+		attempts, nextAttemptAt, lastError, id,
+	)
+	return err
+}
+
+func (r *SQLiteRepository) DeleteAlertEvent(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM alert_queue WHERE id = ?`, id)
+	return err
+}
@@ -0,0 +1,433 @@
+package storage
+
+import (
+	"context"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/flakerimi/inceptor/internal/core"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+func TestMain(m *testing.M) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	os.Exit(m.Run())
+}
+
+// newTestRepository opens a SQLite repository in a temporary directory
+func newTestRepository(t *testing.T) *SQLiteRepository {
+	t.Helper()
+
+	repo, err := NewSQLiteRepository(filepath.Join(t.TempDir(), "inceptor.db"))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	return repo
+}
+
+// createTestApp creates an app with the given ID
+func createTestApp(t *testing.T, repo Repository, id string) *core.App {
+	t.Helper()
+
+	app := &core.App{
+		ID:            id,
+		Name:          "App " + id,
+		APIKeyID:      uuid.New().String(),
+		APIKeyHash:    "hash-" + id,
+		CreatedAt:     time.Now().UTC(),
+		RetentionDays: 30,
+	}
+	if err := repo.CreateApp(context.Background(), app); err != nil {
+		t.Fatalf("create app: %v", err)
+	}
+	return app
+}
+
+// addTestCrash groups and stores a crash the way ingestion does, returning
+// it with its group ID set
+func addTestCrash(t *testing.T, repo Repository, crash *core.Crash) *core.Crash {
+	t.Helper()
+
+	ctx := context.Background()
+	if crash.ID == "" {
+		crash.ID = uuid.New().String()
+	}
+	if crash.CreatedAt.IsZero() {
+		crash.CreatedAt = time.Now().UTC()
+	}
+	if crash.Fingerprint == "" {
+		crash.Fingerprint = "fp-" + crash.ErrorType
+	}
+	crash.GroupID = uuid.New().String()
+	group, _, err := repo.GetOrCreateGroup(ctx, crash)
+	if err != nil {
+		t.Fatalf("group crash: %v", err)
+	}
+	crash.GroupID = group.ID
+	if err := repo.CreateCrash(ctx, crash); err != nil {
+		t.Fatalf("create crash: %v", err)
+	}
+	return crash
+}
+
+func TestCountCrashesByFingerprintVersion(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+	createTestApp(t, repo, "app-1")
+	createTestApp(t, repo, "app-2")
+
+	for _, crash := range []*core.Crash{
+		{AppID: "app-1", ErrorType: "A", FingerprintVersion: 1},
+		{AppID: "app-1", ErrorType: "A", FingerprintVersion: 1},
+		{AppID: "app-1", ErrorType: "B", FingerprintVersion: 2},
+		{AppID: "app-2", ErrorType: "A", FingerprintVersion: 2},
+	} {
+		addTestCrash(t, repo, crash)
+	}
+
+	tests := []struct {
+		appID string
+		want  map[int]int
+	}{
+		{"app-1", map[int]int{1: 2, 2: 1}},
+		{"app-2", map[int]int{2: 1}},
+		{"", map[int]int{1: 2, 2: 2}},
+	}
+	for _, tt := range tests {
+		got, err := repo.CountCrashesByFingerprintVersion(ctx, tt.appID)
+		if err != nil {
+			t.Fatalf("count for %q: %v", tt.appID, err)
+		}
+		if !maps.Equal(got, tt.want) {
+			t.Errorf("counts for %q = %v, want %v", tt.appID, got, tt.want)
+		}
+	}
+}
+
+func TestGetCrashByEventID(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+	createTestApp(t, repo, "app-1")
+	createTestApp(t, repo, "app-2")
+
+	now := time.Now().UTC()
+	old := addTestCrash(t, repo, &core.Crash{AppID: "app-1", EventID: "evt-1", ErrorType: "A", CreatedAt: now.Add(-48 * time.Hour)})
+	recent := addTestCrash(t, repo, &core.Crash{AppID: "app-1", EventID: "evt-2", ErrorType: "A", CreatedAt: now.Add(-time.Hour)})
+	addTestCrash(t, repo, &core.Crash{AppID: "app-2", EventID: "evt-3", ErrorType: "A", CreatedAt: now})
+
+	tests := []struct {
+		name    string
+		appID   string
+		eventID string
+		since   time.Time
+		want    string
+	}{
+		{"within window", "app-1", "evt-2", now.Add(-24 * time.Hour), recent.ID},
+		{"beyond window", "app-1", "evt-1", now.Add(-24 * time.Hour), ""},
+		{"no window", "app-1", "evt-1", time.Time{}, old.ID},
+		{"other app's event", "app-1", "evt-3", time.Time{}, ""},
+		{"unknown event", "app-1", "evt-4", time.Time{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crash, err := repo.GetCrashByEventID(ctx, tt.appID, tt.eventID, tt.since)
+			if err != nil {
+				t.Fatalf("GetCrashByEventID: %v", err)
+			}
+			got := ""
+			if crash != nil {
+				got = crash.ID
+			}
+			if got != tt.want {
+				t.Errorf("crash = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// A reused event ID finds its latest crash
+	latest := addTestCrash(t, repo, &core.Crash{AppID: "app-1", EventID: "evt-1", ErrorType: "A", CreatedAt: now})
+	crash, err := repo.GetCrashByEventID(ctx, "app-1", "evt-1", time.Time{})
+	if err != nil || crash == nil || crash.ID != latest.ID {
+		t.Errorf("reused event ID found %v (%v), want the latest crash %s", crash, err, latest.ID)
+	}
+}
+
+func TestListCrashesHasMetadata(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+	createTestApp(t, repo, "app-1")
+
+	flagged := addTestCrash(t, repo, &core.Crash{AppID: "app-1", ErrorType: "A", Metadata: map[string]interface{}{"feature_flag": "new_checkout", "screen": "cart"}})
+	flaggedOff := addTestCrash(t, repo, &core.Crash{AppID: "app-1", ErrorType: "A", Metadata: map[string]interface{}{"feature_flag": false}})
+	nullFlag := addTestCrash(t, repo, &core.Crash{AppID: "app-1", ErrorType: "A", Metadata: map[string]interface{}{"feature_flag": nil}})
+	screenOnly := addTestCrash(t, repo, &core.Crash{AppID: "app-1", ErrorType: "A", Metadata: map[string]interface{}{"screen": "home"}})
+	addTestCrash(t, repo, &core.Crash{AppID: "app-1", ErrorType: "A"})
+
+	tests := []struct {
+		name string
+		keys []string
+		want []string
+	}{
+		{"key present with any value", []string{"feature_flag"}, []string{flagged.ID, flaggedOff.ID, nullFlag.ID}},
+		{"every key required", []string{"feature_flag", "screen"}, []string{flagged.ID}},
+		{"other key", []string{"screen"}, []string{flagged.ID, screenOnly.ID}},
+		{"missing key", []string{"user_tier"}, nil},
+		{"nested keys aren't top-level", []string{"new_checkout"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crashes, total, err := repo.ListCrashes(ctx, CrashFilter{AppID: "app-1", HasMetadata: tt.keys, Limit: 50})
+			if err != nil {
+				t.Fatalf("ListCrashes: %v", err)
+			}
+			var got []string
+			for _, crash := range crashes {
+				got = append(got, crash.ID)
+			}
+			slices.Sort(got)
+			want := slices.Clone(tt.want)
+			slices.Sort(want)
+			if !slices.Equal(got, want) || total != len(want) {
+				t.Errorf("crashes = %v (total %d), want %v", got, total, want)
+			}
+		})
+	}
+}
+
+// groupIDs returns the IDs of groups, sorted
+func groupIDs(groups []*core.CrashGroup) []string {
+	var ids []string
+	for _, g := range groups {
+		ids = append(ids, g.ID)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestListGroupsStaleUntriaged(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+	createTestApp(t, repo, "app-1")
+	now := time.Now().UTC()
+
+	staleOpen := addTestCrash(t, repo, &core.Crash{AppID: "app-1", ErrorType: "StaleOpen", CreatedAt: now.AddDate(0, 0, -30)})
+	addTestCrash(t, repo, &core.Crash{AppID: "app-1", ErrorType: "Recent", CreatedAt: now.AddDate(0, 0, -2)})
+	assigned := addTestCrash(t, repo, &core.Crash{AppID: "app-1", ErrorType: "Assigned", CreatedAt: now.AddDate(0, 0, -30)})
+	resolved := addTestCrash(t, repo, &core.Crash{AppID: "app-1", ErrorType: "Resolved", CreatedAt: now.AddDate(0, 0, -30)})
+	// Still occurring, but first seen long ago and never triaged
+	staleActive := addTestCrash(t, repo, &core.Crash{AppID: "app-1", ErrorType: "StaleActive", CreatedAt: now.AddDate(0, 0, -10)})
+	addTestCrash(t, repo, &core.Crash{AppID: "app-1", ErrorType: "StaleActive", CreatedAt: now})
+
+	group, err := repo.GetGroup(ctx, assigned.GroupID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	group.AssignedTo = "dana"
+	if err := repo.UpdateGroup(ctx, group); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UpdateGroupStatus(ctx, resolved.GroupID, string(core.GroupStatusResolved)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		staleDays int
+		want      []string
+	}{
+		{7, []string{staleOpen.GroupID, staleActive.GroupID}},
+		{14, []string{staleOpen.GroupID}},
+		{60, nil},
+	}
+	for _, tt := range tests {
+		groups, total, err := repo.ListGroups(ctx, GroupFilter{AppID: "app-1", StaleDays: tt.staleDays, Limit: 50})
+		if err != nil {
+			t.Fatalf("ListGroups: %v", err)
+		}
+		want := slices.Clone(tt.want)
+		slices.Sort(want)
+		if got := groupIDs(groups); !slices.Equal(got, want) || total != len(want) {
+			t.Errorf("stale_days %d: groups = %v (total %d), want %v", tt.staleDays, got, total, want)
+		}
+	}
+}
+
+func TestListAppVersions(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+	createTestApp(t, repo, "app-1")
+	createTestApp(t, repo, "app-2")
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, v := range []struct {
+		appID, version string
+	}{
+		{"app-1", "1.0.0"}, {"app-1", "1.1.0"}, {"app-1", "1.0.0"}, {"app-2", "9.0.0"}, {"app-1", "2.0.0"},
+	} {
+		if _, err := repo.RecordAppVersion(ctx, v.appID, v.version, start.Add(time.Duration(i)*time.Hour)); err != nil {
+			t.Fatalf("record version: %v", err)
+		}
+	}
+
+	tests := []struct {
+		appID string
+		limit int
+		want  []string
+	}{
+		{"app-1", 10, []string{"2.0.0", "1.1.0", "1.0.0"}},
+		{"app-1", 2, []string{"2.0.0", "1.1.0"}},
+		{"app-2", 10, []string{"9.0.0"}},
+		{"app-3", 10, nil},
+	}
+	for _, tt := range tests {
+		versions, err := repo.ListAppVersions(ctx, tt.appID, tt.limit)
+		if err != nil {
+			t.Fatalf("list versions: %v", err)
+		}
+		var got []string
+		for _, v := range versions {
+			got = append(got, v.Version)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("versions of %s (limit %d) = %v, want %v", tt.appID, tt.limit, got, tt.want)
+		}
+	}
+
+	// A version keeps the time it was first seen
+	versions, _ := repo.ListAppVersions(ctx, "app-1", 10)
+	if first := versions[2]; !first.FirstSeen.Equal(start) {
+		t.Errorf("1.0.0 first seen %v, want %v", first.FirstSeen, start)
+	}
+}
+
+func TestListCrashesCreatedBetween(t *testing.T) {
+	repo := newTestRepository(t)
+	app := createTestApp(t, repo, "app-1")
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, crash := range []*core.Crash{
+		{ID: "a", CreatedAt: start},
+		{ID: "b", CreatedAt: start.Add(time.Minute)},
+		{ID: "c", CreatedAt: start.Add(time.Minute)},
+		{ID: "d", CreatedAt: start.Add(2 * time.Minute)},
+	} {
+		crash.AppID = app.ID
+		crash.ErrorType = "StateError"
+		addTestCrash(t, repo, crash)
+	}
+
+	tests := []struct {
+		name    string
+		after   time.Time
+		afterID string
+		before  time.Time
+		limit   int
+		want    []string
+	}{
+		{"from the start", time.Time{}, "", start.Add(time.Hour), 10, []string{"a", "b", "c", "d"}},
+		{"limited", time.Time{}, "", start.Add(time.Hour), 2, []string{"a", "b"}},
+		{"after a cursor", start.Add(time.Minute), "b", start.Add(time.Hour), 10, []string{"c", "d"}},
+		{"before excluded", time.Time{}, "", start.Add(2 * time.Minute), 10, []string{"a", "b", "c"}},
+		{"nothing after the last", start.Add(2 * time.Minute), "d", start.Add(time.Hour), 10, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crashes, err := repo.ListCrashesCreatedBetween(context.Background(), tt.after, tt.afterID, tt.before, tt.limit)
+			if err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			var got []string
+			for _, crash := range crashes {
+				got = append(got, crash.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("crashes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLiteCloseCheckpoints(t *testing.T) {
+	tests := []struct {
+		name string
+		apps int
+	}{
+		{"empty", 0},
+		{"written", 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "inceptor.db")
+			repo, err := NewSQLiteRepository(path)
+			if err != nil {
+				t.Fatalf("open database: %v", err)
+			}
+			for i := 0; i < tt.apps; i++ {
+				createTestApp(t, repo, uuid.New().String())
+			}
+			if err := repo.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			// Nothing is left in the write-ahead log
+			if info, err := os.Stat(path + "-wal"); err == nil && info.Size() != 0 {
+				t.Errorf("WAL is %d bytes after Close, want it checkpointed", info.Size())
+			}
+
+			repo, err = NewSQLiteRepository(path)
+			if err != nil {
+				t.Fatalf("reopen database: %v", err)
+			}
+			defer repo.Close()
+			apps, err := repo.ListApps(context.Background())
+			if err != nil || len(apps) != tt.apps {
+				t.Errorf("apps after reopening = %d, %v; want %d", len(apps), err, tt.apps)
+			}
+		})
+	}
+}
+
+func TestSQLiteSeedTrendKeys(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+	createTestApp(t, repo, "app-1")
+	lastSeen := time.Now().UTC().Add(-24 * time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		errorType string
+		count     int
+		wantScore float64
+	}{
+		{"StateError", 1, 0.5},
+		{"RangeError", 8, 4},
+	}
+	groups := make([]string, len(tests))
+	for i, tt := range tests {
+		for n := 0; n < tt.count; n++ {
+			groups[i] = addTestCrash(t, repo, &core.Crash{AppID: "app-1", ErrorType: tt.errorType, CreatedAt: lastSeen}).GroupID
+		}
+	}
+	// Groups from before trend keys were stored have none
+	if _, err := repo.db.Exec(`UPDATE crash_groups SET trend_key = NULL`); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	now := time.Now()
+	for i, tt := range tests {
+		group, err := repo.GetGroup(ctx, groups[i])
+		if err != nil || group == nil {
+			t.Fatalf("GetGroup: %v, %v", group, err)
+		}
+		group.ComputeActivity(now)
+		if group.TrendingScore < tt.wantScore-0.01 || group.TrendingScore > tt.wantScore+0.01 {
+			t.Errorf("%s: score = %v, want %v as if all %d occurrences were last seen", tt.errorType, group.TrendingScore, tt.wantScore, tt.count)
+		}
+	}
+}
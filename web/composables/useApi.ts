@@ -2,6 +2,7 @@ import type { Crash, CrashGroup, App, Alert, CrashStats, PaginatedResponse } fro
 
 interface AuthStatus {
   needs_password_change: boolean
+  proxy_login: boolean
 }
 
 interface LoginResponse {
@@ -62,10 +63,10 @@ export const useApi = () => {
     return await $fetch<AuthStatus>(`${baseUrl}/auth/status`)
   }
 
-  const login = async (password: string): Promise<LoginResponse> => {
+  const login = async (username: string, password: string): Promise<LoginResponse> => {
     const response = await $fetch<LoginResponse>(`${baseUrl}/auth/login`, {
       method: 'POST',
-      body: { password },
+      body: { username, password },
     })
     token.value = response.token
     needsPasswordChange.value = response.needs_password_change
@@ -75,6 +76,21 @@ export const useApi = () => {
     return response
   }
 
+  // Log in as the user a trusted SSO proxy already authenticated
+  const proxyLogin = async (): Promise<LoginResponse> => {
+    const response = await $fetch<LoginResponse>(`${baseUrl}/auth/proxy-login`, {
+      method: 'POST',
+      // Required by the server, so other sites can't log visitors in
+      headers: { 'X-Requested-With': 'XMLHttpRequest' },
+    })
+    token.value = response.token
+    needsPasswordChange.value = false
+    if (process.client) {
+      localStorage.setItem('inceptor_token', response.token)
+    }
+    return response
+  }
+
   const logout = async (): Promise<void> => {
     try {
       await $fetch(`${baseUrl}/auth/logout`, {
@@ -224,6 +240,7 @@ export const useApi = () => {
     loadToken,
     checkAuthStatus,
     login,
+    proxyLogin,
     logout,
     changePassword,
     getCrashes,
@@ -61,7 +61,7 @@ export interface App {
 export interface Alert {
   id: string
   app_id: string
-  type: 'webhook' | 'email' | 'slack'
+  type: 'webhook' | 'email' | 'slack' | 'discord' | 'teams' | 'pagerduty' | 'telegram'
   config: Record<string, any>
   enabled: boolean
   created_at: string